@@ -0,0 +1,119 @@
+// Package check provides lightweight assertions for notebook cells, to test code without writing
+// `%test` functions.
+//
+// When an assertion fails, it displays in the notebook the values compared, with a colored diff of
+// their structure, and exits the program with a non-zero status, so the cell is marked as failed.
+// Similar to testify's `require`. Example:
+//
+//	%%
+//	got, err := Parse("1+2")
+//	check.NoError(err)
+//	check.Equal(got, &Expr{Op: "+", Args: []int{1, 2}})
+//
+// Outside a notebook, the failures are printed to the standard error.
+package check
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui"
+)
+
+// ExitCode used when an assertion fails.
+var ExitCode = 1
+
+// Equal checks that got and want are deeply equal (see reflect.DeepEqual). Otherwise, it displays the
+// difference between them and exits the program.
+//
+// The optional msgAndArgs is a message (with its format arguments, as in fmt.Sprintf) describing what
+// is being checked.
+func Equal(got, want any, msgAndArgs ...any) {
+	if reflect.DeepEqual(got, want) {
+		return
+	}
+	gotLines := strings.Split(Format(got), "\n")
+	wantLines := strings.Split(Format(want), "\n")
+	fail("check.Equal", message(msgAndArgs), Diff(wantLines, gotLines))
+}
+
+// NoError checks that err is nil. Otherwise, it displays the error and exits the program.
+//
+// The optional msgAndArgs is a message (with its format arguments, as in fmt.Sprintf) describing what
+// is being checked.
+func NoError(err error, msgAndArgs ...any) {
+	if err == nil {
+		return
+	}
+	fail("check.NoError", message(msgAndArgs), []DiffLine{{Op: ' ', Text: fmt.Sprintf("%+v", err)}})
+}
+
+// True checks that cond is true. Otherwise, it displays the failure and exits the program.
+//
+// The optional msgAndArgs is a message (with its format arguments, as in fmt.Sprintf) describing what
+// is being checked.
+func True(cond bool, msgAndArgs ...any) {
+	if cond {
+		return
+	}
+	fail("check.True", message(msgAndArgs), nil)
+}
+
+// message formats the optional message of an assertion.
+func message(msgAndArgs []any) string {
+	if len(msgAndArgs) == 0 {
+		return ""
+	}
+	if format, ok := msgAndArgs[0].(string); ok {
+		return fmt.Sprintf(format, msgAndArgs[1:]...)
+	}
+	return fmt.Sprint(msgAndArgs...)
+}
+
+// fail reports the failed assertion and exits the program.
+//
+// The location of the assertion is printed to the standard error, where GoNB maps it to the cell line.
+func fail(assertion, msg string, lines []DiffLine) {
+	location := ""
+	if _, file, line, ok := runtime.Caller(2); ok {
+		location = fmt.Sprintf(" at %s:%d", file, line)
+	}
+	header := assertion + " failed" + location
+	if msg != "" {
+		header += ": " + msg
+	}
+	fmt.Fprintln(os.Stderr, header)
+	if gonbui.IsNotebook {
+		if len(lines) > 0 {
+			gonbui.DisplayHtml(diffHtml(lines))
+		}
+		gonbui.Sync()
+	} else {
+		for _, line := range lines {
+			fmt.Fprintf(os.Stderr, "%c %s\n", line.Op, line.Text)
+		}
+	}
+	os.Exit(ExitCode)
+}
+
+// diffHtml renders the diff lines, with the removed ("want") lines in red and added ("got") lines in green.
+func diffHtml(lines []DiffLine) string {
+	var sb strings.Builder
+	sb.WriteString(`<pre style="padding: 0.5em; border-left: 3px solid #d73a49">`)
+	for _, line := range lines {
+		style := ""
+		switch line.Op {
+		case '-':
+			style = "color: #b31d28; background-color: #ffeef0"
+		case '+':
+			style = "color: #22863a; background-color: #f0fff4"
+		}
+		sb.WriteString(fmt.Sprintf("<span style=\"%s\">%c %s</span>\n", style, line.Op, html.EscapeString(line.Text)))
+	}
+	sb.WriteString(`<span style="opacity: 0.6">(- want, + got)</span></pre>`)
+	return sb.String()
+}
@@ -0,0 +1,25 @@
+package check
+
+import (
+	"github.com/janpfeifer/gonb/gonbui"
+)
+
+// MaxDiffLines is the maximum number of lines of the values compared by Diff: larger values are not
+// diffed, all their lines are shown instead.
+const MaxDiffLines = gonbui.MaxDiffLines
+
+// Format returns a multi-line representation of the value, with one field, element or map entry per
+// line, in Go syntax, so the differences between values can be diffed line by line.
+// It is the same as gonbui.FormatValue.
+func Format(value any) string {
+	return gonbui.FormatValue(value)
+}
+
+// DiffLine is a line of the output of Diff.
+type DiffLine = gonbui.DiffLine
+
+// Diff returns the line diff from a to b, using their longest common subsequence.
+// It is the same as gonbui.DiffLines.
+func Diff(a, b []string) []DiffLine {
+	return gonbui.DiffLines(a, b)
+}
@@ -0,0 +1,630 @@
+package data
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the preview of [Arrow IPC](https://arrow.apache.org/docs/format/Columnar.html#serialization-and-interprocess-communication-ipc)
+// files (`.arrow` or `.feather` files, and `.arrows` streams), without depending on the Arrow module: the
+// metadata (FlatBuffers) is read directly, and the columns of primitive types, strings, dates and timestamps
+// (also dictionary encoded) are formatted as strings. Compressed record batches are not supported.
+
+// arrowMagic starts and ends the Arrow IPC file format.
+const arrowMagic = "ARROW1"
+
+// Types of the Arrow schema (the `Type` union of Schema.fbs).
+const (
+	arrowNull            = 1
+	arrowInt             = 2
+	arrowFloatingPoint   = 3
+	arrowBinary          = 4
+	arrowUtf8            = 5
+	arrowBool            = 6
+	arrowDecimal         = 7
+	arrowDate            = 8
+	arrowTime            = 9
+	arrowTimestamp       = 10
+	arrowInterval        = 11
+	arrowList            = 12
+	arrowStruct          = 13
+	arrowUnion           = 14
+	arrowFixedSizeBinary = 15
+	arrowFixedSizeList   = 16
+	arrowMap             = 17
+	arrowDuration        = 18
+	arrowLargeBinary     = 19
+	arrowLargeUtf8       = 20
+	arrowLargeList       = 21
+	arrowRunEndEncoded   = 22
+	arrowBinaryView      = 23
+	arrowUtf8View        = 24
+	arrowListView        = 25
+	arrowLargeListView   = 26
+)
+
+// arrowTypeNames are the names of the Arrow types, used as the type of the columns.
+var arrowTypeNames = map[uint8]string{
+	arrowNull: "null", arrowInt: "int", arrowFloatingPoint: "float", arrowBinary: "binary", arrowUtf8: "string",
+	arrowBool: "bool", arrowDecimal: "float", arrowDate: "date", arrowTime: "time of day", arrowTimestamp: "time",
+	arrowInterval: "interval", arrowList: "list", arrowStruct: "struct", arrowUnion: "union",
+	arrowFixedSizeBinary: "binary", arrowFixedSizeList: "list", arrowMap: "map", arrowDuration: "duration",
+	arrowLargeBinary: "binary", arrowLargeUtf8: "string", arrowLargeList: "list", arrowRunEndEncoded: "run-end encoded",
+	arrowBinaryView: "binary", arrowUtf8View: "string", arrowListView: "list", arrowLargeListView: "list",
+}
+
+// Types of the headers of the Arrow messages (the `MessageHeader` union of Message.fbs).
+const (
+	arrowSchemaMessage          = 1
+	arrowDictionaryBatchMessage = 2
+	arrowRecordBatchMessage     = 3
+)
+
+// fbTable is a table in a [FlatBuffers](https://flatbuffers.dev/) buffer, the encoding of the metadata of the
+// Arrow IPC format. Out-of-range accesses panic, and are reported by ReadArrow as an invalid file.
+type fbTable struct {
+	buf []byte
+	pos int
+}
+
+// fbRoot returns the root table of the buffer.
+func fbRoot(buf []byte) fbTable {
+	return fbTable{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}
+}
+
+// field returns the position of the field with the given id, or 0 if it is not set.
+func (t fbTable) field(id int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if 4+2*id >= int(binary.LittleEndian.Uint16(t.buf[vtable:])) {
+		return 0
+	}
+	if offset := int(binary.LittleEndian.Uint16(t.buf[vtable+4+2*id:])); offset != 0 {
+		return t.pos + offset
+	}
+	return 0
+}
+
+// uint returns the unsigned integer field with the given id and size in bytes, or def if not set.
+func (t fbTable) uint(id, size int, def uint64) uint64 {
+	pos := t.field(id)
+	if pos == 0 {
+		return def
+	}
+	switch size {
+	case 1:
+		return uint64(t.buf[pos])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(t.buf[pos:]))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(t.buf[pos:]))
+	}
+	return binary.LittleEndian.Uint64(t.buf[pos:])
+}
+
+// indirect returns the position referred to by the offset at pos.
+func (t fbTable) indirect(pos int) int {
+	return pos + int(binary.LittleEndian.Uint32(t.buf[pos:]))
+}
+
+// table returns the table field with the given id, and whether it is set.
+func (t fbTable) table(id int) (fbTable, bool) {
+	pos := t.field(id)
+	if pos == 0 {
+		return fbTable{}, false
+	}
+	return fbTable{buf: t.buf, pos: t.indirect(pos)}, true
+}
+
+// string returns the string field with the given id, or "" if it is not set.
+func (t fbTable) string(id int) string {
+	pos := t.field(id)
+	if pos == 0 {
+		return ""
+	}
+	pos = t.indirect(pos)
+	length := int(binary.LittleEndian.Uint32(t.buf[pos:]))
+	return string(t.buf[pos+4 : pos+4+length])
+}
+
+// vector returns the position of the first element and the length of the vector field with the given id.
+func (t fbTable) vector(id int) (start, length int) {
+	pos := t.field(id)
+	if pos == 0 {
+		return 0, 0
+	}
+	pos = t.indirect(pos)
+	return pos + 4, int(binary.LittleEndian.Uint32(t.buf[pos:]))
+}
+
+// tables returns the elements of the vector of tables with the given id.
+func (t fbTable) tables(id int) []fbTable {
+	start, length := t.vector(id)
+	tables := make([]fbTable, length)
+	for ii := range tables {
+		tables[ii] = fbTable{buf: t.buf, pos: t.indirect(start + 4*ii)}
+	}
+	return tables
+}
+
+// arrowField is a field of an Arrow schema.
+type arrowField struct {
+	name         string
+	typeId       uint8
+	typ          fbTable // Table with the parameters of the type, e.g.: the bit width of integers.
+	children     []*arrowField
+	dictionary   bool // Whether the values are indices, of type indexType, into the dictionary dictionaryId.
+	dictionaryId int64
+	indexType    fbTable
+}
+
+// parseArrowField parses the `Field` table of the schema.
+func parseArrowField(t fbTable) *arrowField {
+	f := &arrowField{name: t.string(0), typeId: uint8(t.uint(2, 1, 0))}
+	f.typ, _ = t.table(3)
+	if dict, found := t.table(4); found {
+		f.dictionary = true
+		f.dictionaryId = int64(dict.uint(0, 8, 0))
+		f.indexType, _ = dict.table(1)
+	}
+	for _, child := range t.tables(5) {
+		f.children = append(f.children, parseArrowField(child))
+	}
+	return f
+}
+
+// typeName returns the name of the type of the field, used as the type of the column.
+func (f *arrowField) typeName() string {
+	if name, found := arrowTypeNames[f.typeId]; found {
+		return name
+	}
+	return fmt.Sprintf("<arrow type %d>", f.typeId)
+}
+
+// arrowBatch reads the arrays of a record batch: they are laid out as the fields of the schema in pre-order,
+// each with one node (length and null count) and its buffers.
+type arrowBatch struct {
+	metadata, body []byte
+	dictionaries   map[int64][]string
+
+	// Position in metadata and length of the vectors of nodes (FieldNode structs) and buffers (Buffer structs).
+	nodes, numNodes, buffers, numBuffers int
+	nextNode, nextBuffer                 int
+
+	variadicCounts []int64 // Number of data buffers of the view types, in order.
+	nextVariadic   int
+}
+
+// newArrowBatch returns the reader of the arrays of the `RecordBatch` table t, whose buffers are in body.
+func newArrowBatch(t fbTable, body []byte, dictionaries map[int64][]string) *arrowBatch {
+	if _, compressed := t.table(3); compressed {
+		panic("compressed record batches are not supported")
+	}
+	b := &arrowBatch{body: body, dictionaries: dictionaries, metadata: t.buf}
+	b.nodes, b.numNodes = t.vector(1)
+	b.buffers, b.numBuffers = t.vector(2)
+	start, length := t.vector(4)
+	for ii := 0; ii < length; ii++ {
+		b.variadicCounts = append(b.variadicCounts, int64(binary.LittleEndian.Uint64(t.buf[start+8*ii:])))
+	}
+	return b
+}
+
+// node returns the length of the next array.
+func (b *arrowBatch) node() int {
+	if b.nextNode >= b.numNodes {
+		panic("missing field nodes in record batch")
+	}
+	length := int(binary.LittleEndian.Uint64(b.metadata[b.nodes+16*b.nextNode:]))
+	b.nextNode++
+	return length
+}
+
+// buffer returns the contents of the next buffer.
+func (b *arrowBatch) buffer() []byte {
+	if b.nextBuffer >= b.numBuffers {
+		panic("missing buffers in record batch")
+	}
+	pos := b.buffers + 16*b.nextBuffer
+	offset := binary.LittleEndian.Uint64(b.metadata[pos:])
+	length := binary.LittleEndian.Uint64(b.metadata[pos+8:])
+	b.nextBuffer++
+	return b.body[offset : offset+length]
+}
+
+// skip skips the arrays of the field and of its children.
+func (b *arrowBatch) skip(f *arrowField) {
+	b.node()
+	numBuffers := 0
+	switch f.typeId {
+	case arrowNull, arrowRunEndEncoded:
+	case arrowStruct, arrowFixedSizeList:
+		numBuffers = 1
+	case arrowBinary, arrowUtf8, arrowLargeBinary, arrowLargeUtf8, arrowListView, arrowLargeListView:
+		numBuffers = 3
+	case arrowUnion:
+		numBuffers = 1 + int(f.typ.uint(0, 2, 0)) // Type ids, and the offsets for dense unions.
+	case arrowBinaryView, arrowUtf8View:
+		numBuffers = 2
+		if b.nextVariadic < len(b.variadicCounts) {
+			numBuffers += int(b.variadicCounts[b.nextVariadic])
+		}
+		b.nextVariadic++
+	default:
+		numBuffers = 2
+	}
+	for ii := 0; ii < numBuffers; ii++ {
+		b.buffer()
+	}
+	if !f.dictionary {
+		for _, child := range f.children {
+			b.skip(child)
+		}
+	}
+}
+
+// column returns the first (up to) n values of the next array, with the field f, formatted as strings. Null
+// values are returned as "". Values of types not supported (e.g.: lists) are formatted as "<type>".
+func (b *arrowBatch) column(f *arrowField, n int) []string {
+	typeId := f.typeId
+	if f.dictionary {
+		typeId = arrowInt // The values are the indices into the dictionary.
+	}
+	switch typeId {
+	case arrowNull:
+		return make([]string, min(b.node(), n))
+	case arrowInt, arrowFloatingPoint, arrowBool, arrowDecimal, arrowDate, arrowTime, arrowTimestamp, arrowDuration,
+		arrowFixedSizeBinary, arrowBinary, arrowUtf8, arrowLargeBinary, arrowLargeUtf8:
+	default:
+		savedNode := b.nextNode
+		b.skip(f)
+		length := int(binary.LittleEndian.Uint64(b.metadata[b.nodes+16*savedNode:]))
+		values := make([]string, min(length, n))
+		for ii := range values {
+			values[ii] = "<" + f.typeName() + ">"
+		}
+		return values
+	}
+
+	length := min(b.node(), n)
+	validity := b.buffer()
+	data := b.buffer()
+	var offsets []byte
+	if typeId == arrowBinary || typeId == arrowUtf8 || typeId == arrowLargeBinary || typeId == arrowLargeUtf8 {
+		offsets, data = data, b.buffer()
+	}
+	values := make([]string, length)
+	for ii := range values {
+		if len(validity) > 0 && validity[ii/8]&(1<<(ii%8)) == 0 {
+			continue // Null.
+		}
+		switch typeId {
+		case arrowInt:
+			if !f.dictionary {
+				values[ii] = arrowInteger(data, ii, f.typ)
+			} else {
+				values[ii] = arrowInteger(data, ii, f.indexType)
+				index, _ := strconv.Atoi(values[ii])
+				if dict := b.dictionaries[f.dictionaryId]; index >= 0 && index < len(dict) {
+					values[ii] = dict[index]
+				}
+			}
+		case arrowFloatingPoint:
+			values[ii] = arrowFloat(data, ii, int(f.typ.uint(0, 2, 0)))
+		case arrowBool:
+			values[ii] = strconv.FormatBool(data[ii/8]&(1<<(ii%8)) != 0)
+		case arrowDecimal:
+			values[ii] = arrowDecimalValue(data, ii, f.typ)
+		case arrowDate:
+			if f.typ.uint(0, 2, 1) == 0 { // Days.
+				days := int64(int32(binary.LittleEndian.Uint32(data[4*ii:])))
+				values[ii] = time.Unix(days*24*60*60, 0).UTC().Format(time.DateOnly)
+			} else {
+				ms := int64(binary.LittleEndian.Uint64(data[8*ii:]))
+				values[ii] = time.UnixMilli(ms).UTC().Format(time.DateOnly)
+			}
+		case arrowTime:
+			var value int64
+			if f.typ.uint(1, 4, 32) == 32 {
+				value = int64(int32(binary.LittleEndian.Uint32(data[4*ii:])))
+			} else {
+				value = int64(binary.LittleEndian.Uint64(data[8*ii:]))
+			}
+			ofDay := time.Unix(0, 0).UTC().Add(arrowTimeUnit(value, f.typ.uint(0, 2, 1)))
+			values[ii] = ofDay.Format("15:04:05.999999999")
+		case arrowTimestamp:
+			value := int64(binary.LittleEndian.Uint64(data[8*ii:]))
+			t := time.Unix(0, 0).UTC().Add(arrowTimeUnit(value, f.typ.uint(0, 2, 0)))
+			if location, err := time.LoadLocation(f.typ.string(1)); err == nil && f.typ.string(1) != "" {
+				t = t.In(location)
+			}
+			values[ii] = t.Format(time.RFC3339Nano)
+		case arrowDuration:
+			value := int64(binary.LittleEndian.Uint64(data[8*ii:]))
+			values[ii] = arrowTimeUnit(value, f.typ.uint(0, 2, 1)).String()
+		case arrowFixedSizeBinary:
+			width := int(f.typ.uint(0, 4, 0))
+			values[ii] = arrowBytes(data[width*ii : width*(ii+1)])
+		default:
+			var start, end int
+			if typeId == arrowLargeBinary || typeId == arrowLargeUtf8 {
+				start, end = int(binary.LittleEndian.Uint64(offsets[8*ii:])), int(binary.LittleEndian.Uint64(offsets[8*ii+8:]))
+			} else {
+				start, end = int(binary.LittleEndian.Uint32(offsets[4*ii:])), int(binary.LittleEndian.Uint32(offsets[4*ii+4:]))
+			}
+			if typeId == arrowUtf8 || typeId == arrowLargeUtf8 {
+				values[ii] = string(data[start:end])
+			} else {
+				values[ii] = arrowBytes(data[start:end])
+			}
+		}
+	}
+	return values
+}
+
+// arrowInteger formats the integer at index ii of data, with the bit width and signedness of the `Int` table
+// (32 bits signed if not set, the default for dictionary indices).
+func arrowInteger(data []byte, ii int, intType fbTable) string {
+	signed, bitWidth := true, 32
+	if intType.buf != nil {
+		signed, bitWidth = intType.uint(1, 1, 0) != 0, int(intType.uint(0, 4, 0))
+	}
+	var value uint64
+	switch bitWidth {
+	case 8:
+		value = uint64(data[ii])
+		if signed {
+			return strconv.FormatInt(int64(int8(value)), 10)
+		}
+	case 16:
+		value = uint64(binary.LittleEndian.Uint16(data[2*ii:]))
+		if signed {
+			return strconv.FormatInt(int64(int16(value)), 10)
+		}
+	case 32:
+		value = uint64(binary.LittleEndian.Uint32(data[4*ii:]))
+		if signed {
+			return strconv.FormatInt(int64(int32(value)), 10)
+		}
+	default:
+		value = binary.LittleEndian.Uint64(data[8*ii:])
+		if signed {
+			return strconv.FormatInt(int64(value), 10)
+		}
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+// arrowFloat formats the floating point number at index ii of data, with the given precision: 0 for half,
+// 1 for single and 2 for double precision.
+func arrowFloat(data []byte, ii, precision int) string {
+	switch precision {
+	case 0:
+		h := binary.LittleEndian.Uint16(data[2*ii:])
+		exponent, fraction := int(h>>10)&0x1f, float64(h&0x3ff)
+		var value float64
+		switch exponent {
+		case 0:
+			value = math.Ldexp(fraction, -24) // Subnormal.
+		case 0x1f:
+			value = math.Inf(1)
+			if fraction != 0 {
+				value = math.NaN()
+			}
+		default:
+			value = math.Ldexp(1+fraction/1024, exponent-15)
+		}
+		if h&0x8000 != 0 {
+			value = -value
+		}
+		return strconv.FormatFloat(value, 'g', -1, 32)
+	case 1:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*ii:]))), 'g', -1, 32)
+	}
+	return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data[8*ii:])), 'g', -1, 64)
+}
+
+// arrowDecimalValue formats the decimal at index ii of data, with the scale and bit width of the `Decimal` table.
+func arrowDecimalValue(data []byte, ii int, decimalType fbTable) string {
+	scale := int(int32(decimalType.uint(1, 4, 0)))
+	width := int(decimalType.uint(2, 4, 128)) / 8
+	littleEndian := data[width*ii : width*(ii+1)]
+	bigEndian := make([]byte, width)
+	for jj, b := range littleEndian {
+		bigEndian[width-1-jj] = b
+	}
+	value := new(big.Int).SetBytes(bigEndian)
+	if bigEndian[0]&0x80 != 0 { // Two's complement.
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), uint(8*width)))
+	}
+	digits := value.String()
+	if scale <= 0 {
+		return digits + strings.Repeat("0", -scale)
+	}
+	sign := ""
+	if digits[0] == '-' {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// arrowTimeUnit converts the value in the Arrow time unit (0 for seconds, 1 for milli-, 2 for micro- and 3 for
+// nanoseconds) to a time.Duration.
+func arrowTimeUnit(value int64, unit uint64) time.Duration {
+	switch unit {
+	case 0:
+		return time.Duration(value) * time.Second
+	case 1:
+		return time.Duration(value) * time.Millisecond
+	case 2:
+		return time.Duration(value) * time.Microsecond
+	}
+	return time.Duration(value)
+}
+
+// arrowBytes formats binary values in hexadecimal, truncated to 32 bytes.
+func arrowBytes(value []byte) string {
+	if len(value) > 32 {
+		return "0x" + hex.EncodeToString(value[:32]) + "…"
+	}
+	return "0x" + hex.EncodeToString(value)
+}
+
+// readArrowMessage reads the next encapsulated message of the Arrow IPC stream: its metadata (a `Message`
+// table) and its body. It returns nil metadata at the end of the stream.
+func readArrowMessage(r io.Reader) (metadata fbTable, body []byte, err error) {
+	var length uint32
+	if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return
+	}
+	if length == 0xFFFFFFFF { // Continuation marker, followed by the length.
+		if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return
+		}
+	}
+	if length == 0 {
+		return fbTable{}, nil, nil // End of stream.
+	}
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return
+	}
+	metadata = fbRoot(buf)
+	body = make([]byte, metadata.uint(3, 8, 0))
+	_, err = io.ReadFull(r, body)
+	return
+}
+
+// ReadArrow reads the first n rows of an Arrow IPC file (the file format, usually with the extension `.arrow`
+// or `.feather`, or the stream format, `.arrows`), and returns its columns, with their types, and the values
+// formatted as strings (null values are empty). Values of nested types (lists, structs, etc.) are not read,
+// and compressed files are not supported.
+func ReadArrow(filePath string, n int) (columns []Column, rows [][]string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to open %q", filePath)
+	}
+	defer func() { _ = f.Close() }()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			columns, rows = nil, nil
+			err = errors.Errorf("failed to read Arrow file %q: %v", filePath, recovered)
+		}
+	}()
+
+	// In the file format, the stream is between the magic (padded to 8 bytes) and the footer.
+	var r io.Reader
+	magic := make([]byte, 8)
+	if _, err = io.ReadFull(f, magic); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read %q", filePath)
+	}
+	if string(magic[:len(arrowMagic)]) == arrowMagic {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read %q", filePath)
+		}
+		trailer := make([]byte, 4+len(arrowMagic))
+		if _, err = f.ReadAt(trailer, info.Size()-int64(len(trailer))); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read the footer of %q", filePath)
+		}
+		footerStart := info.Size() - int64(len(trailer)) - int64(binary.LittleEndian.Uint32(trailer))
+		r = bufio.NewReader(io.NewSectionReader(f, 8, footerStart-8))
+	} else {
+		r = bufio.NewReader(io.MultiReader(strings.NewReader(string(magic)), f))
+	}
+
+	var fields []*arrowField
+	dictionaries := make(map[int64][]string)
+	dictionaryFields := make(map[int64]*arrowField)
+	for len(rows) < n || fields == nil {
+		metadata, body, err := readArrowMessage(r)
+		if err == io.EOF || (err == nil && metadata.buf == nil) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read %q", filePath)
+		}
+		header, found := metadata.table(2)
+		if !found {
+			continue
+		}
+		switch metadata.uint(1, 1, 0) {
+		case arrowSchemaMessage:
+			var addDictionaries func(f *arrowField)
+			addDictionaries = func(f *arrowField) {
+				if f.dictionary {
+					dictionaryFields[f.dictionaryId] = f
+				}
+				for _, child := range f.children {
+					addDictionaries(child)
+				}
+			}
+			for _, table := range header.tables(1) {
+				fields = append(fields, parseArrowField(table))
+				addDictionaries(fields[len(fields)-1])
+			}
+		case arrowDictionaryBatchMessage:
+			id := int64(header.uint(0, 8, 0))
+			field, found := dictionaryFields[id]
+			data, hasData := header.table(1)
+			if !found || !hasData {
+				continue
+			}
+			valueField := *field
+			valueField.dictionary = false
+			batch := newArrowBatch(data, body, dictionaries)
+			values := batch.column(&valueField, int(data.uint(0, 8, 0)))
+			if header.uint(2, 1, 0) != 0 { // Delta.
+				values = append(dictionaries[id], values...)
+			}
+			dictionaries[id] = values
+		case arrowRecordBatchMessage:
+			batch := newArrowBatch(header, body, dictionaries)
+			var columnValues [][]string
+			for _, field := range fields {
+				columnValues = append(columnValues, batch.column(field, n-len(rows)))
+			}
+			numRows := min(int(header.uint(0, 8, 0)), n-len(rows))
+			for ii := 0; ii < numRows; ii++ {
+				row := make([]string, len(fields))
+				for jj, values := range columnValues {
+					if ii < len(values) {
+						row[jj] = values[ii]
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	if fields == nil {
+		return nil, nil, errors.Errorf("failed to read Arrow file %q: no schema found", filePath)
+	}
+	for _, field := range fields {
+		columns = append(columns, Column{Name: field.name, Type: field.typeName()})
+	}
+	return columns, rows, nil
+}
+
+// PreviewArrow displays the first n rows of an Arrow IPC file (see ReadArrow for the formats accepted) as a
+// table, with the type of each column given by the schema.
+func PreviewArrow(filePath string, n int) error {
+	columns, rows, err := ReadArrow(filePath, n)
+	if err != nil {
+		return err
+	}
+	displayTable(filePath, columns, rows)
+	return nil
+}
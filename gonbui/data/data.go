@@ -0,0 +1,225 @@
+// Package data provides helpers to fetch and inspect the datasets used by a notebook.
+//
+// Download fetches a file once, into a cache directory per notebook, verifying its checksum, and shows a
+// progress bar in the notebook while downloading. Example:
+//
+//	%%
+//	path, err := data.Download("https://example.com/iris.csv", &data.Options{
+//		SHA256: "6f608b71a7317216319b4d27b4d9bc84e6abd734eda7872b71a458569e2656c0"})
+//	check.NoError(err)
+//	check.NoError(data.PreviewCSV(path, 10))
+//
+// PreviewCSV, PreviewParquet and PreviewArrow display the first rows of a dataset as a table, with the
+// inferred type of each column (or the type in the schema, for Arrow files).
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/pkg/errors"
+)
+
+// CacheDirEnv is the environment variable that, if set, overrides the default cache directory, see CacheDir.
+const CacheDirEnv = "GONB_DATA_CACHE_DIR"
+
+// ProgressInterval is the minimum interval between updates of the progress bar.
+var ProgressInterval = 250 * time.Millisecond
+
+// Options of Download. The zero value (or nil) uses the defaults.
+type Options struct {
+	// SHA256 is the expected hex-encoded SHA-256 checksum of the file. If set, the download fails if it
+	// doesn't match, and a cached file that doesn't match is downloaded again.
+	SHA256 string
+
+	// FileName of the downloaded file. Defaults to the last element of the URL path.
+	FileName string
+
+	// CacheDir where to store the file, see CacheDir for the default.
+	CacheDir string
+
+	// Force the download, even if the file is already cached.
+	Force bool
+
+	// Client used to download. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Quiet disables the progress bar.
+	Quiet bool
+}
+
+// CacheDir returns the default cache directory of the notebook: `$GONB_DATA_CACHE_DIR` if set, or
+// `gonb/data/<notebook name>` under the user's cache directory (e.g.: `~/.cache` in Linux).
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the user cache directory, set %s instead", CacheDirEnv)
+	}
+	notebook := "default"
+	if session := os.Getenv("JPY_SESSION_NAME"); session != "" {
+		notebook = strings.TrimSuffix(filepath.Base(session), filepath.Ext(session))
+	}
+	return path.Join(userCacheDir, "gonb", "data", notebook), nil
+}
+
+// Download fetches the file in the URL into the cache directory (see Options.CacheDir), if not yet cached,
+// and returns its local path. While downloading, a progress bar is displayed in the notebook.
+//
+// Files are stored in a subdirectory per URL, so different URLs with the same file name don't collide.
+func Download(fileURL string, opts *Options) (string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	parsedURL, err := url.Parse(fileURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid URL %q", fileURL)
+	}
+	fileName := opts.FileName
+	if fileName == "" {
+		fileName = path.Base(parsedURL.Path)
+		if fileName == "." || fileName == "/" {
+			fileName = "download"
+		}
+	}
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		if cacheDir, err = CacheDir(); err != nil {
+			return "", err
+		}
+	}
+	urlHash := sha256.Sum256([]byte(fileURL))
+	dir := path.Join(cacheDir, hex.EncodeToString(urlHash[:6]))
+	filePath := path.Join(dir, fileName)
+
+	if !opts.Force {
+		if _, err := os.Stat(filePath); err == nil {
+			if opts.SHA256 == "" {
+				return filePath, nil
+			}
+			if checksum, err := fileChecksum(filePath); err == nil && strings.EqualFold(checksum, opts.SHA256) {
+				return filePath, nil
+			}
+		}
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "failed to create cache directory %q", dir)
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %q", fileURL)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		return "", errors.Errorf("failed to download %q: %s", fileURL, resp.Status)
+	}
+
+	// Download to a temporary file, renamed only after the checksum is verified.
+	tmpFile, err := os.CreateTemp(dir, fileName+".*.partial")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create file in %q", dir)
+	}
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+	hasher := sha256.New()
+	var reader io.Reader = resp.Body
+	if !opts.Quiet && gonbui.IsNotebook {
+		progress := newProgressReader(resp.Body, fileName, resp.ContentLength)
+		defer progress.done()
+		reader = progress
+	}
+	_, err = io.Copy(io.MultiWriter(tmpFile, hasher), reader)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %q to %q", fileURL, tmpFile.Name())
+	}
+	if opts.SHA256 != "" {
+		if checksum := hex.EncodeToString(hasher.Sum(nil)); !strings.EqualFold(checksum, opts.SHA256) {
+			return "", errors.Errorf("checksum of %q doesn't match: got SHA-256 %s, wanted %s", fileURL, checksum,
+				opts.SHA256)
+		}
+	}
+	if err = os.Rename(tmpFile.Name(), filePath); err != nil {
+		return "", errors.Wrapf(err, "failed to move downloaded file to %q", filePath)
+	}
+	return filePath, nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 checksum of the file.
+func fileChecksum(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open %q", filePath)
+	}
+	defer func() { _ = f.Close() }()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return "", errors.Wrapf(err, "failed to read %q", filePath)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// progressReader displays the progress of the download in the notebook, as it is read.
+type progressReader struct {
+	reader      io.Reader
+	name        string
+	displayId   string
+	total, read int64
+	start, last time.Time
+}
+
+func newProgressReader(reader io.Reader, name string, total int64) *progressReader {
+	p := &progressReader{reader: reader, name: html.EscapeString(name), displayId: gonbui.UniqueId(),
+		total: total, start: time.Now()}
+	p.update()
+	return p
+}
+
+// Read implements io.Reader.
+func (p *progressReader) Read(buf []byte) (n int, err error) {
+	n, err = p.reader.Read(buf)
+	p.read += int64(n)
+	if time.Since(p.last) >= ProgressInterval {
+		p.update()
+	}
+	return
+}
+
+// update the progress bar.
+func (p *progressReader) update() {
+	p.last = time.Now()
+	rate := float64(p.read) / max(time.Since(p.start).Seconds(), 0.001) / (1 << 20)
+	if p.total > 0 {
+		gonbui.UpdateHtml(p.displayId, fmt.Sprintf(
+			`<div>Downloading <b>%s</b>: <progress max="%d" value="%d"></progress> %.1f / %.1f MiB (%.1f MiB/s)</div>`,
+			p.name, p.total, p.read, float64(p.read)/(1<<20), float64(p.total)/(1<<20), rate))
+	} else {
+		gonbui.UpdateHtml(p.displayId, fmt.Sprintf(
+			`<div>Downloading <b>%s</b>: <progress></progress> %.1f MiB (%.1f MiB/s)</div>`,
+			p.name, float64(p.read)/(1<<20), rate))
+	}
+}
+
+// done erases the progress bar.
+func (p *progressReader) done() {
+	gonbui.UpdateHtml(p.displayId, "")
+}
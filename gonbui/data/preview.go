@@ -0,0 +1,200 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/pkg/errors"
+)
+
+// Column of a previewed table, with its inferred type: "bool", "int", "float", "date", "time" or "string".
+// The columns read by ReadArrow can also have the names of other Arrow types, e.g. "binary" or "list".
+type Column struct {
+	Name, Type string
+}
+
+// ReadCSV reads the header and the first n rows of a CSV file (TSV if the file name ends in ".tsv",
+// and optionally compressed with gzip if it ends in ".gz"), and infers the type of the columns from
+// the values read.
+func ReadCSV(filePath string, n int) (columns []Column, rows [][]string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to open %q", filePath)
+	}
+	defer func() { _ = f.Close() }()
+	var reader io.Reader = f
+	name := filePath
+	if strings.HasSuffix(name, ".gz") {
+		gzReader, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to decompress %q", filePath)
+		}
+		reader = gzReader
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	csvReader := csv.NewReader(reader)
+	if strings.HasSuffix(name, ".tsv") {
+		csvReader.Comma = '\t'
+	}
+	csvReader.FieldsPerRecord = -1 // Allow ragged rows.
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read the header of %q", filePath)
+	}
+	for len(rows) < n {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read %q", filePath)
+		}
+		rows = append(rows, row)
+	}
+	return InferColumns(header, rows), rows, nil
+}
+
+// InferColumns returns the columns with the given names, and the types inferred from the values in the
+// rows: the most specific type that parses all non-empty values of the column.
+func InferColumns(names []string, rows [][]string) []Column {
+	columns := make([]Column, len(names))
+	for ii, name := range names {
+		types := map[string]bool{"bool": true, "int": true, "float": true, "date": true, "time": true}
+		empty := true
+		for _, row := range rows {
+			if ii >= len(row) || strings.TrimSpace(row[ii]) == "" {
+				continue
+			}
+			empty = false
+			value := strings.TrimSpace(row[ii])
+			if _, err := strconv.ParseBool(value); err != nil {
+				types["bool"] = false
+			}
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				types["int"] = false
+			}
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				types["float"] = false
+			}
+			if _, err := time.Parse(time.DateOnly, value); err != nil {
+				types["date"] = false
+			}
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				types["time"] = false
+			}
+		}
+		columns[ii] = Column{Name: name, Type: "string"}
+		if empty {
+			continue
+		}
+		for _, t := range []string{"int", "float", "bool", "date", "time"} {
+			if types[t] {
+				columns[ii].Type = t
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// TableHtml renders the columns (with their types) and rows as an HTML table. Numeric columns are
+// right-aligned.
+func TableHtml(columns []Column, rows [][]string) string {
+	var buf bytes.Buffer
+	buf.WriteString("<table>\n<thead><tr>")
+	for _, col := range columns {
+		fmt.Fprintf(&buf, "<th>%s<br/><span style=\"font-weight: normal; opacity: 0.6\">%s</span></th>",
+			html.EscapeString(col.Name), col.Type)
+	}
+	buf.WriteString("</tr></thead>\n<tbody>\n")
+	for _, row := range rows {
+		buf.WriteString("<tr>")
+		for ii, col := range columns {
+			value := ""
+			if ii < len(row) {
+				value = row[ii]
+			}
+			if col.Type == "int" || col.Type == "float" {
+				fmt.Fprintf(&buf, "<td style=\"text-align: right\">%s</td>", html.EscapeString(value))
+			} else {
+				fmt.Fprintf(&buf, "<td>%s</td>", html.EscapeString(value))
+			}
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody>\n</table>")
+	return buf.String()
+}
+
+// displayTable displays the table in the notebook, or prints it to the standard output if not running
+// in a notebook.
+func displayTable(title string, columns []Column, rows [][]string) {
+	if gonbui.IsNotebook {
+		gonbui.DisplayHtml(fmt.Sprintf("<div><b>%s</b>: first %d rows, %d columns</div>\n%s",
+			html.EscapeString(title), len(rows), len(columns), TableHtml(columns, rows)))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for ii, col := range columns {
+		if ii > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprintf(w, "%s (%s)", col.Name, col.Type)
+	}
+	fmt.Fprintln(w)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	_ = w.Flush()
+}
+
+// PreviewCSV displays the first n rows of a CSV file (see ReadCSV for the formats accepted) as a table,
+// with the inferred type of each column.
+func PreviewCSV(filePath string, n int) error {
+	columns, rows, err := ReadCSV(filePath, n)
+	if err != nil {
+		return err
+	}
+	displayTable(filePath, columns, rows)
+	return nil
+}
+
+// PreviewParquet displays the first n rows of a Parquet file as a table, with the inferred type of each
+// column.
+//
+// It uses the [DuckDB](https://duckdb.org/) command line to read the Parquet file, so it requires `duckdb`
+// to be installed: if it is not found in the PATH, it returns an error saying so, without running anything.
+// Alternatively, Arrow files (e.g. converted from Parquet) can be previewed without external tools with
+// PreviewArrow.
+func PreviewParquet(filePath string, n int) error {
+	duckDB, err := exec.LookPath("duckdb")
+	if err != nil {
+		return errors.Errorf("PreviewParquet(%q) requires the DuckDB command line (`duckdb`), which was not found "+
+			"in the PATH: see https://duckdb.org/docs/installation/ to install it", filePath)
+	}
+	query := fmt.Sprintf("SELECT * FROM read_parquet('%s') LIMIT %d", strings.ReplaceAll(filePath, "'", "''"), n)
+	output, err := exec.Command(duckDB, "-csv", "-c", query).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return errors.Errorf("failed to read Parquet file %q: %s", filePath, exitErr.Stderr)
+		}
+		return errors.Wrapf(err, "failed to run %q", duckDB)
+	}
+	records, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+	if err != nil || len(records) == 0 {
+		return errors.Errorf("failed to parse the rows of Parquet file %q read by duckdb", filePath)
+	}
+	displayTable(filePath, InferColumns(records[0], records[1:]), records[1:])
+	return nil
+}
@@ -0,0 +1,131 @@
+package gonbui
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+var (
+	// VizJsSrc is the source from where to download viz.js, used by DisplayDOT to render the graphs in the
+	// browser when Graphviz is not installed.
+	VizJsSrc = "https://cdn.jsdelivr.net/npm/@viz-js/viz@3.7.0/lib/viz-standalone.js"
+
+	// MermaidSrc is the source from where to download Mermaid, used by DisplayMermaid.
+	MermaidSrc = "https://cdn.jsdelivr.net/npm/mermaid@10.9.1/dist/mermaid.min.js"
+)
+
+// loadScriptJS defines the Javascript function `loadScript(src, moduleName, globalName)`, that returns a promise
+// of the library: the module loaded with RequireJS if available (e.g.: in notebooks exported to HTML), or the
+// global variable set by the script otherwise. The script is only loaded once.
+const loadScriptJS = `
+	const loadScript = (src, moduleName, globalName) => new Promise((resolve, reject) => {
+		if (typeof requirejs === "function") {
+			requirejs.config({paths: {[moduleName]: src.replace(/\.js$/, "")}});
+			require([moduleName], resolve, reject);
+			return;
+		}
+		if (globalThis[globalName]) {
+			resolve(globalThis[globalName]);
+			return;
+		}
+		let script = Array.from(document.head.getElementsByTagName("script")).find((s) => s.src === src);
+		if (!script) {
+			script = document.createElement("script");
+			script.charset = "utf-8";
+			script.src = src;
+			document.head.appendChild(script);
+		}
+		script.addEventListener("load", () => resolve(globalThis[globalName]));
+		script.addEventListener("error", () => reject(new Error("failed to load " + src)));
+	});
+`
+
+// displayRenderedInBrowser displays a `<div>` filled by renderJS -- Javascript with `root` set to the `<div>`,
+// and `library` to the library loaded from src -- and text as the "text/plain" version.
+func displayRenderedInBrowser(src, moduleName, globalName, renderJS, text string) {
+	htmlId := "gonb_diagram_" + UniqueId()
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMETextHTML: fmt.Sprintf(`<div id="%s"></div>
+<script>
+(async () => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+%s
+	try {
+		const library = await loadScript(%s, %s, %s);
+%s
+	} catch (err) {
+		root.textContent = "Failed to render: " + (err?.message ?? err);
+		root.style.color = "#c00";
+	}
+})();
+</script>`, htmlId, jsValue(htmlId), loadScriptJS, jsValue(src), jsValue(moduleName), jsValue(globalName), renderJS),
+			protocol.MIMETextPlain: text,
+		},
+	})
+}
+
+// DisplayDOT displays the graph in the [Graphviz DOT language](https://graphviz.org/doc/info/lang.html), e.g.
+// a dependency graph or a state machine.
+//
+// If Graphviz is installed (the `dot` binary is in the PATH) it's used to render the graph to SVG, otherwise
+// it's rendered in the browser with viz.js (see VizJsSrc). It returns an error only if `dot` fails, e.g.:
+// for syntax errors.
+//
+// Example:
+//
+//	gonbui.DisplayDOT(`digraph { rankdir=LR; idle -> running -> done; running -> idle [label="pause"]; }`)
+func DisplayDOT(dot string) error {
+	if !IsNotebook {
+		return nil
+	}
+	dotPath, err := exec.LookPath("dot")
+	if err != nil {
+		displayRenderedInBrowser(VizJsSrc, "viz", "Viz", fmt.Sprintf(`
+		const viz = await library.instance();
+		root.appendChild(viz.renderSVGElement(%s));`, jsValue(dot)), dot)
+		return nil
+	}
+	cmd := exec.Command(dotPath, "-Tsvg")
+	cmd.Stdin = strings.NewReader(dot)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err = cmd.Run(); err != nil {
+		return errors.Wrapf(err, "failed to render graph with %q: %s", dotPath, strings.TrimSpace(stderr.String()))
+	}
+	svg := stdout.String()
+	// Drop the XML preamble, so the SVG can be inlined in HTML.
+	if idx := strings.Index(svg, "<svg"); idx > 0 {
+		svg = svg[idx:]
+	}
+	DisplaySvg(svg)
+	return nil
+}
+
+// DisplayMermaid displays the [Mermaid](https://mermaid.js.org/) diagram (flowcharts, sequence diagrams, state
+// diagrams, etc.), rendered in the browser with Mermaid (see MermaidSrc). Syntax errors are displayed in place
+// of the diagram.
+//
+// Example:
+//
+//	gonbui.DisplayMermaid(`
+//	flowchart LR
+//		Client --> API --> Database
+//	`)
+func DisplayMermaid(src string) {
+	if !IsNotebook {
+		return
+	}
+	displayRenderedInBrowser(MermaidSrc, "mermaid", "mermaid", fmt.Sprintf(`
+		library.initialize({startOnLoad: false});
+		const {svg} = await library.render(root.id + "_svg", %s);
+		root.innerHTML = svg;`, jsValue(src)), src)
+}
@@ -0,0 +1,367 @@
+package gonbui
+
+import (
+	"fmt"
+	"html"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// MaxDiffLines is the maximum number of lines of the values compared by DiffLines: larger values are not
+// diffed, all their lines are shown instead.
+const MaxDiffLines = 2000
+
+// FormatValue returns a multi-line representation of the value, with one field, element or map entry per
+// line, in Go syntax, so the differences between values can be diffed line by line.
+func FormatValue(value any) string {
+	var sb strings.Builder
+	formatValue(&sb, reflect.ValueOf(value), "", make(map[uintptr]bool))
+	return sb.String()
+}
+
+// formatValue writes the value, indenting any extra lines it uses. Visited pointers are tracked, to
+// handle cyclic structures.
+func formatValue(sb *strings.Builder, v reflect.Value, indent string, visited map[uintptr]bool) {
+	if !v.IsValid() {
+		sb.WriteString("nil")
+		return
+	}
+	if v.CanInterface() {
+		if _, isError := v.Interface().(error); isError && v.Kind() == reflect.Pointer && !v.IsNil() {
+			fmt.Fprintf(sb, "%s(%q)", v.Type(), v.Interface())
+			return
+		}
+	}
+	inner := indent + "\t"
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			fmt.Fprintf(sb, "(%s)(nil)", v.Type())
+			return
+		}
+		if visited[v.Pointer()] {
+			fmt.Fprintf(sb, "<cycle to %s>", v.Type())
+			return
+		}
+		visited[v.Pointer()] = true
+		defer delete(visited, v.Pointer())
+		sb.WriteString("&")
+		formatValue(sb, v.Elem(), indent, visited)
+	case reflect.Interface:
+		formatValue(sb, v.Elem(), indent, visited)
+	case reflect.Struct:
+		fmt.Fprintf(sb, "%s{\n", v.Type())
+		for ii := 0; ii < v.NumField(); ii++ {
+			fmt.Fprintf(sb, "%s%s: ", inner, v.Type().Field(ii).Name)
+			formatValue(sb, v.Field(ii), inner, visited)
+			sb.WriteString(",\n")
+		}
+		sb.WriteString(indent + "}")
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			fmt.Fprintf(sb, "%s(nil)", v.Type())
+			return
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() <= 64 {
+			// Short byte slices in one line.
+			fmt.Fprintf(sb, "%s%#v", v.Type(), v.Bytes())
+			return
+		}
+		fmt.Fprintf(sb, "%s{\n", v.Type())
+		for ii := 0; ii < v.Len(); ii++ {
+			sb.WriteString(inner)
+			formatValue(sb, v.Index(ii), inner, visited)
+			sb.WriteString(",\n")
+		}
+		sb.WriteString(indent + "}")
+	case reflect.Map:
+		if v.IsNil() {
+			fmt.Fprintf(sb, "%s(nil)", v.Type())
+			return
+		}
+		// Sort entries by their formatted keys, for a stable output.
+		type entry struct {
+			key   string
+			value reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			var keySb strings.Builder
+			formatValue(&keySb, iter.Key(), inner, visited)
+			entries = append(entries, entry{keySb.String(), iter.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		fmt.Fprintf(sb, "%s{\n", v.Type())
+		for _, e := range entries {
+			fmt.Fprintf(sb, "%s%s: ", inner, e.key)
+			formatValue(sb, e.value, inner, visited)
+			sb.WriteString(",\n")
+		}
+		sb.WriteString(indent + "}")
+	case reflect.String:
+		fmt.Fprintf(sb, "%q", v.String())
+	default:
+		if v.CanInterface() {
+			fmt.Fprintf(sb, "%#v", v.Interface())
+		} else {
+			// Unexported fields.
+			fmt.Fprintf(sb, "%v", v)
+		}
+	}
+}
+
+// DiffLine is a line of the output of DiffLines.
+type DiffLine struct {
+	// Op is '-' for lines only in the first sequence, '+' for lines only in the second, and ' ' for
+	// lines in both.
+	Op   byte
+	Text string
+}
+
+// DiffLines returns the line diff from a to b, using their longest common subsequence. The common prefix and
+// suffix are matched first, and if what remains of a or b is larger than MaxDiffLines, it is reported as
+// entirely removed and added.
+func DiffLines(a, b []string) []DiffLine {
+	var prefix, suffix []DiffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, DiffLine{Op: ' ', Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append(suffix, DiffLine{Op: ' ', Text: a[len(a)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	slices.Reverse(suffix)
+	lines := prefix
+	if len(a) > MaxDiffLines || len(b) > MaxDiffLines {
+		for _, line := range a {
+			lines = append(lines, DiffLine{Op: '-', Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, DiffLine{Op: '+', Text: line})
+		}
+		return append(lines, suffix...)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: ' ', Text: a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, DiffLine{Op: '-', Text: a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: '+', Text: b[j]})
+			j++
+		}
+	}
+	return append(lines, suffix...)
+}
+
+// DiffContextLines is the number of unchanged lines displayed around the differences by DisplayDiff and
+// DisplayDiffSideBySide: longer runs of unchanged lines are collapsed.
+var DiffContextLines = 3
+
+// diffSkipOp is the DiffLine.Op of the collapsed runs of unchanged lines, see collapseDiff.
+const diffSkipOp = '@'
+
+// diffValueLines returns the lines of the value to diff: strings are diffed as text, and other values by their
+// structure, see FormatValue.
+func diffValueLines(v any) []string {
+	if text, ok := v.(string); ok {
+		return strings.Split(text, "\n")
+	}
+	return strings.Split(FormatValue(v), "\n")
+}
+
+// collapseDiff replaces the runs of unchanged lines farther than DiffContextLines from a difference by a
+// line with Op diffSkipOp, with the number of lines collapsed in its Text.
+func collapseDiff(lines []DiffLine) []DiffLine {
+	var collapsed []DiffLine
+	for start := 0; start < len(lines); {
+		if lines[start].Op != ' ' {
+			collapsed = append(collapsed, lines[start])
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && lines[end].Op == ' ' {
+			end++
+		}
+		keepBefore, keepAfter := DiffContextLines, DiffContextLines
+		if start == 0 {
+			keepBefore = 0
+		}
+		if end == len(lines) {
+			keepAfter = 0
+		}
+		if end-start <= keepBefore+keepAfter+1 { // Collapsing a single line doesn't save space.
+			collapsed = append(collapsed, lines[start:end]...)
+		} else {
+			collapsed = append(collapsed, lines[start:start+keepBefore]...)
+			collapsed = append(collapsed, DiffLine{Op: diffSkipOp, Text: strconv.Itoa(end - start - keepBefore - keepAfter)})
+			collapsed = append(collapsed, lines[end-keepAfter:end]...)
+		}
+		start = end
+	}
+	return collapsed
+}
+
+// diffText returns the unified diff as text, for front-ends that don't display HTML.
+func diffText(lines []DiffLine) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		if line.Op == diffSkipOp {
+			fmt.Fprintf(&sb, "  ... %s unchanged lines\n", line.Text)
+		} else {
+			fmt.Fprintf(&sb, "%c %s\n", line.Op, line.Text)
+		}
+	}
+	return sb.String()
+}
+
+const (
+	diffRemovedStyle = "color: #b31d28; background-color: #ffeef0"
+	diffAddedStyle   = "color: #22863a; background-color: #f0fff4"
+	diffSkipStyle    = "opacity: 0.6"
+)
+
+// diffLineHtml returns the HTML of the text of a line of the diff, with the style of its operation.
+func diffLineHtml(line DiffLine) string {
+	switch line.Op {
+	case '-':
+		return fmt.Sprintf(`<span style="%s">%s</span>`, diffRemovedStyle, html.EscapeString(line.Text))
+	case '+':
+		return fmt.Sprintf(`<span style="%s">%s</span>`, diffAddedStyle, html.EscapeString(line.Text))
+	case diffSkipOp:
+		return fmt.Sprintf(`<span style="%s">... %s unchanged lines</span>`, diffSkipStyle, line.Text)
+	}
+	return html.EscapeString(line.Text)
+}
+
+// displayDiff displays the diff of a and b, rendered by toHtml.
+func displayDiff(a, b any, toHtml func(lines []DiffLine) string) {
+	if !IsNotebook {
+		return
+	}
+	lines := DiffLines(diffValueLines(a), diffValueLines(b))
+	changed := false
+	for _, line := range lines {
+		changed = changed || line.Op != ' '
+	}
+	if !changed {
+		SendData(&protocol.DisplayData{
+			Data: map[protocol.MIMEType]any{
+				protocol.MIMETextHTML:  `<span style="opacity: 0.6">(no differences)</span>`,
+				protocol.MIMETextPlain: "(no differences)",
+			},
+		})
+		return
+	}
+	lines = collapseDiff(lines)
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMETextHTML:  toHtml(lines),
+			protocol.MIMETextPlain: diffText(lines),
+		},
+	})
+}
+
+// DisplayDiff displays a unified diff from a to b, with the removed lines (only in a) in red, and the added
+// lines (only in b) in green. Strings are compared as text, line by line, and other values by their structure
+// (see FormatValue), so changes in nested fields are easy to spot.
+//
+// Long runs of unchanged lines are collapsed, see DiffContextLines. See also DisplayDiffSideBySide.
+//
+// Example:
+//
+//	before := Normalize(records)
+//	Transform(records)
+//	gonbui.DisplayDiff(before, Normalize(records))
+func DisplayDiff(a, b any) {
+	displayDiff(a, b, func(lines []DiffLine) string {
+		var sb strings.Builder
+		sb.WriteString(`<pre style="padding: 0.5em;">`)
+		for _, line := range lines {
+			if line.Op == diffSkipOp {
+				sb.WriteString("  " + diffLineHtml(line) + "\n")
+				continue
+			}
+			sb.WriteString(diffLineHtml(DiffLine{Op: line.Op, Text: string(line.Op) + " " + line.Text}) + "\n")
+		}
+		sb.WriteString(`<span style="opacity: 0.6">(- a, + b)</span></pre>`)
+		return sb.String()
+	})
+}
+
+// DisplayDiffSideBySide is like DisplayDiff, but displays a on the left and b on the right, with the changed
+// lines aligned.
+func DisplayDiffSideBySide(a, b any) {
+	displayDiff(a, b, func(lines []DiffLine) string {
+		var sb strings.Builder
+		sb.WriteString(`<table style="font-family: monospace; border-collapse: collapse;">` +
+			`<tr><th style="text-align: left;">a</th><th style="text-align: left;">b</th></tr>` + "\n")
+		cell := func(line *DiffLine) string {
+			if line == nil {
+				return `<td></td>`
+			}
+			return fmt.Sprintf(`<td style="white-space: pre; text-align: left; vertical-align: top;">%s</td>`,
+				diffLineHtml(*line))
+		}
+		for ii := 0; ii < len(lines); {
+			switch lines[ii].Op {
+			case ' ':
+				sb.WriteString("<tr>" + cell(&lines[ii]) + cell(&lines[ii]) + "</tr>\n")
+				ii++
+			case diffSkipOp:
+				sb.WriteString(`<tr><td colspan="2" style="text-align: left;">` + diffLineHtml(lines[ii]) + "</td></tr>\n")
+				ii++
+			default:
+				// Align the removed and added lines of a block of changes.
+				var removed, added []*DiffLine
+				for ; ii < len(lines) && (lines[ii].Op == '-' || lines[ii].Op == '+'); ii++ {
+					if lines[ii].Op == '-' {
+						removed = append(removed, &lines[ii])
+					} else {
+						added = append(added, &lines[ii])
+					}
+				}
+				for row := 0; row < max(len(removed), len(added)); row++ {
+					var left, right *DiffLine
+					if row < len(removed) {
+						left = removed[row]
+					}
+					if row < len(added) {
+						right = added[row]
+					}
+					sb.WriteString("<tr>" + cell(left) + cell(right) + "</tr>\n")
+				}
+			}
+		}
+		sb.WriteString("</table>")
+		return sb.String()
+	})
+}
@@ -2,8 +2,10 @@ package dom
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/janpfeifer/gonb/gonbui"
 	"github.com/pkg/errors"
+	"strings"
 	"text/template"
 )
 
@@ -100,7 +102,7 @@ var loadOrRequireAndRunTmpl = template.Must(template.New("load_or_required_and_r
         // Use RequireJS to load module.
         requirejs.config({
             paths: {
-                '{{.ModuleName}}': 'https://cdn.plot.ly/plotly-2.29.1.min'
+                '{{.ModuleName}}': '{{.RequireJSPath}}'
             }
         });
         require(['{{.ModuleName}}'], function({{.ModuleName}}) {
@@ -156,22 +158,21 @@ func LoadScriptOrRequireJSModuleAndRunTransient(moduleName, src string, attribut
 	return loadScriptOrRequireJSModuleAndRunImpl(moduleName, src, attributes, runJS, true)
 }
 
-func loadScriptOrRequireJSModuleAndRunImpl(moduleName, src string, attributes map[string]string, runJS string, transient bool) error {
-	var buf bytes.Buffer
-	data := struct {
-		ModuleName, Src, RunJS string
-		Attributes             map[string]string
-	}{
-		ModuleName: moduleName,
-		Src:        src,
-		RunJS:      runJS,
-		Attributes: attributes,
+// LoadScriptOrRequireJSModuleAndRunHtml returns the `<script>` element displayed by
+// [LoadScriptOrRequireJSModuleAndRun], to be included in other HTML content.
+func LoadScriptOrRequireJSModuleAndRunHtml(moduleName, src string, attributes map[string]string, runJS string) (string, error) {
+	js, err := loadScriptOrRequireJSModuleAndRunJS(moduleName, src, attributes, runJS)
+	if err != nil {
+		return "", err
 	}
-	err := loadOrRequireAndRunTmpl.Execute(&buf, data)
+	return fmt.Sprintf("<script charset=%q>%s</script>", "UTF-8", js), nil
+}
+
+func loadScriptOrRequireJSModuleAndRunImpl(moduleName, src string, attributes map[string]string, runJS string, transient bool) error {
+	js, err := loadScriptOrRequireJSModuleAndRunJS(moduleName, src, attributes, runJS)
 	if err != nil {
-		return errors.Wrapf(err, "failed to execute template for LoadScriptOrRequireJSModuleAndRun(%q)", moduleName)
+		return err
 	}
-	js := buf.String()
 	if transient {
 		TransientJavascript(js)
 	} else {
@@ -179,3 +180,23 @@ func loadScriptOrRequireJSModuleAndRunImpl(moduleName, src string, attributes ma
 	}
 	return nil
 }
+
+// loadScriptOrRequireJSModuleAndRunJS returns the Javascript code of [LoadScriptOrRequireJSModuleAndRun].
+func loadScriptOrRequireJSModuleAndRunJS(moduleName, src string, attributes map[string]string, runJS string) (string, error) {
+	var buf bytes.Buffer
+	data := struct {
+		ModuleName, Src, RequireJSPath, RunJS string
+		Attributes                            map[string]string
+	}{
+		ModuleName:    moduleName,
+		Src:           src,
+		RequireJSPath: strings.TrimSuffix(src, ".js"), // RequireJS paths don't include the extension.
+		RunJS:         runJS,
+		Attributes:    attributes,
+	}
+	err := loadOrRequireAndRunTmpl.Execute(&buf, data)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to execute template for LoadScriptOrRequireJSModuleAndRun(%q)", moduleName)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,107 @@
+package gonbui
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+// OfferFileDownload displays a button to download the file in path, saved with the given name (if empty, the
+// base name of path is used). The file is served by the kernel when the button is clicked -- so it works with
+// remote Jupyter servers, and the file is read when downloaded, not when the cell is executed.
+//
+// Example:
+//
+//	err := os.WriteFile("results.csv", csvContents, 0644)
+//	...
+//	gonbui.OfferFileDownload("results.csv", "")
+func OfferFileDownload(path, name string) error {
+	if !IsNotebook {
+		return nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the absolute path of %q", path)
+	}
+	if name == "" {
+		name = filepath.Base(absPath)
+	}
+	startComms()
+	htmlId := "gonb_download_" + UniqueId()
+	DisplayHtml(fmt.Sprintf(`<div id="%s">
+<button style="cursor: pointer;">&#11015; Download %s</button> <span class="gonb-download-status"></span>
+</div>
+<script>
+(() => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+	const status = root.querySelector(".gonb-download-status");
+	const id = %s, name = %s;
+	root.querySelector("button").addEventListener("click", () => {
+		const comm = globalThis?.gonb_comm;
+		if (!comm) {
+			status.textContent = "connection to GoNB not available, execute the cell again";
+			return;
+		}
+		status.textContent = "downloading...";
+		const subscription = comm.subscribe(%s, (address, value) => {
+			if (value?.id !== id) {
+				return;
+			}
+			comm.unsubscribe(subscription);
+			if (value.error) {
+				status.textContent = "failed: " + value.error;
+				return;
+			}
+			const binary = atob(value.data);
+			const bytes = new Uint8Array(binary.length);
+			for (let ii = 0; ii < binary.length; ii++) {
+				bytes[ii] = binary.charCodeAt(ii);
+			}
+			const link = document.createElement("a");
+			link.href = URL.createObjectURL(new Blob([bytes]));
+			link.download = name;
+			link.click();
+			setTimeout(() => URL.revokeObjectURL(link.href), 1000);
+			status.textContent = "";
+		});
+		comm.send(%s, {id: id, path: %s});
+	});
+})();
+</script>`, htmlId, html.EscapeString(name), jsValue(htmlId), jsValue(htmlId), jsValue(name),
+		jsValue(protocol.GonbuiDownloadDataAddress), jsValue(protocol.GonbuiDownloadAddress), jsValue(absPath)))
+	return nil
+}
+
+// CopyToClipboard copies the text to the clipboard of the browser. Since browsers only allow it in response
+// to a user action in some circumstances (e.g.: if the page is not focused), it also displays a button
+// to copy it.
+func CopyToClipboard(text string) {
+	if !IsNotebook {
+		return
+	}
+	htmlId := "gonb_clipboard_" + UniqueId()
+	DisplayHtml(fmt.Sprintf(`<div id="%s">
+<button style="cursor: pointer;">&#128203; Copy to clipboard</button> <span class="gonb-clipboard-status"></span>
+</div>
+<script>
+(() => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+	const status = root.querySelector(".gonb-clipboard-status");
+	const text = %s;
+	const copy = () => navigator.clipboard.writeText(text).then(
+		() => { status.textContent = "copied"; },
+		(err) => { status.textContent = "not copied (" + err + "), click the button"; });
+	root.querySelector("button").addEventListener("click", copy);
+	copy();
+})();
+</script>`, htmlId, jsValue(htmlId), jsValue(text)))
+}
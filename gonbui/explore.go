@@ -0,0 +1,304 @@
+package gonbui
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+var (
+	// ExploreMaxDepth is the maximum depth of the values displayed by Explore: deeper values are summarized.
+	ExploreMaxDepth = 20
+
+	// ExploreMaxItems is the maximum number of elements of each slice, array or map displayed by Explore.
+	ExploreMaxItems = 1000
+
+	// ExploreMaxNodes is the maximum total number of nodes of the tree displayed by Explore, since it is
+	// included in the output of the cell: once reached, the elements of the values that follow are not included.
+	ExploreMaxNodes = 10000
+)
+
+// exploreNode is a node of the tree displayed by Explore, encoded in JSON with short names to keep the
+// output small.
+type exploreNode struct {
+	Label    string         `json:"l,omitempty"` // Field name, map key or index.
+	Type     string         `json:"t,omitempty"`
+	Value    string         `json:"v,omitempty"` // Value of leaves, or summary of the others (e.g.: "len=3").
+	Children []*exploreNode `json:"c,omitempty"`
+	More     int            `json:"m,omitempty"` // Number of elements not included, see ExploreMaxItems and ExploreMaxNodes.
+	Hover    string         `json:"h,omitempty"` // Shown when hovering over the node.
+}
+
+// explorer converts values to exploreNode, detecting cycles of pointers.
+type explorer struct {
+	visiting map[uintptr]bool
+	nodes    int // Number of nodes included, up to ExploreMaxNodes.
+}
+
+// reserve returns how many of the n children of a node can be included, within ExploreMaxNodes, and counts
+// them as included. The children of a node are reserved before they are expanded, so every level shows
+// some of its elements.
+func (e *explorer) reserve(n int) int {
+	n = max(min(n, ExploreMaxNodes-e.nodes), 0)
+	e.nodes += n
+	return n
+}
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// node returns the tree of the value.
+func (e *explorer) node(label string, v reflect.Value, depth int) *exploreNode {
+	if !v.IsValid() {
+		return &exploreNode{Label: label, Type: "nil", Value: "nil"}
+	}
+	if isProtoMessage(v) {
+		return e.protoMessage(label, v, depth)
+	}
+	n := &exploreNode{Label: label, Type: v.Type().String()}
+	if raw, ok := explorerRawJSON(v); ok {
+		var decoded any
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			n.Value = fmt.Sprintf("invalid JSON: %v", err)
+			return n
+		}
+		child := e.node(label, reflect.ValueOf(decoded), depth)
+		child.Type = n.Type
+		return child
+	}
+	if v.CanInterface() && (v.Type().Implements(errorType) || v.Type().Implements(stringerType)) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			n.Value = "nil"
+		} else {
+			n.Value = fmt.Sprint(v.Interface())
+		}
+		return n
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			n.Value = "nil"
+			return n
+		}
+		if v.Kind() == reflect.Pointer {
+			addr := v.Pointer()
+			if e.visiting[addr] {
+				n.Value = fmt.Sprintf("<cycle to %#x>", addr)
+				return n
+			}
+			e.visiting[addr] = true
+			defer delete(e.visiting, addr)
+		}
+		child := e.node(label, v.Elem(), depth)
+		if v.Kind() == reflect.Pointer {
+			child.Type = n.Type
+		}
+		return child
+	case reflect.Struct:
+		n.Value = fmt.Sprintf("%d fields", v.NumField())
+		if depth >= ExploreMaxDepth {
+			return n
+		}
+		count := e.reserve(v.NumField())
+		for ii := 0; ii < count; ii++ {
+			n.Children = append(n.Children, e.node(v.Type().Field(ii).Name, v.Field(ii), depth+1))
+		}
+		n.More = v.NumField() - count
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			n.Value = "nil"
+			return n
+		}
+		n.Value = "len=" + strconv.Itoa(v.Len())
+		if depth >= ExploreMaxDepth {
+			return n
+		}
+		count := e.reserve(min(v.Len(), ExploreMaxItems))
+		for ii := 0; ii < count; ii++ {
+			n.Children = append(n.Children, e.node(strconv.Itoa(ii), v.Index(ii), depth+1))
+		}
+		n.More = v.Len() - count
+	case reflect.Map:
+		if v.IsNil() {
+			n.Value = "nil"
+			return n
+		}
+		n.Value = "len=" + strconv.Itoa(v.Len())
+		if depth >= ExploreMaxDepth {
+			return n
+		}
+		keys := v.MapKeys()
+		labels := make([]string, len(keys))
+		order := make([]int, len(keys))
+		for ii, key := range keys {
+			if key.Kind() == reflect.String {
+				labels[ii] = key.String()
+			} else {
+				labels[ii] = explorerLeaf(key)
+			}
+			order[ii] = ii
+		}
+		sort.Slice(order, func(i, j int) bool { return labels[order[i]] < labels[order[j]] })
+		count := e.reserve(min(len(order), ExploreMaxItems))
+		for _, ii := range order[:count] {
+			n.Children = append(n.Children, e.node(labels[ii], v.MapIndex(keys[ii]), depth+1))
+		}
+		n.More = len(keys) - count
+	default:
+		n.Value = explorerLeaf(v)
+	}
+	return n
+}
+
+// explorerRawJSON returns the JSON in v, if it is a json.RawMessage.
+func explorerRawJSON(v reflect.Value) ([]byte, bool) {
+	if v.Type() != reflect.TypeOf(json.RawMessage(nil)) || v.IsNil() {
+		return nil, false
+	}
+	return v.Bytes(), true
+}
+
+// explorerLeaf formats a basic value (including unexported fields, which can't be used with fmt).
+func explorerLeaf(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits())
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Pointer:
+		if v.IsNil() {
+			return "nil"
+		}
+		return fmt.Sprintf("%#x", v.Pointer())
+	}
+	if v.CanInterface() {
+		return fmt.Sprint(v.Interface())
+	}
+	return "<" + v.Type().String() + ">"
+}
+
+// Explore displays an expandable tree view of v -- a much better way to inspect nested data than
+// `fmt.Printf("%+v", v)`. Structs (including their unexported fields), maps (sorted by key), slices, arrays,
+// pointers and interfaces can be expanded, and `json.RawMessage` values are decoded. Values implementing
+// `fmt.Stringer` or `error` are displayed as strings. Protocol Buffers messages are displayed as in DisplayProto.
+//
+// The nodes are only rendered when expanded, and large slices and maps are shown in pages. Up to
+// ExploreMaxItems elements of each slice or map are included, up to ExploreMaxDepth levels deep, and up to
+// ExploreMaxNodes nodes in total: the number of elements not included is shown.
+//
+// Example:
+//
+//	var config map[string]any
+//	err := json.Unmarshal(contents, &config)
+//	...
+//	gonbui.Explore(config)
+func Explore(v any) {
+	if !IsNotebook {
+		return
+	}
+	root := (&explorer{visiting: make(map[uintptr]bool)}).node("", reflect.ValueOf(v), 0)
+	htmlId := "gonb_explore_" + UniqueId()
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMETextHTML:  fmt.Sprintf(exploreHtml, htmlId, jsValue(htmlId), jsValue(root)),
+			protocol.MIMETextPlain: fmt.Sprintf("%s: %s", root.Type, root.Value),
+		},
+	})
+}
+
+// exploreHtml renders the tree: the `<details>` of the nodes are only filled when first opened, with pages of
+// 100 children.
+const exploreHtml = `<div id="%s" style="font-family: monospace;"></div>
+<script>
+(() => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+	const pageSize = 100;
+	const header = (node) => {
+		const span = document.createElement("span");
+		if (node.l !== undefined) {
+			const label = document.createElement("b");
+			label.textContent = node.l + ": ";
+			span.appendChild(label);
+		}
+		if (node.t) {
+			const type = document.createElement("span");
+			type.style.color = "#777";
+			type.textContent = node.t + " ";
+			span.appendChild(type);
+		}
+		if (node.h) {
+			span.title = node.h;
+		}
+		span.appendChild(document.createTextNode(node.v ?? ""));
+		return span;
+	};
+	const render = (node) => {
+		if (!node.c && !node.m) {
+			const leaf = document.createElement("div");
+			leaf.style.marginLeft = "1.1em";
+			leaf.appendChild(header(node));
+			return leaf;
+		}
+		const details = document.createElement("details");
+		const summary = document.createElement("summary");
+		summary.style.cursor = "pointer";
+		summary.appendChild(header(node));
+		details.appendChild(summary);
+		const body = document.createElement("div");
+		body.style.marginLeft = "1.1em";
+		details.appendChild(body);
+		const children = node.c ?? [];
+		let shown = 0, opened = false;
+		const showPage = () => {
+			body.querySelector(":scope > .gonb-explore-more")?.remove();
+			for (const child of children.slice(shown, shown + pageSize)) {
+				body.appendChild(render(child));
+			}
+			shown = Math.min(shown + pageSize, children.length);
+			const remaining = children.length - shown;
+			if (remaining > 0) {
+				const more = document.createElement("button");
+				more.className = "gonb-explore-more";
+				more.style.cursor = "pointer";
+				more.textContent = "show " + Math.min(remaining, pageSize) + " more of " + remaining;
+				more.addEventListener("click", showPage);
+				body.appendChild(more);
+			} else if (node.m) {
+				const omitted = document.createElement("div");
+				omitted.style.color = "#777";
+				omitted.textContent = "... " + node.m + " more not included";
+				body.appendChild(omitted);
+			}
+		};
+		details.addEventListener("toggle", () => {
+			if (details.open && !opened) {
+				opened = true;
+				showPage();
+			}
+		});
+		return details;
+	};
+	const tree = render(%s);
+	if (tree.tagName === "DETAILS") {
+		tree.open = true;
+	}
+	root.appendChild(tree);
+})();
+</script>`
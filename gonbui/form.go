@@ -0,0 +1,166 @@
+package gonbui
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+// FormField is a field of a form displayed with Form. Create it with TextField, NumberField, CheckboxField or
+// SelectField.
+type FormField struct {
+	// Name of the field, the key of its value in FormValues.
+	Name string
+
+	// Label displayed next to the field. If empty, Name is used.
+	Label string
+
+	// Type of the field: "text", "number", "checkbox" or "select".
+	Type string
+
+	// Value is the initial value of the field: for "checkbox" fields, "true" if checked.
+	Value string
+
+	// Options of "select" fields.
+	Options []string
+}
+
+// TextField returns a text field for Form.
+func TextField(name, label, value string) FormField {
+	return FormField{Name: name, Label: label, Type: "text", Value: value}
+}
+
+// NumberField returns a numeric field for Form. Its value in FormValues can be parsed with strconv.ParseFloat.
+func NumberField(name, label string, value float64) FormField {
+	return FormField{Name: name, Label: label, Type: "number", Value: strconv.FormatFloat(value, 'g', -1, 64)}
+}
+
+// CheckboxField returns a checkbox for Form. Its value in FormValues is "true" or "false".
+func CheckboxField(name, label string, checked bool) FormField {
+	return FormField{Name: name, Label: label, Type: "checkbox", Value: strconv.FormatBool(checked)}
+}
+
+// SelectField returns a field for Form to select one of the options, initially value.
+func SelectField(name, label string, options []string, value string) FormField {
+	return FormField{Name: name, Label: label, Type: "select", Value: value, Options: options}
+}
+
+// formFieldHtml returns the HTML of the field: a table row with the label and the input element.
+func formFieldHtml(field FormField) string {
+	label := field.Label
+	if label == "" {
+		label = field.Name
+	}
+	name := html.EscapeString(field.Name)
+	var input string
+	switch field.Type {
+	case "checkbox":
+		checked := ""
+		if field.Value == "true" {
+			checked = " checked"
+		}
+		input = fmt.Sprintf(`<input type="checkbox" name="%s"%s>`, name, checked)
+	case "select":
+		var options []string
+		for _, option := range field.Options {
+			selected := ""
+			if option == field.Value {
+				selected = " selected"
+			}
+			options = append(options, fmt.Sprintf(`<option%s>%s</option>`, selected, html.EscapeString(option)))
+		}
+		input = fmt.Sprintf(`<select name="%s">%s</select>`, name, strings.Join(options, ""))
+	case "number":
+		input = fmt.Sprintf(`<input type="number" step="any" name="%s" value="%s">`, name, html.EscapeString(field.Value))
+	default:
+		input = fmt.Sprintf(`<input type="text" name="%s" value="%s">`, name, html.EscapeString(field.Value))
+	}
+	return fmt.Sprintf(`<tr><td style="text-align: left;"><label>%s</label></td><td style="text-align: left;">%s</td></tr>`,
+		html.EscapeString(label), input)
+}
+
+// Form displays an HTML form with the given fields and a "Submit" button. When submitted, the values of the
+// fields are sent to the kernel, and can be read with FormValues in the following cell executions.
+//
+// Example:
+//
+//	gonbui.Form(
+//		gonbui.TextField("name", "Name", ""),
+//		gonbui.NumberField("age", "Age", 30),
+//		gonbui.SelectField("unit", "Unit", []string{"kg", "lb"}, "kg"))
+func Form(fields ...FormField) {
+	if !IsNotebook {
+		return
+	}
+	startComms()
+	var rows []string
+	for _, field := range fields {
+		rows = append(rows, formFieldHtml(field))
+	}
+	htmlId := "gonb_form_" + UniqueId()
+	address, _ := json.Marshal(protocol.GonbuiFormAddress)
+	jsId, _ := json.Marshal(htmlId)
+	DisplayHtml(fmt.Sprintf(`<form id="%s">
+<table>
+%s
+</table>
+<button type="submit" style="cursor: pointer;">Submit</button> <span class="gonb-form-status"></span>
+</form>
+<script>
+(() => {
+	const form = document.getElementById(%s);
+	if (!form) {
+		return;
+	}
+	const status = form.querySelector(".gonb-form-status");
+	form.addEventListener("submit", (event) => {
+		event.preventDefault();
+		const comm = globalThis?.gonb_comm;
+		if (!comm) {
+			status.textContent = "connection to GoNB not available, the values were not submitted";
+			return;
+		}
+		const values = {};
+		for (const element of form.elements) {
+			if (element.name) {
+				values[element.name] = element.type === "checkbox" ? String(element.checked) : element.value;
+			}
+		}
+		comm.send(%s, values);
+		status.textContent = "submitted: use gonbui.FormValues() in the next cells to read the values";
+	});
+})();
+</script>`, htmlId, strings.Join(rows, "\n"), jsId, address))
+}
+
+// FormValues returns the values submitted in the forms displayed with Form, by the names of the fields.
+// If more than one form was submitted, the values of all of them are returned, and for fields with the same
+// name, the last value submitted.
+//
+// The values are kept by the kernel until it is reset (`%reset`) or restarted. It returns an empty map if
+// no form was submitted.
+func FormValues() (map[string]string, error) {
+	filePath := os.Getenv(protocol.GONB_FORM_VALUES_ENV)
+	if filePath == "" {
+		return nil, errors.Errorf("form values not available: not running under GoNB, $%s is not set",
+			protocol.GONB_FORM_VALUES_ENV)
+	}
+	values := make(map[string]string)
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read the form values from %q", filePath)
+	}
+	if err = json.Unmarshal(contents, &values); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the form values in %q", filePath)
+	}
+	return values, nil
+}
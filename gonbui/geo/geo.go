@@ -0,0 +1,194 @@
+// Package geo displays maps in GoNB notebooks, using the [Leaflet](https://leafletjs.com/) library, with
+// markers, lines and layers given as Go values or [GeoJSON](https://geojson.org/).
+//
+// Example:
+//
+//	geo.DisplayMap(
+//		[]geo.Point{{Lat: 48.8566, Lon: 2.3522, Label: "Paris"}, {Lat: 51.5072, Lon: -0.1276, Label: "London"}},
+//		geo.Line{{Lat: 48.8566, Lon: 2.3522}, {Lat: 51.5072, Lon: -0.1276}},
+//	)
+//
+// The map tiles are downloaded from OpenStreetMap by default, see TileURL.
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/janpfeifer/gonb/gonbui/dom"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+var (
+	// LeafletSrc is the source from where to download Leaflet.
+	// If you have a local copy or an updated version of the library, change the value here.
+	LeafletSrc = "https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"
+
+	// LeafletCSS is the source of the stylesheet of Leaflet, matching LeafletSrc.
+	LeafletCSS = "https://unpkg.com/leaflet@1.9.4/dist/leaflet.css"
+
+	// TileURL is the template of the URL of the map tiles, see Leaflet's `L.tileLayer`.
+	TileURL = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+
+	// TileAttribution is the attribution of the map tiles, displayed in the corner of the map.
+	TileAttribution = `&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors`
+
+	// MapHeight is the CSS height of the maps displayed.
+	MapHeight = "400px"
+)
+
+// Point is a location, displayed as a marker. The Label, if set, is shown in a popup when the marker is clicked.
+type Point struct {
+	Lat, Lon float64
+	Label    string
+}
+
+// Line is a path through the points, displayed as a polyline.
+type Line []Point
+
+// feature is a GeoJSON feature.
+type feature struct {
+	Type       string         `json:"type"`
+	Geometry   map[string]any `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// pointFeature returns the GeoJSON feature of the point.
+func pointFeature(p Point) feature {
+	f := feature{
+		Type:       "Feature",
+		Geometry:   map[string]any{"type": "Point", "coordinates": []float64{p.Lon, p.Lat}},
+		Properties: map[string]any{},
+	}
+	if p.Label != "" {
+		f.Properties["label"] = p.Label
+	}
+	return f
+}
+
+// toGeoJSON returns the GeoJSON of a layer given to DisplayMap.
+func toGeoJSON(layer any) (json.RawMessage, error) {
+	var value any
+	switch l := layer.(type) {
+	case Point:
+		value = pointFeature(l)
+	case []Point:
+		features := make([]feature, 0, len(l))
+		for _, p := range l {
+			features = append(features, pointFeature(p))
+		}
+		value = map[string]any{"type": "FeatureCollection", "features": features}
+	case Line:
+		coordinates := make([][]float64, 0, len(l))
+		for _, p := range l {
+			coordinates = append(coordinates, []float64{p.Lon, p.Lat})
+		}
+		value = feature{
+			Type:       "Feature",
+			Geometry:   map[string]any{"type": "LineString", "coordinates": coordinates},
+			Properties: map[string]any{},
+		}
+	case string:
+		return rawGeoJSON([]byte(l))
+	case []byte:
+		return rawGeoJSON(l)
+	case json.RawMessage:
+		return rawGeoJSON(l)
+	default:
+		value = layer
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode layer of type %T as GeoJSON", layer)
+	}
+	return encoded, nil
+}
+
+// rawGeoJSON checks that data is valid JSON.
+func rawGeoJSON(data []byte) (json.RawMessage, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid GeoJSON layer: not valid JSON")
+	}
+	return data, nil
+}
+
+// DisplayMap displays a map with the given layers, zoomed to fit them. Each layer can be:
+//
+//   - A Point or a []Point: displayed as markers, with their labels shown when clicked.
+//   - A Line: displayed as a polyline.
+//   - GeoJSON, as a string, []byte or json.RawMessage.
+//   - Any other value, encoded as GeoJSON with json.Marshal: e.g. the types of
+//     `github.com/paulmach/orb/geojson`, or a map with a GeoJSON FeatureCollection.
+//
+// The "label", "name" or "title" property of the GeoJSON features, if set, is shown when they are clicked.
+func DisplayMap(layers ...any) error {
+	if !gonbui.IsNotebook {
+		return nil
+	}
+	geoJSONs := make([]json.RawMessage, 0, len(layers))
+	for _, layer := range layers {
+		geoJSON, err := toGeoJSON(layer)
+		if err != nil {
+			return err
+		}
+		geoJSONs = append(geoJSONs, geoJSON)
+	}
+	encodedLayers, err := json.Marshal(geoJSONs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode the layers of the map")
+	}
+	encodedSettings, _ := json.Marshal(map[string]string{
+		"css": LeafletCSS, "tiles": TileURL, "attribution": TileAttribution})
+
+	divId := "gonb_map_" + gonbui.UniqueId()
+	runJS := fmt.Sprintf(`
+	if (!module) {
+		module = window.L;
+	}
+	const settings = %s;
+	if (!document.querySelector('link[href="' + settings.css + '"]')) {
+		const link = document.createElement("link");
+		link.rel = "stylesheet";
+		link.href = settings.css;
+		document.head.appendChild(link);
+	}
+	const map = module.map('%s');
+	module.tileLayer(settings.tiles, {attribution: settings.attribution, maxZoom: 19}).addTo(map);
+	const bounds = module.latLngBounds([]);
+	for (const data of %s) {
+		const layer = module.geoJSON(data, {
+			onEachFeature: (feature, layer) => {
+				const properties = feature.properties ?? {};
+				const label = properties.label ?? properties.name ?? properties.title;
+				if (label !== undefined) {
+					const popup = document.createElement("span");
+					popup.textContent = String(label);
+					layer.bindPopup(popup);
+				}
+			},
+		}).addTo(map);
+		const layerBounds = layer.getBounds();
+		if (layerBounds.isValid()) {
+			bounds.extend(layerBounds);
+		}
+	}
+	if (bounds.isValid()) {
+		map.fitBounds(bounds, {padding: [20, 20], maxZoom: 15});
+	} else {
+		map.setView([0, 0], 1);
+	}
+`, encodedSettings, divId, encodedLayers)
+	script, err := dom.LoadScriptOrRequireJSModuleAndRunHtml("leaflet", LeafletSrc, map[string]string{"charset": "utf-8"}, runJS)
+	if err != nil {
+		return err
+	}
+	gonbui.SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMETextHTML:  fmt.Sprintf(`<div id="%s" style="height: %s;"></div>%s`, divId, MapHeight, script),
+			protocol.MIMETextPlain: fmt.Sprintf("[Map with %d layer(s)]", len(layers)),
+		},
+	})
+	return nil
+}
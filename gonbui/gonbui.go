@@ -450,6 +450,21 @@ func RequestInput(prompt string, password bool) {
 	})
 }
 
+// ClearOutput clears the outputs of the cell in the Jupyter notebook.
+//
+// If wait is true, the outputs are only cleared when the next output is displayed, which avoids
+// flickering when, for instance, redrawing an animation.
+func ClearOutput(wait bool) {
+	if !IsNotebook {
+		return
+	}
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMEClearOutput: wait,
+		},
+	})
+}
+
 // EmbedImageAsPNGSrc returns a string that can be used as in an HTML <img> tag, as its source (it's `src` field).
 // This simplifies embedding an image in HTML without requiring separate files. It embeds it as a PNG file
 // base64 encoded.
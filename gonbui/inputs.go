@@ -0,0 +1,83 @@
+package gonbui
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// This file implements input widgets bound to variables: their values are sent to the kernel, which declares
+// them as Go variables in the following executions of cells. This allows interactive tuning of parameters,
+// without the program having to listen to the front-end (see the `widgets` sub-package for that).
+
+// Slider displays a slider to choose an int between min and max, initially value. The value chosen is
+// declared by the kernel as `var <name> int = <value>` in the following cell executions.
+//
+// Re-executing the cell that displays the slider resets the variable to value.
+func Slider(name string, min, max, value int) {
+	displayInput(name, "int", fmt.Sprintf(`<input type="range" min="%d" max="%d" step="1" value="%d">`,
+		min, max, value))
+}
+
+// FloatSlider displays a slider to choose a float64 between min and max, in increments of step, initially
+// value. The value chosen is declared by the kernel as `var <name> float64 = <value>` in the following cell
+// executions.
+func FloatSlider(name string, min, max, step, value float64) {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	displayInput(name, "float64", fmt.Sprintf(`<input type="range" min="%s" max="%s" step="%s" value="%s">`,
+		format(min), format(max), format(step), format(value)))
+}
+
+// Checkbox displays a checkbox, initially checked if value is true. Its state is declared by the kernel as
+// `var <name> bool = <value>` in the following cell executions.
+func Checkbox(name string, value bool) {
+	checked := ""
+	if value {
+		checked = " checked"
+	}
+	displayInput(name, "bool", fmt.Sprintf(`<input type="checkbox"%s>`, checked))
+}
+
+// TextInput displays a text field, initially with value. The text entered is declared by the kernel as
+// `var <name> string = <value>` in the following cell executions.
+func TextInput(name, value string) {
+	displayInput(name, "string", fmt.Sprintf(`<input type="text" value="%s">`, html.EscapeString(value)))
+}
+
+// displayInput displays the input element, labeled with the name of the variable, and the Javascript that
+// sends its value (as a string) to the kernel, when displayed and whenever it changes.
+func displayInput(name, goType, input string) {
+	if !IsNotebook {
+		return
+	}
+	startComms()
+	htmlId := "gonb_input_" + UniqueId()
+	DisplayHtml(fmt.Sprintf(`<div id="%s" style="display: flex; align-items: center; gap: 0.5em;">
+<label><code>%s</code></label> %s <code class="gonb-input-value"></code>
+</div>
+<script>
+(() => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+	const input = root.querySelector("input"), shown = root.querySelector(".gonb-input-value");
+	const current = () => input.type === "checkbox" ? String(input.checked) : input.value;
+	const send = () => {
+		shown.textContent = input.type === "text" ? "" : current();
+		const comm = globalThis?.gonb_comm;
+		if (!comm) {
+			console.error("Communication to GoNB not setup, the value of %s will not be set.");
+			return;
+		}
+		comm.send(%s, {type: %s, value: current()});
+	};
+	input.addEventListener("input", () => { shown.textContent = input.type === "text" ? "" : current(); });
+	input.addEventListener("change", send);
+	send();
+})();
+</script>`, htmlId, html.EscapeString(name), input, jsValue(htmlId), html.EscapeString(name),
+		jsValue(protocol.GonbuiInputAddressPrefix+name), jsValue(goType)))
+}
@@ -0,0 +1,75 @@
+package gonbui
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// InterruptExitCode is the exit code of the program after the functions registered with OnInterrupt
+// run: the same as the shell uses for programs killed by SIGINT.
+const InterruptExitCode = 130
+
+// InterruptTimeout is the maximum time given to the functions registered with OnInterrupt to finish,
+// before the program exits anyway.
+var InterruptTimeout = 5 * time.Second
+
+var (
+	muInterrupt    sync.Mutex
+	interruptHooks []func()
+	onceInterrupt  sync.Once
+)
+
+// OnInterrupt registers fn to be called when the execution of the cell is interrupted (the notebook's
+// stop button), so the program can clean up external resources (close files, stop servers, etc.)
+// before it exits. Without it, interruptions (SIGINT) terminate the program immediately.
+//
+// The registered functions are called in the reverse order of registration, like deferred functions,
+// and then the program exits with InterruptExitCode. If they don't finish within InterruptTimeout, or if
+// the execution is interrupted again, the program exits immediately.
+func OnInterrupt(fn func()) {
+	muInterrupt.Lock()
+	interruptHooks = append(interruptHooks, fn)
+	muInterrupt.Unlock()
+	onceInterrupt.Do(func() {
+		sigintC := make(chan os.Signal, 2)
+		signal.Notify(sigintC, os.Interrupt)
+		go func() {
+			<-sigintC
+			done := make(chan struct{})
+			go func() {
+				runInterruptHooks()
+				close(done)
+			}()
+			select {
+			case <-done:
+				Sync()
+			case <-sigintC:
+				fmt.Fprintln(os.Stderr, "gonbui.OnInterrupt: interrupted again, exiting without finishing clean up")
+			case <-time.After(InterruptTimeout):
+				fmt.Fprintf(os.Stderr, "gonbui.OnInterrupt: clean up didn't finish in %s, exiting\n", InterruptTimeout)
+			}
+			os.Exit(InterruptExitCode)
+		}()
+	})
+}
+
+// runInterruptHooks calls the functions registered with OnInterrupt, in reverse order. Panics are
+// reported and don't prevent the other functions from running.
+func runInterruptHooks() {
+	muInterrupt.Lock()
+	hooks := interruptHooks
+	muInterrupt.Unlock()
+	for ii := len(hooks) - 1; ii >= 0; ii-- {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(os.Stderr, "gonbui.OnInterrupt: clean up function panicked: %v\n", r)
+				}
+			}()
+			hooks[ii]()
+		}()
+	}
+}
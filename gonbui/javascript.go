@@ -1,6 +1,8 @@
 package gonbui
 
 import (
+	"encoding/json"
+
 	"github.com/janpfeifer/gonb/gonbui/protocol"
 )
 
@@ -22,3 +24,19 @@ func ScriptJavascript(js string) {
 		Data: map[protocol.MIMEType]any{protocol.MIMETextJavascript: js},
 	})
 }
+
+// startComms makes sure the `gonb_comm` Javascript module is installed in the front-end, as `comms.Start` does.
+// It is used by the displays whose Javascript communicates with the kernel.
+func startComms() {
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMECommValue: &protocol.CommValue{Address: protocol.GonbuiStartAddress, Value: 1},
+		},
+	})
+}
+
+// jsValue encodes v as a Javascript value. json.Marshal escapes "<", ">" and "&", so it's safe within <script>.
+func jsValue(v any) string {
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
@@ -0,0 +1,68 @@
+package gonbui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+// Out returns the textual output of the cell execution number `executionCount` (the number displayed next
+// to the cell in the notebook, as in `[3]`): what it printed to the standard output, and the "text/plain"
+// version of the data it displayed. It is similar to IPython's `Out[n]`, and allows cells to post-process
+// the results of earlier cells.
+//
+// It returns an error if the execution had no output, or if it is no longer kept: GoNB only keeps the
+// outputs of the last 100 executions.
+func Out(executionCount int) (string, error) {
+	dir, err := outputsDir()
+	if err != nil {
+		return "", err
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.txt", executionCount)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.Errorf("no output kept for the cell execution [%d]", executionCount)
+		}
+		return "", errors.Wrapf(err, "failed to read the output of the cell execution [%d]", executionCount)
+	}
+	return string(contents), nil
+}
+
+// LastOutput returns the textual output of the most recent previous cell execution that had an output,
+// similar to IPython's `_`. See Out.
+func LastOutput() (string, error) {
+	dir, err := outputsDir()
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the cell outputs in %q", dir)
+	}
+	last := -1
+	for _, entry := range entries {
+		count, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".txt"))
+		if err == nil && count > last {
+			last = count
+		}
+	}
+	if last < 0 {
+		return "", errors.New("no output kept for previous cell executions")
+	}
+	return Out(last)
+}
+
+// outputsDir returns the directory where GoNB keeps the outputs of the cell executions.
+func outputsDir() (string, error) {
+	dir := os.Getenv(protocol.GONB_OUTPUTS_DIR_ENV)
+	if dir == "" {
+		return "", errors.Errorf("previous outputs are only available when executed by GoNB (%s is not set)",
+			protocol.GONB_OUTPUTS_DIR_ENV)
+	}
+	return dir, nil
+}
@@ -16,6 +16,7 @@ import (
 	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
 	"github.com/janpfeifer/gonb/gonbui"
 	"github.com/janpfeifer/gonb/gonbui/dom"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
 	"github.com/pkg/errors"
 )
 
@@ -24,7 +25,13 @@ import (
 var PlotlySrc = "https://cdn.plot.ly/plotly-2.29.1.min.js"
 
 // DisplayFig as HTML output.
+//
+// If gonbui.SnapshotCharts is set (e.g. with `%config chart_snapshot=on`), once rendered a static PNG image
+// of the figure is included in the output, so it is shown in exported notebooks (PDF, GitHub previews).
 func DisplayFig(fig *grob.Fig) error {
+	if gonbui.SnapshotCharts {
+		return displayFigWithSnapshot(fig)
+	}
 	return displayFigToId("", fig)
 }
 
@@ -44,7 +51,12 @@ func displayFigToId(elementId string, fig *grob.Fig) error {
 	divId := gonbui.UniqueId()
 	divContent := fmt.Sprintf(`<div id="%s"></div>`, divId)
 	if elementId == "" {
-		gonbui.DisplayHTML(divContent)
+		gonbui.SendData(&protocol.DisplayData{
+			Data: map[protocol.MIMEType]any{
+				protocol.MIMETextHTML:  divContent,
+				protocol.MIMETextPlain: figSummary(fig),
+			},
+		})
 	} else {
 		dom.Append(elementId, divContent)
 	}
@@ -74,3 +86,61 @@ func displayFigToId(elementId string, fig *grob.Fig) error {
 	}
 	return nil
 }
+
+// displayFigWithSnapshot implements DisplayFig with gonbui.SnapshotCharts set: the figure is displayed
+// with a self-contained HTML (the `<div>` and the script to render it), so it can be updated with the snapshot
+// (see gonbui.SnapshotJavascript) once rendered.
+func displayFigWithSnapshot(fig *grob.Fig) error {
+	figBytes, err := json.Marshal(fig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal Json to use with plotly")
+	}
+	divId := gonbui.UniqueId()
+	displayId := "gonb_chart_" + divId
+	text := figSummary(fig)
+	figHtml := func(content, onPlotJS string) (string, error) {
+		runJS := fmt.Sprintf(`
+	if (!module) {
+		module = window.Plotly;
+	}
+	let data = JSON.parse('%s');
+	let div = document.getElementById('%s');
+	div.replaceChildren();
+	module.newPlot(div, data).then((gd) => {%s});
+`, figBytes, divId, onPlotJS)
+		script, err := dom.LoadScriptOrRequireJSModuleAndRunHtml("plotly", PlotlySrc, map[string]string{"charset": "utf-8"}, runJS)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`<div id="%s">%s</div>%s`, divId, content, script), nil
+	}
+	staticHtml, err := figHtml(protocol.SnapshotPlaceholder, "")
+	if err != nil {
+		return err
+	}
+	liveHtml, err := figHtml("", gonbui.SnapshotJavascript(displayId, staticHtml, text,
+		`module.toImage(gd, {format: "png"})`))
+	if err != nil {
+		return err
+	}
+	gonbui.SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMETextHTML:  liveHtml,
+			protocol.MIMETextPlain: text,
+		},
+		DisplayID: displayId,
+	})
+	return nil
+}
+
+// figSummary returns the text version of the figure, for front-ends that don't run Javascript (e.g.: consoles)
+// and exported scripts: its title, if any, and its number of traces.
+func figSummary(fig *grob.Fig) string {
+	title := ""
+	if fig.Layout != nil && fig.Layout.Title != nil {
+		if text, ok := fig.Layout.Title.Text.(string); ok && text != "" {
+			title = fmt.Sprintf(" %q", text)
+		}
+	}
+	return fmt.Sprintf("[Plotly figure%s with %d trace(s)]", title, len(fig.Data))
+}
@@ -0,0 +1,263 @@
+package gonbui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements the display of Protocol Buffers messages by Explore and DisplayProto. It doesn't depend
+// on the protobuf module: the schema (field numbers, types and JSON names) is read from the struct tags of the
+// code generated by `protoc-gen-go`.
+
+// isProtoMessage returns whether v is a (pointer to a) message generated by `protoc-gen-go`.
+func isProtoMessage(v reflect.Value) bool {
+	if v.Kind() != reflect.Pointer || v.Type().Elem().Kind() != reflect.Struct {
+		return false
+	}
+	_, found := v.Type().MethodByName("ProtoReflect")
+	return found
+}
+
+// protoField is the schema of a field, parsed from its `protobuf:"..."` struct tag, e.g.:
+// `protobuf:"bytes,2,rep,name=tags,proto3"`.
+type protoField struct {
+	encoding, cardinality string
+	number                int
+	name, jsonName, enum  string
+}
+
+// parseProtoTag parses the `protobuf:"..."` struct tag.
+func parseProtoTag(tag string) (f protoField) {
+	for ii, part := range strings.Split(tag, ",") {
+		switch {
+		case ii == 0:
+			f.encoding = part
+		case ii == 1:
+			f.number, _ = strconv.Atoi(part)
+		case part == "opt" || part == "req" || part == "rep":
+			f.cardinality = part
+		case strings.HasPrefix(part, "name="):
+			f.name = strings.TrimPrefix(part, "name=")
+		case strings.HasPrefix(part, "json="):
+			f.jsonName = strings.TrimPrefix(part, "json=")
+		case strings.HasPrefix(part, "enum="):
+			f.enum = strings.TrimPrefix(part, "enum=")
+		}
+	}
+	if f.jsonName == "" {
+		f.jsonName = f.name
+	}
+	return
+}
+
+// protoTypeName returns the name of the Protocol Buffers type of a (non-repeated) value of Go type t, with
+// the given encoding.
+func protoTypeName(f protoField, t reflect.Type) string {
+	if f.enum != "" {
+		return f.enum
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch f.encoding {
+	case "zigzag32":
+		return "sint32"
+	case "zigzag64":
+		return "sint64"
+	case "fixed32":
+		switch t.Kind() {
+		case reflect.Float32:
+			return "float"
+		case reflect.Int32:
+			return "sfixed32"
+		}
+		return "fixed32"
+	case "fixed64":
+		switch t.Kind() {
+		case reflect.Float64:
+			return "double"
+		case reflect.Int64:
+			return "sfixed64"
+		}
+		return "fixed64"
+	case "bytes", "group":
+		switch t.Kind() {
+		case reflect.String:
+			return "string"
+		case reflect.Slice:
+			return "bytes"
+		case reflect.Struct:
+			return protoMessageName(reflect.New(t))
+		}
+	}
+	return t.Kind().String() // bool, int32, int64, uint32 or uint64.
+}
+
+// protoMessageName returns the full name of the message (e.g. "helloworld.HelloRequest"), using
+// `m.ProtoReflect().Descriptor().FullName()`, or the Go type name if that fails.
+func protoMessageName(m reflect.Value) (name string) {
+	name = m.Type().Elem().String()
+	defer func() { _ = recover() }()
+	descriptor := m.MethodByName("ProtoReflect").Call(nil)[0].MethodByName("Descriptor").Call(nil)[0]
+	return descriptor.MethodByName("FullName").Call(nil)[0].String()
+}
+
+// protoMessage returns the tree of a message: its populated fields are labeled with their JSON names, and
+// the field numbers and types are given as hover text.
+func (e *explorer) protoMessage(label string, m reflect.Value, depth int) *exploreNode {
+	n := &exploreNode{Label: label, Type: protoMessageName(m)}
+	if m.IsNil() {
+		n.Value = "null"
+		return n
+	}
+	if e.visiting[m.Pointer()] {
+		n.Value = fmt.Sprintf("<cycle to %#x>", m.Pointer())
+		return n
+	}
+	e.visiting[m.Pointer()] = true
+	defer delete(e.visiting, m.Pointer())
+
+	// Populated fields.
+	type populatedField struct {
+		f           protoField
+		structField reflect.StructField
+		value       reflect.Value
+		oneof       string
+	}
+	v := m.Elem()
+	var fields []populatedField
+	for ii := 0; ii < v.NumField(); ii++ {
+		structField := v.Type().Field(ii)
+		if !structField.IsExported() {
+			continue
+		}
+		fieldValue := v.Field(ii)
+		if oneof := structField.Tag.Get("protobuf_oneof"); oneof != "" {
+			// The value of a oneof is a pointer to a wrapper struct with the field set.
+			if fieldValue.IsNil() || fieldValue.Elem().Kind() != reflect.Pointer || fieldValue.Elem().IsNil() {
+				continue
+			}
+			wrapper := fieldValue.Elem().Elem()
+			if wrapper.Kind() != reflect.Struct || wrapper.NumField() != 1 {
+				continue
+			}
+			fields = append(fields, populatedField{parseProtoTag(wrapper.Type().Field(0).Tag.Get("protobuf")),
+				wrapper.Type().Field(0), wrapper.Field(0), oneof})
+			continue
+		}
+		tag := structField.Tag.Get("protobuf")
+		if tag == "" || fieldValue.IsZero() {
+			continue // Not populated.
+		}
+		fields = append(fields, populatedField{parseProtoTag(tag), structField, fieldValue, ""})
+	}
+	n.Value = fmt.Sprintf("%d fields", len(fields))
+	if depth >= ExploreMaxDepth {
+		return n
+	}
+	count := e.reserve(len(fields))
+	for _, field := range fields[:count] {
+		child := e.protoField(field.f, field.structField, field.value, depth)
+		if field.oneof != "" {
+			child.Hover += ", oneof " + field.oneof
+		}
+		n.Children = append(n.Children, child)
+	}
+	n.More = len(fields) - count
+	return n
+}
+
+// protoField returns the tree of a populated field.
+func (e *explorer) protoField(f protoField, structField reflect.StructField, v reflect.Value, depth int) *exploreNode {
+	var typeName string
+	switch {
+	case v.Kind() == reflect.Map:
+		keyField := parseProtoTag(structField.Tag.Get("protobuf_key"))
+		valueField := parseProtoTag(structField.Tag.Get("protobuf_val"))
+		typeName = fmt.Sprintf("map<%s, %s>", protoTypeName(keyField, v.Type().Key()),
+			protoTypeName(valueField, v.Type().Elem()))
+		n := &exploreNode{Label: f.jsonName, Value: "len=" + strconv.Itoa(v.Len())}
+		if depth+1 < ExploreMaxDepth {
+			keys := v.MapKeys()
+			labels := make([]string, len(keys))
+			order := make([]int, len(keys))
+			for ii, key := range keys {
+				labels[ii] = explorerLeaf(key)
+				if key.Kind() == reflect.String {
+					labels[ii] = key.String()
+				}
+				order[ii] = ii
+			}
+			sort.Slice(order, func(i, j int) bool { return labels[order[i]] < labels[order[j]] })
+			count := e.reserve(min(len(order), ExploreMaxItems))
+			for _, ii := range order[:count] {
+				n.Children = append(n.Children, e.protoValue(labels[ii], valueField, v.MapIndex(keys[ii]), depth+2))
+			}
+			n.More = len(keys) - count
+		}
+		n.Hover = protoHover(f, typeName)
+		return n
+
+	case f.cardinality == "rep":
+		typeName = "repeated " + protoTypeName(f, v.Type().Elem())
+		n := &exploreNode{Label: f.jsonName, Value: "len=" + strconv.Itoa(v.Len())}
+		if depth+1 < ExploreMaxDepth {
+			count := e.reserve(min(v.Len(), ExploreMaxItems))
+			for ii := 0; ii < count; ii++ {
+				n.Children = append(n.Children, e.protoValue(strconv.Itoa(ii), f, v.Index(ii), depth+2))
+			}
+			n.More = v.Len() - count
+		}
+		n.Hover = protoHover(f, typeName)
+		return n
+	}
+	n := e.protoValue(f.jsonName, f, v, depth+1)
+	n.Hover = protoHover(f, protoTypeName(f, v.Type()))
+	return n
+}
+
+// protoHover returns the hover text of a field, e.g.: "field 2: repeated string tags".
+func protoHover(f protoField, typeName string) string {
+	hover := fmt.Sprintf("field %d: %s %s", f.number, typeName, f.name)
+	if f.cardinality == "req" {
+		hover = fmt.Sprintf("field %d: required %s %s", f.number, typeName, f.name)
+	}
+	return hover
+}
+
+// protoValue returns the tree of a singular value (or element of a repeated field or map), formatted as in JSON.
+func (e *explorer) protoValue(label string, f protoField, v reflect.Value, depth int) *exploreNode {
+	if isProtoMessage(v) {
+		return e.protoMessage(label, v, depth)
+	}
+	if v.Kind() == reflect.Pointer {
+		// Fields with explicit presence (proto2 or proto3 `optional`).
+		if v.IsNil() {
+			return &exploreNode{Label: label, Value: "null"}
+		}
+		v = v.Elem()
+	}
+	n := &exploreNode{Label: label}
+	switch {
+	case f.enum != "" && v.CanInterface():
+		n.Value = strconv.Quote(fmt.Sprint(v.Interface())) // Enums implement fmt.Stringer with the name of the value.
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		n.Value = strconv.Quote(base64.StdEncoding.EncodeToString(v.Bytes()))
+	default:
+		n.Value = explorerLeaf(v)
+	}
+	return n
+}
+
+// DisplayProto displays a Protocol Buffers message (a `proto.Message` generated by `protoc-gen-go`) as an
+// expandable tree in JSON form: only the populated fields are shown, labeled by their JSON names, and
+// hovering over a field shows its number and type. Explore also displays messages this way.
+//
+// See also `%%protoc`, to compile `.proto` definitions in a cell.
+func DisplayProto(m any) {
+	Explore(m)
+}
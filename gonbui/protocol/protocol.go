@@ -36,6 +36,30 @@ const (
 	// `!*` special commands.
 	GONB_TMP_DIR_ENV = "GONB_TMP_DIR"
 
+	// GONB_OUTPUTS_DIR_ENV is the name of the environment variable holding the directory with the
+	// textual outputs of the previous cell executions, one file per execution named `<execution count>.txt`.
+	//
+	// One doesn't need to use this directly usually, just use `gonbui.Out` or `gonbui.LastOutput` instead.
+	GONB_OUTPUTS_DIR_ENV = "GONB_OUTPUTS_DIR"
+
+	// GONB_FORM_VALUES_ENV is the name of the environment variable holding the path to the JSON file with the
+	// values submitted in the forms displayed with `gonbui.Form`. The file only exists after a form is submitted.
+	//
+	// One doesn't need to use this directly usually, just use `gonbui.FormValues` instead.
+	GONB_FORM_VALUES_ENV = "GONB_FORM_VALUES"
+
+	// GONB_UPLOADED_FILES_ENV is the name of the environment variable holding the path to the JSON file with the
+	// paths of the files uploaded with the widgets displayed by `gonbui.RequestFileUpload`, by their names. The
+	// file only exists after a file is uploaded.
+	//
+	// One doesn't need to use this directly usually, just use `gonbui.UploadedFiles` instead.
+	GONB_UPLOADED_FILES_ENV = "GONB_UPLOADED_FILES"
+
+	// GONB_CHART_SNAPSHOT_ENV is the name of the environment variable set to "true" if the interactive charts
+	// should also be captured as static PNG images (see `gonbui.SnapshotCharts`). Set with
+	// `%config chart_snapshot=on`.
+	GONB_CHART_SNAPSHOT_ENV = "GONB_CHART_SNAPSHOT"
+
 	// GONB_JUPYTER_ROOT_ENV is the path to the Jupyter root directory, if GONB managed
 	// to read it (depends on the architecture).
 	//
@@ -68,6 +92,13 @@ const (
 	// Notice that the Wasm program gets this value from a global variable automatically introduced in the Go code,
 	// see `%help`.
 	GONB_WASM_URL_ENV = "GONB_WASM_URL"
+
+	// GONB_SERVE_ADDR_ENV is the name of the environment variable with the address (`host:port`) where
+	// the program started by a `%serve` cell should listen for HTTP requests.
+	// It is only set when `%serve` is used.
+	//
+	// One doesn't need to use this directly usually, just use `gonbui.ServeHTTP` instead.
+	GONB_SERVE_ADDR_ENV = "GONB_SERVE_ADDR"
 )
 
 type MIMEType string
@@ -86,6 +117,12 @@ const (
 	// It's a GoNB specific mime type.
 	MIMEJupyterInput MIMEType = "gonb/jupyter_input"
 
+	// MIMEClearOutput maps to a `bool` (whether to wait for the next output), and clears the outputs
+	// of the cell. It's used by `gonbui.ClearOutput`.
+	//
+	// It's a GoNB specific mime type.
+	MIMEClearOutput MIMEType = "gonb/clear_output"
+
 	// MIMECommValue maps to a `*CommValue`. It can be used to send or request a value to/from
 	// the front-end (notebook).
 	// It's used by `comms.UpdateValue` and `comms.ReadValue`, used by widgets implementations.
@@ -158,6 +195,34 @@ const (
 	GonbuiSyncAckAddress = "#gonbui/sync_ack"
 	// GonbuiStartAddress is for internal use -- used to implement `comms.Start`.
 	GonbuiStartAddress = "#comms/start"
+	// GonbuiInputAddressPrefix is for internal use -- used to implement `gonbui.Slider` and the other input
+	// widgets: followed by the name of the variable, it's handled by the kernel.
+	GonbuiInputAddressPrefix = "#gonbui/input/"
+	// GonbuiFormAddress is for internal use -- used to implement `gonbui.Form`: the values submitted are sent
+	// to it, and handled by the kernel.
+	GonbuiFormAddress = "#gonbui/form"
+	// GonbuiDownloadAddress is for internal use -- used to implement `gonbui.OfferFileDownload`: the requests
+	// of the files to download are sent to it, and handled by the kernel, which replies to
+	// GonbuiDownloadDataAddress.
+	GonbuiDownloadAddress = "#gonbui/download"
+	// GonbuiDownloadDataAddress is for internal use -- the contents of the files requested to
+	// GonbuiDownloadAddress are sent to it.
+	GonbuiDownloadDataAddress = "#gonbui/download_data"
+	// GonbuiUploadAddress is for internal use -- used to implement `gonbui.RequestFileUpload`: the chunks of
+	// the files uploaded are sent to it, and handled by the kernel, which acknowledges each one to
+	// GonbuiUploadAckAddress.
+	GonbuiUploadAddress = "#gonbui/upload"
+	// GonbuiUploadAckAddress is for internal use -- the chunks received in GonbuiUploadAddress are acknowledged
+	// to it.
+	GonbuiUploadAckAddress = "#gonbui/upload_ack"
+	// GonbuiSnapshotAddress is for internal use -- used to implement `gonbui.SnapshotJavascript`: the PNG snapshots
+	// of the charts rendered in the front-end are sent to it, and the kernel updates the output of the chart
+	// to include them.
+	GonbuiSnapshotAddress = "#gonbui/snapshot"
+
+	// SnapshotPlaceholder is replaced by an `<img>` element with the snapshot, in the HTML of the outputs
+	// updated with the snapshots sent to GonbuiSnapshotAddress.
+	SnapshotPlaceholder = "<!--gonb_snapshot-->"
 )
 
 func init() {
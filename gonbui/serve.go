@@ -0,0 +1,60 @@
+package gonbui
+
+import (
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+// ServeHTTP serves the handler on the address assigned by the kernel to a `%serve` cell, where it is
+// kept running (across cells) until `%stop`, while its page is previewed in the cell output.
+//
+// Example:
+//
+//	%serve
+//	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintf(w, "Hello!") })
+//	if err := gonbui.ServeHTTP(http.DefaultServeMux); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// It only returns if the server fails. It returns an error if the cell was not started with `%serve`.
+func ServeHTTP(handler http.Handler) error {
+	listener, err := ServeListener()
+	if err != nil {
+		return err
+	}
+	return http.Serve(listener, handler)
+}
+
+// ServeListener returns a listener on the address assigned by the kernel to a `%serve` cell, for servers
+// other than HTTP. For gRPC servers, use `%serve --grpc` and register the reflection service, so requests
+// can be sent with `%grpc call <Service>/<Method> <json>`.
+//
+// Example:
+//
+//	%serve --grpc
+//	listener, err := gonbui.ServeListener()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	server := grpc.NewServer()
+//	pb.RegisterGreeterServer(server, &greeter{})
+//	reflection.Register(server)
+//	log.Fatal(server.Serve(listener))
+//
+// It returns an error if the cell was not started with `%serve`.
+func ServeListener() (net.Listener, error) {
+	addr := os.Getenv(protocol.GONB_SERVE_ADDR_ENV)
+	if addr == "" {
+		return nil, errors.Errorf("gonbui.ServeHTTP and gonbui.ServeListener must be called from a cell "+
+			"with `%%serve`, $%s is not set", protocol.GONB_SERVE_ADDR_ENV)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %q", addr)
+	}
+	return listener, nil
+}
@@ -0,0 +1,42 @@
+package gonbui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// SnapshotCharts indicates whether the interactive charts (e.g.: `plotly.DisplayFig`) should also be captured
+// as static PNG images, included in their outputs, so exported notebooks (PDF, GitHub previews) show them.
+//
+// It defaults to true if enabled in the kernel with `%config chart_snapshot=on`, and can be changed by the
+// program.
+var SnapshotCharts = os.Getenv(protocol.GONB_CHART_SNAPSHOT_ENV) == "true"
+
+// SnapshotJavascript returns the Javascript code to send the snapshot of a chart to the kernel, which then
+// updates the output with the given displayId (see UpdateHtml) with staticHtml -- where
+// protocol.SnapshotPlaceholder is replaced by an `<img>` with the snapshot -- the image itself as "image/png",
+// and text as "text/plain".
+//
+// pngPromise is a Javascript expression with a promise of the PNG image of the chart, as a data URL
+// ("data:image/png;base64,..."), e.g.: `Plotly.toImage(div, {format: "png"})`.
+//
+// staticHtml should render the chart the same way (without sending the snapshot again), replacing the image
+// when scripts are executed.
+func SnapshotJavascript(displayId, staticHtml, text, pngPromise string) string {
+	return fmt.Sprintf(`
+(async () => {
+	const comm = globalThis?.gonb_comm;
+	if (!comm) {
+		return;
+	}
+	try {
+		const png = await %s;
+		comm.send(%s, {display_id: %s, html: %s, text: %s, png: png});
+	} catch (err) {
+		console.error("GoNB: failed to capture the chart snapshot:", err);
+	}
+})();
+`, pngPromise, jsValue(protocol.GonbuiSnapshotAddress), jsValue(displayId), jsValue(staticHtml), jsValue(text))
+}
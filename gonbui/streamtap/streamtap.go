@@ -0,0 +1,342 @@
+// Package streamtap taps into streams of messages -- e.g. Kafka topics or NATS subjects -- for a while,
+// displaying the messages received in a table updated live, to debug the event-driven systems prototyped in
+// notebooks.
+//
+// It doesn't depend on any client library: the messages are read from a Source, easily adapted from the
+// client used by the notebook. E.g., with NATS (github.com/nats-io/nats.go):
+//
+//	ch := make(chan *streamtap.Message, 100)
+//	sub, err := nc.Subscribe("orders.>", func(m *nats.Msg) {
+//		ch <- &streamtap.Message{Time: time.Now(), Topic: m.Subject, Value: m.Data}
+//	})
+//	...
+//	defer sub.Unsubscribe()
+//	n, err := streamtap.Tap(context.Background(), streamtap.ChanSource(ch), 10*time.Second)
+//
+// JSON objects are decoded, and their fields displayed as columns of the table.
+//
+// GoNB also offers the `%tap` special command, that taps into Kafka topics or NATS subjects using their command
+// line tools (`kcat` and `nats`), without writing any code.
+package streamtap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/pkg/errors"
+)
+
+var (
+	// MaxRows is the maximum number of messages displayed in the table: only the most recent ones are kept.
+	MaxRows = 100
+
+	// UpdateInterval is the interval between the updates of the table displayed by Tap.
+	UpdateInterval = 250 * time.Millisecond
+
+	// MaxCellLength is the maximum length of the values displayed in the cells of the table, longer values are
+	// truncated.
+	MaxCellLength = 200
+)
+
+// Message received from a stream.
+type Message struct {
+	// Time the message was received (or produced, if known).
+	Time time.Time
+
+	// Topic (Kafka), subject (NATS) or any other name of the stream, optional.
+	Topic string
+
+	// Key of the message, optional.
+	Key string
+
+	// Value is the payload of the message.
+	Value []byte
+}
+
+// Source of messages.
+type Source interface {
+	// Next blocks until the next message is received, and returns it. It returns io.EOF if there are no more
+	// messages, or the error of the context if it is done.
+	Next(ctx context.Context) (*Message, error)
+}
+
+// SourceFunc is a function that implements Source.
+type SourceFunc func(ctx context.Context) (*Message, error)
+
+// Next implements Source.
+func (f SourceFunc) Next(ctx context.Context) (*Message, error) {
+	return f(ctx)
+}
+
+// ChanSource returns a Source that reads the messages from the channel, until it is closed.
+func ChanSource(ch <-chan *Message) Source {
+	return SourceFunc(func(ctx context.Context) (*Message, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case m, ok := <-ch:
+			if !ok {
+				return nil, io.EOF
+			}
+			return m, nil
+		}
+	})
+}
+
+// row of the table: a message with its decoded fields.
+type row struct {
+	msg    *Message
+	fields map[string]string // Set if the value is a JSON object.
+	value  string            // Set otherwise.
+}
+
+// Table of the messages received, with the most recent MaxRows. The fields of messages with JSON objects
+// are the columns of the table, in the order they were first seen.
+type Table struct {
+	rows     []row
+	columns  []string
+	isColumn map[string]bool
+	hasTopic bool
+	hasKey   bool
+	count    int
+}
+
+// NewTable returns an empty table of messages.
+func NewTable() *Table {
+	return &Table{isColumn: make(map[string]bool)}
+}
+
+// Count returns the number of messages added to the table, including the ones dropped.
+func (t *Table) Count() int {
+	return t.count
+}
+
+// truncate returns the value truncated to MaxCellLength runes.
+func truncate(value string) string {
+	if runes := []rune(value); len(runes) > MaxCellLength {
+		return string(runes[:MaxCellLength]) + "…"
+	}
+	return value
+}
+
+// Add adds the message to the table, decoding its value.
+func (t *Table) Add(msg *Message) {
+	t.count++
+	r := row{msg: msg}
+	var object map[string]json.RawMessage
+	decoder := json.NewDecoder(bytes.NewReader(msg.Value))
+	decoder.UseNumber()
+	switch {
+	case json.Unmarshal(msg.Value, &object) == nil && object != nil:
+		r.fields = make(map[string]string, len(object))
+		// Keep the order of the fields of the message, for the new columns.
+		var ordered []string
+		if token, err := decoder.Token(); err == nil && token == json.Delim('{') {
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					break
+				}
+				ordered = append(ordered, fmt.Sprint(key))
+				var skip json.RawMessage
+				if decoder.Decode(&skip) != nil {
+					break
+				}
+			}
+		}
+		for _, key := range ordered {
+			raw := object[key]
+			var text string
+			if json.Unmarshal(raw, &text) != nil {
+				text = string(raw)
+			}
+			r.fields[key] = truncate(text)
+			if !t.isColumn[key] {
+				t.isColumn[key] = true
+				t.columns = append(t.columns, key)
+			}
+		}
+	case utf8.Valid(msg.Value):
+		r.value = truncate(strings.TrimRight(string(msg.Value), "\n"))
+		if !t.isColumn[""] {
+			t.isColumn[""] = true
+			t.columns = append([]string{""}, t.columns...)
+		}
+	default:
+		r.value = fmt.Sprintf("(%d bytes of binary data)", len(msg.Value))
+		if !t.isColumn[""] {
+			t.isColumn[""] = true
+			t.columns = append([]string{""}, t.columns...)
+		}
+	}
+	t.hasTopic = t.hasTopic || msg.Topic != ""
+	t.hasKey = t.hasKey || msg.Key != ""
+	t.rows = append(t.rows, r)
+	if len(t.rows) > MaxRows {
+		t.rows = t.rows[len(t.rows)-MaxRows:]
+	}
+}
+
+// header returns the names of the columns of the table.
+func (t *Table) header() []string {
+	header := []string{"time"}
+	if t.hasTopic {
+		header = append(header, "topic")
+	}
+	if t.hasKey {
+		header = append(header, "key")
+	}
+	for _, column := range t.columns {
+		if column == "" {
+			column = "value"
+		}
+		header = append(header, column)
+	}
+	return header
+}
+
+// cells returns the values of the row, in the order of header.
+func (t *Table) cells(r row) []string {
+	cells := []string{r.msg.Time.Format("15:04:05.000")}
+	if t.hasTopic {
+		cells = append(cells, r.msg.Topic)
+	}
+	if t.hasKey {
+		cells = append(cells, r.msg.Key)
+	}
+	for _, column := range t.columns {
+		if column == "" {
+			cells = append(cells, r.value)
+		} else {
+			cells = append(cells, r.fields[column])
+		}
+	}
+	return cells
+}
+
+// Html returns the table as HTML, with the status (e.g.: "tapping for 5s") above it.
+func (t *Table) Html(status string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div><b>%s</b>: %d message(s)", html.EscapeString(status), t.count)
+	if t.count > len(t.rows) {
+		fmt.Fprintf(&sb, ", showing the last %d", len(t.rows))
+	}
+	sb.WriteString("</div>\n")
+	if len(t.rows) == 0 {
+		return sb.String()
+	}
+	sb.WriteString("<table>\n<tr>")
+	for _, name := range t.header() {
+		fmt.Fprintf(&sb, `<th style="text-align: left;">%s</th>`, html.EscapeString(name))
+	}
+	sb.WriteString("</tr>\n")
+	for _, r := range t.rows {
+		sb.WriteString("<tr>")
+		for _, cell := range t.cells(r) {
+			fmt.Fprintf(&sb, `<td style="text-align: left;">%s</td>`, html.EscapeString(cell))
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+// Text returns the table as aligned columns of text, with the status above it.
+func (t *Table) Text(status string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d message(s)\n", status, t.count)
+	if len(t.rows) == 0 {
+		return sb.String()
+	}
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.header(), "\t"))
+	for _, r := range t.rows {
+		fmt.Fprintln(w, strings.Join(t.cells(r), "\t"))
+	}
+	_ = w.Flush()
+	return sb.String()
+}
+
+// Tap reads the messages from source for the given duration (or until ctx is done, or the source returns
+// io.EOF), displaying them in a table updated live. It returns the number of messages received.
+//
+// Outside a notebook, the table is printed at the end.
+func Tap(ctx context.Context, source Source, duration time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	messages := make(chan *Message)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(messages)
+		for {
+			msg, err := source.Next(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	table := NewTable()
+	displayId := "gonb_streamtap_" + gonbui.UniqueId()
+	status := fmt.Sprintf("Tapping for %s", duration)
+	update := func() {
+		gonbui.UpdateHtml(displayId, table.Html(status))
+	}
+	update()
+	ticker := time.NewTicker(UpdateInterval)
+	defer ticker.Stop()
+	var err error
+	changed := false
+loop:
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				break loop
+			}
+			if msg.Time.IsZero() {
+				msg.Time = time.Now()
+			}
+			table.Add(msg)
+			changed = true
+		case <-ticker.C:
+			if changed {
+				update()
+				changed = false
+			}
+		case <-ctx.Done():
+			// In case the source doesn't stop when the context is done.
+			break loop
+		}
+	}
+	select {
+	case err = <-errs:
+	default:
+	}
+	if err == io.EOF || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	status = "Tapped for " + time.Since(start).Round(time.Millisecond).String()
+	if gonbui.IsNotebook {
+		update()
+	} else {
+		fmt.Print(table.Text(status))
+	}
+	return table.Count(), err
+}
@@ -0,0 +1,114 @@
+package gonbui
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pkg/errors"
+)
+
+// PreviewTemplateName is the name of the templates parsed by PreviewTemplate from their source.
+const PreviewTemplateName = "preview"
+
+// PreviewTemplate executes the template with data and displays its output: HTML in a sandboxed iframe (where
+// scripts don't run, and its styles don't affect the notebook), and text in a preformatted block.
+//
+// tmpl can be a parsed *html/template.Template or *text/template.Template, or the source of a template, parsed
+// with html/template if it starts with an HTML tag (e.g. "<div>"), or with text/template otherwise.
+//
+// If the template fails to parse or execute, the error is displayed with the lines of the source (if given)
+// around the line of the error, and returned.
+//
+// Example:
+//
+//	gonbui.PreviewTemplate(`<h3>{{.Title}}</h3>
+//	<ul>{{range .Items}}<li>{{.}}</li>{{end}}</ul>`,
+//		map[string]any{"Title": "Groceries", "Items": []string{"eggs", "milk"}})
+func PreviewTemplate(tmpl any, data any) error {
+	if !IsNotebook {
+		return nil
+	}
+	var (
+		source string
+		output bytes.Buffer
+		isHtml bool
+		err    error
+	)
+	switch t := tmpl.(type) {
+	case string:
+		source = t
+		isHtml = strings.HasPrefix(strings.TrimSpace(source), "<")
+		if isHtml {
+			var parsed *htmltemplate.Template
+			if parsed, err = htmltemplate.New(PreviewTemplateName).Parse(source); err == nil {
+				err = parsed.Execute(&output, data)
+			}
+		} else {
+			var parsed *texttemplate.Template
+			if parsed, err = texttemplate.New(PreviewTemplateName).Parse(source); err == nil {
+				err = parsed.Execute(&output, data)
+			}
+		}
+	case *htmltemplate.Template:
+		isHtml = true
+		err = t.Execute(&output, data)
+	case *texttemplate.Template:
+		err = t.Execute(&output, data)
+	default:
+		return errors.Errorf("PreviewTemplate: tmpl must be a template source or a parsed *html/template.Template "+
+			"or *text/template.Template, got %T", tmpl)
+	}
+	if err != nil {
+		DisplayHtml(templateErrorHtml(err, source))
+		return errors.Wrap(err, "PreviewTemplate")
+	}
+	if isHtml {
+		DisplayHtml(fmt.Sprintf(`<iframe sandbox="allow-same-origin" srcdoc="%s" style="width: 100%%; border: 1px solid #ccc;" `+
+			`onload="this.style.height = (this.contentDocument.documentElement.scrollHeight + 20) + 'px';"></iframe>`,
+			html.EscapeString(output.String())))
+	} else {
+		DisplayHtml(fmt.Sprintf(`<pre style="border: 1px solid #ccc; padding: 0.5em;">%s</pre>`,
+			html.EscapeString(output.String())))
+	}
+	return nil
+}
+
+// reTemplateErrorLine matches the line (and optionally the column) in the errors of html/template and
+// text/template, e.g.: "template: preview:3:12: executing ...".
+var reTemplateErrorLine = regexp.MustCompile(`template: ?[^:\s]*:(\d+)(?::\d+)?:`)
+
+// templateErrorContextLines is the number of lines of the template source displayed before and after the line
+// of the error.
+const templateErrorContextLines = 2
+
+// templateErrorHtml returns the HTML displaying the error of the template, with the lines of the source around
+// the line of the error, if the source is known.
+func templateErrorHtml(err error, source string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<div style="color: #c00;"><b>Template error:</b> %s</div>`, html.EscapeString(err.Error()))
+	matches := reTemplateErrorLine.FindStringSubmatch(err.Error())
+	if source == "" || matches == nil {
+		return sb.String()
+	}
+	errLine, _ := strconv.Atoi(matches[1])
+	lines := strings.Split(source, "\n")
+	if errLine < 1 || errLine > len(lines) {
+		return sb.String()
+	}
+	sb.WriteString(`<pre style="border: 1px solid #ccc; padding: 0.5em;">`)
+	for ii := max(errLine-templateErrorContextLines, 1); ii <= min(errLine+templateErrorContextLines, len(lines)); ii++ {
+		line := fmt.Sprintf("%4d  %s", ii, html.EscapeString(lines[ii-1]))
+		if ii == errLine {
+			line = fmt.Sprintf(`<span style="background: #fdd;"><b>%s</b></span>`, line)
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("</pre>")
+	return sb.String()
+}
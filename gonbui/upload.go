@@ -0,0 +1,135 @@
+package gonbui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+// UploadChunkSize is the size of the chunks in which the files are sent to the kernel by the widgets displayed
+// with RequestFileUpload.
+const UploadChunkSize = 512 * 1024
+
+// RequestFileUpload displays a widget to upload files from the browser to the kernel: the files chosen are
+// sent in chunks, saved in the directory of the kernel, and their paths can be read with UploadedFiles in the
+// following cell executions -- so remote notebooks can use local files of the user.
+//
+// Example:
+//
+//	gonbui.RequestFileUpload()
+//
+// And in the next cell, after uploading "data.csv":
+//
+//	files, err := gonbui.UploadedFiles()
+//	...
+//	contents, err := os.ReadFile(files["data.csv"])
+func RequestFileUpload() {
+	if !IsNotebook {
+		return
+	}
+	startComms()
+	htmlId := "gonb_upload_" + UniqueId()
+	DisplayHtml(fmt.Sprintf(`<div id="%s">
+<input type="file" multiple> <span class="gonb-upload-status"></span>
+</div>
+<script>
+(() => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+	const status = root.querySelector(".gonb-upload-status");
+	const input = root.querySelector("input");
+	const chunkSize = %d;
+	const uploadAddress = %s, ackAddress = %s;
+	let uploadCount = 0;
+
+	// sendChunk sends the chunk and returns a promise resolved with the acknowledgement of the kernel.
+	const sendChunk = (comm, chunk) => new Promise((resolve) => {
+		const subscription = comm.subscribe(ackAddress, (address, value) => {
+			if (value?.id !== chunk.id) {
+				return;
+			}
+			comm.unsubscribe(subscription);
+			resolve(value);
+		});
+		comm.send(uploadAddress, chunk);
+	});
+
+	const toBase64 = (buffer) => {
+		const bytes = new Uint8Array(buffer);
+		let binary = "";
+		for (let ii = 0; ii < bytes.length; ii += 0x8000) {
+			binary += String.fromCharCode.apply(null, bytes.subarray(ii, ii + 0x8000));
+		}
+		return btoa(binary);
+	};
+
+	const upload = async (comm, file) => {
+		const id = %s + "_" + (uploadCount++);
+		let offset = 0;
+		do {
+			const data = toBase64(await file.slice(offset, offset + chunkSize).arrayBuffer());
+			const last = offset + chunkSize >= file.size;
+			const ack = await sendChunk(comm, {id: id, name: file.name, offset: offset, data: data, last: last});
+			if (ack.error) {
+				throw new Error(ack.error);
+			}
+			offset = Math.min(offset + chunkSize, file.size);
+			status.textContent = file.name + ": " + Math.round(100 * offset / Math.max(file.size, 1)) + "%%";
+			if (last) {
+				return ack.path;
+			}
+		} while (true);
+	};
+
+	input.addEventListener("change", async () => {
+		const comm = globalThis?.gonb_comm;
+		if (!comm) {
+			status.textContent = "connection to GoNB not available, execute the cell again";
+			return;
+		}
+		input.disabled = true;
+		const paths = [];
+		try {
+			for (const file of input.files) {
+				paths.push(await upload(comm, file));
+			}
+			status.textContent = "uploaded to " + paths.join(", ") +
+				": use gonbui.UploadedFiles() in the next cells to read the paths";
+		} catch (err) {
+			status.textContent = "upload failed: " + err.message;
+		}
+		input.disabled = false;
+	});
+})();
+</script>`, htmlId, jsValue(htmlId), UploadChunkSize, jsValue(protocol.GonbuiUploadAddress),
+		jsValue(protocol.GonbuiUploadAckAddress), jsValue(htmlId)))
+}
+
+// UploadedFiles returns the paths of the files uploaded with the widgets displayed by RequestFileUpload, by
+// their names. If a file with the same name is uploaded more than once, the last one overwrites the others.
+//
+// The files are kept by the kernel until it is restarted. It returns an empty map if no file was uploaded.
+func UploadedFiles() (map[string]string, error) {
+	filePath := os.Getenv(protocol.GONB_UPLOADED_FILES_ENV)
+	if filePath == "" {
+		return nil, errors.Errorf("uploaded files not available: not running under GoNB, $%s is not set",
+			protocol.GONB_UPLOADED_FILES_ENV)
+	}
+	files := make(map[string]string)
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read the uploaded files from %q", filePath)
+	}
+	if err = json.Unmarshal(contents, &files); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the uploaded files in %q", filePath)
+	}
+	return files, nil
+}
@@ -75,6 +75,10 @@ type State struct {
 	// LogWebsocket controls whether to turn verbose logging (on the Javascript console) of the
 	// WebSocket Javascript library, when it is installed.
 	LogWebSocket bool
+
+	// kernelHandlers are the handlers of the addresses served by the kernel, by address prefix.
+	// See HandleAddressPrefix.
+	kernelHandlers map[string]KernelHandler
 }
 
 const (
@@ -364,6 +368,9 @@ func (s *State) HandleMsg(msg kernel.Message) (err error) {
 			klog.Warningf("comms: comm_msg did not set an \"content/data/value\" field: %+v", err)
 			return nil
 		}
+		if s.deliverKernelHandlerLocked(msg, address, value) {
+			return nil
+		}
 		if s.deliverProgramSubscriptionsLocked(address, value) {
 			klog.V(2).Infof("comms: HandleMsg(address=%q) delivered", address)
 		} else {
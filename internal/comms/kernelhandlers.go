@@ -0,0 +1,46 @@
+package comms
+
+import (
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+)
+
+// KernelHandler handles a message sent by the front-end to an address served by the kernel itself (as
+// opposed to the program being executed). It can reply with State.Send, using the given msg.
+type KernelHandler func(msg kernel.Message, address string, value any)
+
+// HandleAddressPrefix registers the handler of the messages from the front-end to addresses starting with
+// prefix. These are handled by the kernel, even if no program is being executed, e.g. to serve the contents
+// of outputs of previous cells.
+func (s *State) HandleAddressPrefix(prefix string, handler KernelHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kernelHandlers == nil {
+		s.kernelHandlers = make(map[string]KernelHandler)
+	}
+	s.kernelHandlers[prefix] = handler
+}
+
+// kernelHandlerLocked returns the handler registered with HandleAddressPrefix for the address, or nil.
+func (s *State) kernelHandlerLocked(address string) KernelHandler {
+	for prefix, handler := range s.kernelHandlers {
+		if strings.HasPrefix(address, prefix) {
+			return handler
+		}
+	}
+	return nil
+}
+
+// deliverKernelHandlerLocked calls the handler of the address, if one is registered, in a separate
+// goroutine -- since it will likely reply, which requires the lock. It returns whether there was one.
+func (s *State) deliverKernelHandlerLocked(msg kernel.Message, address string, value any) bool {
+	handler := s.kernelHandlerLocked(address)
+	if handler == nil {
+		return false
+	}
+	klog.V(2).Infof("comms: HandleMsg(address=%q) delivered to the kernel", address)
+	go handler(msg, address, value)
+	return true
+}
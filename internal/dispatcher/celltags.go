@@ -0,0 +1,169 @@
+package dispatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the cell metadata honored by GoNB, following the conventions of papermill, nbclient
+// and nbgrader: the tags and the "nbgrader" metadata of the cell executed are given in the metadata of the
+// "execute_request" (see RunNotebook), or read from the saved notebook file, for the cell with the id sent by
+// the front-end.
+
+const (
+	// SkipCellTag marks the cells that are not executed. Their outputs are kept. SkipExecutionCellTag is
+	// an alias.
+	SkipCellTag          = "skip"
+	SkipExecutionCellTag = "skip-execution"
+
+	// SetupCellTag marks the cells executed before the first cell executed after the kernel (re)starts.
+	SetupCellTag = "setup"
+
+	// RaisesExceptionCellTag marks the cells expected to fail: the error is displayed, but the execution is
+	// not marked as failed, so "Run All" continues.
+	RaisesExceptionCellTag = "raises-exception"
+)
+
+// cellDirectives are the tags honored by GoNB, and the nbgrader metadata, of the cell being executed.
+type cellDirectives struct {
+	cellId                string // Sent by the front-end, if any.
+	skip, raisesException bool
+
+	// autograder is set for the autograder test cells of nbgrader, see parseAutograderCell.
+	autograder *autograderCell
+}
+
+// autograderCell is an autograder test cell of nbgrader (https://nbgrader.readthedocs.io): "grade" is set and
+// "solution" is not, in its "nbgrader" metadata. Its grade is published after it is executed, see
+// goexec.PublishGrade.
+type autograderCell struct {
+	gradeId string
+	points  float64
+}
+
+// parseAutograderCell returns the autograderCell of the cell metadata, or nil if it is not an autograder test cell.
+func parseAutograderCell(metadata map[string]any) *autograderCell {
+	nbgrader, _ := metadata["nbgrader"].(map[string]any)
+	if grade, _ := nbgrader["grade"].(bool); !grade {
+		return nil
+	}
+	if solution, _ := nbgrader["solution"].(bool); solution {
+		return nil // Manually graded.
+	}
+	cell := &autograderCell{}
+	cell.gradeId, _ = nbgrader["grade_id"].(string)
+	cell.points, _ = nbgrader["points"].(float64)
+	return cell
+}
+
+// notebookCache holds the last notebook read by currentNotebook, reused while the file is not modified.
+var notebookCache struct {
+	mu           sync.Mutex
+	notebookPath string
+	modTime      time.Time
+	nb           Notebook
+}
+
+// currentNotebook returns the notebook of the kernel, read from the file given by Jupyter in
+// goexec.JupyterSessionNameEnv. It returns nil if it is not known or can't be read.
+func currentNotebook() Notebook {
+	notebookPath := os.Getenv(goexec.JupyterSessionNameEnv)
+	if notebookPath == "" || filepath.Ext(notebookPath) != ".ipynb" {
+		return nil
+	}
+	info, err := os.Stat(notebookPath)
+	if err != nil {
+		klog.V(1).Infof("Notebook file not available: %v", err)
+		return nil
+	}
+	notebookCache.mu.Lock()
+	defer notebookCache.mu.Unlock()
+	if notebookCache.nb != nil && notebookCache.notebookPath == notebookPath && notebookCache.modTime.Equal(info.ModTime()) {
+		return notebookCache.nb
+	}
+	nb, err := ReadNotebook(notebookPath)
+	if err != nil {
+		klog.Warningf("Failed to read the notebook for the tags of its cells: %+v", err)
+		return nil
+	}
+	notebookCache.notebookPath, notebookCache.modTime, notebookCache.nb = notebookPath, info.ModTime(), nb
+	return nb
+}
+
+// cellById returns the cell with the given id (nbformat >= 4.5), or nil if not found.
+func (nb Notebook) cellById(cellId string) map[string]any {
+	for _, cell := range nb.cells() {
+		if cell["id"] == cellId {
+			return cell
+		}
+	}
+	return nil
+}
+
+// executeDirectives returns the directives of the cell of the "execute_request" msg. They are taken from the
+// metadata of the request if it has the "tags" or the "nbgrader" metadata of the cell (see ExecuteHeadless), or
+// otherwise from the cell of the notebook file with the id sent by the front-end ("cellId", sent by JupyterLab
+// and Notebook 7) -- so changes to the cell metadata are only seen once the notebook is saved.
+func executeDirectives(msg kernel.Message) cellDirectives {
+	var d cellDirectives
+	metadata := msg.ComposedMsg().Metadata
+	d.cellId, _ = metadata["cellId"].(string)
+	_, hasTags := metadata["tags"]
+	_, hasNBGrader := metadata["nbgrader"]
+	if !hasTags && !hasNBGrader && d.cellId != "" {
+		if nb := currentNotebook(); nb != nil {
+			if cell := nb.cellById(d.cellId); cell != nil {
+				metadata, _ = cell["metadata"].(map[string]any)
+			}
+		}
+	}
+	tags, _ := metadata["tags"].([]any)
+	for _, tag := range tags {
+		switch tag {
+		case SkipCellTag, SkipExecutionCellTag:
+			d.skip = true
+		case RaisesExceptionCellTag:
+			d.raisesException = true
+		}
+	}
+	d.autograder = parseAutograderCell(metadata)
+	return d
+}
+
+// runSetupCells executes, in order, the code cells of the notebook tagged SetupCellTag (except the one with
+// cellId, being executed), if this is the first execution after the kernel (re)started.
+//
+// It requires the front-end to send the id of the cell executed, so it is not done for the cells executed by
+// RunNotebook, which executes all cells in order anyway.
+func runSetupCells(msg kernel.Message, goExec *goexec.State, cellId string) error {
+	if goExec.SetupCellsRun || cellId == "" {
+		return nil
+	}
+	goExec.SetupCellsRun = true
+	nb := currentNotebook()
+	if nb == nil {
+		return nil
+	}
+	for ii, cell := range nb.cells() {
+		if cell["cell_type"] != "code" || cell["id"] == cellId || !cellHasTag(cell, SetupCellTag) {
+			continue
+		}
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("* Executing cell #%d, tagged %q, first.\n", ii, SetupCellTag))
+		if err := executeCode(msg, goExec, cellSource(cell)); err != nil {
+			return errors.WithMessagef(err, "executing the cell #%d tagged %q", ii, SetupCellTag)
+		}
+		if msg.Kernel().Interrupted.Load() {
+			return nil
+		}
+	}
+	return nil
+}
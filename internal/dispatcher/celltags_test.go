@@ -0,0 +1,114 @@
+package dispatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHeadlessState creates a kernel with kernel.NewHeadless and its goexec.State.
+func newHeadlessState(t *testing.T) (*kernel.Kernel, *goexec.State) {
+	k := kernel.NewHeadless()
+	goExec, err := goexec.New(k, fmt.Sprintf("celltags%d", os.Getpid()), false, false)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = goExec.Stop() })
+	return k, goExec
+}
+
+// codeCell returns a notebook code cell with the given id, source and tags.
+func codeCell(id, source string, tags ...any) any {
+	return map[string]any{
+		"cell_type":       "code",
+		"id":              id,
+		"execution_count": nil,
+		"metadata":        map[string]any{"tags": tags},
+		"outputs":         []any{},
+		"source":          source,
+	}
+}
+
+func TestRunNotebookTags(t *testing.T) {
+	k, goExec := newHeadlessState(t)
+	t.Setenv("GONB_TEST_TAGS", "")
+	nb := Notebook{"cells": []any{
+		codeCell("a", "%config pager_lines=invalid", RaisesExceptionCellTag),
+		codeCell("b", "%config pager_lines=also_invalid", SkipCellTag),
+		codeCell("c", "%env GONB_TEST_TAGS=ok"),
+	}}
+	nb.cells()[1]["outputs"] = []any{"kept"}
+	require.NoError(t, RunNotebook(k, goExec, nb, nil, nil))
+	cells := nb.cells()
+	assert.Contains(t, fmt.Sprint(cells[0]["outputs"]), "invalid number of lines")
+	assert.Equal(t, []any{"kept"}, cells[1]["outputs"])
+	assert.Nil(t, cells[1]["execution_count"])
+	assert.Equal(t, "ok", os.Getenv("GONB_TEST_TAGS"))
+
+	// Without the tag, the failure stops the execution.
+	nb["cells"] = []any{codeCell("a", "%config pager_lines=invalid"), codeCell("b", "%env GONB_TEST_TAGS=not_reached")}
+	require.Error(t, RunNotebook(k, goExec, nb, nil, nil))
+	assert.Equal(t, "ok", os.Getenv("GONB_TEST_TAGS"))
+}
+
+func TestExecuteTagsFromNotebook(t *testing.T) {
+	k, goExec := newHeadlessState(t)
+	notebookPath := filepath.Join(t.TempDir(), "test.ipynb")
+	nb := Notebook{"cells": []any{
+		codeCell("setup", "%env GONB_TEST_SETUP=done", SetupCellTag),
+		codeCell("skipped", "%env GONB_TEST_SKIPPED=executed", SkipExecutionCellTag),
+		codeCell("main", "%env GONB_TEST_MAIN=executed"),
+	}}
+	require.NoError(t, nb.Write(notebookPath))
+	t.Setenv(goexec.JupyterSessionNameEnv, notebookPath)
+	for _, name := range []string{"GONB_TEST_SETUP", "GONB_TEST_SKIPPED", "GONB_TEST_MAIN"} {
+		t.Setenv(name, "")
+	}
+
+	execute := func(cellId, code string) *kernel.HeadlessMessage {
+		msg, err := kernel.NewHeadlessMessage(k, "execute_request", map[string]any{
+			"code": code, "silent": false, "store_history": true})
+		require.NoError(t, err)
+		msg.SetMetadata(map[string]any{"cellId": cellId})
+		require.NoError(t, handleExecuteRequest(msg, goExec))
+		return msg
+	}
+
+	// Skipped cell: the setup cells are not run either.
+	msg := execute("skipped", "%env GONB_TEST_SKIPPED=executed")
+	assert.Equal(t, "ok", msg.ReplyContent()["status"])
+	assert.Empty(t, os.Getenv("GONB_TEST_SKIPPED"))
+	assert.Empty(t, os.Getenv("GONB_TEST_SETUP"))
+
+	// First cell executed: the setup cell is executed first, and only once.
+	msg = execute("main", "%env GONB_TEST_MAIN=executed")
+	assert.Equal(t, "done", os.Getenv("GONB_TEST_SETUP"))
+	assert.Contains(t, fmt.Sprint(msg.Outputs()), `tagged "setup", first`)
+	t.Setenv("GONB_TEST_SETUP", "")
+	execute("main", "%env GONB_TEST_MAIN=executed")
+	assert.Empty(t, os.Getenv("GONB_TEST_SETUP"))
+}
+
+func TestRunNotebookAutograder(t *testing.T) {
+	k, goExec := newHeadlessState(t)
+	graded := func(id, source string, points float64) any {
+		cell := codeCell(id, source).(map[string]any)
+		cell["metadata"] = map[string]any{"nbgrader": map[string]any{
+			"grade": true, "solution": false, "grade_id": id, "points": points}}
+		return cell
+	}
+	nb := Notebook{"cells": []any{
+		graded("test_ok", "%env GONB_TEST_GRADE=ok", 2),
+		graded("test_fails", "%config pager_lines=invalid", 3),
+	}}
+	t.Setenv("GONB_TEST_GRADE", "")
+	require.Error(t, RunNotebook(k, goExec, nb, nil, nil))
+	cells := nb.cells()
+	assert.Contains(t, fmt.Sprint(cells[0]["outputs"]), `Grade "test_ok": 2 of 2 points.`)
+	assert.Contains(t, fmt.Sprint(cells[0]["outputs"]), goexec.GradeMIMEType)
+	assert.Contains(t, fmt.Sprint(cells[1]["outputs"]), `Grade "test_fails": 0 of 3 points.`)
+}
@@ -15,6 +15,7 @@ import (
 	"k8s.io/klog/v2"
 	"strings"
 	"sync"
+	"unicode/utf16"
 )
 
 const (
@@ -112,7 +113,7 @@ func handleShellMsg(msg kernel.Message, goExec *goexec.State) (err error) {
 	}
 	msgType := msg.ComposedMsg().Header.MsgType
 	defer func() {
-		klog.V(2).Infof("Message %q dispatched.", msgType)
+		klog.V(2).InfoS("Message dispatched", "msg_type", msgType)
 	}()
 
 	if !slices.Contains(BusyMessageTypes, msgType) {
@@ -121,7 +122,7 @@ func handleShellMsg(msg kernel.Message, goExec *goexec.State) (err error) {
 		case "comm_open", "comm_msg", "comm_comm_close", "comm_info_request":
 			// Handle in a separate goroutine.
 			go func() {
-				klog.V(1).Infof("Dispatcher: handling %q", msgType)
+				klog.V(1).InfoS("Dispatcher: handling message", "msg_type", msgType)
 				err = handleComms(msg, goExec)
 				if err != nil {
 					klog.Errorf("Failed to handle %q, this may affect communication with the front-end "+
@@ -130,13 +131,18 @@ func handleShellMsg(msg kernel.Message, goExec *goexec.State) (err error) {
 			}()
 
 		case "is_complete_request":
-			klog.V(2).Infof("Received is_complete_request: ignoring, since it's not a console like kernel.")
+			err = handleIsCompleteRequest(msg)
 
 		case "shutdown_request":
 			if err = handleShutdownRequest(msg, goExec); err != nil {
 				err = errors.WithMessagef(err, "replying 'shutdown_request'")
 			}
 
+		case "interrupt_request":
+			if err = handleInterruptRequest(msg); err != nil {
+				err = errors.WithMessagef(err, "replying 'interrupt_request'")
+			}
+
 		default:
 			// Log, ignore, and hope for the best.
 			klog.Infof("Unhandled shell-socket message %q", msg.ComposedMsg().Header.MsgType)
@@ -149,7 +155,7 @@ func handleShellMsg(msg kernel.Message, goExec *goexec.State) (err error) {
 		go func() {
 			for params := range busyMessagesChan {
 				msgType := msg.ComposedMsg().Header.MsgType
-				klog.V(1).Infof("Dispatcher: handling %q", msgType)
+				klog.V(1).InfoS("Dispatcher: handling message", "msg_type", msgType)
 				err := handleBusyMessage(params.msg, params.goExec)
 				if err != nil {
 					klog.Errorf("Failed to handle %q, this may indicate that the kernel is in an "+
@@ -193,7 +199,7 @@ func handleBusyMessage(msg kernel.Message, goExec *goexec.State) (err error) {
 
 	switch msgType {
 	case "kernel_info_request":
-		if err = kernel.SendKernelInfo(msg, Version); err != nil {
+		if err = kernel.SendKernelInfo(msg, Version, goExec.ActiveGoVersion()); err != nil {
 			err = errors.WithMessagef(err, "replying to 'kernel_info_request'")
 		}
 
@@ -214,7 +220,7 @@ func handleBusyMessage(msg kernel.Message, goExec *goexec.State) (err error) {
 		err = handleComms(msg, goExec)
 
 	case "is_complete_request":
-		klog.V(2).Infof("Received is_complete_request: ignoring, since it's not a console like kernel.")
+		err = handleIsCompleteRequest(msg)
 
 	default:
 		// Log, ignore, and hope for the best.
@@ -246,6 +252,13 @@ func handleShutdownRequest(msg kernel.Message, goExec *goexec.State) error {
 	return err
 }
 
+// handleInterruptRequest interrupts the execution, for kernels installed with `"interrupt_mode": "message"`
+// (the default on Windows), see kernel.Kernel.Interrupt.
+func handleInterruptRequest(msg kernel.Message) error {
+	msg.Kernel().Interrupt()
+	return msg.Reply("interrupt_reply", map[string]any{"status": "ok"})
+}
+
 type OutErr struct {
 	out io.Writer
 	err io.Writer
@@ -264,6 +277,21 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 		klog.Infof("Message content: %+v", content)
 	}
 
+	// Record execution, if `%record start` was used (in a previous cell).
+	var recorded *kernel.RecordedMessage
+	recorder := msg.Kernel().Recorder
+	if recorder != nil && !silent {
+		recorded = kernel.NewRecordedMessage(msg, code)
+		msg = recorded
+	}
+
+	// Capture the textual output of the execution, to be accessed by the following cells.
+	var captured *kernel.CapturedMessage
+	if storeHistory && !silent {
+		captured = kernel.NewCapturedMessage(msg)
+		msg = captured
+	}
+
 	// Prepare the map that will hold the reply content.
 	replyContent := make(map[string]any)
 	if storeHistory {
@@ -281,15 +309,32 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 
 	// Dispatch to various executors.
 	msg.Kernel().Interrupted.Store(false)
-	lines := strings.Split(code, "\n")
-	specialLines := MakeSet[int]() // lines that are special commands and not Go.
+	directives := executeDirectives(msg)
 	var executionErr error
-	if err := specialcmd.Parse(msg, goExec, true, lines, specialLines); err != nil {
-		executionErr = errors.WithMessagef(err, "executing special commands in cell")
+	if directives.skip {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("* Cell tagged %q: not executed.\n", SkipCellTag))
+	} else {
+		executionErr = runSetupCells(msg, goExec, directives.cellId)
+		if executionErr == nil && !msg.Kernel().Interrupted.Load() {
+			executionErr = executeCode(msg, goExec, code)
+		}
 	}
-	hasMoreToRun := len(specialLines) < len(lines) || goExec.CellIsTest
-	if executionErr == nil && !msg.Kernel().Interrupted.Load() && hasMoreToRun {
-		executionErr = goExec.ExecuteCell(msg, msg.Kernel().ExecCounter, lines, specialLines)
+	msg.Kernel().FlushStreams()
+	if directives.autograder != nil && !directives.skip {
+		grade := goExec.GradeCell(directives.autograder.gradeId, directives.autograder.points, executionErr)
+		if err := goexec.PublishGrade(msg, grade); err != nil {
+			klog.Warningf("Failed to publish the grade of the cell: %+v", err)
+		}
+	}
+	goExec.ResetCellSecrets()
+	if err := goExec.JournalDeclarations(msg); err != nil {
+		klog.Warningf("Failed to journal the memorized declarations: %+v", err)
+	}
+	if captured != nil {
+		if err := goExec.SaveCellOutput(msg.Kernel().ExecCounter, captured.Output()); err != nil {
+			klog.Warningf("Failed to save the output of the cell: %+v", err)
+		}
 	}
 
 	// Final execution result.
@@ -297,12 +342,22 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 		// if the only non-nil value should be auto-rendered graphically, render it
 		replyContent["status"] = "ok"
 		replyContent["user_expressions"] = make(map[string]string)
+		if directives.raisesException {
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+				fmt.Sprintf("* Cell tagged %q didn't fail.\n", RaisesExceptionCellTag))
+		}
 	} else {
 		name, value, traceback := goexec.JupyterErrorSplit(executionErr)
-		replyContent["status"] = "error"
-		replyContent["ename"] = name
-		replyContent["evalue"] = value
-		replyContent["traceback"] = traceback
+		if directives.raisesException {
+			// Expected error: it is displayed, but the execution is not marked as failed.
+			replyContent["status"] = "ok"
+			replyContent["user_expressions"] = make(map[string]string)
+		} else {
+			replyContent["status"] = "error"
+			replyContent["ename"] = name
+			replyContent["evalue"] = value
+			replyContent["traceback"] = traceback
+		}
 
 		// Publish an execution_error message.
 		if err := kernel.PublishExecutionError(msg, value, traceback, name); err != nil {
@@ -310,6 +365,17 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 		}
 	}
 
+	if payloads := msg.Kernel().TakePayloads(); len(payloads) > 0 {
+		replyContent["payload"] = payloads
+	}
+
+	if recorded != nil && msg.Kernel().Recorder == recorder {
+		// Not saved if the cell stopped the recording (`%record stop`).
+		if err := recorder.Save(recorded.Execution(replyContent["status"].(string))); err != nil {
+			klog.Errorf("Failed to record cell execution: %+v", err)
+		}
+	}
+
 	// Send the output back to the notebook.
 	if klog.V(2).Enabled() {
 		klog.Infof("> execute_reply: %+v", replyContent)
@@ -320,6 +386,20 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 	return nil
 }
 
+// executeCode executes the special commands and the Go code of a cell.
+func executeCode(msg kernel.Message, goExec *goexec.State, code string) error {
+	lines := strings.Split(code, "\n")
+	specialLines := MakeSet[int]() // lines that are special commands and not Go.
+	if err := specialcmd.Parse(msg, goExec, true, lines, specialLines); err != nil {
+		return errors.WithMessagef(err, "executing special commands in cell")
+	}
+	hasMoreToRun := len(specialLines) < len(lines) || goExec.CellIsTest || goExec.CellShare || goExec.CellRunCLI
+	if !msg.Kernel().Interrupted.Load() && hasMoreToRun {
+		return goExec.ExecuteCell(msg, msg.Kernel().ExecCounter, lines, specialLines)
+	}
+	return nil
+}
+
 // HandleInspectRequest presents rich data (HTML?) with contextual information for the
 // contents under the cursor.
 func HandleInspectRequest(msg kernel.Message, goExec *goexec.State) error {
@@ -413,7 +493,13 @@ func handleCompleteRequest(msg kernel.Message, goExec *goexec.State) (err error)
 		return
 	}
 	if usedLines.Has(cursorLine) {
-		// No auto-complete for special commands.
+		// Special and shell commands are completed by specialcmd.
+		line := lines[cursorLine]
+		matches, start := specialcmd.Complete(goExec, line, cursorCol)
+		if len(matches) > 0 {
+			reply.Matches = matches
+			reply.CursorStart -= len(utf16.Encode([]rune(line[start:cursorCol])))
+		}
 		return
 	}
 
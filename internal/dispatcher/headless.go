@@ -0,0 +1,218 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+)
+
+// This file implements the execution of notebooks without Jupyter (`gonb run`), e.g. for scheduled or CI runs:
+// each code cell is executed as if it was an "execute_request" from Jupyter, with a kernel created
+// with kernel.NewHeadless, and the outputs are saved in the notebook.
+
+// ExecuteHeadless executes the code of a cell, handled as an "execute_request" from Jupyter, in a kernel
+// created with kernel.NewHeadless. It returns the message with the outputs collected, and the error
+// reported by the cell, if it failed. metadata is the metadata of the cell, with its tags (e.g.
+// RaisesExceptionCellTag) and "nbgrader" metadata, if any.
+//
+// onOutput, if not nil, is called with each output as it is published.
+func ExecuteHeadless(k *kernel.Kernel, goExec *goexec.State, code string, metadata map[string]any,
+	onOutput func(output map[string]any)) (msg *kernel.HeadlessMessage, cellErr error, err error) {
+	msg, err = kernel.NewHeadlessMessage(k, "execute_request", map[string]any{
+		"code":          code,
+		"silent":        false,
+		"store_history": true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	msg.SetMetadata(metadata)
+	msg.OnOutput = onOutput
+	if err = handleExecuteRequest(msg, goExec); err != nil {
+		return msg, nil, err
+	}
+	if reply := msg.ReplyContent(); reply["status"] == "error" {
+		cellErr = errors.Errorf("%s: %s", reply["ename"], reply["evalue"])
+	}
+	return msg, cellErr, nil
+}
+
+// Notebook is a Jupyter notebook (nbformat), as read from a `.ipynb` file. Fields not used by GoNB
+// are preserved when it is saved.
+type Notebook map[string]any
+
+// ReadNotebook reads a Jupyter notebook (`.ipynb` file).
+func ReadNotebook(notebookPath string) (Notebook, error) {
+	contents, err := os.ReadFile(notebookPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read notebook %q", notebookPath)
+	}
+	var nb Notebook
+	if err = json.Unmarshal(contents, &nb); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse notebook %q", notebookPath)
+	}
+	if _, ok := nb["cells"].([]any); !ok {
+		return nil, errors.Errorf("notebook %q has no cells", notebookPath)
+	}
+	return nb, nil
+}
+
+// Write the notebook to the given path.
+func (nb Notebook) Write(notebookPath string) error {
+	contents, err := json.MarshalIndent(nb, "", " ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode notebook")
+	}
+	return errors.Wrapf(os.WriteFile(notebookPath, append(contents, '\n'), 0644), "failed to write notebook %q", notebookPath)
+}
+
+// cells returns the cells of the notebook.
+func (nb Notebook) cells() []map[string]any {
+	var cells []map[string]any
+	for _, cell := range nb["cells"].([]any) {
+		if cellMap, ok := cell.(map[string]any); ok {
+			cells = append(cells, cellMap)
+		}
+	}
+	return cells
+}
+
+// cellSource returns the source of a cell, which is either a string or a list of lines.
+func cellSource(cell map[string]any) string {
+	switch source := cell["source"].(type) {
+	case string:
+		return source
+	case []any:
+		var sb strings.Builder
+		for _, line := range source {
+			if lineStr, ok := line.(string); ok {
+				sb.WriteString(lineStr)
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// cellHasTag returns whether the cell is tagged (in its metadata) with the given tag.
+func cellHasTag(cell map[string]any, tag string) bool {
+	metadata, _ := cell["metadata"].(map[string]any)
+	tags, _ := metadata["tags"].([]any)
+	return slices.Contains(tags, any(tag))
+}
+
+// injectParameters inserts (or replaces) the cell tagged goexec.InjectedParametersCellTag after the cell
+// tagged goexec.ParametersCellTag, with the declarations of the parameter values.
+func (nb Notebook) injectParameters(values map[string]string) error {
+	cells := nb.cells()
+	for ii, cell := range cells {
+		if !cellHasTag(cell, goexec.ParametersCellTag) || cell["cell_type"] != "code" {
+			continue
+		}
+		code, err := goexec.InjectedParametersCode(strings.Split(cellSource(cell), "\n"), values)
+		if err != nil {
+			return err
+		}
+		injected := map[string]any{
+			"cell_type":       "code",
+			"execution_count": nil,
+			"metadata":        map[string]any{"tags": []any{goexec.InjectedParametersCellTag}},
+			"outputs":         []any{},
+			"source":          code,
+		}
+		var newCells []any
+		for jj, other := range cells {
+			if jj > ii && cellHasTag(other, goexec.InjectedParametersCellTag) {
+				continue // Replaced by the new one.
+			}
+			newCells = append(newCells, other)
+			if jj == ii {
+				newCells = append(newCells, injected)
+			}
+		}
+		nb["cells"] = newCells
+		return nil
+	}
+	return errors.Errorf("notebook has no code cell tagged %q, to declare the parameters", goexec.ParametersCellTag)
+}
+
+// setCellParams sets the values of the parameters declared in the code cells with `// gonb:param`, and
+// returns the remaining values.
+func (nb Notebook) setCellParams(goExec *goexec.State, values map[string]string) (map[string]string, error) {
+	declared := MakeSet[string]()
+	for _, cell := range nb.cells() {
+		if cell["cell_type"] == "code" {
+			for name := range goexec.ParamNames(cellSource(cell)) {
+				declared.Insert(name)
+			}
+		}
+	}
+	remaining := make(map[string]string)
+	for name, value := range values {
+		if !declared.Has(name) {
+			remaining[name] = value
+			continue
+		}
+		if err := goExec.SetParam(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return remaining, nil
+}
+
+// RunNotebook executes the code cells of the notebook in order, in a kernel created with
+// kernel.NewHeadless, and replaces their outputs and execution counts. If values of parameters are given,
+// those declared in the cells with `// gonb:param` are set with goexec.State.SetParam, and the others are
+// injected after the cell tagged "parameters" (see goexec.InjectedParametersCode).
+//
+// Cells tagged SkipCellTag (or SkipExecutionCellTag) are not executed, and their outputs are kept.
+// Execution stops at the first cell that fails, whose error is returned, after the outputs were updated --
+// except for cells tagged RaisesExceptionCellTag, which are expected to fail.
+// onOutput, if not nil, is called with the index of the cell and each output as it is published.
+func RunNotebook(k *kernel.Kernel, goExec *goexec.State, nb Notebook, values map[string]string,
+	onOutput func(cellIdx int, output map[string]any)) error {
+	if len(values) > 0 {
+		values, err := nb.setCellParams(goExec, values)
+		if err != nil {
+			return err
+		}
+		if len(values) > 0 {
+			if err = nb.injectParameters(values); err != nil {
+				return err
+			}
+		}
+	}
+	for ii, cell := range nb.cells() {
+		if cell["cell_type"] != "code" || cellHasTag(cell, SkipCellTag) || cellHasTag(cell, SkipExecutionCellTag) {
+			continue
+		}
+		cellMetadata, _ := cell["metadata"].(map[string]any)
+		var cellOutput func(output map[string]any)
+		if onOutput != nil {
+			cellOutput = func(output map[string]any) { onOutput(ii, output) }
+		}
+		msg, cellErr, err := ExecuteHeadless(k, goExec, cellSource(cell), cellMetadata, cellOutput)
+		if err != nil {
+			return errors.WithMessagef(err, "executing cell #%d", ii)
+		}
+		outputs := make([]any, 0, len(msg.Outputs()))
+		for _, output := range msg.Outputs() {
+			outputs = append(outputs, output)
+		}
+		cell["outputs"] = outputs
+		cell["execution_count"] = k.ExecCounter
+		if cellErr != nil {
+			return errors.WithMessagef(cellErr, "cell #%d failed", ii)
+		}
+		if k.Interrupted.Load() {
+			return errors.Errorf("interrupted while executing cell #%d", ii)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package dispatcher
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the "is_complete_request", sent by console front-ends (jupyter-console,
+// emacs-jupyter) to decide whether to execute the code entered, or to continue reading lines.
+
+// Statuses of the "is_complete_reply".
+const (
+	codeComplete   = "complete"
+	codeIncomplete = "incomplete"
+	codeInvalid    = "invalid"
+)
+
+// handleIsCompleteRequest replies whether the code is complete. Since only consoles send it, the session is
+// also marked as a console front-end, see kernel.FrontendOf.
+func handleIsCompleteRequest(msg kernel.Message) error {
+	msg.Kernel().MarkConsoleSession(msg)
+	content, _ := msg.ComposedMsg().Content.(map[string]any)
+	code, _ := content["code"].(string)
+	status, indent := isCodeComplete(code)
+	reply := map[string]any{"status": status}
+	if status == codeIncomplete {
+		reply["indent"] = indent
+	}
+	if err := msg.Reply("is_complete_reply", reply); err != nil {
+		return errors.WithMessagef(err, "replying to 'is_complete_request'")
+	}
+	return nil
+}
+
+// isCodeComplete returns whether the code is complete, incomplete (and in this case the indentation of the
+// next line) or invalid:
+//
+//   - Lines ending in `\` (special and shell commands) continue in the next line.
+//   - `%%` (or `%main`) as the last line, and commands that take the rest of the cell (`%%c`, `%%gopkg`,
+//     `%%protoc`, `%%http`, `%%prelude`, `%%writefile`) until an empty line, are incomplete.
+//   - Go code is incomplete if it has unclosed brackets, raw strings or comments, or if it ends in an operator.
+func isCodeComplete(code string) (status, indent string) {
+	lines := strings.Split(code, "\n")
+	lastLine := lines[len(lines)-1]
+	if strings.HasSuffix(lastLine, "\\") {
+		return codeIncomplete, ""
+	}
+	trimmedLast := strings.TrimSpace(lastLine)
+	if trimmedLast == "%%" || trimmedLast == "%main" {
+		return codeIncomplete, "\t"
+	}
+	first := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(first, "%%c ") || strings.HasPrefix(first, "%%gopkg ") ||
+		strings.HasPrefix(first, "%%protoc ") || first == "%%http" || first == "%%prelude" ||
+		strings.HasPrefix(first, "%%writefile") || strings.HasPrefix(first, "%writefile") {
+		if len(lines) > 1 && trimmedLast == "" {
+			return codeComplete, ""
+		}
+		return codeIncomplete, ""
+	}
+
+	// Go code, without the special commands and shell lines.
+	var goCode strings.Builder
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "!") {
+			goCode.WriteString(line)
+		}
+		goCode.WriteString("\n")
+	}
+	src := []byte(goCode.String())
+	fset := token.NewFileSet()
+	var unterminated, invalid bool
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, func(_ token.Position, msg string) {
+		if strings.HasSuffix(msg, "not terminated") {
+			unterminated = true
+		} else {
+			invalid = true
+		}
+	}, scanner.ScanComments)
+	depth := 0
+	lastTok := token.ILLEGAL
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch tok {
+		case token.LPAREN, token.LBRACE, token.LBRACK:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACK:
+			depth--
+			if depth < 0 {
+				return codeInvalid, ""
+			}
+		}
+		if tok == token.COMMENT || (tok == token.SEMICOLON && lit == "\n") {
+			continue // Automatically inserted semicolons.
+		}
+		lastTok = tok
+	}
+	switch {
+	case unterminated:
+		return codeIncomplete, ""
+	case invalid:
+		return codeInvalid, ""
+	case depth > 0:
+		return codeIncomplete, strings.Repeat("\t", depth)
+	case lastTok.IsOperator() && lastTok != token.RPAREN && lastTok != token.RBRACE && lastTok != token.RBRACK &&
+		lastTok != token.INC && lastTok != token.DEC && lastTok != token.SEMICOLON:
+		return codeIncomplete, "\t"
+	}
+	return codeComplete, ""
+}
@@ -0,0 +1,34 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCodeComplete(t *testing.T) {
+	for _, tc := range []struct {
+		code, status, indent string
+	}{
+		{`fmt.Println("hello")`, codeComplete, ""},
+		{"func f(x int) {", codeIncomplete, "\t"},
+		{"m := map[string]int{\n\t\"a\": {", codeIncomplete, "\t\t"},
+		{"x := 1 +", codeIncomplete, "\t"},
+		{"s := `multi\nline", codeIncomplete, ""},
+		{"!ls \\", codeIncomplete, ""},
+		{"%%", codeIncomplete, "\t"},
+		{"%%writefile f.txt\nsome text", codeIncomplete, ""},
+		{"%%writefile f.txt\nsome text\n", codeComplete, ""},
+		{"%%http\nPOST https://example.com\n\n{\"a\": {", codeIncomplete, ""},
+		{"%%protoc pb/person.proto\nmessage Person {", codeIncomplete, ""},
+		{"%%protoc pb/person.proto\nmessage Person {\n", codeComplete, ""},
+		{"%%http\nPOST https://example.com\n\n{\"a\": {\n", codeComplete, ""},
+		{"%env A 1\n!echo $A", codeComplete, ""},
+		{"x++ // comment", codeComplete, ""},
+		{"}", codeInvalid, ""},
+	} {
+		status, indent := isCodeComplete(tc.code)
+		assert.Equalf(t, tc.status, status, "status of %q", tc.code)
+		assert.Equalf(t, tc.indent, indent, "indent of %q", tc.code)
+	}
+}
@@ -0,0 +1,84 @@
+package dispatcher
+
+import (
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+)
+
+// This file implements the replay of sessions recorded with `%record` (`gonb replay`): the outputs of each
+// cell are re-emitted with their original timing, and collected in a fresh notebook.
+
+// NewNotebook creates a notebook (nbformat 4) for the GoNB kernel with the given cells.
+func NewNotebook(cells []any) Notebook {
+	return Notebook{
+		"cells": cells,
+		"metadata": map[string]any{
+			"kernelspec": map[string]any{
+				"display_name": "Go (gonb)",
+				"language":     "go",
+				"name":         "gonb",
+			},
+			"language_info": map[string]any{
+				"name":            "go",
+				"file_extension":  ".go",
+				"mimetype":        kernel.LanguageMIMEType,
+				"pygments_lexer":  kernel.LanguagePygmentsLexer,
+				"codemirror_mode": kernel.LanguageCodeMirrorMode,
+			},
+		},
+		"nbformat":       4,
+		"nbformat_minor": 5,
+	}
+}
+
+// ReplaySession re-emits the outputs of the recorded executions, with their original timing scaled by
+// 1/speed (if speed is 0, there are no delays), and returns a notebook with the cells and their outputs.
+//
+// onCell, if not nil, is called with the index and source of each cell before its outputs are re-emitted,
+// and onOutput, if not nil, is called with each output.
+func ReplaySession(executions []*kernel.SessionExecution, speed float64,
+	onCell func(cellIdx int, source string), onOutput func(output map[string]any)) (Notebook, error) {
+	wait := func(ms int64) {
+		if speed > 0 && ms > 0 {
+			time.Sleep(time.Duration(float64(ms) * float64(time.Millisecond) / speed))
+		}
+	}
+	k := kernel.NewHeadless()
+	cells := make([]any, 0, len(executions))
+	for ii, execution := range executions {
+		if onCell != nil {
+			onCell(ii, execution.Source)
+		}
+		msg, err := kernel.NewHeadlessMessage(k, "execute_request", map[string]any{"code": execution.Source})
+		if err != nil {
+			return nil, err
+		}
+		msg.OnOutput = onOutput
+		var elapsed int64
+		for _, output := range execution.Outputs {
+			wait(output.Offset - elapsed)
+			elapsed = output.Offset
+			if output.MsgType == "execute_result" {
+				output.Content["execution_count"] = ii + 1
+			}
+			if err = msg.Publish(output.MsgType, output.Content); err != nil {
+				return nil, err
+			}
+		}
+		wait(execution.Duration - elapsed)
+
+		outputs := make([]any, 0, len(msg.Outputs()))
+		for _, output := range msg.Outputs() {
+			outputs = append(outputs, output)
+		}
+		cells = append(cells, map[string]any{
+			"cell_type":       "code",
+			"execution_count": ii + 1,
+			"metadata":        map[string]any{},
+			"outputs":         outputs,
+			"source":          execution.Source,
+		})
+	}
+	return NewNotebook(cells), nil
+}
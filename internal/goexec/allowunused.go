@@ -0,0 +1,121 @@
+package goexec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// This file implements `%config allow_unused=on`: the local variables declared in the `func main()` created
+// for the cell (see State.mainPreamble) are marked as used (`_ = x`), to avoid the "declared and not used"
+// error, the most common one in exploratory code.
+
+// unusedFix is the code inserted at an offset of the `func main()` definition.
+type unusedFix struct {
+	offset int
+	code   string
+}
+
+// markedAsUsed returns the code that marks the identifiers as used, e.g.: `_ = x; _ = y`.
+func markedAsUsed(idents []*ast.Ident) string {
+	var parts []string
+	for _, ident := range idents {
+		if ident != nil && ident.Name != "_" {
+			parts = append(parts, "_ = "+ident.Name)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// definedIdents returns the local variables defined by the statement: by a `:=` assignment or a `var`
+// declaration.
+func definedIdents(stmt ast.Stmt) (idents []*ast.Ident) {
+	switch typedStmt := stmt.(type) {
+	case *ast.AssignStmt:
+		if typedStmt.Tok != token.DEFINE {
+			return
+		}
+		for _, expr := range typedStmt.Lhs {
+			if ident, ok := expr.(*ast.Ident); ok {
+				idents = append(idents, ident)
+			}
+		}
+	case *ast.DeclStmt:
+		genDecl, ok := typedStmt.Decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			return
+		}
+		for _, spec := range genDecl.Specs {
+			idents = append(idents, spec.(*ast.ValueSpec).Names...)
+		}
+	}
+	return
+}
+
+// allowUnusedLocals returns the definition of `func main()` with each local variable marked as used right
+// after it's defined, on the same line -- so the mapping of lines to the cell is preserved. It handles the
+// variables defined by statements, by `for ... range` and by type switches. The definition is returned
+// unchanged if it doesn't parse.
+func allowUnusedLocals(definition string) string {
+	const header = "package main\n"
+	fileSet := token.NewFileSet()
+	fileObj, err := parser.ParseFile(fileSet, "", header+definition, parser.SkipObjectResolution)
+	if err != nil {
+		return definition
+	}
+	offset := func(pos token.Pos) int { return fileSet.Position(pos).Offset - len(header) }
+
+	var fixes []unusedFix
+	markStatements := func(list []ast.Stmt) {
+		for _, stmt := range list {
+			if code := markedAsUsed(definedIdents(stmt)); code != "" {
+				fixes = append(fixes, unusedFix{offset(stmt.End()), "; " + code})
+			}
+		}
+	}
+	ast.Inspect(fileObj, func(node ast.Node) bool {
+		switch typedNode := node.(type) {
+		case *ast.BlockStmt:
+			markStatements(typedNode.List)
+		case *ast.CaseClause:
+			markStatements(typedNode.Body)
+		case *ast.CommClause:
+			markStatements(typedNode.Body)
+		case *ast.RangeStmt:
+			if typedNode.Tok != token.DEFINE {
+				break
+			}
+			key, _ := typedNode.Key.(*ast.Ident)
+			value, _ := typedNode.Value.(*ast.Ident)
+			if code := markedAsUsed([]*ast.Ident{key, value}); code != "" {
+				fixes = append(fixes, unusedFix{offset(typedNode.Body.Lbrace) + 1, " " + code + ";"})
+			}
+		case *ast.TypeSwitchStmt:
+			assign, ok := typedNode.Assign.(*ast.AssignStmt)
+			if !ok || len(assign.Lhs) != 1 {
+				break
+			}
+			code := markedAsUsed(definedIdents(assign))
+			if code == "" {
+				break
+			}
+			// The variable is declared in each clause.
+			for _, clause := range typedNode.Body.List {
+				fixes = append(fixes, unusedFix{offset(clause.(*ast.CaseClause).Colon) + 1, " " + code + ";"})
+			}
+		}
+		return true
+	})
+	if len(fixes) == 0 {
+		return definition
+	}
+
+	// Insert from the end, so the offsets remain valid.
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].offset > fixes[j].offset })
+	for _, fix := range fixes {
+		definition = definition[:fix.offset] + fix.code + definition[fix.offset:]
+	}
+	return definition
+}
@@ -0,0 +1,72 @@
+package goexec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// typeCheck type checks the `func main()` definition, without imports.
+func typeCheck(t *testing.T, definition string) error {
+	fileSet := token.NewFileSet()
+	fileObj, err := parser.ParseFile(fileSet, "main.go", "package main\n"+definition, 0)
+	require.NoError(t, err)
+	_, err = (&types.Config{}).Check("main", fileSet, []*ast.File{fileObj}, nil)
+	return err
+}
+
+func TestAllowUnusedLocals(t *testing.T) {
+	definition := `func main() {
+	x := 1
+	var y, z int
+	a, _ := 2, 3
+	for i, v := range []int{1, 2} {
+		w := i
+	}
+	var any interface{} = x
+	switch s := any.(type) {
+	case int:
+	default:
+	}
+	go func() {
+		inner := "unused"
+	}()
+}`
+	require.Error(t, typeCheck(t, definition))
+	fixed := allowUnusedLocals(definition)
+	require.NoError(t, typeCheck(t, fixed), "Fixed code:\n%s", fixed)
+	assert.Equal(t, strings.Count(definition, "\n"), strings.Count(fixed, "\n"), "Lines must be preserved")
+	assert.Contains(t, fixed, "x := 1; _ = x\n")
+	assert.Contains(t, fixed, "var y, z int; _ = y; _ = z\n")
+	assert.Contains(t, fixed, "a, _ := 2, 3; _ = a\n")
+
+	// Invalid code is left unchanged.
+	assert.Equal(t, "func main() { x := }", allowUnusedLocals("func main() { x := }"))
+}
+
+func TestAllowUnusedMain(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	s.AllowUnused = true
+
+	// Only the `func main()` created for `%%` is changed.
+	lines := []string{"%%", "x := 1"}
+	_, mainDecl, _, _, err := s.parseLinesAndComposeMain(nil, 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	assert.Contains(t, mainDecl.Definition, "x := 1; _ = x")
+
+	lines = []string{"func main() {", "\tx := 1", "}"}
+	_, mainDecl, _, _, err = s.parseLinesAndComposeMain(nil, 2, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	assert.NotContains(t, mainDecl.Definition, "_ = x")
+}
@@ -0,0 +1,176 @@
+package goexec
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the inspection of the code generated by the compiler for functions defined in
+// the notebook: assembly (`%asm`) and SSA (`%ssa`).
+
+// SSAFileName is the name of the file where the compiler dumps the SSA, when GOSSAFUNC is set.
+const SSAFileName = "ssa.html"
+
+// Assembly displays the assembly generated by the compiler (`go build -gcflags=-S`) for the function
+// funcName, defined in the memorized declarations. Methods are given as `Type.Method`.
+func (s *State) Assembly(msg kernel.Message, funcName string) error {
+	if err := s.checkFunctionDefined(funcName); err != nil {
+		return err
+	}
+	output, fileToCellIdAndLine, err := s.buildMemorizedDeclarations(msg, nil, "-gcflags=-S")
+	if err != nil {
+		return err
+	}
+	asm := filterAssembly(output, funcName)
+	if asm == "" {
+		return errors.Errorf("%%asm: no assembly generated for %q -- maybe it was inlined or is never used", funcName)
+	}
+	asm = mapCodeReferences(asm, fileToCellIdAndLine)
+	return kernel.PublishHtml(msg, fmt.Sprintf("<h4>Assembly of <code>%s</code></h4>\n<pre>%s</pre>\n",
+		html.EscapeString(funcName), html.EscapeString(asm)))
+}
+
+// SSA displays the SSA (Static Single Assignment) form of the function funcName, as dumped by the
+// compiler with GOSSAFUNC, inlined in the notebook. Methods are given as `Type.Method`.
+func (s *State) SSA(msg kernel.Message, funcName string) error {
+	if err := s.checkFunctionDefined(funcName); err != nil {
+		return err
+	}
+	ssaPath := path.Join(s.TempDir, SSAFileName)
+	_ = os.Remove(ssaPath)
+	for _, ssaFunc := range ssaFuncNames(funcName) {
+		_, _, err := s.buildMemorizedDeclarations(msg, []string{"GOSSAFUNC=" + ssaFunc})
+		if err != nil {
+			return err
+		}
+		if _, err = os.Stat(ssaPath); err == nil {
+			break
+		}
+	}
+	contents, err := os.ReadFile(ssaPath)
+	if err != nil {
+		return errors.Errorf("%%ssa: no SSA generated for %q -- maybe it was inlined or is never used", funcName)
+	}
+	return kernel.PublishHtml(msg, fmt.Sprintf(
+		"<iframe srcdoc=\"%s\" style=\"width: 100%%; height: 600px; border: none\"></iframe>\n",
+		html.EscapeString(string(contents))))
+}
+
+// checkFunctionDefined returns an error if funcName, in the form `Func` or `Type.Method`, is not a
+// function defined in the memorized declarations.
+func (s *State) checkFunctionDefined(funcName string) error {
+	key := strings.Replace(funcName, ".", "~", 1)
+	if _, found := s.Definitions.Functions[key]; !found {
+		return errors.Errorf("function %q not found in memorized definitions -- see `%%ls`", funcName)
+	}
+	return nil
+}
+
+// buildMemorizedDeclarations composes `main.go` with the memorized declarations and builds it with the
+// extra environment variables and `go build` flags. It returns the output of the compilation.
+//
+// Compilation errors are displayed in the notebook.
+func (s *State) buildMemorizedDeclarations(msg kernel.Message, env []string, flags ...string) (
+	output string, fileToCellIdAndLine []CellIdAndLine, err error) {
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	fileToCellIdAndLine, err = s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+	args := append([]string{"build", "-o", os.DevNull}, flags...)
+	args = append(args, s.GoBuildFlags...)
+	cmd := s.goCommand(args...)
+	cmd.Env = append(cmd.Environ(), env...)
+	klog.V(2).Infof("Executing %s", cmd)
+	var outputBytes []byte
+	outputBytes, err = cmd.CombinedOutput()
+	output = string(outputBytes)
+	if err != nil {
+		klog.Errorf("Failed %q:\n%s\n", cmd, output)
+		err = s.DisplayErrorWithContext(msg, fileToCellIdAndLine, output, err)
+		s.publishModuleFetchDiagnosis(msg, output)
+		err = errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	return
+}
+
+// compiledSymbolNames returns the possible names of the symbol generated by the compiler for funcName,
+// in the form `Func` or `Type.Method`.
+func compiledSymbolNames(funcName string) []string {
+	typeName, methodName, isMethod := strings.Cut(funcName, ".")
+	if !isMethod {
+		return []string{"main." + funcName}
+	}
+	return []string{"main." + funcName, fmt.Sprintf("main.(*%s).%s", typeName, methodName)}
+}
+
+// ssaFuncNames returns the possible values of GOSSAFUNC for funcName, in the form `Func` or `Type.Method`.
+func ssaFuncNames(funcName string) []string {
+	typeName, methodName, isMethod := strings.Cut(funcName, ".")
+	if !isMethod {
+		return []string{funcName}
+	}
+	return []string{funcName, fmt.Sprintf("(*%s).%s", typeName, methodName)}
+}
+
+// filterAssembly returns the assembly of funcName (and of its closures) from the output of
+// `go build -gcflags=-S`. Each function starts with a line with its symbol name, followed by indented
+// lines with the instructions.
+func filterAssembly(output, funcName string) string {
+	var sb strings.Builder
+	symbols := compiledSymbolNames(funcName)
+	inFunc := false
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || line[0] == '\t' || line[0] == ' ' {
+			if inFunc {
+				sb.WriteString(line + "\n")
+			}
+			continue
+		}
+		symbol, _, _ := strings.Cut(line, " ")
+		inFunc = false
+		for _, name := range symbols {
+			// Also matches generic instances (`main.f[...]`) and closures (`main.f.func1`).
+			if symbol == name || strings.HasPrefix(symbol, name+"[") || strings.HasPrefix(symbol, name+".func") {
+				inFunc = true
+				break
+			}
+		}
+		if inFunc {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+var reCodeReference = regexp.MustCompile(`[^\s()]*main(_test)?\.go:(\d+)`)
+
+// mapCodeReferences replaces references to lines of `main.go` (e.g.: `/tmp/gonb_1234/main.go:12`) in
+// the output of the compiler by references to the corresponding cell lines.
+func mapCodeReferences(text string, fileToCellIdAndLine []CellIdAndLine) string {
+	return reCodeReference.ReplaceAllStringFunc(text, func(ref string) string {
+		parts := reCodeReference.FindStringSubmatch(ref)
+		lineNum, err := strconv.Atoi(parts[2])
+		if err != nil || lineNum < 1 || lineNum > len(fileToCellIdAndLine) {
+			return ref
+		}
+		cellIdAndLine := fileToCellIdAndLine[lineNum-1]
+		if cellIdAndLine.Line == NoCursorLine {
+			return ref
+		}
+		return fmt.Sprintf("Cell[%d]: Line %d", cellIdAndLine.Id, cellIdAndLine.Line+1)
+	})
+}
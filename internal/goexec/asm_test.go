@@ -0,0 +1,38 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterAssembly(t *testing.T) {
+	output := `# gonb_1234
+main.f.func1 STEXT size=0 align=0x0 args=0x0 locals=0x0 funcid=0x0
+	0x0000 00000 (/tmp/gonb_1234/main.go:10)	TEXT	main.f.func1(SB), ABIInternal, $0-0
+main.(*T).M STEXT nosplit size=7 align=0x0 args=0x8 locals=0x0 funcid=0x0
+	0x0000 00000 (/tmp/gonb_1234/main.go:6)	TEXT	main.(*T).M(SB), NOSPLIT|ABIInternal, $0-8
+main.f STEXT nosplit size=9 align=0x0 args=0x8 locals=0x0 funcid=0x0
+	0x0000 00000 (/tmp/gonb_1234/main.go:9)	TEXT	main.f(SB), NOSPLIT|ABIInternal, $0-8
+main.main STEXT size=114 align=0x0 args=0x0 locals=0x28 funcid=0x0
+	0x0000 00000 (/tmp/gonb_1234/main.go:14)	TEXT	main.main(SB), ABIInternal, $48-0
+`
+	asm := filterAssembly(output, "f")
+	assert.Contains(t, asm, "main.f.func1(SB)")
+	assert.Contains(t, asm, "main.f(SB)")
+	assert.NotContains(t, asm, "main.main")
+	assert.NotContains(t, asm, "main.(*T).M")
+
+	asm = filterAssembly(output, "T.M")
+	assert.Contains(t, asm, "main.(*T).M(SB)")
+	assert.NotContains(t, asm, "main.f")
+
+	fileToCellIdAndLine := make([]CellIdAndLine, 14)
+	for ii := range fileToCellIdAndLine {
+		fileToCellIdAndLine[ii] = CellIdAndLine{Id: 3, Line: ii - 2}
+	}
+	fileToCellIdAndLine[13].Line = NoCursorLine
+	assert.Equal(t, "0x0000 00000 (Cell[3]: Line 7)\tTEXT\tmain.f(SB)",
+		mapCodeReferences("0x0000 00000 (/tmp/gonb_1234/main.go:9)\tTEXT\tmain.f(SB)", fileToCellIdAndLine))
+	assert.Equal(t, "(./main.go:14)", mapCodeReferences("(./main.go:14)", fileToCellIdAndLine))
+}
@@ -0,0 +1,84 @@
+package goexec
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the automatic `go get` of packages reported missing by `go build`, and
+// the configuration of which packages can be automatically fetched (see `%autoget allow` and `%autoget deny`).
+
+var reMissingPackage = regexp.MustCompile(`no required module provides package ([^\s;:]+)`)
+
+// missingPackages returns the packages reported missing ("no required module provides package X")
+// in the output of `go build`.
+func missingPackages(output string) []string {
+	packages := MakeSet[string]()
+	for _, match := range reMissingPackage.FindAllStringSubmatch(output, -1) {
+		packages.Insert(match[1])
+	}
+	return SortedKeys(packages)
+}
+
+// matchPackagePattern returns whether the package matches the pattern. Patterns ending in `/...` match
+// the package path and all its sub-packages, other patterns are matched with path.Match (e.g.: `github.com/*/foo`).
+func matchPackagePattern(pattern, pkg string) bool {
+	if prefix, found := strings.CutSuffix(pattern, "/..."); found {
+		return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+	}
+	matched, err := path.Match(pattern, pkg)
+	return err == nil && matched
+}
+
+// AutoGetAllowed returns whether the package can be automatically fetched with `go get`, according
+// to State.AutoGetAllow and State.AutoGetDeny. The deny list takes precedence.
+func (s *State) AutoGetAllowed(pkg string) bool {
+	for _, pattern := range s.AutoGetDeny {
+		if matchPackagePattern(pattern, pkg) {
+			return false
+		}
+	}
+	if len(s.AutoGetAllow) == 0 {
+		return true
+	}
+	for _, pattern := range s.AutoGetAllow {
+		if matchPackagePattern(pattern, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// autoGetMissingPackages runs `go get` for the packages reported missing in the output of a failed
+// build, if allowed. It returns whether any package was fetched, in which case the build should be retried.
+func (s *State) autoGetMissingPackages(msg kernel.Message, buildOutput string) (fetched bool) {
+	if !s.AutoGet {
+		return false
+	}
+	for _, pkg := range missingPackages(buildOutput) {
+		if !s.AutoGetAllowed(pkg) {
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+				fmt.Sprintf("* Package %q is missing, but not fetched: it's not allowed by `%%autoget allow/deny`.\n", pkg))
+			continue
+		}
+		cmd := s.goCommand("get", pkg)
+		klog.V(2).Infof("Executing %s", cmd)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			klog.Warningf("Failed %q:\n%s\n", cmd, output)
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+				fmt.Sprintf("* Failed to fetch missing package %q:\n%s\n", pkg, output))
+			continue
+		}
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("* Added dependency for missing package %q.\n", pkg))
+		fetched = true
+	}
+	return
+}
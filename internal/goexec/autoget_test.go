@@ -0,0 +1,29 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoGetAllowed(t *testing.T) {
+	output := `main.go:4:2: no required module provides package github.com/foo/bar; to add it:
+	go get github.com/foo/bar
+main.go:5:2: no required module provides package example.com/x/y/z; to add it:
+	go get example.com/x/y/z
+`
+	assert.Equal(t, []string{"example.com/x/y/z", "github.com/foo/bar"}, missingPackages(output))
+	assert.Empty(t, missingPackages("main.go:3:2: undefined: x"))
+
+	s := &State{}
+	assert.True(t, s.AutoGetAllowed("github.com/foo/bar"))
+	s.AutoGetAllow = []string{"github.com/foo/...", "example.com/*/y"}
+	assert.True(t, s.AutoGetAllowed("github.com/foo"))
+	assert.True(t, s.AutoGetAllowed("github.com/foo/bar/baz"))
+	assert.False(t, s.AutoGetAllowed("github.com/foobar"))
+	assert.True(t, s.AutoGetAllowed("example.com/x/y"))
+	assert.False(t, s.AutoGetAllowed("example.com/x/y/z"))
+	s.AutoGetDeny = []string{"github.com/foo/bar/..."}
+	assert.False(t, s.AutoGetAllowed("github.com/foo/bar/baz"))
+	assert.True(t, s.AutoGetAllowed("github.com/foo/qux"))
+}
@@ -0,0 +1,163 @@
+package goexec
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%bugreport`: it zips the information needed to reproduce and diagnose a problem --
+// the composed code, `go.mod`, the kernel log, the environment and the last error -- into a file that can be
+// attached to an issue.
+
+// BugReportFilePrefix is the prefix of the name of the archives created by `%bugreport`, in the current
+// directory (the one of the notebook).
+const BugReportFilePrefix = "gonb_bugreport_"
+
+// cellError is the last error of a cell execution, see State.recordCellError.
+type cellError struct {
+	cellId int
+	time   time.Time
+	err    error
+}
+
+// recordCellError records the error of the execution of the cell, to be included in `%bugreport`.
+func (s *State) recordCellError(cellId int, err error) {
+	if err != nil {
+		s.lastCellError = &cellError{cellId: cellId, time: time.Now(), err: err}
+	}
+}
+
+// bugReportEnvironment returns the description of the environment of the kernel.
+func (s *State) bugReportEnvironment() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "GoNB built with: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&sb, "Directory: %s\n", s.TempDir)
+	fmt.Fprintf(&sb, "Memorized declarations: %d functions, %d variables, %d types, %d constants, %d imports\n",
+		len(s.Definitions.Functions), len(s.Definitions.Variables), len(s.Definitions.Types),
+		len(s.Definitions.Constants), len(s.Definitions.Imports))
+	if env := s.GoEnv(); len(env) > 0 {
+		fmt.Fprintf(&sb, "GoNB environment for the go tool: %s\n", strings.Join(env, " "))
+	}
+	for _, args := range [][]string{{"version"}, {"env"}} {
+		output, err := s.goCommand(args...).CombinedOutput()
+		fmt.Fprintf(&sb, "\n=== go %s\n", strings.Join(args, " "))
+		if err != nil {
+			fmt.Fprintf(&sb, "failed: %v\n", err)
+		}
+		sb.Write(output)
+	}
+	return sb.String()
+}
+
+// WriteBugReport writes to zipPath the archive of `%bugreport`, with the given kernel log. If redact is set, the
+// code (the composed code, the diff of `%why` and the toolchain diagnostics, which include it) is left out.
+// It returns the names of the files in the archive.
+func (s *State) WriteBugReport(zipPath, kernelLog string, redact bool) (names []string, err error) {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %q", zipPath)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = errors.Wrapf(closeErr, "failed to close %q", zipPath)
+		}
+	}()
+	zw := zip.NewWriter(f)
+	add := func(name, contents string) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add %q to %q", name, zipPath)
+		}
+		if _, err = w.Write([]byte(contents)); err != nil {
+			return errors.Wrapf(err, "failed to write %q in %q", name, zipPath)
+		}
+		names = append(names, name)
+		return nil
+	}
+	addFile := func(name, path string) error {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				klog.Warningf("%%bugreport: failed to read %q: %+v", path, err)
+			}
+			return nil
+		}
+		return add(name, string(contents))
+	}
+
+	if err = add("environment.txt", s.bugReportEnvironment()); err != nil {
+		return
+	}
+	for _, name := range []string{"go.mod", "go.work"} {
+		if err = addFile(name, filepath.Join(s.TempDir, name)); err != nil {
+			return
+		}
+	}
+	if kernelLog != "" {
+		if err = add("kernel.log", kernelLog); err != nil {
+			return
+		}
+	}
+	if s.lastCellError != nil {
+		lastError := fmt.Sprintf("Cell [%d], at %s:\n%+v\n", s.lastCellError.cellId,
+			s.lastCellError.time.Format(time.RFC3339), s.lastCellError.err)
+		if err = add("last_error.txt", lastError); err != nil {
+			return
+		}
+	}
+	if s.lastBuildFailure != nil && s.lastBuildFailure.output != "" {
+		if err = add("build_output.txt", s.lastBuildFailure.output); err != nil {
+			return
+		}
+	}
+	if !redact {
+		if s.lastComposed != nil {
+			if err = add(s.lastComposed.fileName, s.lastComposed.code); err != nil {
+				return
+			}
+		}
+		if s.lastBuildFailure != nil {
+			if err = add("why.md", s.WhyMarkdown()); err != nil {
+				return
+			}
+		}
+		if err = addFile(ToolchainDiagnosticsFile, filepath.Join(s.TempDir, ToolchainDiagnosticsFile)); err != nil {
+			return
+		}
+	}
+	if err = zw.Close(); err != nil {
+		err = errors.Wrapf(err, "failed to finish %q", zipPath)
+	}
+	return
+}
+
+// BugReport implements `%bugreport [--redact]`: it writes the archive with the diagnostics (see
+// WriteBugReport) to the current directory, and displays its path and contents.
+func (s *State) BugReport(msg kernel.Message, kernelLog string, redact bool) error {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get current directory")
+	}
+	zipPath := filepath.Join(pwd, BugReportFilePrefix+time.Now().Format("20060102_150405")+".zip")
+	names, err := s.WriteBugReport(zipPath, kernelLog, redact)
+	if err != nil {
+		return err
+	}
+	note := ""
+	if redact {
+		note = " The code was left out (`--redact`)."
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+		"Bug report saved in %s, with %s.%s\nPlease review it before attaching it to an issue in "+
+			"https://github.com/janpfeifer/gonb/issues.\n", zipPath, strings.Join(names, ", "), note))
+}
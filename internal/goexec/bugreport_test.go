@@ -0,0 +1,55 @@
+package goexec
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readZip returns the contents of the files in the zip archive, by name.
+func readZip(t *testing.T, zipPath string) map[string]string {
+	r, err := zip.OpenReader(zipPath)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[f.Name] = string(contents)
+	}
+	return files
+}
+
+func TestWriteBugReport(t *testing.T) {
+	s := &State{TempDir: t.TempDir(), Definitions: NewDeclarations()}
+	require.NoError(t, os.WriteFile(filepath.Join(s.TempDir, "go.mod"), []byte("module gonb_test\n"), 0600))
+	s.lastComposed = &composedCode{fileName: "main.go", code: "package main\n\nfunc main() {}\n"}
+	s.recordCellError(3, errors.New("failed to run the cell"))
+
+	zipPath := filepath.Join(t.TempDir(), "report.zip")
+	names, err := s.WriteBugReport(zipPath, "I1015 kernel started\n", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environment.txt", "go.mod", "kernel.log", "last_error.txt", "main.go"}, names)
+	files := readZip(t, zipPath)
+	assert.Contains(t, files["environment.txt"], "GoNB built with:")
+	assert.Contains(t, files["environment.txt"], "=== go version")
+	assert.Equal(t, "module gonb_test\n", files["go.mod"])
+	assert.Equal(t, "I1015 kernel started\n", files["kernel.log"])
+	assert.Contains(t, files["last_error.txt"], "Cell [3], at ")
+	assert.Contains(t, files["last_error.txt"], "failed to run the cell")
+	assert.Equal(t, s.lastComposed.code, files["main.go"])
+
+	// With redact the code is left out.
+	names, err = s.WriteBugReport(zipPath, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"environment.txt", "go.mod", "last_error.txt"}, names)
+}
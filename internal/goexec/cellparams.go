@@ -0,0 +1,225 @@
+package goexec
+
+import (
+	"fmt"
+	"go/token"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements typed parameters declared in the cells, with comments like
+//
+//	// gonb:param name type = default
+//
+// The line is composed as `var name type = <value>`, where the value is the default, or the value set with
+// `%params name=value` or `gonb run --param name=value`, validated against the type. It allows notebooks to
+// be used as reusable "reports" with typed inputs.
+
+// reParamDeclaration matches a `// gonb:param name type [= default]` line, capturing the name, the type
+// and the default value, if given.
+var reParamDeclaration = regexp.MustCompile(`^\s*//\s*gonb:param\s+([\p{L}_][\p{L}\p{Nd}_]*)\s+([\w.]+)\s*(?:=\s*(.*?))?\s*$`)
+
+// paramBitSizes are the supported integer and floating point types of the parameters, with their bit sizes.
+var paramBitSizes = map[string]int{
+	"int": strconv.IntSize, "int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	"uint": strconv.IntSize, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64,
+	"float32": 32, "float64": 64,
+}
+
+// CellParam is a parameter declared in a cell with `// gonb:param name type = default`.
+type CellParam struct {
+	Name, Type, Default string
+}
+
+// parseParamDeclaration returns the parameter declared in the line, if it is a `// gonb:param` comment.
+func parseParamDeclaration(line string) (param CellParam, ok bool) {
+	matches := reParamDeclaration.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+	return CellParam{Name: matches[1], Type: matches[2], Default: matches[3]}, true
+}
+
+// ParamNames returns the names of the parameters declared (with `// gonb:param`) in the code.
+func ParamNames(code string) Set[string] {
+	names := MakeSet[string]()
+	for _, line := range strings.Split(code, "\n") {
+		if param, ok := parseParamDeclaration(line); ok {
+			names.Insert(param.Name)
+		}
+	}
+	return names
+}
+
+// paramLiteral returns the Go literal of the value of a parameter of the given type, or an error if the
+// value is not valid for the type. String values may be given quoted (as a Go string literal) or not.
+func paramLiteral(typeName, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case typeName == "string":
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		return strconv.Quote(value), nil
+	case typeName == "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.Errorf("invalid bool %q", value)
+		}
+		return strconv.FormatBool(b), nil
+	case typeName == "time.Duration":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return "", errors.Errorf("invalid time.Duration %q, use for instance \"1m30s\"", value)
+		}
+		return fmt.Sprintf("time.Duration(%d)", d), nil
+	case strings.HasPrefix(typeName, "float"):
+		if _, err := strconv.ParseFloat(value, paramBitSizes[typeName]); err != nil {
+			return "", errors.Errorf("invalid %s %q", typeName, value)
+		}
+		return value, nil
+	case strings.HasPrefix(typeName, "uint"):
+		if _, err := strconv.ParseUint(value, 0, paramBitSizes[typeName]); err != nil {
+			return "", errors.Errorf("invalid %s %q", typeName, value)
+		}
+		return value, nil
+	case strings.HasPrefix(typeName, "int"):
+		if _, err := strconv.ParseInt(value, 0, paramBitSizes[typeName]); err != nil {
+			return "", errors.Errorf("invalid %s %q", typeName, value)
+		}
+		return value, nil
+	}
+	return "", errors.Errorf("unsupported type %q, parameters can be a string, bool, time.Duration or a "+
+		"numeric type", typeName)
+}
+
+// validParamType returns whether the type is supported for parameters.
+func validParamType(typeName string) bool {
+	_, found := paramBitSizes[typeName]
+	return found || typeName == "string" || typeName == "bool" || typeName == "time.Duration"
+}
+
+// paramDeclaration returns the Go declaration of the parameter: with the value set with State.SetParam,
+// if any, or its default.
+func (s *State) paramDeclaration(param CellParam) (string, error) {
+	if !validParamType(param.Type) {
+		_, err := paramLiteral(param.Type, "")
+		return "", errors.WithMessagef(err, "parameter %q", param.Name)
+	}
+	value, found := s.ParamValues[param.Name]
+	if !found {
+		if param.Default == "" {
+			return fmt.Sprintf("var %s %s", param.Name, param.Type), nil
+		}
+		value = param.Default
+	}
+	literal, err := paramLiteral(param.Type, value)
+	if err != nil {
+		if found {
+			return "", errors.WithMessagef(err, "value of parameter %q", param.Name)
+		}
+		return "", errors.WithMessagef(err, "default of parameter %q", param.Name)
+	}
+	return fmt.Sprintf("var %s %s = %s", param.Name, param.Type, literal), nil
+}
+
+// expandParamDeclarations replaces the `// gonb:param` lines by the declarations of the parameters, in the
+// same line, so line numbers are preserved. The parameters are recorded, to be listed by `%params`.
+//
+// While completing or inspecting (cursorLine != NoCursorLine) the line of the cursor and invalid declarations
+// are left as comments, and the parameters are not recorded.
+func (s *State) expandParamDeclarations(lines []string, skipLines Set[int], cursorLine int) ([]string, error) {
+	var expanded []string
+	for ii, line := range lines {
+		if skipLines.Has(ii) || ii == cursorLine {
+			continue
+		}
+		param, ok := parseParamDeclaration(line)
+		if !ok {
+			continue
+		}
+		decl, err := s.paramDeclaration(param)
+		if err != nil {
+			if cursorLine != NoCursorLine {
+				continue
+			}
+			return nil, err
+		}
+		if expanded == nil {
+			expanded = append([]string(nil), lines...)
+		}
+		expanded[ii] = decl
+		if cursorLine == NoCursorLine {
+			if s.params == nil {
+				s.params = make(map[string]CellParam)
+			}
+			s.params[param.Name] = param
+		}
+	}
+	if expanded == nil {
+		return lines, nil
+	}
+	return expanded, nil
+}
+
+// SetParam sets the value of a parameter, used instead of its default when its `// gonb:param` declaration
+// is executed. If the parameter was already declared, the value is validated, and the memorized variable
+// updated.
+func (s *State) SetParam(name, value string) error {
+	if !token.IsIdentifier(name) {
+		return errors.Errorf("invalid parameter name %q", name)
+	}
+	if param, found := s.params[name]; found {
+		literal, err := paramLiteral(param.Type, value)
+		if err != nil {
+			return errors.WithMessagef(err, "value of parameter %q", name)
+		}
+		if v, found := s.Definitions.Variables[name]; found {
+			v.ValueDefinition = literal
+		}
+	}
+	if s.ParamValues == nil {
+		s.ParamValues = make(map[string]string)
+	}
+	s.ParamValues[name] = value
+	return nil
+}
+
+// ListParams implements `%params` without arguments: it lists the parameters declared, with their types,
+// defaults and values set.
+func (s *State) ListParams(msg kernel.Message) error {
+	if len(s.params) == 0 && len(s.ParamValues) == 0 {
+		return kernel.PublishMarkdown(msg, "No parameters declared: use `// gonb:param <name> <type> = <default>`.")
+	}
+	names := MakeSet[string]()
+	for name := range s.params {
+		names.Insert(name)
+	}
+	for name := range s.ParamValues {
+		names.Insert(name)
+	}
+	var sb strings.Builder
+	sb.WriteString("<table>\n<tr><th>Parameter</th><th>Type</th><th>Default</th><th>Value</th></tr>\n")
+	for _, name := range SortedKeys(names) {
+		param, declared := s.params[name]
+		paramType := html.EscapeString(param.Type)
+		if !declared {
+			paramType = "<i>not declared</i>"
+		}
+		value, found := s.ParamValues[name]
+		if !found {
+			value = param.Default
+		}
+		sb.WriteString(fmt.Sprintf("<tr><td><code>%s</code></td><td><code>%s</code></td><td style=\"text-align: left\">%s</td><td style=\"text-align: left\">%s</td></tr>\n",
+			html.EscapeString(name), paramType, html.EscapeString(param.Default), html.EscapeString(value)))
+	}
+	sb.WriteString("</table>")
+	return kernel.PublishHtml(msg, sb.String())
+}
@@ -0,0 +1,79 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamLiteral(t *testing.T) {
+	for _, tc := range []struct{ typeName, value, want string }{
+		{"string", "hello world", `"hello world"`},
+		{"string", `"quoted"`, `"quoted"`},
+		{"bool", "true", "true"},
+		{"int", "42", "42"},
+		{"int8", "-0x10", "-0x10"},
+		{"uint16", "65535", "65535"},
+		{"float64", "1e-3", "1e-3"},
+		{"time.Duration", "1m30s", "time.Duration(90000000000)"},
+	} {
+		got, err := paramLiteral(tc.typeName, tc.value)
+		require.NoErrorf(t, err, "type %s, value %q", tc.typeName, tc.value)
+		assert.Equal(t, tc.want, got)
+	}
+	for _, tc := range []struct{ typeName, value string }{
+		{"bool", "maybe"}, {"int", "1.5"}, {"int8", "300"}, {"uint", "-1"}, {"float32", "x"},
+		{"time.Duration", "10"}, {"[]int", "{1}"},
+	} {
+		_, err := paramLiteral(tc.typeName, tc.value)
+		assert.Errorf(t, err, "type %s, value %q", tc.typeName, tc.value)
+	}
+}
+
+func TestExpandParamDeclarations(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	lines := []string{
+		"%env FOO=bar",
+		"// gonb:param Name string = world",
+		"  //gonb:param N int=3",
+		"// gonb:param Verbose bool",
+		"// Not a parameter.",
+	}
+	skipLines := MakeSet[int]()
+	skipLines.Insert(0)
+	require.NoError(t, s.SetParam("N", "7"))
+	expanded, err := s.expandParamDeclarations(lines, skipLines, NoCursorLine)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"%env FOO=bar",
+		`var Name string = "world"`,
+		"var N int = 7",
+		"var Verbose bool",
+		"// Not a parameter.",
+	}, expanded)
+	assert.Equal(t, CellParam{Name: "N", Type: "int", Default: "3"}, s.params["N"])
+	assert.Equal(t, MakeSet[string](), ParamNames("// gonb:param"))
+	assert.Len(t, ParamNames(strings.Join(lines, "\n")), 3)
+
+	// Values are validated once the parameter is declared, and update the memorized variable.
+	s.Definitions.Variables["N"] = &Variable{Key: "N", Name: "N", TypeDefinition: "int", ValueDefinition: "7"}
+	require.Error(t, s.SetParam("N", "seven"))
+	require.NoError(t, s.SetParam("N", "8"))
+	assert.Equal(t, "8", s.Definitions.Variables["N"].ValueDefinition)
+
+	// Invalid values are reported when executing, but ignored when completing.
+	s.ParamValues["Verbose"] = "maybe"
+	_, err = s.expandParamDeclarations(lines, skipLines, NoCursorLine)
+	require.ErrorContains(t, err, "value of parameter \"Verbose\"")
+	expanded, err = s.expandParamDeclarations(lines, skipLines, 2)
+	require.NoError(t, err)
+	assert.Equal(t, lines[2:4], expanded[2:4])
+}
@@ -0,0 +1,146 @@
+package goexec
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%compose show`: it displays the exact code composed for the last execution (the
+// `main.go` or `main_test.go` after `goimports`), with the line numbers reported by the compiler, and the
+// cell each line came from -- to debug confusing build errors. `%compose on` displays it on every execution.
+
+// composedCode is the code composed for the last execution of a cell.
+type composedCode struct {
+	fileName, code      string
+	fileToCellIdAndLine []CellIdAndLine
+}
+
+// recordComposed records the current contents of the composed code file, to be displayed by `%compose show`.
+func (s *State) recordComposed(fileToCellIdAndLine []CellIdAndLine) {
+	code, err := os.ReadFile(s.CodePath())
+	if err != nil {
+		klog.Warningf("Failed to read the composed code in %q: %+v", s.CodePath(), err)
+		return
+	}
+	s.lastComposed = &composedCode{
+		fileName:            filepath.Base(s.CodePath()),
+		code:                string(code),
+		fileToCellIdAndLine: fileToCellIdAndLine,
+	}
+}
+
+// goTokenStyles are the HTML styles used to highlight the Go tokens, by class.
+var goTokenStyles = map[string]string{
+	"keyword": "color: #0033b3; font-weight: bold",
+	"string":  "color: #067d17",
+	"comment": "color: #8c8c8c; font-style: italic",
+	"number":  "color: #1750eb",
+}
+
+// goTokenClass returns the class of the token for highlighting, or "" if it is not highlighted.
+func goTokenClass(tok token.Token) string {
+	switch {
+	case tok.IsKeyword():
+		return "keyword"
+	case tok == token.STRING || tok == token.CHAR:
+		return "string"
+	case tok == token.COMMENT:
+		return "comment"
+	case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+		return "number"
+	}
+	return ""
+}
+
+// highlightGo returns the HTML of each line of the Go code, with its keywords, literals and comments
+// highlighted. Invalid code is highlighted as far as it can be scanned.
+func highlightGo(code string) []string {
+	var sb strings.Builder
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(code))
+	var sc scanner.Scanner
+	sc.Init(file, []byte(code), nil, scanner.ScanComments)
+	last := 0
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			// Automatically inserted semicolon.
+			continue
+		}
+		class := goTokenClass(tok)
+		if class == "" {
+			continue
+		}
+		offset := file.Offset(pos)
+		end := offset + len(lit)
+		if offset < last || end > len(code) {
+			continue
+		}
+		sb.WriteString(html.EscapeString(code[last:offset]))
+		// Spans are closed at the end of each line, so the HTML can be split in lines.
+		for ii, part := range strings.Split(code[offset:end], "\n") {
+			if ii > 0 {
+				sb.WriteString("\n")
+			}
+			if part != "" {
+				fmt.Fprintf(&sb, "<span style=\"%s\">%s</span>", goTokenStyles[class], html.EscapeString(part))
+			}
+		}
+		last = end
+	}
+	sb.WriteString(html.EscapeString(code[last:]))
+	return strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+}
+
+// ShowComposed implements `%compose show`: it displays the code composed for the last execution, highlighted,
+// with its line numbers and the cell of each line.
+func (s *State) ShowComposed(msg kernel.Message) error {
+	if s.lastComposed == nil {
+		return kernel.PublishMarkdown(msg, "No code composed yet: execute a cell with Go code first.")
+	}
+	composed := s.lastComposed
+	lines := highlightGo(composed.code)
+	cellLabels := make([]string, len(lines))
+	labelWidth := 0
+	previousId := NoCursorLine
+	for ii := range lines {
+		if ii >= len(composed.fileToCellIdAndLine) {
+			break
+		}
+		cellId := composed.fileToCellIdAndLine[ii].Id
+		if composed.fileToCellIdAndLine[ii].Line == NoCursorLine {
+			continue
+		}
+		if cellId != previousId {
+			if cellId == NoCursorLine {
+				cellLabels[ii] = "[cell]"
+			} else {
+				cellLabels[ii] = fmt.Sprintf("[%d]", cellId)
+			}
+			labelWidth = max(labelWidth, len(cellLabels[ii]))
+		}
+		previousId = cellId
+	}
+	numberWidth := len(fmt.Sprintf("%d", len(lines)))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>%s</b> composed for the last execution (cell markers in brackets)\n", composed.fileName)
+	sb.WriteString("<pre style=\"line-height: 1.3\">")
+	for ii, line := range lines {
+		fmt.Fprintf(&sb, "<span style=\"color: #999; user-select: none\">%*d %-*s</span> %s\n",
+			numberWidth, ii+1, labelWidth, cellLabels[ii], line)
+	}
+	sb.WriteString("</pre>")
+	return kernel.PublishHtml(msg, sb.String())
+}
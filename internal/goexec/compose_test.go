@@ -0,0 +1,44 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightGo(t *testing.T) {
+	lines := highlightGo("package main\n\n/* A <b>\n comment */\nvar x = \"a\" + `b\nc`\n")
+	require.Len(t, lines, 6)
+	assert.Equal(t, `<span style="color: #0033b3; font-weight: bold">package</span> main`, lines[0])
+	assert.Equal(t, "", lines[1])
+	assert.Equal(t, `<span style="color: #8c8c8c; font-style: italic">/* A &lt;b&gt;</span>`, lines[2])
+	assert.Equal(t, `<span style="color: #8c8c8c; font-style: italic"> comment */</span>`, lines[3])
+	assert.Equal(t, `<span style="color: #0033b3; font-weight: bold">var</span> x = `+
+		`<span style="color: #067d17">&#34;a&#34;</span> + <span style="color: #067d17">`+"`b</span>", lines[4])
+	assert.Equal(t, "<span style=\"color: #067d17\">c`</span>", lines[5])
+
+	// Invalid code.
+	assert.Len(t, highlightGo("func (\n\"unterminated\n"), 2)
+}
+
+func TestShowComposed(t *testing.T) {
+	s := &State{TempDir: t.TempDir()}
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.ShowComposed(msg))
+	assert.Contains(t, fmt.Sprint(msg.Outputs()[0]["data"]), "No code composed yet")
+
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte("package main\n\nfunc f() {}\n\nfunc main() {\n}\n"), 0600))
+	s.recordComposed([]CellIdAndLine{{-1, -1}, {-1, -1}, {3, 0}, {-1, -1}, {5, 0}, {5, 1}})
+	require.NoError(t, s.ShowComposed(msg))
+	data := msg.Outputs()[1]["data"].(map[string]any)
+	html := data["text/html"].(string)
+	assert.Contains(t, html, "<b>main.go</b>")
+	assert.Contains(t, html, `3 [3]</span> <span style="color: #0033b3; font-weight: bold">func</span> f() {}`)
+	assert.Contains(t, html, `5 [5]</span> <span style="color: #0033b3; font-weight: bold">func</span> main() {`)
+	assert.Contains(t, html, "6    </span> }")
+}
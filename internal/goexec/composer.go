@@ -115,9 +115,30 @@ func (d *Declarations) RenderImports(w *WriterWithCursor, fileToCellIdAndLine []
 		return cursor, fileToCellIdAndLine
 	}
 
+	// `import "C"` is rendered on its own, preceded by its cgo preambles, if any.
+	if importDecl, found := d.Imports["C"]; found && importDecl.Path == "C" {
+		for _, preamble := range importDecl.CgoPreambles {
+			fileToCellIdAndLine = w.FillLinesGap(fileToCellIdAndLine)
+			fileToCellIdAndLine = preamble.CellLines.Append(fileToCellIdAndLine)
+			w.Writef("%s\n", preamble.Text)
+		}
+		fileToCellIdAndLine = w.FillLinesGap(fileToCellIdAndLine)
+		fileToCellIdAndLine = importDecl.CellLines.Append(fileToCellIdAndLine)
+		if importDecl.CursorInPath {
+			cursor = w.CursorPlusDelta(importDecl.Cursor)
+		}
+		w.Write("import \"C\"\n\n")
+		if len(d.Imports) == 1 {
+			return cursor, fileToCellIdAndLine
+		}
+	}
+
 	w.Write("import (\n")
 	for _, key := range SortedKeys(d.Imports) {
 		importDecl := d.Imports[key]
+		if key == "C" && importDecl.Path == "C" {
+			continue
+		}
 		fileToCellIdAndLine = w.FillLinesGap(fileToCellIdAndLine)
 		fileToCellIdAndLine = importDecl.CellLines.Append(fileToCellIdAndLine)
 		w.Write("\t")
@@ -136,6 +157,26 @@ func (d *Declarations) RenderImports(w *WriterWithCursor, fileToCellIdAndLine []
 	return cursor, fileToCellIdAndLine
 }
 
+// RenderGenerateDirectives writes out the `//go:generate` directives in Declarations.
+func (d *Declarations) RenderGenerateDirectives(w *WriterWithCursor, fileToCellIdAndLine []CellIdAndLine) (Cursor, []CellIdAndLine) {
+	cursor := NoCursor
+	if len(d.GenerateDirectives) == 0 {
+		return cursor, fileToCellIdAndLine
+	}
+
+	for _, key := range SortedKeys(d.GenerateDirectives) {
+		directive := d.GenerateDirectives[key]
+		fileToCellIdAndLine = w.FillLinesGap(fileToCellIdAndLine)
+		fileToCellIdAndLine = directive.CellLines.Append(fileToCellIdAndLine)
+		if directive.HasCursor() {
+			cursor = w.CursorPlusDelta(directive.Cursor)
+		}
+		w.Writef("%s%s\n", GenerateDirectivePrefix, key)
+	}
+	w.Write("\n")
+	return cursor, fileToCellIdAndLine
+}
+
 // RenderVariables writes out `var ( ... )` for all variables in Declarations.
 func (d *Declarations) RenderVariables(w *WriterWithCursor, fileToCellIdAndLine []CellIdAndLine) (Cursor, []CellIdAndLine) {
 	cursor := NoCursor
@@ -287,12 +328,38 @@ func (c *Constant) Render(w *WriterWithCursor, cursor *Cursor, fileToCellIdAndLi
 	return fileToCellIdAndLine
 }
 
+// mainPreamble returns the code that starts the `func main()` created for the `%%` line: it parses the flags
+// (except for `%run-cli`, where the CLI parses them), if State.LeakCheck is set, it defers the check for leaked
+// goroutines, if State.MainContext is set, it defines a `ctx` canceled when the execution is interrupted, and
+// it executes the prelude, if one is set (see State.SetPrelude).
+func (s *State) mainPreamble() string {
+	preamble := "func main() {\n"
+	if !s.CellRunCLI {
+		preamble += "\tflag.Parse()\n"
+	}
+	if s.leakCheckEnabled() {
+		// Deferred first, so it runs after any other deferred function of main.
+		preamble += "\tdefer gonbLeakCheck()()\n"
+	}
+	if s.MainContext {
+		if s.CellIsWasm || s.TinyGoTarget != "" {
+			// No interruption signals in the browser or in the TinyGo targets.
+			preamble += "\tctx, cancelCtx := context.WithCancel(context.Background())\n"
+		} else {
+			preamble += "\tctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt)\n"
+		}
+		preamble += "\tdefer cancelCtx()\n\t_ = ctx\n"
+	}
+	return preamble + s.preludeStatements()
+}
+
 // createGoFileFromLines creates a Go file from the cell contents.
 // It doesn't yet include previous declarations.
 //
 // Among the things it handles:
 //   - Adding an initial `package main` line.
 //   - Handle the special `%%` line, a shortcut to create a `func main()`.
+//   - Move `go` statements at the top level of the cell into a `func main()`, see topLevelGoStatements.
 //
 // Parameters:
 //   - filePath is the path where to write the Go code.
@@ -309,9 +376,10 @@ func (s *State) createGoFileFromLines(filePath string, cellId int, lines []strin
 	cursorInFile Cursor, fileToCellLines []int, err error) {
 	cursorInFile = NoCursor
 
-	// Maximum number of extra Lines created is 5, so we create a map with that amount of line. Later we trim it
-	// to the correct number.
-	fileToCellLines = make([]int, len(lines)+5)
+	// Maximum number of extra Lines created is 5 (plus the lines of the `ctx` preamble), so we create a map with
+	// that amount of line. Later we trim it to the correct number.
+	mainPreamble := s.mainPreamble()
+	fileToCellLines = make([]int, len(lines)+5+strings.Count(mainPreamble, "\n"))
 	for ii := 0; ii < len(fileToCellLines); ii++ {
 		fileToCellLines[ii] = NoCursorLine
 	}
@@ -332,20 +400,37 @@ func (s *State) createGoFileFromLines(filePath string, cellId int, lines []strin
 		}
 	}()
 
+	var hoisted Set[int]
+	if !s.CellIsSelection {
+		if hoisted, err = topLevelGoStatements(lines, skipLines); err != nil {
+			return
+		}
+	}
+
 	w.Write("package main\n\n")
 	var createdFuncMain bool
 	isFirstLine := true
+	if s.CellIsSelection {
+		// Selection: all the code is the body of `func main()`, whose preamble is associated to the first line.
+		for line := 0; line < strings.Count(mainPreamble, "\n"); line++ {
+			fileToCellLines[w.Line+line] = 0
+		}
+		w.Write(mainPreamble)
+		createdFuncMain = true
+		isFirstLine = false
+	}
 	for ii, line := range lines {
 		if strings.HasPrefix(line, "%main") || strings.HasPrefix(line, "%%") {
 			// Write preamble of func main() and associate to the "%%" line:
-			fileToCellLines[w.Line] = ii
-			fileToCellLines[w.Line+1] = ii
-			w.Write("func main() {\n\tflag.Parse()\n")
+			for line := 0; line < strings.Count(mainPreamble, "\n"); line++ {
+				fileToCellLines[w.Line+line] = ii
+			}
+			w.Write(mainPreamble)
 			createdFuncMain = true
 			isFirstLine = false
 			continue
 		}
-		if _, found := skipLines[ii]; found {
+		if _, found := skipLines[ii]; found || hoisted.Has(ii) {
 			continue
 		}
 		if createdFuncMain && line != "" {
@@ -365,6 +450,25 @@ func (s *State) createGoFileFromLines(filePath string, cellId int, lines []strin
 		w.Write("\n")
 		isFirstLine = false
 	}
+	if len(hoisted) > 0 {
+		// Hoisted `go` statements, in a `func main()` whose preamble is associated to the first of them.
+		hoistedLines := SortedKeys(hoisted)
+		w.Write("\n")
+		for line := 0; line < strings.Count(mainPreamble, "\n"); line++ {
+			fileToCellLines[w.Line+line] = hoistedLines[0]
+		}
+		w.Write(mainPreamble)
+		for _, ii := range hoistedLines {
+			w.Write("\t")
+			if ii == cursorInCell.Line {
+				cursorInFile = w.CursorPlusDelta(Cursor{Col: cursorInCell.Col})
+			}
+			fileToCellLines[w.Line] = ii
+			w.Write(lines[ii])
+			w.Write("\n")
+		}
+		createdFuncMain = true
+	}
 	if createdFuncMain {
 		w.Write("\n}\n")
 	}
@@ -461,6 +565,9 @@ func (s *State) createCodeFromDecls(writer io.Writer, decls *Declarations, mainD
 		return false
 	}
 
+	if mergeCursorAndReportError(w, decls.RenderGenerateDirectives, "generate directives") {
+		return
+	}
 	if mergeCursorAndReportError(w, decls.RenderImports, "imports") {
 		return
 	}
@@ -72,3 +72,31 @@ func TestCreateGoFileFromLines(t *testing.T) {
 	require.Errorf(t, err, "Expected error for unnecessary setting of `package`.")
 	assert.Contains(t, err.Error(), "Please don't set a `package`")
 }
+
+func TestCreateGoFileFromLinesMainContext(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	s.MainContext = true
+
+	cellLines := strings.Split("%%\nfmt.Println(ctx.Err())", "\n")
+	_, fileToCellLines, err := s.createGoFileFromLines(s.CodePath(), 1, cellLines, MakeSet[int](), NoCursor)
+	require.NoErrorf(t, err, "Failed createGoFileFromLines(%q)", s.CodePath())
+	contentBytes, err := os.ReadFile(s.CodePath())
+	require.NoErrorf(t, err, "Failed os.ReadFile(%q)", s.CodePath())
+	content := string(contentBytes)
+	require.Contains(t, content, "ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt)")
+	require.Contains(t, content, "defer cancelCtx()")
+
+	// All lines of the preamble of `func main()` map to the "%%" line.
+	fileLines := strings.Split(content, "\n")
+	for ii, line := range fileLines {
+		if strings.Contains(line, "ctx.Err()") {
+			assert.Equal(t, 1, fileToCellLines[ii], "Line mapping of %q", line)
+		} else if strings.Contains(line, "flag.Parse()") || strings.Contains(line, "cancelCtx") {
+			assert.Equal(t, 0, fileToCellLines[ii], "Line mapping of %q", line)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package goexec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the coverage report of `%test -cover`: the coverage profile of the test
+// is rendered as a heat-map over the source of the functions defined in the notebook.
+
+// CoverProfileName is the name of the coverage profile, written in State.TempDir by `%test -cover`.
+const CoverProfileName = "cover.out"
+
+// CoverProfilePath is the path where the coverage profile is written by `%test -cover`.
+func (s *State) CoverProfilePath() string {
+	return path.Join(s.TempDir, CoverProfileName)
+}
+
+// coverBlock is one entry of the coverage profile. Lines are 1-based, as in the profile.
+type coverBlock struct {
+	startLine, endLine int
+	numStmts, count    int
+}
+
+// parseCoverProfile parses the blocks of the coverage profile content, for the file with the given base name.
+func parseCoverProfile(content, fileName string) (blocks []coverBlock, err error) {
+	for lineNum, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// Format: <file>:<startLine>.<startCol>,<endLine>.<endCol> <numStmts> <count>
+		var fields []string
+		colon := strings.LastIndex(line, ":")
+		if colon >= 0 {
+			fields = strings.Fields(line[colon+1:])
+		}
+		if len(fields) != 3 {
+			return nil, errors.Errorf("invalid coverage profile line %d: %q", lineNum+1, line)
+		}
+		if filepath.Base(line[:colon]) != fileName {
+			continue
+		}
+		start, end, found := strings.Cut(fields[0], ",")
+		var block coverBlock
+		if found {
+			block.startLine, err = strconv.Atoi(strings.Split(start, ".")[0])
+			if err == nil {
+				block.endLine, err = strconv.Atoi(strings.Split(end, ".")[0])
+			}
+			if err == nil {
+				block.numStmts, err = strconv.Atoi(fields[1])
+			}
+			if err == nil {
+				block.count, err = strconv.Atoi(fields[2])
+			}
+		}
+		if !found || err != nil {
+			return nil, errors.Errorf("invalid coverage profile line %d: %q", lineNum+1, line)
+		}
+		blocks = append(blocks, block)
+	}
+	return
+}
+
+// lineCoverage maps each line (1-based) with statements to its execution count. When more than one
+// block covers a line, the largest count is used.
+func lineCoverage(blocks []coverBlock) map[int]int {
+	counts := make(map[int]int)
+	for _, block := range blocks {
+		for line := block.startLine; line <= block.endLine; line++ {
+			if count, found := counts[line]; !found || block.count > count {
+				counts[line] = block.count
+			}
+		}
+	}
+	return counts
+}
+
+// coverageColor returns the background color of a line executed count times, where maxCount is the
+// largest count in the report.
+func coverageColor(count, maxCount int) string {
+	if count == 0 {
+		return "rgba(220, 50, 50, 0.35)"
+	}
+	alpha := 0.15
+	if maxCount > 1 {
+		alpha += 0.45 * float64(count-1) / float64(maxCount-1)
+	}
+	return fmt.Sprintf("rgba(40, 170, 70, %.2f)", alpha)
+}
+
+// renderCoverage renders the functions of the notebook in the source file content, as HTML, with each line
+// colored according to its coverage. Tests, benchmarks and the `main` function are not included.
+func renderCoverage(content string, blocks []coverBlock, fileToCellIdAndLine []CellIdAndLine) (string, error) {
+	fileSet := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fileSet, "", content, parser.SkipObjectResolution)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing code for coverage report")
+	}
+	counts := lineCoverage(blocks)
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+	lines := strings.Split(content, "\n")
+
+	var sb strings.Builder
+	sb.WriteString("<h3>Coverage</h3>\n")
+	for _, decl := range fileAst.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		name := funcDecl.Name.Name
+		if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+			name = receiverTypeName(funcDecl.Recv.List[0].Type) + "." + name
+		} else if name == "main" || name == "init" || strings.HasPrefix(name, "Test") ||
+			strings.HasPrefix(name, "Benchmark") || strings.HasPrefix(name, "Fuzz") {
+			continue
+		}
+		fromLine, toLine := fileSet.Position(funcDecl.Pos()).Line, fileSet.Position(funcDecl.End()).Line
+		var numStmts, coveredStmts int
+		for _, block := range blocks {
+			if block.startLine >= fromLine && block.endLine <= toLine {
+				numStmts += block.numStmts
+				if block.count > 0 {
+					coveredStmts += block.numStmts
+				}
+			}
+		}
+		percentage := 100.0
+		if numStmts > 0 {
+			percentage = 100.0 * float64(coveredStmts) / float64(numStmts)
+		}
+		sb.WriteString(fmt.Sprintf("<h4><code>%s</code>: %.1f%% of statements</h4>\n", html.EscapeString(name), percentage))
+		sb.WriteString("<table style=\"border-collapse: collapse; font-family: monospace\">\n")
+		for lineNum := fromLine; lineNum <= toLine && lineNum <= len(lines); lineNum++ {
+			var cellRef, countStr, style string
+			if lineNum-1 < len(fileToCellIdAndLine) {
+				if ref := fileToCellIdAndLine[lineNum-1]; ref.Line != NoCursorLine {
+					cellRef = fmt.Sprintf("Cell[%d]: Line %d", ref.Id, ref.Line+1)
+				}
+			}
+			if count, found := counts[lineNum]; found {
+				countStr = strconv.Itoa(count)
+				style = fmt.Sprintf(" style=\"background-color: %s\"", coverageColor(count, maxCount))
+			}
+			sb.WriteString(fmt.Sprintf(
+				"<tr%s><td style=\"padding: 0 1em; opacity: 0.6\">%s</td><td style=\"padding: 0 1em; text-align: right\">%s</td>"+
+					"<td style=\"text-align: left\"><pre style=\"margin: 0; background: transparent\">%s</pre></td></tr>\n",
+				style, cellRef, countStr, html.EscapeString(lines[lineNum-1])))
+		}
+		sb.WriteString("</table>\n")
+	}
+	return sb.String(), nil
+}
+
+// publishCoverage reads the coverage profile written by the test binary, and publishes the coverage
+// heat-map of the functions defined in the notebook.
+func (s *State) publishCoverage(msg kernel.Message, fileToCellIdAndLine []CellIdAndLine) error {
+	profile, err := os.ReadFile(s.CoverProfilePath())
+	if err != nil {
+		return errors.Wrapf(err, "reading coverage profile %q", s.CoverProfilePath())
+	}
+	blocks, err := parseCoverProfile(string(profile), filepath.Base(s.CodePath()))
+	if err != nil {
+		return err
+	}
+	content, err := s.readMainGo()
+	if err != nil {
+		return err
+	}
+	report, err := renderCoverage(content, blocks, fileToCellIdAndLine)
+	if err != nil {
+		return err
+	}
+	return kernel.PublishHtml(msg, report)
+}
@@ -0,0 +1,51 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	profile := `mode: count
+gonb_1234/main_test.go:5.14,6.12 1 3
+gonb_1234/main_test.go:6.12,8.3 1 0
+gonb_1234/main_test.go:9.2,9.10 1 3
+gonb_1234/other.go:1.1,2.2 1 1
+`
+	blocks, err := parseCoverProfile(profile, "main_test.go")
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+	assert.Equal(t, coverBlock{startLine: 6, endLine: 8, numStmts: 1, count: 0}, blocks[1])
+	assert.Equal(t, map[int]int{5: 3, 6: 3, 7: 0, 8: 0, 9: 3}, lineCoverage(blocks))
+
+	_, err = parseCoverProfile("main_test.go:5.14 1\n", "main_test.go")
+	assert.Error(t, err)
+
+	content := `package main
+
+import "testing"
+
+func f(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestF(t *testing.T) {
+	f(1)
+}
+`
+	fileToCellIdAndLine := make([]CellIdAndLine, 14)
+	for ii := range fileToCellIdAndLine {
+		fileToCellIdAndLine[ii] = CellIdAndLine{Id: 2, Line: ii}
+	}
+	report, err := renderCoverage(content, blocks, fileToCellIdAndLine)
+	require.NoError(t, err)
+	assert.Contains(t, report, "<code>f</code>: 66.7% of statements")
+	assert.Contains(t, report, "Cell[2]: Line 7")
+	assert.Contains(t, report, "return -x")
+	assert.NotContains(t, report, "TestF")
+}
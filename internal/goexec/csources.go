@@ -0,0 +1,90 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the C source cells (`%%c <file>`): C (or C++, assembly) files written to the
+// temporary module and compiled by cgo along with the memorized declarations, that can use them with
+// `import "C"`.
+
+// cSourceExtensions are the extensions of the files accepted by `%%c`, compiled (or included) by cgo.
+var cSourceExtensions = MakeSet[string]()
+
+func init() {
+	for _, ext := range []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx", ".s", ".S"} {
+		cSourceExtensions.Insert(ext)
+	}
+}
+
+// cSource holds where a C source file was defined: the cell and the line in the cell of its first line.
+type cSource struct {
+	cellId, firstLine int
+}
+
+// WriteCSource writes the contents of a C source file, defined in the cell cellId starting at line
+// firstLine, to State.TempDir, where it is compiled by cgo. It replaces any previous file with the same name.
+func (s *State) WriteCSource(msg kernel.Message, cellId, firstLine int, name, contents string) error {
+	if name == "" || filepath.Base(name) != name {
+		return errors.Errorf("%%%%c requires a file name (without directories), e.g.: `%%%%c mylib.c`, got %q", name)
+	}
+	if !cSourceExtensions.Has(path.Ext(name)) {
+		return errors.Errorf("%%%%c file %q must have one of the extensions %q", name, SortedKeys(cSourceExtensions))
+	}
+	filePath := path.Join(s.TempDir, name)
+	if err := os.WriteFile(filePath, []byte(contents), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %q", filePath)
+	}
+	if s.cSources == nil {
+		s.cSources = make(map[string]cSource)
+	}
+	s.cSources[name] = cSource{cellId: cellId, firstLine: firstLine}
+	if msg == nil {
+		return nil
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("%%%%c: %s written, use it with `import \"C\"`.\n", name))
+}
+
+// RemoveCSources removes the C source files written with `%%c`.
+func (s *State) RemoveCSources() error {
+	for name := range s.cSources {
+		filePath := path.Join(s.TempDir, name)
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %q", filePath)
+		}
+	}
+	s.cSources = nil
+	return nil
+}
+
+var reCSourceReference = regexp.MustCompile(`[^\s():]*?([^\s():/]+):(\d+)(:\d+)?`)
+
+// mapCSourceReferences annotates references to lines of the C source files written with `%%c`
+// (e.g.: `./mylib.c:12:3`) in the output of the compiler with the corresponding cell lines.
+func (s *State) mapCSourceReferences(output string) string {
+	if len(s.cSources) == 0 {
+		return output
+	}
+	return reCSourceReference.ReplaceAllStringFunc(output, func(ref string) string {
+		parts := reCSourceReference.FindStringSubmatch(ref)
+		source, found := s.cSources[parts[1]]
+		if !found {
+			return ref
+		}
+		lineNum, err := strconv.Atoi(parts[2])
+		if err != nil || lineNum < 1 {
+			return ref
+		}
+		return fmt.Sprintf("%s (Cell[%d]: Line %d)", ref, source.cellId, source.firstLine+lineNum)
+	})
+}
@@ -0,0 +1,114 @@
+package goexec
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgoPreamble(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	cellCode := `import "fmt"
+
+// #cgo pkg-config: zlib
+// #include <stdlib.h>
+// int twice(int x) { return 2 * x; }
+import "C"
+
+func Twice(x int) int { return int(C.twice(C.int(x))) }
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	decls, err := s.parseFromGoCode(nil, 1, NoCursor, MakeFileToCellIdAndLine(1, fileToCellLine))
+	require.NoError(t, err)
+	require.Contains(t, decls.Imports, "C")
+	assert.Equal(t, []CgoPreamble{{
+		Text:      "// #cgo pkg-config: zlib\n// #include <stdlib.h>\n// int twice(int x) { return 2 * x; }",
+		CellLines: CellLines{Id: 1, Lines: []int{2, 3, 4}},
+	}}, decls.Imports["C"].CgoPreambles)
+
+	// `import "C"` is rendered on its own, right after its preamble.
+	buf := bytes.NewBuffer(nil)
+	_, fileToCellIdAndLine, err := s.createCodeFromDecls(buf, decls, nil)
+	require.NoError(t, err)
+	content := buf.String()
+	assert.Contains(t, content, "// int twice(int x) { return 2 * x; }\nimport \"C\"\n\nimport (\n\t\"fmt\"\n)\n")
+	assert.NotContains(t, content, "\t\"C\"")
+	lineOfImportC := strings.Count(content[:strings.Index(content, "import \"C\"")], "\n")
+	assert.Equal(t, CellIdAndLine{Id: 1, Line: 5}, fileToCellIdAndLine[lineOfImportC])
+	assert.Equal(t, CellIdAndLine{Id: 1, Line: 4}, fileToCellIdAndLine[lineOfImportC-1])
+}
+
+func TestCgoPreamblesMerged(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	// parseCell parses the cell and merges its declarations into the memorized ones.
+	parseCell := func(cellId int, cellCode string) {
+		lines := strings.Split(cellCode, "\n")
+		_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), cellId, lines, MakeSet[int](), NoCursor)
+		require.NoError(t, err)
+		decls, err := s.parseFromGoCode(nil, cellId, NoCursor, MakeFileToCellIdAndLine(cellId, fileToCellLine))
+		require.NoError(t, err)
+		s.Definitions.MergeFrom(decls)
+	}
+	preambleTexts := func() (texts []string) {
+		for _, preamble := range s.Definitions.Imports["C"].CgoPreambles {
+			texts = append(texts, preamble.Text)
+		}
+		return
+	}
+
+	parseCell(1, "// int twice(int x) { return 2 * x; }\nimport \"C\"\n\nfunc Twice(x int) int { return int(C.twice(C.int(x))) }\n")
+	// A bare `import "C"` keeps the memorized preamble.
+	parseCell(2, "import \"C\"\n\nfunc Four() int { return int(C.twice(2)) }\n")
+	assert.Equal(t, []string{"// int twice(int x) { return 2 * x; }"}, preambleTexts())
+
+	// Preambles of different cells are merged, and the same preamble isn't repeated.
+	parseCell(3, "// int thrice(int x) { return 3 * x; }\nimport \"C\"\n\nfunc Thrice(x int) int { return int(C.thrice(C.int(x))) }\n")
+	parseCell(4, "// int twice(int x) { return 2 * x; }\nimport \"C\"\n\nfunc Twice(x int) int { return int(C.twice(C.int(x))) }\n")
+	assert.Equal(t, []string{"// int twice(int x) { return 2 * x; }", "// int thrice(int x) { return 3 * x; }"},
+		preambleTexts())
+	assert.Equal(t, 4, s.Definitions.Imports["C"].CgoPreambles[0].Id)
+
+	// Both preambles are rendered right before `import "C"`, mapped to their cells.
+	buf := bytes.NewBuffer(nil)
+	_, fileToCellIdAndLine, err := s.createCodeFromDecls(buf, s.Definitions, nil)
+	require.NoError(t, err)
+	content := buf.String()
+	assert.Contains(t, content, "// int twice(int x) { return 2 * x; }\n// int thrice(int x) { return 3 * x; }\nimport \"C\"\n")
+	lineOfImportC := strings.Count(content[:strings.Index(content, "import \"C\"")], "\n")
+	assert.Equal(t, CellIdAndLine{Id: 3, Line: 0}, fileToCellIdAndLine[lineOfImportC-1])
+	assert.Equal(t, CellIdAndLine{Id: 4, Line: 0}, fileToCellIdAndLine[lineOfImportC-2])
+}
+
+func TestCSources(t *testing.T) {
+	s := &State{TempDir: t.TempDir()}
+	require.Error(t, s.WriteCSource(nil, 3, 1, "../mylib.c", ""))
+	require.Error(t, s.WriteCSource(nil, 3, 1, "mylib.go", ""))
+	require.NoError(t, s.WriteCSource(nil, 3, 1, "mylib.c", "int twice(int x) {\n  return 2 * y;\n}\n"))
+	assert.FileExists(t, path.Join(s.TempDir, "mylib.c"))
+
+	output := "# gonb_1234\n./mylib.c:2:14: error: 'y' undeclared\nmain.go:3:1: other\n"
+	assert.Equal(t, "# gonb_1234\n./mylib.c:2:14 (Cell[3]: Line 3): error: 'y' undeclared\nmain.go:3:1: other\n",
+		s.mapCSourceReferences(output))
+
+	require.NoError(t, s.RemoveCSources())
+	_, err := os.Stat(path.Join(s.TempDir, "mylib.c"))
+	assert.True(t, os.IsNotExist(err))
+}
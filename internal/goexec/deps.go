@@ -0,0 +1,184 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os/exec"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the display of the module dependencies of the notebook, connected to the
+// special commands `%deps` and `%deps why`.
+
+// moduleGraph is the graph of module requirements, as output by `go mod graph`.
+type moduleGraph struct {
+	root  string
+	edges map[string][]string // Module (with version) to its requirements, in order.
+}
+
+// parseModuleGraph parses the output of `go mod graph`: each line holds a module and one of its requirements.
+// The first module listed is the main module.
+func parseModuleGraph(output string) *moduleGraph {
+	g := &moduleGraph{edges: make(map[string][]string)}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if g.root == "" {
+			g.root = fields[0]
+		}
+		g.edges[fields[0]] = append(g.edges[fields[0]], fields[1])
+	}
+	return g
+}
+
+// renderTree renders the graph as nested collapsible HTML lists. Modules already rendered are not expanded again.
+func (g *moduleGraph) renderTree() string {
+	var sb strings.Builder
+	visited := MakeSet[string]()
+	var render func(module string)
+	render = func(module string) {
+		requirements := g.edges[module]
+		name := fmt.Sprintf("<code>%s</code>", html.EscapeString(module))
+		if len(requirements) == 0 {
+			sb.WriteString(fmt.Sprintf("<li>%s</li>\n", name))
+			return
+		}
+		if visited.Has(module) {
+			sb.WriteString(fmt.Sprintf("<li>%s (see above)</li>\n", name))
+			return
+		}
+		visited.Insert(module)
+		sb.WriteString(fmt.Sprintf("<li><details><summary>%s (%d)</summary>\n<ul>\n", name, len(requirements)))
+		for _, requirement := range requirements {
+			render(requirement)
+		}
+		sb.WriteString("</ul></details></li>\n")
+	}
+	sb.WriteString("<ul>\n")
+	if g.root != "" {
+		render(g.root)
+	}
+	sb.WriteString("</ul>\n")
+	return sb.String()
+}
+
+// dot returns the graph in the Graphviz DOT language.
+func (g *moduleGraph) dot() string {
+	var sb strings.Builder
+	sb.WriteString("digraph deps {\n\trankdir=LR;\n\tnode [shape=box, fontsize=10];\n")
+	for _, module := range SortedKeys(g.edges) {
+		for _, requirement := range g.edges[module] {
+			sb.WriteString(fmt.Sprintf("\t%q -> %q;\n", module, requirement))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Deps displays the dependencies of the notebook: the direct dependencies with their versions, the
+// module graph (`go mod graph`) as a collapsible tree, and as an SVG if Graphviz (`dot`) is installed.
+func (s *State) Deps(msg kernel.Message) (err error) {
+	err = s.composeForDependencies(msg)
+	if err != nil {
+		return
+	}
+	deps, err := s.goModDependencies()
+	if err != nil {
+		return
+	}
+	cmd := s.goCommand("mod", "graph")
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	graph := parseModuleGraph(string(output))
+
+	var sb strings.Builder
+	sb.WriteString("<h4>Direct dependencies</h4>\n")
+	var direct []string
+	for _, key := range SortedKeys(deps) {
+		kind, module, _ := strings.Cut(key, " ")
+		if kind == "require" && !strings.HasSuffix(deps[key], "// indirect") {
+			direct = append(direct, fmt.Sprintf("<tr><td><code>%s</code></td><td><code>%s</code></td></tr>\n",
+				html.EscapeString(module), html.EscapeString(deps[key])))
+		}
+	}
+	if len(direct) == 0 {
+		sb.WriteString("<p>No dependencies outside the standard library.</p>\n")
+	} else {
+		sb.WriteString("<table>\n<tr><th>Module</th><th>Version</th></tr>\n")
+		sb.WriteString(strings.Join(direct, ""))
+		sb.WriteString("</table>\n")
+	}
+	if len(graph.edges) > 0 {
+		sb.WriteString("<h4>Module graph</h4>\n")
+		sb.WriteString(graph.renderTree())
+		if svg := renderDot(graph.dot()); svg != "" {
+			sb.WriteString("<details><summary>Graph</summary>\n")
+			sb.WriteString(svg)
+			sb.WriteString("\n</details>\n")
+		}
+	}
+	return kernel.PublishHtml(msg, sb.String())
+}
+
+// DepsWhy explains why the packages (or modules, if the first argument is `-m`) are needed by the
+// notebook, with `go mod why`.
+func (s *State) DepsWhy(msg kernel.Message, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("%deps why requires the packages (or `-m` and modules) to explain")
+	}
+	err = s.composeForDependencies(msg)
+	if err != nil {
+		return
+	}
+	executor, err := s.goExecutor(msg, append([]string{"mod", "why"}, args...)...)
+	if err == nil {
+		err = executor.Exec()
+	}
+	return err
+}
+
+// composeForDependencies composes `main.go` with the memorized declarations, so the dependencies
+// reflect all the imports of the notebook.
+func (s *State) composeForDependencies(msg kernel.Message) (err error) {
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	_, err = s.composeMemorizedDeclarations(msg)
+	return
+}
+
+// renderDot renders the DOT graph to SVG using Graphviz, if it is installed. It returns an empty
+// string if it is not installed or fails.
+func renderDot(dot string) string {
+	dotPath, err := exec.LookPath("dot")
+	if err != nil {
+		return ""
+	}
+	cmd := exec.Command(dotPath, "-Tsvg")
+	cmd.Stdin = strings.NewReader(dot)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err = cmd.Run(); err != nil {
+		klog.Warningf("Failed to render dependency graph with %q: %+v", dotPath, err)
+		return ""
+	}
+	svg := stdout.String()
+	// Drop the XML preamble, so the SVG can be inlined in HTML.
+	if idx := strings.Index(svg, "<svg"); idx > 0 {
+		svg = svg[idx:]
+	}
+	return svg
+}
@@ -0,0 +1,26 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleGraph(t *testing.T) {
+	g := parseModuleGraph(`gonb_1234 github.com/a/b@v1.0.0
+gonb_1234 github.com/c/d@v0.2.0
+github.com/a/b@v1.0.0 github.com/c/d@v0.2.0
+github.com/c/d@v0.2.0 golang.org/x/exp@v0.0.1
+`)
+	assert.Equal(t, "gonb_1234", g.root)
+	assert.Equal(t, []string{"github.com/a/b@v1.0.0", "github.com/c/d@v0.2.0"}, g.edges["gonb_1234"])
+
+	tree := g.renderTree()
+	// `github.com/c/d` is expanded only once.
+	assert.Equal(t, 1, strings.Count(tree, "<summary><code>github.com/c/d@v0.2.0</code> (1)</summary>"))
+	assert.Contains(t, tree, "<li><code>github.com/c/d@v0.2.0</code> (see above)</li>")
+	assert.Contains(t, tree, "<li><code>golang.org/x/exp@v0.0.1</code></li>")
+
+	assert.Contains(t, g.dot(), "\t\"github.com/a/b@v1.0.0\" -> \"github.com/c/d@v0.2.0\";\n")
+}
@@ -0,0 +1,288 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%doc`: it renders the documentation of the memorized declarations, in the layout
+// of the documentation of Go packages (godoc), so a notebook can double as the documentation of the API
+// it builds. The documentation is extracted with go/doc, from a file composed with the declarations and
+// their doc comments.
+
+// writeDocComment writes the text of the doc comment with the `//` markers, indented with indent.
+func writeDocComment(sb *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line == "" {
+			sb.WriteString(indent + "//\n")
+		} else {
+			sb.WriteString(indent + "// " + line + "\n")
+		}
+	}
+}
+
+// docSource composes the Go code of the declarations, with their doc comments, to be parsed by go/doc.
+// Imports are not needed, since the code is not type-checked.
+func (d *Declarations) docSource() string {
+	var sb strings.Builder
+	sb.WriteString("package main\n\n")
+	for _, key := range SortedKeys(d.Types) {
+		typeDecl := d.Types[key]
+		writeDocComment(&sb, "", typeDecl.Doc)
+		sb.WriteString("type " + typeDecl.TypeDefinition + "\n\n")
+	}
+
+	// Constants are rendered in their blocks, as in Declarations.RenderConstants.
+	var headKeys []string
+	for key, constDecl := range d.Constants {
+		if constDecl.Prev == nil {
+			headKeys = append(headKeys, key)
+		}
+	}
+	sort.Strings(headKeys)
+	for _, headKey := range headKeys {
+		constDecl := d.Constants[headKey]
+		if constDecl.Next == nil {
+			writeDocComment(&sb, "", constDecl.Doc)
+			sb.WriteString("const " + constDecl.docSpec() + "\n\n")
+			continue
+		}
+		sb.WriteString("const (\n")
+		for ; constDecl != nil; constDecl = constDecl.Next {
+			writeDocComment(&sb, "\t", constDecl.Doc)
+			sb.WriteString("\t" + constDecl.docSpec() + "\n")
+		}
+		sb.WriteString(")\n\n")
+	}
+
+	for _, key := range SortedKeys(d.Variables) {
+		varDecl := d.Variables[key]
+		if strings.HasPrefix(key, "_~") {
+			continue
+		}
+		writeDocComment(&sb, "", varDecl.Doc)
+		sb.WriteString("var " + varDecl.Name)
+		if varDecl.TypeDefinition != "" {
+			sb.WriteString(" " + varDecl.TypeDefinition)
+		}
+		if varDecl.ValueDefinition != "" {
+			sb.WriteString(" = " + varDecl.ValueDefinition)
+		}
+		sb.WriteString("\n\n")
+	}
+	for _, key := range SortedKeys(d.Functions) {
+		if strings.HasPrefix(key, InitFunctionPrefix) {
+			continue
+		}
+		writeDocComment(&sb, "", d.Functions[key].Doc)
+		sb.WriteString(d.Functions[key].Definition + "\n\n")
+	}
+	return sb.String()
+}
+
+// docSpec returns the spec of the constant, as written in a `const` declaration.
+func (c *Constant) docSpec() string {
+	spec := c.Key
+	if c.TypeDefinition != "" {
+		spec += " " + c.TypeDefinition
+	}
+	if c.ValueDefinition != "" {
+		spec += " = " + c.ValueDefinition
+	}
+	return spec
+}
+
+// docRenderer renders the documentation extracted by go/doc as Markdown.
+type docRenderer struct {
+	pkg     *doc.Package
+	fileSet *token.FileSet
+	file    *ast.File
+	cellIds map[string]int // Cell where each declaration was defined, by key, see declarationCellIds.
+	sb      strings.Builder
+}
+
+// code renders the declaration, without its doc comment (rendered separately) and without the body of
+// functions, as a Go code block.
+func (r *docRenderer) code(node ast.Node) {
+	switch decl := node.(type) {
+	case *ast.GenDecl:
+		declCopy := *decl
+		declCopy.Doc = nil
+		node = &declCopy
+	case *ast.FuncDecl:
+		declCopy := *decl
+		declCopy.Doc, declCopy.Body = nil, nil
+		node = &declCopy
+	}
+	var buf bytes.Buffer
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := config.Fprint(&buf, r.fileSet, &printer.CommentedNode{Node: node, Comments: r.file.Comments}); err != nil {
+		buf.WriteString(fmt.Sprintf("// failed to render declaration: %v", err))
+	}
+	r.sb.WriteString("```go\n" + buf.String() + "\n```\n\n")
+}
+
+// doc renders the doc comment, and the cell where the declaration with the given key was defined.
+func (r *docRenderer) doc(text, key string) {
+	if text != "" {
+		r.sb.Write(r.pkg.Markdown(text))
+		r.sb.WriteString("\n")
+	}
+	if cellId, found := r.cellIds[key]; found && cellId > 0 {
+		r.sb.WriteString(fmt.Sprintf("<sub>Defined in cell [%d].</sub>\n\n", cellId))
+	}
+}
+
+// values renders the constants or variables.
+func (r *docRenderer) values(values []*doc.Value) {
+	for _, value := range values {
+		r.code(value.Decl)
+		key := ""
+		if len(value.Names) > 0 {
+			key = value.Names[0]
+		}
+		r.doc(value.Doc, key)
+	}
+}
+
+// funcs renders the functions or methods, with the given heading level.
+func (r *docRenderer) funcs(funcs []*doc.Func, heading string) {
+	for _, f := range funcs {
+		key := f.Name
+		if f.Recv != "" {
+			recv := strings.TrimPrefix(f.Recv, "*")
+			if idx := strings.Index(recv, "["); idx >= 0 {
+				recv = recv[:idx] // Generic types.
+			}
+			key = recv + "~" + f.Name
+			r.sb.WriteString(fmt.Sprintf("%s func (%s) %s\n\n", heading, f.Recv, f.Name))
+		} else {
+			r.sb.WriteString(fmt.Sprintf("%s func %s\n\n", heading, f.Name))
+		}
+		r.code(f.Decl)
+		r.doc(f.Doc, key)
+	}
+}
+
+// render renders the documentation of the package in the layout of godoc: constants, variables, functions
+// and types, with their associated constants, variables, functions and methods.
+func (r *docRenderer) render() string {
+	r.sb.WriteString("## Notebook API\n\n")
+	if len(r.pkg.Consts) > 0 {
+		r.sb.WriteString("### Constants\n\n")
+		r.values(r.pkg.Consts)
+	}
+	if len(r.pkg.Vars) > 0 {
+		r.sb.WriteString("### Variables\n\n")
+		r.values(r.pkg.Vars)
+	}
+	if len(r.pkg.Funcs) > 0 {
+		r.sb.WriteString("### Functions\n\n")
+		r.funcs(r.pkg.Funcs, "####")
+	}
+	if len(r.pkg.Types) > 0 {
+		r.sb.WriteString("### Types\n\n")
+		for _, t := range r.pkg.Types {
+			r.sb.WriteString(fmt.Sprintf("#### type %s\n\n", t.Name))
+			r.code(t.Decl)
+			r.doc(t.Doc, t.Name)
+			r.values(t.Consts)
+			r.values(t.Vars)
+			r.funcs(t.Funcs, "#####")
+			r.funcs(t.Methods, "#####")
+		}
+	}
+	return r.sb.String()
+}
+
+// filterDocPackage keeps only the declarations with the given names in the package. Methods are selected
+// with `<type>.<method>`, and types include their methods and associated declarations.
+func filterDocPackage(pkg *doc.Package, names []string) {
+	selected := MakeSet[string]()
+	for _, name := range names {
+		selected.Insert(name)
+	}
+	filterValues := func(values []*doc.Value) (kept []*doc.Value) {
+		for _, value := range values {
+			for _, name := range value.Names {
+				if selected.Has(name) {
+					kept = append(kept, value)
+					break
+				}
+			}
+		}
+		return
+	}
+	filterFuncs := func(funcs []*doc.Func, recv string) (kept []*doc.Func) {
+		for _, f := range funcs {
+			if selected.Has(f.Name) || (recv != "" && selected.Has(recv+"."+f.Name)) {
+				kept = append(kept, f)
+			}
+		}
+		return
+	}
+	pkg.Consts = filterValues(pkg.Consts)
+	pkg.Vars = filterValues(pkg.Vars)
+	pkg.Funcs = filterFuncs(pkg.Funcs, "")
+	var types []*doc.Type
+	for _, t := range pkg.Types {
+		if selected.Has(t.Name) {
+			types = append(types, t)
+			continue
+		}
+		// Declarations associated to the type are listed on their own.
+		pkg.Consts = append(pkg.Consts, filterValues(t.Consts)...)
+		pkg.Vars = append(pkg.Vars, filterValues(t.Vars)...)
+		pkg.Funcs = append(pkg.Funcs, filterFuncs(t.Funcs, "")...)
+		pkg.Funcs = append(pkg.Funcs, filterFuncs(t.Methods, t.Name)...)
+	}
+	pkg.Types = types
+}
+
+// DocMarkdown returns the documentation, in Markdown, of the memorized declarations with the given names,
+// or of all of them, if names is empty. See `%doc`.
+func (s *State) DocMarkdown(names []string) (string, error) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "notebook.go", s.Definitions.docSource(), parser.ParseComments)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse the memorized declarations")
+	}
+	pkg, err := doc.NewFromFiles(fileSet, []*ast.File{file}, "main", doc.AllDecls|doc.PreserveAST)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to extract the documentation of the memorized declarations")
+	}
+	if len(names) > 0 {
+		filterDocPackage(pkg, names)
+		if len(pkg.Consts)+len(pkg.Vars)+len(pkg.Funcs)+len(pkg.Types) == 0 {
+			return "", errors.Errorf("no memorized declaration named %q", strings.Join(names, ", "))
+		}
+	} else if len(pkg.Consts)+len(pkg.Vars)+len(pkg.Funcs)+len(pkg.Types) == 0 {
+		return "", errors.New("no declarations memorized")
+	}
+	r := &docRenderer{pkg: pkg, fileSet: fileSet, file: file, cellIds: declarationCellIds(s.Definitions)}
+	return r.render(), nil
+}
+
+// Doc implements `%doc -all` and `%doc <names...>`: it displays the documentation of the memorized
+// declarations (all of them, or the given ones), see DocMarkdown.
+func (s *State) Doc(msg kernel.Message, names []string) error {
+	markdown, err := s.DocMarkdown(names)
+	if err != nil {
+		return err
+	}
+	return kernel.PublishMarkdown(msg, markdown)
+}
@@ -0,0 +1,71 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoc(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	cellCode := `// Shape is a geometric shape.
+type Shape struct {
+	// Sides of the shape.
+	Sides int
+}
+
+// Kinds of shapes.
+const (
+	// Triangle has 3 sides.
+	Triangle = iota
+	Square
+)
+
+// DefaultShape is used when none is given.
+var DefaultShape = NewShape(3)
+
+// NewShape creates a Shape with the given number of sides.
+func NewShape(sides int) *Shape { return &Shape{Sides: sides} }
+
+// Area returns the area of the [Shape].
+func (s *Shape) Area() float64 { return 0 }
+
+func helper() {}
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 3, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	s.Definitions, err = s.parseFromGoCode(nil, 3, NoCursor, MakeFileToCellIdAndLine(3, fileToCellLine))
+	require.NoError(t, err)
+	assert.Equal(t, "Shape is a geometric shape.\n", s.Definitions.Types["Shape"].Doc)
+	assert.Equal(t, "Triangle has 3 sides.\n", s.Definitions.Constants["Triangle"].Doc)
+	assert.Equal(t, "DefaultShape is used when none is given.\n", s.Definitions.Variables["DefaultShape"].Doc)
+
+	markdown, err := s.DocMarkdown(nil)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"### Constants\n\n```go\nconst (\n\t// Triangle has 3 sides.\n\tTriangle = iota\n\tSquare\n)\n```\n",
+		"### Functions\n\n#### func helper\n\n```go\nfunc helper()\n```\n",
+		"#### type Shape\n\n```go\ntype Shape struct {\n\t// Sides of the shape.\n\tSides int\n}\n```\n\nShape is a geometric shape.\n",
+		"var DefaultShape = NewShape(3)",
+		"##### func NewShape\n\n```go\nfunc NewShape(sides int) *Shape\n```\n\nNewShape creates a Shape with the given number of sides.\n",
+		"##### func (*Shape) Area\n\n```go\nfunc (s *Shape) Area() float64\n```\n\nArea returns the area of the [Shape](#Shape).\n\n<sub>Defined in cell [3].</sub>",
+	} {
+		assert.Contains(t, markdown, want)
+	}
+
+	// Selected declarations only.
+	markdown, err = s.DocMarkdown([]string{"Shape.Area", "Triangle"})
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "func (*Shape) Area")
+	assert.Contains(t, markdown, "Triangle = iota")
+	assert.NotContains(t, markdown, "type Shape")
+	assert.NotContains(t, markdown, "helper")
+	_, err = s.DocMarkdown([]string{"unknown"})
+	assert.Error(t, err)
+}
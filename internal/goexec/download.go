@@ -0,0 +1,62 @@
+package goexec
+
+import (
+	"encoding/base64"
+	"os"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the kernel side of the download buttons displayed with `gonbui.OfferFileDownload`: the
+// files are read and sent to the front-end when requested.
+
+// MaxDownloadSize is the maximum size of the files served to the download buttons of
+// `gonbui.OfferFileDownload`, since they are sent base64 encoded in one message.
+const MaxDownloadSize = 256 << 20
+
+// readDownload returns the contents of the file to download, encoded in base64.
+func readDownload(filePath string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "file not available")
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.Errorf("%q is not a regular file", filePath)
+	}
+	if info.Size() > MaxDownloadSize {
+		return "", errors.Errorf("%q has %d bytes, more than the maximum of %d bytes to download", filePath,
+			info.Size(), MaxDownloadSize)
+	}
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %q", filePath)
+	}
+	return base64.StdEncoding.EncodeToString(contents), nil
+}
+
+// handleDownloadRequest replies the requests of the download buttons (see `gonbui.OfferFileDownload`), sent to
+// protocol.GonbuiDownloadAddress and registered with comms.State.HandleAddressPrefix. The request has the fields
+// "id" and "path"; the reply, sent to protocol.GonbuiDownloadDataAddress, has the "id" and either the "data"
+// (base64 encoded) or an "error".
+func (s *State) handleDownloadRequest(msg kernel.Message, address string, value any) {
+	request, ok := value.(map[string]any)
+	filePath, _ := request["path"].(string)
+	if !ok || filePath == "" {
+		klog.Warningf("Invalid download request to %q: %v", address, value)
+		return
+	}
+	reply := map[string]any{"id": request["id"]}
+	data, err := readDownload(filePath)
+	if err != nil {
+		klog.Warningf("Download request failed: %+v", err)
+		reply["error"] = err.Error()
+	} else {
+		reply["data"] = data
+	}
+	if err = s.Comms.Send(msg, protocol.GonbuiDownloadDataAddress, reply); err != nil {
+		klog.Warningf("Failed to reply download request of %q: %+v", filePath, err)
+	}
+}
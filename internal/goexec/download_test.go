@@ -0,0 +1,25 @@
+package goexec
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDownload(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "results.csv")
+	require.NoError(t, os.WriteFile(filePath, []byte("a,b\n1,2\n"), 0600))
+	data, err := readDownload(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n")), data)
+
+	_, err = readDownload(dir)
+	assert.ErrorContains(t, err, "not a regular file")
+	_, err = readDownload(filepath.Join(dir, "missing.csv"))
+	assert.ErrorContains(t, err, "file not available")
+}
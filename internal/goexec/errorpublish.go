@@ -2,7 +2,10 @@ package goexec
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/pkg/errors"
+	"os"
+	"sync/atomic"
 	"text/template"
 
 	"github.com/janpfeifer/gonb/internal/kernel"
@@ -28,7 +31,7 @@ var templateErrorReport = template.Must(template.New("error_report").Parse(`
 .gonb-err-context {
 	display: none;
 }
-.gonb-err-location:hover + .gonb-err-context {
+.gonb-err-location:hover ~ .gonb-err-context {
 	background: var(--jp-dialog-background);  
 	border-radius: 3px;
 	border-style: solid;
@@ -54,28 +57,80 @@ var templateErrorReport = template.Must(template.New("error_report").Parse(`
 	padding-left: 0.2em;
 	padding-right: 0.2em;
 }
+.gonb-err-summary {
+	margin-bottom: 0.3em;
+}
+.gonb-err-group > summary {
+	cursor: pointer;
+	font-weight: bold;
+}
+.gonb-err-group {
+	margin-bottom: 0.3em;
+}
+.gonb-err-error .gonb-err-message {
+	color: var(--jp-error-color1);
+}
+.gonb-err-detail, .gonb-err-package, .gonb-err-other {
+	color: var(--jp-content-font-color2);
+	white-space: pre;
+}
+.gonb-err-package {
+	font-weight: bold;
+}
+.gonb-err-excerpt {
+	white-space: pre;
+	margin-left: 2em;
+	color: var(--jp-content-font-color1);
+}
+.gonb-err-col {
+	background-color: var(--jp-rendermime-err-background);
+	text-decoration: underline wavy var(--jp-error-color1);
+}
+.gonb-err-copy {
+	font-size: 80%;
+	padding: 0 0.3em;
+	cursor: pointer;
+	border: 1px solid var(--jp-border-color2);
+	border-radius: 3px;
+	background: var(--jp-layout-color2);
+	color: var(--jp-content-font-color2);
+}
 </style>
 <div class="lm-Widget p-Widget lm-Panel p-Panel jp-OutputArea-child">
 <div class="lm-Widget p-Widget jp-RenderedText jp-mod-trusted jp-OutputArea-output" data-mime-type="application/vnd.jupyter.stderr" style="font-family: monospace;">
-{{range .Lines}}
-{{if .HasContext}}{{if .HasCellInfo}}<span class="gonb-cell-line-info">{{.CellInfo}}</span>
-{{end}}<span class="gonb-err-location">{{.Location}}</span> {{.Message}}
+{{if gt (len .Groups) 1}}<div class="gonb-err-summary">{{.NumErrors}} error(s) in {{range $ii, $group := .Groups}}{{if $ii}}, {{end}}<a href="#{{$group.Anchor}}">{{$group.Title}}</a>{{end}}</div>
+{{end}}{{range .Groups}}<details open class="gonb-err-group" id="{{.Anchor}}">
+<summary>{{.Title}}{{if .NumErrors}}: {{.NumErrors}} error(s){{end}}</summary>
+{{range .Lines}}<div class="gonb-err-{{.Kind}}">
+{{if .HasContext}}{{if .HasCellInfo}}<span class="gonb-cell-line-info">{{html .CellInfo}}</span>
+{{end}}<span class="gonb-err-location">{{html .Location}}</span> <span class="gonb-err-message">{{html .Message}}</span>
+<button class="gonb-err-copy" title="Copy location" data-location="{{html .FixLocation}}" onclick="navigator.clipboard.writeText(this.dataset.location)">copy</button>
 <div class="gonb-err-context">
 {{.HtmlContext}}
 </div>
-{{else}}
-<span style="white-space: pre;">{{.Location}} {{.Message}}</span>
-{{end}}
-<br/>
-{{end}}
+{{if .Excerpt}}<div class="gonb-err-excerpt">{{.Excerpt}}</div>{{end}}
+{{else}}{{html .Message}}
+{{end}}</div>
+{{end}}</details>
+{{end}}</div>
 </div>
 `))
 
+// errorReport is the data used to render templateErrorReport.
+type errorReport struct {
+	Groups    []errorGroup
+	NumErrors int
+}
+
+// errorReportCount is used to create unique ids for the elements of the HTML error reports.
+var errorReportCount atomic.Int64
+
 // Example type of err message:
 // /tmp/gonb_4e5ea2e7/main.go:3:1: expected declaration, found fmt
 
-// DisplayErrorWithContext in an HTML div, with a mouse-over pop-up window
-// listing the Lines with the error, and highlighting the exact position.
+// DisplayErrorWithContext in an HTML div, with the errors grouped (and folded) per cell, color-coded, with
+// the offending source line inline, a button to copy the location of the error in the cell, and a
+// mouse-over pop-up window listing the Lines around the error, highlighting the exact position.
 //
 // Except if `rawError` is set to true (see `New() *State`): in which case the enriched GonbError is returned
 // instead, for a textual report back.
@@ -113,8 +168,12 @@ func (nbErr *GonbError) PublishWithHTML(msg kernel.Message) {
 	}()
 
 	// Render err block.
+	report := errorReport{Groups: nbErr.Groups(fmt.Sprintf("gonb-err-%d-%d", os.Getpid(), errorReportCount.Add(1)))}
+	for _, group := range report.Groups {
+		report.NumErrors += group.NumErrors
+	}
 	buf := bytes.NewBuffer(make([]byte, 0, 512*len(nbErr.Lines)))
-	if err := templateErrorReport.Execute(buf, nbErr); err != nil {
+	if err := templateErrorReport.Execute(buf, report); err != nil {
 		klog.Errorf("Failed to execute template in DisplayErrorWithContext: %+v", err)
 		return
 	}
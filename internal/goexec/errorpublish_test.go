@@ -1,8 +1,14 @@
 package goexec
 
 import (
-	"github.com/stretchr/testify/assert"
+	"fmt"
+	"os"
 	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestJupyterErrorSplit(t *testing.T) {
@@ -13,3 +19,21 @@ func TestJupyterErrorSplit(t *testing.T) {
 	assert.Equal(t, msg, errorMsg)
 	assert.NotEmpty(t, traceback, []string{errorMsg})
 }
+
+func TestPublishWithHTML(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte("package main\n\nfunc main() {\n\tvar m map[string]int = 1\n}\n"), 0644))
+	fileToCellIdAndLine := []CellIdAndLine{{-1, NoCursorLine}, {-1, NoCursorLine}, {-1, NoCursorLine}, {3, 0}, {-1, NoCursorLine}}
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	nbErr := newGonbErrors(s, fileToCellIdAndLine,
+		"./main.go:4:25: cannot use 1 (untyped int constant) as map[string]int value", errors.New("exit status 1"))
+	nbErr.PublishWithHTML(msg)
+	require.Len(t, msg.Outputs(), 1)
+	report := fmt.Sprint(msg.Outputs()[0]["data"])
+	assert.Contains(t, report, `<summary>Cell[3]: 1 error(s)</summary>`)
+	assert.Contains(t, report, `data-location="Cell[3]: Line 1, Column 25"`)
+	assert.Contains(t, report, `cannot use 1 (untyped int constant) as map[string]int value`)
+	assert.Contains(t, report, `var m map[string]int = <span class="gonb-err-col">1</span>`)
+}
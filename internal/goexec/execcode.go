@@ -6,16 +6,19 @@ import (
 	. "github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/internal/jpyexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/tracing"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/slices"
 	"io"
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // cellExecParams are the parameters of ExecuteCell, packaged so they
@@ -59,8 +62,17 @@ func (s *State) serializeExecuteCell() {
 		select {
 		case params := <-s.cellExecChan:
 			// Received new execution request.
-			params.done.Trigger(s.executeCellImpl(
-				params.msg, params.cellId, params.lines, params.skipLines))
+			s.cellSpan = tracing.Start("gonb.execute_cell").SetAttribute("gonb.cell_id", params.cellId)
+			s.reactivePending = MakeSet[int]()
+			err := s.executeCellImpl(params.msg, params.cellId, params.lines, params.skipLines)
+			if err == nil && s.Reactive {
+				err = s.reexecuteStaleCells(params.msg)
+			}
+			s.cellSpan.End(err)
+			s.stats.addExecution(err)
+			s.recordCellError(params.cellId, err)
+			s.cellSpan = nil
+			params.done.Trigger(err)
 
 		case <-stopC:
 			// Kernel stopped, exit.
@@ -75,31 +87,73 @@ func (s *State) serializeExecuteCell() {
 // ExecuteCell serializes the calls to this method.
 func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string, skipLines Set[int]) error {
 	klog.V(1).Infof("ExecuteCell: %q", lines)
+	s.publishUpdateNotice(msg)
 
 	defer s.PostExecuteCell()
+	s.cellTestResults = nil
 	klog.V(2).Infof("ExecuteCell(): CellIsTest=%v, CellIsWasm=%v", s.CellIsTest, s.CellIsWasm)
 	if s.CellIsTest && s.CellIsWasm {
 		return errors.Errorf("Cannot execute test in a %%wasm cell. Please, choose either `%%wasm` or `%%test`.")
 	}
+	if s.CellServe && (s.CellIsTest || s.CellIsWasm) {
+		return errors.Errorf("Cannot serve `%%test` or `%%wasm` cells. Please, choose either `%%serve` or the other.")
+	}
+	if s.CellShare && (s.CellIsTest || s.CellIsWasm || s.CellServe) {
+		return errors.Errorf("Cannot share `%%test`, `%%wasm` or `%%serve` cells in the Go Playground.")
+	}
+	if s.CellRunCLI && (s.CellIsTest || s.CellIsWasm || s.CellServe || s.CellShare) {
+		return errors.Errorf("Cannot use `%%run-cli` in `%%test`, `%%wasm`, `%%serve` or `%%share` cells.")
+	}
+	if s.TinyGoTarget != "" && (s.CellIsTest || s.CellIsWasm) {
+		return errors.Errorf("Cannot execute `%%test` or `%%wasm` cells with the TinyGo target %q, "+
+			"use `%%tinygo off` to go back to the standard toolchain.", s.TinyGoTarget)
+	}
+
+	if !s.CellIsTest && isSelection(lines, skipLines) {
+		klog.V(1).Infof("ExecuteCell: executing selection as the body of main()")
+		s.CellIsSelection = true
+		lines = printLastExpression(lines, skipLines)
+	}
 
-	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
-	err := s.AutoTrack()
+	// Wires local modules of the workspace into the temporary module.
+	span := s.cellSpan.Child("gonb.track")
+	err := s.AutoWorkspace(msg)
+	if err == nil {
+		// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+		err = s.AutoTrack()
+	}
+	span.End(err)
 	if err != nil {
 		return err
 	}
 
 	klog.V(2).Infof("ExecuteCell: after AutoTrack")
+	s.reportChangedSources(msg)
 
+	span = s.cellSpan.Child("gonb.parse").SetAttribute("gonb.num_lines", len(lines))
+	stopPhase := s.stats.startPhase(phaseParse)
 	updatedDecls, mainDecl, _, fileToCellIdAndLine, err := s.parseLinesAndComposeMain(msg, cellId, lines, skipLines, NoCursor)
+	stopPhase()
+	span.End(err)
+	var partialErr *PartialParseError
+	if errors.As(err, &partialErr) {
+		// Declarations that parsed correctly are still compiled and memorized, but the cell is not executed.
+		err = nil
+	}
 	if err != nil {
 		klog.Infof("goexec.ExecuteCell() failed to parse the cell: %+v", err)
 		return err
 	}
 	klog.V(2).Infof("ExecuteCell: after s.parseLinesAndComposeMain()")
+	s.recordComposed(fileToCellIdAndLine)
 
 	// ProgramExecutor `goimports` (or the code that implements it) -- it updates `updatedDecls` with
 	// the new imports, if there are any.
+	span = s.cellSpan.Child("gonb.goimports").SetAttribute("gonb.autoget", s.AutoGet)
+	stopPhase = s.stats.startPhase(phaseGoImports)
 	_, fileToCellIdAndLine, err = s.GoImports(msg, updatedDecls, mainDecl, fileToCellIdAndLine)
+	stopPhase()
+	span.End(err)
 
 	klog.V(2).Infof("ExecuteCell: after s.GoImports()")
 
@@ -107,20 +161,65 @@ func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string,
 		klog.Infof("goexec.ExecuteCell() failed to run `go imports` and `go get`: %+v", err)
 		return err
 	}
+	s.recordComposed(fileToCellIdAndLine)
+	if s.EchoComposed {
+		if err := s.ShowComposed(msg); err != nil {
+			klog.Warningf("Failed to display the composed code: %+v", err)
+		}
+	}
 
 	// And then compile it.
-	if err := s.Compile(msg, fileToCellIdAndLine); err != nil {
+	span = s.cellSpan.Child("gonb.build").SetAttribute("gonb.test", s.CellIsTest).SetAttribute("gonb.wasm", s.CellIsWasm)
+	stopPhase = s.stats.startPhase(phaseCompile)
+	err = s.Compile(msg, fileToCellIdAndLine)
+	stopPhase()
+	span.End(err)
+	if err != nil {
 		klog.Infof("goexec.ExecuteCell() failed to compile cell: %+v", err)
+		s.recordBuildFailure(cellId, updatedDecls)
 		return err
 	}
+	s.recordBuildSuccess()
 
 	klog.V(2).Infof("ExecuteCell: after s.Compile()")
 
-	// Compilation successful: save merged declarations into current State.
+	if partialErr != nil {
+		if !s.CellIsSelection && !s.CellShare {
+			s.Definitions = updatedDecls
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+				"* The declarations of the cell that parsed correctly were memorized, %d with errors were not; "+
+					"the cell was not executed.\n", partialErr.Skipped))
+		}
+		return partialErr
+	}
+
+	if s.CellShare {
+		// Shared instead of executed, and its declarations are not memorized.
+		return s.Share(msg)
+	}
+
+	// Compilation successful: save merged declarations into current State, except for selections.
+	if s.CellIsSelection {
+		updatedDecls = s.Definitions
+	}
+	for key := range updatedDecls.GenerateDirectives {
+		if _, found := s.Definitions.GenerateDirectives[key]; !found {
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+				fmt.Sprintf("* New directive `//go:generate %s` memorized: use `%%generate` to run it.\n", key))
+		}
+	}
+	if !s.CellIsSelection {
+		s.publishStaleCells(msg, cellId, lines, skipLines, updatedDecls)
+	}
 	s.Definitions = updatedDecls
 
 	// Execute compiled code.
-	return s.Execute(msg, fileToCellIdAndLine)
+	span = s.cellSpan.Child("gonb.run")
+	stopPhase = s.stats.startPhase(phaseRun)
+	err = s.Execute(msg, fileToCellIdAndLine)
+	stopPhase()
+	span.End(err)
+	return err
 }
 
 // PostExecuteCell reset state that is valid only for the duration of a cell.
@@ -136,13 +235,23 @@ func (s *State) PostExecuteCell() {
 	s.CellIsTest = false
 	s.CellTests = nil
 	s.CellHasBenchmarks = false
+	s.CellCoverage = false
+	s.CellServe = false
+	s.CellServeGRPC = false
+	s.CellShare = false
+	s.CellRunCLI = false
+	s.CellIsSelection = false
 	s.CellIsWasm = false
+	s.CellWasmIframe = false
 	s.WasmDivId = ""
 }
 
 // BinaryPath is the path to the generated binary file.
 func (s *State) BinaryPath() string {
-	return path.Join(s.TempDir, s.Package)
+	if runtime.GOOS == "windows" {
+		return filepath.Join(s.TempDir, s.Package+".exe")
+	}
+	return filepath.Join(s.TempDir, s.Package)
 }
 
 const (
@@ -156,14 +265,14 @@ func (s *State) CodePath() string {
 	if s.CellIsTest {
 		name = MainTestGo
 	}
-	return path.Join(s.TempDir, name)
+	return filepath.Join(s.TempDir, name)
 }
 
 // RemoveCode removes the code files (`main.go` or `main_test.go`).
 // Usually used just before creating creating a new version.
 func (s *State) RemoveCode() error {
 	for _, name := range [2]string{MainGo, MainTestGo} {
-		p := path.Join(s.TempDir, name)
+		p := filepath.Join(s.TempDir, name)
 		err := os.Remove(p)
 		if err != nil && !os.IsNotExist(err) {
 			return errors.Wrapf(err, "can't remove previously generated code in %q", p)
@@ -175,26 +284,103 @@ func (s *State) RemoveCode() error {
 // AlternativeDefinitionsPath is the path to a temporary file that holds the memorize definitions,
 // when we are not able to include them in the `main.go`, because the current cell is not parseable.
 func (s *State) AlternativeDefinitionsPath() string {
-	return path.Join(s.TempDir, "other.go")
+	return filepath.Join(s.TempDir, "other.go")
 }
 
 func (s *State) Execute(msg kernel.Message, fileToCellIdAndLine []CellIdAndLine) error {
 	if s.CellIsWasm {
 		return s.ExecuteWasm(msg)
 	}
+	if s.TinyGoTarget != "" {
+		// Firmware is not executed locally, see State.Flash.
+		return nil
+	}
+	if s.CellServe {
+		return s.Serve(msg)
+	}
 	args := s.Args
 	if len(args) == 0 && s.CellIsTest {
 		args = s.DefaultCellTestArgs()
 	}
-	err := jpyexec.New(msg, s.BinaryPath(), args...).
-		UseNamedPipes(s.Comms).
-		ExecutionCount(msg.Kernel().ExecCounter).
-		WithStderr(newJupyterStackTraceMapperWriter(msg, "stderr", s.CodePath(), fileToCellIdAndLine)).
-		Exec()
+	coverage := s.CellIsTest && s.CellCoverage
+	if coverage {
+		_ = os.Remove(s.CoverProfilePath())
+		args = append(slices.Clone(args), "-test.coverprofile="+s.CoverProfilePath())
+	}
+	command := s.BinaryPath()
+	capturePostMortem := PostMortemEnabled()
+	if capturePostMortem {
+		command, args = postMortemCommand(command, args)
+	}
+	startTime := time.Now()
+	var stdout io.Writer = kernel.NewJupyterStreamWriter(msg, kernel.StreamStdout)
+	pager := s.newPagerWriter(stdout, msg.Kernel().ExecCounter)
+	if pager != nil {
+		stdout = pager
+	}
+	if s.CellIsTest {
+		s.cellTestResults = &TestResults{}
+		stdout = io.MultiWriter(stdout, &testResultsWriter{results: s.cellTestResults})
+	}
+	tail := &stderrTail{}
+	trace := &stackTraceCollector{}
+	stderr := io.MultiWriter(newJupyterStackTraceMapperWriter(msg, "stderr", s.CodePath(), fileToCellIdAndLine), tail, trace)
+	var logs *logCollector
+	if s.LogView {
+		logs = &logCollector{}
+		stdout, stderr = logs.writer("stdout", stdout), logs.writer("stderr", stderr)
+	}
+	// Starting a freshly built binary may fail with "text file busy", in which case it is retried. Errors
+	// after the program started are not retried, since the program could have side effects.
+	var executor *jpyexec.Executor
+	var startedErr error
+	_, err := s.withRetries(msg, "Starting the program", func() (string, error) {
+		executor = jpyexec.New(msg, command, args...).
+			UseNamedPipes(s.Comms).
+			WithDisplayRateLimit(s.DisplayMaxFPS).
+			WithBinaryOutput(s.BinaryOutputsPath()).
+			ExecutionCount(msg.Kernel().ExecCounter).
+			WithStdout(stdout).
+			WithStderr(stderr).
+			WithEnv(append(append(s.CellSecretsEnv(), s.seedEnv()...), s.chartSnapshotEnv()...))
+		err := executor.Exec()
+		if err != nil && executor.ProcessState() != nil {
+			startedErr = err
+			return "", nil
+		}
+		return "", err
+	})
+	if startedErr != nil {
+		err = startedErr
+	}
+	if pager != nil {
+		s.finishPager(msg, pager)
+	}
+	if logs != nil {
+		if publishErr := logs.publish(msg); publishErr != nil {
+			klog.Warningf("Failed to publish the log records: %+v", publishErr)
+		}
+	}
+	s.publishResourceReport(msg, executor.ProcessState(), startTime)
 	if err != nil {
 		klog.Infof("goexec.Execute(): failed to run the compiled cell: %+v", msg)
+		return err
 	}
-	return err
+	if state := executor.ProcessState(); state != nil && !state.Success() && !msg.Kernel().Interrupted.Load() {
+		s.publishStackTrace(msg, trace, fileToCellIdAndLine)
+	}
+	if capturePostMortem {
+		s.capturePostMortem(msg, executor.ProcessState(), startTime)
+	}
+	if state := executor.ProcessState(); s.CellRunCLI && state != nil && state.ExitCode() > 0 {
+		return errors.Errorf("%%run-cli: the command exited with status %d", state.ExitCode())
+	}
+	if coverage {
+		if err := s.publishCoverage(msg, fileToCellIdAndLine); err != nil {
+			return err
+		}
+	}
+	return s.exitStatusError(executor.ProcessState(), msg.Kernel().Interrupted.Load(), tail, fileToCellIdAndLine)
 }
 
 // Compile compiles the currently generate go files in State.TempDir to a binary named State.Package.
@@ -202,17 +388,22 @@ func (s *State) Execute(msg kernel.Message, fileToCellIdAndLine []CellIdAndLine)
 // If errors in compilation happen, linesPos is used to adjust line numbers to their content in the
 // current cell.
 func (s *State) Compile(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine) error {
+	if s.TinyGoTarget != "" {
+		return s.compileTinyGo(msg, fileToCellIdAndLines)
+	}
 	var args []string
 	if s.CellIsTest {
 		args = []string{"test", "-c", "-o", s.BinaryPath()}
+		if s.CellCoverage {
+			args = append(args, "-cover", "-covermode=count")
+		}
 	} else if s.CellIsWasm {
-		args = []string{"build", "-o", path.Join(s.WasmDir, CompiledWasmName)}
+		args = []string{"build", "-o", s.CompiledWasmPath()}
 	} else {
 		args = []string{"build", "-o", s.BinaryPath()}
 	}
 	args = append(args, s.GoBuildFlags...)
-	cmd := exec.Command("go", args...)
-	cmd.Dir = s.TempDir
+	cmd := s.goCommand(args...)
 	if s.CellIsWasm {
 		// Set GOARCH and GOOS in cmd.Env.
 		cmd.Env = append(
@@ -226,13 +417,23 @@ func (s *State) Compile(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine
 	}
 
 	var output []byte
-	klog.V(2).Infof("Executing %s", cmd)
-	output, err := cmd.CombinedOutput()
+	output, err := s.combinedOutputWithRetries(msg, cmd)
+	if err != nil && s.autoGetMissingPackages(msg, string(output)) {
+		// Retry once, after fetching the missing packages.
+		env := cmd.Env
+		cmd = s.goCommand(args...)
+		cmd.Env = env
+		output, err = s.combinedOutputWithRetries(msg, cmd)
+	}
 	if err != nil {
 		klog.Errorf("Failed %q:\n%s\n", cmd, output)
-		err := s.DisplayErrorWithContext(msg, fileToCellIdAndLines, string(output), err)
+		s.lastBuildOutput = string(output)
+		err := s.DisplayErrorWithContext(msg, fileToCellIdAndLines,
+			s.mapGoPackageReferences(s.mapCSourceReferences(s.filterWarnings(string(output)))), err)
+		s.publishModuleFetchDiagnosis(msg, string(output))
 		return errors.Wrapf(err, "failed to run %q", cmd)
 	}
+	s.publishBuildWarnings(msg, string(output))
 	return nil
 }
 
@@ -258,9 +459,12 @@ can install it from the notebook with:
 	}
 	cmd := exec.Command(goimportsPath, "-w", s.CodePath())
 	cmd.Dir = s.TempDir
+	if env := s.GoEnv(); len(env) > 0 {
+		// goimports uses the `go` tool to resolve packages.
+		cmd.Env = append(cmd.Environ(), env...)
+	}
 	var output []byte
-	klog.V(2).Infof("Executing %s", cmd)
-	output, err = cmd.CombinedOutput()
+	output, err = s.combinedOutputWithRetries(msg, cmd)
 	if err != nil {
 		err = s.DisplayErrorWithContext(msg, fileToCellIdAndLine, string(output)+"\n"+err.Error(), err)
 		err = errors.Wrapf(err, "failed to run %q", cmd.String())
@@ -309,10 +513,8 @@ can install it from the notebook with:
 	if s.CellIsTest {
 		args = append(args, "-t")
 	}
-	cmd = exec.Command("go", args...)
-	cmd.Dir = s.TempDir
-	klog.V(2).Infof("Executing %s", cmd)
-	output, err = cmd.CombinedOutput()
+	cmd = s.goCommand(args...)
+	output, err = s.combinedOutputWithRetries(msg, cmd)
 	if err != nil {
 		err = errors.Wrapf(err, "failed to run %q", cmd.String())
 		strOutput := fmt.Sprintf("%v\n\n%s", err, output)
@@ -0,0 +1,115 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+)
+
+// This file reports the exit status of the cell program: a program that exits with a non-zero status (e.g.:
+// `os.Exit(1)`, `log.Fatal(...)` or a panic) marks the cell as failed, with the reason it printed to stderr,
+// instead of simply stopping its output.
+
+// maxStderrTail is the number of bytes of the end of the stderr of the program kept by stderrTail.
+const maxStderrTail = 4096
+
+// stderrTail is an io.Writer that keeps the end of what is written to it, to report the reason the program
+// exited (e.g.: the message of `log.Fatal`).
+type stderrTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > maxStderrTail {
+		t.buf = t.buf[len(t.buf)-maxStderrTail:]
+	}
+	return len(p), nil
+}
+
+// Reason returns the line that most likely explains why the program exited: the `panic: ...` line, if the
+// program panicked, otherwise the last non-empty line written.
+func (t *stderrTail) Reason() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := strings.Split(strings.TrimRight(string(t.buf), " \t\r\n"), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "panic: ") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// reExitSkipsDefers matches calls that exit the program without running the deferred functions.
+var reExitSkipsDefers = regexp.MustCompile(`\b(os\.Exit\(|log\.Fatal|klog\.Fatal|klog\.Exit)`)
+
+// exitSkipsDefersHint returns a hint that `os.Exit` doesn't run deferred functions, if the code uses both.
+func exitSkipsDefersHint(code string) string {
+	if !reExitSkipsDefers.MatchString(code) || !strings.Contains(code, "defer ") {
+		return ""
+	}
+	return "note: os.Exit (also called by log.Fatal) terminates the program immediately, without running deferred functions"
+}
+
+// userCode returns the lines of the program (State.CodePath) that come from the cells, according to
+// fileToCellIdAndLine, leaving out the code generated by the kernel, like the preamble of `func main()`.
+func (s *State) userCode(fileToCellIdAndLine []CellIdAndLine) string {
+	code, err := os.ReadFile(s.CodePath())
+	if err != nil {
+		return ""
+	}
+	generated := MakeSet[string]()
+	for _, line := range strings.Split(s.mainPreamble(), "\n") {
+		generated.Insert(strings.TrimSpace(line))
+	}
+	var sb strings.Builder
+	for ii, line := range strings.Split(string(code), "\n") {
+		if ii < len(fileToCellIdAndLine) && fileToCellIdAndLine[ii].Line != NoCursorLine &&
+			!generated.Has(strings.TrimSpace(line)) {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// exitStatusError returns an error if the cell program exited with a non-zero status, or was killed by a
+// signal, with the line of its stderr that explains why (see stderrTail.Reason). It returns nil if the program
+// succeeded, or if it was interrupted by the user.
+//
+// If the code of the cells (see State.userCode) defers functions and calls `os.Exit`, a hint that the deferred
+// functions were not executed is added. Not for the exit status 2 of panics, which do execute them.
+func (s *State) exitStatusError(processState *os.ProcessState, interrupted bool, tail *stderrTail,
+	fileToCellIdAndLine []CellIdAndLine) error {
+	if processState == nil || processState.Success() || interrupted {
+		return nil
+	}
+	var errMsg string
+	signaled := false
+	if status, ok := processState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		errMsg = fmt.Sprintf("process killed by signal %q", status.Signal())
+		signaled = true
+	} else {
+		errMsg = fmt.Sprintf("process exited with status %d", processState.ExitCode())
+	}
+	if reason := tail.Reason(); reason != "" {
+		errMsg += ": " + reason
+	}
+	if !signaled && processState.ExitCode() != 2 {
+		if hint := exitSkipsDefersHint(s.userCode(fileToCellIdAndLine)); hint != "" {
+			errMsg += "\n" + hint
+		}
+	}
+	return errors.New(errMsg)
+}
@@ -0,0 +1,67 @@
+package goexec
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitStatusError(t *testing.T) {
+	s := &State{TempDir: t.TempDir()}
+	tail := &stderrTail{}
+	_, _ = tail.Write([]byte("starting\n2024/01/01 10:00:00 failed to open "))
+	_, _ = tail.Write([]byte("data.csv\n\n"))
+	assert.Equal(t, "2024/01/01 10:00:00 failed to open data.csv", tail.Reason())
+	panicked := &stderrTail{}
+	_, _ = panicked.Write([]byte("panic: runtime error: index out of range [3] with length 2\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/main.go:5 +0x1d\n"))
+	assert.Equal(t, "panic: runtime error: index out of range [3] with length 2", panicked.Reason())
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	require.Error(t, cmd.Run())
+	err := s.exitStatusError(cmd.ProcessState, false, tail, nil)
+	require.Error(t, err)
+	assert.Equal(t, "process exited with status 3: 2024/01/01 10:00:00 failed to open data.csv", err.Error())
+
+	// Interrupted by the user: not an error.
+	require.NoError(t, s.exitStatusError(cmd.ProcessState, true, tail, nil))
+
+	// Hint that deferred functions are not executed.
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte("func main() {\n\tdefer f.Close()\n\tlog.Fatal(err)\n}\n"), 0600))
+	fileToCellIdAndLine := MakeFileToCellIdAndLine(1, []int{0, 1, 2, 3})
+	err = s.exitStatusError(cmd.ProcessState, false, &stderrTail{}, fileToCellIdAndLine)
+	require.Error(t, err)
+	assert.Equal(t, "process exited with status 3\n"+exitSkipsDefersHint("defer os.Exit("), err.Error())
+
+	// Panics (exit status 2) execute the deferred functions.
+	cmd = exec.Command("sh", "-c", "exit 2")
+	require.Error(t, cmd.Run())
+	err = s.exitStatusError(cmd.ProcessState, false, &stderrTail{}, fileToCellIdAndLine)
+	require.Error(t, err)
+	assert.Equal(t, "process exited with status 2", err.Error())
+
+	// The functions deferred by the code generated by the kernel are not considered.
+	s.LeakCheck = true
+	s.MainContext = true
+	code := s.mainPreamble() + "\tlog.Fatal(err)\n}\n"
+	require.Contains(t, code, "defer gonbLeakCheck()()")
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(code), 0600))
+	cmd = exec.Command("sh", "-c", "exit 1")
+	require.Error(t, cmd.Run())
+	fileToCellIdAndLine = MakeFileToCellIdAndLine(1, []int{0, 0, 0, 0, 0, 0, 1, 2})
+	err = s.exitStatusError(cmd.ProcessState, false, &stderrTail{}, fileToCellIdAndLine)
+	require.Error(t, err)
+	assert.Equal(t, "process exited with status 1", err.Error())
+
+	cmd = exec.Command("sh", "-c", "exit 0")
+	require.NoError(t, cmd.Run())
+	require.NoError(t, s.exitStatusError(cmd.ProcessState, false, tail, nil))
+
+	cmd = exec.Command("sh", "-c", "kill -9 $$")
+	require.Error(t, cmd.Run())
+	err = s.exitStatusError(cmd.ProcessState, false, &stderrTail{}, nil)
+	require.Error(t, err)
+	assert.Equal(t, `process killed by signal "killed"`, err.Error())
+}
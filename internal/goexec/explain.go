@@ -0,0 +1,213 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%explain <expr>`: it type-checks the expression against the memorized declarations, and
+// reports its static type, method set and where it is declared, without running any code.
+
+// Explain type-checks the Go expression expr in the scope of the memorized declarations, and displays what
+// it is (value, constant or type), its static type and underlying type, its method set, and where the
+// identifier it refers to and its type are declared (cell and line, for the memorized declarations).
+func (s *State) Explain(msg kernel.Message, expr string) error {
+	markdown, err := s.ExplainMarkdown(expr)
+	if err != nil {
+		return err
+	}
+	return kernel.PublishMarkdown(msg, markdown)
+}
+
+// ExplainMarkdown returns the explanation of the expression displayed by Explain, in Markdown.
+func (s *State) ExplainMarkdown(expr string) (string, error) {
+	c, err := s.checkDeclarations("%explain")
+	if err != nil {
+		return "", err
+	}
+	exprAst, err := parser.ParseExprFrom(c.fset, "expr", expr, 0)
+	if err != nil {
+		return "", errors.Errorf("%%explain: invalid expression %q: %v", expr, err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	if err = types.CheckExpr(c.fset, c.pkg, c.filePos, exprAst, info); err != nil {
+		return "", errors.Errorf("%%explain %s: %v", expr, err)
+	}
+	return c.explanation(expr, exprAst, info), nil
+}
+
+// checkedDeclarations holds the memorized declarations type-checked with go/types, used by `%explain`,
+// `%implements` and `%satisfies`.
+type checkedDeclarations struct {
+	fset                *token.FileSet
+	pkg                 *types.Package
+	filePos             token.Pos // Position in the scope of the file, where the imports are visible.
+	qualifier           types.Qualifier
+	mainPath            string
+	fileToCellIdAndLine []CellIdAndLine
+}
+
+// checkDeclarations composes `main.go` with the memorized declarations, and type-checks it (as much as
+// possible) for the given command.
+func (s *State) checkDeclarations(command string) (*checkedDeclarations, error) {
+	var buf bytes.Buffer
+	_, fileToCellIdAndLine, err := s.createCodeFromDecls(&buf, s.Definitions, nil)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to compose the memorized declarations")
+	}
+	fset := token.NewFileSet()
+	pkg, err := s.typeCheckSource(fset, buf.String(), command)
+	if err != nil {
+		return nil, err
+	}
+	c := &checkedDeclarations{
+		fset: fset, pkg: pkg, qualifier: packageQualifier(pkg),
+		mainPath: path.Join(s.TempDir, MainGo), fileToCellIdAndLine: fileToCellIdAndLine,
+	}
+	fset.Iterate(func(file *token.File) bool {
+		if file.Name() == c.mainPath {
+			c.filePos = token.Pos(file.Base())
+			return false
+		}
+		return true
+	})
+	return c, nil
+}
+
+// position returns a human-readable reference to where obj is declared.
+func (c *checkedDeclarations) position(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return "predeclared"
+	}
+	if !obj.Pos().IsValid() {
+		return fmt.Sprintf("package `%s`", obj.Pkg().Path())
+	}
+	pos := c.fset.Position(obj.Pos())
+	if pos.Filename == c.mainPath {
+		return cellReference(pos.Line-1, c.fileToCellIdAndLine)
+	}
+	return fmt.Sprintf("package `%s`, `%s:%d`", obj.Pkg().Path(), filepath.Base(pos.Filename), pos.Line)
+}
+
+// typeString returns the type in Markdown inline code.
+func (c *checkedDeclarations) typeString(t types.Type) string {
+	return "`" + types.TypeString(t, c.qualifier) + "`"
+}
+
+// explanation returns the explanation of the type-checked expression, in Markdown.
+func (c *checkedDeclarations) explanation(expr string, exprAst ast.Expr, info *types.Info) string {
+	var sb strings.Builder
+	tv := info.Types[exprAst]
+	w := func(format string, args ...any) { sb.WriteString(fmt.Sprintf(format, args...)) }
+	switch {
+	case tv.IsType():
+		w("`%s` is the type %s\n\n", expr, c.typeString(tv.Type))
+	case tv.IsBuiltin():
+		w("`%s` is a built-in function\n\n", expr)
+		return sb.String()
+	case tv.IsVoid():
+		w("`%s` has no value\n\n", expr)
+		return sb.String()
+	case tv.Value != nil:
+		w("`%s` is a constant of type %s, with value `%s`\n\n", expr, c.typeString(tv.Type), tv.Value.ExactString())
+	default:
+		w("`%s` is a value of type %s\n\n", expr, c.typeString(tv.Type))
+	}
+	if tv.Type == nil {
+		return sb.String()
+	}
+	if underlying := tv.Type.Underlying(); !types.Identical(underlying, tv.Type) {
+		w("* **Underlying type:** %s\n", c.typeString(underlying))
+	}
+
+	// Declaration of the identifier (or selected field or method) referred to by the expression.
+	var obj types.Object
+	node := exprAst
+	for paren, ok := node.(*ast.ParenExpr); ok; paren, ok = node.(*ast.ParenExpr) {
+		node = paren.X
+	}
+	switch node := node.(type) {
+	case *ast.Ident:
+		obj = info.Uses[node]
+	case *ast.SelectorExpr:
+		if selection := info.Selections[node]; selection != nil {
+			obj = selection.Obj()
+		} else {
+			obj = info.Uses[node.Sel] // Qualified identifier, e.g.: `fmt.Println`.
+		}
+	}
+	if obj != nil && obj.Pos().IsValid() {
+		w("* **Declared:** %s\n", c.position(obj))
+	}
+
+	// Declaration of the type.
+	named := tv.Type
+	if ptr, ok := named.(*types.Pointer); ok {
+		named = ptr.Elem()
+	}
+	n, isNamed := named.(*types.Named)
+	if isNamed && n.Obj() != obj {
+		w("* **Type %s declared:** %s\n", c.typeString(n), c.position(n.Obj()))
+	}
+
+	// Method sets: of the type, and the additional ones of its pointer. The (empty) method sets of unnamed
+	// types, like functions or slices, are omitted.
+	methods := types.NewMethodSet(tv.Type)
+	if methods.Len() == 0 && !isNamed && !types.IsInterface(tv.Type) {
+		return sb.String()
+	}
+	w("* **Method set of %s:**%s\n", c.typeString(tv.Type), c.methodList(methodSelections(methods)))
+	if _, isPointer := tv.Type.(*types.Pointer); !isPointer && !types.IsInterface(tv.Type) {
+		ptrType := types.NewPointer(tv.Type)
+		ptrMethods := types.NewMethodSet(ptrType)
+		if ptrMethods.Len() > methods.Len() {
+			var extra []*types.Selection
+			for ii := 0; ii < ptrMethods.Len(); ii++ {
+				if sel := ptrMethods.At(ii); methods.Lookup(sel.Obj().Pkg(), sel.Obj().Name()) == nil {
+					extra = append(extra, sel)
+				}
+			}
+			w("* **Additional methods of %s:**%s\n", c.typeString(ptrType), c.methodList(extra))
+		}
+	}
+	return sb.String()
+}
+
+// methodSelections returns the methods of the method set.
+func methodSelections(methods *types.MethodSet) []*types.Selection {
+	selections := make([]*types.Selection, 0, methods.Len())
+	for ii := 0; ii < methods.Len(); ii++ {
+		selections = append(selections, methods.At(ii))
+	}
+	return selections
+}
+
+// methodList renders the methods as a nested Markdown list, one per line, with their signatures.
+func (c *checkedDeclarations) methodList(selections []*types.Selection) string {
+	if len(selections) == 0 {
+		return " empty"
+	}
+	var sb strings.Builder
+	for _, sel := range selections {
+		signature := strings.TrimPrefix(types.TypeString(sel.Obj().Type(), c.qualifier), "func")
+		sb.WriteString(fmt.Sprintf("\n  * `%s%s`", sel.Obj().Name(), signature))
+		if len(sel.Index()) > 1 {
+			sb.WriteString(" (promoted from an embedded field)")
+		}
+	}
+	return sb.String()
+}
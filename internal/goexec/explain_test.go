@@ -0,0 +1,70 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	cellCode := `import "strings"
+
+type Point struct {
+	X, Y int
+}
+
+func (p Point) String() string { return "" }
+
+func (p *Point) Move(dx, dy int) { p.X += dx; p.Y += dy }
+
+const Limit = 10 * 2
+
+var origin = &Point{}
+
+var builder strings.Builder
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 3, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	s.Definitions, err = s.parseFromGoCode(nil, 3, NoCursor, MakeFileToCellIdAndLine(3, fileToCellLine))
+	require.NoError(t, err)
+
+	markdown, err := s.ExplainMarkdown("origin")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "`origin` is a value of type `*Point`")
+	assert.Contains(t, markdown, "* **Declared:** Cell[3]: Line 13\n")
+	assert.Contains(t, markdown, "* **Type `Point` declared:** Cell[3]: Line 3\n")
+	assert.Contains(t, markdown, "* **Method set of `*Point`:**\n  * `Move(dx int, dy int)`\n  * `String() string`\n")
+
+	markdown, err = s.ExplainMarkdown("Point")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "`Point` is the type `Point`")
+	assert.Contains(t, markdown, "* **Underlying type:** `struct{X int; Y int}`\n")
+	assert.Contains(t, markdown, "* **Method set of `Point`:**\n  * `String() string`\n")
+	assert.Contains(t, markdown, "* **Additional methods of `*Point`:**\n  * `Move(dx int, dy int)`\n")
+
+	markdown, err = s.ExplainMarkdown("Limit / 3")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "is a constant of type `untyped int`, with value `6`")
+
+	markdown, err = s.ExplainMarkdown("builder.WriteString")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "is a value of type `func(s string) (int, error)`")
+	assert.Contains(t, markdown, "* **Declared:** package `strings`, `builder.go:")
+	assert.NotContains(t, markdown, "Method set")
+
+	markdown, err = s.ExplainMarkdown("strings.ToUpper")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "is a value of type `func(s string) string`")
+
+	_, err = s.ExplainMarkdown("origin.Z")
+	assert.Error(t, err)
+	_, err = s.ExplainMarkdown("origin +")
+	assert.Error(t, err)
+}
@@ -0,0 +1,349 @@
+package goexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	lsp "github.com/go-language-server/protocol"
+	"github.com/janpfeifer/gonb/internal/goexec/goplsclient"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements applying code actions (quick-fixes) offered by `gopls` to the memorized
+// declarations. It's connected to the special command `%fix`.
+
+// Fix composes `main.go` with the memorized declarations and asks `gopls` for its diagnostics and
+// the code actions available to fix them.
+//
+// If fixIdx < 0, it lists the diagnostics and the available fixes, numbered from 1.
+// Otherwise, it applies the fix indexed by fixIdx (0-based) to the memorized declarations,
+// and reports what changed.
+func (s *State) Fix(msg kernel.Message, fixIdx int) (err error) {
+	if s.gopls == nil {
+		return errors.New("`gopls` is not installed, it is required for %fix")
+	}
+
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+
+	fileToCellIdAndLine, err := s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+
+	// Query `gopls`.
+	ctx := context.Background()
+	err = s.notifyAboutStandardAndTrackedFiles(ctx)
+	if err != nil {
+		return
+	}
+	var diagnostics []lsp.Diagnostic
+	var actions []*goplsclient.CodeAction
+	_, err = s.withRetries(msg, "`gopls` quick-fixes", func() (string, error) {
+		var err error
+		diagnostics, actions, err = s.gopls.QuickFixes(ctx, s.CodePath())
+		_ = s.gopls.ConsumeMessages()
+		return "", err
+	})
+	if err != nil {
+		return
+	}
+	if fixIdx < 0 {
+		return kernel.PublishMarkdown(msg, listFixes(diagnostics, actions, fileToCellIdAndLine))
+	}
+	if fixIdx >= len(actions) {
+		return errors.Errorf("fix #%d not available, there are only %d fixes -- use `%%fix` to list them",
+			fixIdx+1, len(actions))
+	}
+
+	// Apply fix.
+	action := actions[fixIdx]
+	edits, err := s.gopls.Edits(ctx, s.CodePath(), action)
+	if err != nil {
+		return
+	}
+	if len(edits) == 0 {
+		return errors.Errorf("fix %q has no edits to apply to the memorized declarations", action.Title)
+	}
+	changes, err := s.applyEditsToDeclarations(edits, nil)
+	if err != nil {
+		return errors.WithMessagef(err, "applying fix %q", action.Title)
+	}
+	return kernel.PublishMarkdown(msg, reportFix(action, changes))
+}
+
+// composeMemorizedDeclarations writes `main.go` with the memorized declarations, and a stub `main`
+// function. It returns the mapping of the file lines to the cells.
+func (s *State) composeMemorizedDeclarations(msg kernel.Message) (fileToCellIdAndLine []CellIdAndLine, err error) {
+	mainDecl := &Function{
+		Cursor:     NoCursor,
+		Key:        "main",
+		Name:       "main",
+		Definition: "func main() { flag.Parse() }",
+	}
+	decls := s.Definitions.Copy()
+	decls.ClearCursor()
+	_, fileToCellIdAndLine, err = s.createCodeFileFromDecls(decls, mainDecl)
+	if err != nil {
+		err = errors.WithMessagef(err, "while composing main.go with all declarations")
+		return
+	}
+	_, fileToCellIdAndLine, err = s.GoImports(msg, decls, mainDecl, fileToCellIdAndLine)
+	if err != nil {
+		err = errors.WithMessagef(err, "goimports failed")
+	}
+	return
+}
+
+// applyEditsToDeclarations applies the `gopls` edits to the current `main.go` (presumably created with
+// composeMemorizedDeclarations), and updates the memorized declarations accordingly.
+//
+// If renamed is given, it is called with the declarations parsed before the edits, and should update
+// them (and the memorized declarations) for any declaration that changed keys.
+//
+// It returns a human-readable list of the changes.
+func (s *State) applyEditsToDeclarations(edits []lsp.TextEdit, renamed func(before *Declarations) []string) (changes []string, err error) {
+	before, err := s.parseFromGoCode(nil, NoCursorLine, NoCursor, nil)
+	if err != nil {
+		return
+	}
+	content, err := s.readMainGo()
+	if err != nil {
+		return
+	}
+	content, err = applyTextEdits(content, edits)
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(s.CodePath(), []byte(content), 0600)
+	if err != nil {
+		err = errors.Wrapf(err, "writing updated %q", s.CodePath())
+		return
+	}
+	after, err := s.parseFromGoCode(nil, NoCursorLine, NoCursor, nil)
+	if err != nil {
+		err = errors.WithMessagef(err, "parsing the edited %q", s.CodePath())
+		return
+	}
+	if renamed != nil {
+		changes = renamed(before)
+	}
+	changes = append(changes, s.Definitions.applyChanges(before, after)...)
+	return
+}
+
+// cellReference returns a human-readable reference to the cell line corresponding to the line in `main.go`.
+func cellReference(fileLine int, fileToCellIdAndLine []CellIdAndLine) string {
+	if fileLine >= 0 && fileLine < len(fileToCellIdAndLine) {
+		if cell := fileToCellIdAndLine[fileLine]; cell.Id >= 0 && cell.Line != NoCursorLine {
+			return fmt.Sprintf("Cell[%d]: Line %d", cell.Id, cell.Line+1)
+		}
+	}
+	return fmt.Sprintf("main.go: Line %d", fileLine+1)
+}
+
+// listFixes renders in Markdown the diagnostics and the numbered fixes available for them.
+func listFixes(diagnostics []lsp.Diagnostic, actions []*goplsclient.CodeAction, fileToCellIdAndLine []CellIdAndLine) string {
+	if len(diagnostics) == 0 {
+		return "No diagnostics reported by `gopls` for the memorized declarations.\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("**Diagnostics and fixes:**\n\n")
+	actionIdx := 0
+	for _, diag := range diagnostics {
+		sb.WriteString(fmt.Sprintf("* `%s`: %s\n", cellReference(int(diag.Range.Start.Line), fileToCellIdAndLine), diag.Message))
+		hasFix := false
+		for ; actionIdx < len(actions) && actions[actionIdx].Diagnostic.Range == diag.Range &&
+			actions[actionIdx].Diagnostic.Message == diag.Message; actionIdx++ {
+			sb.WriteString(fmt.Sprintf("  %d. %s\n", actionIdx+1, actions[actionIdx].Title))
+			hasFix = true
+		}
+		if !hasFix {
+			sb.WriteString("  * _(no fixes available)_\n")
+		}
+	}
+	if len(actions) > 0 {
+		sb.WriteString("\nUse `%fix <number>` to apply one of the fixes.\n")
+	}
+	return sb.String()
+}
+
+// reportFix renders in Markdown the changes to the memorized declarations by the fix.
+func reportFix(action *goplsclient.CodeAction, changes []string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Applied fix: **%s**\n\n", action.Title))
+	if len(changes) == 0 {
+		sb.WriteString("* No changes to memorized declarations.\n")
+	}
+	for _, change := range changes {
+		sb.WriteString(fmt.Sprintf("* %s\n", change))
+	}
+	return sb.String()
+}
+
+// applyChanges updates the memorized declarations with the changes from before to after: these are the
+// declarations parsed from `main.go` before and after some edit.
+//
+// The cell lines information of the memorized declarations is preserved.
+// It returns a human-readable list of the changes.
+//
+// Functions `main` and `init` (rendered from `init_*` functions) and anonymous variables (`var _ = ...`)
+// are not updated.
+func (d *Declarations) applyChanges(before, after *Declarations) (changes []string) {
+	changes = append(changes, applyMapChanges("import", d.Imports, before.Imports, after.Imports,
+		func(i *Import) string { return i.Alias + " " + i.Path },
+		func(i *Import) *CellLines { return &i.CellLines })...)
+	changes = append(changes, applyMapChanges("type", d.Types, before.Types, after.Types,
+		func(t *TypeDecl) string { return t.TypeDefinition },
+		func(t *TypeDecl) *CellLines { return &t.CellLines })...)
+	constantChanges := applyMapChanges("constant", d.Constants, before.Constants, after.Constants,
+		func(c *Constant) string { return c.TypeDefinition + "=" + c.ValueDefinition },
+		func(c *Constant) *CellLines { return &c.CellLines })
+	if len(constantChanges) > 0 {
+		// Constants are linked within their blocks, so they are all taken from after, to keep the
+		// links consistent.
+		for key, c := range after.Constants {
+			if old, found := d.Constants[key]; found && old != c {
+				c.CellLines = adjustCellLines(old.CellLines, len(c.Lines))
+			}
+		}
+		d.Constants = after.Constants
+		changes = append(changes, constantChanges...)
+	}
+	changes = append(changes, applyMapChanges("variable", d.Variables, before.Variables, after.Variables,
+		func(v *Variable) string { return v.TypeDefinition + "=" + v.ValueDefinition },
+		func(v *Variable) *CellLines { return &v.CellLines })...)
+	changes = append(changes, applyMapChanges("function", d.Functions, before.Functions, after.Functions,
+		func(f *Function) string { return f.Definition },
+		func(f *Function) *CellLines { return &f.CellLines })...)
+	return
+}
+
+// applyMapChanges implements Declarations.applyChanges for one type of declaration.
+func applyMapChanges[T any](kind string, memorized, before, after map[string]*T,
+	content func(*T) string, cellLines func(*T) *CellLines) (changes []string) {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, found := before[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "main" || key == "init" || strings.HasPrefix(key, "_~") {
+			continue
+		}
+		oldDecl, inBefore := before[key]
+		newDecl, inAfter := after[key]
+		switch {
+		case inBefore && !inAfter:
+			delete(memorized, key)
+			changes = append(changes, fmt.Sprintf("removed %s `%s`", kind, key))
+		case !inBefore && inAfter:
+			memorized[key] = newDecl
+			changes = append(changes, fmt.Sprintf("added %s `%s`", kind, key))
+		case content(oldDecl) != content(newDecl):
+			if memorizedDecl, found := memorized[key]; found {
+				newLines := cellLines(newDecl)
+				*newLines = adjustCellLines(*cellLines(memorizedDecl), len(newLines.Lines))
+			}
+			memorized[key] = newDecl
+			changes = append(changes, fmt.Sprintf("changed %s `%s`", kind, key))
+		}
+	}
+	return
+}
+
+// adjustCellLines returns a copy of cellLines adjusted to the given number of lines: extra lines
+// are dropped, and missing lines are filled with NoCursorLine.
+func adjustCellLines(cellLines CellLines, numLines int) CellLines {
+	adjusted := CellLines{Id: cellLines.Id, Lines: make([]int, numLines)}
+	for ii := range adjusted.Lines {
+		if ii < len(cellLines.Lines) {
+			adjusted.Lines[ii] = cellLines.Lines[ii]
+		} else {
+			adjusted.Lines[ii] = NoCursorLine
+		}
+	}
+	return adjusted
+}
+
+// lineStartsOf returns the byte offset of the start of each line of content.
+func lineStartsOf(content string) []int {
+	lineStarts := []int{0}
+	for ii := 0; ii < len(content); ii++ {
+		if content[ii] == '\n' {
+			lineStarts = append(lineStarts, ii+1)
+		}
+	}
+	return lineStarts
+}
+
+// lspPositionToOffset converts a position given in lines and UTF-16 characters, as defined by the
+// Language Server Protocol, to a byte offset in content. lineStarts is given by lineStartsOf(content).
+func lspPositionToOffset(content string, lineStarts []int, pos lsp.Position) (int, error) {
+	line := int(pos.Line)
+	if line < 0 || line >= len(lineStarts) {
+		return 0, errors.Errorf("position line %d out of range (%d lines)", line+1, len(lineStarts))
+	}
+	offset := lineStarts[line]
+	for utf16Count := 0; utf16Count < int(pos.Character); {
+		if offset >= len(content) || content[offset] == '\n' {
+			return 0, errors.Errorf("position %d:%d beyond end of line", line+1, int(pos.Character))
+		}
+		r, size := utf8.DecodeRuneInString(content[offset:])
+		utf16Count += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset, nil
+}
+
+// applyTextEdits applies the `gopls` edits to content. Positions in the edits are given
+// in lines and UTF-16 characters, as defined by the Language Server Protocol.
+func applyTextEdits(content string, edits []lsp.TextEdit) (string, error) {
+	lineStarts := lineStartsOf(content)
+	type byteEdit struct {
+		start, end int
+		newText    string
+	}
+	byteEdits := make([]byteEdit, 0, len(edits))
+	for _, edit := range edits {
+		start, err := lspPositionToOffset(content, lineStarts, edit.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := lspPositionToOffset(content, lineStarts, edit.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", errors.Errorf("invalid edit range %+v", edit.Range)
+		}
+		byteEdits = append(byteEdits, byteEdit{start, end, edit.NewText})
+	}
+
+	// Apply from the end of the file to its start, so offsets remain valid.
+	sort.SliceStable(byteEdits, func(i, j int) bool { return byteEdits[i].start > byteEdits[j].start })
+	for ii, edit := range byteEdits {
+		if ii > 0 && edit.end > byteEdits[ii-1].start {
+			return "", errors.Errorf("overlapping edits at offset %d", edit.start)
+		}
+		content = content[:edit.start] + edit.newText + content[edit.end:]
+		klog.V(2).Infof("applied edit [%d:%d] -> %q", edit.start, edit.end, edit.newText)
+	}
+	return content, nil
+}
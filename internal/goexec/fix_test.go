@@ -0,0 +1,69 @@
+package goexec
+
+import (
+	"testing"
+
+	lsp "github.com/go-language-server/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func textEdit(startLine, startChar, endLine, endChar int, newText string) lsp.TextEdit {
+	return lsp.TextEdit{
+		Range: lsp.Range{
+			Start: lsp.Position{Line: float64(startLine), Character: float64(startChar)},
+			End:   lsp.Position{Line: float64(endLine), Character: float64(endChar)},
+		},
+		NewText: newText,
+	}
+}
+
+func TestApplyTextEdits(t *testing.T) {
+	content := "func f() {\n\tx := 1\n\ty := \"☺☺\" + z\n}\n"
+
+	// Remove line, and replace after multi-byte characters (each "☺" is 1 UTF-16 character, but 3 bytes).
+	got, err := applyTextEdits(content, []lsp.TextEdit{
+		textEdit(1, 0, 2, 0, ""),
+		textEdit(2, 13, 2, 14, "w"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "func f() {\n\ty := \"☺☺\" + w\n}\n", got)
+
+	// Insertion.
+	got, err = applyTextEdits(content, []lsp.TextEdit{textEdit(0, 10, 0, 10, "\n\t_ = 0")})
+	require.NoError(t, err)
+	assert.Equal(t, "func f() {\n\t_ = 0\n\tx := 1\n\ty := \"☺☺\" + z\n}\n", got)
+
+	// Invalid edits.
+	_, err = applyTextEdits(content, []lsp.TextEdit{textEdit(10, 0, 10, 0, "")})
+	assert.Error(t, err)
+	_, err = applyTextEdits(content, []lsp.TextEdit{textEdit(1, 100, 1, 100, "")})
+	assert.Error(t, err)
+	_, err = applyTextEdits(content, []lsp.TextEdit{textEdit(1, 0, 1, 4, ""), textEdit(1, 2, 1, 5, "")})
+	assert.Error(t, err)
+}
+
+func TestApplyChanges(t *testing.T) {
+	memorized := NewDeclarations()
+	memorized.Functions["f"] = &Function{Key: "f", Definition: "func f() {\n\tx := 1\n}",
+		CellLines: CellLines{Id: 3, Lines: []int{0, 1, 2}}}
+	memorized.Functions["g"] = &Function{Key: "g", Definition: "func g() {}",
+		CellLines: CellLines{Id: 4, Lines: []int{0}}}
+	memorized.Variables["v"] = &Variable{Key: "v", Name: "v", ValueDefinition: "1"}
+	before := memorized.Copy()
+
+	after := NewDeclarations()
+	after.Functions["f"] = &Function{Key: "f", Definition: "func f() {\n}",
+		CellLines: CellLines{Id: NoCursorLine, Lines: []int{NoCursorLine, NoCursorLine}}}
+	after.Functions["g"] = before.Functions["g"]
+	after.Functions["main"] = &Function{Key: "main", Definition: "func main() {}"}
+	after.Imports["fmt"] = NewImport("fmt", "")
+
+	changes := memorized.applyChanges(before, after)
+	assert.Equal(t, []string{"added import `fmt`", "removed variable `v`", "changed function `f`"}, changes)
+	assert.Equal(t, CellLines{Id: 3, Lines: []int{0, 1}}, memorized.Functions["f"].CellLines)
+	assert.Equal(t, "func f() {\n}", memorized.Functions["f"].Definition)
+	assert.NotContains(t, memorized.Functions, "main")
+	assert.NotContains(t, memorized.Variables, "v")
+	assert.Contains(t, memorized.Imports, "fmt")
+}
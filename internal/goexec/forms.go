@@ -0,0 +1,66 @@
+package goexec
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the kernel side of the forms displayed with `gonbui.Form`: the values submitted are
+// saved to a file, read by `gonbui.FormValues` in the following cell executions.
+
+// FormValuesFile is the file, under State.TempDir, with the values submitted in the forms, by field name. It
+// is given to the cells in the environment variable protocol.GONB_FORM_VALUES_ENV.
+const FormValuesFile = "gonb_form_values.json"
+
+// initFormValues sets the environment variable pointing to the file with the values submitted in the forms.
+func (s *State) initFormValues() error {
+	return errors.Wrapf(os.Setenv(protocol.GONB_FORM_VALUES_ENV, path.Join(s.TempDir, FormValuesFile)),
+		"failed to set environment variable %q", protocol.GONB_FORM_VALUES_ENV)
+}
+
+// handleFormValues saves the values submitted in a form, sent to protocol.GonbuiFormAddress, registered with
+// comms.State.HandleAddressPrefix. The value of the message maps the names of the fields to their values;
+// they are merged with the ones submitted before, in other forms.
+func (s *State) handleFormValues(_ kernel.Message, address string, value any) {
+	submitted, ok := value.(map[string]any)
+	if !ok {
+		klog.Warningf("Invalid form values sent to %q: %v", address, value)
+		return
+	}
+	s.muInputs.Lock()
+	defer s.muInputs.Unlock()
+	if s.formValues == nil {
+		s.formValues = make(map[string]string)
+	}
+	for name, fieldValue := range submitted {
+		if str, ok := fieldValue.(string); ok {
+			s.formValues[name] = str
+		}
+	}
+	if err := s.saveFormValuesLocked(); err != nil {
+		klog.Warningf("Failed to save the values submitted in the form: %+v", err)
+	}
+}
+
+// saveFormValuesLocked writes the form values to FormValuesFile, or removes it if there are none.
+// It must be called with s.muInputs locked.
+func (s *State) saveFormValuesLocked() error {
+	filePath := path.Join(s.TempDir, FormValuesFile)
+	if len(s.formValues) == 0 {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %q", filePath)
+		}
+		return nil
+	}
+	contents, err := json.Marshal(s.formValues)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the form values")
+	}
+	return errors.Wrapf(os.WriteFile(filePath, contents, 0600), "failed to write %q", filePath)
+}
@@ -0,0 +1,36 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormValues(t *testing.T) {
+	t.Setenv(protocol.GONB_FORM_VALUES_ENV, "")
+	_, err := gonbui.FormValues()
+	assert.Error(t, err)
+
+	s := &State{TempDir: t.TempDir()}
+	require.NoError(t, s.initFormValues())
+	values, err := gonbui.FormValues()
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	s.handleFormValues(nil, protocol.GonbuiFormAddress, map[string]any{"name": "Ada", "age": "36"})
+	s.handleFormValues(nil, protocol.GonbuiFormAddress, map[string]any{"age": "37", "member": "true", "ignored": 1.0})
+	s.handleFormValues(nil, protocol.GonbuiFormAddress, "invalid")
+	values, err = gonbui.FormValues()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "Ada", "age": "37", "member": "true"}, values)
+
+	// Values removed, as in `%reset`.
+	s.formValues = nil
+	require.NoError(t, s.saveFormValuesLocked())
+	values, err = gonbui.FormValues()
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
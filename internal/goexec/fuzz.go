@@ -0,0 +1,219 @@
+package goexec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"k8s.io/klog/v2"
+)
+
+// This file implements fuzzing of fuzz targets (`func FuzzXxx(f *testing.F)`) defined in the notebook,
+// with `go test -fuzz`. It's connected to the special command `%fuzz`.
+
+// DefaultFuzzTime is the value of `-fuzztime` used by `%fuzz`, if none is given: otherwise `go test -fuzz`
+// would run until interrupted.
+const DefaultFuzzTime = "30s"
+
+// Fuzz runs `go test -fuzz` on the fuzz target fuzzTarget, defined in the memorized declarations. The
+// extra flags (e.g.: `-fuzztime=1m`) are passed to `go test`. The progress of the fuzzing is streamed to
+// the notebook.
+//
+// If the fuzzing finds a failing input, its minimized version (written by `go test` to the corpus in
+// `testdata/fuzz/<fuzzTarget>`) is displayed, along with a `%test` cell that reproduces the failure, and an
+// error is returned. It also returns an error if `go test -fuzz` fails for other reasons, e.g.: compilation
+// errors.
+func (s *State) Fuzz(msg kernel.Message, fuzzTarget string, flags []string) (err error) {
+	if !strings.HasPrefix(fuzzTarget, "Fuzz") {
+		return errors.Errorf("%%fuzz: %q is not a fuzz target, its name must start with `Fuzz`", fuzzTarget)
+	}
+	if _, found := s.Definitions.Functions[fuzzTarget]; !found {
+		return errors.Errorf("%%fuzz: fuzz target %q not found in memorized definitions -- see `%%ls`", fuzzTarget)
+	}
+
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+
+	// Fuzz targets are only recognized by `go test` in test files.
+	s.CellIsTest = true
+	defer func() { s.CellIsTest = false }()
+	_, err = s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+
+	corpusDir := path.Join(s.TempDir, "testdata", "fuzz", fuzzTarget)
+	before, err := listCorpus(corpusDir)
+	if err != nil {
+		return
+	}
+	args := []string{"test", "-run=^$", fmt.Sprintf("-fuzz=^%s$", fuzzTarget)}
+	if !slices.ContainsFunc(flags, func(flag string) bool {
+		return strings.HasPrefix(flag, "-fuzztime") || strings.HasPrefix(flag, "--fuzztime")
+	}) {
+		args = append(args, "-fuzztime="+DefaultFuzzTime)
+	}
+	args = append(args, flags...)
+	args = append(args, s.GoBuildFlags...)
+	klog.V(1).Infof("%%fuzz: executing go %v", args)
+	executor, err := s.goExecutor(msg, args...)
+	if err == nil {
+		err = executor.Exec()
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "`go test -fuzz` failed")
+	}
+	state := executor.ProcessState()
+	failed := state != nil && !state.Success()
+
+	after, err := listCorpus(corpusDir)
+	if err != nil {
+		return
+	}
+	var crashers []string
+	for _, name := range after {
+		if !slices.Contains(before, name) {
+			crashers = append(crashers, name)
+		}
+	}
+	var fuzzFn string
+	if len(crashers) > 0 {
+		var fuzzFnErr error
+		fuzzFn, fuzzFnErr = fuzzFunction(s.Definitions.Functions[fuzzTarget].Definition)
+		if fuzzFnErr != nil {
+			klog.Warningf("%%fuzz: can't generate cell reproducing failures: %v", fuzzFnErr)
+		}
+	}
+	for _, name := range crashers {
+		var contents []byte
+		contents, err = os.ReadFile(path.Join(corpusDir, name))
+		if err != nil {
+			return errors.Wrapf(err, "reading failing input %q", name)
+		}
+		err = kernel.PublishMarkdown(msg, fuzzCrasherReport(fuzzTarget, fuzzFn, name, string(contents)))
+		if err != nil {
+			return
+		}
+	}
+	if len(crashers) > 0 {
+		return errors.Errorf("%%fuzz: %d failing input(s) found for %s", len(crashers), fuzzTarget)
+	}
+	if failed {
+		return errors.Errorf("%%fuzz: `go test -fuzz` failed")
+	}
+	return nil
+}
+
+// fuzzCrasherReport returns the Markdown report of a failing input found by the fuzzer. If fuzzFn (the
+// function passed to `f.Fuzz`, see fuzzFunction) is known, it includes a `%test` cell that reproduces the
+// failure by calling it with the failing input.
+func fuzzCrasherReport(fuzzTarget, fuzzFn, name, contents string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Failing input found for `%s`** (saved in `testdata/fuzz/%s/%s`):\n\n",
+		fuzzTarget, fuzzTarget, name))
+	sb.WriteString("```\n" + strings.TrimRight(contents, "\n") + "\n```\n\n")
+	values, ok := fuzzCorpusValues(contents)
+	if fuzzFn == "" || !ok {
+		return sb.String()
+	}
+	testName := "Test" + fuzzTarget + "_" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	sb.WriteString("To reproduce it, execute the following cell:\n\n")
+	sb.WriteString(fmt.Sprintf("```go\n%%test\nfunc %s(t *testing.T) {\n\tfuzzFn := %s\n\tfuzzFn(%s)\n}\n```\n",
+		testName, fuzzFn, strings.Join(append([]string{"t"}, values...), ", ")))
+	return sb.String()
+}
+
+// fuzzFunction returns the source of the function passed to `f.Fuzz` in the definition of a fuzz target:
+// usually a function literal `func(t *testing.T, ...) {...}`.
+func fuzzFunction(definition string) (string, error) {
+	const header = "package main\n\n"
+	src := header + definition
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse fuzz target")
+	}
+	var funcDecl *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			funcDecl = fd
+			break
+		}
+	}
+	if funcDecl == nil || funcDecl.Body == nil || len(funcDecl.Type.Params.List) != 1 ||
+		len(funcDecl.Type.Params.List[0].Names) != 1 {
+		return "", errors.New("fuzz target is not a function `func FuzzXxx(f *testing.F)`")
+	}
+	fName := funcDecl.Type.Params.List[0].Names[0].Name
+	var fuzzFn ast.Expr
+	ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
+		if fuzzFn != nil {
+			return false
+		}
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Fuzz" || len(call.Args) != 1 {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == fName {
+			fuzzFn = call.Args[0]
+		}
+		return true
+	})
+	if fuzzFn == nil {
+		return "", errors.Errorf("call to `%s.Fuzz` not found in fuzz target", fName)
+	}
+	return src[fset.Position(fuzzFn.Pos()).Offset:fset.Position(fuzzFn.End()).Offset], nil
+}
+
+// fuzzCorpusValues returns the values of an entry of a fuzz corpus, as Go expressions (e.g.: `string("\xff")`).
+// It returns false if the contents are not in the `go test fuzz v1` format.
+func fuzzCorpusValues(contents string) ([]string, bool) {
+	lines := strings.Split(strings.TrimSpace(contents), "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != "go test fuzz v1" {
+		return nil, false
+	}
+	var values []string
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	return values, true
+}
+
+// listCorpus returns the names of the entries in the corpus directory of a fuzz target. It returns an
+// empty list if the directory doesn't exist.
+func listCorpus(corpusDir string) ([]string, error) {
+	entries, err := os.ReadDir(corpusDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read fuzz corpus directory %q", corpusDir)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
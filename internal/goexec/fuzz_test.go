@@ -0,0 +1,45 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzCorpus(t *testing.T) {
+	corpusDir := path.Join(t.TempDir(), "testdata", "fuzz", "FuzzParse")
+	names, err := listCorpus(corpusDir)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	require.NoError(t, os.MkdirAll(corpusDir, 0700))
+	require.NoError(t, os.WriteFile(path.Join(corpusDir, "a1b2"), []byte("go test fuzz v1\nstring(\"\\xff\")\n"), 0600))
+	names, err = listCorpus(corpusDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1b2"}, names)
+
+	fuzzFn, err := fuzzFunction(`func FuzzParse(f *testing.F) {
+	f.Add("x")
+	f.Fuzz(func(t *testing.T, s string, n int) {
+		if _, err := Parse(s, n); err != nil {
+			t.Fatal(err)
+		}
+	})
+}`)
+	require.NoError(t, err)
+	assert.Equal(t, "func(t *testing.T, s string, n int) {\n\t\tif _, err := Parse(s, n); err != nil {\n\t\t\tt.Fatal(err)\n\t\t}\n\t}", fuzzFn)
+
+	report := fuzzCrasherReport("FuzzParse", "checkParse", "a1b2", "go test fuzz v1\nstring(\"\\xff\")\nint(3)\n")
+	assert.Contains(t, report, "```\ngo test fuzz v1\nstring(\"\\xff\")\nint(3)\n```")
+	assert.Contains(t, report, "```go\n%test\nfunc TestFuzzParse_a1b2(t *testing.T) {\n"+
+		"\tfuzzFn := checkParse\n\tfuzzFn(t, string(\"\\xff\"), int(3))\n}\n```")
+
+	// Without the function passed to f.Fuzz, no cell is given.
+	_, err = fuzzFunction("func FuzzParse(f *testing.F) {}")
+	require.Error(t, err)
+	report = fuzzCrasherReport("FuzzParse", "", "a1b2", "go test fuzz v1\nstring(\"\\xff\")\n")
+	assert.NotContains(t, report, "%test")
+}
@@ -0,0 +1,88 @@
+package goexec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the report of the compiler escape-analysis and inlining decisions for functions
+// defined in the notebook. It's connected to the special command `%gcflags-report`.
+
+// GCFlagsReport compiles the memorized declarations with `-gcflags=-m=2`, and displays the escape-analysis
+// and inlining diagnostics of the function funcName (or `Type.Method`), mapped back to cell lines.
+func (s *State) GCFlagsReport(msg kernel.Message, funcName string) error {
+	if err := s.checkFunctionDefined(funcName); err != nil {
+		return err
+	}
+	output, fileToCellIdAndLine, err := s.buildMemorizedDeclarations(msg, nil, "-gcflags=-m=2")
+	if err != nil {
+		return err
+	}
+	content, err := s.readMainGo()
+	if err != nil {
+		return err
+	}
+	fromLine, toLine, err := functionLineRange(content, funcName)
+	if err != nil {
+		return err
+	}
+	report := filterDiagnostics(output, fromLine, toLine)
+	if report == "" {
+		return kernel.PublishMarkdown(msg, fmt.Sprintf("No escape-analysis or inlining diagnostics for `%s`.\n", funcName))
+	}
+	report = mapCodeReferences(report, fileToCellIdAndLine)
+	return kernel.PublishHtml(msg, fmt.Sprintf("<h4>Escape analysis and inlining of <code>%s</code></h4>\n<pre>%s</pre>\n",
+		html.EscapeString(funcName), html.EscapeString(report)))
+}
+
+// functionLineRange returns the first and last lines (1-based) of the declaration of the function funcName,
+// in the form `Func` or `Type.Method`, in the Go code content.
+func functionLineRange(content, funcName string) (fromLine, toLine int, err error) {
+	typeName, methodName, isMethod := strings.Cut(funcName, ".")
+	fileSet := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fileSet, "", content, parser.SkipObjectResolution)
+	if err != nil {
+		err = errors.Wrapf(err, "parsing composed code")
+		return
+	}
+	for _, decl := range fileAst.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		hasReceiver := funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0
+		if (!isMethod && !hasReceiver && funcDecl.Name.Name == funcName) ||
+			(isMethod && hasReceiver && funcDecl.Name.Name == methodName &&
+				receiverTypeName(funcDecl.Recv.List[0].Type) == typeName) {
+			return fileSet.Position(funcDecl.Pos()).Line, fileSet.Position(funcDecl.End()).Line, nil
+		}
+	}
+	err = errors.Errorf("declaration of function %q not found in composed code", funcName)
+	return
+}
+
+// filterDiagnostics returns the lines of the compiler output that refer to lines of `main.go`
+// in the range [fromLine, toLine].
+func filterDiagnostics(output string, fromLine, toLine int) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		parts := reCodeReference.FindStringSubmatch(line)
+		if parts == nil || !strings.HasPrefix(line, parts[0]) {
+			continue
+		}
+		lineNum, err := strconv.Atoi(parts[2])
+		if err != nil || lineNum < fromLine || lineNum > toLine {
+			continue
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
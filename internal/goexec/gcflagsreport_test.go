@@ -0,0 +1,44 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCFlagsReportFilter(t *testing.T) {
+	content := `package main
+
+type T struct{ x int }
+
+func (t *T) M() int { return t.x + 1 }
+
+func f(a int) *int {
+	b := a + 1
+	return &b
+}
+
+func main() {}
+`
+	fromLine, toLine, err := functionLineRange(content, "f")
+	require.NoError(t, err)
+	assert.Equal(t, []int{7, 10}, []int{fromLine, toLine})
+	fromLine, toLine, err = functionLineRange(content, "T.M")
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 5}, []int{fromLine, toLine})
+	_, _, err = functionLineRange(content, "g")
+	assert.Error(t, err)
+
+	output := `# gonb_1234
+./main.go:5:6: can inline (*T).M with cost 6 as: method(t *T) func() int { return t.x + 1 }
+./main.go:7:6: can inline f with cost 9 as: func(a int) *int { b := a + 1; return &b }
+./main.go:8:2: b escapes to heap:
+./main.go:8:2:   flow: ~r0 = &b:
+./main.go:12:6: can inline main with cost 0 as: func() {  }
+`
+	assert.Equal(t, `./main.go:7:6: can inline f with cost 9 as: func(a int) *int { b := a + 1; return &b }
+./main.go:8:2: b escapes to heap:
+./main.go:8:2:   flow: ~r0 = &b:
+`, filterDiagnostics(output, 7, 10))
+}
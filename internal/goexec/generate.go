@@ -0,0 +1,114 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements running the `//go:generate` directives of the memorized declarations
+// (e.g.: `stringer`, `mockgen`). It's connected to the special command `%generate`.
+
+// Generate composes `main.go` with the memorized declarations, and runs `go generate ./...` in the
+// temporary module.
+//
+// The files created (or updated) by the generators are kept in State.TempDir, so they are compiled
+// along with the following cells. Files generated by a previous `%generate` are removed first, so
+// generators always see the current declarations.
+func (s *State) Generate(msg kernel.Message) (err error) {
+	if len(s.Definitions.GenerateDirectives) == 0 {
+		return errors.New("no `//go:generate` directives memorized -- see `%ls`")
+	}
+	if err = s.RemoveGeneratedFiles(); err != nil {
+		return
+	}
+
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	_, err = s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+
+	before, err := s.listTempDirFiles()
+	if err != nil {
+		return
+	}
+	executor, err := s.goExecutor(msg, "generate", "./...")
+	if err == nil {
+		err = executor.Exec()
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "`go generate` failed")
+	}
+	after, err := s.listTempDirFiles()
+	if err != nil {
+		return
+	}
+
+	s.generatedFiles = nil
+	for _, name := range SortedKeys(after) {
+		if name == MainGo || name == MainTestGo || name == "go.mod" || name == "go.sum" {
+			continue
+		}
+		if modTime, found := before[name]; found && !after[name].After(modTime) {
+			continue
+		}
+		s.generatedFiles = append(s.generatedFiles, name)
+	}
+	klog.V(1).Infof("%%generate: generated files %v", s.generatedFiles)
+
+	var sb strings.Builder
+	if len(s.generatedFiles) == 0 {
+		sb.WriteString("`go generate` executed, no files were generated.\n")
+	} else {
+		sb.WriteString("**Generated files** (available to the following cells):\n\n")
+		for _, name := range s.generatedFiles {
+			sb.WriteString(fmt.Sprintf("* `%s`\n", name))
+		}
+	}
+	return kernel.PublishMarkdown(msg, sb.String())
+}
+
+// RemoveGeneratedFiles removes the files created by the last `%generate`.
+func (s *State) RemoveGeneratedFiles() error {
+	for _, name := range s.generatedFiles {
+		p := path.Join(s.TempDir, name)
+		err := os.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "can't remove previously generated file %q", p)
+		}
+	}
+	s.generatedFiles = nil
+	return nil
+}
+
+// listTempDirFiles returns the modification time of the regular files in State.TempDir.
+func (s *State) listTempDirFiles() (map[string]time.Time, error) {
+	entries, err := os.ReadDir(s.TempDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read directory %q", s.TempDir)
+	}
+	files := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[entry.Name()] = info.ModTime()
+	}
+	return files, nil
+}
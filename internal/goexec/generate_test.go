@@ -0,0 +1,49 @@
+package goexec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDirectives(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	cellCode := `//go:generate stringer -type=Kind
+type Kind int
+
+func f() {
+	//go:generate echo inside function
+}
+
+//go:generate echo hello
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	decls, err := s.parseFromGoCode(nil, 1, NoCursor, MakeFileToCellIdAndLine(1, fileToCellLine))
+	require.NoError(t, err)
+
+	// Directives inside declarations are not memorized separately.
+	assert.Equal(t, []string{"echo hello", "stringer -type=Kind"}, SortedKeys(decls.GenerateDirectives))
+	assert.Equal(t, CellLines{Id: 1, Lines: []int{0}}, decls.GenerateDirectives["stringer -type=Kind"].CellLines)
+	assert.Equal(t, CellLines{Id: 1, Lines: []int{7}}, decls.GenerateDirectives["echo hello"].CellLines)
+
+	// Directives are rendered right after the package declaration.
+	buf := bytes.NewBuffer(nil)
+	_, fileToCellIdAndLine, err := s.createCodeFromDecls(buf, decls, nil)
+	require.NoError(t, err)
+	content := buf.String()
+	assert.True(t, strings.HasPrefix(content,
+		"package main\n\n//go:generate echo hello\n//go:generate stringer -type=Kind\n\n"), "Got:\n%s", content)
+	assert.Equal(t, CellIdAndLine{Id: 1, Line: 7}, fileToCellIdAndLine[2])
+	assert.Equal(t, 1, strings.Count(content, "inside function"))
+}
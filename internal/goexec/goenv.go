@@ -0,0 +1,153 @@
+package goexec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/jpyexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file holds the configuration of the environment of the `go` tool invocations (`go build`, `go get`, etc.),
+// set with the special command `%config`, and the `%vendor` command.
+
+// VendorDir is the name of the directory (in State.TempDir) with the vendored dependencies.
+const VendorDir = "vendor"
+
+// goplsSettingsTimeout is the time limit to notify `gopls` of new settings.
+const goplsSettingsTimeout = 2 * time.Second
+
+// GoEnv returns the extra environment variables (in the form "key=value") to use for all invocations of
+// the `go` tool, according to the configuration of the State.
+//
+// These are appended to the environment of the kernel, which is always passed through: so the user's
+// GOPRIVATE, GONOSUMDB, GONOPROXY, GOINSECURE, GOFLAGS, `~/.netrc` (or NETRC) and git credential helpers
+// configuration are used to resolve private modules. Only the options explicitly configured override them.
+func (s *State) GoEnv() (env []string) {
+	if s.GoVersion != "" {
+		env = append(env, "GOTOOLCHAIN=go"+s.GoVersion)
+	}
+	if s.GoProxy != "" {
+		env = append(env, "GOPROXY="+s.GoProxy)
+	}
+	if s.GoSumDB != "" {
+		env = append(env, "GOSUMDB="+s.GoSumDB)
+	}
+	if s.GoPrivate != "" {
+		env = append(env, "GOPRIVATE="+s.GoPrivate)
+	}
+	if s.GoExperiment != "" {
+		env = append(env, "GOEXPERIMENT="+s.GoExperiment)
+	}
+	var goFlags []string
+	if len(s.BuildTags) > 0 {
+		goFlags = append(goFlags, "-tags="+strings.Join(s.BuildTags, ","))
+	}
+	if s.Offline {
+		env = append(env, "GOPROXY=off")
+		if _, err := os.Stat(path.Join(s.TempDir, VendorDir, "modules.txt")); err == nil {
+			goFlags = append(goFlags, "-mod=vendor")
+		}
+	}
+	if len(goFlags) > 0 {
+		// Flags in GOFLAGS apply to all `go` commands (and to `goimports`), and are overridden by
+		// the ones given in the command line (e.g.: `%goflags`).
+		env = append(env, "GOFLAGS="+strings.TrimSpace(os.Getenv("GOFLAGS")+" "+strings.Join(goFlags, " ")))
+	}
+	return
+}
+
+// UpdateGoplsSettings configures `gopls` with the environment (see State.GoEnv) and build tags of the
+// notebook, so its diagnostics and completions match what is compiled. It should be called whenever
+// the configuration changes.
+func (s *State) UpdateGoplsSettings() {
+	if s.gopls == nil {
+		return
+	}
+	envMap := make(map[string]string)
+	for _, keyValue := range s.GoEnv() {
+		key, value, _ := strings.Cut(keyValue, "=")
+		envMap[key] = value
+	}
+	if s.IsolatedGoPath {
+		// `gopls` was possibly started before the environment of the kernel was changed.
+		for _, keyValue := range s.isolatedGoPathEnv() {
+			key, value, _ := strings.Cut(keyValue, "=")
+			envMap[key] = value
+		}
+	}
+	for key, value := range s.gpuEnv {
+		// Set by `%gpu info`, possibly after `gopls` was started.
+		envMap[key] = value
+	}
+	settings := map[string]any{"env": envMap}
+	if len(s.BuildTags) > 0 {
+		settings["buildFlags"] = []string{"-tags=" + strings.Join(s.BuildTags, ",")}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), goplsSettingsTimeout)
+	defer cancel()
+	if err := s.gopls.SetSettings(ctx, settings); err != nil {
+		klog.Warningf("Failed to update gopls settings: %+v", err)
+	}
+}
+
+// goCommand returns the command to execute the `go` tool with the given arguments, in State.TempDir and
+// with the environment configured with State.GoEnv.
+func (s *State) goCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = s.TempDir
+	if env := s.GoEnv(); len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+	return cmd
+}
+
+// goExecutor returns an executor of the `go` tool with the given arguments, with the output piped to the
+// notebook. It is executed in State.TempDir and with the environment configured with State.GoEnv.
+func (s *State) goExecutor(msg kernel.Message, args ...string) (*jpyexec.Executor, error) {
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		return nil, errors.Wrapf(err, "while trying to run `go %s`", strings.Join(args, " "))
+	}
+	return jpyexec.New(msg, goPath, args...).
+		ExecutionCount(msg.Kernel().ExecCounter).
+		InDir(s.TempDir).
+		WithEnv(s.GoEnv()), nil
+}
+
+// Vendor copies the dependencies of the memorized declarations into the `vendor` directory of the temporary
+// module, with `go mod vendor`. Combined with `%config offline=on`, it allows executing the notebook
+// without network access.
+func (s *State) Vendor(msg kernel.Message) (err error) {
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	_, err = s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+	for _, args := range [][]string{{"mod", "tidy"}, {"mod", "vendor"}} {
+		klog.V(1).Infof("%%vendor: executing go %v", args)
+		var executor *jpyexec.Executor
+		executor, err = s.goExecutor(msg, args...)
+		if err == nil {
+			err = executor.Exec()
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "`go %s` failed", strings.Join(args, " "))
+		}
+		if state := executor.ProcessState(); state != nil && !state.Success() {
+			return errors.Errorf("`go %s` failed", strings.Join(args, " "))
+		}
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		"* Dependencies vendored in `$GONB_TMP_DIR/vendor`: use `%config offline=on` to use them.\n")
+}
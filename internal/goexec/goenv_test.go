@@ -0,0 +1,63 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoEnv(t *testing.T) {
+	s := &State{TempDir: t.TempDir()}
+	assert.Empty(t, s.GoEnv())
+
+	t.Setenv("GOFLAGS", "-tags=x")
+	s.Offline = true
+	assert.Equal(t, []string{"GOPROXY=off"}, s.GoEnv())
+
+	// With vendored dependencies.
+	require.NoError(t, os.MkdirAll(path.Join(s.TempDir, VendorDir), 0700))
+	require.NoError(t, os.WriteFile(path.Join(s.TempDir, VendorDir, "modules.txt"), nil, 0600))
+	assert.Equal(t, []string{"GOPROXY=off", "GOFLAGS=-tags=x -mod=vendor"}, s.GoEnv())
+
+	cmd := s.goCommand("build")
+	assert.Equal(t, s.TempDir, cmd.Dir)
+	assert.Contains(t, cmd.Env, "GOPROXY=off")
+
+	// User's environment is passed through, and overridden by the configured options.
+	t.Setenv("GONOSUMDB", "example.com/private")
+	s.Offline = false
+	s.GoPrivate = "github.com/myorg/*"
+	cmd = s.goCommand("get")
+	assert.Contains(t, cmd.Env, "GONOSUMDB=example.com/private")
+	assert.Equal(t, "GOPRIVATE=github.com/myorg/*", cmd.Env[len(cmd.Env)-1])
+
+	s.GoVersion = "1.22.3"
+	assert.Contains(t, s.GoEnv(), "GOTOOLCHAIN=go1.22.3")
+
+	// Build tags are combined with the user's GOFLAGS.
+	s.GoVersion = ""
+	s.GoPrivate = ""
+	s.GoExperiment = "rangefunc"
+	s.BuildTags = []string{"integration", "linux"}
+	assert.Equal(t, []string{"GOEXPERIMENT=rangefunc", "GOFLAGS=-tags=x -tags=integration,linux"}, s.GoEnv())
+}
+
+func TestParseGoVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"1.22.3":   "1.22.3",
+		"go1.22.3": "1.22.3",
+		"1.23rc1":  "1.23rc1",
+		"1.21.0":   "1.21.0",
+	} {
+		got, err := parseGoVersion(version)
+		require.NoError(t, err, "version %q", version)
+		assert.Equal(t, want, got)
+	}
+	for _, version := range []string{"1.20.5", "1.22", "latest", "2.0.0", "1.22.3 "} {
+		_, err := parseGoVersion(version)
+		assert.Error(t, err, "version %q", version)
+	}
+}
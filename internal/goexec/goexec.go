@@ -11,12 +11,16 @@ import (
 	"github.com/janpfeifer/gonb/internal/comms"
 	"github.com/janpfeifer/gonb/internal/goexec/goplsclient"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/tracing"
 	"github.com/pkg/errors"
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -28,6 +32,9 @@ const (
 	// InitFunctionPrefix -- functions named with this prefix will be rendered as
 	// a separate `func init()`.
 	InitFunctionPrefix = "init_"
+
+	// DefaultDisplayMaxFPS is the default value of State.DisplayMaxFPS.
+	DefaultDisplayMaxFPS = 30
 )
 
 // State holds information about Go code execution for this kernel. It's a singleton (for now).
@@ -49,6 +56,201 @@ type State struct {
 	GoBuildFlags []string // Flags to be passed to `go build`, in State.Compile.
 	AutoGet      bool     // Whether to do a "go get" before compiling, to fetch missing external modules.
 
+	// AutoGetAllow and AutoGetDeny are lists of package patterns (e.g.: `github.com/myorg/...`) that configure
+	// which missing packages are automatically fetched when the build fails. See State.AutoGetAllowed.
+	AutoGetAllow, AutoGetDeny []string
+
+	// AutoWorkspaceEnabled configures whether the local modules of WorkspaceDir (the `go.work` "use" paths, or
+	// the directory itself if it is a Go module) are used by the temporary module. See State.AutoWorkspace.
+	// It is disabled by default (enabled with `%workspace`), so notebooks don't pick up local modules
+	// unexpectedly. WorkspaceDir defaults to the current directory if empty.
+	AutoWorkspaceEnabled bool
+	WorkspaceDir         string
+
+	// Offline configures the `go` tool not to access the network (GOPROXY=off), and to use the vendored
+	// dependencies if available (see `%vendor`). Set with `%config offline=on`. See State.GoEnv.
+	Offline bool
+
+	// GoPrivate, if set, overrides the GOPRIVATE environment variable (the modules that are not fetched
+	// through the proxy nor checked against the checksum database) for the `go` tool. See State.GoEnv.
+	GoPrivate string
+
+	// GoProxy and GoSumDB, if set, override GOPROXY and GOSUMDB for the `go` tool. Set with
+	// `%config goproxy=...` and `%config gosumdb=...`. See State.GoEnv.
+	GoProxy, GoSumDB string
+
+	// GoVersion, if set, is the version of the Go toolchain (e.g.: "1.22.3") used to build the notebook,
+	// downloaded by the `go` tool if needed (GOTOOLCHAIN). Set with `%go-version`. See State.SetGoVersion.
+	GoVersion string
+
+	// GoExperiment, if set, overrides GOEXPERIMENT (e.g.: "rangefunc"), and BuildTags are the build tags
+	// (`-tags`) used to compose and build the notebook. Set with `%config goexperiment=...` and `%tags`.
+	// See State.GoEnv.
+	GoExperiment string
+	BuildTags    []string
+
+	// TinyGoTarget, if set, is the TinyGo target (e.g.: "arduino") cells are compiled to, with `tinygo`
+	// instead of the standard toolchain. The firmware is not executed, see State.Flash. Set with `%tinygo`.
+	TinyGoTarget string
+
+	// IsolatedGoPath indicates the notebook uses its own GOPATH, GOBIN and module cache, under TempDir.
+	// Set with the `--isolated_gopath` flag or `%config isolated_gopath=on`. See State.SetIsolatedGoPath.
+	IsolatedGoPath        bool
+	isolatedGoPathPrevEnv map[string]string
+
+	// searchIndex caches the exported symbols of the packages that don't change (standard library and
+	// module cache), by import path, for `%search`.
+	searchIndex map[string][]searchSymbol
+
+	// gpuEnv holds the environment variables set by `%gpu info`, see State.GPUInfo.
+	gpuEnv map[string]string
+
+	// NotifyChangedSources configures whether the tracked local sources (see `%track` and State.AutoTrack)
+	// that changed on disk since the last execution are reported when executing a cell. Set with
+	// `%config notify_changes=on`.
+	NotifyChangedSources bool
+
+	// MainContext configures whether the `func main()` created for `%%` cells defines a `ctx` variable
+	// (context.Context), canceled when the execution is interrupted (SIGINT). Set with
+	// `%config main_context=on`. See State.mainPreamble.
+	MainContext bool
+
+	// AllowUnused configures whether the local variables of the `func main()` created for the cell are marked
+	// as used, to avoid the "declared and not used" error. Set with `%config allow_unused=on`, see
+	// allowUnusedLocals.
+	AllowUnused bool
+
+	// HideWarnings matches the lines of the build output that are not displayed, e.g. recurring warnings of
+	// generated code. Set with `%config hide_warnings=<regexp>`, see SetHideWarnings.
+	HideWarnings *regexp.Regexp
+
+	// updateNotice is set by the update check when a newer version of GoNB is available, and displayed by
+	// the next cell executed. See StartUpdateCheck.
+	updateNotice atomic.Pointer[string]
+
+	// EchoComposed configures whether the code composed for each execution is displayed, as with
+	// `%compose show`. Set with `%compose on`.
+	EchoComposed bool
+
+	// lastComposed is the code composed for the last execution, see State.ShowComposed.
+	lastComposed *composedCode
+
+	// lastGoodComposed is the code composed for the last successful build, and lastBuildFailure the last
+	// failed build, if the build failed since. They are reported by `%why`, see State.WhyMarkdown.
+	lastGoodComposed *composedCode
+	lastBuildFailure *buildFailure
+
+	// lastBuildOutput is the output of the compiler of the last failed build.
+	lastBuildOutput string
+
+	// lastCellError is the error of the last cell execution that failed, included by `%bugreport`.
+	lastCellError *cellError
+
+	// nbSettings are the settings set in the session, persisted in the notebook metadata by `%settings save`.
+	nbSettings notebookSettingsState
+
+	// SetupCellsRun is set once the cells of the notebook tagged "setup" were executed, before the first cell
+	// executed after the kernel (re)started.
+	SetupCellsRun bool
+
+	// prelude is executed at the start of every `func main()` created for `%%` cells. Set with `%%prelude`
+	// or `%prelude --file=<path>`, see State.SetPrelude.
+	prelude *preludeCode
+
+	// LeakCheck configures whether the `func main()` created for `%%` cells reports the goroutines still
+	// running when it returns. Set with `%config leak_check=on`, see SetLeakCheck.
+	LeakCheck bool
+
+	// Seed seeds `math/rand` in the programs and tests of the cells, if not nil. Set with `%config seed=<n>`,
+	// see SetSeed.
+	Seed *int64
+
+	// cellTestResults are the results of the tests of the last cell executed, if it was a `%test` cell.
+	cellTestResults *TestResults
+
+	// ReportResources configures whether to report the resources (time, memory) used by each execution of
+	// the cell program, in a collapsible footer. Set with `%config report_resources=on`.
+	ReportResources bool
+
+	// PagerLines is the number of lines of the output of the program displayed as it's printed, and at the end
+	// of the execution: the lines in between are hidden in a pager (see pagerWriter). 0 disables it.
+	// Defaults to DefaultPagerLines. Set with `%config pager_lines=<n>`.
+	PagerLines int
+
+	// DisplayMaxFPS is the maximum number of times per second each display (with a display id, e.g.:
+	// `gonbui.UpdateHtml`) is updated by the program: more frequent updates are coalesced, and only the last one
+	// is published. 0 disables it. Defaults to DefaultDisplayMaxFPS. Set with `%config display_fps=<n>`.
+	DisplayMaxFPS int
+
+	// BinaryOutput enables the detection of binary data (PNG, JPEG or GIF images) written to stdout by the
+	// programs of the cells and shell commands: it's saved to a file and displayed, instead of streamed as
+	// text. Defaults to true. Set with `%config binary_output=off`.
+	BinaryOutput bool
+
+	// ChartSnapshot enables capturing the interactive charts (e.g.: `plotly.DisplayFig`) also as static PNG
+	// images, included in their outputs for exported notebooks. Set with `%config chart_snapshot=on`.
+	ChartSnapshot bool
+
+	// LogView configures whether the structured logs (JSON lines) printed by the program are displayed as a
+	// filterable table, instead of as raw text. Set with `%config logview=on`.
+	LogView bool
+
+	// StaleHints configures whether to list, after a cell changes memorized declarations, the cells executed
+	// before that use them, since their results may be stale. Defaults to true. Set with `%config stale_hints=off`.
+	StaleHints bool
+
+	// ToolchainRetries is the number of times toolchain operations that fail with a transient error (e.g.:
+	// "text file busy", module cache lock errors or `gopls` timeouts) are retried. Defaults to
+	// DefaultToolchainRetries. Set with `%config toolchain_retries=<n>`.
+	ToolchainRetries int
+
+	// Reactive configures the experimental reactive mode: executing a cell that changes memorized declarations
+	// re-executes the cells executed before that use them. Set with `%config reactive=on`.
+	Reactive bool
+
+	// cellRefs are the memorized declarations used by the cells executed, keyed by cell, see cellKey and
+	// State.recordCellReferences.
+	cellRefs map[string]*cellReferences
+
+	// staleHintDisplayId is the display id of the last note of stale cells published, erased when the next
+	// cell is executed. See State.publishStaleCells.
+	staleHintDisplayId string
+
+	// reexecutingCellKey is the key (see cellKey) of the cell being re-executed in the reactive mode, if any.
+	reexecutingCellKey string
+
+	// reactivePending are the ids of the cells to re-execute in the reactive mode, see State.reexecuteStaleCells.
+	reactivePending common.Set[int]
+
+	// Shell executes the `!` commands, as `<Shell> -c <command>`. If empty, DefaultShell is used. Set with
+	// `%config shell=<path>`.
+	Shell string
+
+	// SecretCommand is the command that prints the secret for `%secret get <name>`, executed with the Shell,
+	// where `{name}` is replaced by the name of the secret. Set with `%config secret_command=...`.
+	SecretCommand string
+
+	// cellSecrets are the secrets read with `%secret get` for the current cell, see State.GetSecret.
+	cellSecrets map[string]string
+
+	// ParamValues are the values of the parameters declared with `// gonb:param`, used instead of their
+	// defaults. Set with `%params name=value` or `gonb run --param name=value`, see State.SetParam.
+	ParamValues map[string]string
+
+	// params are the parameters declared in the cells executed, by name.
+	params map[string]CellParam
+
+	// inputValues are the values of the input widgets (e.g.: `gonbui.Slider`), by the name of the variable
+	// they are declared as, and formValues the values submitted in the forms (`gonbui.Form`), by the name
+	// of the field. Protected by muInputs, since they are set by the comms handlers.
+	inputValues map[string]inputValue
+	formValues  map[string]string
+	muInputs    sync.Mutex
+
+	// uploadedFiles are the paths of the files uploaded with `gonbui.RequestFileUpload`, by name. Protected by
+	// muInputs.
+	uploadedFiles map[string]string
+
 	// Global elements defined mapped by their keys.
 	Definitions *Declarations
 
@@ -88,13 +290,87 @@ type State struct {
 	CellIsTest        bool
 	CellTests         []string // Tests defined in this cell. Only used if CellIsTest==true.
 	CellHasBenchmarks bool
+	CellCoverage      bool // Set with `%test -cover`: a coverage report is displayed after the tests run.
 
 	// CellIsWasm indicates whether the current cell is to be compiled for WebAssembly (wasm).
 	CellIsWasm                  bool
 	WasmDir, WasmUrl, WasmDivId string
 
+	// CellServe indicates the program of the current cell is kept running in the background, serving HTTP,
+	// until `%stop`. Set with `%serve`. See State.Serve.
+	CellServe bool
+
+	// CellServeGRPC indicates the `%serve` cell runs a gRPC server (with `%serve --grpc`), so it is not
+	// previewed in an iframe. Requests are sent to it with `%grpc call`, see State.GRPCCall.
+	CellServeGRPC bool
+
+	// ServeURL, if set, is the template of the URL used to preview `%serve` cells, where "{port}" is replaced
+	// by the port served, e.g.: "/proxy/{port}/" for jupyter-server-proxy. Defaults to DefaultServeURL.
+	ServeURL string
+
+	// CellShare indicates the program of the current cell is posted to the Go Playground, instead of being
+	// executed. Set with `%share`. See State.Share.
+	CellShare bool
+
+	// CellRunCLI indicates the program of the current cell is executed as a command line tool, with Args. Set
+	// with `%run-cli`. See runCLIMain.
+	CellRunCLI bool
+
+	// CellIsSelection indicates the Go code of the current cell is a fragment of statements (e.g.: VS Code's
+	// "Run Selection/Line"), executed as the body of `func main()` without memorizing its definitions. See
+	// isSelection.
+	CellIsSelection bool
+
+	// PlaygroundURL, if set, is the URL of the Go Playground instance used by `%share`. Defaults to
+	// DefaultPlaygroundURL.
+	PlaygroundURL string
+
+	// CellWasmIframe indicates the wasm of the current cell is embedded, along with `wasm_exec.js`, in a
+	// sandboxed iframe, instead of being served by Jupyter. Set with `%wasm --iframe`.
+	CellWasmIframe bool
+
 	// Comms represents the communication with the front-end.
 	Comms *comms.State
+
+	// postMortemBinary and postMortemCore are the copies of the binary and of the core dump of the
+	// last cell that crashed, used by `%postmortem`. See State.capturePostMortem.
+	postMortemBinary, postMortemCore string
+
+	// generatedFiles are the files in TempDir created by the last `%generate`. See State.Generate.
+	generatedFiles []string
+
+	// served is the program of the last `%serve` cell, running in the background.
+	served *servedProgram
+
+	// cSources are the C source files written to TempDir with `%%c`. See State.WriteCSource.
+	cSources map[string]cSource
+
+	// goPkgFiles are the Go files of packages in TempDir written with `%%gopkg`, indexed by their path
+	// relative to TempDir. See State.WriteGoPackageFile.
+	goPkgFiles map[string]goPkgFile
+
+	// cellSpan traces the execution of the current cell, if tracing is enabled. See package tracing.
+	cellSpan *tracing.Span
+
+	// notebookImports maps the names of the packages created by `%nbimport` to their import paths.
+	// See State.NotebookImport.
+	notebookImports map[string]string
+
+	// cellOutputs are the execution counts of the cells whose textual outputs are kept, in order.
+	// See State.SaveCellOutput.
+	cellOutputs []int
+
+	// journal saves the changes to Definitions, to restore them if the kernel crashes. See State.JournalDeclarations.
+	journal *declarationsJournal
+
+	// autoWorkspaceContents is the contents of the `go.work` last written by State.AutoWorkspace.
+	autoWorkspaceContents string
+
+	// variableTypesCache caches the types of the memorized variables listed by `%variables`.
+	variableTypesCache *variableTypesCache
+
+	// stats accumulates the statistics of the session, displayed by `%stats`.
+	stats sessionStats
 }
 
 // Declarations is a collection of declarations that we carry over from one cell to another.
@@ -104,6 +380,9 @@ type Declarations struct {
 	Types     map[string]*TypeDecl
 	Imports   map[string]*Import
 	Constants map[string]*Constant
+
+	// GenerateDirectives are the `//go:generate` directives, keyed by the command they execute.
+	GenerateDirectives map[string]*GenerateDirective
 }
 
 // New returns an empty State object, that can be used to execute Cells.
@@ -118,24 +397,37 @@ type Declarations struct {
 // goroutines, that stop when the kernel stops.
 func New(k *kernel.Kernel, uniqueID string, preserveTempDir, rawError bool) (*State, error) {
 	s := &State{
-		Kernel:          k,
-		UniqueID:        uniqueID,
-		Package:         "gonb_" + uniqueID,
-		Definitions:     NewDeclarations(),
-		AutoGet:         true,
-		trackingInfo:    newTrackingInfo(),
-		preserveTempDir: preserveTempDir,
-		rawError:        rawError,
-		Comms:           comms.New(),
-		cellExecChan:    make(chan *cellExecParams),
+		Kernel:           k,
+		UniqueID:         uniqueID,
+		Package:          "gonb_" + uniqueID,
+		Definitions:      NewDeclarations(),
+		AutoGet:          true,
+		trackingInfo:     newTrackingInfo(),
+		preserveTempDir:  preserveTempDir,
+		rawError:         rawError,
+		Comms:            comms.New(),
+		PagerLines:       DefaultPagerLines,
+		DisplayMaxFPS:    DefaultDisplayMaxFPS,
+		BinaryOutput:     true,
+		StaleHints:       true,
+		ToolchainRetries: DefaultToolchainRetries,
+		reactivePending:  common.MakeSet[int](),
+		cellExecChan:     make(chan *cellExecParams),
 	}
+	s.Comms.HandleAddressPrefix(pagerAddressPrefix, s.handlePagerRequest)
+	s.Comms.HandleAddressPrefix(protocol.GonbuiInputAddressPrefix, s.handleInputValue)
+	s.Comms.HandleAddressPrefix(protocol.GonbuiFormAddress, s.handleFormValues)
+	s.Comms.HandleAddressPrefix(protocol.GonbuiDownloadAddress, s.handleDownloadRequest)
+	s.Comms.HandleAddressPrefix(protocol.GonbuiUploadAddress, s.handleUploadChunk)
+	s.Comms.HandleAddressPrefix(protocol.GonbuiSnapshotAddress, s.handleChartSnapshot)
+	s.Comms.HandleAddressPrefix(notebookSettingsAckAddress, s.handleNotebookSettingsAck)
 
 	// Goroutine that processes incoming ExecuteCell requests.
 	// It stops when the kernel stops.
 	go s.serializeExecuteCell()
 
 	// Create directory.
-	s.TempDir = path.Join(os.TempDir(), s.Package)
+	s.TempDir = filepath.Join(os.TempDir(), s.Package)
 	err := os.Mkdir(s.TempDir, 0700)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create temporary directory %q", s.TempDir)
@@ -162,9 +454,23 @@ func New(k *kernel.Kernel, uniqueID string, preserveTempDir, rawError bool) (*St
 		err = nil
 	}
 
+	if err = s.initCellOutputs(); err != nil {
+		return nil, err
+	}
+	if err = s.initFormValues(); err != nil {
+		return nil, err
+	}
+	if err = s.initUploads(); err != nil {
+		return nil, err
+	}
+
 	if err = s.GoModInit(); err != nil {
 		return nil, err
 	}
+	if err = s.openJournal(); err != nil {
+		klog.Warningf("Journal of declarations disabled: %+v", err)
+		err = nil
+	}
 
 	if _, err = exec.LookPath("gopls"); err == nil {
 		s.gopls = goplsclient.New(s.TempDir)
@@ -204,14 +510,13 @@ with:
 
 // GoModInit removes current `go.mod` if it already exists, and recreate it with `go mod init`.
 func (s *State) GoModInit() error {
-	err := os.Remove(path.Join(s.TempDir, "go.mod"))
+	err := os.Remove(filepath.Join(s.TempDir, "go.mod"))
 	if err != nil && !os.IsNotExist(err) {
 		klog.Errorf("Failed to remove go.mod: %+v", err)
 		return errors.Wrapf(err, "failed to remove go.mod")
 	}
 	// ProgramExecutor `go mod init` on given directory.
-	cmd := exec.Command("go", "mod", "init", s.Package)
-	cmd.Dir = s.TempDir
+	cmd := s.goCommand("mod", "init", s.Package)
 	var output []byte
 	output, err = cmd.CombinedOutput()
 	if err != nil {
@@ -227,7 +532,14 @@ func (s *State) Stop() error {
 		s.gopls.Shutdown()
 		s.gopls = nil
 	}
+	if err := s.StopServing(nil); err != nil {
+		klog.Errorf("Failed to stop %%serve program: %+v", err)
+	}
+	if err := s.closeJournal(); err != nil {
+		klog.Warningf("Failed to close the journal of declarations: %+v", err)
+	}
 	if s.TempDir != "" && !s.preserveTempDir {
+		s.removeIsolatedGoPath()
 		err := os.RemoveAll(s.TempDir)
 		if err != nil {
 			return errors.Wrapf(err, "Failed to remove goexec.State temporary directory %s", s.TempDir)
@@ -249,6 +561,8 @@ func NewDeclarations() *Declarations {
 		Variables: make(map[string]*Variable),
 		Types:     make(map[string]*TypeDecl),
 		Constants: make(map[string]*Constant),
+
+		GenerateDirectives: make(map[string]*GenerateDirective),
 	}
 }
 
@@ -260,18 +574,44 @@ func (d *Declarations) Copy() *Declarations {
 		Variables: make(map[string]*Variable, len(d.Variables)),
 		Types:     make(map[string]*TypeDecl, len(d.Types)),
 		Constants: make(map[string]*Constant, len(d.Constants)),
+
+		GenerateDirectives: make(map[string]*GenerateDirective, len(d.GenerateDirectives)),
 	}
 	d2.MergeFrom(d)
 	return d2
 }
 
 // MergeFrom declarations in d2.
+//
+// The cgo preambles of `import "C"` are merged, instead of replaced: so a cell with a bare `import "C"`, or
+// with its own preamble, keeps the C declarations of the previous cells. See mergeCgoPreambles.
 func (d *Declarations) MergeFrom(d2 *Declarations) {
+	oldC, hadC := d.Imports["C"]
 	copyMap(d.Imports, d2.Imports)
+	if newC, found := d2.Imports["C"]; found && hadC && newC != oldC {
+		merged := *newC
+		merged.CgoPreambles = mergeCgoPreambles(oldC.CgoPreambles, newC.CgoPreambles)
+		d.Imports["C"] = &merged
+	}
 	copyMap(d.Functions, d2.Functions)
 	copyMap(d.Variables, d2.Variables)
 	copyMap(d.Types, d2.Types)
 	copyMap(d.Constants, d2.Constants)
+	copyMap(d.GenerateDirectives, d2.GenerateDirectives)
+}
+
+// mergeCgoPreambles returns the preambles in current followed by the ones in added. A preamble identical to
+// one in current (e.g.: the same cell executed again) is not repeated: it only updates the cell lines.
+func mergeCgoPreambles(current, added []CgoPreamble) []CgoPreamble {
+	merged := slices.Clone(current)
+	for _, preamble := range added {
+		if ii := slices.IndexFunc(merged, func(p CgoPreamble) bool { return p.Text == preamble.Text }); ii >= 0 {
+			merged[ii] = preamble
+		} else {
+			merged = append(merged, preamble)
+		}
+	}
+	return merged
 }
 
 func copyMap[K comparable, V any](dst, src map[K]V) {
@@ -287,6 +627,7 @@ func (d *Declarations) ClearCursor() {
 	clearCursor(d.Variables)
 	clearCursor(d.Types)
 	clearCursor(d.Constants)
+	clearCursor(d.GenerateDirectives)
 }
 
 func clearCursor[K comparable, V interface{ ClearCursor() }](data map[K]V) {
@@ -382,7 +723,8 @@ type Function struct {
 
 	Key            string
 	Name, Receiver string
-	Definition     string // Multi-line definition, includes comments preceding definition.
+	Definition     string // Multi-line definition, without the doc comment preceding it.
+	Doc            string // Doc comment, without the comment markers. Used by `%doc`.
 
 }
 
@@ -394,6 +736,7 @@ type Variable struct {
 	CursorInName, CursorInType, CursorInValue bool
 	Key, Name                                 string
 	TypeDefinition, ValueDefinition           string // Type definition may be empty.
+	Doc                                       string // Doc comment, without the comment markers. Used by `%doc`.
 }
 
 // TypeDecl definition, parsed from a notebook cell.
@@ -404,6 +747,7 @@ type TypeDecl struct {
 	Key            string // Same as the name here.
 	TypeDefinition string // Type definition which includes the name.
 	CursorInType   bool
+	Doc            string // Doc comment, without the comment markers. Used by `%doc`.
 }
 
 // Constant represents the declaration of a constant. Because when appearing in block
@@ -416,7 +760,8 @@ type Constant struct {
 	Key                                      string
 	TypeDefinition, ValueDefinition          string // Can be empty, if used as iota.
 	CursorInKey, CursorInType, CursorInValue bool
-	Next, Prev                               *Constant // Next and previous declaration in same Const block.
+	Doc                                      string    // Doc comment, without the comment markers. Used by `%doc`.
+	Next, Prev                               *Constant `json:"-"` // Next and previous declaration in same Const block.
 }
 
 // Import represents an import to be included -- if not used it's automatically removed by
@@ -428,6 +773,26 @@ type Import struct {
 	Key                         string
 	Path, Alias                 string
 	CursorInPath, CursorInAlias bool
+
+	// CgoPreambles are the comments immediately preceding `import "C"` (the cgo preambles, with C
+	// declarations and `#cgo` directives) of the cells that import "C", in the order they were executed.
+	// They are merged, see Declarations.MergeFrom.
+	CgoPreambles []CgoPreamble
+}
+
+// CgoPreamble is the cgo preamble of an `import "C"` in a cell, and the cell lines where it was defined.
+type CgoPreamble struct {
+	CellLines
+
+	Text string
+}
+
+// GenerateDirective represents a `//go:generate` directive, executed with `%generate`.
+type GenerateDirective struct {
+	Cursor
+	CellLines
+
+	Key string // The command to execute, that is, the directive without the `//go:generate ` prefix.
 }
 
 var reDefaultImportPathAlias = regexp.MustCompile(`^.*?(\w[\w0-9_]*)\s*$`)
@@ -438,4 +803,25 @@ var reDefaultImportPathAlias = regexp.MustCompile(`^.*?(\w[\w0-9_]*)\s*$`)
 // It is connected to the special command `%reset`.
 func (s *State) Reset() {
 	s.Definitions = NewDeclarations()
+	s.params = nil
+	s.cellRefs = nil
+	s.muInputs.Lock()
+	s.inputValues = nil
+	s.formValues = nil
+	if err := s.saveFormValuesLocked(); err != nil {
+		klog.Errorf("Failed to remove the form values: %+v", err)
+	}
+	s.muInputs.Unlock()
+	if err := s.RemoveGeneratedFiles(); err != nil {
+		klog.Errorf("Failed to remove generated files: %+v", err)
+	}
+	if err := s.RemoveCSources(); err != nil {
+		klog.Errorf("Failed to remove C source files: %+v", err)
+	}
+	if err := s.RemoveGoPackages(); err != nil {
+		klog.Errorf("Failed to remove Go package files: %+v", err)
+	}
+	if err := s.RemoveNotebookImports(); err != nil {
+		klog.Errorf("Failed to remove packages imported from notebooks: %+v", err)
+	}
 }
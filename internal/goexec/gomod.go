@@ -0,0 +1,200 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+)
+
+// This file implements the explicit management of the `go.mod` of the temporary module, with the special
+// commands `%go get`, `%go mod tidy` and `%replace`. The `go.mod` file persists across cell executions,
+// until it is re-initialized with `%reset go.mod`.
+
+// GoModPath is the path to the `go.mod` file of the temporary module.
+func (s *State) GoModPath() string {
+	return path.Join(s.TempDir, "go.mod")
+}
+
+// GoCommand executes `go get ...` or `go mod ...` in the temporary module, and reports the changes to
+// its dependencies.
+//
+// For `go mod tidy` the `main.go` is first composed with all the memorized declarations, so their
+// imports are taken into account.
+func (s *State) GoCommand(msg kernel.Message, args []string) (err error) {
+	if len(args) == 0 || (args[0] != "get" && args[0] != "mod") {
+		return errors.Errorf("%%go only supports the `get` and `mod` sub-commands, got %q", args)
+	}
+	if args[0] == "mod" && len(args) > 1 && args[1] == "tidy" {
+		// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+		err = s.AutoTrack()
+		if err != nil {
+			return
+		}
+		_, err = s.composeMemorizedDeclarations(msg)
+		if err != nil {
+			return
+		}
+	}
+
+	before, err := s.goModDependencies()
+	if err != nil {
+		return
+	}
+	executor, err := s.goExecutor(msg, args...)
+	if err == nil {
+		err = executor.Exec()
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "`go %s` failed", strings.Join(args, " "))
+	}
+	after, err := s.goModDependencies()
+	if err != nil {
+		return
+	}
+	return publishDependencyChanges(msg, before, after)
+}
+
+// Replace adds (or updates) a `replace` rule in `go.mod`, from the module to the target, which is either
+// a local directory or a `module@version`. Relative local directories are taken relative to the current
+// directory of the kernel.
+func (s *State) Replace(msg kernel.Message, module, target string) (err error) {
+	targetPath, targetVersion, _ := strings.Cut(target, "@")
+	if modfile.IsDirectoryPath(targetPath) {
+		targetPath, err = filepath.Abs(targetPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get absolute path of %q", target)
+		}
+		targetVersion = ""
+	} else if targetVersion == "" {
+		return errors.Errorf("%%replace target %q must be a local directory (e.g.: `../mymodule`) or "+
+			"a module with version (e.g.: `example.com/fork@v1.2.3`)", target)
+	}
+
+	before, err := s.goModDependencies()
+	if err != nil {
+		return
+	}
+	goModPath := s.GoModPath()
+	contents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.Parse(goModPath, contents, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	err = modFile.AddReplace(module, "", targetPath, targetVersion)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add replace rule from %q to %q", module, target)
+	}
+	contents, err = modFile.Format()
+	if err != nil {
+		return errors.Wrapf(err, "failed to format %q", goModPath)
+	}
+	err = os.WriteFile(goModPath, contents, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %q", goModPath)
+	}
+
+	// Makes sure the new local directory is tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	after, err := s.goModDependencies()
+	if err != nil {
+		return
+	}
+	return publishDependencyChanges(msg, before, after)
+}
+
+// goModDependencies returns the `require` and `replace` entries of `go.mod`, mapped to their versions (or
+// replacement targets). Entries are keyed by "require <module>" or "replace <module>".
+func (s *State) goModDependencies() (map[string]string, error) {
+	goModPath := s.GoModPath()
+	contents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	return parseDependencies(goModPath, contents)
+}
+
+// parseDependencies parses the contents of a `go.mod` file, see State.goModDependencies.
+func parseDependencies(goModPath string, contents []byte) (map[string]string, error) {
+	modFile, err := modfile.Parse(goModPath, contents, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	deps := make(map[string]string, len(modFile.Require)+len(modFile.Replace))
+	for _, require := range modFile.Require {
+		version := require.Mod.Version
+		if require.Indirect {
+			version += " // indirect"
+		}
+		deps["require "+require.Mod.Path] = version
+	}
+	for _, replace := range modFile.Replace {
+		target := replace.New.Path
+		if replace.New.Version != "" {
+			target += "@" + replace.New.Version
+		}
+		deps["replace "+replace.Old.Path] = target
+	}
+	return deps, nil
+}
+
+// dependencyChanges returns a human-readable list of the changes between two versions of the
+// dependencies, as returned by State.goModDependencies.
+func dependencyChanges(before, after map[string]string) (changes []string) {
+	for _, key := range SortedKeys(after) {
+		kind, module, _ := strings.Cut(key, " ")
+		oldValue, found := before[key]
+		newValue := after[key]
+		if !found {
+			if kind == "replace" {
+				changes = append(changes, fmt.Sprintf("added replace `%s` => `%s`", module, newValue))
+			} else {
+				changes = append(changes, fmt.Sprintf("added `%s %s`", module, newValue))
+			}
+		} else if oldValue != newValue {
+			if kind == "replace" {
+				changes = append(changes, fmt.Sprintf("changed replace `%s` => `%s` (was `%s`)", module, newValue, oldValue))
+			} else {
+				changes = append(changes, fmt.Sprintf("changed `%s` from `%s` to `%s`", module, oldValue, newValue))
+			}
+		}
+	}
+	for _, key := range SortedKeys(before) {
+		if _, found := after[key]; !found {
+			kind, module, _ := strings.Cut(key, " ")
+			if kind == "replace" {
+				changes = append(changes, fmt.Sprintf("removed replace of `%s`", module))
+			} else {
+				changes = append(changes, fmt.Sprintf("removed `%s %s`", module, before[key]))
+			}
+		}
+	}
+	return
+}
+
+// publishDependencyChanges reports the changes to the dependencies in `go.mod`.
+func publishDependencyChanges(msg kernel.Message, before, after map[string]string) error {
+	changes := dependencyChanges(before, after)
+	var sb strings.Builder
+	if len(changes) == 0 {
+		sb.WriteString("No changes to the dependencies in `go.mod`.\n")
+	} else {
+		sb.WriteString("**Changes to `go.mod`:**\n\n")
+		for _, change := range changes {
+			sb.WriteString(fmt.Sprintf("* %s\n", change))
+		}
+	}
+	return kernel.PublishMarkdown(msg, sb.String())
+}
@@ -0,0 +1,44 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyChanges(t *testing.T) {
+	before, err := parseDependencies("go.mod", []byte(`module gonb_1234
+
+go 1.21
+
+require (
+	github.com/pkg/errors v0.9.1
+	golang.org/x/exp v0.0.0-20240119083558-1b970713d09a // indirect
+	k8s.io/klog/v2 v2.120.1
+)
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "v0.0.0-20240119083558-1b970713d09a // indirect", before["require golang.org/x/exp"])
+
+	after, err := parseDependencies("go.mod", []byte(`module gonb_1234
+
+go 1.21
+
+require (
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.8.1
+	k8s.io/klog/v2 v2.110.1
+)
+
+replace github.com/pkg/errors => /home/user/errors
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"added replace `github.com/pkg/errors` => `/home/user/errors`",
+		"added `github.com/stretchr/testify v1.8.1`",
+		"changed `k8s.io/klog/v2` from `v2.120.1` to `v2.110.1`",
+		"removed `golang.org/x/exp v0.0.0-20240119083558-1b970713d09a // indirect`",
+	}, dependencyChanges(before, after))
+	assert.Empty(t, dependencyChanges(after, after))
+}
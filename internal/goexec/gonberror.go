@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // GonbError is a special type of error that wraps a collection of errors returned by
@@ -106,8 +107,21 @@ type errorLine struct {
 
 	HasCellInfo bool
 	CellInfo    string
+	CellTitle   string // Title of the group of errors of the cell (e.g.: "Cell[3]"), only if HasCellInfo == true.
+
+	Kind        string // One of errorLineKinds: used to color-code the line in the HTML report.
+	Excerpt     string // HTML of the offending source line, with the column marked, only if HasContext == true.
+	FixLocation string // Location of the error in the cell (or in the file), copied by the HTML report.
 }
 
+// Kinds of errorLine, used as suffix of their CSS class ("gonb-err-<kind>") in the HTML report.
+const (
+	errorLineKindError   = "error"   // Error with a location.
+	errorLineKindDetail  = "detail"  // Indented continuation of the previous error (e.g.: "have ..." / "want ...").
+	errorLineKindPackage = "package" // Header with the package of the following errors ("# <package>").
+	errorLineKindOther   = "other"   // Anything else printed by the Go tools.
+)
+
 // getTraceback renders the colored traceback sent to Jupyter for this errorLine.
 func (e *errorLine) getTraceback() (message string) {
 	if e.HasCellInfo {
@@ -149,16 +163,29 @@ func (s *State) parseErrorLine(lineStr string, codeLines []string, fileToCellIdA
 	if len(codeLines) == 0 || len(matches) != 6 {
 		l.HasContext = false
 		l.Message = lineStr
+		switch {
+		case strings.HasPrefix(lineStr, "# "):
+			l.Kind = errorLineKindPackage
+		case strings.HasPrefix(lineStr, "\t") || strings.HasPrefix(lineStr, "    "):
+			l.Kind = errorLineKindDetail
+		default:
+			l.Kind = errorLineKindOther
+		}
 		return
 	}
 
 	l.HasContext = true
+	l.Kind = errorLineKindError
 	l.Message = matches[5]
 	l.Location = matches[1]
+	l.FixLocation = strings.TrimSuffix(l.Location, ": ")
 
 	lineNum, _ := strconv.Atoi(matches[3])
 	lineNum -= 1 // Error messages start at line 1 (as opposed to 0)
-	//colNum, _ := strconv.Atoi(matches[4])
+	colNum, _ := strconv.Atoi(matches[4])
+	if lineNum >= 0 && lineNum < len(codeLines) {
+		l.Excerpt = excerptHTML(codeLines[lineNum], colNum)
+	}
 	fromLines := lineNum - LinesForErrorContext
 	fromLines = inBetween(fromLines, 0, len(codeLines)-1)
 	toLines := lineNum + LinesForErrorContext
@@ -187,9 +214,66 @@ func (s *State) parseErrorLine(lineStr string, codeLines []string, fileToCellIdA
 		// Notice GoNB store Lines starting at 0, but Jupyter display Lines starting at 1, so we add 1 here.
 		if cell.Id != -1 {
 			l.CellInfo = fmt.Sprintf("Cell[%d]: Line %d", cell.Id, cell.Line+1)
+			l.CellTitle = fmt.Sprintf("Cell[%d]", cell.Id)
 		} else {
 			l.CellInfo = fmt.Sprintf("Cell Line %d", cell.Line+1)
+			l.CellTitle = "Cell"
 		}
+		l.FixLocation = fmt.Sprintf("%s, Column %d", l.CellInfo, colNum)
 	}
 	return
 }
+
+// excerptHTML returns the source line in HTML, with the character at the column (starting at 1, in bytes,
+// as reported by the Go compiler) marked.
+func excerptHTML(line string, col int) string {
+	col = inBetween(col-1, 0, len(line))
+	marked, rest := " ", ""
+	if col < len(line) {
+		_, size := utf8.DecodeRuneInString(line[col:])
+		marked, rest = line[col:col+size], line[col+size:]
+	}
+	return fmt.Sprintf(`%s<span class="gonb-err-col">%s</span>%s`,
+		html.EscapeString(line[:col]), html.EscapeString(marked), html.EscapeString(rest))
+}
+
+// errorGroup is a group of errorLine of the same cell (or of the build output not associated to any cell),
+// folded together in the HTML report.
+type errorGroup struct {
+	Anchor    string // Id of the HTML element of the group, to navigate to it.
+	Title     string
+	NumErrors int
+	Lines     []errorLine
+}
+
+// Groups returns the lines of the error grouped by cell, in order of first appearance. Indented continuation
+// lines are kept with the error they follow, and lines not associated to any cell are grouped under
+// "Build output". Empty lines are dropped. anchorPrefix is used to create unique ids for the groups.
+func (nbErr *GonbError) Groups(anchorPrefix string) []errorGroup {
+	var groups []errorGroup
+	groupIdx := make(map[string]int)
+	current := -1
+	for _, line := range nbErr.Lines {
+		if strings.TrimSpace(line.Message) == "" && !line.HasContext {
+			continue
+		}
+		if line.Kind != errorLineKindDetail || current < 0 {
+			title := "Build output"
+			if line.HasCellInfo {
+				title = line.CellTitle
+			}
+			idx, found := groupIdx[title]
+			if !found {
+				idx = len(groups)
+				groupIdx[title] = idx
+				groups = append(groups, errorGroup{Anchor: fmt.Sprintf("%s-%d", anchorPrefix, idx), Title: title})
+			}
+			current = idx
+		}
+		if line.Kind == errorLineKindError {
+			groups[current].NumErrors++
+		}
+		groups[current].Lines = append(groups[current].Lines, line)
+	}
+	return groups
+}
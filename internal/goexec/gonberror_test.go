@@ -4,6 +4,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"os"
 	"testing"
 )
 
@@ -41,3 +42,37 @@ func TestRawError(t *testing.T) {
 	assert.True(t, errors.As(err, &gonbError))
 
 }
+
+func TestErrorGroups(t *testing.T) {
+	s := newEmptyStateWithRawError(t, true)
+	defer func() { require.NoError(t, s.Stop()) }()
+	mainGo := "package main\n\nfunc main() {\n\tx := 1\n\tvar y string = 1 < 2\n}\n"
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(mainGo), 0644))
+	fileToCellIdAndLine := make([]CellIdAndLine, 6)
+	for ii := range fileToCellIdAndLine {
+		fileToCellIdAndLine[ii] = CellIdAndLine{Id: -1, Line: NoCursorLine}
+	}
+	fileToCellIdAndLine[3] = CellIdAndLine{Id: 5, Line: 0}
+	fileToCellIdAndLine[4] = CellIdAndLine{Id: 7, Line: 2}
+
+	errorMsg := "# gonb_test\n" +
+		"./main.go:4:2: declared and not used: x\n" +
+		"./main.go:5:17: cannot use 1 < 2 (untyped bool constant) as string value in variable declaration\n" +
+		"\thave bool\n" +
+		"\n"
+	nbErr := newGonbErrors(s, fileToCellIdAndLine, errorMsg, errors.New("exit status 1"))
+	groups := nbErr.Groups("test")
+	require.Len(t, groups, 3)
+	assert.Equal(t, "Build output", groups[0].Title)
+	assert.Equal(t, errorLineKindPackage, groups[0].Lines[0].Kind)
+	assert.Equal(t, "Cell[5]", groups[1].Title)
+	assert.Equal(t, 1, groups[1].NumErrors)
+	assert.Equal(t, "Cell[5]: Line 1, Column 2", groups[1].Lines[0].FixLocation)
+	assert.Equal(t, `	<span class="gonb-err-col">x</span> := 1`, groups[1].Lines[0].Excerpt)
+	assert.Equal(t, "Cell[7]", groups[2].Title)
+	assert.Equal(t, "test-2", groups[2].Anchor)
+	// The indented continuation line is kept with its error.
+	require.Len(t, groups[2].Lines, 2)
+	assert.Equal(t, errorLineKindDetail, groups[2].Lines[1].Kind)
+	assert.Equal(t, `	var y string = <span class="gonb-err-col">1</span> &lt; 2`, groups[2].Lines[0].Excerpt)
+}
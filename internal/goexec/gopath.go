@@ -0,0 +1,98 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the isolated GOPATH option: each notebook gets its own GOPATH (and so its own
+// GOBIN and module cache) under State.TempDir, so helper binaries installed with `go install` and
+// downloaded modules don't collide between notebooks running concurrently on a shared machine.
+
+// IsolatedGoPathDir is the name of the directory (in State.TempDir) used as GOPATH, when
+// State.IsolatedGoPath is enabled.
+const IsolatedGoPathDir = "gopath"
+
+// isolatedGoPathEnvVars are the environment variables overridden when using an isolated GOPATH.
+var isolatedGoPathEnvVars = []string{"GOPATH", "GOBIN", "GOMODCACHE", "PATH"}
+
+// isolatedGoPathEnv returns the environment variables (in the form "key=value") of the isolated GOPATH,
+// except PATH.
+func (s *State) isolatedGoPathEnv() []string {
+	goPath := path.Join(s.TempDir, IsolatedGoPathDir)
+	return []string{
+		"GOPATH=" + goPath,
+		"GOBIN=" + path.Join(goPath, "bin"),
+		"GOMODCACHE=" + path.Join(goPath, "pkg", "mod"),
+	}
+}
+
+// SetIsolatedGoPath enables or disables the isolated GOPATH of the notebook, see IsolatedGoPathDir.
+//
+// The environment of the kernel is changed accordingly (GOPATH, GOBIN, GOMODCACHE, and GOBIN is
+// prepended to PATH), so it also applies to shell commands (e.g.: `!go install ...`). Disabling it
+// restores the previous environment, but the isolated GOPATH is only removed when the kernel stops.
+func (s *State) SetIsolatedGoPath(enabled bool) error {
+	if enabled == s.IsolatedGoPath {
+		return nil
+	}
+	if !enabled {
+		for _, key := range isolatedGoPathEnvVars {
+			value, found := s.isolatedGoPathPrevEnv[key]
+			var err error
+			if found {
+				err = os.Setenv(key, value)
+			} else {
+				err = os.Unsetenv(key)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to restore environment variable %q", key)
+			}
+		}
+		s.IsolatedGoPath = false
+		s.isolatedGoPathPrevEnv = nil
+		s.UpdateGoplsSettings()
+		return nil
+	}
+
+	goBin := path.Join(s.TempDir, IsolatedGoPathDir, "bin")
+	if err := os.MkdirAll(goBin, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create isolated GOPATH in %q", path.Dir(goBin))
+	}
+	s.isolatedGoPathPrevEnv = make(map[string]string)
+	for _, key := range isolatedGoPathEnvVars {
+		if value, found := os.LookupEnv(key); found {
+			s.isolatedGoPathPrevEnv[key] = value
+		}
+	}
+	env := append(s.isolatedGoPathEnv(), "PATH="+goBin+string(filepath.ListSeparator)+os.Getenv("PATH"))
+	for _, keyValue := range env {
+		key, value, _ := strings.Cut(keyValue, "=")
+		if err := os.Setenv(key, value); err != nil {
+			return errors.Wrapf(err, "failed to set environment variable %q", key)
+		}
+	}
+	s.IsolatedGoPath = true
+	s.UpdateGoplsSettings()
+	klog.V(1).Infof("Using isolated GOPATH %q", path.Dir(goBin))
+	return nil
+}
+
+// removeIsolatedGoPath removes the isolated GOPATH, if it was created. The module cache is read-only,
+// so it needs to be removed with `go clean -modcache`.
+func (s *State) removeIsolatedGoPath() {
+	goPath := path.Join(s.TempDir, IsolatedGoPathDir)
+	if _, err := os.Stat(goPath); err != nil {
+		return
+	}
+	cmd := s.goCommand("clean", "-modcache")
+	cmd.Env = append(cmd.Environ(), s.isolatedGoPathEnv()...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		klog.Errorf("Failed to clean module cache of the isolated GOPATH %q: %+v\n%s", goPath, err, output)
+	}
+}
@@ -0,0 +1,35 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetIsolatedGoPath(t *testing.T) {
+	// t.Setenv restores the environment at the end of the test.
+	t.Setenv("GOPATH", "/home/user/go")
+	t.Setenv("GOBIN", "")
+	require.NoError(t, os.Unsetenv("GOBIN"))
+	t.Setenv("PATH", os.Getenv("PATH"))
+	t.Setenv("GOMODCACHE", os.Getenv("GOMODCACHE"))
+	originalPath := os.Getenv("PATH")
+
+	s := &State{TempDir: t.TempDir()}
+	require.NoError(t, s.SetIsolatedGoPath(true))
+	goPath := path.Join(s.TempDir, IsolatedGoPathDir)
+	assert.Equal(t, goPath, os.Getenv("GOPATH"))
+	assert.Equal(t, path.Join(goPath, "bin"), os.Getenv("GOBIN"))
+	assert.True(t, strings.HasPrefix(os.Getenv("PATH"), path.Join(goPath, "bin")))
+	assert.DirExists(t, path.Join(goPath, "bin"))
+
+	require.NoError(t, s.SetIsolatedGoPath(false))
+	assert.Equal(t, "/home/user/go", os.Getenv("GOPATH"))
+	_, found := os.LookupEnv("GOBIN")
+	assert.False(t, found)
+	assert.Equal(t, originalPath, os.Getenv("PATH"))
+}
@@ -0,0 +1,171 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the Go package cells (`%%gopkg <dir>/<file>.go`): the body of the cell is written as a
+// file of a package in the temporary module, so a notebook can contain an actual multi-file package. The
+// packages are imported by the following cells (`goimports` removes the ones not used), and are seen by
+// `gopls`, so completion works as usual.
+
+// goPkgFile holds where a file written with `%%gopkg` (or `%%protoc`) was defined: the cell and the line in the
+// cell of its first line, and the number of lines prepended (the package clause, if missing). Files generated
+// by `%%protoc` have no corresponding cell lines.
+type goPkgFile struct {
+	cellId, firstLine, prepended int
+	pkgName                      string
+	generated                    bool
+}
+
+// reNonIdentifier matches the characters not allowed in a package name.
+var reNonIdentifier = regexp.MustCompile(`[^\p{L}\p{Nd}_]`)
+
+// goPkgPath validates and cleans the path of a file written with `%%gopkg`: it must be a `.go` file in a
+// directory (the package) relative to the temporary module.
+func goPkgPath(filePath string) (string, error) {
+	cleaned := path.Clean(filePath)
+	switch {
+	case filePath == "" || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../"):
+		return "", errors.Errorf("%%%%gopkg requires a path relative to the module, e.g. `%%%%gopkg utils/strings.go`, got %q", filePath)
+	case path.Ext(cleaned) != ".go":
+		return "", errors.Errorf("%%%%gopkg file %q must have the extension `.go`", filePath)
+	case path.Dir(cleaned) == ".":
+		return "", errors.Errorf("%%%%gopkg file %q must be in a package directory, e.g. `utils/%s`", filePath, cleaned)
+	case strings.HasPrefix(cleaned, NotebookImportsDir+"/"):
+		return "", errors.Errorf("%%%%gopkg directory %q is reserved for %%nbimport", NotebookImportsDir)
+	}
+	return cleaned, nil
+}
+
+// WriteGoPackageFile writes the contents of a Go file, defined in the cell cellId starting at line firstLine,
+// in a package directory of the temporary module. If the contents have no package clause, one is prepended,
+// with the name of the directory. The package is imported by the following cells.
+//
+// Files whose contents didn't change are not rewritten, so their modification time is preserved, and the
+// build cache and `gopls` don't reprocess them.
+func (s *State) WriteGoPackageFile(msg kernel.Message, cellId, firstLine int, filePath, contents string) error {
+	relPath, err := goPkgPath(filePath)
+	if err != nil {
+		return err
+	}
+	source := goPkgFile{cellId: cellId, firstLine: firstLine}
+	fileSet := token.NewFileSet()
+	if parsed, err := parser.ParseFile(fileSet, relPath, contents, parser.PackageClauseOnly); err == nil {
+		source.pkgName = parsed.Name.Name
+	} else {
+		source.pkgName = reNonIdentifier.ReplaceAllString(path.Base(path.Dir(relPath)), "_")
+		if !token.IsIdentifier(source.pkgName) {
+			source.pkgName = "_" + source.pkgName
+		}
+		contents = fmt.Sprintf("package %s\n%s", source.pkgName, contents)
+		source.prepended = 1
+	}
+	if source.pkgName == "main" {
+		return errors.Errorf("%%%%gopkg file %q can't be in package `main`", filePath)
+	}
+	for otherPath, other := range s.goPkgFiles {
+		if otherPath != relPath && path.Dir(otherPath) == path.Dir(relPath) && other.pkgName != source.pkgName {
+			return errors.Errorf("%%%%gopkg file %q is in package %q, but %q is in package %q", filePath,
+				source.pkgName, otherPath, other.pkgName)
+		}
+	}
+
+	fullPath := path.Join(s.TempDir, relPath)
+	status := "unchanged"
+	if current, err := os.ReadFile(fullPath); err != nil || !bytes.Equal(current, []byte(contents)) {
+		if err := os.MkdirAll(path.Dir(fullPath), 0755); err != nil {
+			return errors.Wrapf(err, "failed to create directory for %q", fullPath)
+		}
+		if err := os.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %q", fullPath)
+		}
+		status = "written"
+	}
+	if s.goPkgFiles == nil {
+		s.goPkgFiles = make(map[string]goPkgFile)
+	}
+	s.goPkgFiles[relPath] = source
+	if msg == nil {
+		return nil
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("%%%%gopkg: %s %s, package `%s` (%q).\n", relPath, status, source.pkgName,
+			s.goPkgImportPath(relPath)))
+}
+
+// goPkgImportPath returns the import path of the package of a file written with `%%gopkg`.
+func (s *State) goPkgImportPath(relPath string) string {
+	return path.Join(s.Package, path.Dir(relPath))
+}
+
+// addGoPackageImports adds the imports of the packages written with `%%gopkg` to the declarations, if not
+// imported otherwise. `goimports` removes the ones not used.
+func (s *State) addGoPackageImports(decls *Declarations) {
+	for _, relPath := range SortedKeys(s.goPkgFiles) {
+		importPath := s.goPkgImportPath(relPath)
+		pkgName := s.goPkgFiles[relPath].pkgName
+		if _, found := decls.Imports[pkgName]; found {
+			// Already imported, or the name is used by another import.
+			continue
+		}
+		alias := ""
+		if pkgName != path.Base(importPath) {
+			alias = pkgName
+		}
+		imp := NewImport(importPath, alias)
+		imp.Cursor = NoCursor
+		decls.Imports[imp.Key] = imp
+	}
+}
+
+// RemoveGoPackages removes the files written with `%%gopkg`, and the package directories left empty.
+func (s *State) RemoveGoPackages() error {
+	for relPath := range s.goPkgFiles {
+		fullPath := path.Join(s.TempDir, relPath)
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %q", fullPath)
+		}
+		for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+			if os.Remove(path.Join(s.TempDir, dir)) != nil {
+				break // Not empty.
+			}
+		}
+	}
+	s.goPkgFiles = nil
+	return nil
+}
+
+var reGoPkgReference = regexp.MustCompile(`(?:\./)?([^\s():]+\.(?:go|proto)):(\d+)(:\d+)?`)
+
+// mapGoPackageReferences annotates references to lines of the files written with `%%gopkg` or `%%protoc`
+// (e.g.: `utils/strings.go:12:3`) in the output of the compiler with the corresponding cell lines.
+func (s *State) mapGoPackageReferences(output string) string {
+	if len(s.goPkgFiles) == 0 {
+		return output
+	}
+	return reGoPkgReference.ReplaceAllStringFunc(output, func(ref string) string {
+		parts := reGoPkgReference.FindStringSubmatch(ref)
+		source, found := s.goPkgFiles[parts[1]]
+		if !found || source.generated {
+			return ref
+		}
+		lineNum, err := strconv.Atoi(parts[2])
+		if err != nil || lineNum <= source.prepended {
+			return ref
+		}
+		return fmt.Sprintf("%s (Cell[%d]: Line %d)", ref, source.cellId, source.firstLine+lineNum-source.prepended)
+	})
+}
@@ -0,0 +1,55 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoPackageFiles(t *testing.T) {
+	s := &State{TempDir: t.TempDir(), Package: "gonb_test"}
+	require.Error(t, s.WriteGoPackageFile(nil, 3, 1, "../utils/strings.go", ""))
+	require.Error(t, s.WriteGoPackageFile(nil, 3, 1, "strings.go", ""))
+	require.Error(t, s.WriteGoPackageFile(nil, 3, 1, "utils/strings.c", ""))
+	require.Error(t, s.WriteGoPackageFile(nil, 3, 1, "utils/main.go", "package main\n"))
+
+	// Package clause added if missing.
+	require.NoError(t, s.WriteGoPackageFile(nil, 3, 1, "utils/strings.go", "func Reverse(s string) string {\n\treturn y\n}\n"))
+	filePath := path.Join(s.TempDir, "utils", "strings.go")
+	contents, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "package utils\nfunc Reverse(s string) string {\n\treturn y\n}\n", string(contents))
+
+	// Unchanged contents are not rewritten.
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(filePath, past, past))
+	require.NoError(t, s.WriteGoPackageFile(nil, 3, 1, "utils/strings.go", "func Reverse(s string) string {\n\treturn y\n}\n"))
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, past, info.ModTime())
+
+	// Other files of the same package, with an explicit package clause.
+	require.Error(t, s.WriteGoPackageFile(nil, 4, 1, "utils/more.go", "package other\n"))
+	require.NoError(t, s.WriteGoPackageFile(nil, 4, 1, "utils/more.go", "package utils\n\nconst X = 1\n"))
+
+	output := "# gonb_test/utils\nutils/strings.go:3:9: undefined: y\nmain.go:3:1: other\n"
+	assert.Equal(t, "# gonb_test/utils\nutils/strings.go:3:9 (Cell[3]: Line 3): undefined: y\nmain.go:3:1: other\n",
+		s.mapGoPackageReferences(output))
+
+	// Following cells import it, once.
+	decls := NewDeclarations()
+	s.addGoPackageImports(decls)
+	require.Len(t, decls.Imports, 1)
+	require.Contains(t, decls.Imports, "utils")
+	assert.Equal(t, "gonb_test/utils", decls.Imports["utils"].Path)
+
+	require.NoError(t, s.RemoveGoPackages())
+	assert.NoDirExists(t, path.Join(s.TempDir, "utils"))
+	decls = NewDeclarations()
+	s.addGoPackageImports(decls)
+	assert.Empty(t, decls.Imports)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"k8s.io/klog/v2"
 	"net"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -61,7 +62,7 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	netMethod := "tcp"
 	addr := c.address
-	if strings.HasPrefix(addr, "/") {
+	if filepath.IsAbs(addr) {
 		netMethod = "unix"
 	} else if strings.HasPrefix(addr, "unix;") {
 		netMethod = "unix"
@@ -89,9 +90,12 @@ func (c *Client) Connect(ctx context.Context) error {
 	}(c.conn)
 
 	callId, err := c.jsonConn.Call(ctx, lsp.MethodInitialize, &lsp.InitializeParams{
-		ProcessID: 0,
-		RootURI:   uri.File(c.dir),
-		// Capabilities:          lsp.ClientCapabilities{},
+		ProcessID:             0,
+		RootURI:               uri.File(c.dir),
+		InitializationOptions: c.Settings(),
+		Capabilities: lsp.ClientCapabilities{
+			Workspace: &lsp.WorkspaceClientCapabilities{Configuration: true},
+		},
 	}, &c.lspCapabilities)
 	_ = callId // Not used now.
 	if err != nil {
@@ -353,6 +357,167 @@ func (c *Client) callCompleteLocked(ctx context.Context, filePath string, line,
 	return
 }
 
+// CallCodeAction service in `gopls`. It returns the code actions available for the given diagnostic
+// of the file.
+//
+// This will automatically call NotifyDidOpenOrChange, if file hasn't been sent yet.
+func (c *Client) CallCodeAction(ctx context.Context, filePath string, diagnostic lsp.Diagnostic) (actions []lsp.CodeAction, err error) {
+	if !c.WaitConnection(ctx) {
+		// Silently do nothing, if no connection available.
+		return
+	}
+	ctx = minTimeout(ctx, CommunicationTimeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	return c.callCodeActionLocked(ctx, filePath, diagnostic)
+}
+
+func (c *Client) callCodeActionLocked(ctx context.Context, filePath string, diagnostic lsp.Diagnostic) (actions []lsp.CodeAction, err error) {
+	klog.V(2).Infof("goplsclient.CallCodeAction(ctx, %s, %q)", uri.File(filePath), diagnostic.Message)
+	if _, found := c.fileVersions[filePath]; !found {
+		err = c.notifyDidOpenOrChangeLocked(ctx, filePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	params := &lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(filePath),
+		},
+		Context: lsp.CodeActionContext{
+			Diagnostics: []lsp.Diagnostic{diagnostic},
+		},
+		Range: diagnostic.Range,
+	}
+	_, err = c.jsonConn.Call(ctx, lsp.MethodTextDocumentCodeAction, params, &actions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed call to `gopls` \"code_action_request\"")
+	}
+	return
+}
+
+// CallRename service in `gopls`. It returns the edits needed to rename the identifier under the
+// given position to newName.
+//
+// This will automatically call NotifyDidOpenOrChange, if file hasn't been sent yet.
+func (c *Client) CallRename(ctx context.Context, filePath string, line, col int, newName string) (workspaceEdit *lsp.WorkspaceEdit, err error) {
+	if !c.WaitConnection(ctx) {
+		// Silently do nothing, if no connection available.
+		return
+	}
+	ctx = minTimeout(ctx, CommunicationTimeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	klog.V(2).Infof("goplsclient.CallRename(ctx, %s, %d, %d, %q)", uri.File(filePath), line, col, newName)
+	if _, found := c.fileVersions[filePath]; !found {
+		err = c.notifyDidOpenOrChangeLocked(ctx, filePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	params := &lsp.RenameParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(filePath),
+		},
+		Position: lsp.Position{
+			Line:      float64(line),
+			Character: float64(col),
+		},
+		NewName: newName,
+	}
+	workspaceEdit = &lsp.WorkspaceEdit{}
+	_, err = c.jsonConn.Call(ctx, lsp.MethodTextDocumentRename, params, workspaceEdit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed call to `gopls` \"rename_request\"")
+	}
+	return
+}
+
+// CallReferences service in `gopls`. It returns the locations where the identifier under the given
+// position is referenced, excluding its declaration.
+//
+// This will automatically call NotifyDidOpenOrChange, if file hasn't been sent yet.
+func (c *Client) CallReferences(ctx context.Context, filePath string, line, col int) (results []lsp.Location, err error) {
+	if !c.WaitConnection(ctx) {
+		// Silently do nothing, if no connection available.
+		return
+	}
+	ctx = minTimeout(ctx, CommunicationTimeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	klog.V(2).Infof("goplsclient.CallReferences(ctx, %s, %d, %d)", uri.File(filePath), line, col)
+	if _, found := c.fileVersions[filePath]; !found {
+		err = c.notifyDidOpenOrChangeLocked(ctx, filePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	params := &lsp.ReferenceParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(filePath),
+			},
+			Position: lsp.Position{
+				Line:      float64(line),
+				Character: float64(col),
+			},
+		},
+		Context: lsp.ReferenceContext{IncludeDeclaration: false},
+	}
+	_, err = c.jsonConn.Call(ctx, lsp.MethodTextDocumentReferences, params, &results)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed call to `gopls` \"references_request\"")
+	}
+	return
+}
+
+// CallExecuteCommand service in `gopls`. Some code actions are not returned with their edits, instead
+// `gopls` requires a command to be executed, which in turn asks the client to apply the edits.
+// These edits are collected and returned.
+func (c *Client) CallExecuteCommand(ctx context.Context, command *lsp.Command) (edits []lsp.WorkspaceEdit, err error) {
+	if !c.WaitConnection(ctx) {
+		// Silently do nothing, if no connection available.
+		return
+	}
+	ctx = minTimeout(ctx, CommunicationTimeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+
+	klog.V(2).Infof("goplsclient.CallExecuteCommand(ctx, %q)", command.Command)
+	c.muDiagnostics.Lock()
+	c.appliedEdits = nil
+	c.muDiagnostics.Unlock()
+	params := &lsp.ExecuteCommandParams{
+		Command:   command.Command,
+		Arguments: command.Arguments,
+	}
+	var result any
+	_, err = c.jsonConn.Call(ctx, lsp.MethodWorkspaceExecuteCommand, params, &result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed call to `gopls` \"execute_command\" for %q", command.Command)
+	}
+	c.muDiagnostics.Lock()
+	edits = c.appliedEdits
+	c.appliedEdits = nil
+	c.muDiagnostics.Unlock()
+	return
+}
+
 func (c *Client) ConsumeMessages() []string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -370,7 +535,6 @@ func trimString(s string, maxLen int) string {
 
 // Handler implements jsonrpc2.Handler, and receives messages initiated by gopls.
 func (c *Client) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
-	_ = ctx
 	switch req.Method() {
 	case lsp.MethodWindowShowMessage:
 		var params lsp.ShowMessageParams
@@ -402,12 +566,77 @@ func (c *Client) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 		for _, diag := range params.Diagnostics {
 			c.messages = append(c.messages, diag.Message)
 		}
+		c.muDiagnostics.Lock()
+		c.diagnostics[uri.URI(params.URI).Filename()] = &fileDiagnostics{
+			version:     int(params.Version),
+			diagnostics: params.Diagnostics,
+		}
+		c.muDiagnostics.Unlock()
 		if (klog.V(2).Enabled() && len(params.Diagnostics) > 0) || klog.V(3).Enabled() {
 			klog.V(2).Infof("received gopls diagnostics: %+v",
 				trimString(fmt.Sprintf("%+v", params), 100))
 		}
+
+	case lsp.MethodWorkspaceApplyEdit:
+		// gopls asks the client to apply edits when executing some commands (see CallExecuteCommand):
+		// we simply collect them, and they are applied by the caller.
+		var params lsp.ApplyWorkspaceEditParams
+		err := json.Unmarshal(req.Params(), &params)
+		if err != nil {
+			klog.Errorf("Failed to parse ApplyWorkspaceEditParams: %v", err)
+			return reply(ctx, nil, err)
+		}
+		c.muDiagnostics.Lock()
+		c.appliedEdits = append(c.appliedEdits, params.Edit)
+		c.muDiagnostics.Unlock()
+		return reply(ctx, &lsp.ApplyWorkspaceEditResponse{Applied: true}, nil)
+
+	case lsp.MethodWorkspaceConfiguration:
+		// gopls asks for its settings: the same settings are used for every section and scope.
+		var params lsp.ConfigurationParams
+		err := json.Unmarshal(req.Params(), &params)
+		if err != nil {
+			klog.Errorf("Failed to parse ConfigurationParams: %v", err)
+			return reply(ctx, nil, err)
+		}
+		settings := c.Settings()
+		results := make([]any, len(params.Items))
+		for ii := range results {
+			results[ii] = settings
+		}
+		return reply(ctx, results, nil)
+
 	default:
 		klog.Errorf("gopls jsonrpc2 message delivered to GoNB but not handled: %q", req.Method())
 	}
 	return nil
 }
+
+// Settings returns the current settings of `gopls`, see SetSettings.
+func (c *Client) Settings() map[string]any {
+	c.muSettings.Lock()
+	defer c.muSettings.Unlock()
+	return c.settings
+}
+
+// SetSettings configures `gopls` (e.g.: {"buildFlags": []string{"-tags=integration"}}), see
+// https://github.com/golang/tools/blob/master/gopls/doc/settings.md.
+//
+// If already connected, `gopls` is notified of the change, and it will fetch the new settings.
+// Otherwise, they are used when connecting.
+func (c *Client) SetSettings(ctx context.Context, settings map[string]any) error {
+	c.muSettings.Lock()
+	c.settings = settings
+	c.muSettings.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.jsonConn.Notify(ctx, lsp.MethodWorkspaceDidChangeConfiguration, &lsp.DidChangeConfigurationParams{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to notify gopls of the new settings")
+	}
+	return nil
+}
@@ -5,8 +5,8 @@ import (
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -40,7 +40,7 @@ func (c *Client) Start() error {
 	}
 
 	addr := c.Address()
-	if strings.HasPrefix(addr, "/") {
+	if filepath.IsAbs(addr) {
 		addr = "unix;" + addr
 	}
 	c.goplsExec = exec.Command(goplsPath, "-listen", addr)
@@ -49,7 +49,7 @@ func (c *Client) Start() error {
 	// the kernel receives from Jupyter and dying.
 	// Not sure on the status of MacOS:
 	// https://stackoverflow.com/questions/43364958/start-command-with-new-process-group-id-golang
-	c.goplsExec.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: 0}
+	setNewProcessGroup(c.goplsExec)
 	c.goplsExec.Dir = c.dir
 	klog.Infof("Executing %q", c.goplsExec)
 	err = c.goplsExec.Start()
@@ -58,6 +58,7 @@ func (c *Client) Start() error {
 		close(c.stop)
 		return err
 	}
+	c.numStarts.Add(1)
 
 	c.waitConnecting = true
 
@@ -92,6 +93,7 @@ func (c *Client) Start() error {
 		err := c.goplsExec.Wait()
 		if err != nil {
 			klog.Warningf("gopls failed with: %+v", err)
+			c.numFailures.Add(1)
 		} else {
 			klog.V(2).Infof("gopls terminated normally.")
 		}
@@ -161,9 +163,15 @@ func (c *Client) removeUnixSocketFile() {
 	if strings.HasPrefix(addr, "unix;") {
 		addr = addr[5:]
 	}
-	if strings.HasPrefix(addr, "/") {
+	if filepath.IsAbs(addr) {
 		klog.V(2).Infof("Removing %s", addr)
 		// Remove unix socket file, if it exists -- we ignore any errors.
 		_ = os.Remove(addr)
 	}
 }
+
+// Starts returns the number of times `gopls` was started, and how many of its executions failed (as opposed
+// to terminating normally). They are reported by `%stats`.
+func (c *Client) Starts() (starts, failures int) {
+	return int(c.numStarts.Load()), int(c.numFailures.Load())
+}
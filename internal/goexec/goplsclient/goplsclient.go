@@ -24,8 +24,10 @@ import (
 	"net"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lsp "github.com/go-language-server/protocol"
@@ -51,12 +53,34 @@ type Client struct {
 	stop           chan struct{}
 	waitConnecting bool
 
+	// Counters of executions of `gopls`, see Client.Starts.
+	numStarts, numFailures atomic.Int32
+
 	// File cache.
 	fileVersions map[string]int       // Every open file that has been sent to gopls has a version, that is bumped when it is sent again.
 	fileCache    map[string]*FileData // Cache of files stored in disk.
 
 	// Messages: they should be reset whenever they have been consumed.
 	messages []string
+
+	// Diagnostics published by `gopls` per file, and edits requested by `gopls` (with "workspace/applyEdit")
+	// while executing commands. They have their own mutex, because they are updated by Handler, while
+	// Client.mu may be held by a pending call.
+	muDiagnostics sync.Mutex
+	diagnostics   map[string]*fileDiagnostics
+	appliedEdits  []lsp.WorkspaceEdit
+
+	// Settings of `gopls` (e.g.: "buildFlags", "env"), sent on initialization and whenever requested
+	// by `gopls` with "workspace/configuration". See SetSettings.
+	muSettings sync.Mutex
+	settings   map[string]any
+}
+
+// fileDiagnostics holds the last diagnostics published by `gopls` for a file, and the version of the file
+// they refer to.
+type fileDiagnostics struct {
+	version     int
+	diagnostics []lsp.Diagnostic
 }
 
 // New returns a new Client in the directory. The returned Client does not yet start
@@ -69,9 +93,10 @@ type Client struct {
 func New(dir string) *Client {
 	c := &Client{
 		dir:          dir,
-		address:      path.Join(dir, "gopls_socket"),
+		address:      filepath.Join(dir, "gopls_socket"),
 		fileVersions: make(map[string]int),
 		fileCache:    make(map[string]*FileData),
+		diagnostics:  make(map[string]*fileDiagnostics),
 
 		stop: nil, // gopls starts stopped.
 	}
@@ -145,9 +170,10 @@ func (c *Client) Definition(ctx context.Context, filePath string, line, col int)
 }
 
 // Complete request auto-complete suggestions from `gopls`. It returns the text
-// of the matches and the number of characters before the cursor position that should
+// of the matches, their kinds (lower-cased LSP completion kinds, e.g.: "function" or "variable"),
+// and the number of characters before the cursor position that should
 // be replaced by the matches (the same value for every entry).
-func (c *Client) Complete(ctx context.Context, filePath string, line, col int) (matches []string, replaceLength int, err error) {
+func (c *Client) Complete(ctx context.Context, filePath string, line, col int) (matches, kinds []string, replaceLength int, err error) {
 	klog.V(2).Infof("goplsclient.Complete(ctx, %s, %d, %d)", filePath, line, col)
 	err = c.NotifyDidOpenOrChange(ctx, filePath)
 	if err != nil {
@@ -183,6 +209,7 @@ func (c *Client) Complete(ctx context.Context, filePath string, line, col int) (
 		}
 		replaceLength = newReplaceLength
 		matches = append(matches, edit.NewText)
+		kinds = append(kinds, completionKind(item.Kind))
 	}
 	if len(items.Items) != len(matches) {
 		klog.Infof("Complete found %d items, used only %d", len(items.Items), len(matches))
@@ -190,6 +217,170 @@ func (c *Client) Complete(ctx context.Context, filePath string, line, col int) (
 	return
 }
 
+// completionKind returns the lower-cased name of the LSP completion kind, or "" if it is not set.
+func completionKind(kind lsp.CompletionItemKind) string {
+	if kind < lsp.TextCompletion || kind > lsp.TypeParameterCompletion {
+		return ""
+	}
+	return strings.ToLower(kind.String())
+}
+
+// DiagnosticsTimeout is the maximum time to wait for `gopls` to publish the diagnostics of the
+// latest version of a file.
+var DiagnosticsTimeout = 3 * time.Second
+
+// Diagnostics returns the diagnostics published by `gopls` for the file. It first sends the file
+// to `gopls` (see NotifyDidOpenOrChange), and then waits (up to DiagnosticsTimeout) for the diagnostics
+// of its current version to be published.
+func (c *Client) Diagnostics(ctx context.Context, filePath string) (diagnostics []lsp.Diagnostic, err error) {
+	klog.V(2).Infof("goplsclient.Diagnostics(ctx, %s)", filePath)
+	err = c.NotifyDidOpenOrChange(ctx, filePath)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	version := c.fileVersions[filePath]
+	c.mu.Unlock()
+
+	deadline := time.Now().Add(DiagnosticsTimeout)
+	for {
+		c.muDiagnostics.Lock()
+		fileDiags, found := c.diagnostics[filePath]
+		c.muDiagnostics.Unlock()
+		if found && (fileDiags.version == 0 || fileDiags.version >= version) {
+			return fileDiags.diagnostics, nil
+		}
+		if time.Now().After(deadline) {
+			if found {
+				// Return the older diagnostics, better than nothing.
+				klog.Warningf("gopls didn't publish diagnostics for version %d of %q, using version %d",
+					version, filePath, fileDiags.version)
+				return fileDiags.diagnostics, nil
+			}
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "waiting for diagnostics of %q", filePath)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// CodeAction offered by `gopls` to fix a diagnostic of a file. See Client.QuickFixes.
+type CodeAction struct {
+	Diagnostic lsp.Diagnostic
+	Title      string
+	Kind       lsp.CodeActionKind
+
+	edit    *lsp.WorkspaceEdit
+	command *lsp.Command
+}
+
+// QuickFixes returns the diagnostics of the file and the code actions offered by `gopls` to fix them.
+// Actions are returned in the order of the diagnostics.
+//
+// Only actions of kind "quickfix" and "refactor" are returned.
+func (c *Client) QuickFixes(ctx context.Context, filePath string) (diagnostics []lsp.Diagnostic, actions []*CodeAction, err error) {
+	klog.V(2).Infof("goplsclient.QuickFixes(ctx, %s)", filePath)
+	diagnostics, err = c.Diagnostics(ctx, filePath)
+	if err != nil {
+		return
+	}
+	for _, diag := range diagnostics {
+		var results []lsp.CodeAction
+		results, err = c.CallCodeAction(ctx, filePath, diag)
+		if err != nil {
+			return
+		}
+		for _, result := range results {
+			kind := string(result.Kind)
+			if kind != "" && !strings.HasPrefix(kind, string(lsp.QuickFix)) && !strings.HasPrefix(kind, string(lsp.Refactor)) {
+				continue
+			}
+			if result.Edit == nil && result.Command == nil {
+				continue
+			}
+			actions = append(actions, &CodeAction{
+				Diagnostic: diag,
+				Title:      result.Title,
+				Kind:       result.Kind,
+				edit:       result.Edit,
+				command:    result.Command,
+			})
+		}
+	}
+	return
+}
+
+// Edits returns the text edits to the file filePath required by the code action. It may involve
+// asking `gopls` to execute a command. Edits to other files are ignored.
+func (c *Client) Edits(ctx context.Context, filePath string, action *CodeAction) (edits []lsp.TextEdit, err error) {
+	workspaceEdits := make([]lsp.WorkspaceEdit, 0, 1)
+	if action.edit != nil {
+		workspaceEdits = append(workspaceEdits, *action.edit)
+	}
+	if action.command != nil {
+		var commandEdits []lsp.WorkspaceEdit
+		commandEdits, err = c.CallExecuteCommand(ctx, action.command)
+		if err != nil {
+			return
+		}
+		workspaceEdits = append(workspaceEdits, commandEdits...)
+	}
+	edits = fileEdits(filePath, workspaceEdits)
+	return
+}
+
+// Rename asks `gopls` to rename the identifier at the given position to newName. It returns the
+// text edits to the file filePath. Edits to other files are ignored.
+func (c *Client) Rename(ctx context.Context, filePath string, line, col int, newName string) (edits []lsp.TextEdit, err error) {
+	klog.V(2).Infof("goplsclient.Rename(ctx, %s, %d, %d, %q)", filePath, line, col, newName)
+	err = c.NotifyDidOpenOrChange(ctx, filePath)
+	if err != nil {
+		return
+	}
+	var workspaceEdit *lsp.WorkspaceEdit
+	workspaceEdit, err = c.CallRename(ctx, filePath, line, col, newName)
+	if err != nil || workspaceEdit == nil {
+		return
+	}
+	edits = fileEdits(filePath, []lsp.WorkspaceEdit{*workspaceEdit})
+	return
+}
+
+// References returns the locations where the identifier at the given position is referenced,
+// excluding its declaration.
+func (c *Client) References(ctx context.Context, filePath string, line, col int) (locations []lsp.Location, err error) {
+	klog.V(2).Infof("goplsclient.References(ctx, %s, %d, %d)", filePath, line, col)
+	err = c.NotifyDidOpenOrChange(ctx, filePath)
+	if err != nil {
+		return
+	}
+	return c.CallReferences(ctx, filePath, line, col)
+}
+
+// fileEdits returns the text edits to filePath in workspaceEdits. Edits to other files are ignored.
+func fileEdits(filePath string, workspaceEdits []lsp.WorkspaceEdit) (edits []lsp.TextEdit) {
+	for _, workspaceEdit := range workspaceEdits {
+		for fileURI, textEdits := range workspaceEdit.Changes {
+			if fileURI.Filename() == filePath {
+				edits = append(edits, textEdits...)
+			} else {
+				klog.Warningf("gopls edits to %q ignored", fileURI.Filename())
+			}
+		}
+		for _, docEdit := range workspaceEdit.DocumentChanges {
+			if docEdit.TextDocument.URI.Filename() == filePath {
+				edits = append(edits, docEdit.Edits...)
+			} else {
+				klog.Warningf("gopls edits to %q ignored", docEdit.TextDocument.URI.Filename())
+			}
+		}
+	}
+	return
+}
+
 // Span returns the text spanning the given location (`lsp.Location` represents a range).
 func (c *Client) Span(loc lsp.Location) (string, error) {
 	fileData, _, err := c.FileData(loc.URI.Filename())
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package goplsclient
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setNewProcessGroup configures the command to start on its own process group.
+func setNewProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: 0}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package goplsclient
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setNewProcessGroup configures the command to start on its own process group, so it doesn't receive the
+// console control events (Control+C) sent to the kernel.
+func setNewProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -0,0 +1,141 @@
+package goexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the configuration of the module proxy and checksum database (`%config goproxy=...`
+// and `%config gosumdb=...`), and the diagnosis of failures to fetch modules.
+
+// ModuleEnvVars are the environment variables that configure how the `go` tool fetches modules, displayed
+// with their effective values by `%config`.
+var ModuleEnvVars = []string{"GOPROXY", "GOSUMDB", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOFLAGS"}
+
+// ValidateGoProxy checks that value is a valid GOPROXY: a list of proxy URLs, `direct` or `off`,
+// separated by "," or "|".
+func ValidateGoProxy(value string) error {
+	for _, entry := range regexp.MustCompile(`[,|]`).Split(value, -1) {
+		if entry == "direct" || entry == "off" {
+			continue
+		}
+		proxyURL, err := url.Parse(entry)
+		validScheme := err == nil &&
+			(proxyURL.Scheme == "https" || proxyURL.Scheme == "http" || proxyURL.Scheme == "file")
+		if !validScheme || (proxyURL.Scheme != "file" && proxyURL.Host == "") {
+			return errors.Errorf("invalid GOPROXY entry %q: it must be a URL (`https://...`, `http://...` or "+
+				"`file://...`), `direct` or `off`", entry)
+		}
+	}
+	return nil
+}
+
+// ValidateGoSumDB checks that value is a valid GOSUMDB: `off`, or the name of the checksum database,
+// optionally followed by its public key (`name+key`) and by its URL, separated by a space.
+func ValidateGoSumDB(value string) error {
+	if value == "off" {
+		return nil
+	}
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return errors.Errorf("invalid GOSUMDB %q: use `off`, `<name>`, `<name>+<key>` or `<name>+<key> <url>`", value)
+	}
+	if len(fields) == 2 {
+		sumDBURL, err := url.Parse(fields[1])
+		if err != nil || sumDBURL.Host == "" {
+			return errors.Errorf("invalid GOSUMDB URL %q", fields[1])
+		}
+	}
+	return nil
+}
+
+// EffectiveModuleEnv returns the values of ModuleEnvVars as seen by the `go` tool, with the configuration
+// of the notebook applied.
+func (s *State) EffectiveModuleEnv() (map[string]string, error) {
+	cmd := s.goCommand(append([]string{"env", "-json"}, ModuleEnvVars...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	values := make(map[string]string, len(ModuleEnvVars))
+	if err = json.Unmarshal(output, &values); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse output of %q", cmd)
+	}
+	return values, nil
+}
+
+var (
+	reModuleFetchFailure = regexp.MustCompile(
+		`(?:reading|Get) "?(https?://[^\s"]+)"?: (.*)$`)
+	reChecksumFailure = regexp.MustCompile(
+		`verifying (?:module: )?([^\s:]+).*: (checksum mismatch|SECURITY ERROR|.*(?:sum\.golang\.org|/lookup/).*)$`)
+)
+
+// moduleFetchDiagnosis returns human-readable explanations (in markdown) of failures to fetch modules
+// found in the output of the `go` tool, or nil if there are none.
+func moduleFetchDiagnosis(output string) (diagnosis []string) {
+	seen := MakeSet[string]()
+	add := func(text string) {
+		if !seen.Has(text) {
+			seen.Insert(text)
+			diagnosis = append(diagnosis, text)
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.Contains(line, "module lookup disabled by GOPROXY=off"):
+			add("Modules can't be downloaded with `GOPROXY=off` (see `%config offline`): vendor the dependencies " +
+				"with `%vendor` while online, or set a proxy with `%config goproxy=...`.")
+		case reChecksumFailure.MatchString(line):
+			parts := reChecksumFailure.FindStringSubmatch(line)
+			add(fmt.Sprintf("The checksum of `%s` couldn't be verified (%s): for private modules use "+
+				"`%%config goprivate=...`, or configure the checksum database with `%%config gosumdb=...`.",
+				parts[1], strings.TrimSpace(parts[2])))
+		case reModuleFetchFailure.MatchString(line):
+			parts := reModuleFetchFailure.FindStringSubmatch(line)
+			fetchURL, err := url.Parse(parts[1])
+			host := parts[1]
+			if err == nil {
+				host = fetchURL.Scheme + "://" + fetchURL.Host
+			}
+			add(fmt.Sprintf("The request to `%s` failed (%s): if it is a (corporate) module proxy it may have "+
+				"rejected the module, check `%%config goproxy=...`; for private modules use `%%config goprivate=...`.",
+				host, strings.TrimSpace(parts[2])))
+		}
+	}
+	return
+}
+
+// publishModuleFetchDiagnosis explains failures to fetch modules found in the output of the `go` tool,
+// along with the effective proxy configuration, if there are any.
+func (s *State) publishModuleFetchDiagnosis(msg kernel.Message, output string) {
+	diagnosis := moduleFetchDiagnosis(output)
+	if len(diagnosis) == 0 || msg == nil {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("**Failed to fetch modules:**\n\n")
+	for _, text := range diagnosis {
+		sb.WriteString(fmt.Sprintf("* %s\n", text))
+	}
+	if values, err := s.EffectiveModuleEnv(); err == nil {
+		sb.WriteString("\nEffective configuration:")
+		for _, key := range ModuleEnvVars {
+			if values[key] != "" {
+				sb.WriteString(fmt.Sprintf(" `%s=%s`", key, values[key]))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if err := kernel.PublishMarkdown(msg, sb.String()); err != nil {
+		klog.Errorf("Failed publishing module fetch diagnosis: %+v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGoProxyAndSumDB(t *testing.T) {
+	for _, value := range []string{"direct", "off", "https://proxy.corp.example.com,direct",
+		"https://a.example.com|https://b.example.com", "file:///var/cache/goproxy"} {
+		assert.NoError(t, ValidateGoProxy(value), "GOPROXY=%q", value)
+	}
+	for _, value := range []string{"proxy.example.com", "ftp://proxy.example.com", "https://,direct", "none"} {
+		assert.Error(t, ValidateGoProxy(value), "GOPROXY=%q", value)
+	}
+
+	for _, value := range []string{"off", "sum.golang.org", "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ey18htkTo",
+		"sumdb.corp+key https://sumdb.corp.example.com"} {
+		assert.NoError(t, ValidateGoSumDB(value), "GOSUMDB=%q", value)
+	}
+	for _, value := range []string{" ", "a b c", "sumdb.corp+key not-a-url"} {
+		assert.Error(t, ValidateGoSumDB(value), "GOSUMDB=%q", value)
+	}
+}
+
+func TestModuleFetchDiagnosis(t *testing.T) {
+	assert.Empty(t, moduleFetchDiagnosis("./main.go:3:2: undefined: x\n"))
+
+	diagnosis := moduleFetchDiagnosis(`go: finding module for package github.com/foo/bar
+main.go:2:8: module github.com/foo/bar: reading https://proxy.corp.example.com/github.com/foo/bar/@v/list: 403 Forbidden
+main.go:3:8: module github.com/foo/baz: reading https://proxy.corp.example.com/github.com/foo/baz/@v/list: 403 Forbidden
+`)
+	require.Len(t, diagnosis, 1)
+	assert.Contains(t, diagnosis[0], "`https://proxy.corp.example.com` failed (403 Forbidden)")
+
+	diagnosis = moduleFetchDiagnosis(
+		"verifying github.com/corp/private@v1.0.0: github.com/corp/private@v1.0.0: reading " +
+			"https://sum.golang.org/lookup/github.com/corp/private@v1.0.0: 404 Not Found\n" +
+			"main.go:2:8: cannot find module providing package x.com/y: module lookup disabled by GOPROXY=off\n")
+	require.Len(t, diagnosis, 2)
+	assert.Contains(t, diagnosis[0], "checksum of `github.com/corp/private@v1.0.0`")
+	assert.Contains(t, diagnosis[1], "`GOPROXY=off`")
+}
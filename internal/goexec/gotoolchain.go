@@ -0,0 +1,113 @@
+package goexec
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the selection of the version of the Go toolchain used by the notebook, with the
+// special command `%go-version`.
+
+// reGoVersion matches the Go versions accepted by `%go-version`, e.g.: "1.22.3", "1.22rc1" or "go1.22.3".
+var reGoVersion = regexp.MustCompile(`^(?:go)?1\.(\d+)((?:\.\d+)|(?:rc\d+))?$`)
+
+// parseGoVersion validates the Go version given to `%go-version`, and returns it without the "go" prefix.
+// Only Go 1.21 or later can be selected: older versions are not distributed as toolchain modules.
+func parseGoVersion(version string) (string, error) {
+	parts := reGoVersion.FindStringSubmatch(version)
+	if parts == nil {
+		return "", errors.Errorf("invalid Go version %q, use something like `1.22.3`", version)
+	}
+	minor, _ := strconv.Atoi(parts[1])
+	if minor < 21 {
+		return "", errors.Errorf("Go version %q not supported, only Go 1.21 or later can be selected", version)
+	}
+	if parts[2] == "" {
+		// Since Go 1.21 the first release is "1.X.0", and "1.X" is only a language version.
+		return "", errors.Errorf("invalid Go version %q, use the full release version, e.g.: `1.%d.0`", version, minor)
+	}
+	return strings.TrimPrefix(version, "go"), nil
+}
+
+// ActiveGoVersion returns the version of the Go toolchain selected with `%go-version` (e.g.: "go1.22.3"),
+// or an empty string if the notebook uses the default `go` tool.
+func (s *State) ActiveGoVersion() string {
+	if s.GoVersion == "" {
+		return ""
+	}
+	return "go" + s.GoVersion
+}
+
+// SetGoVersion selects the version of the Go toolchain used to build the notebook, downloading it if
+// needed (the `go` tool fetches it as the module `golang.org/toolchain`), and updates the `go` directive
+// of `go.mod` accordingly. An empty version (or "default") restores the default `go` tool of the kernel.
+func (s *State) SetGoVersion(msg kernel.Message, version string) (err error) {
+	if version == "" || version == "default" {
+		s.GoVersion = ""
+		// Reset the `go` directive to the version of the default `go` tool, otherwise it could switch
+		// automatically to the newer version previously selected.
+		cmd := s.goCommand("env", "GOVERSION")
+		cmd.Env = append(cmd.Environ(), "GOTOOLCHAIN=local")
+		var output []byte
+		output, err = cmd.Output()
+		if err != nil {
+			return errors.Wrapf(err, "failed to run %q", cmd)
+		}
+		version = strings.TrimPrefix(strings.TrimSpace(string(output)), "go")
+		if err = s.setGoModVersion(version); err != nil {
+			return
+		}
+		s.UpdateGoplsSettings()
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("%%go-version: using the default Go toolchain (go%s)\n", version))
+	}
+
+	version, err = parseGoVersion(version)
+	if err != nil {
+		return
+	}
+	previous := s.GoVersion
+	s.GoVersion = version
+	defer func() {
+		if err != nil {
+			s.GoVersion = previous
+		}
+	}()
+
+	// `go version` downloads the toolchain, if not yet available, and reports it.
+	klog.V(1).Infof("%%go-version: selecting go%s", version)
+	executor, err := s.goExecutor(msg, "version")
+	if err == nil {
+		err = executor.Exec()
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "failed to select Go toolchain go%s", version)
+	}
+	if state := executor.ProcessState(); state != nil && !state.Success() {
+		return errors.Errorf("failed to download or run the Go toolchain go%s", version)
+	}
+	if err = s.setGoModVersion(version); err != nil {
+		return
+	}
+	s.UpdateGoplsSettings()
+	return
+}
+
+// setGoModVersion sets the `go` directive of `go.mod` to the given version, and removes the `toolchain`
+// directive, so the toolchain is selected only by State.GoVersion.
+func (s *State) setGoModVersion(version string) error {
+	cmd := s.goCommand("mod", "edit", "-go="+version, "-toolchain=none")
+	// Edit with the local toolchain: the one selected may not accept the current `go` directive.
+	cmd.Env = append(cmd.Environ(), "GOTOOLCHAIN=local")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		klog.Errorf("Failed %q:\n%s\n", cmd, output)
+		return errors.Wrapf(err, "failed to set the Go version of go.mod: %s", output)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%gpu info`: it probes the NVIDIA (CUDA) and AMD (ROCm) accelerators and
+// toolkits installed, and configures the environment of the kernel (LD_LIBRARY_PATH, CGO_CFLAGS,
+// CGO_LDFLAGS, etc.) so Go bindings of machine learning libraries that use cgo build and run without
+// manual setup.
+
+const (
+	// DefaultCudaHome is where the CUDA toolkit is usually installed, if CUDA_HOME or CUDA_PATH are not set.
+	DefaultCudaHome = "/usr/local/cuda"
+
+	// DefaultRocmHome is where ROCm is usually installed, if ROCM_PATH is not set.
+	DefaultRocmHome = "/opt/rocm"
+)
+
+// GPUDevice is an accelerator found by ProbeGPUs.
+type GPUDevice struct {
+	Vendor, Name, Memory, Driver string
+}
+
+// GPUInfo holds the accelerators and toolkits found by ProbeGPUs.
+type GPUInfo struct {
+	Devices []GPUDevice
+
+	// CudaHome and RocmHome are the installation directories of the CUDA toolkit and ROCm, if found.
+	CudaHome, RocmHome string
+}
+
+// ProbeGPUs finds the NVIDIA devices (with `nvidia-smi`, or the driver information in `/proc`), the AMD
+// devices (with `rocm-smi`), and the CUDA and ROCm installations.
+func ProbeGPUs() *GPUInfo {
+	info := &GPUInfo{}
+	if output, err := exec.Command("nvidia-smi", "--query-gpu=name,memory.total,driver_version",
+		"--format=csv,noheader").Output(); err == nil {
+		info.Devices = append(info.Devices, parseNvidiaSmi(string(output))...)
+	} else if infoFiles, _ := filepath.Glob("/proc/driver/nvidia/gpus/*/information"); len(infoFiles) > 0 {
+		for _, infoFile := range infoFiles {
+			contents, err := os.ReadFile(infoFile)
+			if err != nil {
+				continue
+			}
+			info.Devices = append(info.Devices, GPUDevice{Vendor: "NVIDIA", Name: procNvidiaModel(string(contents))})
+		}
+	}
+	if output, err := exec.Command("rocm-smi", "--showproductname").Output(); err == nil {
+		info.Devices = append(info.Devices, parseRocmSmi(string(output))...)
+	}
+	info.CudaHome = findToolkit(DefaultCudaHome, "CUDA_HOME", "CUDA_PATH")
+	info.RocmHome = findToolkit(DefaultRocmHome, "ROCM_PATH")
+	return info
+}
+
+// parseNvidiaSmi parses the output of `nvidia-smi --query-gpu=name,memory.total,driver_version
+// --format=csv,noheader`.
+func parseNvidiaSmi(output string) (devices []GPUDevice) {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		devices = append(devices, GPUDevice{Vendor: "NVIDIA", Name: strings.TrimSpace(fields[0]),
+			Memory: strings.TrimSpace(fields[1]), Driver: strings.TrimSpace(fields[2])})
+	}
+	return
+}
+
+// procNvidiaModel returns the model in the contents of `/proc/driver/nvidia/gpus/*/information`.
+func procNvidiaModel(contents string) string {
+	for _, line := range strings.Split(contents, "\n") {
+		if model, found := strings.CutPrefix(line, "Model:"); found {
+			return strings.TrimSpace(model)
+		}
+	}
+	return "unknown"
+}
+
+// parseRocmSmi parses the output of `rocm-smi --showproductname`, with lines like
+// "GPU[0]		: Card series: 		Radeon Instinct MI100".
+func parseRocmSmi(output string) (devices []GPUDevice) {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "GPU[") || !strings.Contains(line, "Card series:") {
+			continue
+		}
+		_, name, _ := strings.Cut(line, "Card series:")
+		devices = append(devices, GPUDevice{Vendor: "AMD", Name: strings.TrimSpace(name)})
+	}
+	return
+}
+
+// findToolkit returns the installation directory given by the first of the environment variables set,
+// or defaultDir, if it exists.
+func findToolkit(defaultDir string, envVars ...string) string {
+	for _, key := range envVars {
+		if dir := os.Getenv(key); dir != "" {
+			return dir
+		}
+	}
+	if stat, err := os.Stat(defaultDir); err == nil && stat.IsDir() {
+		return defaultDir
+	}
+	return ""
+}
+
+// appendPathList appends dir to the list of paths (separated by filepath.ListSeparator), if not yet there.
+func appendPathList(list, dir string) string {
+	if list == "" {
+		return dir
+	}
+	if slices.Contains(filepath.SplitList(list), dir) {
+		return list
+	}
+	return list + string(filepath.ListSeparator) + dir
+}
+
+// appendFlag appends the flag to the space separated flags, if not yet there.
+func appendFlag(flags, flag string) string {
+	if slices.Contains(strings.Fields(flags), flag) {
+		return flags
+	}
+	return strings.TrimSpace(flags + " " + flag)
+}
+
+// Env returns the changes to the environment (given by getenv) required to build and run programs that
+// use the toolkits found: the libraries directories are added to LD_LIBRARY_PATH and CGO_LDFLAGS, the
+// include directories to CGO_CFLAGS, and the binaries to PATH.
+func (info *GPUInfo) Env(getenv func(key string) string) map[string]string {
+	env := make(map[string]string)
+	get := func(key string) string {
+		if value, found := env[key]; found {
+			return value
+		}
+		return getenv(key)
+	}
+	addToolkit := func(home, homeVar, libDir string) {
+		if home == "" {
+			return
+		}
+		if getenv(homeVar) == "" {
+			env[homeVar] = home
+		}
+		for key, value := range map[string]string{
+			"PATH":            appendPathList(get("PATH"), path.Join(home, "bin")),
+			"LD_LIBRARY_PATH": appendPathList(get("LD_LIBRARY_PATH"), path.Join(home, libDir)),
+			"CGO_CFLAGS":      appendFlag(get("CGO_CFLAGS"), "-I"+path.Join(home, "include")),
+			"CGO_LDFLAGS":     appendFlag(get("CGO_LDFLAGS"), "-L"+path.Join(home, libDir)),
+		} {
+			if value != getenv(key) {
+				env[key] = value
+			}
+		}
+	}
+	addToolkit(info.CudaHome, "CUDA_HOME", "lib64")
+	addToolkit(info.RocmHome, "ROCM_PATH", "lib")
+	return env
+}
+
+// Markdown describes the accelerators and toolkits found.
+func (info *GPUInfo) Markdown() string {
+	var buf bytes.Buffer
+	if len(info.Devices) == 0 {
+		buf.WriteString("No NVIDIA or AMD accelerators found.\n\n")
+	} else {
+		buf.WriteString("| # | Vendor | Device | Memory | Driver |\n|---|---|---|---|---|\n")
+		for ii, device := range info.Devices {
+			fmt.Fprintf(&buf, "| %d | %s | %s | %s | %s |\n", ii, device.Vendor, device.Name, device.Memory, device.Driver)
+		}
+		buf.WriteString("\n")
+	}
+	for _, toolkit := range [][2]string{{"CUDA", info.CudaHome}, {"ROCm", info.RocmHome}} {
+		if toolkit[1] == "" {
+			fmt.Fprintf(&buf, "* %s: not found\n", toolkit[0])
+		} else {
+			fmt.Fprintf(&buf, "* %s: `%s`\n", toolkit[0], toolkit[1])
+		}
+	}
+	return buf.String()
+}
+
+// GPUInfo implements `%gpu info`: it probes the accelerators and toolkits installed, and sets up the
+// environment of the kernel to use them, see GPUInfo.Env. The environment is also passed to `gopls`.
+func (s *State) GPUInfo(msg kernel.Message) error {
+	info := ProbeGPUs()
+	env := info.Env(os.Getenv)
+	report := info.Markdown()
+	if len(env) > 0 {
+		report += "\nEnvironment set:\n\n"
+		for _, key := range SortedKeys(env) {
+			if err := os.Setenv(key, env[key]); err != nil {
+				return errors.Wrapf(err, "failed to set environment variable %q", key)
+			}
+			report += fmt.Sprintf("* `%s=%s`\n", key, env[key])
+		}
+		klog.V(1).Infof("%%gpu info: environment set: %v", env)
+		if s.gpuEnv == nil {
+			s.gpuEnv = make(map[string]string)
+		}
+		for key, value := range env {
+			s.gpuEnv[key] = value
+		}
+		s.UpdateGoplsSettings()
+	}
+	return kernel.PublishMarkdown(msg, report)
+}
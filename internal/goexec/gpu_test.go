@@ -0,0 +1,45 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGPUProbes(t *testing.T) {
+	devices := parseNvidiaSmi("NVIDIA GeForce RTX 4090, 24564 MiB, 550.54.14\nTesla T4, 15360 MiB, 550.54.14\n")
+	require.Len(t, devices, 2)
+	assert.Equal(t, GPUDevice{Vendor: "NVIDIA", Name: "NVIDIA GeForce RTX 4090", Memory: "24564 MiB", Driver: "550.54.14"},
+		devices[0])
+	assert.Equal(t, "Tesla T4", devices[1].Name)
+
+	assert.Equal(t, "Tesla T4", procNvidiaModel("Model: \t\t Tesla T4\nIRQ:   34\n"))
+
+	devices = parseRocmSmi("======= ROCm System Management Interface =======\n" +
+		"GPU[0]\t\t: Card series: \t\tRadeon Instinct MI100\nGPU[0]\t\t: Card vendor: \t\tAMD\n")
+	require.Len(t, devices, 1)
+	assert.Equal(t, GPUDevice{Vendor: "AMD", Name: "Radeon Instinct MI100"}, devices[0])
+}
+
+func TestGPUInfoEnv(t *testing.T) {
+	cudaHome := t.TempDir()
+	info := &GPUInfo{CudaHome: cudaHome}
+	current := map[string]string{
+		"PATH":        "/usr/bin",
+		"CGO_LDFLAGS": "-L" + path.Join(cudaHome, "lib64"),
+	}
+	env := info.Env(func(key string) string { return current[key] })
+	assert.Equal(t, map[string]string{
+		"CUDA_HOME":       cudaHome,
+		"PATH":            "/usr/bin" + string(os.PathListSeparator) + path.Join(cudaHome, "bin"),
+		"LD_LIBRARY_PATH": path.Join(cudaHome, "lib64"),
+		"CGO_CFLAGS":      "-I" + path.Join(cudaHome, "include"),
+	}, env)
+
+	// Nothing to set without toolkits.
+	assert.Empty(t, (&GPUInfo{}).Env(os.Getenv))
+	assert.Contains(t, (&GPUInfo{}).Markdown(), "No NVIDIA or AMD accelerators found")
+}
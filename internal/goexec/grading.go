@@ -0,0 +1,150 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the support for autograding with nbgrader (https://nbgrader.readthedocs.io): the results of
+// the tests of `%test` cells are collected, so the autograder test cells (see package dispatcher) can report
+// their score in a machine-readable output, with GradeMIMEType. It also implements `%config seed=<n>`, to make
+// the programs and tests using the top-level functions of `math/rand` deterministic.
+
+const (
+	// GradeMIMEType is the MIME type of the output with the Grade of an autograder test cell, encoded as JSON.
+	GradeMIMEType = "application/vnd.gonb.grade+json"
+
+	// SeedGo is the file, in State.TempDir, that seeds `math/rand`, written by State.SetSeed.
+	SeedGo = "gonb_seed.go"
+
+	// SeedEnv is the environment variable with the seed set with `%config seed=<n>`, for the programs using
+	// other random generators (e.g.: `math/rand/v2`).
+	SeedEnv = "GONB_SEED"
+)
+
+// seedProgram is the contents of SeedGo, formatted with the seed.
+const seedProgram = `// Code generated by GoNB for %%config seed=%[1]d. DO NOT EDIT.
+
+package main
+
+import "math/rand"
+
+func init() {
+	rand.Seed(%[1]d)
+}
+`
+
+// SetSeed seeds `math/rand` in the programs and tests of the cells with the given seed, writing SeedGo, or
+// stops seeding it if seed is nil.
+func (s *State) SetSeed(seed *int64) error {
+	filePath := path.Join(s.TempDir, SeedGo)
+	if seed != nil {
+		if err := os.WriteFile(filePath, []byte(fmt.Sprintf(seedProgram, *seed)), 0600); err != nil {
+			return errors.Wrapf(err, "failed to write %q", filePath)
+		}
+	} else if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove %q", filePath)
+	}
+	s.Seed = seed
+	return nil
+}
+
+// seedEnv returns the environment variables for the execution of the cell programs, if a seed is set: SeedEnv,
+// and GODEBUG with `randseednop=0`, since from Go 1.24 `rand.Seed` is a no-op otherwise.
+func (s *State) seedEnv() []string {
+	if s.Seed == nil {
+		return nil
+	}
+	goDebug := "randseednop=0"
+	if previous := os.Getenv("GODEBUG"); previous != "" {
+		goDebug = previous + "," + goDebug
+	}
+	return []string{SeedEnv + "=" + strconv.FormatInt(*s.Seed, 10), "GODEBUG=" + goDebug}
+}
+
+// TestResults are the names of the tests, run by a `%test` cell, that passed and failed.
+type TestResults struct {
+	Passed, Failed []string
+}
+
+// testResultRegexp matches the result of a (top-level) test in the output of `go test -v`.
+var testResultRegexp = regexp.MustCompile(`^--- (PASS|FAIL): (\S+)`)
+
+// testResultsWriter is an io.Writer that collects the TestResults from the output of `go test -v`.
+type testResultsWriter struct {
+	results *TestResults
+	partial []byte // Last line, not yet complete.
+}
+
+// Write implements io.Writer.
+func (w *testResultsWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		eol := bytes.IndexByte(w.partial, '\n')
+		if eol < 0 {
+			break
+		}
+		if matches := testResultRegexp.FindSubmatch(w.partial[:eol]); matches != nil {
+			if string(matches[1]) == "PASS" {
+				w.results.Passed = append(w.results.Passed, string(matches[2]))
+			} else {
+				w.results.Failed = append(w.results.Failed, string(matches[2]))
+			}
+		}
+		w.partial = w.partial[eol+1:]
+	}
+	return len(p), nil
+}
+
+// Grade is the score of an autograder test cell, published with GradeMIMEType.
+type Grade struct {
+	GradeId  string   `json:"grade_id"`
+	Score    float64  `json:"score"`
+	MaxScore float64  `json:"max_score"`
+	Passed   []string `json:"passed,omitempty"`
+	Failed   []string `json:"failed,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// GradeCell returns the Grade of the autograder test cell just executed, worth points, given the error of its
+// execution. If it ran tests (`%test`), the score is proportional to the tests that passed. Otherwise, following
+// nbgrader, it gets all the points if it didn't fail.
+func (s *State) GradeCell(gradeId string, points float64, executionErr error) Grade {
+	grade := Grade{GradeId: gradeId, MaxScore: points}
+	if executionErr != nil {
+		grade.Error = executionErr.Error()
+	}
+	if results := s.cellTestResults; results != nil && len(results.Passed)+len(results.Failed) > 0 {
+		grade.Passed, grade.Failed = results.Passed, results.Failed
+		grade.Score = points * float64(len(results.Passed)) / float64(len(results.Passed)+len(results.Failed))
+	} else if executionErr == nil {
+		grade.Score = points
+	}
+	return grade
+}
+
+// PublishGrade publishes the grade, with GradeMIMEType and a textual summary.
+func PublishGrade(msg kernel.Message, grade Grade) error {
+	text := fmt.Sprintf("Grade %q: %g of %g points", grade.GradeId, grade.Score, grade.MaxScore)
+	if total := len(grade.Passed) + len(grade.Failed); total > 0 {
+		text += fmt.Sprintf(" (%d of %d tests passed", len(grade.Passed), total)
+		if len(grade.Failed) > 0 {
+			text += ", failed: " + strings.Join(grade.Failed, ", ")
+		}
+		text += ")"
+	}
+	return kernel.PublishDisplayData(msg, kernel.Data{
+		Data: kernel.MIMEMap{
+			GradeMIMEType:                  grade,
+			string(protocol.MIMETextPlain): text + ".",
+		}})
+}
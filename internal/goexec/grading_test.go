@@ -0,0 +1,64 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestResultsWriter(t *testing.T) {
+	results := &TestResults{}
+	w := &testResultsWriter{results: results}
+	for _, chunk := range []string{
+		"=== RUN   TestA\n--- PASS: TestA (0.00s)\n=== RUN   TestB\n    --- PASS: TestB/sub (0.00s)\n--- FA",
+		"IL: TestB (0.01s)\n    main_test.go:12: wrong answer\n--- PASS: TestC (0.00s)\nFAIL\n",
+	} {
+		_, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"TestA", "TestC"}, results.Passed)
+	assert.Equal(t, []string{"TestB"}, results.Failed)
+}
+
+func TestGradeCell(t *testing.T) {
+	s := &State{}
+	assert.Equal(t, Grade{GradeId: "q1", Score: 2, MaxScore: 2}, s.GradeCell("q1", 2, nil))
+	assert.Equal(t, Grade{GradeId: "q1", MaxScore: 2, Error: "exit status 1"},
+		s.GradeCell("q1", 2, errors.New("exit status 1")))
+
+	// Partial credit, by the tests that passed.
+	s.cellTestResults = &TestResults{Passed: []string{"TestA", "TestC", "TestD"}, Failed: []string{"TestB"}}
+	grade := s.GradeCell("q2", 2, errors.New("exit status 1"))
+	assert.Equal(t, 1.5, grade.Score)
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	require.NoError(t, PublishGrade(msg, grade))
+	require.Len(t, msg.Outputs(), 1)
+	data := msg.Outputs()[0]["data"].(kernel.MIMEMap)
+	assert.Equal(t, `Grade "q2": 1.5 of 2 points (3 of 4 tests passed, failed: TestB).`, data["text/plain"])
+	gradeData := data[GradeMIMEType].(map[string]any)
+	assert.Equal(t, "q2", gradeData["grade_id"])
+	assert.Equal(t, 1.5, gradeData["score"])
+}
+
+func TestSetSeed(t *testing.T) {
+	s := &State{TempDir: t.TempDir()}
+	t.Setenv("GODEBUG", "")
+	seed := int64(42)
+	require.NoError(t, s.SetSeed(&seed))
+	contents, err := os.ReadFile(filepath.Join(s.TempDir, SeedGo))
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "rand.Seed(42)")
+	assert.Equal(t, []string{"GONB_SEED=42", "GODEBUG=randseednop=0"}, s.seedEnv())
+
+	require.NoError(t, s.SetSeed(nil))
+	_, err = os.Stat(filepath.Join(s.TempDir, SeedGo))
+	assert.True(t, os.IsNotExist(err), fmt.Sprintf("%s should have been removed", SeedGo))
+	assert.Empty(t, s.seedEnv())
+}
@@ -0,0 +1,206 @@
+package goexec
+
+import (
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%grpc call`: it sends a request to the gRPC server of a `%serve --grpc` cell,
+// using server reflection to find the request and response types, and pretty-prints the response.
+//
+// The kernel doesn't depend on gRPC: the client is a small program built in the temporary module (which
+// already requires gRPC, since the cell serving it does), and removed after the call.
+
+// GRPCCallDir is the name of the sub-package (in State.TempDir) of the program that sends `%grpc call` requests.
+const GRPCCallDir = "gonb_grpc_call"
+
+// ParseGRPCMethod parses the `<Service>/<Method>` argument of `%grpc call`, where `<Service>` is the fully
+// qualified name of the service (e.g.: `helloworld.Greeter/SayHello`). A leading "/" is accepted.
+func ParseGRPCMethod(fullMethod string) (service, method string, err error) {
+	service, method, found := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !found || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", errors.Errorf("invalid gRPC method %q, it must be of the form `<package>.<Service>/<Method>`",
+			fullMethod)
+	}
+	return service, method, nil
+}
+
+// GRPCCall sends the request (in JSON) to the method (`<Service>/<Method>`) of the gRPC server of the
+// `%serve --grpc` cell running in the background, and prints the response in JSON.
+func (s *State) GRPCCall(msg kernel.Message, fullMethod, request string) error {
+	service, method, err := ParseGRPCMethod(fullMethod)
+	if err != nil {
+		return err
+	}
+	if request == "" {
+		request = "{}"
+	}
+	served := s.served
+	if served == nil || !served.grpc {
+		return errors.New("%grpc call requires a gRPC server running in the background, started by a " +
+			"`%serve --grpc` cell with `gonbui.ServeListener()`")
+	}
+
+	dir := path.Join(s.TempDir, GRPCCallDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create directory %q", dir)
+	}
+	// Removed after the call, so it's not included by `go mod tidy`, `go vet ./...`, etc.
+	defer func() { _ = os.RemoveAll(dir) }()
+	mainPath := path.Join(dir, "main.go")
+	if err = os.WriteFile(mainPath, []byte(grpcCallProgram), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %q", mainPath)
+	}
+
+	args := []string{"run"}
+	if !s.Offline {
+		// Adds the modules used by the client to go.mod, if the served cell didn't require them.
+		args = append(args, "-mod=mod")
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(served.port))
+	args = append(args, "./"+GRPCCallDir, addr, service+"/"+method, request)
+	executor, err := s.goExecutor(msg, args...)
+	if err != nil {
+		return err
+	}
+	if err = executor.Exec(); err != nil {
+		return errors.WithMessagef(err, "%%grpc call failed")
+	}
+	if state := executor.ProcessState(); state != nil && !state.Success() {
+		return errors.Errorf("%%grpc call %s/%s failed", service, method)
+	}
+	return nil
+}
+
+// grpcCallProgram is the program that sends a `%grpc call` request. It takes as arguments the address of
+// the server, the method (`<Service>/<Method>`) and the request in JSON.
+const grpcCallProgram = `// Generated by GoNB for %grpc call: it is removed after the call.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) != 4 {
+		fatalf("usage: %s <address> <Service>/<Method> <json request>", os.Args[0])
+	}
+	addr, fullMethod, request := os.Args[1], os.Args[2], os.Args[3]
+	serviceName, methodName, _ := strings.Cut(fullMethod, "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fatalf("failed to connect to %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	// Fetch the descriptors of the service (and their dependencies) with server reflection.
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		fatalf("server reflection failed (is reflection.Register called on the server?): %v", err)
+	}
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	fetch := func(req *rpb.ServerReflectionRequest) {
+		if err := stream.Send(req); err != nil {
+			fatalf("server reflection failed: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			fatalf("server reflection failed (is reflection.Register called on the server?): %v", err)
+		}
+		if errResp := resp.GetErrorResponse(); errResp != nil {
+			fatalf("server reflection failed: %s", errResp.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, fd); err != nil {
+				fatalf("invalid file descriptor from server reflection: %v", err)
+			}
+			files[fd.GetName()] = fd
+		}
+	}
+	fetch(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName}})
+	for missing := true; missing; {
+		missing = false
+		for _, fd := range files {
+			for _, dep := range fd.GetDependency() {
+				if _, found := files[dep]; !found {
+					missing = true
+					fetch(&rpb.ServerReflectionRequest{
+						MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep}})
+				}
+			}
+		}
+	}
+	fileSet := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range files {
+		fileSet.File = append(fileSet.File, fd)
+	}
+	registry, err := protodesc.NewFiles(fileSet)
+	if err != nil {
+		fatalf("invalid descriptors from server reflection: %v", err)
+	}
+	desc, err := registry.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		fatalf("service %q not found: %v", serviceName, err)
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		fatalf("%q is not a service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		var names []string
+		for ii := 0; ii < service.Methods().Len(); ii++ {
+			names = append(names, string(service.Methods().Get(ii).Name()))
+		}
+		fatalf("method %q not found in service %q, available methods: %s", methodName, serviceName,
+			strings.Join(names, ", "))
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		fatalf("%s/%s is a streaming method, only unary methods are supported", serviceName, methodName)
+	}
+
+	req := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal([]byte(request), req); err != nil {
+		fatalf("invalid request for %s: %v", method.Input().FullName(), err)
+	}
+	resp := dynamicpb.NewMessage(method.Output())
+	if err := conn.Invoke(ctx, "/"+serviceName+"/"+methodName, req, resp); err != nil {
+		fatalf("%v", err)
+	}
+	output, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(resp)
+	if err != nil {
+		fatalf("failed to convert response to JSON: %v", err)
+	}
+	fmt.Println(string(output))
+}
+`
@@ -0,0 +1,34 @@
+package goexec
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCCall(t *testing.T) {
+	service, method, err := ParseGRPCMethod("helloworld.Greeter/SayHello")
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld.Greeter", service)
+	assert.Equal(t, "SayHello", method)
+	service, method, err = ParseGRPCMethod("/helloworld.Greeter/SayHello")
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld.Greeter", service)
+	assert.Equal(t, "SayHello", method)
+	for _, invalid := range []string{"", "Greeter", "Greeter/", "/SayHello", "a/b/c"} {
+		_, _, err = ParseGRPCMethod(invalid)
+		assert.Errorf(t, err, "ParseGRPCMethod(%q) should have failed", invalid)
+	}
+
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", grpcCallProgram, parser.AllErrors)
+	require.NoError(t, err, "Program used by %%grpc call doesn't parse")
+
+	// Requires a `%serve --grpc` program running.
+	s := &State{TempDir: t.TempDir()}
+	err = s.GRPCCall(nil, "helloworld.Greeter/SayHello", `{"name": "world"}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "%serve --grpc")
+}
@@ -0,0 +1,82 @@
+package goexec
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+)
+
+// This file implements the hoisting of `go` statements written at the top level of a cell (e.g.: a pasted
+// `go func() {...}()`), which are invalid outside a function: they are moved to the body of a `func main()`
+// created for the cell, as if they followed a `%%` line, instead of failing to parse the whole cell.
+
+// topLevelGoStatements returns the lines of the `go` statements at the top level of the cell. It returns an
+// error if they can't be hoisted to a `func main()`: if they share lines with other code, or if the cell
+// defines its own `func main()`. Cells with a `%%` (or `%main`) line are not considered.
+func topLevelGoStatements(lines []string, skipLines Set[int]) (Set[int], error) {
+	code, ok := selectionCode(lines, skipLines)
+	if !ok || !strings.Contains(code, "go") {
+		return nil, nil
+	}
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(code))
+	var sc scanner.Scanner
+	sc.Init(file, []byte(code), func(token.Position, string) {}, 0)
+
+	hoisted := MakeSet[int]()
+	depth, statementStart, goStartLine := 0, true, -1
+	var previous token.Token
+	hasMain := false
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		position := fileSet.Position(pos)
+		switch tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		case token.IDENT:
+			if depth == 0 && previous == token.FUNC && lit == "main" {
+				hasMain = true
+			}
+		}
+		if depth == 0 && statementStart && tok == token.GO {
+			goStartLine = position.Line - 1
+			if strings.TrimSpace(lines[goStartLine][:position.Column-1]) != "" {
+				return nil, errors.Errorf("`go` statement at the top level of the cell (line %d) must start its own "+
+					"line, to be moved into `func main()`", goStartLine+1)
+			}
+		}
+		statementStart = depth == 0 && tok == token.SEMICOLON
+		if statementStart && goStartLine >= 0 {
+			if lit != "\n" {
+				return nil, errors.Errorf("`go` statement at the top level of the cell (line %d) must end its line, "+
+					"to be moved into `func main()`", goStartLine+1)
+			}
+			for line := goStartLine; line < position.Line; line++ {
+				hoisted.Insert(line)
+			}
+			goStartLine = -1
+		}
+		previous = tok
+	}
+	if goStartLine >= 0 {
+		// Unterminated statement: let the parser report the error.
+		return nil, nil
+	}
+	if len(hoisted) > 0 && hasMain {
+		lineNum := len(lines)
+		for line := range hoisted {
+			lineNum = min(lineNum, line)
+		}
+		return nil, errors.Errorf("`go` statement at the top level of the cell (line %d) is not valid outside a "+
+			"function: move it into the cell's `func main()`", lineNum+1)
+	}
+	return hoisted, nil
+}
@@ -0,0 +1,55 @@
+package goexec
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopLevelGoStatements(t *testing.T) {
+	lines := strings.Split(`var done = make(chan bool)
+
+go func() {
+	done <- true
+}()
+
+func wait() { go work(); <-done }
+go wait()`, "\n")
+	hoisted, err := topLevelGoStatements(lines, MakeSet[int]())
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4, 7}, SortedKeys(hoisted))
+
+	// No top-level go statements, or in `%%` cells.
+	hoisted, err = topLevelGoStatements([]string{"func f() {", "\tgo f()", "}"}, MakeSet[int]())
+	require.NoError(t, err)
+	assert.Empty(t, hoisted)
+	hoisted, err = topLevelGoStatements([]string{"%%", "go f()"}, MakeSet[int]())
+	require.NoError(t, err)
+	assert.Empty(t, hoisted)
+
+	// Precise errors.
+	_, err = topLevelGoStatements([]string{"var x = 1; go f()"}, MakeSet[int]())
+	assert.ErrorContains(t, err, "line 1) must start its own line")
+	_, err = topLevelGoStatements([]string{"func main() {}", "", "go f()"}, MakeSet[int]())
+	assert.ErrorContains(t, err, "line 3) is not valid outside a function")
+}
+
+func TestCreateGoFileWithHoistedStatements(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	lines := strings.Split("func work() {}\ngo work()\nvar x = 1", "\n")
+	_, fileToCellLines, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	contents, err := os.ReadFile(s.CodePath())
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc work() {}\nvar x = 1\n\nfunc main() {\n\tflag.Parse()\n\tgo work()\n\n}\n",
+		string(contents))
+	assert.Equal(t, []int{-1, -1, 0, 2, -1, 1, 1, 1, -1, -1}, fileToCellLines)
+}
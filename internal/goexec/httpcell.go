@@ -0,0 +1,211 @@
+package goexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the `%%http` cells: the body of the cell is an HTTP request -- the method and URL in the
+// first line, followed by the headers and, after an empty line, the body -- executed by the kernel, and the
+// response (status, headers and pretty-printed body) is displayed. As in the `!` commands, `{name}` placeholders
+// are replaced by the values of the memorized Go variables or constants initialized with a literal.
+
+var (
+	// HTTPCellTimeout is the maximum duration of the requests of `%%http` cells.
+	HTTPCellTimeout = 60 * time.Second
+
+	// MaxHTTPResponseDisplay is the maximum size of the body of the responses of `%%http` cells displayed:
+	// longer bodies are truncated.
+	MaxHTTPResponseDisplay = 1 << 20
+)
+
+// reHTTPPlaceholder matches the `{name}` placeholders of Go variables in `%%http` cells.
+var reHTTPPlaceholder = regexp.MustCompile(`\{([\pL_][\pL\pN_]*)\}`)
+
+// httpMethods are the methods accepted in the first line of `%%http` cells.
+var httpMethods = common.MakeSet[string]()
+
+func init() {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace} {
+		httpMethods.Insert(method)
+	}
+}
+
+// parseHTTPCell parses the request of a `%%http` cell: "[<method>] <url>" in the first (non-empty) line, the
+// "<name>: <value>" headers in the following lines and, after an empty line, the body. The method defaults to
+// GET. Lines starting with "#" before the body are comments.
+func parseHTTPCell(ctx context.Context, text string) (*http.Request, error) {
+	lines := strings.Split(text, "\n")
+	ii := 0
+	for ii < len(lines) && (strings.TrimSpace(lines[ii]) == "" || strings.HasPrefix(strings.TrimSpace(lines[ii]), "#")) {
+		ii++
+	}
+	if ii == len(lines) {
+		return nil, errors.New("%%http: missing the request, e.g.: `GET https://example.com/`")
+	}
+	fields := strings.Fields(lines[ii])
+	method, url := http.MethodGet, ""
+	switch {
+	case len(fields) == 1:
+		url = fields[0]
+	case len(fields) == 2 || (len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/")):
+		method, url = strings.ToUpper(fields[0]), fields[1]
+		if !httpMethods.Has(method) {
+			return nil, errors.Errorf("%%http: unknown method %q in %q", fields[0], lines[ii])
+		}
+	default:
+		return nil, errors.Errorf("%%http: the first line must be `[<method>] <url>`, got %q", lines[ii])
+	}
+
+	header := make(http.Header)
+	for ii++; ii < len(lines) && strings.TrimSpace(lines[ii]) != ""; ii++ {
+		line := strings.TrimSpace(lines[ii])
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, errors.Errorf("%%http: invalid header %q, headers must be `<name>: <value>`", line)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	var body io.Reader
+	if ii < len(lines) {
+		if bodyText := strings.TrimSpace(strings.Join(lines[ii+1:], "\n")); bodyText != "" {
+			body = strings.NewReader(bodyText)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%%http: invalid request")
+	}
+	req.Header = header
+	return req, nil
+}
+
+// HTTPRequest implements the `%%http` cells: it executes the HTTP request in text (see parseHTTPCell), after
+// replacing the `{name}` placeholders with the values of the memorized Go variables or constants, and displays
+// the response.
+func (s *State) HTTPRequest(msg kernel.Message, text string) error {
+	values := s.literalDeclarations()
+	text = reHTTPPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, found := values[placeholder[1:len(placeholder)-1]]; found {
+			return value
+		}
+		return placeholder
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), HTTPCellTimeout)
+	defer cancel()
+	if msg != nil && msg.Kernel() != nil {
+		// Cancelled by `interrupt_request` messages (see kernel.Kernel.Interrupt), or by SIGINT, which only marks
+		// the kernel as interrupted.
+		k := msg.Kernel()
+		unregister := k.OnInterrupt(cancel)
+		defer unregister()
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if k.Interrupted.Load() {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+	}
+	req, err := parseHTTPCell(ctx, text)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%%http: %s %s failed", req.Method, req.URL)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(MaxHTTPResponseDisplay)+1))
+	if err != nil {
+		return errors.Wrapf(err, "%%http: failed to read the response of %s %s", req.Method, req.URL)
+	}
+	return kernel.PublishDisplayData(msg, httpResponseData(req, resp, body, time.Since(start)))
+}
+
+// httpResponseData returns the display of the response: its status, the headers (collapsed) and the body --
+// JSON indented, images displayed, and text as is.
+func httpResponseData(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) kernel.Data {
+	truncated := len(body) > MaxHTTPResponseDisplay
+	if truncated {
+		body = body[:MaxHTTPResponseDisplay]
+	}
+	status := fmt.Sprintf("%s %s: %s (%s)", req.Method, req.URL, resp.Status, elapsed.Round(time.Millisecond))
+	color := "#22863a"
+	if resp.StatusCode >= 400 {
+		color = "#b31d28"
+	}
+	var htmlSb, textSb strings.Builder
+	fmt.Fprintf(&htmlSb, `<div style="color: %s;"><b>%s</b></div>`+"\n", color, html.EscapeString(status))
+	textSb.WriteString(status + "\n")
+	fmt.Fprintf(&htmlSb, "<details><summary>Headers (%d)</summary><table>\n", len(resp.Header))
+	for _, name := range common.SortedKeys(resp.Header) {
+		for _, value := range resp.Header[name] {
+			fmt.Fprintf(&htmlSb, `<tr><td style="text-align: left;"><b>%s</b></td><td style="text-align: left;">%s</td></tr>`+"\n",
+				html.EscapeString(name), html.EscapeString(value))
+			fmt.Fprintf(&textSb, "%s: %s\n", name, value)
+		}
+	}
+	htmlSb.WriteString("</table></details>\n")
+	textSb.WriteString("\n")
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var indented bytes.Buffer
+	switch {
+	case len(body) == 0:
+		htmlSb.WriteString(`<div style="opacity: 0.6;">(empty body)</div>`)
+	case strings.HasPrefix(mediaType, "image/") && !truncated:
+		fmt.Fprintf(&htmlSb, `<img src="data:%s;base64,%s">`, mediaType, base64.StdEncoding.EncodeToString(body))
+		fmt.Fprintf(&textSb, "(%s image, %d bytes)\n", mediaType, len(body))
+	case !utf8.Valid(body):
+		fmt.Fprintf(&htmlSb, `<div style="opacity: 0.6;">(%d bytes of binary data)</div>`, len(body))
+		fmt.Fprintf(&textSb, "(%d bytes of binary data)\n", len(body))
+	default:
+		text := string(body)
+		if !truncated && json.Indent(&indented, body, "", "  ") == nil {
+			text = indented.String()
+		}
+		fmt.Fprintf(&htmlSb, `<pre style="max-height: 40em; overflow: auto;">%s</pre>`, html.EscapeString(text))
+		textSb.WriteString(text + "\n")
+	}
+	if truncated {
+		note := fmt.Sprintf("(body truncated to %d bytes)", MaxHTTPResponseDisplay)
+		fmt.Fprintf(&htmlSb, `<div style="opacity: 0.6;">%s</div>`, note)
+		textSb.WriteString(note + "\n")
+	}
+	return kernel.Data{
+		Data: kernel.MIMEMap{
+			string(protocol.MIMETextHTML):  htmlSb.String(),
+			string(protocol.MIMETextPlain): textSb.String(),
+		},
+	}
+}
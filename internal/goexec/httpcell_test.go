@@ -0,0 +1,96 @@
+package goexec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPCell(t *testing.T) {
+	ctx := context.Background()
+	req, err := parseHTTPCell(ctx, "\n# Get the users.\nhttps://example.com/users\n")
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodGet, req.Method)
+	assert.Equal(t, "https://example.com/users", req.URL.String())
+
+	req, err = parseHTTPCell(ctx, "post https://example.com/users HTTP/1.1\nContent-Type: application/json\n"+
+		"# Comment.\nX-Trace:  abc \n\n{\"name\": \"Ada\"}\n")
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "abc", req.Header.Get("X-Trace"))
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name": "Ada"}`, string(body))
+
+	_, err = parseHTTPCell(ctx, "")
+	assert.ErrorContains(t, err, "missing the request")
+	_, err = parseHTTPCell(ctx, "FETCH https://example.com/")
+	assert.ErrorContains(t, err, "unknown method")
+	_, err = parseHTTPCell(ctx, "GET https://example.com/\nnot a header")
+	assert.ErrorContains(t, err, "invalid header")
+}
+
+func TestHTTPRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/42" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id":42,"token":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	s := &State{Definitions: NewDeclarations()}
+	s.Definitions.Variables["baseURL"] = &Variable{Key: "baseURL", Name: "baseURL", ValueDefinition: fmt.Sprintf("%q", server.URL)}
+	s.Definitions.Constants["userId"] = &Constant{Key: "userId", ValueDefinition: "42"}
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.HTTPRequest(msg, "GET {baseURL}/users/{userId}\nAuthorization: Bearer {unknown}\n"))
+	require.Len(t, msg.Outputs(), 1)
+	text := msg.Outputs()[0]["data"].(kernel.MIMEMap)["text/plain"].(string)
+	assert.Contains(t, text, "GET "+server.URL+"/users/42: 200 OK")
+	assert.Contains(t, text, "Content-Type: application/json\n")
+	assert.Contains(t, text, "{\n  \"id\": 42,\n  \"token\": \"Bearer {unknown}\"\n}")
+
+	require.NoError(t, s.HTTPRequest(msg, "DELETE {baseURL}/missing"))
+	require.Len(t, msg.Outputs(), 2)
+	htmlOutput := msg.Outputs()[1]["data"].(kernel.MIMEMap)["text/html"].(string)
+	assert.Contains(t, htmlOutput, "404 Not Found")
+	assert.Contains(t, htmlOutput, "#b31d28")
+}
+
+func TestHTTPRequestInterrupted(t *testing.T) {
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-blocked:
+		}
+	}))
+	defer server.Close()
+	defer close(blocked)
+
+	s := &State{Definitions: NewDeclarations()}
+	k := kernel.NewHeadless()
+	msg, err := kernel.NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		k.Interrupt()
+	}()
+	start := time.Now()
+	err = s.HTTPRequest(msg, "GET "+server.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context canceled")
+	assert.Less(t, time.Since(start), HTTPCellTimeout)
+}
@@ -0,0 +1,145 @@
+package goexec
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%implements <interface>` and `%satisfies <type>`: they list the types that implement an
+// interface, and the interfaces a type implements, using go/types over the memorized declarations. The types
+// considered are the ones memorized and the exported ones of the packages directly imported by them.
+
+// scopeTypeNames returns the named types considered by `%implements` and `%satisfies`: the ones declared in the
+// memorized declarations, followed by the exported ones of the imported packages (sorted by package path), and
+// `error`. Generic types are skipped, since they can't be checked without instantiating them.
+func (c *checkedDeclarations) scopeTypeNames() []*types.TypeName {
+	var typeNames []*types.TypeName
+	addScope := func(scope *types.Scope, exportedOnly bool) {
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || typeName.IsAlias() || (exportedOnly && !typeName.Exported()) {
+				continue
+			}
+			if named, ok := typeName.Type().(*types.Named); ok && named.TypeParams().Len() == 0 {
+				typeNames = append(typeNames, typeName)
+			}
+		}
+	}
+	addScope(c.pkg.Scope(), false)
+	imports := c.pkg.Imports()
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path() < imports[j].Path() })
+	for _, imported := range imports {
+		addScope(imported.Scope(), true)
+	}
+	return append(typeNames, types.Universe.Lookup("error").(*types.TypeName))
+}
+
+// lookupType type-checks the type expression typeExpr (e.g.: `MyType` or `io.Reader`) in the scope of the
+// memorized declarations.
+func (c *checkedDeclarations) lookupType(command, typeExpr string) (types.Type, error) {
+	tv, err := types.Eval(c.fset, c.pkg, c.filePos, typeExpr)
+	if err != nil {
+		return nil, errors.Errorf("%s %s: %v", command, typeExpr, err)
+	}
+	if !tv.IsType() {
+		return nil, errors.Errorf("%s %s: %q is not a type", command, typeExpr, typeExpr)
+	}
+	return tv.Type, nil
+}
+
+// Implements lists the types (memorized, or exported by the packages imported by them) that implement the
+// interface ifaceExpr (e.g.: `Shape` or `io.Reader`), with where they are declared.
+func (s *State) Implements(msg kernel.Message, ifaceExpr string) error {
+	markdown, err := s.ImplementsMarkdown(ifaceExpr)
+	if err != nil {
+		return err
+	}
+	return kernel.PublishMarkdown(msg, markdown)
+}
+
+// ImplementsMarkdown returns the list of types displayed by Implements, in Markdown.
+func (s *State) ImplementsMarkdown(ifaceExpr string) (string, error) {
+	c, err := s.checkDeclarations("%implements")
+	if err != nil {
+		return "", err
+	}
+	t, err := c.lookupType("%implements", ifaceExpr)
+	if err != nil {
+		return "", err
+	}
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return "", errors.Errorf("%%implements %s: %s is not an interface, use `%%satisfies %s` to list the "+
+			"interfaces it implements", ifaceExpr, c.typeString(t), ifaceExpr)
+	}
+	var sb strings.Builder
+	for _, typeName := range c.scopeTypeNames() {
+		if types.IsInterface(typeName.Type()) {
+			continue
+		}
+		var implementer types.Type
+		switch {
+		case types.Implements(typeName.Type(), iface):
+			implementer = typeName.Type()
+		case types.Implements(types.NewPointer(typeName.Type()), iface):
+			implementer = types.NewPointer(typeName.Type())
+		default:
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("* %s: %s\n", c.typeString(implementer), c.position(typeName)))
+	}
+	if sb.Len() == 0 {
+		return fmt.Sprintf("No types implementing %s found.\n", c.typeString(t)), nil
+	}
+	return fmt.Sprintf("**Types implementing %s:**\n\n%s", c.typeString(t), sb.String()), nil
+}
+
+// Satisfies lists the interfaces (memorized, or exported by the packages imported by them, and `error`) that
+// the type typeExpr (e.g.: `MyType`) implements, or whose pointer implements.
+func (s *State) Satisfies(msg kernel.Message, typeExpr string) error {
+	markdown, err := s.SatisfiesMarkdown(typeExpr)
+	if err != nil {
+		return err
+	}
+	return kernel.PublishMarkdown(msg, markdown)
+}
+
+// SatisfiesMarkdown returns the list of interfaces displayed by Satisfies, in Markdown.
+func (s *State) SatisfiesMarkdown(typeExpr string) (string, error) {
+	c, err := s.checkDeclarations("%satisfies")
+	if err != nil {
+		return "", err
+	}
+	t, err := c.lookupType("%satisfies", typeExpr)
+	if err != nil {
+		return "", err
+	}
+	_, isPointer := t.(*types.Pointer)
+	var sb strings.Builder
+	for _, typeName := range c.scopeTypeNames() {
+		iface, ok := typeName.Type().Underlying().(*types.Interface)
+		if !ok || iface.NumMethods() == 0 || !iface.IsMethodSet() || types.Identical(typeName.Type(), t) {
+			// Interfaces without methods (like `any`) are implemented by all types, and type constraints
+			// can't be implemented.
+			continue
+		}
+		var note string
+		switch {
+		case types.Implements(t, iface):
+		case !isPointer && !types.IsInterface(t) && types.Implements(types.NewPointer(t), iface):
+			note = fmt.Sprintf(" (by %s)", c.typeString(types.NewPointer(t)))
+		default:
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("* %s%s: %s\n", c.typeString(typeName.Type()), note, c.position(typeName)))
+	}
+	if sb.Len() == 0 {
+		return fmt.Sprintf("No interfaces implemented by %s found.\n", c.typeString(t)), nil
+	}
+	return fmt.Sprintf("**Interfaces implemented by %s:**\n\n%s", c.typeString(t), sb.String()), nil
+}
@@ -0,0 +1,68 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImplements(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	cellCode := `import "io"
+
+type Shape interface {
+	Area() float64
+}
+
+type Square struct{ Side float64 }
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+
+type Circle struct{ Radius float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+func (c *Circle) Read(p []byte) (int, error) { return 0, io.EOF }
+
+func (c *Circle) Error() string { return "circle" }
+
+type Other int
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 3, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	s.Definitions, err = s.parseFromGoCode(nil, 3, NoCursor, MakeFileToCellIdAndLine(3, fileToCellLine))
+	require.NoError(t, err)
+
+	markdown, err := s.ImplementsMarkdown("Shape")
+	require.NoError(t, err)
+	assert.Equal(t, "**Types implementing `Shape`:**\n\n* `*Circle`: Cell[3]: Line 11\n* `Square`: Cell[3]: Line 7\n", markdown)
+
+	markdown, err = s.ImplementsMarkdown("io.Reader")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "* `*Circle`: Cell[3]: Line 11\n")
+	assert.Contains(t, markdown, "* `*io.SectionReader`: package `io`")
+	assert.NotContains(t, markdown, "Square")
+
+	markdown, err = s.SatisfiesMarkdown("Circle")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "**Interfaces implemented by `Circle`:**\n\n")
+	assert.Contains(t, markdown, "* `Shape` (by `*Circle`): Cell[3]: Line 3\n")
+	assert.Contains(t, markdown, "* `io.Reader` (by `*Circle`): package `io`")
+	assert.Contains(t, markdown, "* `error` (by `*Circle`): predeclared\n")
+	assert.NotContains(t, markdown, "io.Writer")
+
+	markdown, err = s.SatisfiesMarkdown("Other")
+	require.NoError(t, err)
+	assert.Equal(t, "No interfaces implemented by `Other` found.\n", markdown)
+
+	_, err = s.ImplementsMarkdown("Square")
+	assert.Error(t, err)
+	_, err = s.ImplementsMarkdown("Unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package goexec
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+)
+
+// This file detects imports of different packages with the same name (e.g.: "math/rand" and "crypto/rand"),
+// which otherwise would silently replace each other in the memorized declarations, leaving code that fails
+// to compile with confusing errors.
+
+// importConflict describes an import whose name is already used by the import of another package.
+type importConflict struct {
+	Name, Path, OtherPath string
+
+	// Users are the memorized declarations that use the other import, if it was memorized.
+	Users []string
+}
+
+// suggestedImportAlias returns an alias for the import path, prefixing its name with the previous element
+// of the path, e.g.: "crypto/rand" -> "cryptorand".
+func suggestedImportAlias(importPath, name string) string {
+	parts := strings.Split(importPath, "/")
+	if len(parts) >= 2 {
+		alias := strings.ToLower(reNonIdentifier.ReplaceAllString(parts[len(parts)-2]+name, ""))
+		if token.IsIdentifier(alias) && !token.IsKeyword(alias) {
+			return alias
+		}
+	}
+	return name + "2"
+}
+
+// Error implements the error interface, with a message suggesting how to fix the conflict.
+func (c importConflict) Error() string {
+	alias := suggestedImportAlias(c.Path, c.Name)
+	if len(c.Users) == 0 {
+		return fmt.Sprintf("imports %q and %q are both named `%s`: use an alias for one of them, e.g. `import %s %q`",
+			c.OtherPath, c.Path, c.Name, alias, c.Path)
+	}
+	users := c.Users
+	if len(users) > 5 {
+		users = append(users[:5:5], "...")
+	}
+	return fmt.Sprintf("import %q is named `%s`, like the memorized import %q, used by %s: use an alias for the new "+
+		"import, e.g. `import %s %q`, or remove the memorized one with `%%rm %s`",
+		c.Path, c.Name, c.OtherPath, strings.Join(users, ", "), alias, c.Path, c.Name)
+}
+
+// isNamedImport returns whether the import key is the name of the package in the code: dot and blank imports
+// don't define a name.
+func isNamedImport(key string) bool {
+	return !strings.HasPrefix(key, ".~") && !strings.HasPrefix(key, "_~")
+}
+
+// declarationsUsing returns the keys of the declarations (prefixed by their kind) whose source references
+// the name as a package qualifier (`name.`), skipping the ones redefined in skip.
+func declarationsUsing(decls, skip *Declarations, name string) []string {
+	reUse := regexp.MustCompile(`(^|[^\w.])` + regexp.QuoteMeta(name) + `\.\w`)
+	var users []string
+	for _, key := range SortedKeys(decls.Functions) {
+		if _, found := skip.Functions[key]; !found && reUse.MatchString(decls.Functions[key].Definition) {
+			users = append(users, "func "+key)
+		}
+	}
+	for _, key := range SortedKeys(decls.Variables) {
+		v := decls.Variables[key]
+		if _, found := skip.Variables[key]; !found && reUse.MatchString(v.TypeDefinition+" "+v.ValueDefinition) {
+			users = append(users, "var "+key)
+		}
+	}
+	for _, key := range SortedKeys(decls.Types) {
+		if _, found := skip.Types[key]; !found && reUse.MatchString(decls.Types[key].TypeDefinition) {
+			users = append(users, "type "+key)
+		}
+	}
+	for _, key := range SortedKeys(decls.Constants) {
+		c := decls.Constants[key]
+		if _, found := skip.Constants[key]; !found && reUse.MatchString(c.TypeDefinition+" "+c.ValueDefinition) {
+			users = append(users, "const "+key)
+		}
+	}
+	return users
+}
+
+// checkImportConflicts returns an error if an import of the cell has the same name as a memorized import of
+// another package that is still used by memorized declarations (not redefined in the cell). If the memorized
+// import is no longer used, it is simply replaced.
+func (s *State) checkImportConflicts(newDecls *Declarations) error {
+	for _, key := range SortedKeys(newDecls.Imports) {
+		imp := newDecls.Imports[key]
+		old, found := s.Definitions.Imports[key]
+		if !found || old.Path == imp.Path || !isNamedImport(key) {
+			continue
+		}
+		users := declarationsUsing(s.Definitions, newDecls, key)
+		if len(users) > 0 {
+			return errors.WithStack(importConflict{Name: key, Path: imp.Path, OtherPath: old.Path, Users: users})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestedImportAlias(t *testing.T) {
+	assert.Equal(t, "cryptorand", suggestedImportAlias("crypto/rand", "rand"))
+	assert.Equal(t, "pkgerrors", suggestedImportAlias("github.com/pkg/errors", "errors"))
+	assert.Equal(t, "errors2", suggestedImportAlias("errors", "errors"))
+}
+
+func TestCheckImportConflicts(t *testing.T) {
+	s := &State{Definitions: NewDeclarations()}
+	s.Definitions.Imports["rand"] = NewImport("math/rand", "")
+	s.Definitions.Functions["Roll"] = &Function{Key: "Roll", Definition: "func Roll() int { return 1 + rand.Intn(6) }"}
+	s.Definitions.Functions["Other"] = &Function{Key: "Other", Definition: "func Other() int { return operand.Value }"}
+
+	newDecls := NewDeclarations()
+	newDecls.Imports["rand"] = NewImport("crypto/rand", "")
+	err := s.checkImportConflicts(newDecls)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "func Roll")
+	assert.NotContains(t, err.Error(), "Other")
+	assert.Contains(t, err.Error(), "import cryptorand \"crypto/rand\"")
+
+	// Redefining the users in the cell resolves the conflict.
+	newDecls.Functions["Roll"] = &Function{Key: "Roll", Definition: "func Roll() int { return 1 }"}
+	require.NoError(t, s.checkImportConflicts(newDecls))
+
+	// Same package, dot and blank imports don't conflict.
+	newDecls = NewDeclarations()
+	newDecls.Imports["rand"] = NewImport("math/rand", "")
+	imp := NewImport("crypto/rand", "_")
+	newDecls.Imports[imp.Key] = imp
+	require.NoError(t, s.checkImportConflicts(newDecls))
+}
+
+func TestImportConflictsInCell(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	cellCode := `import (
+	"math/rand"
+	"crypto/rand"
+	_ "image/png"
+	_ "image/jpeg"
+)
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	_, err = s.parseFromGoCode(nil, 1, NoCursor, MakeFileToCellIdAndLine(1, fileToCellLine))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `imports "math/rand" and "crypto/rand" are both named`)
+
+	// Blank imports are all kept.
+	lines = strings.Split(strings.Replace(cellCode, "\t\"crypto/rand\"\n", "", 1), "\n")
+	_, fileToCellLine, err = s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	decls, err := s.parseFromGoCode(nil, 1, NoCursor, MakeFileToCellIdAndLine(1, fileToCellLine))
+	require.NoError(t, err)
+	assert.Len(t, decls.Imports, 3)
+	assert.Contains(t, decls.Imports, "_~image/png")
+}
@@ -0,0 +1,75 @@
+package goexec
+
+import (
+	"go/token"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the kernel side of the input widgets of gonbui (e.g.: `gonbui.Slider("n", 1, 100, 10)`):
+// the values sent by the widgets are declared as Go variables (`var n int = <value>`) in the following
+// executions of cells.
+
+// inputValue is the value of an input widget, see State.handleInputValue.
+type inputValue struct {
+	Type, Literal string
+}
+
+// handleInputValue stores the values sent by the input widgets, to the address
+// `protocol.GonbuiInputAddressPrefix + <variable name>`, registered with comms.State.HandleAddressPrefix.
+// The value of the message has the fields "type" (the Go type of the variable) and "value" (as a string).
+func (s *State) handleInputValue(_ kernel.Message, address string, value any) {
+	name := strings.TrimPrefix(address, protocol.GonbuiInputAddressPrefix)
+	request, ok := value.(map[string]any)
+	if !ok || !token.IsIdentifier(name) {
+		klog.Warningf("Invalid input value sent to %q: %v", address, value)
+		return
+	}
+	goType, _ := request["type"].(string)
+	valueStr, _ := request["value"].(string)
+	if !validParamType(goType) {
+		klog.Warningf("Invalid type %q of input value sent to %q", goType, address)
+		return
+	}
+	literal, err := paramLiteral(goType, valueStr)
+	if err != nil {
+		klog.Warningf("Invalid input value sent to %q: %+v", address, err)
+		return
+	}
+	s.muInputs.Lock()
+	defer s.muInputs.Unlock()
+	if s.inputValues == nil {
+		s.inputValues = make(map[string]inputValue)
+	}
+	s.inputValues[name] = inputValue{Type: goType, Literal: literal}
+}
+
+// declareInputVariables declares in decls the variables with the values of the input widgets. Inputs whose
+// names are declared by the cell (in cellDecls) are not declared, and if dropRedeclared is set (when the
+// cell is executed) they are dropped: the latest declaration prevails.
+func (s *State) declareInputVariables(cellDecls, decls *Declarations, dropRedeclared bool) {
+	s.muInputs.Lock()
+	defer s.muInputs.Unlock()
+	for name, input := range s.inputValues {
+		_, isFunc := cellDecls.Functions[name]
+		_, isVar := cellDecls.Variables[name]
+		_, isType := cellDecls.Types[name]
+		_, isConst := cellDecls.Constants[name]
+		if isFunc || isVar || isType || isConst {
+			if dropRedeclared {
+				delete(s.inputValues, name)
+			}
+			continue
+		}
+		decls.Variables[name] = &Variable{
+			Cursor:          NoCursor,
+			Key:             name,
+			Name:            name,
+			TypeDefinition:  input.Type,
+			ValueDefinition: input.Literal,
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputValues(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	s.handleInputValue(nil, protocol.GonbuiInputAddressPrefix+"n", map[string]any{"type": "int", "value": "42"})
+	s.handleInputValue(nil, protocol.GonbuiInputAddressPrefix+"rate", map[string]any{"type": "float64", "value": "0.5"})
+	s.handleInputValue(nil, protocol.GonbuiInputAddressPrefix+"title", map[string]any{"type": "string", "value": `a "b"`})
+	// Invalid: not an identifier, invalid value, unsupported type.
+	s.handleInputValue(nil, protocol.GonbuiInputAddressPrefix+"a-b", map[string]any{"type": "int", "value": "1"})
+	s.handleInputValue(nil, protocol.GonbuiInputAddressPrefix+"x", map[string]any{"type": "int", "value": "abc"})
+	s.handleInputValue(nil, protocol.GonbuiInputAddressPrefix+"y", map[string]any{"type": "[]int", "value": "1"})
+	require.Len(t, s.inputValues, 3)
+
+	cellDecls := NewDeclarations()
+	decls := NewDeclarations()
+	s.declareInputVariables(cellDecls, decls, true)
+	require.Len(t, decls.Variables, 3)
+	assert.Equal(t, "int", decls.Variables["n"].TypeDefinition)
+	assert.Equal(t, "42", decls.Variables["n"].ValueDefinition)
+	assert.Equal(t, "0.5", decls.Variables["rate"].ValueDefinition)
+	assert.Equal(t, `"a \"b\""`, decls.Variables["title"].ValueDefinition)
+
+	// Cell redeclaring the variable: the input is dropped, except while completing or inspecting.
+	cellDecls.Variables["n"] = &Variable{Key: "n", Name: "n", ValueDefinition: "7"}
+	decls = NewDeclarations()
+	s.declareInputVariables(cellDecls, decls, false)
+	assert.Len(t, decls.Variables, 2)
+	assert.Len(t, s.inputValues, 3)
+	s.declareInputVariables(cellDecls, decls, true)
+	assert.Len(t, s.inputValues, 2)
+	assert.NotContains(t, s.inputValues, "n")
+}
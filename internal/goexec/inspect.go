@@ -5,6 +5,7 @@ import (
 	"github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/gonbui/protocol"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/tracing"
 	"github.com/pkg/errors"
 	"k8s.io/klog/v2"
 	"os"
@@ -53,6 +54,9 @@ func (s *State) InspectIdentifierInCell(lines []string, skipLines map[int]struct
 		return
 	}
 
+	span := tracing.Start("gonb.inspect")
+	defer func() { span.End(err) }()
+
 	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
 	err = s.AutoTrack()
 	if err != nil {
@@ -108,7 +112,9 @@ func (s *State) InspectIdentifierInCell(lines []string, skipLines map[int]struct
 	if err != nil {
 		return
 	}
+	goplsSpan := span.Child("gopls.definition")
 	desc, err = s.gopls.Definition(ctx, s.CodePath(), cursorInFile.Line, cursorInFile.Col)
+	goplsSpan.End(err)
 	messages := s.gopls.ConsumeMessages()
 	if err != nil {
 		parts := []string{errors.Cause(err).Error()}
@@ -137,6 +143,9 @@ func (s *State) AutoCompleteOptionsInCell(cellLines []string, skipLines map[int]
 		return
 	}
 
+	span := tracing.Start("gonb.complete")
+	defer func() { span.End(err) }()
+
 	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
 	err = s.AutoTrack()
 	if err != nil {
@@ -185,9 +194,11 @@ func (s *State) AutoCompleteOptionsInCell(cellLines []string, skipLines map[int]
 		return
 	}
 	_ = cursorInFile
-	var matches []string
+	var matches, kinds []string
 	var replaceLength int
-	matches, replaceLength, err = s.gopls.Complete(ctx, s.CodePath(), cursorInFile.Line, cursorInFile.Col)
+	goplsSpan := span.Child("gopls.complete")
+	matches, kinds, replaceLength, err = s.gopls.Complete(ctx, s.CodePath(), cursorInFile.Line, cursorInFile.Col)
+	goplsSpan.SetAttribute("gopls.num_matches", len(matches)).End(err)
 	if err != nil {
 		err = errors.Cause(err)
 		return
@@ -199,10 +210,30 @@ func (s *State) AutoCompleteOptionsInCell(cellLines []string, skipLines map[int]
 	}
 	if len(matches) > 0 {
 		reply.Matches = matches
+		reply.Metadata = kernel.EnsureMIMEMap(reply.Metadata)
+		reply.Metadata[CompletionTypesMetadataKey] = completionTypes(matches, kinds, reply.CursorStart, reply.CursorEnd)
 	}
 	return
 }
 
+// CompletionTypesMetadataKey is the key in the metadata of a "complete_reply" with the range and type
+// of each match. It's not yet part of the Jupyter protocol, but JupyterLab and VS Code use it to
+// show the kind of each match and to replace the right range of text.
+const CompletionTypesMetadataKey = "_jupyter_types_experimental"
+
+// completionTypes returns the contents of the CompletionTypesMetadataKey metadata of a "complete_reply".
+func completionTypes(matches, kinds []string, cursorStart, cursorEnd int) []map[string]any {
+	types := make([]map[string]any, 0, len(matches))
+	for ii, match := range matches {
+		entry := map[string]any{"start": cursorStart, "end": cursorEnd, "text": match}
+		if ii < len(kinds) && kinds[ii] != "" {
+			entry["type"] = kinds[ii]
+		}
+		types = append(types, entry)
+	}
+	return types
+}
+
 // runeIndicesForLine returns the start of each rune in the line (encoded as UTF-8).
 func runeIndicesForLine(line string, col int) (runeIndices []int, colIdx int) {
 	runeIndices = make([]int, 0, len(line))
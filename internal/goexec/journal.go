@@ -0,0 +1,365 @@
+package goexec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the journal of the memorized declarations: each change to State.Definitions is
+// appended to a journal file in the kernel's temporary directory. If the kernel crashes, its temporary
+// directory is not removed, and the next kernel started for the same notebook offers to restore the
+// declarations from it, with `%journal restore`.
+
+// JournalFileName is the name of the journal of declarations, in State.TempDir.
+const JournalFileName = "declarations.journal"
+
+// Operations of the journal entries.
+const (
+	journalOpStart  = "start"  // First entry: identifies the notebook and the kernel process.
+	journalOpSet    = "set"    // Declaration created or changed.
+	journalOpDelete = "delete" // Declaration removed.
+	journalOpClosed = "closed" // Kernel exited normally: the journal is not offered for restoring.
+)
+
+// journalEntry is one line of the journal.
+type journalEntry struct {
+	Op       string          `json:"op"`
+	Kind     string          `json:"kind,omitempty"`
+	Key      string          `json:"key,omitempty"`
+	Decl     json.RawMessage `json:"decl,omitempty"`
+	Notebook string          `json:"notebook,omitempty"`
+	Pid      int             `json:"pid,omitempty"`
+}
+
+// journalConstant is how constants are saved in the journal: the links to the other constants of
+// their block are saved as keys.
+type journalConstant struct {
+	*Constant
+	NextKey, PrevKey string `json:",omitempty"`
+}
+
+// declarationsJournal is the journal of the kernel.
+type declarationsJournal struct {
+	path string
+	file *os.File
+
+	// journaled holds the "set" entries of the declarations in the journal, indexed by "<kind>/<key>",
+	// to find the changes to append.
+	journaled map[string]journalEntry
+
+	// crashedJournal is the journal of a crashed kernel for the same notebook, offered for restoring.
+	crashedJournal string
+	offered        bool
+}
+
+// openJournal creates the journal in TempDir, and looks for the journal of a crashed kernel of the same
+// notebook (if known), to offer restoring it.
+func (s *State) openJournal() error {
+	journalPath := path.Join(s.TempDir, JournalFileName)
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create journal of declarations %q", journalPath)
+	}
+	s.journal = &declarationsJournal{path: journalPath, file: f, journaled: make(map[string]journalEntry)}
+	notebook := os.Getenv(JupyterSessionNameEnv)
+	if err = s.journal.append(journalEntry{Op: journalOpStart, Notebook: notebook, Pid: os.Getpid()}); err != nil {
+		return err
+	}
+	s.journal.crashedJournal = findCrashedJournal(notebook, journalPath)
+	if s.journal.crashedJournal != "" {
+		klog.Infof("Found journal of declarations of a crashed kernel: %q", s.journal.crashedJournal)
+	}
+	return nil
+}
+
+// append entries to the journal, and sync it to disk.
+func (j *declarationsJournal) append(entries ...journalEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode journal entry")
+		}
+		buf.Write(encoded)
+		buf.WriteByte('\n')
+	}
+	if _, err := j.file.Write(buf.Bytes()); err != nil {
+		return errors.Wrapf(err, "failed to write to journal %q", j.path)
+	}
+	return errors.Wrapf(j.file.Sync(), "failed to sync journal %q", j.path)
+}
+
+// closeJournal marks the journal as closed, so it's not offered for restoring, and closes it.
+func (s *State) closeJournal() error {
+	j := s.journal
+	if j == nil {
+		return nil
+	}
+	s.journal = nil
+	err := j.append(journalEntry{Op: journalOpClosed})
+	if errClose := j.file.Close(); err == nil {
+		err = errors.Wrapf(errClose, "failed to close journal %q", j.path)
+	}
+	return err
+}
+
+// encodeDeclarations returns the journal "set" entries of all declarations, indexed by "<kind>/<key>".
+func encodeDeclarations(d *Declarations) (map[string]journalEntry, error) {
+	entries := make(map[string]journalEntry)
+	var err error
+	add := func(kind, key string, decl any) {
+		if err != nil {
+			return
+		}
+		var encoded []byte
+		encoded, err = json.Marshal(decl)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to encode %s %q for the journal", kind, key)
+			return
+		}
+		entries[kind+"/"+key] = journalEntry{Op: journalOpSet, Kind: kind, Key: key, Decl: encoded}
+	}
+	for key, decl := range d.Imports {
+		add("import", key, decl)
+	}
+	for key, decl := range d.Functions {
+		add("function", key, decl)
+	}
+	for key, decl := range d.Variables {
+		add("variable", key, decl)
+	}
+	for key, decl := range d.Types {
+		add("type", key, decl)
+	}
+	for key, decl := range d.Constants {
+		jc := journalConstant{Constant: decl}
+		if decl.Next != nil {
+			jc.NextKey = decl.Next.Key
+		}
+		if decl.Prev != nil {
+			jc.PrevKey = decl.Prev.Key
+		}
+		add("constant", key, jc)
+	}
+	for key, decl := range d.GenerateDirectives {
+		add("generate", key, decl)
+	}
+	return entries, err
+}
+
+// decodeInto decodes the declaration of the entry into a new value stored in decls.
+func decodeInto[T any](decls map[string]*T, entry journalEntry) error {
+	decl := new(T)
+	if err := json.Unmarshal(entry.Decl, decl); err != nil {
+		return errors.Wrapf(err, "failed to decode %s %q from the journal", entry.Kind, entry.Key)
+	}
+	decls[entry.Key] = decl
+	return nil
+}
+
+// decodeDeclarations is the inverse of encodeDeclarations.
+func decodeDeclarations(entries map[string]journalEntry) (*Declarations, error) {
+	d := NewDeclarations()
+	constantLinks := make(map[string]journalConstant)
+	for _, entry := range entries {
+		var err error
+		switch entry.Kind {
+		case "import":
+			err = decodeInto(d.Imports, entry)
+		case "function":
+			err = decodeInto(d.Functions, entry)
+		case "variable":
+			err = decodeInto(d.Variables, entry)
+		case "type":
+			err = decodeInto(d.Types, entry)
+		case "generate":
+			err = decodeInto(d.GenerateDirectives, entry)
+		case "constant":
+			jc := journalConstant{Constant: &Constant{}}
+			if err = json.Unmarshal(entry.Decl, &jc); err == nil {
+				d.Constants[entry.Key] = jc.Constant
+				constantLinks[entry.Key] = jc
+			}
+		default:
+			err = errors.Errorf("unknown kind of declaration %q in the journal", entry.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for key, jc := range constantLinks {
+		d.Constants[key].Next = d.Constants[jc.NextKey]
+		d.Constants[key].Prev = d.Constants[jc.PrevKey]
+	}
+	return d, nil
+}
+
+// readJournal reads the journal, and returns its first entry (journalOpStart), the "set" entries of the
+// declarations still in it, and whether it was closed.
+func readJournal(journalPath string) (start journalEntry, entries map[string]journalEntry, closed bool, err error) {
+	f, err := os.Open(journalPath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to open journal %q", journalPath)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	entries = make(map[string]journalEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		var entry journalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The last line may be incomplete, if the kernel crashed while writing it.
+			klog.Warningf("Journal %q: ignoring line %d and after: %v", journalPath, lineNum, err)
+			err = nil
+			break
+		}
+		switch entry.Op {
+		case journalOpStart:
+			start = entry
+		case journalOpSet:
+			entries[entry.Kind+"/"+entry.Key] = entry
+		case journalOpDelete:
+			delete(entries, entry.Kind+"/"+entry.Key)
+		case journalOpClosed:
+			closed = true
+		}
+	}
+	err = errors.Wrapf(scanner.Err(), "failed to read journal %q", journalPath)
+	return
+}
+
+// findCrashedJournal returns the most recent journal, other than ownJournal, of a kernel for the same
+// notebook that exited without closing it, or "" if there is none, or if the notebook is not known.
+func findCrashedJournal(notebook, ownJournal string) string {
+	if notebook == "" {
+		return ""
+	}
+	candidates, _ := filepath.Glob(path.Join(os.TempDir(), "gonb_*", JournalFileName))
+	var crashed string
+	var crashedTime int64
+	for _, candidate := range candidates {
+		if candidate == ownJournal {
+			continue
+		}
+		start, entries, closed, err := readJournal(candidate)
+		if err != nil || closed || start.Notebook != notebook || len(entries) == 0 || isProcessAlive(start.Pid) {
+			continue
+		}
+		info, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if modTime := info.ModTime().UnixNano(); crashed == "" || modTime > crashedTime {
+			crashed, crashedTime = candidate, modTime
+		}
+	}
+	return crashed
+}
+
+// JournalDeclarations appends to the journal the changes to the memorized declarations since it was last
+// called. The first time, if the journal of a crashed kernel for the same notebook was found, it also
+// offers to restore its declarations.
+func (s *State) JournalDeclarations(msg kernel.Message) error {
+	j := s.journal
+	if j == nil {
+		return nil
+	}
+	if j.crashedJournal != "" && !j.offered {
+		j.offered = true
+		_ = kernel.PublishMarkdown(msg, fmt.Sprintf(
+			"A previous kernel for this notebook exited unexpectedly: use `%%journal restore` to restore its "+
+				"memorized declarations (from `%s`), or `%%journal discard` to discard them.", j.crashedJournal))
+	}
+	current, err := encodeDeclarations(s.Definitions)
+	if err != nil {
+		return err
+	}
+	var changes []journalEntry
+	for _, key := range SortedKeys(j.journaled) {
+		if _, found := current[key]; !found {
+			entry := j.journaled[key]
+			changes = append(changes, journalEntry{Op: journalOpDelete, Kind: entry.Kind, Key: entry.Key})
+		}
+	}
+	for _, key := range SortedKeys(current) {
+		if previous, found := j.journaled[key]; !found || !bytes.Equal(previous.Decl, current[key].Decl) {
+			changes = append(changes, current[key])
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err = j.append(changes...); err != nil {
+		return err
+	}
+	j.journaled = current
+	return nil
+}
+
+// RestoreJournal restores the declarations from the journal of a crashed kernel, found when the kernel
+// started, and removes the temporary directory of the crashed kernel. Declarations already memorized in
+// this kernel take precedence. It implements `%journal restore`.
+func (s *State) RestoreJournal(msg kernel.Message) error {
+	if s.journal == nil || s.journal.crashedJournal == "" {
+		return errors.New("%journal restore: no journal of a crashed kernel for this notebook")
+	}
+	crashedJournal := s.journal.crashedJournal
+	_, entries, _, err := readJournal(crashedJournal)
+	if err != nil {
+		return err
+	}
+	decls, err := decodeDeclarations(entries)
+	if err != nil {
+		return err
+	}
+	decls.MergeFrom(s.Definitions)
+	s.Definitions = decls
+	if err = s.DiscardCrashedJournal(nil); err != nil {
+		return err
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+		"* Restored %d declarations from %q: use `%%list` to list them.\n", len(entries), crashedJournal))
+}
+
+// DiscardCrashedJournal removes the temporary directory, with the journal, of the crashed kernel, found
+// when the kernel started. It implements `%journal discard`.
+func (s *State) DiscardCrashedJournal(msg kernel.Message) error {
+	if s.journal == nil || s.journal.crashedJournal == "" {
+		return errors.New("%journal discard: no journal of a crashed kernel for this notebook")
+	}
+	crashedDir := filepath.Dir(s.journal.crashedJournal)
+	s.journal.crashedJournal = ""
+	if err := os.RemoveAll(crashedDir); err != nil {
+		return errors.Wrapf(err, "failed to remove the directory of the crashed kernel %q", crashedDir)
+	}
+	if msg != nil {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("* Discarded the directory of the crashed kernel %q.\n", crashedDir))
+	}
+	return nil
+}
+
+// JournalStatus displays the path of the journal and the number of declarations in it, and whether there
+// is a journal of a crashed kernel to restore. It implements `%journal`.
+func (s *State) JournalStatus(msg kernel.Message) error {
+	if s.journal == nil {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, "Journal of declarations disabled.\n")
+	}
+	status := fmt.Sprintf("Journal of declarations: %s (%d declarations)\n", s.journal.path, len(s.journal.journaled))
+	if s.journal.crashedJournal != "" {
+		status += fmt.Sprintf("Journal of a crashed kernel, to `%%journal restore` or `%%journal discard`: %s\n",
+			s.journal.crashedJournal)
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, status)
+}
@@ -0,0 +1,90 @@
+package goexec
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// journalTestDecls returns declarations of each kind, including a block of constants.
+func journalTestDecls() *Declarations {
+	d := NewDeclarations()
+	d.Imports["fmt"] = &Import{Key: "fmt", Path: "fmt", CellLines: CellLines{Id: 1, Lines: []int{0}}}
+	d.Functions["f"] = &Function{Key: "f", Name: "f", Definition: "func f() {}"}
+	d.Variables["x"] = &Variable{Key: "x", Name: "x", ValueDefinition: "1"}
+	d.Types["T"] = &TypeDecl{Key: "T", TypeDefinition: "T int"}
+	a := &Constant{Key: "A", ValueDefinition: "iota"}
+	b := &Constant{Key: "B", Prev: a}
+	a.Next = b
+	d.Constants["A"], d.Constants["B"] = a, b
+	d.GenerateDirectives["stringer -type=T"] = &GenerateDirective{Key: "stringer -type=T"}
+	return d
+}
+
+func TestJournal(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	t.Setenv(JupyterSessionNameEnv, "notebook.ipynb")
+
+	// Crashed kernel: its journal is not closed.
+	crashed := &State{TempDir: path.Join(tmpDir, "gonb_crashed"), Definitions: journalTestDecls()}
+	require.NoError(t, os.Mkdir(crashed.TempDir, 0700))
+	require.NoError(t, crashed.openJournal())
+	require.NoError(t, crashed.JournalDeclarations(nil))
+	delete(crashed.Definitions.Functions, "f")
+	crashed.Definitions.Variables["x"] = &Variable{Key: "x", Name: "x", ValueDefinition: "2"}
+	require.NoError(t, crashed.JournalDeclarations(nil))
+	// Pretend the kernel process died, using the pid of a process that exited.
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	start, entries, closed, err := readJournal(crashed.journal.path)
+	require.NoError(t, err)
+	assert.False(t, closed)
+	assert.Len(t, entries, 6)
+	start.Pid = cmd.Process.Pid
+	require.NoError(t, crashed.journal.file.Truncate(0))
+	_, err = crashed.journal.file.Seek(0, 0)
+	require.NoError(t, err)
+	require.NoError(t, crashed.journal.append(start))
+	crashed.journal.journaled = make(map[string]journalEntry)
+	require.NoError(t, crashed.JournalDeclarations(nil))
+
+	// Kernel that exited normally: its journal is closed, and not offered for restoring.
+	exited := &State{TempDir: path.Join(tmpDir, "gonb_exited"), Definitions: journalTestDecls()}
+	require.NoError(t, os.Mkdir(exited.TempDir, 0700))
+	require.NoError(t, exited.openJournal())
+	require.NoError(t, exited.JournalDeclarations(nil))
+	require.NoError(t, exited.closeJournal())
+
+	// New kernel for the same notebook finds the journal of the crashed one.
+	s := &State{TempDir: path.Join(tmpDir, "gonb_new"), Definitions: NewDeclarations()}
+	require.NoError(t, os.Mkdir(s.TempDir, 0700))
+	require.NoError(t, s.openJournal())
+	assert.Equal(t, crashed.journal.path, s.journal.crashedJournal)
+	s.Definitions.Types["T"] = &TypeDecl{Key: "T", TypeDefinition: "T string"}
+	require.NoError(t, s.RestoreJournal(nil))
+	assert.NoDirExists(t, crashed.TempDir)
+
+	d := s.Definitions
+	assert.NotContains(t, d.Functions, "f")
+	assert.Equal(t, "2", d.Variables["x"].ValueDefinition)
+	assert.Equal(t, "T string", d.Types["T"].TypeDefinition, "declarations of the new kernel take precedence")
+	assert.Equal(t, []int{0}, d.Imports["fmt"].Lines)
+	assert.Contains(t, d.GenerateDirectives, "stringer -type=T")
+	require.Contains(t, d.Constants, "A")
+	assert.Same(t, d.Constants["B"], d.Constants["A"].Next)
+	assert.Same(t, d.Constants["A"], d.Constants["B"].Prev)
+	assert.Nil(t, d.Constants["A"].Prev)
+	assert.Error(t, s.RestoreJournal(nil))
+
+	// Restored declarations are journaled by the new kernel.
+	require.NoError(t, s.JournalDeclarations(nil))
+	_, entries, _, err = readJournal(s.journal.path)
+	require.NoError(t, err)
+	assert.Len(t, entries, 6)
+	require.NoError(t, s.closeJournal())
+}
@@ -0,0 +1,115 @@
+package goexec
+
+import (
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the goroutine leak check (`%config leak_check=on`): the `func main()` created for
+// `%%` cells defers a check that, when it returns, waits briefly for the goroutines started during the
+// execution to finish, and reports the ones still running, with their stacks, to stderr.
+//
+// The check is implemented in a separate file of the temporary module, LeakCheckGo, compiled with the cell.
+
+// LeakCheckGo is the file, in State.TempDir, with the implementation of the goroutine leak check.
+const LeakCheckGo = "gonb_leakcheck.go"
+
+// leakCheckProgram is the contents of LeakCheckGo.
+const leakCheckProgram = `// Code generated by GoNB for %config leak_check=on. DO NOT EDIT.
+
+//go:build !tinygo
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// gonbLeakCheckTimeout is how long to wait for the goroutines to finish, before reporting them as leaked.
+const gonbLeakCheckTimeout = 500 * time.Millisecond
+
+// gonbLeakCheckIgnored lists functions of goroutines started by the Go runtime or the standard library,
+// that are not leaks.
+var gonbLeakCheckIgnored = []string{"os/signal.signal_recv", "os/signal.loop", "runtime.ensureSigM",
+	"runtime/trace.", "testing."}
+
+// gonbGoroutines returns the stacks of the running goroutines, except the current one, by their header.
+func gonbGoroutines() map[string]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	goroutines := make(map[string]string)
+	for ii, stack := range strings.Split(string(buf), "\n\n") {
+		if ii == 0 {
+			continue // Current goroutine.
+		}
+		id, _, _ := strings.Cut(stack, " [")
+		ignored := false
+		for _, fn := range gonbLeakCheckIgnored {
+			ignored = ignored || strings.Contains(stack, "\n"+fn)
+		}
+		if !ignored {
+			goroutines[id] = stack
+		}
+	}
+	return goroutines
+}
+
+// gonbLeakCheck takes a snapshot of the running goroutines, and returns the function that reports the
+// goroutines started since then and still running.
+func gonbLeakCheck() func() {
+	before := gonbGoroutines()
+	return func() {
+		deadline := time.Now().Add(gonbLeakCheckTimeout)
+		var leaked []string
+		for {
+			leaked = leaked[:0]
+			for id, stack := range gonbGoroutines() {
+				if _, found := before[id]; !found {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if len(leaked) > 0 {
+			fmt.Fprintf(os.Stderr, "\nGoNB: %d goroutine(s) still running at the end of the execution:\n\n%s\n",
+				len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	}
+}
+`
+
+// leakCheckEnabled returns whether the `func main()` created for `%%` cells should check for leaked
+// goroutines: it is not supported in WASM or TinyGo cells.
+func (s *State) leakCheckEnabled() bool {
+	return s.LeakCheck && !s.CellIsWasm && s.TinyGoTarget == ""
+}
+
+// SetLeakCheck enables or disables the goroutine leak check of `%%` cells, writing or removing LeakCheckGo.
+func (s *State) SetLeakCheck(enabled bool) error {
+	filePath := path.Join(s.TempDir, LeakCheckGo)
+	if enabled {
+		if err := os.WriteFile(filePath, []byte(leakCheckProgram), 0600); err != nil {
+			return errors.Wrapf(err, "failed to write %q", filePath)
+		}
+	} else if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove %q", filePath)
+	}
+	s.LeakCheck = enabled
+	return nil
+}
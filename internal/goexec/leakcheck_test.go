@@ -0,0 +1,43 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakCheck(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	require.NoError(t, s.SetLeakCheck(true))
+	assert.FileExists(t, path.Join(s.TempDir, LeakCheckGo))
+	assert.Contains(t, s.mainPreamble(), "defer gonbLeakCheck()()")
+
+	// The program leaks one goroutine, and another finishes before the check times out.
+	program := `package main
+
+import "time"
+
+func main() {
+	defer gonbLeakCheck()()
+	go func() { time.Sleep(100 * time.Millisecond) }()
+	go func() { select {} }()
+}
+`
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(program), 0600))
+	output, err := s.goCommand("run", ".").CombinedOutput()
+	require.NoErrorf(t, err, "Failed to run program: %s", output)
+	assert.Contains(t, string(output), "GoNB: 1 goroutine(s) still running")
+	assert.Contains(t, string(output), "main.main.func2()")
+	assert.NotContains(t, string(output), "main.main.func1()")
+
+	require.NoError(t, s.SetLeakCheck(false))
+	assert.NoFileExists(t, path.Join(s.TempDir, LeakCheckGo))
+	assert.NotContains(t, s.mainPreamble(), "gonbLeakCheck")
+}
@@ -0,0 +1,262 @@
+package goexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+)
+
+// This file implements `%config logview=on`: the structured logs (JSON lines, as written by log/slog's
+// JSONHandler, zap, zerolog or logrus) printed by the program are collected, and displayed as a filterable
+// table after the execution, instead of as raw text.
+
+// MaxLogViewRecords is the maximum number of log records displayed in the table: the older ones are dropped.
+const MaxLogViewRecords = 5000
+
+// Keys of the standard fields of the log records, as used by the different logging libraries.
+var (
+	logMessageKeys = []string{"msg", "message"}
+	logLevelKeys   = []string{"level", "lvl", "severity"}
+	logTimeKeys    = []string{"time", "ts", "timestamp"}
+)
+
+// logAttr is an attribute of a log record, with its value as JSON.
+type logAttr struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// logRecord is a structured log line printed by the program.
+type logRecord struct {
+	Stream, Time, Level, Msg string
+	Attrs                    []logAttr
+}
+
+// parseLogLine parses a JSON log line. It returns false if the line is not a JSON object with a message.
+func parseLogLine(line []byte) (*logRecord, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) < 2 || line[0] != '{' || line[len(line)-1] != '}' {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, false
+	}
+	var attrs []logAttr // Kept in the order printed.
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return nil, false
+		}
+		attrs = append(attrs, logAttr{Key: key, Value: value})
+	}
+
+	record := &logRecord{}
+	var hasMsg bool
+	attrs, record.Msg, hasMsg = takeLogField(attrs, logMessageKeys)
+	if !hasMsg {
+		return nil, false
+	}
+	attrs, record.Level, _ = takeLogField(attrs, logLevelKeys)
+	record.Level = strings.ToUpper(record.Level)
+	attrs, record.Time, _ = takeLogField(attrs, logTimeKeys)
+	record.Attrs = attrs
+	return record, true
+}
+
+// takeLogField removes the first attribute with one of the keys, and returns its value formatted as text.
+func takeLogField(attrs []logAttr, keys []string) (remaining []logAttr, value string, found bool) {
+	for ii, attr := range attrs {
+		for _, key := range keys {
+			if attr.Key == key {
+				remaining = append(attrs[:ii:ii], attrs[ii+1:]...)
+				return remaining, formatLogValue(key, attr.Value), true
+			}
+		}
+	}
+	return attrs, "", false
+}
+
+// formatLogValue formats the JSON value of a log field as text: strings are unquoted, and numeric
+// timestamps (in seconds since epoch, as written by zap) are converted to time.
+func formatLogValue(key string, value json.RawMessage) string {
+	var str string
+	if json.Unmarshal(value, &str) == nil {
+		return str
+	}
+	if key == "ts" || key == "time" || key == "timestamp" {
+		var seconds float64
+		if json.Unmarshal(value, &seconds) == nil {
+			sec, frac := math.Modf(seconds)
+			return time.Unix(int64(sec), int64(frac*1e9)).Format(time.RFC3339Nano)
+		}
+	}
+	return string(value)
+}
+
+// logCollector collects the log records of the program output, see logCollector.writer.
+type logCollector struct {
+	mu      sync.Mutex
+	records []*logRecord
+	dropped int
+	writers []*logViewWriter
+}
+
+// writer returns a writer that collects the log lines written to it, and forwards everything else to next.
+func (c *logCollector) writer(stream string, next io.Writer) io.Writer {
+	w := &logViewWriter{collector: c, stream: stream, next: next}
+	c.writers = append(c.writers, w)
+	return w
+}
+
+// add a record, dropping the oldest if there are more than MaxLogViewRecords.
+func (c *logCollector) add(record *logRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, record)
+	if len(c.records) > MaxLogViewRecords {
+		c.records = c.records[1:]
+		c.dropped++
+	}
+}
+
+// publish the collected records as a table, after flushing the incomplete lines of the writers.
+func (c *logCollector) publish(msg kernel.Message) error {
+	for _, w := range c.writers {
+		w.flush()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.records) == 0 {
+		return nil
+	}
+	return kernel.PublishHtml(msg, logViewHtml(c.records, c.dropped))
+}
+
+// logViewWriter separates the log lines written to it, see logCollector.writer.
+type logViewWriter struct {
+	collector *logCollector
+	stream    string
+	next      io.Writer
+	partial   []byte // Incomplete line that may be a log record.
+}
+
+// Write implements io.Writer. Lines that can't be log records (not starting with "{") are forwarded
+// immediately, even if incomplete, so progress output is not delayed.
+func (w *logViewWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		lineEnd := bytes.IndexByte(p, '\n') + 1
+		if len(w.partial) == 0 && len(bytes.TrimLeft(p, " \t")) > 0 && bytes.TrimLeft(p, " \t")[0] != '{' {
+			if lineEnd == 0 {
+				lineEnd = len(p)
+			}
+			if _, err := w.next.Write(p[:lineEnd]); err != nil {
+				return n, err
+			}
+			p = p[lineEnd:]
+			continue
+		}
+		if lineEnd == 0 {
+			w.partial = append(w.partial, p...)
+			break
+		}
+		line := append(w.partial, p[:lineEnd]...)
+		w.partial = nil
+		p = p[lineEnd:]
+		if record, ok := parseLogLine(line); ok {
+			record.Stream = w.stream
+			w.collector.add(record)
+		} else if _, err := w.next.Write(line); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// flush parses or forwards the last incomplete line.
+func (w *logViewWriter) flush() {
+	if len(w.partial) == 0 {
+		return
+	}
+	if record, ok := parseLogLine(w.partial); ok {
+		record.Stream = w.stream
+		w.collector.add(record)
+	} else {
+		_, _ = w.next.Write(w.partial)
+	}
+	w.partial = nil
+}
+
+// logLevelColors maps the log levels to the color of their cell.
+var logLevelColors = map[string]string{
+	"DEBUG": "gray", "TRACE": "gray", "WARN": "darkorange", "WARNING": "darkorange",
+	"ERROR": "crimson", "FATAL": "crimson", "PANIC": "crimson", "DPANIC": "crimson", "CRITICAL": "crimson",
+}
+
+// logViewHtml renders the log records as a table, with a text filter and a level selector.
+func logViewHtml(records []*logRecord, dropped int) string {
+	id := "gonb_logview_" + UniqueId()
+	levels := MakeSet[string]()
+	for _, record := range records {
+		if record.Level != "" {
+			levels.Insert(record.Level)
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<div id="%s">`+"\n", id)
+	fmt.Fprintf(&sb, `<div>%d log records`, len(records))
+	if dropped > 0 {
+		fmt.Fprintf(&sb, ` (%d older records dropped)`, dropped)
+	}
+	sb.WriteString(`: <input type="text" placeholder="Filter..." oninput="gonbFilterLogs_` + id + `()"/> `)
+	sb.WriteString(`<select onchange="gonbFilterLogs_` + id + `()"><option value="">All levels</option>`)
+	for _, level := range SortedKeys(levels) {
+		fmt.Fprintf(&sb, `<option>%s</option>`, html.EscapeString(level))
+	}
+	sb.WriteString("</select></div>\n")
+	sb.WriteString("<table>\n<thead><tr><th>Time</th><th>Level</th><th>Message</th><th>Attributes</th></tr></thead>\n<tbody>\n")
+	for _, record := range records {
+		attrs := make([]string, 0, len(record.Attrs))
+		for _, attr := range record.Attrs {
+			attrs = append(attrs, attr.Key+"="+formatLogValue(attr.Key, attr.Value))
+		}
+		style := ""
+		if color, found := logLevelColors[record.Level]; found {
+			style = fmt.Sprintf(` style="color: %s"`, color)
+		}
+		fmt.Fprintf(&sb, `<tr data-level="%s" title="%s"><td style="white-space: nowrap">%s</td><td%s>%s</td>`+
+			`<td style="text-align: left">%s</td><td style="text-align: left"><code>%s</code></td></tr>`+"\n",
+			html.EscapeString(record.Level), record.Stream, html.EscapeString(record.Time), style,
+			html.EscapeString(record.Level), html.EscapeString(record.Msg), html.EscapeString(strings.Join(attrs, " ")))
+	}
+	sb.WriteString("</tbody>\n</table>\n")
+	fmt.Fprintf(&sb, `<script>
+function gonbFilterLogs_%[1]s() {
+	let div = document.getElementById("%[1]s");
+	let text = div.querySelector("input").value.toLowerCase();
+	let level = div.querySelector("select").value;
+	for (let row of div.querySelectorAll("tbody tr")) {
+		let visible = (level === "" || row.dataset.level === level) && row.textContent.toLowerCase().includes(text);
+		row.style.display = visible ? "" : "none";
+	}
+}
+</script>
+</div>`, id)
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package goexec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLine(t *testing.T) {
+	// log/slog JSONHandler.
+	record, ok := parseLogLine([]byte(`{"time":"2024-05-01T10:00:00Z","level":"INFO","msg":"started","port":8080,"tls":false}` + "\n"))
+	require.True(t, ok)
+	assert.Equal(t, "2024-05-01T10:00:00Z", record.Time)
+	assert.Equal(t, "INFO", record.Level)
+	assert.Equal(t, "started", record.Msg)
+	require.Len(t, record.Attrs, 2)
+	assert.Equal(t, "port", record.Attrs[0].Key)
+	assert.Equal(t, "8080", formatLogValue("port", record.Attrs[0].Value))
+
+	// zap: lower case level and numeric timestamp.
+	record, ok = parseLogLine([]byte(`{"level":"warn","ts":1714557600.5,"msg":"slow request"}`))
+	require.True(t, ok)
+	assert.Equal(t, "WARN", record.Level)
+	assert.Contains(t, record.Time, ":00.5")
+
+	// Not log records.
+	for _, line := range []string{`plain text`, `{"no":"message"}`, `{"msg": broken`, `[1, 2]`} {
+		_, ok = parseLogLine([]byte(line))
+		assert.Falsef(t, ok, "line %q", line)
+	}
+}
+
+func TestLogViewWriter(t *testing.T) {
+	collector := &logCollector{}
+	var out bytes.Buffer
+	w := collector.writer("stderr", &out)
+	_, err := w.Write([]byte("progress: 10%\rprogress: 20%\n{\"level\":\"error\",\"msg\":\"fa"))
+	require.NoError(t, err)
+	assert.Equal(t, "progress: 10%\rprogress: 20%\n", out.String(), "text must be forwarded immediately")
+	_, err = w.Write([]byte("iled\"}\n{\"not\":\"a log\"}\ndone"))
+	require.NoError(t, err)
+	for _, w := range collector.writers {
+		w.flush()
+	}
+	assert.Equal(t, "progress: 10%\rprogress: 20%\n{\"not\":\"a log\"}\ndone", out.String())
+	require.Len(t, collector.records, 1)
+	assert.Equal(t, "failed", collector.records[0].Msg)
+	assert.Equal(t, "stderr", collector.records[0].Stream)
+
+	html := logViewHtml(collector.records, 0)
+	assert.Contains(t, html, `<option>ERROR</option>`)
+	assert.Contains(t, html, `<td style="color: crimson">ERROR</td>`)
+}
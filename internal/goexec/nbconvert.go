@@ -0,0 +1,212 @@
+package goexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the conversion of a notebook to a Go program (`gonb nbconvert`), outside Jupyter:
+// the code cells are parsed and their declarations merged as if they were executed in order, and the
+// result is rendered with the same composer used by the kernel.
+
+// ReadNotebookCells reads the contents of the code cells of a Jupyter notebook (`.ipynb` file), one
+// slice of lines per cell.
+func ReadNotebookCells(notebookPath string) (cells [][]string, err error) {
+	contents, err := os.ReadFile(notebookPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read notebook %q", notebookPath)
+	}
+	var notebook struct {
+		Cells []struct {
+			CellType string          `json:"cell_type"`
+			Source   json.RawMessage `json:"source"`
+		} `json:"cells"`
+	}
+	if err = json.Unmarshal(contents, &notebook); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse notebook %q", notebookPath)
+	}
+	for ii, cell := range notebook.Cells {
+		if cell.CellType != "code" {
+			continue
+		}
+		// The source is either a string or a list of strings (the lines, each with its "\n").
+		var source string
+		if err = json.Unmarshal(cell.Source, &source); err != nil {
+			var sourceLines []string
+			if err = json.Unmarshal(cell.Source, &sourceLines); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse source of cell #%d of notebook %q", ii, notebookPath)
+			}
+			source = strings.Join(sourceLines, "")
+		}
+		cells = append(cells, strings.Split(source, "\n"))
+	}
+	return cells, nil
+}
+
+// nbConvertSkippedCellCommands are the special commands that make a cell not be converted, since it is
+// not part of the program (e.g.: tests or a file written by `%%writefile`).
+var nbConvertSkippedCellCommands = MakeSet[string]()
+
+func init() {
+	for _, cmd := range []string{"%test", "%wasm", "%serve", "%%writefile", "%%c", "%%script", "%%bash", "%%sh"} {
+		nbConvertSkippedCellCommands.Insert(cmd)
+	}
+}
+
+// mergeNotebookCells parses the code cells of a notebook (see ReadNotebookCells) and merges their
+// declarations into s.Definitions, in order, as if they were executed in the kernel. It returns the
+// `main` functions defined by the cells, with the index of their cells (starting from 1).
+//
+// Special commands (lines starting with "%" or "!") are not converted: the returned warnings list the
+// ones dropped and the cells skipped.
+func (s *State) mergeNotebookCells(cells [][]string) (mains []*Function, mainCellIds []int, warnings []string, err error) {
+cellsLoop:
+	for ii, lines := range cells {
+		cellId := ii + 1
+		skipLines := MakeSet[int]()
+		for lineNum, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if !strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "!") {
+				continue
+			}
+			fields := strings.Fields(trimmed)
+			switch {
+			case fields[0] == "%%" || fields[0] == "%main":
+				// `func main()` is created by the composer.
+				continue
+			case nbConvertSkippedCellCommands.Has(fields[0]):
+				warnings = append(warnings, fmt.Sprintf("cell #%d skipped, because of `%s`", cellId, trimmed))
+				continue cellsLoop
+			case fields[0] == "%reset" && len(fields) == 1:
+				s.Definitions = NewDeclarations()
+				mains, mainCellIds = nil, nil
+			default:
+				warnings = append(warnings, fmt.Sprintf("cell #%d, line %d: special command `%s` dropped",
+					cellId, lineNum+1, trimmed))
+			}
+			skipLines.Insert(lineNum)
+			// Continuation lines of special commands.
+			for strings.HasSuffix(lines[lineNum], "\\") && lineNum+1 < len(lines) {
+				lineNum++
+				skipLines.Insert(lineNum)
+			}
+		}
+
+		updatedDecls, mainDecl, _, _, err := s.parseLinesAndComposeMain(nil, cellId, lines, skipLines, NoCursor)
+		if err != nil {
+			return nil, nil, warnings, errors.WithMessagef(err, "in cell #%d", cellId)
+		}
+		s.Definitions = updatedDecls
+		if len(mainDecl.CellLines.Lines) > 0 {
+			// Not the stub `main` created when the cell doesn't define one.
+			mains = append(mains, mainDecl)
+			mainCellIds = append(mainCellIds, cellId)
+		}
+	}
+	return mains, mainCellIds, warnings, nil
+}
+
+// formatProgram fixes the imports of the Go code with `goimports`, executed in dir (if not empty), or
+// only formats it, if `goimports` is not installed, in which case a warning is returned.
+func formatProgram(code []byte, dir string) (formatted []byte, warning string, err error) {
+	if goimportsPath, lookErr := exec.LookPath("goimports"); lookErr == nil {
+		cmd := exec.Command(goimportsPath)
+		cmd.Dir = dir
+		cmd.Stdin = bytes.NewReader(code)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		klog.V(2).Infof("Executing %s", cmd)
+		formatted, err = cmd.Output()
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to run %q: %s", cmd, stderr.String())
+		}
+		return formatted, "", nil
+	}
+	warning = "`goimports` not found in PATH: missing or unused imports are not fixed, " +
+		"install it with `go install golang.org/x/tools/cmd/goimports@latest`"
+	formatted, err = format.Source(code)
+	if err != nil {
+		return nil, warning, errors.Wrapf(err, "failed to format the program")
+	}
+	return formatted, warning, nil
+}
+
+// ConvertNotebook composes the code cells of a notebook (see ReadNotebookCells) into one Go program,
+// merging the declarations of the cells in order, as if they were executed in the kernel.
+//
+// If only one cell defines `main` (or uses `%%`), it is the `main` of the program. If more than one
+// does, each one is renamed `mainCell<N>` (N being the index of the code cell, starting from 1), and
+// called in order by `main`.
+//
+// Special commands (lines starting with "%" or "!") are not converted: the returned warnings list the
+// ones dropped and the cells skipped. If `goimports` is installed, it is used to fix the imports, as the
+// kernel does, otherwise the program is only formatted.
+func ConvertNotebook(cells [][]string) (code string, warnings []string, err error) {
+	tempDir, err := os.MkdirTemp("", "gonb_nbconvert_")
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to create temporary directory")
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+	s := &State{TempDir: tempDir, Definitions: NewDeclarations()}
+
+	mains, mainCellIds, warnings, err := s.mergeNotebookCells(cells)
+	if err != nil {
+		return "", warnings, err
+	}
+
+	decls := s.Definitions.Copy()
+	decls.ClearCursor()
+	var mainDecl *Function
+	switch len(mains) {
+	case 0:
+		mainDecl = &Function{Cursor: NoCursor, Key: "main", Name: "main", Definition: "func main() { flag.Parse() }"}
+	case 1:
+		mainDecl = mains[0]
+	default:
+		var calls []string
+		for ii, cellMain := range mains {
+			name := fmt.Sprintf("mainCell%d", mainCellIds[ii])
+			decls.Functions[name] = &Function{
+				Cursor:     NoCursor,
+				CellLines:  cellMain.CellLines,
+				Key:        name,
+				Name:       name,
+				Definition: strings.Replace(cellMain.Definition, "func main()", "func "+name+"()", 1),
+			}
+			calls = append(calls, "\t"+name+"()\n")
+		}
+		mainDecl = &Function{
+			Cursor: NoCursor, Key: "main", Name: "main",
+			Definition: "func main() {\n\tflag.Parse()\n" + strings.Join(calls, "") + "}",
+		}
+	}
+
+	if _, found := decls.Imports["flag"]; !found {
+		// Used by `main`, in case `goimports` is not available.
+		flagImport := NewImport("flag", "")
+		flagImport.Cursor = NoCursor
+		decls.Imports[flagImport.Key] = flagImport
+	}
+
+	var buf bytes.Buffer
+	if _, _, err = s.createCodeFromDecls(&buf, decls, mainDecl); err != nil {
+		return "", warnings, errors.WithMessagef(err, "while composing the program")
+	}
+	formatted, warning, err := formatProgram(buf.Bytes(), "")
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if err != nil {
+		return "", warnings, err
+	}
+	return string(formatted), warnings, nil
+}
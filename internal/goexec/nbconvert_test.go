@@ -0,0 +1,55 @@
+package goexec
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertNotebook(t *testing.T) {
+	notebookPath := path.Join(t.TempDir(), "notebook.ipynb")
+	require.NoError(t, os.WriteFile(notebookPath, []byte(`{
+ "cells": [
+  {"cell_type": "markdown", "source": ["# Title\n"]},
+  {"cell_type": "code", "source": ["import \"fmt\"\n", "\n", "func hello(name string) string { return \"Hello \" + name }\n"]},
+  {"cell_type": "code", "source": "!echo ignored\n%%\nfmt.Println(hello(\"world\"))"},
+  {"cell_type": "code", "source": ["%test\n", "func TestHello(t *testing.T) {}\n"]}
+ ],
+ "metadata": {}, "nbformat": 4, "nbformat_minor": 5
+}`), 0600))
+	cells, err := ReadNotebookCells(notebookPath)
+	require.NoError(t, err)
+	require.Len(t, cells, 3)
+	assert.Equal(t, []string{"%test", "func TestHello(t *testing.T) {}", ""}, cells[2])
+
+	code, warnings, err := ConvertNotebook(cells)
+	require.NoError(t, err)
+	assert.Contains(t, warnings, "cell #2, line 1: special command `!echo ignored` dropped")
+	assert.Contains(t, warnings, "cell #3 skipped, because of `%test`")
+	assert.Contains(t, code, "func hello(name string) string")
+	assert.Contains(t, code, "fmt.Println(hello(\"world\"))")
+	assert.NotContains(t, code, "TestHello")
+	assert.NotContains(t, code, "mainCell")
+
+	// With more than one `main`, they are called in order.
+	cells = append(cells, []string{"%%", `fmt.Println(hello("again"))`})
+	code, _, err = ConvertNotebook(cells)
+	require.NoError(t, err)
+	assert.Contains(t, code, "func mainCell2() {")
+	assert.Contains(t, code, "func mainCell4() {")
+	assert.Regexp(t, `(?s)func main\(\) \{\s*flag.Parse\(\)\s*mainCell2\(\)\s*mainCell4\(\)\s*}`, code)
+
+	// The program runs.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "main.go"), []byte(code), 0600))
+	require.NoError(t, os.WriteFile(path.Join(dir, "go.mod"), []byte("module nbconvert_test\n\ngo 1.21\n"), 0600))
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoErrorf(t, err, "Output:\n%s", output)
+	assert.Equal(t, "Hello world\nHello again\n", string(output))
+}
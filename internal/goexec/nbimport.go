@@ -0,0 +1,135 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%nbimport <notebook.ipynb> as <name>`: the declarations of another notebook are
+// composed (as in `gonb nbconvert`) into a package of the temporary module, which is imported as <name>
+// by the following cells. It allows shared helper notebooks.
+
+// NotebookImportsDir is the directory, under State.TempDir, with the packages created by `%nbimport`.
+const NotebookImportsDir = "gonb_nbimport"
+
+// notebookImportPath returns the import path of the package created by `%nbimport` with the given name.
+func (s *State) notebookImportPath(name string) string {
+	return path.Join(s.Package, NotebookImportsDir, name)
+}
+
+// NotebookImport composes the declarations of the code cells of the notebook into a package named `name`
+// in the temporary module, and memorizes its import, so the exported declarations are accessible as
+// `<name>.<Identifier>` in the following cells.
+//
+// The `main` functions (and `%%` cells) of the notebook are dropped, and so are its special commands.
+// Importing a notebook again with the same name updates the package.
+func (s *State) NotebookImport(msg kernel.Message, notebookPath, name string) error {
+	if !token.IsIdentifier(name) || name == "main" || name == "_" {
+		return errors.Errorf("invalid package name %q for %%nbimport: it must be a Go identifier, other than `main`",
+			name)
+	}
+	cells, err := ReadNotebookCells(notebookPath)
+	if err != nil {
+		return err
+	}
+
+	// Parse the notebook in a separate State, with its own scratch directory, so the `main.go` of the kernel
+	// is left untouched. The `go.mod` and `go.sum` are copied, for `goimports` to resolve the same modules.
+	scratchDir, err := os.MkdirTemp("", "gonb_nbimport_")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary directory")
+	}
+	defer func() { _ = os.RemoveAll(scratchDir) }()
+	for _, fileName := range []string{"go.mod", "go.sum"} {
+		if contents, err := os.ReadFile(path.Join(s.TempDir, fileName)); err == nil {
+			if err = os.WriteFile(path.Join(scratchDir, fileName), contents, 0644); err != nil {
+				return errors.Wrapf(err, "failed to copy %q to %q", fileName, scratchDir)
+			}
+		}
+	}
+	nbState := &State{TempDir: scratchDir, Package: s.Package, Definitions: NewDeclarations()}
+	_, _, warnings, err := nbState.mergeNotebookCells(cells)
+	if err != nil {
+		return errors.WithMessagef(err, "in notebook %q", notebookPath)
+	}
+	decls := nbState.Definitions
+	decls.ClearCursor()
+	if len(decls.Functions)+len(decls.Variables)+len(decls.Types)+len(decls.Constants) == 0 {
+		return errors.Errorf("notebook %q has no declarations to import", notebookPath)
+	}
+
+	var buf bytes.Buffer
+	if _, _, err = nbState.createCodeFromDecls(&buf, decls, nil); err != nil {
+		return errors.WithMessagef(err, "while composing package %q", name)
+	}
+	code, found := strings.CutPrefix(buf.String(), "package main\n")
+	if !found {
+		return errors.Errorf("unexpected code composed for package %q", name)
+	}
+	code = fmt.Sprintf("// Package %s is generated by GoNB from the notebook %q (`%%nbimport`).\npackage %s\n%s",
+		name, filepath.Base(notebookPath), name, code)
+
+	pkgDir := path.Join(s.TempDir, NotebookImportsDir, name)
+	if err = os.MkdirAll(pkgDir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %q", pkgDir)
+	}
+	formatted, warning, err := formatProgram([]byte(code), pkgDir)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "in package composed from notebook %q", notebookPath)
+	}
+	pkgFile := path.Join(pkgDir, name+".go")
+	if err = os.WriteFile(pkgFile, formatted, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %q", pkgFile)
+	}
+
+	if s.notebookImports == nil {
+		s.notebookImports = make(map[string]string)
+	}
+	s.notebookImports[name] = s.notebookImportPath(name)
+	for _, warning := range warnings {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, "%nbimport: "+warning+"\n")
+	}
+	var exported []string
+	for _, key := range SortedKeys(decls.Functions) {
+		if fn := decls.Functions[key]; fn.Receiver == "" && token.IsExported(fn.Name) {
+			exported = append(exported, fn.Name)
+		}
+	}
+	summary := fmt.Sprintf("Notebook %q imported as package `%s`", notebookPath, name)
+	if len(exported) > 0 {
+		summary += fmt.Sprintf(", with functions %s", strings.Join(exported, ", "))
+	}
+	return kernel.PublishMarkdown(msg, summary+". Only exported (capitalized) declarations are accessible.")
+}
+
+// addNotebookImports adds the imports of the packages created by `%nbimport` to the declarations, if
+// not imported otherwise. `goimports` removes the ones not used.
+func (s *State) addNotebookImports(decls *Declarations) {
+	for _, name := range SortedKeys(s.notebookImports) {
+		if _, found := decls.Imports[name]; found {
+			continue
+		}
+		imp := NewImport(s.notebookImports[name], name)
+		imp.Cursor = NoCursor
+		decls.Imports[imp.Key] = imp
+	}
+}
+
+// RemoveNotebookImports removes the packages created by `%nbimport`, and their imports.
+func (s *State) RemoveNotebookImports() error {
+	s.notebookImports = nil
+	dir := path.Join(s.TempDir, NotebookImportsDir)
+	return errors.Wrapf(os.RemoveAll(dir), "failed to remove %q", dir)
+}
@@ -0,0 +1,53 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotebookImport(t *testing.T) {
+	s := &State{TempDir: t.TempDir(), Package: "gonb_test", Definitions: NewDeclarations()}
+	notebookPath := path.Join(t.TempDir(), "helpers.ipynb")
+	require.NoError(t, os.WriteFile(notebookPath, []byte(`{
+ "cells": [
+  {"cell_type": "code", "source": ["func Double(x int) int { return 2 * x }\n", "\n", "const Scale = 3\n"]},
+  {"cell_type": "code", "source": "%%\nfmt.Println(Double(Scale))"}
+ ],
+ "metadata": {}, "nbformat": 4, "nbformat_minor": 5
+}`), 0600))
+
+	// The program of the kernel is left untouched.
+	mainGo := "package main\n\nfunc main() {}\n"
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(mainGo), 0600))
+
+	require.Error(t, s.NotebookImport(nil, notebookPath, "main"))
+	require.Error(t, s.NotebookImport(nil, notebookPath, "not-valid"))
+	require.NoError(t, s.NotebookImport(nil, notebookPath, "helpers"))
+
+	contents, err := os.ReadFile(path.Join(s.TempDir, NotebookImportsDir, "helpers", "helpers.go"))
+	require.NoError(t, err)
+	code := string(contents)
+	assert.Contains(t, code, "package helpers\n")
+	assert.Contains(t, code, "func Double(x int) int")
+	assert.Contains(t, code, "Scale = 3")
+	assert.NotContains(t, code, "func main()")
+	contents, err = os.ReadFile(s.CodePath())
+	require.NoError(t, err)
+	assert.Equal(t, mainGo, string(contents))
+
+	// Following cells import it.
+	decls := NewDeclarations()
+	s.addNotebookImports(decls)
+	require.Contains(t, decls.Imports, "helpers")
+	assert.Equal(t, "gonb_test/gonb_nbimport/helpers", decls.Imports["helpers"].Path)
+
+	require.NoError(t, s.RemoveNotebookImports())
+	assert.NoDirExists(t, path.Join(s.TempDir, NotebookImportsDir))
+	decls = NewDeclarations()
+	s.addNotebookImports(decls)
+	assert.Empty(t, decls.Imports)
+}
@@ -0,0 +1,127 @@
+package goexec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+)
+
+// This file implements the parameters of notebooks run headless (`gonb run --param <name>=<value>`): like in
+// papermill, a cell tagged "parameters" declares the parameters (as `const` or `var`) with their default
+// values, and a cell tagged "injected-parameters" is inserted after it, re-declaring them with the values
+// given -- later declarations replace the previous ones, as usual in GoNB.
+
+const (
+	// ParametersCellTag is the tag of the cell that declares the parameters of a notebook.
+	ParametersCellTag = "parameters"
+
+	// InjectedParametersCellTag is the tag of the cell, inserted after the parameters cell, with the
+	// declarations of the parameter values given.
+	InjectedParametersCellTag = "injected-parameters"
+)
+
+// notebookParameter is a parameter declared in the parameters cell.
+type notebookParameter struct {
+	tok      token.Token // token.CONST or token.VAR.
+	typeExpr string      // Empty if not declared.
+	kind     token.Token // Kind of the default value, if it is a basic literal, or token.ILLEGAL.
+}
+
+// parseNotebookParameters returns the `const` and `var` declared in the parameters cell.
+func parseNotebookParameters(lines []string) (map[string]*notebookParameter, error) {
+	var sb strings.Builder
+	sb.WriteString("package main\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") {
+			line = "" // Keeps line numbers.
+		}
+		sb.WriteString(line + "\n")
+	}
+	fileSet := token.NewFileSet()
+	fileObj, err := parser.ParseFile(fileSet, "parameters.go", sb.String(), parser.SkipObjectResolution)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse cell tagged %q", ParametersCellTag)
+	}
+	params := make(map[string]*notebookParameter)
+	for _, decl := range fileObj.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || (genDecl.Tok != token.CONST && genDecl.Tok != token.VAR) {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			var typeExpr string
+			if valueSpec.Type != nil {
+				start, end := fileSet.Position(valueSpec.Type.Pos()).Offset, fileSet.Position(valueSpec.Type.End()).Offset
+				typeExpr = sb.String()[start:end]
+			}
+			for ii, name := range valueSpec.Names {
+				param := &notebookParameter{tok: genDecl.Tok, typeExpr: typeExpr, kind: token.ILLEGAL}
+				if ii < len(valueSpec.Values) {
+					if lit, ok := valueSpec.Values[ii].(*ast.BasicLit); ok {
+						param.kind = lit.Kind
+					}
+				}
+				params[name.Name] = param
+			}
+		}
+	}
+	return params, nil
+}
+
+// ParseNotebookParameterFlags parses the `<name>=<value>` settings of the parameters given to `gonb run`.
+func ParseNotebookParameterFlags(settings []string) (map[string]string, error) {
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		name, value, found := strings.Cut(setting, "=")
+		if !found || !token.IsIdentifier(name) {
+			return nil, errors.Errorf("invalid parameter %q, it must be of the form `<name>=<value>`", setting)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// InjectedParametersCode returns the code of the "injected-parameters" cell: the declarations of the
+// parameters declared in the parameters cell (given by its lines), with the values given.
+//
+// Values of string parameters are quoted if they are not already a Go string literal. Other values must be Go
+// expressions, converted to float64 if the default value of an untyped `var` is a floating point number.
+func InjectedParametersCode(parametersCell []string, values map[string]string) (string, error) {
+	params, err := parseNotebookParameters(parametersCell)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("// Parameters injected by `gonb run`.\n")
+	for _, name := range SortedKeys(values) {
+		param, found := params[name]
+		if !found {
+			return "", errors.Errorf("parameter %q is not declared (as a `const` or `var`) in the cell tagged %q",
+				name, ParametersCellTag)
+		}
+		value := values[name]
+		if param.typeExpr == "string" || (param.typeExpr == "" && param.kind == token.STRING) {
+			if _, err := strconv.Unquote(value); err != nil {
+				value = strconv.Quote(value)
+			}
+		} else if _, err := parser.ParseExpr(value); err != nil {
+			return "", errors.Errorf("value %q of parameter %q is not a valid Go expression", value, name)
+		} else if param.typeExpr == "" && param.kind == token.FLOAT && param.tok == token.VAR {
+			value = fmt.Sprintf("float64(%s)", value)
+		}
+		declType := ""
+		if param.typeExpr != "" {
+			declType = " " + param.typeExpr
+		}
+		sb.WriteString(fmt.Sprintf("%s %s%s = %s\n", param.tok, name, declType, value))
+	}
+	return sb.String(), nil
+}
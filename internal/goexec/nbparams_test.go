@@ -0,0 +1,44 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectedParametersCode(t *testing.T) {
+	values, err := ParseNotebookParameterFlags([]string{"N=10", "Name=gonb", "Quoted=\"x y\"", "Ratio=2", "Limit=1<<4"})
+	require.NoError(t, err)
+	assert.Equal(t, "gonb", values["Name"])
+	_, err = ParseNotebookParameterFlags([]string{"1N=10"})
+	require.Error(t, err)
+	_, err = ParseNotebookParameterFlags([]string{"N"})
+	require.Error(t, err)
+
+	parametersCell := strings.Split(`%env FOO=bar
+const N = 3
+var (
+	Name = "world"
+	Quoted string
+	Ratio = 0.5
+)
+var Limit int64 = 1024`, "\n")
+	code, err := InjectedParametersCode(parametersCell, values)
+	require.NoError(t, err)
+	assert.Equal(t, "// Parameters injected by `gonb run`.\n"+
+		"var Limit int64 = 1<<4\n"+
+		"const N = 10\n"+
+		"var Name = \"gonb\"\n"+
+		"var Quoted string = \"x y\"\n"+
+		"var Ratio = float64(2)\n", code)
+
+	// Undeclared parameter.
+	_, err = InjectedParametersCode(parametersCell, map[string]string{"Other": "1"})
+	require.ErrorContains(t, err, "\"Other\" is not declared")
+
+	// Invalid value.
+	_, err = InjectedParametersCode(parametersCell, map[string]string{"N": "3 +"})
+	require.ErrorContains(t, err, "not a valid Go expression")
+}
@@ -0,0 +1,292 @@
+package goexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements persisting the sticky settings of a notebook (`%config` options, `%env` variables and
+// `%goflags`) in the metadata of the notebook, with `%settings save`: the settings are sent to the front-end,
+// where the `gonb_comm` Javascript (see package websocket) stores them in the notebook model, so they are
+// saved with the notebook. When the notebook is reopened, the settings are read from the notebook file and
+// restored on the first execution.
+//
+// Environment variables whose names look like secrets (see IsSecretEnvName) are never persisted, since the
+// notebook is usually shared.
+
+const (
+	// NotebookMetadataKey is the key of the settings in the metadata of the notebook.
+	NotebookMetadataKey = "gonb"
+
+	// notebookSettingsAddress is the comms address where the settings to store in the notebook metadata are
+	// sent, and notebookSettingsAckAddress where the front-end acknowledges them.
+	notebookSettingsAddress    = "#gonb/notebook_settings"
+	notebookSettingsAckAddress = "#gonb/notebook_settings/ack"
+)
+
+// NotebookSettingsAckTimeout is the time to wait for the front-end to acknowledge the settings were stored
+// in the notebook metadata.
+var NotebookSettingsAckTimeout = 3 * time.Second
+
+// NotebookSettings are the sticky settings of a notebook, persisted in its metadata under NotebookMetadataKey.
+type NotebookSettings struct {
+	Config  map[string]string `json:"config,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	GoFlags []string          `json:"goflags,omitempty"`
+}
+
+// IsEmpty returns whether there are no settings.
+func (ns *NotebookSettings) IsEmpty() bool {
+	return len(ns.Config) == 0 && len(ns.Env) == 0 && len(ns.GoFlags) == 0
+}
+
+// Commands returns the special commands that set the settings, in a stable order.
+func (ns *NotebookSettings) Commands() []string {
+	var commands []string
+	for _, key := range common.SortedKeys(ns.Config) {
+		commands = append(commands, fmt.Sprintf("%%config %s=%s", key, ns.Config[key]))
+	}
+	for _, key := range common.SortedKeys(ns.Env) {
+		commands = append(commands, fmt.Sprintf("%%env %s=%s", key, ns.Env[key]))
+	}
+	if len(ns.GoFlags) > 0 {
+		commands = append(commands, "%goflags "+strings.Join(ns.GoFlags, " "))
+	}
+	return commands
+}
+
+// reSecretEnvName matches the names of environment variables that likely hold secrets.
+var reSecretEnvName = regexp.MustCompile(`(?i)TOKEN|KEY|SECRET|PASSWORD`)
+
+// IsSecretEnvName returns whether the name of the environment variable looks like it holds a secret (it
+// contains "TOKEN", "KEY", "SECRET" or "PASSWORD", in any case): those are not persisted by `%settings save`.
+func IsSecretEnvName(name string) bool {
+	return reSecretEnvName.MatchString(name)
+}
+
+// notebookSettingsState holds the settings set in the session, and the acknowledgement of the front-end.
+type notebookSettingsState struct {
+	mu         sync.Mutex
+	settings   NotebookSettings
+	skippedEnv common.Set[string]                  // Environment variables not recorded, see IsSecretEnvName.
+	restored   bool                                // Whether the settings of the notebook file were checked.
+	ack        *common.LatchWithValue[notebookAck] // Set while waiting for the front-end.
+}
+
+// notebookAck is the acknowledgement of the front-end to the settings sent.
+type notebookAck struct {
+	saved bool
+	err   string
+}
+
+// RecordConfigSetting records the `%config` option set, to be persisted by `%settings save`.
+func (s *State) RecordConfigSetting(key, value string) {
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	if s.nbSettings.settings.Config == nil {
+		s.nbSettings.settings.Config = make(map[string]string)
+	}
+	s.nbSettings.settings.Config[key] = value
+}
+
+// RecordEnvSetting records the environment variable set with `%env`, to be persisted by `%settings save`. It
+// returns false, and doesn't record it, if its name looks like a secret (see IsSecretEnvName).
+func (s *State) RecordEnvSetting(key, value string) (recorded bool) {
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	if IsSecretEnvName(key) {
+		if s.nbSettings.skippedEnv == nil {
+			s.nbSettings.skippedEnv = common.MakeSet[string]()
+		}
+		s.nbSettings.skippedEnv.Insert(key)
+		return false
+	}
+	if s.nbSettings.settings.Env == nil {
+		s.nbSettings.settings.Env = make(map[string]string)
+	}
+	s.nbSettings.settings.Env[key] = value
+	return true
+}
+
+// skippedEnvNote returns a note listing the environment variables set in the session that are not persisted,
+// because their names look like secrets, or "" if there are none.
+func (s *State) skippedEnvNote() string {
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	if len(s.nbSettings.skippedEnv) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Not saved, since their names look like secrets: %s.\n",
+		strings.Join(common.SortedKeys(s.nbSettings.skippedEnv), ", "))
+}
+
+// RecordGoFlagsSetting records the flags set with `%goflags`, to be persisted by `%settings save`.
+func (s *State) RecordGoFlagsSetting(flags []string) {
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	s.nbSettings.settings.GoFlags = slices.Clone(flags)
+}
+
+// NotebookSettings returns a copy of the settings recorded in the session.
+func (s *State) NotebookSettings() NotebookSettings {
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	settings := s.nbSettings.settings
+	settings.Config = maps.Clone(settings.Config)
+	settings.Env = maps.Clone(settings.Env)
+	settings.GoFlags = slices.Clone(settings.GoFlags)
+	return settings
+}
+
+// ReadNotebookSettings reads the settings persisted in the metadata of the notebook (`.ipynb` file). It
+// returns nil if there are none.
+func ReadNotebookSettings(notebookPath string) (*NotebookSettings, error) {
+	contents, err := os.ReadFile(notebookPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read notebook %q", notebookPath)
+	}
+	var notebook struct {
+		Metadata map[string]json.RawMessage `json:"metadata"`
+	}
+	if err = json.Unmarshal(contents, &notebook); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse notebook %q", notebookPath)
+	}
+	raw, found := notebook.Metadata[NotebookMetadataKey]
+	if !found {
+		return nil, nil
+	}
+	settings := &NotebookSettings{}
+	if err = json.Unmarshal(raw, settings); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the %q metadata of notebook %q", NotebookMetadataKey, notebookPath)
+	}
+	if settings.IsEmpty() {
+		return nil, nil
+	}
+	return settings, nil
+}
+
+// SettingsToRestore returns the settings persisted in the metadata of the notebook of the kernel (given by
+// Jupyter in JupyterSessionNameEnv), the first time it is called. Otherwise, or if there are none, it
+// returns nil.
+func (s *State) SettingsToRestore() *NotebookSettings {
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	if s.nbSettings.restored {
+		return nil
+	}
+	s.nbSettings.restored = true
+	notebookPath := os.Getenv(JupyterSessionNameEnv)
+	if notebookPath == "" || filepath.Ext(notebookPath) != ".ipynb" {
+		return nil
+	}
+	settings, err := ReadNotebookSettings(notebookPath)
+	if err != nil {
+		klog.Warningf("Failed to restore the settings of the notebook: %+v", err)
+		return nil
+	}
+	return settings
+}
+
+// handleNotebookSettingsAck handles the acknowledgement of the front-end to the settings sent by SaveSettings.
+// The value has the fields "saved" (a boolean) and, if not saved, "error".
+func (s *State) handleNotebookSettingsAck(_ kernel.Message, _ string, value any) {
+	ack := notebookAck{}
+	if fields, ok := value.(map[string]any); ok {
+		ack.saved, _ = fields["saved"].(bool)
+		ack.err, _ = fields["error"].(string)
+	}
+	s.nbSettings.mu.Lock()
+	defer s.nbSettings.mu.Unlock()
+	if s.nbSettings.ack != nil {
+		s.nbSettings.ack.Trigger(ack)
+	}
+}
+
+// SaveSettings implements `%settings save` (and `%settings clear`, if clear is set): it sends the settings
+// recorded in the session (or empty settings) to the front-end, to be stored in the notebook metadata, and
+// waits for its acknowledgement.
+func (s *State) SaveSettings(msg kernel.Message, clear bool) error {
+	var settings NotebookSettings
+	if !clear {
+		settings = s.NotebookSettings()
+		if settings.IsEmpty() {
+			return errors.New("%settings save: no `%config`, `%env` or `%goflags` set in this session, nothing to save")
+		}
+	}
+	if err := s.Comms.InstallWebSocket(msg); err != nil {
+		return errors.WithMessagef(err, "%%settings: failed to connect to the front-end")
+	}
+	latch := common.NewLatchWithValue[notebookAck]()
+	s.nbSettings.mu.Lock()
+	s.nbSettings.ack = latch
+	s.nbSettings.mu.Unlock()
+	defer func() {
+		s.nbSettings.mu.Lock()
+		if s.nbSettings.ack == latch {
+			s.nbSettings.ack = nil
+		}
+		s.nbSettings.mu.Unlock()
+	}()
+	go func() {
+		time.Sleep(NotebookSettingsAckTimeout)
+		latch.Trigger(notebookAck{err: "timed out waiting for the front-end"})
+	}()
+	if err := s.Comms.Send(msg, notebookSettingsAddress, settings); err != nil {
+		return errors.WithMessagef(err, "%%settings: failed to send the settings to the front-end")
+	}
+	ack := latch.Wait()
+	if !ack.saved {
+		return errors.Errorf("%%settings: the front-end couldn't store the settings in the notebook metadata: %s", ack.err)
+	}
+	if clear {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			"Settings cleared from the notebook metadata: save the notebook to persist it.\n")
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+		"Settings stored in the notebook metadata, restored when it is reopened (save the notebook to persist it):\n%s\n%s",
+		strings.Join(settings.Commands(), "\n"), s.skippedEnvNote()))
+}
+
+// ShowSettings implements `%settings`: it lists the settings recorded in the session, and the ones persisted
+// in the notebook file, if any.
+func (s *State) ShowSettings(msg kernel.Message) error {
+	var sb strings.Builder
+	settings := s.NotebookSettings()
+	if settings.IsEmpty() {
+		sb.WriteString("No `%config`, `%env` or `%goflags` set in this session.\n")
+	} else {
+		sb.WriteString("Settings set in this session (use `%settings save` to store them in the notebook):\n")
+		for _, command := range settings.Commands() {
+			sb.WriteString("  " + command + "\n")
+		}
+	}
+	sb.WriteString(s.skippedEnvNote())
+	if notebookPath := os.Getenv(JupyterSessionNameEnv); notebookPath != "" {
+		persisted, err := ReadNotebookSettings(notebookPath)
+		switch {
+		case err != nil:
+			fmt.Fprintf(&sb, "Failed to read the settings saved in the notebook: %v\n", err)
+		case persisted == nil:
+			sb.WriteString("No settings saved in the notebook file.\n")
+		default:
+			sb.WriteString("Settings saved in the notebook file:\n")
+			for _, command := range persisted.Commands() {
+				sb.WriteString("  " + command + "\n")
+			}
+		}
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
+}
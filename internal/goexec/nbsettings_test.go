@@ -0,0 +1,62 @@
+package goexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadNotebookSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeNotebook := func(name, contents string) string {
+		notebookPath := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(notebookPath, []byte(contents), 0600))
+		return notebookPath
+	}
+
+	settings, err := ReadNotebookSettings(writeNotebook("none.ipynb", `{"cells": [], "metadata": {"kernelspec": {}}}`))
+	require.NoError(t, err)
+	assert.Nil(t, settings)
+
+	settings, err = ReadNotebookSettings(writeNotebook("settings.ipynb", `{"cells": [], "metadata": {"gonb": {
+		"config": {"pager_lines": "100", "offline": "on"}, "env": {"CGO_ENABLED": "0"}, "goflags": ["-race", "-v"]}}}`))
+	require.NoError(t, err)
+	require.NotNil(t, settings)
+	assert.Equal(t, []string{"%config offline=on", "%config pager_lines=100", "%env CGO_ENABLED=0", "%goflags -race -v"},
+		settings.Commands())
+
+	_, err = ReadNotebookSettings(writeNotebook("invalid.ipynb", `{"metadata": {"gonb": {"config": 1}}}`))
+	assert.Error(t, err)
+
+	// Restored only once.
+	s := &State{}
+	t.Setenv(JupyterSessionNameEnv, filepath.Join(dir, "settings.ipynb"))
+	require.NotNil(t, s.SettingsToRestore())
+	assert.Nil(t, s.SettingsToRestore())
+}
+
+func TestRecordSettings(t *testing.T) {
+	s := &State{}
+	settings := s.NotebookSettings()
+	assert.True(t, settings.IsEmpty())
+	s.RecordConfigSetting("offline", "on")
+	s.RecordEnvSetting("GOEXPERIMENT", "rangefunc")
+	s.RecordGoFlagsSetting([]string{"-race"})
+	s.RecordConfigSetting("offline", "off")
+	settings = s.NotebookSettings()
+	assert.Equal(t, []string{"%config offline=off", "%env GOEXPERIMENT=rangefunc", "%goflags -race"}, settings.Commands())
+
+	// Secrets are not recorded.
+	assert.False(t, s.RecordEnvSetting("GITHUB_TOKEN", "ghp_123"))
+	assert.False(t, s.RecordEnvSetting("openai_api_key", "sk-123"))
+	assert.False(t, s.RecordEnvSetting("DB_PASSWORD", "hunter2"))
+	assert.True(t, s.RecordEnvSetting("GOPROXY", "direct"))
+	settings = s.NotebookSettings()
+	assert.Equal(t, []string{"GOEXPERIMENT", "GOPROXY"}, common.SortedKeys(settings.Env))
+	assert.Equal(t, "Not saved, since their names look like secrets: DB_PASSWORD, GITHUB_TOKEN, openai_api_key.\n",
+		s.skippedEnvNote())
+}
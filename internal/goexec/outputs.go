@@ -0,0 +1,68 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+)
+
+// This file keeps the textual outputs of the cells executed, so they can be accessed by the following
+// cells, with `gonbui.Out` and `gonbui.LastOutput`.
+
+const (
+	// CellOutputsDir is the directory, under State.TempDir, with the textual outputs of the previous
+	// cell executions. It is given to the cells in the environment variable protocol.GONB_OUTPUTS_DIR_ENV.
+	CellOutputsDir = "gonb_outputs"
+
+	// MaxCellOutputs is the number of most recent outputs kept.
+	MaxCellOutputs = 100
+
+	// BinaryOutputsDir is the directory, under State.TempDir, where binary data written to stdout by the
+	// programs of the cells and the shell commands is saved, see jpyexec.Executor.WithBinaryOutput.
+	BinaryOutputsDir = "gonb_binary_outputs"
+)
+
+// BinaryOutputsPath returns the path of BinaryOutputsDir, or "" if the detection of binary outputs is disabled
+// (see State.BinaryOutput).
+func (s *State) BinaryOutputsPath() string {
+	if !s.BinaryOutput {
+		return ""
+	}
+	return path.Join(s.TempDir, BinaryOutputsDir)
+}
+
+// initCellOutputs creates the directory of the cell outputs, and sets the environment variable
+// pointing to it.
+func (s *State) initCellOutputs() error {
+	dir := path.Join(s.TempDir, CellOutputsDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create directory for cell outputs %q", dir)
+	}
+	return errors.Wrapf(os.Setenv(protocol.GONB_OUTPUTS_DIR_ENV, dir),
+		"failed to set environment variable %q", protocol.GONB_OUTPUTS_DIR_ENV)
+}
+
+// SaveCellOutput saves the textual output of the cell execution with the given execution count, if not
+// empty, and removes the outputs older than the last MaxCellOutputs.
+func (s *State) SaveCellOutput(executionCount int, output string) error {
+	if output == "" {
+		return nil
+	}
+	dir := path.Join(s.TempDir, CellOutputsDir)
+	outputPath := path.Join(dir, fmt.Sprintf("%d.txt", executionCount))
+	if err := os.WriteFile(outputPath, []byte(output), 0600); err != nil {
+		return errors.Wrapf(err, "failed to save cell output to %q", outputPath)
+	}
+	s.cellOutputs = append(s.cellOutputs, executionCount)
+	for len(s.cellOutputs) > MaxCellOutputs {
+		oldPath := path.Join(dir, fmt.Sprintf("%d.txt", s.cellOutputs[0]))
+		s.cellOutputs = s.cellOutputs[1:]
+		if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove old cell output %q", oldPath)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package goexec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCellOutputs(t *testing.T) {
+	t.Setenv(protocol.GONB_OUTPUTS_DIR_ENV, "")
+	_, err := gonbui.LastOutput()
+	assert.Error(t, err)
+
+	s := &State{TempDir: t.TempDir()}
+	require.NoError(t, s.initCellOutputs())
+	_, err = gonbui.LastOutput()
+	assert.Error(t, err)
+
+	require.NoError(t, s.SaveCellOutput(1, "first\n"))
+	require.NoError(t, s.SaveCellOutput(2, "")) // Executions without output are not kept.
+	require.NoError(t, s.SaveCellOutput(10, "tenth\n"))
+	output, err := gonbui.Out(1)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", output)
+	_, err = gonbui.Out(2)
+	assert.Error(t, err)
+	output, err = gonbui.LastOutput()
+	require.NoError(t, err)
+	assert.Equal(t, "tenth\n", output)
+
+	// Only the last MaxCellOutputs are kept.
+	for ii := 11; ii < 11+MaxCellOutputs; ii++ {
+		require.NoError(t, s.SaveCellOutput(ii, fmt.Sprintf("%d\n", ii)))
+	}
+	_, err = gonbui.Out(10)
+	assert.Error(t, err)
+	output, err = gonbui.Out(11)
+	require.NoError(t, err)
+	assert.Equal(t, "11\n", output)
+	output, err = gonbui.LastOutput()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d\n", 10+MaxCellOutputs), output)
+}
@@ -0,0 +1,284 @@
+package goexec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the pagination of large outputs: after State.PagerLines lines, the stdout of the
+// program is no longer displayed as it's printed. The whole output is saved to a file in PagerDir (under
+// State.TempDir), and when the execution ends the last State.PagerLines lines are displayed in an HTML pager,
+// with a button to expand the hidden lines, read from the file through the comms with the front-end.
+
+const (
+	// PagerDir is the directory, under State.TempDir, with the outputs of the cells that were paginated.
+	PagerDir = "gonb_pager"
+
+	// DefaultPagerLines is the default value of State.PagerLines: 0, the pager is disabled by default, so the
+	// output is always displayed live.
+	DefaultPagerLines = 0
+
+	// PagerExpandLines is the number of hidden lines displayed by each click on the "expand" button of the pager.
+	PagerExpandLines = 200
+
+	// pagerAddressPrefix is the prefix of the comms address of the requests of hidden lines by the pagers,
+	// followed by `<State.UniqueID>/<execution count>`.
+	pagerAddressPrefix = "#pager/"
+
+	// maxPagerLineSize is the maximum size of a line read from the file of the output.
+	maxPagerLineSize = 16 * 1024 * 1024
+)
+
+// pagerWriter is an io.Writer that forwards the first headLines lines to out, and then keeps only the last
+// headLines lines, to display them when the execution ends (see State.finishPager). Everything is also
+// written to the file in path.
+type pagerWriter struct {
+	mu             sync.Mutex
+	out            io.Writer
+	file           *os.File
+	path           string
+	headLines      int
+	executionCount int
+
+	lines   int      // Complete lines forwarded, before paging.
+	paging  bool     // Whether headLines lines were reached.
+	partial []byte   // Incomplete last line, while paging.
+	tail    []string // Last headLines complete lines, while paging.
+	hidden  int      // Number of lines not displayed: neither forwarded nor in tail.
+}
+
+// newPagerWriter returns a pagerWriter of the output of the cell with the given execution count, or nil if
+// pagination is disabled (State.PagerLines is 0) or if the file of the output can't be created.
+func (s *State) newPagerWriter(out io.Writer, executionCount int) *pagerWriter {
+	if s.PagerLines <= 0 {
+		return nil
+	}
+	dir := filepath.Join(s.TempDir, PagerDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		klog.Warningf("Output pagination disabled: failed to create %q: %+v", dir, err)
+		return nil
+	}
+	outputPath := filepath.Join(dir, fmt.Sprintf("%d.txt", executionCount))
+	file, err := os.Create(outputPath)
+	if err != nil {
+		klog.Warningf("Output pagination disabled: failed to create %q: %+v", outputPath, err)
+		return nil
+	}
+	return &pagerWriter{out: out, file: file, path: outputPath, headLines: s.PagerLines, executionCount: executionCount}
+}
+
+// Write implements io.Writer.
+func (w *pagerWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(p)
+	if _, err := w.file.Write(p); err != nil {
+		klog.Warningf("Failed to save the output to %q: %+v", w.path, err)
+	}
+	if !w.paging {
+		idx := 0
+		for ; idx < len(p) && w.lines < w.headLines; idx++ {
+			if p[idx] == '\n' {
+				w.lines++
+			}
+		}
+		if _, err := w.out.Write(p[:idx]); err != nil {
+			return 0, err
+		}
+		if w.lines < w.headLines {
+			return n, nil
+		}
+		w.paging = true
+		notice := fmt.Sprintf("[... more than %d lines of output: the rest is displayed when the execution ends ...]\n",
+			w.headLines)
+		if _, err := w.out.Write([]byte(notice)); err != nil {
+			return 0, err
+		}
+		p = p[idx:]
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.tail = append(w.tail, string(w.partial[:idx]))
+		w.partial = w.partial[idx+1:]
+		if len(w.tail) > w.headLines {
+			w.tail = w.tail[1:]
+			w.hidden++
+		}
+	}
+	return n, nil
+}
+
+// pagerAddress returns the comms address of the requests of hidden lines of the pager of the cell.
+func (s *State) pagerAddress(executionCount int) string {
+	return fmt.Sprintf("%s%s/%d", pagerAddressPrefix, s.UniqueID, executionCount)
+}
+
+// finishPager displays the end of the output kept by the pagerWriter, when the execution of the cell ends:
+// as text, if no line was hidden, or as an HTML pager otherwise.
+func (s *State) finishPager(msg kernel.Message, w *pagerWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Close(); err != nil {
+		klog.Warningf("Failed to save the output to %q: %+v", w.path, err)
+	}
+	if !w.paging {
+		_ = os.Remove(w.path)
+		return
+	}
+	rest := strings.Join(w.tail, "\n")
+	if len(w.tail) > 0 {
+		rest += "\n"
+	}
+	rest += string(w.partial)
+	if w.hidden == 0 {
+		_, _ = w.out.Write([]byte(rest))
+		return
+	}
+
+	executionCount := w.executionCount
+	from := w.headLines // Index of the first hidden line.
+	htmlId := fmt.Sprintf("gonb-pager-%s-%d", s.UniqueID, executionCount)
+	jsValue := func(v any) string {
+		encoded, _ := json.Marshal(v) // Escapes "<", ">" and "&", so it's safe within <script>.
+		return string(encoded)
+	}
+	pager := fmt.Sprintf(`<div id="%s" style="font-family: monospace;">
+<pre class="gonb-pager-expanded" style="margin: 0;"></pre>
+<div style="margin: 0.2em 0; color: var(--jp-content-font-color2);"><span class="gonb-pager-status">&#8943; %d lines hidden &#8943;</span>
+<button style="cursor: pointer;">expand</button>
+<span style="font-size: 80%%;">(full output in <code>%s</code>)</span></div>
+<pre style="margin: 0;">%s</pre>
+</div>
+<script>
+(() => {
+	const root = document.getElementById(%s);
+	if (!root) {
+		return;
+	}
+	const status = root.querySelector(".gonb-pager-status");
+	const expanded = root.querySelector(".gonb-pager-expanded");
+	const button = root.querySelector("button");
+	const address = %s, outputPath = %s, end = %d;
+	let next = %d, subscription = null;
+	button.addEventListener("click", () => {
+		const comm = globalThis?.gonb_comm;
+		if (!comm) {
+			status.textContent = "connection to GoNB not available, see the full output in " + outputPath;
+			return;
+		}
+		if (subscription === null) {
+			subscription = comm.subscribe(address + "/lines", (address, value) => {
+				if (value.from !== next) {
+					return;
+				}
+				expanded.textContent += value.lines.map((line) => line + "\n").join("");
+				next += value.lines.length;
+				if (next >= end || value.lines.length === 0) {
+					status.parentElement.remove();
+					comm.unsubscribe(subscription);
+				} else {
+					status.textContent = "⋯ " + (end - next) + " lines hidden ⋯";
+				}
+			});
+		}
+		comm.send(address, {from: next, count: Math.min(%d, end - next)});
+	});
+})();
+</script>`, htmlId, w.hidden, html.EscapeString(w.path), html.EscapeString(rest),
+		jsValue(htmlId), jsValue(s.pagerAddress(executionCount)), jsValue(w.path), from+w.hidden, from, PagerExpandLines)
+	if err := kernel.PublishHtml(msg, pager); err != nil {
+		klog.Warningf("Failed to publish the pager of the output: %+v", err)
+		return
+	}
+	if s.Comms != nil && msg.Kernel().JupyterKernelId != "" {
+		// The "expand" button requests the hidden lines through the comms with the front-end.
+		if err := s.Comms.InstallWebSocket(msg); err != nil {
+			klog.Warningf("The \"expand\" button of the pager won't work: %+v", err)
+		}
+	}
+}
+
+// readPagerLines returns up to count lines of the file, starting from the line from (starting at 0).
+func readPagerLines(filePath string, from, count int) ([]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the output in %q", filePath)
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPagerLineSize)
+	lines := make([]string, 0, max(count, 0))
+	for lineNum := 0; len(lines) < count && scanner.Scan(); lineNum++ {
+		if lineNum >= from {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the output in %q", filePath)
+	}
+	return lines, nil
+}
+
+// parsePagerRequest validates the value of a request of hidden lines, and returns its fields "from" (the
+// index of the first line, non-negative) and "count" (clamped to PagerExpandLines).
+func parsePagerRequest(value any) (from, count int, err error) {
+	request, ok := value.(map[string]any)
+	if !ok {
+		return 0, 0, errors.Errorf("invalid pager request %v", value)
+	}
+	fromValue, fromOk := request["from"].(float64)
+	countValue, countOk := request["count"].(float64)
+	if !fromOk || !countOk || fromValue < 0 || countValue < 0 ||
+		fromValue != float64(int(fromValue)) || countValue != float64(int(countValue)) {
+		return 0, 0, errors.Errorf("invalid pager request %v: \"from\" and \"count\" must be non-negative integers", value)
+	}
+	return int(fromValue), min(int(countValue), PagerExpandLines), nil
+}
+
+// handlePagerRequest replies the requests of hidden lines by the pagers (see State.finishPager), registered
+// with comms.State.HandleAddressPrefix. The value of the request has the fields "from" (the index of the
+// first line) and "count".
+func (s *State) handlePagerRequest(msg kernel.Message, address string, value any) {
+	parts := strings.Split(strings.TrimPrefix(address, pagerAddressPrefix), "/")
+	if len(parts) != 2 || parts[0] != s.UniqueID {
+		klog.V(1).Infof("Pager request to %q ignored: not from this kernel session", address)
+		return
+	}
+	executionCount, err := strconv.Atoi(parts[1])
+	if err != nil {
+		klog.Warningf("Invalid pager request to %q: %v", address, value)
+		return
+	}
+	from, count, err := parsePagerRequest(value)
+	if err != nil {
+		klog.Warningf("Pager request to %q ignored: %v", address, err)
+		return
+	}
+	outputPath := filepath.Join(s.TempDir, PagerDir, fmt.Sprintf("%d.txt", executionCount))
+	lines, err := readPagerLines(outputPath, from, count)
+	if err != nil {
+		klog.Warningf("Pager request to %q failed: %+v", address, err)
+		return
+	}
+	err = s.Comms.Send(msg, address+"/lines", map[string]any{"from": from, "lines": lines})
+	if err != nil {
+		klog.Warningf("Failed to reply pager request to %q: %+v", address, err)
+	}
+}
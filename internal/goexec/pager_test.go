@@ -0,0 +1,95 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPager(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	s.PagerLines = 3
+	var lines []string
+	for ii := 0; ii < 10; ii++ {
+		lines = append(lines, fmt.Sprintf("line %d", ii))
+	}
+	output := strings.Join(lines, "\n") // Last line is incomplete.
+
+	// Output with lines hidden.
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	var displayed bytes.Buffer
+	pager := s.newPagerWriter(&displayed, 1)
+	require.NotNil(t, pager)
+	for _, part := range []string{output[:5], output[5:30], output[30:]} {
+		n, err := pager.Write([]byte(part))
+		require.NoError(t, err)
+		require.Equal(t, len(part), n)
+	}
+	s.finishPager(msg, pager)
+	assert.Equal(t, "line 0\nline 1\nline 2\n"+
+		"[... more than 3 lines of output: the rest is displayed when the execution ends ...]\n", displayed.String())
+	require.Len(t, msg.Outputs(), 1)
+	pagerHTML := fmt.Sprint(msg.Outputs()[0]["data"])
+	assert.Contains(t, pagerHTML, "&#8943; 3 lines hidden &#8943;")
+	assert.Contains(t, pagerHTML, "<pre style=\"margin: 0;\">line 6\nline 7\nline 8\nline 9</pre>")
+	assert.Contains(t, pagerHTML, `const address = "#pager/`+s.UniqueID+`/1"`)
+	contents, err := os.ReadFile(pager.path)
+	require.NoError(t, err)
+	assert.Equal(t, output, string(contents))
+	hidden, err := readPagerLines(pager.path, 3, PagerExpandLines)
+	require.NoError(t, err)
+	assert.Equal(t, lines[3:], hidden)
+	hidden, err = readPagerLines(pager.path, 3, 4)
+	require.NoError(t, err)
+	assert.Equal(t, lines[3:7], hidden)
+
+	// Output that fits: no line is hidden.
+	msg, err = kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	displayed.Reset()
+	pager = s.newPagerWriter(&displayed, 2)
+	_, _ = pager.Write([]byte(strings.Join(lines[:5], "\n") + "\n"))
+	s.finishPager(msg, pager)
+	assert.Empty(t, msg.Outputs())
+	assert.Equal(t, "line 0\nline 1\nline 2\n"+
+		"[... more than 3 lines of output: the rest is displayed when the execution ends ...]\nline 3\nline 4\n",
+		displayed.String())
+
+	// Short output: the file is removed.
+	displayed.Reset()
+	pager = s.newPagerWriter(&displayed, 3)
+	_, _ = pager.Write([]byte("hello\n"))
+	s.finishPager(msg, pager)
+	assert.Equal(t, "hello\n", displayed.String())
+	assert.NoFileExists(t, pager.path)
+
+	s.PagerLines = 0
+	assert.Nil(t, s.newPagerWriter(&displayed, 4))
+}
+
+func TestParsePagerRequest(t *testing.T) {
+	from, count, err := parsePagerRequest(map[string]any{"from": 500.0, "count": 1000.0})
+	require.NoError(t, err)
+	assert.Equal(t, 500, from)
+	assert.Equal(t, PagerExpandLines, count)
+
+	for _, value := range []any{
+		"not a map",
+		map[string]any{"from": 3.0},
+		map[string]any{"from": -1.0, "count": 10.0},
+		map[string]any{"from": 3.0, "count": -10.0},
+		map[string]any{"from": 3.5, "count": 10.0},
+		map[string]any{"from": "3", "count": 10.0},
+	} {
+		_, _, err = parsePagerRequest(value)
+		assert.Error(t, err, "request %v", value)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"io"
 	"io/fs"
@@ -32,6 +33,9 @@ type parseInfo struct {
 	// cell. This is used when reporting back errors with a file number. Values of -1 (NoCursorLine) are injected Lines
 	// that have no correspondent value in the cell code.
 	fileToCellIdAndLine []CellIdAndLine
+
+	// importConflict is set if two imports of the cell have the same name. See ParseImportEntry.
+	importConflict *importConflict
 }
 
 // getCursor returns the cursor position within this declaration, if the original cursor falls in there.
@@ -138,13 +142,29 @@ func (s *State) parseFromGoCode(msg kernel.Message,
 		keep := name == "main.go" || name == "main_test.go"
 		klog.V(2).Infof("parser.ParseDir().filter(%q) -> keep=%v", name, keep)
 		return keep
-	}, parser.SkipObjectResolution) // |parser.AllErrors
+	}, parser.SkipObjectResolution|parser.ParseComments) // |parser.AllErrors
+	var partialErr *PartialParseError
 	if err != nil {
+		// When executing, the declarations that parsed correctly are recovered.
+		skipped := 0
+		packages = nil
+		if msg != nil && !cursor.HasCursor() {
+			var declErrs scanner.ErrorList
+			packages, skipped, declErrs = s.parsePartialPackages(pi.fileSet)
+			if len(packages) > 0 && len(declErrs) > 0 {
+				// Report only the errors of the broken declarations.
+				err = declErrs
+			}
+		}
 		if msg != nil {
 			err = s.DisplayErrorWithContext(msg, fileToCellIdAndLine, err.Error(), err)
 		}
 		err = errors.Wrapf(err, "parsing go files in TempDir %q", s.TempDir)
-		return
+		if len(packages) == 0 {
+			return
+		}
+		partialErr = &PartialParseError{err: err, Skipped: skipped}
+		err = nil
 	}
 
 	pi.filesContents = make(map[string]string)
@@ -167,6 +187,9 @@ func (s *State) parseFromGoCode(msg kernel.Message,
 			for _, entry := range fileObj.Imports {
 				pi.ParseImportEntry(decls, entry)
 			}
+			if pi.importConflict != nil && !cursor.HasCursor() {
+				return nil, errors.WithStack(*pi.importConflict)
+			}
 
 			// Enumerate various declarations.
 			for _, decl := range fileObj.Decls {
@@ -177,7 +200,8 @@ func (s *State) parseFromGoCode(msg kernel.Message,
 				case *ast.GenDecl:
 					klog.V(2).Infof("> Declaration %T: %s", typedDecl, typedDecl.Tok)
 					if typedDecl.Tok == token.IMPORT {
-						// Imports are handled above.
+						// Imports are handled above, except the cgo preamble.
+						pi.ParseCgoPreamble(decls, typedDecl)
 						continue
 					} else if typedDecl.Tok == token.VAR {
 						pi.ParseVarEntry(decls, typedDecl)
@@ -192,11 +216,53 @@ func (s *State) parseFromGoCode(msg kernel.Message,
 					klog.Warningf("Dropped unknown declaration type\n")
 				}
 			}
+
+			// Top-level `//go:generate` directives.
+			pi.ParseGenerateDirectives(decls, fileObj)
 		}
 	}
+	if partialErr != nil {
+		err = partialErr
+	}
 	return
 }
 
+// GenerateDirectivePrefix is the prefix of the comments interpreted by `go generate`.
+const GenerateDirectivePrefix = "//go:generate "
+
+// ParseGenerateDirectives registers the `//go:generate` directives found in comments outside of
+// declarations. Directives inside declarations (e.g.: in a function body) are kept as part of the declaration.
+// See State.parseFromGoCode.
+func (pi *parseInfo) ParseGenerateDirectives(decls *Declarations, fileObj *ast.File) {
+	for _, group := range fileObj.Comments {
+		insideDecl := false
+		for _, decl := range fileObj.Decls {
+			if group.Pos() >= decl.Pos() && group.End() <= decl.End() {
+				insideDecl = true
+				break
+			}
+		}
+		if insideDecl {
+			continue
+		}
+		for _, comment := range group.List {
+			if !strings.HasPrefix(comment.Text, GenerateDirectivePrefix) {
+				continue
+			}
+			directive := &GenerateDirective{
+				Key:       strings.TrimSpace(strings.TrimPrefix(comment.Text, GenerateDirectivePrefix)),
+				CellLines: pi.calculateCellLines(comment),
+			}
+			if c := pi.getCursor(comment); c.HasCursor() {
+				directive.Cursor = c
+			} else {
+				directive.Cursor = NoCursor
+			}
+			decls.GenerateDirectives[directive.Key] = directive
+		}
+	}
+}
+
 // NewImport from the importPath and it's alias. If alias is empty or "<nil>", it will default to the
 // last name part of the importPath.
 func NewImport(importPath, alias string) *Import {
@@ -208,9 +274,9 @@ func NewImport(importPath, alias string) *Import {
 		} else {
 			key = parts[1]
 		}
-	} else if key == "." {
-		// More than one import can be moved to the current namespace.
-		key = ".~" + importPath
+	} else if key == "." || key == "_" {
+		// More than one import can be moved to the current namespace, or imported for side effects only.
+		key = key + "~" + importPath
 	}
 	return &Import{Key: key, Path: importPath, Alias: alias}
 }
@@ -236,9 +302,35 @@ func (pi *parseInfo) ParseImportEntry(decls *Declarations, entry *ast.ImportSpec
 			importEntry.Cursor = c
 		}
 	}
+	if other, found := decls.Imports[importEntry.Key]; found && other.Path != importEntry.Path &&
+		isNamedImport(importEntry.Key) && pi.importConflict == nil {
+		pi.importConflict = &importConflict{Name: importEntry.Key, Path: importEntry.Path, OtherPath: other.Path}
+	}
 	decls.Imports[importEntry.Key] = importEntry
 }
 
+// ParseCgoPreamble registers the cgo preamble of an `import "C"` declaration: the comment immediately
+// preceding it. See State.parseFromGoCode.
+func (pi *parseInfo) ParseCgoPreamble(decls *Declarations, genDecl *ast.GenDecl) {
+	for _, spec := range genDecl.Specs {
+		importSpec, ok := spec.(*ast.ImportSpec)
+		if !ok || importSpec.Path.Value != `"C"` {
+			continue
+		}
+		// Same rule as cgo: the comment of the spec, or of the declaration, if it has only one spec.
+		doc := importSpec.Doc
+		if doc == nil && len(genDecl.Specs) == 1 {
+			doc = genDecl.Doc
+		}
+		importEntry, found := decls.Imports["C"]
+		if doc == nil || !found {
+			return
+		}
+		importEntry.CgoPreambles = []CgoPreamble{{Text: pi.extractContentOfNode(doc), CellLines: pi.calculateCellLines(doc)}}
+		return
+	}
+}
+
 // ParseFuncEntry registers a new `func` declaration based on the ast.FuncDecl. See State.parseFromGoCode
 func (pi *parseInfo) ParseFuncEntry(decls *Declarations, funcDecl *ast.FuncDecl) {
 	// Incorporate functions.
@@ -253,12 +345,21 @@ func (pi *parseInfo) ParseFuncEntry(decls *Declarations, funcDecl *ast.FuncDecl)
 		}
 		key = fmt.Sprintf("%s~%s", typeName, key)
 	}
-	f := &Function{Key: key, Definition: pi.extractContentOfNode(funcDecl)}
+	f := &Function{Key: key, Definition: pi.extractContentOfNode(funcDecl), Doc: funcDecl.Doc.Text()}
 	f.CellLines = pi.calculateCellLines(funcDecl)
 	f.Cursor = pi.getCursor(funcDecl)
 	decls.Functions[f.Key] = f
 }
 
+// specDoc returns the text of the doc comment of a spec of the declaration: its own, or the one of the
+// declaration, if it has only one spec (e.g.: `// Doc.\nvar x = 1`).
+func specDoc(genDecl *ast.GenDecl, doc *ast.CommentGroup) string {
+	if doc == nil && len(genDecl.Specs) == 1 {
+		doc = genDecl.Doc
+	}
+	return doc.Text()
+}
+
 // ParseVarEntry registers a new `var` declaration based on the ast.GenDecl. See State.parseFromGoCode
 func (pi *parseInfo) ParseVarEntry(decls *Declarations, genDecl *ast.GenDecl) {
 	// Multiple declarations in the same line may share the cursor (e.g: `var a, b int` if the cursor
@@ -275,7 +376,7 @@ func (pi *parseInfo) ParseVarEntry(decls *Declarations, genDecl *ast.GenDecl) {
 		}
 		// Each spec may be a list of variables (comma separated).
 		for nameIdx, name := range vSpec.Names {
-			v := &Variable{Name: name.Name, TypeDefinition: typeDefinition}
+			v := &Variable{Name: name.Name, TypeDefinition: typeDefinition, Doc: specDoc(genDecl, vSpec.Doc)}
 			if !cursorFound {
 				if c := pi.getCursor(name); c.HasCursor() {
 					v.CursorInName = true
@@ -327,7 +428,7 @@ func (pi *parseInfo) ParseConstEntry(decls *Declarations, typedDecl *ast.GenDecl
 		}
 		// Each spec may be a list of variables (comma separated).
 		for nameIdx, name := range vSpec.Names {
-			c := &Constant{Cursor: NoCursor, Key: name.Name, TypeDefinition: typeDefinition}
+			c := &Constant{Cursor: NoCursor, Key: name.Name, TypeDefinition: typeDefinition, Doc: specDoc(typedDecl, vSpec.Doc)}
 			c.Prev = prevConstDecl
 			if c.Prev != nil {
 				c.Prev.Next = c
@@ -368,7 +469,7 @@ func (pi *parseInfo) ParseTypeEntry(decls *Declarations, typedDecl *ast.GenDecl)
 		tSpec := spec.(*ast.TypeSpec)
 		name := tSpec.Name.Name
 		tDef := pi.extractContentOfNode(tSpec)
-		tDecl := &TypeDecl{Key: name, TypeDefinition: tDef}
+		tDecl := &TypeDecl{Key: name, TypeDefinition: tDef, Doc: specDoc(typedDecl, tSpec.Doc)}
 		if c := pi.getCursor(tSpec); c.HasCursor() {
 			tDecl.Cursor = c
 			tDecl.CursorInType = true
@@ -400,6 +501,12 @@ func (s *State) parseLinesAndComposeMain(
 	updatedDecls *Declarations, mainDecl *Function, cursorInFile Cursor, fileToCellIdAndLine []CellIdAndLine, err error) {
 	cursorInFile = NoCursor
 
+	// Parameters declared with `// gonb:param` are composed as variables.
+	lines, err = s.expandParamDeclarations(lines, skipLines, cursorInCell.Line)
+	if err != nil {
+		return
+	}
+
 	var fileToCellLine []int
 	if err = s.RemoveCode(); err != nil {
 		return
@@ -420,6 +527,11 @@ func (s *State) parseLinesAndComposeMain(
 		s.SetCellTests(newDecls)
 	}
 
+	// Declarations that parsed correctly are composed, and the partial parse error returned at the end.
+	var partialErr error
+	if isPartialParse(err) {
+		partialErr, err = err, nil
+	}
 	if err != nil {
 		return
 	}
@@ -430,6 +542,10 @@ func (s *State) parseLinesAndComposeMain(
 		// Remove "main" from newDecls: this should not be stored from one cell execution from
 		// another.
 		delete(newDecls.Functions, "main")
+		if s.AllowUnused && !cursorInCell.HasCursor() && strings.HasPrefix(mainDecl.Definition, s.mainPreamble()) {
+			// Only the `func main()` created for the cell (starting with the preamble), not one written by the user.
+			mainDecl.Definition = allowUnusedLocals(mainDecl.Definition)
+		}
 	} else {
 		// Declare a stub main function, just so we can try to compile the final code.
 		mainDecl = &Function{
@@ -442,11 +558,27 @@ func (s *State) parseLinesAndComposeMain(
 		}
 	}
 
+	// Imports with the same name of memorized imports of other packages, still in use, can't be merged.
+	if !cursorInCell.HasCursor() {
+		if err = s.checkImportConflicts(newDecls); err != nil {
+			return
+		}
+	}
+
 	// Merge cell declarations with a copy of the current state: we don't want to commit the new
 	// declarations until they compile successfully.
 	updatedDecls = s.Definitions.Copy()
 	updatedDecls.ClearCursor()
 	updatedDecls.MergeFrom(newDecls)
+	s.declareInputVariables(newDecls, updatedDecls, !cursorInCell.HasCursor())
+	s.addNotebookImports(updatedDecls)
+	s.addGoPackageImports(updatedDecls)
+	s.addPreludeImports(updatedDecls)
+	if s.CellRunCLI && !hasMain {
+		if mainDecl, err = runCLIMain(updatedDecls); err != nil {
+			return
+		}
+	}
 	if s.CellIsWasm {
 		s.ExportWasmConstants(updatedDecls)
 	}
@@ -465,6 +597,7 @@ func (s *State) parseLinesAndComposeMain(
 	if cursorInCell.HasCursor() && klog.V(1).Enabled() {
 		s.logCursor(cursorInFile)
 	}
+	err = partialErr
 	return
 }
 
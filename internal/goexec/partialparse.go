@@ -0,0 +1,122 @@
+package goexec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the recovery from parse errors in part of a cell: the declarations of the cell that
+// parsed correctly are still memorized (if they compile), and only the broken ones are reported, instead of
+// a single typo invalidating all the cell's contributions. The cell is not executed.
+
+// PartialParseError is returned when some declarations of a cell failed to parse. The declarations parsed
+// correctly are still returned.
+type PartialParseError struct {
+	err error
+
+	// Skipped is the number of declarations dropped because they had errors.
+	Skipped int
+}
+
+// Error implements the error interface.
+func (e *PartialParseError) Error() string { return e.err.Error() }
+
+// Unwrap returns the parse error.
+func (e *PartialParseError) Unwrap() error { return e.err }
+
+// isPartialParse returns whether the error is a PartialParseError.
+func isPartialParse(err error) bool {
+	var partialErr *PartialParseError
+	return errors.As(err, &partialErr)
+}
+
+// reDeclStart matches the lines that start a top-level declaration, or its documentation comment.
+var reDeclStart = regexp.MustCompile(`^(func|type|var|const|import)\b|^//`)
+
+// declChunks splits the code in chunks of lines, each holding one top-level declaration (with its
+// documentation comment), and returns the offsets where each chunk starts. The first chunk, with the
+// `package` clause, is the header.
+func declChunks(code string) (starts []int) {
+	starts = []int{0}
+	offset := 0
+	previousIsComment := false
+	for _, line := range strings.SplitAfter(code, "\n") {
+		isComment := strings.HasPrefix(line, "//")
+		if offset > 0 && !previousIsComment && reDeclStart.MatchString(line) {
+			starts = append(starts, offset)
+		}
+		previousIsComment = isComment
+		offset += len(line)
+	}
+	return
+}
+
+// maskCode returns the code with everything outside [from, to) blanked, except for the newlines (so positions
+// are preserved) and the header up to headerEnd.
+func maskCode(code string, headerEnd, from, to int) []byte {
+	masked := []byte(code)
+	for ii := headerEnd; ii < len(masked); ii++ {
+		if (ii < from || ii >= to) && masked[ii] != '\n' {
+			masked[ii] = ' '
+		}
+	}
+	return masked
+}
+
+// parsePartialPackages parses again, one declaration at a time, the files of the cell that failed to parse,
+// keeping the declarations that parse correctly -- the parser recovers poorly from errors, often swallowing
+// the declarations that follow the broken one. It returns the packages with the valid declarations, and the
+// number of declarations skipped because of errors, with their errors. The positions in the returned syntax trees are those of
+// the original files.
+func (s *State) parsePartialPackages(fileSet *token.FileSet) (packages map[string]*ast.Package, skipped int,
+	declErrs scanner.ErrorList) {
+	for _, name := range []string{"main.go", "main_test.go"} {
+		filePath := filepath.Join(s.TempDir, name)
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			continue
+		}
+		code := string(content)
+		starts := append(declChunks(code), len(code))
+		var fileObj *ast.File
+		for ii := 1; ii < len(starts)-1; ii++ {
+			chunkObj, err := parser.ParseFile(fileSet, filePath, maskCode(code, starts[1], starts[ii], starts[ii+1]),
+				parser.SkipObjectResolution|parser.ParseComments)
+			if err != nil || chunkObj.Name == nil {
+				skipped++
+				// Only the first error of each declaration is reported, the others are usually consequences of it.
+				var errList scanner.ErrorList
+				if errors.As(err, &errList) && len(errList) > 0 {
+					declErrs = append(declErrs, errList[0])
+				}
+				continue
+			}
+			if fileObj == nil {
+				fileObj = &ast.File{Name: chunkObj.Name, Package: chunkObj.Package}
+			}
+			fileObj.Decls = append(fileObj.Decls, chunkObj.Decls...)
+			fileObj.Imports = append(fileObj.Imports, chunkObj.Imports...)
+			fileObj.Comments = append(fileObj.Comments, chunkObj.Comments...)
+		}
+		if fileObj == nil {
+			continue
+		}
+		if packages == nil {
+			packages = make(map[string]*ast.Package)
+		}
+		pkgName := fileObj.Name.Name
+		if packages[pkgName] == nil {
+			packages[pkgName] = &ast.Package{Name: pkgName, Files: make(map[string]*ast.File)}
+		}
+		packages[pkgName].Files[filePath] = fileObj
+	}
+	return
+}
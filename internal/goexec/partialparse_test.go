@@ -0,0 +1,71 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialParse(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+
+	cellCode := `// Good is fine.
+func Good() int { return 1 }
+
+func Broken() int {
+	return 1 +
+}
+
+const Answer = 42
+
+type Point struct{ X, Y float64 }
+`
+	lines := strings.Split(cellCode, "\n")
+	_, fileToCellLine, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	fileToCellIdAndLine := MakeFileToCellIdAndLine(1, fileToCellLine)
+
+	// Without a message (e.g.: exporting), or with a cursor (e.g.: completion), parse errors are fatal.
+	_, err = s.parseFromGoCode(nil, 1, NoCursor, fileToCellIdAndLine)
+	require.Error(t, err)
+	assert.False(t, isPartialParse(err))
+
+	decls, err := s.parseFromGoCode(msg, 1, NoCursor, fileToCellIdAndLine)
+	require.Error(t, err)
+	require.True(t, isPartialParse(err))
+	assert.Equal(t, 1, err.(*PartialParseError).Skipped)
+	assert.Contains(t, err.Error(), "expected operand")
+	assert.NotContains(t, err.Error(), "more errors")
+	assert.Contains(t, decls.Functions, "Good")
+	assert.NotContains(t, decls.Functions, "Broken")
+	assert.Contains(t, decls.Constants, "Answer")
+	assert.Contains(t, decls.Types, "Point")
+
+	// The error was reported.
+	require.NotEmpty(t, msg.Outputs())
+}
+
+func TestDeclChunks(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\n// A is documented.\n// Over two lines.\nfunc A() {\n\tfmt.Println()\n}\nvar (\n\tx = 1\n)\n"
+	starts := declChunks(code)
+	require.Len(t, starts, 4)
+	assert.True(t, strings.HasPrefix(code[starts[1]:], "import"))
+	assert.True(t, strings.HasPrefix(code[starts[2]:], "// A is documented."))
+	assert.True(t, strings.HasPrefix(code[starts[3]:], "var ("))
+
+	masked := string(maskCode(code, starts[1], starts[3], len(code)))
+	assert.Len(t, masked, len(code))
+	assert.Equal(t, strings.Count(code, "\n"), strings.Count(masked, "\n"))
+	assert.NotContains(t, masked, "func A")
+	assert.Contains(t, masked, "package main")
+}
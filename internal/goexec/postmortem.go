@@ -0,0 +1,70 @@
+package goexec
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/jpyexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the post-mortem analysis of the core dumps of crashing cells with delve (`dlv`). It's
+// connected to the special command `%postmortem`. The capture of the core dumps, which depends on the
+// platform, is implemented in postmortem_linux.go (and postmortem_others.go for the unsupported platforms).
+
+// PostMortemSubdir is the subdirectory of State.TempDir where the binary and the core dump
+// of the last crashed cell are kept.
+const PostMortemSubdir = "postmortem"
+
+// DefaultPostMortemCommands are the delve commands executed by `%postmortem`, if none are given.
+var DefaultPostMortemCommands = []string{"goroutines -t", "bt -full"}
+
+// PostMortemEnabled returns whether core dumps of crashing cells are captured. This is the case when the
+// environment variable GOTRACEBACK is set to "crash" (e.g.: with `%env GOTRACEBACK=crash`). The capture of
+// core dumps is only supported on Linux, see postMortemSupported.
+func PostMortemEnabled() bool {
+	return postMortemSupported && os.Getenv("GOTRACEBACK") == "crash"
+}
+
+// errPostMortemUnsupported is returned by `%postmortem` on platforms where core dumps are not captured.
+var errPostMortemUnsupported = errors.Errorf("%%postmortem is not supported on %s: core dumps of crashing cells "+
+	"are only captured on Linux", runtime.GOOS)
+
+// PostMortem opens the core dumped by the last crashed cell with delve (`dlv core`), and executes the
+// given delve commands -- DefaultPostMortemCommands if none are given --, printing the results in the notebook.
+func (s *State) PostMortem(msg kernel.Message, commands []string) error {
+	if !postMortemSupported {
+		return errPostMortemUnsupported
+	}
+	if s.postMortemCore == "" {
+		return errors.New("no core dump captured: set `%env GOTRACEBACK=crash` before executing the crashing cell")
+	}
+	dlvPath, err := exec.LookPath("dlv")
+	if err != nil {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, `
+Program dlv (delve) is not installed. It is used to inspect the core dump. You
+can install it from the notebook with:
+
+!go install github.com/go-delve/delve/cmd/dlv@latest
+
+`)
+		return errors.WithMessagef(err, "while trying to run dlv")
+	}
+	if len(commands) == 0 {
+		commands = DefaultPostMortemCommands
+	}
+	initPath := path.Join(s.TempDir, PostMortemSubdir, "init.dlv")
+	err = os.WriteFile(initPath, []byte(strings.Join(append(commands, "exit"), "\n")+"\n"), 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write delve commands to %q", initPath)
+	}
+	return jpyexec.New(msg, dlvPath, "core", s.postMortemBinary, s.postMortemCore,
+		"--allow-non-terminal-interactive=true", "--init", initPath).
+		ExecutionCount(msg.Kernel().ExecCounter).
+		InDir(s.TempDir).
+		Exec()
+}
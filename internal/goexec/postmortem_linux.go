@@ -0,0 +1,130 @@
+//go:build linux
+
+package goexec
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the capture of core dumps of crashing cells on Linux, see postmortem.go.
+
+// postMortemSupported indicates whether the core dumps of crashing cells can be captured on this platform.
+const postMortemSupported = true
+
+// corePatternPath is the Linux configuration of where core dumps are written.
+const corePatternPath = "/proc/sys/kernel/core_pattern"
+
+// postMortemCommand wraps the execution of the binary with a shell that raises the limit of the
+// core dump size, so that the core is dumped if the program crashes.
+func postMortemCommand(binary string, args []string) (string, []string) {
+	return "/bin/sh", append([]string{"-c", `ulimit -c unlimited 2>/dev/null; exec "$0" "$@"`, binary}, args...)
+}
+
+// capturePostMortem checks whether the executed cell dumped core, and if so, it moves the core dump
+// and a copy of the binary to PostMortemSubdir, and informs the user that `%postmortem` is available.
+//
+// Errors are reported to the notebook, but not returned, since the cell execution itself has finished.
+func (s *State) capturePostMortem(msg kernel.Message, processState *os.ProcessState, startTime time.Time) {
+	if processState == nil {
+		return
+	}
+	status, ok := processState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return
+	}
+	if !status.CoreDump() {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			"* Cell crashed but no core was dumped, check the core size limits (`ulimit -c`) of the kernel.\n")
+		return
+	}
+	corePath, err := findCoreDump(startTime)
+	if err != nil {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("* Cell dumped core, but it could not be captured: %v\n", err))
+		return
+	}
+
+	dir := path.Join(s.TempDir, PostMortemSubdir)
+	if err = os.RemoveAll(dir); err == nil {
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err != nil {
+		klog.Errorf("Failed to create post-mortem directory %q: %+v", dir, err)
+		return
+	}
+	s.postMortemCore = path.Join(dir, "core")
+	s.postMortemBinary = path.Join(dir, s.Package)
+	err = os.Rename(corePath, s.postMortemCore)
+	if err == nil {
+		err = os.Rename(s.BinaryPath(), s.postMortemBinary)
+	}
+	if err != nil {
+		s.postMortemCore, s.postMortemBinary = "", ""
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("* Cell dumped core, but it could not be captured: %v\n", err))
+		return
+	}
+	_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+		"* Cell crashed and its core was captured: use `%postmortem` to inspect it with delve.\n")
+}
+
+// findCoreDump returns the path to the core dumped after startTime, according to the core pattern
+// configured in the system.
+func findCoreDump(startTime time.Time) (string, error) {
+	pattern := "core"
+	if contents, err := os.ReadFile(corePatternPath); err == nil {
+		pattern = strings.TrimSpace(string(contents))
+	}
+	if strings.HasPrefix(pattern, "|") {
+		return "", errors.Errorf("core dumps are piped to a program (see %s: %q), "+
+			"use it to retrieve the core (e.g.: `coredumpctl`)", corePatternPath, pattern)
+	}
+	dir := filepath.Dir(pattern)
+	if !filepath.IsAbs(pattern) {
+		// Relative to the current directory of the crashed program, which is the same as the kernel's.
+		pwd, err := os.Getwd()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get current directory")
+		}
+		dir = filepath.Join(pwd, dir)
+	}
+	prefix := filepath.Base(pattern)
+	if idx := strings.Index(prefix, "%"); idx >= 0 {
+		prefix = prefix[:idx]
+	}
+
+	var newest string
+	var newestTime time.Time
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read directory %q where core is dumped", dir)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		var info fs.FileInfo
+		info, err = entry.Info()
+		if err != nil || info.ModTime().Before(startTime) {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = filepath.Join(dir, entry.Name()), info.ModTime()
+		}
+	}
+	if newest == "" {
+		return "", errors.Errorf("no core file matching %q found in %q", prefix+"*", dir)
+	}
+	return newest, nil
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package goexec
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostMortemCommand(t *testing.T) {
+	command, args := postMortemCommand("/bin/echo", []string{"a b", "c"})
+	output, err := exec.Command(command, args...).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "a b c\n", string(output))
+}
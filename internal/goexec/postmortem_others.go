@@ -0,0 +1,24 @@
+//go:build !linux
+
+package goexec
+
+import (
+	"os"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+)
+
+// This file disables the capture of core dumps of crashing cells on platforms other than Linux: the location
+// of the core files (and whether they are written at all) is only known on Linux. See postmortem_linux.go.
+
+// postMortemSupported indicates whether the core dumps of crashing cells can be captured on this platform.
+const postMortemSupported = false
+
+// postMortemCommand returns the command unchanged: core dumps are not captured on this platform.
+func postMortemCommand(binary string, args []string) (string, []string) {
+	return binary, args
+}
+
+// capturePostMortem does nothing: core dumps are not captured on this platform.
+func (s *State) capturePostMortem(_ kernel.Message, _ *os.ProcessState, _ time.Time) {}
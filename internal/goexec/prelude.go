@@ -0,0 +1,122 @@
+package goexec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the prelude: code set with `%%prelude` (or `%prelude --file=<path>`) that is executed
+// at the start of every `func main()` created for `%%` cells, before the cell body. E.g.: seeding
+// `math/rand`, setting the `log` flags, or initializing a helper package.
+
+// preludeCode is the parsed prelude: the imports it declares, and the statements executed in `func main()`.
+type preludeCode struct {
+	source     string
+	imports    []*Import
+	statements string
+}
+
+// parsePrelude parses the prelude source: optional import declarations, followed by Go statements.
+func parsePrelude(source string) (*preludeCode, error) {
+	prelude := &preludeCode{source: source}
+	fileSet := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fileSet, "prelude.go", "package main\n"+source, parser.ImportsOnly)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the imports of the prelude")
+	}
+	statementsStart := 0
+	for _, decl := range fileAst.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			break
+		}
+		statementsStart = fileSet.Position(genDecl.End()).Offset - len("package main\n")
+	}
+	for _, spec := range fileAst.Imports {
+		var alias string
+		if spec.Name != nil {
+			alias = spec.Name.Name
+		}
+		imp := NewImport(spec.Path.Value[1:len(spec.Path.Value)-1], alias)
+		imp.Cursor = NoCursor
+		prelude.imports = append(prelude.imports, imp)
+	}
+	prelude.statements = strings.TrimSpace(source[statementsStart:])
+
+	// Check that the statements are valid Go.
+	if _, err = parser.ParseFile(fileSet, "prelude.go", "package main\nfunc main() {\n"+prelude.statements+"\n}\n", 0); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the statements of the prelude")
+	}
+	return prelude, nil
+}
+
+// SetPrelude sets the code executed at the start of every `func main()` created for `%%` cells: optional import
+// declarations, followed by Go statements. An empty source removes the prelude.
+func (s *State) SetPrelude(source string) error {
+	if strings.TrimSpace(source) == "" {
+		s.prelude = nil
+		return nil
+	}
+	prelude, err := parsePrelude(source)
+	if err != nil {
+		return err
+	}
+	s.prelude = prelude
+	return nil
+}
+
+// SetPreludeFromFile sets the prelude (see SetPrelude) with the contents of the file.
+func (s *State) SetPreludeFromFile(filePath string) error {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read prelude from %q", filePath)
+	}
+	return errors.WithMessagef(s.SetPrelude(string(contents)), "prelude from %q", filePath)
+}
+
+// preludeStatements returns the statements of the prelude, indented to be included in `func main()`, or an
+// empty string if there is no prelude.
+func (s *State) preludeStatements() string {
+	if s.prelude == nil || s.prelude.statements == "" {
+		return ""
+	}
+	var sb strings.Builder
+	for _, line := range strings.Split(s.prelude.statements, "\n") {
+		if line != "" {
+			sb.WriteString("\t")
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// addPreludeImports adds the imports declared in the prelude to the declarations, if not imported otherwise.
+// `goimports` removes the ones not used.
+func (s *State) addPreludeImports(decls *Declarations) {
+	if s.prelude == nil {
+		return
+	}
+	for _, imp := range s.prelude.imports {
+		if _, found := decls.Imports[imp.Key]; !found {
+			decls.Imports[imp.Key] = imp
+		}
+	}
+}
+
+// DisplayPrelude implements `%prelude` without arguments: it displays the current prelude.
+func (s *State) DisplayPrelude(msg kernel.Message) error {
+	if s.prelude == nil {
+		return kernel.PublishMarkdown(msg, "No prelude set: set one with `%%prelude`, followed by the code in the "+
+			"following lines of the cell, or with `%prelude --file=<path>`.")
+	}
+	return kernel.PublishMarkdown(msg, fmt.Sprintf("Prelude executed at the start of `func main()`:\n\n```go\n%s\n```\n",
+		strings.TrimSpace(s.prelude.source)))
+}
@@ -0,0 +1,63 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrelude(t *testing.T) {
+	s := &State{Definitions: NewDeclarations()}
+	require.Error(t, s.SetPrelude("rand.Seed(42"))
+	require.Error(t, s.SetPrelude("import \"math/rand\n"))
+
+	require.NoError(t, s.SetPrelude(`import (
+	helpers "example.com/company/helpers"
+	_ "image/png"
+)
+
+rand.Seed(42)
+log.SetFlags(0)
+`))
+	assert.Equal(t, "func main() {\n\tflag.Parse()\n\trand.Seed(42)\n\tlog.SetFlags(0)\n", s.mainPreamble())
+
+	decls := NewDeclarations()
+	s.addPreludeImports(decls)
+	require.Contains(t, decls.Imports, "helpers")
+	assert.Equal(t, "example.com/company/helpers", decls.Imports["helpers"].Path)
+	assert.Contains(t, decls.Imports, "_~image/png")
+
+	// Only statements, from a file.
+	preludePath := path.Join(t.TempDir(), "prelude.go")
+	require.NoError(t, os.WriteFile(preludePath, []byte("// Common setup.\nlog.SetPrefix(\"> \")\n"), 0600))
+	require.NoError(t, s.SetPreludeFromFile(preludePath))
+	assert.True(t, strings.HasSuffix(s.mainPreamble(), "\t// Common setup.\n\tlog.SetPrefix(\"> \")\n"))
+	decls = NewDeclarations()
+	s.addPreludeImports(decls)
+	assert.Empty(t, decls.Imports)
+
+	require.NoError(t, s.SetPrelude(""))
+	assert.Equal(t, "func main() {\n\tflag.Parse()\n", s.mainPreamble())
+}
+
+func TestPreludeInCell(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	require.NoError(t, s.SetPrelude("rand.Seed(42)"))
+	lines := strings.Split("%%\nfmt.Println(rand.Int())", "\n")
+	_, fileToCellLines, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	contents, err := os.ReadFile(s.CodePath())
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n\tflag.Parse()\n\trand.Seed(42)\n\tfmt.Println(rand.Int())\n\n}\n",
+		string(contents))
+	assert.Equal(t, []int{-1, -1, 0, 0, 0, 1, -1, -1}, fileToCellLines)
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package goexec
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultShell used to execute `!` commands, if State.Shell is not set.
+const DefaultShell = "/bin/bash"
+
+// setNewProcessGroup configures the command to start on its own process group, so it doesn't receive the
+// interruptions of the kernel.
+func setNewProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: 0}
+}
+
+// killProcessGroup kills the process group of the command started with setNewProcessGroup.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
+
+// isProcessAlive returns whether the process with the given pid is running.
+func isProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// maxRSS returns the maximum resident memory of the finished process, in bytes, or 0 if not known.
+func maxRSS(processState *os.ProcessState) int64 {
+	rusage, ok := processState.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		// MacOS reports it in bytes.
+		return int64(rusage.Maxrss)
+	}
+	// Linux (and BSDs) report it in kilobytes.
+	return int64(rusage.Maxrss) * 1024
+}
@@ -0,0 +1,57 @@
+//go:build windows
+
+package goexec
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// DefaultShell used to execute `!` commands, if State.Shell is not set.
+const DefaultShell = "cmd"
+
+// setNewProcessGroup configures the command to start on its own process group, so it doesn't receive the
+// console control events (Control+C) of the kernel.
+func setNewProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessGroup kills the command and its child processes, with `taskkill /T`, or only the command if
+// that fails.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err == nil {
+		return nil
+	}
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// stillActive is the exit code of a process that hasn't exited yet.
+const stillActive = 259
+
+// isProcessAlive returns whether the process with the given pid is running.
+func isProcessAlive(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access denied means the process exists.
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer func() { _ = windows.CloseHandle(handle) }()
+	var exitCode uint32
+	if err = windows.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == stillActive
+}
+
+// maxRSS returns the maximum resident memory of the finished process, in bytes: not known on Windows.
+func maxRSS(*os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,158 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the Protocol Buffers cells (`%%protoc <dir>/<file>.proto`): the body of the cell is
+// written as a `.proto` file in a package directory of the temporary module, and compiled with `protoc` (and
+// `protoc-gen-go`) to Go code. The generated package is then handled as the ones written with `%%gopkg`: it is
+// imported by the following cells, and removed by `%reset`.
+
+var (
+	reProtoSyntax  = regexp.MustCompile(`(?m)^\s*(syntax|edition)\s*=`)
+	reProtoService = regexp.MustCompile(`(?m)^\s*service\s+\w+`)
+)
+
+// protoPath validates and cleans the path of a file written with `%%protoc`: it must be a `.proto` file in a
+// directory (the package) relative to the temporary module.
+func protoPath(filePath string) (string, error) {
+	cleaned := path.Clean(filePath)
+	switch {
+	case filePath == "" || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../"):
+		return "", errors.Errorf("%%%%protoc requires a path relative to the module, e.g. `%%%%protoc pb/person.proto`, got %q", filePath)
+	case path.Ext(cleaned) != ".proto":
+		return "", errors.Errorf("%%%%protoc file %q must have the extension `.proto`", filePath)
+	case path.Dir(cleaned) == ".":
+		return "", errors.Errorf("%%%%protoc file %q must be in a package directory, e.g. `pb/%s`", filePath, cleaned)
+	case strings.HasPrefix(cleaned, NotebookImportsDir+"/"):
+		return "", errors.Errorf("%%%%protoc directory %q is reserved for %%nbimport", NotebookImportsDir)
+	}
+	return cleaned, nil
+}
+
+// protocArgs returns the arguments to `protoc` to compile the `.proto` file relPath, in a directory of the
+// temporary module, to Go. The import paths of the `.proto` files written with `%%protoc` are given with `M`
+// options, so they don't need a `go_package` option. If withGRPC, the gRPC service code is also generated.
+func (s *State) protocArgs(relPath string, withGRPC bool) []string {
+	plugins := []string{"go"}
+	if withGRPC {
+		plugins = append(plugins, "go-grpc")
+	}
+	args := []string{"--proto_path=" + s.TempDir}
+	for _, plugin := range plugins {
+		args = append(args, fmt.Sprintf("--%s_out=%s", plugin, s.TempDir),
+			fmt.Sprintf("--%s_opt=paths=source_relative", plugin))
+		for _, protoFile := range SortedKeys(s.goPkgFiles) {
+			if path.Ext(protoFile) == ".proto" && protoFile != relPath {
+				args = append(args, fmt.Sprintf("--%s_opt=M%s=%s;%s", plugin, protoFile,
+					s.goPkgImportPath(protoFile), s.goPkgFiles[protoFile].pkgName))
+			}
+		}
+	}
+	return append(args, relPath)
+}
+
+// CompileProto writes the Protocol Buffers definitions, defined in the cell cellId starting at line firstLine,
+// as a `.proto` file in a package directory of the temporary module, and compiles it to Go with `protoc`. If
+// the contents have no `syntax` statement, `syntax = "proto3";` is prepended. If the file defines services and
+// `protoc-gen-go-grpc` is installed, the gRPC code is also generated.
+//
+// The generated package is imported by the following cells, and `gonbui.DisplayProto` can be used to display
+// its messages.
+func (s *State) CompileProto(msg kernel.Message, cellId, firstLine int, filePath, contents string) error {
+	relPath, err := protoPath(filePath)
+	if err != nil {
+		return err
+	}
+	for _, tool := range []string{"protoc", "protoc-gen-go"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return errors.Errorf("%%%%protoc requires %q, see https://protobuf.dev/getting-started/gotutorial/#compiling-protocol-buffers"+
+				" to install it", tool)
+		}
+	}
+	withGRPC := false
+	if reProtoService.MatchString(contents) {
+		if _, err := exec.LookPath("protoc-gen-go-grpc"); err == nil {
+			withGRPC = true
+		} else if msg != nil {
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+				"* %%protoc: services are not compiled, `protoc-gen-go-grpc` is not installed.\n")
+		}
+	}
+
+	source := goPkgFile{cellId: cellId, firstLine: firstLine}
+	source.pkgName = reNonIdentifier.ReplaceAllString(path.Base(path.Dir(relPath)), "_")
+	if !token.IsIdentifier(source.pkgName) {
+		source.pkgName = "_" + source.pkgName
+	}
+	if !reProtoSyntax.MatchString(contents) {
+		contents = "syntax = \"proto3\";\n" + contents
+		source.prepended = 1
+	}
+	for otherPath, other := range s.goPkgFiles {
+		if otherPath != relPath && path.Dir(otherPath) == path.Dir(relPath) && other.pkgName != source.pkgName {
+			return errors.Errorf("%%%%protoc file %q is in package %q, but %q is in package %q", filePath,
+				source.pkgName, otherPath, other.pkgName)
+		}
+	}
+
+	fullPath := path.Join(s.TempDir, relPath)
+	if current, err := os.ReadFile(fullPath); err != nil || !bytes.Equal(current, []byte(contents)) {
+		if err := os.MkdirAll(path.Dir(fullPath), 0755); err != nil {
+			return errors.Wrapf(err, "failed to create directory for %q", fullPath)
+		}
+		if err := os.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %q", fullPath)
+		}
+	}
+	if s.goPkgFiles == nil {
+		s.goPkgFiles = make(map[string]goPkgFile)
+	}
+	s.goPkgFiles[relPath] = source
+
+	// Generated files of a previous version, e.g. with a service since removed.
+	base := strings.TrimSuffix(relPath, ".proto")
+	generated := []string{base + ".pb.go", base + "_grpc.pb.go"}
+	for _, genPath := range generated {
+		_ = os.Remove(path.Join(s.TempDir, genPath))
+		delete(s.goPkgFiles, genPath)
+	}
+
+	cmd := exec.Command("protoc", s.protocArgs(relPath, withGRPC)...)
+	cmd.Dir = s.TempDir
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// Removed, so the following cells don't import a package without Go files.
+		_ = os.Remove(fullPath)
+		output = []byte(s.mapGoPackageReferences(string(output)))
+		delete(s.goPkgFiles, relPath)
+		return errors.Errorf("%%%%protoc %s failed:\n%s", relPath, output)
+	}
+	var written []string
+	for _, genPath := range generated {
+		if _, err := os.Stat(path.Join(s.TempDir, genPath)); err == nil {
+			s.goPkgFiles[genPath] = goPkgFile{cellId: cellId, pkgName: source.pkgName, generated: true}
+			written = append(written, genPath)
+		}
+	}
+	if msg == nil {
+		return nil
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("%%%%protoc: %s compiled to %s, package `%s` (%q).\n", relPath, strings.Join(written, ", "),
+			source.pkgName, s.goPkgImportPath(relPath)))
+}
@@ -0,0 +1,62 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileProto(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a shell script")
+	}
+	// Fake `protoc`, that writes the `.pb.go` file of the last argument, or fails if it contains "error".
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+for last; do :; done
+if grep -q error "$last"; then
+	echo "$last:3:1: Expected \"required\", \"optional\", or \"repeated\"." >&2
+	exit 1
+fi
+echo "package pb" > "${last%.proto}.pb.go"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "protoc"), []byte(script), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "protoc-gen-go"), []byte("#!/bin/sh\n"), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	s := &State{TempDir: t.TempDir(), Package: "gonb_test"}
+	require.Error(t, s.CompileProto(nil, 3, 1, "person.proto", ""))
+	require.Error(t, s.CompileProto(nil, 3, 1, "pb/person.go", ""))
+	require.Error(t, s.CompileProto(nil, 3, 1, "../pb/person.proto", ""))
+
+	// Syntax statement added if missing.
+	require.NoError(t, s.CompileProto(nil, 3, 1, "pb/person.proto", "message Person {\n  string name = 1;\n}\n"))
+	contents, err := os.ReadFile(path.Join(s.TempDir, "pb", "person.proto"))
+	require.NoError(t, err)
+	assert.Equal(t, "syntax = \"proto3\";\nmessage Person {\n  string name = 1;\n}\n", string(contents))
+	assert.FileExists(t, path.Join(s.TempDir, "pb", "person.pb.go"))
+
+	// Other `.proto` files are given their import paths.
+	assert.Equal(t, []string{"--proto_path=" + s.TempDir, "--go_out=" + s.TempDir, "--go_opt=paths=source_relative",
+		"--go_opt=Mpb/person.proto=gonb_test/pb;pb", "other/other.proto"}, s.protocArgs("other/other.proto", false))
+
+	// Errors are annotated with the cell lines, and the file is not kept.
+	err = s.CompileProto(nil, 4, 2, "pb/broken.proto", "syntax = \"proto3\";\nmessage Broken {\n  error\n}\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pb/broken.proto:3:1 (Cell[4]: Line 5): Expected")
+	assert.NoFileExists(t, path.Join(s.TempDir, "pb", "broken.proto"))
+
+	// Following cells import it, once.
+	decls := NewDeclarations()
+	s.addGoPackageImports(decls)
+	require.Len(t, decls.Imports, 1)
+	assert.Equal(t, "gonb_test/pb", decls.Imports["pb"].Path)
+
+	require.NoError(t, s.RemoveGoPackages())
+	assert.NoDirExists(t, path.Join(s.TempDir, "pb"))
+}
@@ -0,0 +1,222 @@
+package goexec
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	lsp "github.com/go-language-server/protocol"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements listing references to symbols and callers of functions defined in the
+// notebook, using `gopls`. It's connected to the special commands `%refs` and `%callers`.
+
+// symbolReference is a reference to a symbol in `main.go`.
+type symbolReference struct {
+	cellRef  string // Human-readable reference to cell and line.
+	line     string // Contents of the line.
+	enclosed string // Name of the enclosing declaration.
+	isCall   bool   // Whether the reference is a function call.
+}
+
+// References lists where the symbol name, defined in the memorized declarations, is used, by cell
+// and line. Name can be a top-level identifier, or a method or field of a type, in the form `Type.Name`.
+func (s *State) References(msg kernel.Message, name string) error {
+	refs, err := s.symbolReferences(msg, name)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	if len(refs) == 0 {
+		sb.WriteString(fmt.Sprintf("No references to `%s` found.\n", name))
+	} else {
+		sb.WriteString(fmt.Sprintf("**References to `%s`:**\n\n", name))
+	}
+	for _, ref := range refs {
+		sb.WriteString(fmt.Sprintf("* `%s` in `%s`: `%s`\n", ref.cellRef, ref.enclosed, strings.TrimSpace(ref.line)))
+	}
+	return kernel.PublishMarkdown(msg, sb.String())
+}
+
+// Callers lists the functions (and methods) that call the function funcName, by cell and line.
+// FuncName can be a function name, or a method in the form `Type.Method`.
+func (s *State) Callers(msg kernel.Message, funcName string) error {
+	refs, err := s.symbolReferences(msg, funcName)
+	if err != nil {
+		return err
+	}
+	var callers []string
+	callSites := make(map[string][]string)
+	for _, ref := range refs {
+		if !ref.isCall {
+			continue
+		}
+		if _, found := callSites[ref.enclosed]; !found {
+			callers = append(callers, ref.enclosed)
+		}
+		callSites[ref.enclosed] = append(callSites[ref.enclosed], ref.cellRef)
+	}
+	var sb strings.Builder
+	if len(callers) == 0 {
+		sb.WriteString(fmt.Sprintf("No callers of `%s` found.\n", funcName))
+	} else {
+		sb.WriteString(fmt.Sprintf("**Callers of `%s`:**\n\n", funcName))
+	}
+	for _, caller := range callers {
+		sb.WriteString(fmt.Sprintf("* `%s` → `%s` (%s)\n", caller, funcName, strings.Join(callSites[caller], ", ")))
+	}
+	return kernel.PublishMarkdown(msg, sb.String())
+}
+
+// symbolReferences composes `main.go` with the memorized declarations, and returns the references to
+// the symbol name.
+func (s *State) symbolReferences(msg kernel.Message, name string) (refs []symbolReference, err error) {
+	if s.gopls == nil {
+		return nil, errors.New("`gopls` is not installed, it is required to find references")
+	}
+
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	fileToCellIdAndLine, err := s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+	pos, err := s.findDeclarationPosition(name)
+	if err != nil {
+		return
+	}
+
+	// Query `gopls`.
+	ctx := context.Background()
+	err = s.notifyAboutStandardAndTrackedFiles(ctx)
+	if err != nil {
+		return
+	}
+	var locations []lsp.Location
+	_, err = s.withRetries(msg, "`gopls` references", func() (string, error) {
+		var err error
+		locations, err = s.gopls.References(ctx, s.CodePath(), pos.Line-1, pos.Column-1)
+		_ = s.gopls.ConsumeMessages()
+		return "", err
+	})
+	if err != nil {
+		err = errors.WithMessagef(err, "finding references to %q", name)
+		return
+	}
+
+	// Map references to cells and enclosing declarations.
+	content, err := s.readMainGo()
+	if err != nil {
+		return
+	}
+	lineStarts := lineStartsOf(content)
+	fileSet := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fileSet, s.CodePath(), content, parser.SkipObjectResolution)
+	if err != nil {
+		err = errors.Wrapf(err, "parsing %q", s.CodePath())
+		return
+	}
+	tokFile := fileSet.File(fileAst.Pos())
+	callOffsets := callIdentifierOffsets(fileAst, tokFile)
+	for _, location := range locations {
+		if location.URI.Filename() != s.CodePath() {
+			// References in tracked files are not mapped to cells.
+			continue
+		}
+		var offset int
+		offset, err = lspPositionToOffset(content, lineStarts, location.Range.Start)
+		if err != nil {
+			return
+		}
+		lineNum := int(location.Range.Start.Line)
+		line := content[lineStarts[lineNum]:]
+		if eol := strings.IndexByte(line, '\n'); eol >= 0 {
+			line = line[:eol]
+		}
+		refs = append(refs, symbolReference{
+			cellRef:  cellReference(lineNum, fileToCellIdAndLine),
+			line:     line,
+			enclosed: enclosingDeclarationName(fileAst, tokFile, offset),
+			isCall:   callOffsets.Has(offset),
+		})
+	}
+	return
+}
+
+// callIdentifierOffsets returns the byte offsets of the identifiers of the functions (or methods) being called.
+func callIdentifierOffsets(fileAst *ast.File, tokFile *token.File) Set[int] {
+	offsets := MakeSet[int]()
+	ast.Inspect(fileAst, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if ident := calledIdentifier(call.Fun); ident != nil {
+			offsets.Insert(tokFile.Offset(ident.Pos()))
+		}
+		return true
+	})
+	return offsets
+}
+
+// calledIdentifier returns the identifier of the function (or method) called by fun, or nil if
+// it's not a named function (e.g.: a function literal).
+func calledIdentifier(fun ast.Expr) *ast.Ident {
+	switch typedFun := fun.(type) {
+	case *ast.ParenExpr:
+		return calledIdentifier(typedFun.X)
+	case *ast.IndexExpr: // Instantiation of generic function.
+		return calledIdentifier(typedFun.X)
+	case *ast.IndexListExpr:
+		return calledIdentifier(typedFun.X)
+	case *ast.Ident:
+		return typedFun
+	case *ast.SelectorExpr:
+		return typedFun.Sel
+	}
+	return nil
+}
+
+// enclosingDeclarationName returns the name of the top-level declaration that contains offset.
+// Methods are named `Type.Method`.
+func enclosingDeclarationName(fileAst *ast.File, tokFile *token.File, offset int) string {
+	for _, decl := range fileAst.Decls {
+		if offset < tokFile.Offset(decl.Pos()) || offset >= tokFile.Offset(decl.End()) {
+			continue
+		}
+		switch typedDecl := decl.(type) {
+		case *ast.FuncDecl:
+			if typedDecl.Recv != nil && len(typedDecl.Recv.List) > 0 {
+				return receiverTypeName(typedDecl.Recv.List[0].Type) + "." + typedDecl.Name.Name
+			}
+			return typedDecl.Name.Name
+		case *ast.GenDecl:
+			for _, spec := range typedDecl.Specs {
+				if offset < tokFile.Offset(spec.Pos()) || offset >= tokFile.Offset(spec.End()) {
+					continue
+				}
+				switch typedSpec := spec.(type) {
+				case *ast.TypeSpec:
+					return typedSpec.Name.Name
+				case *ast.ValueSpec:
+					names := make([]string, 0, len(typedSpec.Names))
+					for _, name := range typedSpec.Names {
+						names = append(names, name.Name)
+					}
+					return strings.Join(names, ", ")
+				}
+			}
+			return typedDecl.Tok.String()
+		}
+	}
+	return "?"
+}
@@ -0,0 +1,48 @@
+package goexec
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallersAndEnclosingDeclarations(t *testing.T) {
+	content := `package main
+
+type Kg int
+
+func (k Kg) Double() Kg { return 2 * k }
+
+var x = f(Kg(1).Double())
+
+func f(k Kg) Kg {
+	g := f
+	return g(k)
+}
+`
+	fileSet := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fileSet, "main.go", content, parser.SkipObjectResolution)
+	require.NoError(t, err)
+	tokFile := fileSet.File(fileAst.Pos())
+	callOffsets := callIdentifierOffsets(fileAst, tokFile)
+
+	// Call in variable declaration.
+	offset := strings.Index(content, "f(Kg")
+	assert.True(t, callOffsets.Has(offset))
+	assert.Equal(t, "x", enclosingDeclarationName(fileAst, tokFile, offset))
+
+	// Method call.
+	offset = strings.Index(content, "Double()")
+	assert.Equal(t, "Kg.Double", enclosingDeclarationName(fileAst, tokFile, offset))
+	offset = strings.Index(content, "Double())")
+	assert.True(t, callOffsets.Has(offset))
+
+	// Reference to function, not a call.
+	offset = strings.Index(content, "f\n")
+	assert.False(t, callOffsets.Has(offset))
+	assert.Equal(t, "f", enclosingDeclarationName(fileAst, tokFile, offset))
+}
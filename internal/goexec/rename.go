@@ -0,0 +1,210 @@
+package goexec
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	lsp "github.com/go-language-server/protocol"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements renaming identifiers across all memorized declarations, using `gopls`.
+// It's connected to the special command `%rename`.
+
+// Rename the identifier oldName to newName in all memorized declarations, and reports
+// which declarations were updated.
+//
+// oldName can be a top-level identifier (function, type, variable or constant), or a method or
+// field of a type, in the form `Type.Name`. newName is always just the new identifier.
+func (s *State) Rename(msg kernel.Message, oldName, newName string) (err error) {
+	if s.gopls == nil {
+		return errors.New("`gopls` is not installed, it is required for %rename")
+	}
+	if !token.IsIdentifier(newName) {
+		return errors.Errorf("%%rename: new name %q is not a valid Go identifier", newName)
+	}
+
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	_, err = s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+	pos, err := s.findDeclarationPosition(oldName)
+	if err != nil {
+		return
+	}
+
+	// Query `gopls`.
+	ctx := context.Background()
+	err = s.notifyAboutStandardAndTrackedFiles(ctx)
+	if err != nil {
+		return
+	}
+	var edits []lsp.TextEdit
+	_, err = s.withRetries(msg, "`gopls` rename", func() (string, error) {
+		var err error
+		edits, err = s.gopls.Rename(ctx, s.CodePath(), pos.Line-1, pos.Column-1, newName)
+		_ = s.gopls.ConsumeMessages()
+		return "", err
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "renaming %q to %q", oldName, newName)
+	}
+	if len(edits) == 0 {
+		return errors.Errorf("%%rename: `gopls` returned no edits to rename %q", oldName)
+	}
+
+	changes, err := s.applyEditsToDeclarations(edits, func(before *Declarations) []string {
+		return s.Definitions.renameKeys(before, oldName, newName)
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "renaming %q to %q", oldName, newName)
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Renamed `%s` to `%s`:\n\n", oldName, newName))
+	for _, change := range changes {
+		sb.WriteString(fmt.Sprintf("* %s\n", change))
+	}
+	return kernel.PublishMarkdown(msg, sb.String())
+}
+
+// findDeclarationPosition finds in `main.go` the position of the identifier where name is declared.
+// Name can be a top-level identifier, or a method or field of a type, in the form `Type.Name`.
+func (s *State) findDeclarationPosition(name string) (pos token.Position, err error) {
+	typeName, memberName, isMember := strings.Cut(name, ".")
+	fileSet := token.NewFileSet()
+	var fileAst *ast.File
+	fileAst, err = parser.ParseFile(fileSet, s.CodePath(), nil, parser.SkipObjectResolution)
+	if err != nil {
+		err = errors.Wrapf(err, "parsing %q", s.CodePath())
+		return
+	}
+
+	var found *ast.Ident
+	for _, decl := range fileAst.Decls {
+		switch typedDecl := decl.(type) {
+		case *ast.FuncDecl:
+			hasReceiver := typedDecl.Recv != nil && len(typedDecl.Recv.List) > 0
+			if !isMember && !hasReceiver && typedDecl.Name.Name == name {
+				found = typedDecl.Name
+			} else if isMember && hasReceiver && typedDecl.Name.Name == memberName &&
+				receiverTypeName(typedDecl.Recv.List[0].Type) == typeName {
+				found = typedDecl.Name
+			}
+		case *ast.GenDecl:
+			for _, spec := range typedDecl.Specs {
+				switch typedSpec := spec.(type) {
+				case *ast.TypeSpec:
+					if !isMember && typedSpec.Name.Name == name {
+						found = typedSpec.Name
+					} else if isMember && typedSpec.Name.Name == typeName {
+						if structType, ok := typedSpec.Type.(*ast.StructType); ok {
+							for _, field := range structType.Fields.List {
+								for _, fieldName := range field.Names {
+									if fieldName.Name == memberName {
+										found = fieldName
+									}
+								}
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					for _, valueName := range typedSpec.Names {
+						if !isMember && valueName.Name == name {
+							found = valueName
+						}
+					}
+				}
+			}
+		}
+		if found != nil {
+			return fileSet.Position(found.Pos()), nil
+		}
+	}
+	err = errors.Errorf("declaration of %q not found in memorized definitions -- see `%%ls`", name)
+	return
+}
+
+// receiverTypeName returns the name of the type of a method receiver, without pointer or type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch typedExpr := expr.(type) {
+		case *ast.StarExpr:
+			expr = typedExpr.X
+		case *ast.IndexExpr:
+			expr = typedExpr.X
+		case *ast.IndexListExpr:
+			expr = typedExpr.X
+		case *ast.Ident:
+			return typedExpr.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// renameKeys updates the keys of the memorized declarations (and of before, the declarations parsed
+// before the rename) of the renamed identifier, so their cell lines information is preserved.
+//
+// It returns a human-readable list of the keys renamed.
+func (d *Declarations) renameKeys(before *Declarations, oldName, newName string) (changes []string) {
+	typeName, _, isMember := strings.Cut(oldName, ".")
+	if isMember {
+		// Method: key is "Type~Method". Fields are not memorized declarations on their own.
+		oldKey, newKey := typeName+"~"+strings.TrimPrefix(oldName, typeName+"."), typeName+"~"+newName
+		if renameKey(d.Functions, oldKey, newKey) && renameKey(before.Functions, oldKey, newKey) {
+			changes = append(changes, fmt.Sprintf("renamed method `%s` to `%s`", oldKey, newKey))
+		}
+		return
+	}
+
+	if renameKey(d.Functions, oldName, newName) && renameKey(before.Functions, oldName, newName) {
+		changes = append(changes, fmt.Sprintf("renamed function `%s` to `%s`", oldName, newName))
+	}
+	if renameKey(d.Variables, oldName, newName) && renameKey(before.Variables, oldName, newName) {
+		// The name of variables is not part of their definition, so it must be updated here.
+		for _, v := range []*Variable{d.Variables[newName], before.Variables[newName]} {
+			v.Key, v.Name = newName, newName
+		}
+		changes = append(changes, fmt.Sprintf("renamed variable `%s` to `%s`", oldName, newName))
+	}
+	if renameKey(d.Constants, oldName, newName) && renameKey(before.Constants, oldName, newName) {
+		// Same for constants.
+		d.Constants[newName].Key = newName
+		before.Constants[newName].Key = newName
+		changes = append(changes, fmt.Sprintf("renamed constant `%s` to `%s`", oldName, newName))
+	}
+	if renameKey(d.Types, oldName, newName) && renameKey(before.Types, oldName, newName) {
+		changes = append(changes, fmt.Sprintf("renamed type `%s` to `%s`", oldName, newName))
+		// Methods of the type are keyed by the type name.
+		for _, key := range SortedKeys(d.Functions) {
+			if strings.HasPrefix(key, oldName+"~") {
+				newKey := newName + key[len(oldName):]
+				renameKey(d.Functions, key, newKey)
+				renameKey(before.Functions, key, newKey)
+			}
+		}
+	}
+	return
+}
+
+// renameKey moves the entry oldKey to newKey in the map, if it exists. It returns whether the key was found.
+func renameKey[T any](m map[string]*T, oldKey, newKey string) bool {
+	value, found := m[oldKey]
+	if !found {
+		return false
+	}
+	delete(m, oldKey)
+	m[newKey] = value
+	return true
+}
@@ -0,0 +1,33 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameKeys(t *testing.T) {
+	memorized := NewDeclarations()
+	memorized.Types["Kg"] = &TypeDecl{Key: "Kg", TypeDefinition: "Kg int",
+		CellLines: CellLines{Id: 1, Lines: []int{0}}}
+	memorized.Functions["Kg~Weight"] = &Function{Key: "Kg~Weight", Definition: "func (k Kg) Weight() int { return int(k) }",
+		CellLines: CellLines{Id: 2, Lines: []int{3}}}
+	memorized.Variables["v"] = &Variable{Key: "v", Name: "v", ValueDefinition: "Kg(1)"}
+	before := memorized.Copy()
+	before.Variables["v"] = &Variable{Key: "v", Name: "v", ValueDefinition: "Kg(1)"}
+
+	changes := memorized.renameKeys(before, "Kg", "Kilogram")
+	assert.Equal(t, []string{"renamed type `Kg` to `Kilogram`"}, changes)
+	assert.Contains(t, memorized.Types, "Kilogram")
+	assert.Contains(t, memorized.Functions, "Kilogram~Weight")
+	assert.Contains(t, before.Functions, "Kilogram~Weight")
+	assert.Equal(t, 2, memorized.Functions["Kilogram~Weight"].Id)
+
+	changes = memorized.renameKeys(before, "v", "w")
+	assert.Equal(t, []string{"renamed variable `v` to `w`"}, changes)
+	assert.Equal(t, "w", memorized.Variables["w"].Name)
+	assert.Equal(t, "w", before.Variables["w"].Key)
+
+	changes = memorized.renameKeys(before, "Kilogram.Weight", "Mass")
+	assert.Equal(t, []string{"renamed method `Kilogram~Weight` to `Kilogram~Mass`"}, changes)
+}
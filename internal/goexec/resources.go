@@ -0,0 +1,95 @@
+package goexec
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+)
+
+// This file implements the report of the resources used by each execution (`%config report_resources=on`):
+// the wall time, CPU times and maximum resident memory of the cell program, and its exit status, taken
+// from its `rusage` when it finishes.
+
+// ResourceReport holds the resources used by the execution of a cell program.
+type ResourceReport struct {
+	WallTime, UserTime, SysTime time.Duration
+
+	// MaxRSS is the maximum resident set size, in bytes. It is 0 if not available.
+	MaxRSS int64
+
+	// ExitStatus is the exit code of the program, or the signal that terminated it (e.g.: "signal: killed").
+	ExitStatus string
+}
+
+// NewResourceReport creates the ResourceReport for a program that finished with the given state.
+func NewResourceReport(processState *os.ProcessState, wallTime time.Duration) *ResourceReport {
+	r := &ResourceReport{
+		WallTime:   wallTime,
+		UserTime:   processState.UserTime(),
+		SysTime:    processState.SystemTime(),
+		ExitStatus: fmt.Sprintf("exit code %d", processState.ExitCode()),
+	}
+	if status, ok := processState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		r.ExitStatus = "signal: " + status.Signal().String()
+	}
+	r.MaxRSS = maxRSS(processState)
+	return r
+}
+
+// formatBytes formats a number of bytes using binary prefixes, e.g.: "12.3 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
+
+// Summary returns a one line summary of the resources used.
+func (r *ResourceReport) Summary() string {
+	summary := fmt.Sprintf("%s wall, %s CPU", r.WallTime.Round(time.Millisecond),
+		(r.UserTime + r.SysTime).Round(time.Millisecond))
+	if r.MaxRSS > 0 {
+		summary += ", " + formatBytes(r.MaxRSS) + " max RSS"
+	}
+	return summary + ", " + r.ExitStatus
+}
+
+// Html returns the report as a collapsible footer: the summary, that expands to a table with the details.
+func (r *ResourceReport) Html() string {
+	maxRSS := "n/a"
+	if r.MaxRSS > 0 {
+		maxRSS = formatBytes(r.MaxRSS)
+	}
+	rows := [][2]string{
+		{"Wall time", r.WallTime.Round(time.Millisecond).String()},
+		{"User CPU time", r.UserTime.Round(time.Millisecond).String()},
+		{"System CPU time", r.SysTime.Round(time.Millisecond).String()},
+		{"Max RSS", maxRSS},
+		{"Exit status", r.ExitStatus},
+	}
+	htmlReport := fmt.Sprintf("<details style=\"font-size: small; opacity: 0.7\"><summary>Resources: %s</summary>\n<table>\n",
+		html.EscapeString(r.Summary()))
+	for _, row := range rows {
+		htmlReport += fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>\n", row[0], html.EscapeString(row[1]))
+	}
+	return htmlReport + "</table></details>"
+}
+
+// publishResourceReport publishes the report of the resources used by the cell program, if
+// State.ReportResources is set.
+func (s *State) publishResourceReport(msg kernel.Message, processState *os.ProcessState, startTime time.Time) {
+	if !s.ReportResources || processState == nil {
+		return
+	}
+	_ = kernel.PublishHtml(msg, NewResourceReport(processState, time.Since(startTime)).Html())
+}
@@ -0,0 +1,28 @@
+package goexec
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceReport(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 3")
+	require.Error(t, cmd.Run())
+	r := NewResourceReport(cmd.ProcessState, 1500*time.Millisecond)
+	assert.Equal(t, "exit code 3", r.ExitStatus)
+	assert.Greater(t, r.MaxRSS, int64(0))
+	assert.Contains(t, r.Summary(), "1.5s wall")
+	htmlReport := r.Html()
+	assert.Contains(t, htmlReport, "<details")
+	assert.Contains(t, htmlReport, "<td>Exit status</td><td>exit code 3</td>")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "12.0 MiB", formatBytes(12<<20))
+}
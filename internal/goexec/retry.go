@@ -0,0 +1,148 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the automatic retry of toolchain operations that fail intermittently: "text file busy"
+// when starting a freshly built binary, module cache lock errors when running the `go` tool, and `gopls`
+// timeouts. They are retried State.ToolchainRetries times with exponential backoff and, if they keep failing,
+// the output of each attempt and the composed code are saved to a diagnostics file, to attach to an issue.
+
+const (
+	// DefaultToolchainRetries is the default value of State.ToolchainRetries.
+	DefaultToolchainRetries = 2
+
+	// ToolchainDiagnosticsFile is the name of the file, in State.TempDir, where the diagnostics of the last
+	// toolchain operation that kept failing are saved.
+	ToolchainDiagnosticsFile = "gonb_toolchain_diagnostics.txt"
+)
+
+// toolchainRetryBackoff is the wait before the first retry, doubled for each following one.
+var toolchainRetryBackoff = 250 * time.Millisecond
+
+// flakyToolchainErrors match the output (or error) of the transient failures retried.
+var flakyToolchainErrors = []*regexp.Regexp{
+	regexp.MustCompile(`text file busy`),
+	regexp.MustCompile(`(?i)\bR?Lock\b.*(resource temporarily unavailable|interrupted system call|no locks available)`),
+	regexp.MustCompile(`(?i)could not acquire lock`),
+	regexp.MustCompile(`context deadline exceeded`),
+}
+
+// isFlakyToolchainError returns whether the output or the error of a toolchain operation indicate a transient
+// failure, worth retrying.
+func isFlakyToolchainError(output string, err error) bool {
+	if err == nil {
+		return false
+	}
+	text := output + "\n" + err.Error()
+	for _, re := range flakyToolchainErrors {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolchainAttempt is the result of one attempt of a toolchain operation, kept for the diagnostics.
+type toolchainAttempt struct {
+	start  time.Time
+	output string
+	err    error
+}
+
+// withRetries runs the toolchain operation described by description, retrying it up to State.ToolchainRetries
+// times, with exponential backoff, while it fails with a transient error (see isFlakyToolchainError).
+// If it still fails with a transient error, the diagnostics are saved with State.saveToolchainDiagnostics.
+//
+// It returns the output and error of the last attempt. msg can be nil, in which case nothing is published.
+func (s *State) withRetries(msg kernel.Message, description string, run func() (output string, err error)) (string, error) {
+	var attempts []toolchainAttempt
+	backoff := toolchainRetryBackoff
+	for {
+		attempt := toolchainAttempt{start: time.Now()}
+		attempt.output, attempt.err = run()
+		attempts = append(attempts, attempt)
+		if !isFlakyToolchainError(attempt.output, attempt.err) {
+			return attempt.output, attempt.err
+		}
+		if len(attempts) > s.ToolchainRetries {
+			break
+		}
+		klog.Warningf("%s failed with a transient error, retrying in %s: %v", description, backoff, attempt.err)
+		if msg != nil {
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+				"* %s failed with a transient error, retrying in %s (attempt %d of %d).\n",
+				description, backoff, len(attempts)+1, s.ToolchainRetries+1))
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	last := attempts[len(attempts)-1]
+	diagnosticsPath, err := s.saveToolchainDiagnostics(description, attempts)
+	if err != nil {
+		klog.Errorf("Failed to save the toolchain diagnostics: %+v", err)
+	} else if msg != nil {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+			"* %s kept failing with a transient error (%d attempts): the diagnostics (output of each attempt "+
+				"and the composed code) were saved in %q, to attach to an issue.\n",
+			description, len(attempts), diagnosticsPath))
+	}
+	return last.output, last.err
+}
+
+// combinedOutputWithRetries runs cmd, as exec.Cmd.CombinedOutput, retrying it on transient errors, see
+// State.withRetries. Retries run a copy of cmd, since commands can only be run once.
+func (s *State) combinedOutputWithRetries(msg kernel.Message, cmd *exec.Cmd) ([]byte, error) {
+	description := fmt.Sprintf("`%s`", strings.Join(append([]string{filepath.Base(cmd.Path)}, cmd.Args[1:]...), " "))
+	first := true
+	output, err := s.withRetries(msg, description, func() (string, error) {
+		if !first {
+			retryCmd := exec.Command(cmd.Path, cmd.Args[1:]...)
+			retryCmd.Dir, retryCmd.Env = cmd.Dir, cmd.Env
+			cmd = retryCmd
+		}
+		first = false
+		klog.V(2).Infof("Executing %s", cmd)
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	})
+	return []byte(output), err
+}
+
+// saveToolchainDiagnostics saves the diagnostics of a toolchain operation that kept failing: the environment,
+// the output of each attempt and the last composed code. It returns the path of the file.
+func (s *State) saveToolchainDiagnostics(description string, attempts []toolchainAttempt) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Operation: %s\n", description)
+	fmt.Fprintf(&sb, "Time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "GoNB built with: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&sb, "Directory: %s\n", s.TempDir)
+	if env := s.GoEnv(); len(env) > 0 {
+		fmt.Fprintf(&sb, "Go environment: %s\n", strings.Join(env, " "))
+	}
+	for ii, attempt := range attempts {
+		fmt.Fprintf(&sb, "\n=== Attempt %d (%s): %v\n%s\n", ii+1, attempt.start.Format(time.RFC3339Nano), attempt.err,
+			strings.TrimRight(attempt.output, "\n"))
+	}
+	if s.lastComposed != nil {
+		fmt.Fprintf(&sb, "\n=== Composed code (%s)\n%s", s.lastComposed.fileName, s.lastComposed.code)
+	}
+	diagnosticsPath := filepath.Join(s.TempDir, ToolchainDiagnosticsFile)
+	if err := os.WriteFile(diagnosticsPath, []byte(sb.String()), 0600); err != nil {
+		return "", errors.Wrapf(err, "failed to write toolchain diagnostics to %q", diagnosticsPath)
+	}
+	return diagnosticsPath, nil
+}
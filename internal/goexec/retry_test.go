@@ -0,0 +1,92 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsFlakyToolchainError(t *testing.T) {
+	assert.False(t, isFlakyToolchainError("text file busy", nil))
+	assert.True(t, isFlakyToolchainError("", errors.New("fork/exec /tmp/gonb_1/gonb_1: text file busy")))
+	assert.True(t, isFlakyToolchainError(
+		"go: RLock /root/go/pkg/mod/cache/download/lock: resource temporarily unavailable", errors.New("exit status 1")))
+	assert.True(t, isFlakyToolchainError("", errors.New("request failed: context deadline exceeded")))
+	assert.False(t, isFlakyToolchainError("./main.go:3:2: undefined: x", errors.New("exit status 1")))
+}
+
+func TestWithRetries(t *testing.T) {
+	defer func(backoff time.Duration) { toolchainRetryBackoff = backoff }(toolchainRetryBackoff)
+	toolchainRetryBackoff = time.Millisecond
+	s := &State{TempDir: t.TempDir(), ToolchainRetries: 2}
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+
+	// Succeeds at the second attempt.
+	calls := 0
+	output, err := s.withRetries(msg, "`go build`", func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.New("text file busy")
+		}
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", output)
+	assert.Equal(t, 2, calls)
+	require.Len(t, msg.Outputs(), 1)
+	assert.Contains(t, fmt.Sprint(msg.Outputs()[0]["text"]), "retrying in 1ms (attempt 2 of 3)")
+
+	// Non-transient errors are not retried.
+	calls = 0
+	_, err = s.withRetries(msg, "`go build`", func() (string, error) {
+		calls++
+		return "undefined: x", errors.New("exit status 1")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// Keeps failing: the diagnostics are saved.
+	calls = 0
+	s.lastComposed = &composedCode{fileName: "main.go", code: "package main\n"}
+	_, err = s.withRetries(msg, "`go build`", func() (string, error) {
+		calls++
+		return fmt.Sprintf("go: RLock lock: resource temporarily unavailable #%d", calls), errors.New("exit status 1")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 3, calls)
+	outputs := msg.Outputs()
+	assert.Contains(t, fmt.Sprint(outputs[len(outputs)-1]["text"]), "kept failing with a transient error (3 attempts)")
+	diagnostics, err := os.ReadFile(filepath.Join(s.TempDir, ToolchainDiagnosticsFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(diagnostics), "Operation: `go build`")
+	assert.Contains(t, string(diagnostics), "=== Attempt 3")
+	assert.Contains(t, string(diagnostics), "resource temporarily unavailable #3")
+	assert.Contains(t, string(diagnostics), "=== Composed code (main.go)\npackage main\n")
+}
+
+func TestCombinedOutputWithRetries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a shell script")
+	}
+	defer func(backoff time.Duration) { toolchainRetryBackoff = backoff }(toolchainRetryBackoff)
+	toolchainRetryBackoff = time.Millisecond
+	s := &State{TempDir: t.TempDir(), ToolchainRetries: 1}
+
+	// The command fails with "text file busy" the first time only.
+	marker := filepath.Join(s.TempDir, "marker")
+	cmd := exec.Command("sh", "-c",
+		fmt.Sprintf("if [ -e %q ]; then echo done; else touch %q; echo 'text file busy'; exit 1; fi", marker, marker))
+	output, err := s.combinedOutputWithRetries(nil, cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(output))
+}
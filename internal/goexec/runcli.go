@@ -0,0 +1,99 @@
+package goexec
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements `%run-cli <args...>`: it executes the program composed from the memorized
+// declarations as a command line tool, with the given arguments. If the cell doesn't define a `func main()`,
+// one is created that executes the root command of the CLI framework used (cobra or urfave/cli).
+
+// cliFramework describes how to find and execute the root command of a CLI framework.
+type cliFramework struct {
+	importPath, packageName string
+	typeName                string // Type of the root command, e.g.: "Command" for `*cobra.Command`.
+	run                     string // Body of main, with `%[1]s` replaced by the root command variable.
+}
+
+// cliFrameworks supported by `%run-cli`.
+var cliFrameworks = []cliFramework{
+	{importPath: "github.com/spf13/cobra", packageName: "cobra", typeName: "Command",
+		run: "\tif err := %[1]s.Execute(); err != nil {\n\t\tos.Exit(1)\n\t}\n"},
+	{importPath: "github.com/urfave/cli/v3", packageName: "cli", typeName: "Command",
+		run: "\tif err := %[1]s.Run(context.Background(), os.Args); err != nil {\n\t\tfmt.Fprintln(os.Stderr, err)\n\t\tos.Exit(1)\n\t}\n"},
+	{importPath: "github.com/urfave/cli/v2", packageName: "cli", typeName: "App",
+		run: "\tif err := %[1]s.Run(os.Args); err != nil {\n\t\tfmt.Fprintln(os.Stderr, err)\n\t\tos.Exit(1)\n\t}\n"},
+	{importPath: "github.com/urfave/cli", packageName: "cli", typeName: "App",
+		run: "\tif err := %[1]s.Run(os.Args); err != nil {\n\t\tfmt.Fprintln(os.Stderr, err)\n\t\tos.Exit(1)\n\t}\n"},
+}
+
+// preferredRootNames are the names of the variables taken as the root command, if more than one command
+// is declared.
+var preferredRootNames = []string{"rootCmd", "root", "app", "cmd"}
+
+// isRootCommand returns whether the variable is declared or initialized as the root command type of the
+// framework, imported as the package qualifier.
+func (f *cliFramework) isRootCommand(v *Variable, qualifier string) bool {
+	typeName := regexp.QuoteMeta(qualifier + "." + f.typeName)
+	if regexp.MustCompile(`^\*\s*` + typeName + `$`).MatchString(strings.TrimSpace(v.TypeDefinition)) {
+		return true
+	}
+	re := regexp.MustCompile(`^(&\s*` + typeName + `\s*\{|` + regexp.QuoteMeta(qualifier) + `\.NewApp\(\))`)
+	return re.MatchString(strings.TrimSpace(v.ValueDefinition))
+}
+
+// runCLIMain returns the `func main()` that executes the CLI declared in decls: it calls the memorized
+// `func Execute()` (the convention of the cobra generator), if there is one, or executes the root command
+// variable of one of the cliFrameworks.
+func runCLIMain(decls *Declarations) (*Function, error) {
+	mainDecl := &Function{Cursor: NoCursor, Key: "main", Name: "main"}
+	if fn, found := decls.Functions["Execute"]; found && fn.Receiver == "" {
+		mainDecl.Definition = "func main() {\n\tExecute()\n}"
+		return mainDecl, nil
+	}
+	for _, framework := range cliFrameworks {
+		var candidates []string
+		for _, imp := range decls.Imports {
+			if imp.Path != framework.importPath {
+				continue
+			}
+			qualifier := framework.packageName
+			if imp.Alias != "" {
+				qualifier = imp.Alias
+			}
+			for _, v := range decls.Variables {
+				if v.Name != "_" && framework.isRootCommand(v, qualifier) {
+					candidates = append(candidates, v.Name)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		sort.Strings(candidates)
+		root := candidates[0]
+		if len(candidates) > 1 {
+			root = ""
+			for _, name := range preferredRootNames {
+				if found := sort.SearchStrings(candidates, name); found < len(candidates) && candidates[found] == name {
+					root = name
+					break
+				}
+			}
+			if root == "" {
+				return nil, errors.Errorf("%%run-cli: more than one %s command declared (%s), define "+
+					"`func main()` or `func Execute()` to select the root command", framework.packageName,
+					strings.Join(candidates, ", "))
+			}
+		}
+		mainDecl.Definition = "func main() {\n" + fmt.Sprintf(framework.run, root) + "}"
+		return mainDecl, nil
+	}
+	return nil, errors.New("%run-cli: no CLI found, declare the root command as a variable (e.g.: " +
+		"`var rootCmd = &cobra.Command{...}`), or define `func main()` or `func Execute()`")
+}
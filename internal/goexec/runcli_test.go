@@ -0,0 +1,66 @@
+package goexec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCLIMain(t *testing.T) {
+	// Cobra root command, selected by its name among the commands declared.
+	decls := NewDeclarations()
+	decls.Imports["cobra"] = NewImport("github.com/spf13/cobra", "")
+	decls.Variables["rootCmd"] = &Variable{Key: "rootCmd", Name: "rootCmd", ValueDefinition: `&cobra.Command{Use: "greet"}`}
+	decls.Variables["helloCmd"] = &Variable{Key: "helloCmd", Name: "helloCmd", ValueDefinition: `&cobra.Command{Use: "hello"}`}
+	mainDecl, err := runCLIMain(decls)
+	require.NoError(t, err)
+	assert.Contains(t, mainDecl.Definition, "if err := rootCmd.Execute(); err != nil {")
+
+	// Ambiguous root command.
+	delete(decls.Variables, "rootCmd")
+	decls.Variables["byeCmd"] = &Variable{Key: "byeCmd", Name: "byeCmd", TypeDefinition: "*cobra.Command"}
+	_, err = runCLIMain(decls)
+	require.ErrorContains(t, err, "byeCmd, helloCmd")
+
+	// `func Execute()` takes precedence.
+	decls.Functions["Execute"] = &Function{Key: "Execute", Name: "Execute", Definition: "func Execute() {}"}
+	mainDecl, err = runCLIMain(decls)
+	require.NoError(t, err)
+	assert.Equal(t, "func main() {\n\tExecute()\n}", mainDecl.Definition)
+
+	// urfave/cli, imported with an alias.
+	decls = NewDeclarations()
+	decls.Imports["ucli"] = NewImport("github.com/urfave/cli/v2", "ucli")
+	decls.Variables["app"] = &Variable{Key: "app", Name: "app", ValueDefinition: `&ucli.App{Name: "greet"}`}
+	mainDecl, err = runCLIMain(decls)
+	require.NoError(t, err)
+	assert.Contains(t, mainDecl.Definition, "if err := app.Run(os.Args); err != nil {")
+
+	// No CLI.
+	_, err = runCLIMain(NewDeclarations())
+	require.ErrorContains(t, err, "no CLI found")
+}
+
+func TestRunCLI(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	s.CellRunCLI = true
+	assert.NotContains(t, s.mainPreamble(), "flag.Parse()")
+
+	cell := `import "fmt"
+
+func Execute() {
+	fmt.Println("args:", os.Args[1:])
+}
+`
+	lines := strings.Split(cell, "\n")
+	updatedDecls, mainDecl, _, _, err := s.parseLinesAndComposeMain(nil, 1, lines, nil, NoCursor)
+	require.NoError(t, err)
+	assert.Contains(t, updatedDecls.Functions, "Execute")
+	assert.Equal(t, "func main() {\n\tExecute()\n}", mainDecl.Definition)
+}
@@ -0,0 +1,204 @@
+package goexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%search <regexp>`: it searches the exported identifiers, and their documentation,
+// of the standard library and the packages the notebook depends on, offline. The packages are listed
+// with `go list`, and their exported symbols extracted with go/doc.
+
+// MaxSearchResults is the maximum number of symbols listed by `%search`.
+const MaxSearchResults = 100
+
+// searchSymbol is an exported identifier of a package.
+type searchSymbol struct {
+	ImportPath string
+	Package    string // Package name, that qualifies the symbol.
+	Name       string // Qualified by the type, for methods (e.g.: "Builder.WriteString").
+	Kind       string // "func", "method", "type", "var" or "const".
+	Synopsis   string // First sentence of the documentation.
+}
+
+// searchPackage is a package listed by `go list -json`.
+type searchPackage struct {
+	ImportPath, Dir, Name string
+	Standard              bool
+	GoFiles               []string
+	Module                *struct {
+		Main    bool
+		Replace *struct{}
+	}
+}
+
+// immutable returns whether the package sources can't change during the session: the standard library and
+// modules in the module cache. Their symbols are indexed only once.
+func (p *searchPackage) immutable() bool {
+	return p.Standard || (p.Module != nil && !p.Module.Main && p.Module.Replace == nil)
+}
+
+// reInternalPackage matches the import path of internal packages, that can't be imported by the notebook.
+var reInternalPackage = regexp.MustCompile(`(^|/)internal(/|$)`)
+
+// listSearchPackages lists the packages of the standard library and the ones the notebook depends on.
+func (s *State) listSearchPackages() ([]*searchPackage, error) {
+	cmd := s.goCommand("list", "-e", "-json=ImportPath,Dir,Name,Standard,GoFiles,Module", "std", "all")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list packages with %q: %s", cmd, stderr.String())
+	}
+	var packages []*searchPackage
+	seen := make(map[string]bool)
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		pkg := &searchPackage{}
+		if err = decoder.Decode(pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the output of %q", cmd)
+		}
+		if seen[pkg.ImportPath] || pkg.Name == "main" || len(pkg.GoFiles) == 0 ||
+			reInternalPackage.MatchString(pkg.ImportPath) || strings.HasPrefix(pkg.ImportPath, "vendor/") {
+			continue
+		}
+		seen[pkg.ImportPath] = true
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// packageSymbols returns the exported symbols of the package.
+func packageSymbols(pkg *searchPackage) ([]searchSymbol, error) {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(pkg.GoFiles))
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(fset, path.Join(pkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse package %q", pkg.ImportPath)
+		}
+		files = append(files, f)
+	}
+	docPkg, err := doc.NewFromFiles(fset, files, pkg.ImportPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to extract documentation of package %q", pkg.ImportPath)
+	}
+	var symbols []searchSymbol
+	add := func(name, kind, text string) {
+		symbols = append(symbols, searchSymbol{ImportPath: pkg.ImportPath, Package: docPkg.Name, Name: name,
+			Kind: kind, Synopsis: docPkg.Synopsis(text)})
+	}
+	addValues := func(values []*doc.Value, kind string) {
+		for _, value := range values {
+			for _, name := range value.Names {
+				if token.IsExported(name) {
+					add(name, kind, value.Doc)
+				}
+			}
+		}
+	}
+	addValues(docPkg.Consts, "const")
+	addValues(docPkg.Vars, "var")
+	for _, fn := range docPkg.Funcs {
+		add(fn.Name, "func", fn.Doc)
+	}
+	for _, t := range docPkg.Types {
+		add(t.Name, "type", t.Doc)
+		addValues(t.Consts, "const")
+		addValues(t.Vars, "var")
+		for _, fn := range t.Funcs {
+			add(fn.Name, "func", fn.Doc)
+		}
+		for _, method := range t.Methods {
+			add(t.Name+"."+method.Name, "method", method.Doc)
+		}
+	}
+	return symbols, nil
+}
+
+// Search implements `%search <regexp>`: it lists the exported symbols (of the standard library and the
+// packages the notebook depends on) whose qualified name (e.g.: `strings.Builder.WriteString`) matches
+// the regular expression, followed by those whose documentation matches it.
+func (s *State) Search(msg kernel.Message, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "invalid regular expression for %%search")
+	}
+	packages, err := s.listSearchPackages()
+	if err != nil {
+		return err
+	}
+	if s.searchIndex == nil {
+		s.searchIndex = make(map[string][]searchSymbol)
+	}
+	var byName, byDoc []searchSymbol
+	for _, pkg := range packages {
+		symbols, found := s.searchIndex[pkg.ImportPath]
+		if !found {
+			symbols, err = packageSymbols(pkg)
+			if err != nil {
+				klog.V(1).Infof("%%search: %+v", err)
+				continue
+			}
+			if pkg.immutable() {
+				s.searchIndex[pkg.ImportPath] = symbols
+			}
+		}
+		for _, symbol := range symbols {
+			if re.MatchString(symbol.Package + "." + symbol.Name) {
+				byName = append(byName, symbol)
+			} else if re.MatchString(symbol.Synopsis) {
+				byDoc = append(byDoc, symbol)
+			}
+		}
+	}
+	for _, results := range [][]searchSymbol{byName, byDoc} {
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].ImportPath != results[j].ImportPath {
+				return results[i].ImportPath < results[j].ImportPath
+			}
+			return results[i].Name < results[j].Name
+		})
+	}
+	results := append(byName, byDoc...)
+	if len(results) == 0 {
+		return kernel.PublishMarkdown(msg, fmt.Sprintf("No exported symbols matching `%s` in %d packages.",
+			pattern, len(packages)))
+	}
+	return kernel.PublishHtml(msg, searchResultsHtml(results, len(packages)))
+}
+
+// searchResultsHtml renders the results of `%search` as a table, up to MaxSearchResults.
+func searchResultsHtml(results []searchSymbol, numPackages int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<p>%d symbols found in %d packages", len(results), numPackages))
+	if len(results) > MaxSearchResults {
+		sb.WriteString(fmt.Sprintf(", showing the first %d", MaxSearchResults))
+		results = results[:MaxSearchResults]
+	}
+	sb.WriteString(":</p>\n<table>\n<tr><th>Symbol</th><th>Kind</th><th>Import path</th><th>Synopsis</th></tr>\n")
+	for _, symbol := range results {
+		sb.WriteString(fmt.Sprintf("<tr><td><code>%s.%s</code></td><td>%s</td><td><code>%s</code></td><td>%s</td></tr>\n",
+			html.EscapeString(symbol.Package), html.EscapeString(symbol.Name), symbol.Kind,
+			html.EscapeString(symbol.ImportPath), html.EscapeString(symbol.Synopsis)))
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
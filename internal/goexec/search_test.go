@@ -0,0 +1,35 @@
+package goexec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Search(msg, `^strings\.Builder\.Write`))
+	outputs := msg.Outputs()
+	require.Len(t, outputs, 1)
+	html := fmt.Sprint(outputs[0]["data"])
+	assert.Contains(t, html, "<code>strings.Builder.WriteString</code>")
+	assert.Contains(t, html, "method")
+	assert.NotContains(t, html, "bytes.Buffer")
+	assert.NotEmpty(t, s.searchIndex["strings"], "standard library packages are indexed only once")
+
+	// Matching the documentation.
+	require.NoError(t, s.Search(msg, `Fields splits the string`))
+	assert.Contains(t, fmt.Sprint(msg.Outputs()[1]["data"]), "<code>strings.Fields</code>")
+
+	require.Error(t, s.Search(msg, `(`))
+}
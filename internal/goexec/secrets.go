@@ -0,0 +1,135 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%secret get <NAME>`: it reads a secret (e.g.: an API token) from the environment of
+// the kernel, the OS keychain or a configured command (e.g.: of a vault), and injects it as an environment
+// variable only in the processes executed by the cell (the program and the shell commands). The value is
+// masked in all outputs (see kernel.Kernel.AddSecret), so it is never saved in the notebook.
+
+// Sources of secrets.
+const (
+	SecretFromEnv      = "env"
+	SecretFromKeychain = "keychain"
+	SecretFromCommand  = "command"
+)
+
+// SecretKeychainService is the service name of the secrets stored in the OS keychain.
+const SecretKeychainService = "gonb"
+
+// keychainCommand returns the command that prints the secret stored in the OS keychain, under the service
+// SecretKeychainService and the account name.
+func keychainCommand(name string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("security", "find-generic-password", "-s", SecretKeychainService, "-a", name, "-w"), nil
+	case "linux", "freebsd", "openbsd":
+		return exec.Command("secret-tool", "lookup", "service", SecretKeychainService, "account", name), nil
+	}
+	return nil, errors.Errorf("OS keychain not supported in %s", runtime.GOOS)
+}
+
+// secretFromCommand returns the output (trimmed of spaces) of the command, or an error including its stderr.
+func secretFromCommand(cmd *exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to execute %q: %s", cmd, strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimSpace(string(output))
+	if value == "" {
+		return "", errors.Errorf("%q returned an empty secret", cmd)
+	}
+	return value, nil
+}
+
+// ReadSecret reads the secret with the given name from the source (SecretFromEnv, SecretFromKeychain or
+// SecretFromCommand). If source is empty, the configured SecretCommand is used if set, otherwise the
+// environment, and then the OS keychain are tried. It returns the source used.
+func (s *State) ReadSecret(name, source string) (value, usedSource string, err error) {
+	if source == "" {
+		if s.SecretCommand != "" {
+			source = SecretFromCommand
+		} else if _, found := os.LookupEnv(name); found {
+			source = SecretFromEnv
+		} else {
+			source = SecretFromKeychain
+		}
+	}
+	switch source {
+	case SecretFromEnv:
+		var found bool
+		value, found = os.LookupEnv(name)
+		if !found {
+			err = errors.Errorf("environment variable %q not set", name)
+		}
+	case SecretFromKeychain:
+		var cmd *exec.Cmd
+		cmd, err = keychainCommand(name)
+		if err == nil {
+			value, err = secretFromCommand(cmd)
+		}
+	case SecretFromCommand:
+		if s.SecretCommand == "" {
+			return "", source, errors.Errorf("no command configured for secrets, " +
+				"set one with `%%config secret_command=\"<command> {name}\"`")
+		}
+		shell, args := s.ShellCommand(strings.ReplaceAll(s.SecretCommand, "{name}", name))
+		value, err = secretFromCommand(exec.Command(shell, args...))
+	default:
+		err = errors.Errorf("unknown source of secrets %q, valid values are %q, %q or %q", source,
+			SecretFromEnv, SecretFromKeychain, SecretFromCommand)
+	}
+	if err != nil {
+		return "", source, errors.WithMessagef(err, "failed to read secret %q", name)
+	}
+	return value, source, nil
+}
+
+// GetSecret implements `%secret get <NAME>`: it reads the secret (see ReadSecret), registers it to be masked
+// in the outputs, and sets it as an environment variable of the processes executed by the cell.
+func (s *State) GetSecret(msg kernel.Message, name, source string) error {
+	value, usedSource, err := s.ReadSecret(name, source)
+	if err != nil {
+		return err
+	}
+	if len(value) < kernel.MinSecretLength {
+		return errors.Errorf("secret %q is too short (less than %d characters) to be masked in the outputs",
+			name, kernel.MinSecretLength)
+	}
+	msg.Kernel().AddSecret(value)
+	if s.cellSecrets == nil {
+		s.cellSecrets = make(map[string]string)
+	}
+	s.cellSecrets[name] = value
+	return kernel.PublishWriteStream(msg, kernel.StreamStderr,
+		fmt.Sprintf("* Secret %q (from %s) set for this cell.\n", name, usedSource))
+}
+
+// CellSecretsEnv returns the secrets read with `%secret get` for the current cell, as environment variables
+// ("key=value") for the processes it executes.
+func (s *State) CellSecretsEnv() []string {
+	env := make([]string, 0, len(s.cellSecrets))
+	for _, name := range SortedKeys(s.cellSecrets) {
+		env = append(env, name+"="+s.cellSecrets[name])
+	}
+	return env
+}
+
+// ResetCellSecrets forgets the secrets read for the cell: they are not injected in the next cells. It is
+// called after the cell is executed.
+func (s *State) ResetCellSecrets() {
+	s.cellSecrets = nil
+}
@@ -0,0 +1,38 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecret(t *testing.T) {
+	s := &State{}
+	t.Setenv("GONB_TEST_TOKEN", "env-token")
+	value, source, err := s.ReadSecret("GONB_TEST_TOKEN", "")
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", value)
+	assert.Equal(t, SecretFromEnv, source)
+
+	_, _, err = s.ReadSecret("GONB_TEST_TOKEN", SecretFromCommand)
+	require.ErrorContains(t, err, "no command configured")
+	_, _, err = s.ReadSecret("GONB_TEST_TOKEN", "vault")
+	require.ErrorContains(t, err, "unknown source")
+
+	// The configured command takes precedence.
+	s.SecretCommand = "echo 'cmd-{name}'"
+	value, source, err = s.ReadSecret("GONB_TEST_TOKEN", "")
+	require.NoError(t, err)
+	assert.Equal(t, "cmd-GONB_TEST_TOKEN", value)
+	assert.Equal(t, SecretFromCommand, source)
+
+	s.SecretCommand = "true"
+	_, _, err = s.ReadSecret("GONB_TEST_TOKEN", SecretFromCommand)
+	require.ErrorContains(t, err, "empty secret")
+
+	s.cellSecrets = map[string]string{"B": "2", "A": "1"}
+	assert.Equal(t, []string{"A=1", "B=2"}, s.CellSecretsEnv())
+	s.ResetCellSecrets()
+	assert.Empty(t, s.CellSecretsEnv())
+}
@@ -0,0 +1,87 @@
+package goexec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+)
+
+// This file implements the execution of selections: fragments of a cell (as sent by VS Code's "Run
+// Selection/Line") that are statements, and not declarations. They are executed as the body of an ephemeral
+// `func main()`, against the memorized declarations, and their definitions are not memorized.
+
+// selectionCode returns the Go code of the cell, with the special command lines blanked (to preserve the
+// line numbers). It returns false if the cell has a `%%` (or `%main`) line.
+func selectionCode(lines []string, skipLines Set[int]) (string, bool) {
+	var sb strings.Builder
+	for ii, line := range lines {
+		if strings.HasPrefix(line, "%main") || strings.HasPrefix(line, "%%") {
+			return "", false
+		}
+		if !skipLines.Has(ii) {
+			sb.WriteString(line)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), true
+}
+
+// parseSelection parses the code as the body of `func main()`, returning the parsed main function. The
+// body starts at line 3 of the parsed file.
+func parseSelection(fset *token.FileSet, code string) (*ast.FuncDecl, error) {
+	file, err := parser.ParseFile(fset, "", "package main\nfunc main() {\n"+code+"\n}", parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	return file.Decls[0].(*ast.FuncDecl), nil
+}
+
+// isSelection returns whether the Go code of the cell is a selection: it doesn't parse as declarations,
+// but it does as statements.
+func isSelection(lines []string, skipLines Set[int]) bool {
+	code, ok := selectionCode(lines, skipLines)
+	if !ok || strings.TrimSpace(code) == "" {
+		return false
+	}
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, "", "package main\n"+code, parser.SkipObjectResolution); err == nil {
+		return false
+	}
+	_, err := parseSelection(fset, code)
+	return err == nil
+}
+
+// printLastExpression returns the lines of the selection with its last statement wrapped in `fmt.Println()`,
+// if it is an expression whose value would otherwise be discarded (e.g.: a variable name), so executing it
+// displays its value.
+func printLastExpression(lines []string, skipLines Set[int]) []string {
+	code, _ := selectionCode(lines, skipLines)
+	fset := token.NewFileSet()
+	mainFunc, err := parseSelection(fset, code)
+	if err != nil || len(mainFunc.Body.List) == 0 {
+		return lines
+	}
+	exprStmt, ok := mainFunc.Body.List[len(mainFunc.Body.List)-1].(*ast.ExprStmt)
+	if !ok {
+		return lines
+	}
+	switch expr := exprStmt.X.(type) {
+	case *ast.CallExpr:
+		return lines
+	case *ast.UnaryExpr:
+		if expr.Op == token.ARROW {
+			return lines // Receiving from a channel.
+		}
+	}
+	const firstLine = 3 // Line of the parsed file where the selection starts.
+	start, end := fset.Position(exprStmt.Pos()), fset.Position(exprStmt.End())
+	lines = append([]string(nil), lines...)
+	endLine := end.Line - firstLine
+	lines[endLine] = lines[endLine][:end.Column-1] + ")" + lines[endLine][end.Column-1:]
+	startLine := start.Line - firstLine
+	lines[startLine] = lines[startLine][:start.Column-1] + "fmt.Println(" + lines[startLine][start.Column-1:]
+	return lines
+}
@@ -0,0 +1,56 @@
+package goexec
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSelection(t *testing.T) {
+	noSkip, skipFirst := MakeSet[int](), MakeSet[int]()
+	skipFirst.Insert(0)
+	assert.True(t, isSelection([]string{"total += x", "fmt.Println(total)"}, noSkip))
+	assert.True(t, isSelection([]string{"x"}, noSkip))
+	assert.True(t, isSelection([]string{"!echo before", "for i := range 3 {", "\tfmt.Println(i)", "}"}, skipFirst))
+
+	// Declarations, cells with `%%`, and invalid code are not selections.
+	assert.False(t, isSelection([]string{"var x = 1", "func f() {}"}, noSkip))
+	assert.False(t, isSelection([]string{"%%", "fmt.Println(x)"}, skipFirst))
+	assert.False(t, isSelection([]string{"fmt.Println(("}, noSkip))
+	assert.False(t, isSelection([]string{""}, noSkip))
+}
+
+func TestPrintLastExpression(t *testing.T) {
+	noSkip := MakeSet[int]()
+	assert.Equal(t, []string{"y := x * 2", "fmt.Println(y)"}, printLastExpression([]string{"y := x * 2", "y"}, noSkip))
+	assert.Equal(t, []string{"fmt.Println(x +", "\t1)"}, printLastExpression([]string{"x +", "\t1"}, noSkip))
+	// Calls and receives are kept as they are.
+	assert.Equal(t, []string{"f(x)"}, printLastExpression([]string{"f(x)"}, noSkip))
+	assert.Equal(t, []string{"<-done"}, printLastExpression([]string{"<-done"}, noSkip))
+}
+
+func TestCreateGoFileFromLinesSelection(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	s.CellIsSelection = true
+
+	cellLines := []string{"y := x * 2", "fmt.Println(y)"}
+	_, fileToCellLines, err := s.createGoFileFromLines(s.CodePath(), 1, cellLines, MakeSet[int](), NoCursor)
+	require.NoErrorf(t, err, "Failed createGoFileFromLines(%q)", s.CodePath())
+	contentBytes, err := os.ReadFile(s.CodePath())
+	require.NoError(t, err)
+	content := string(contentBytes)
+	assert.Contains(t, content, "func main() {\n\tflag.Parse()\n\ty := x * 2\n\tfmt.Println(y)\n")
+	for ii, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, "fmt.Println(y)") {
+			assert.Equal(t, 1, fileToCellLines[ii], "Line mapping of %q", line)
+		}
+	}
+}
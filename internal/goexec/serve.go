@@ -0,0 +1,166 @@
+package goexec
+
+import (
+	"fmt"
+	"html"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%serve` cells: the cell program (usually calling `gonbui.ServeHTTP`) is kept
+// running in the background, serving HTTP on a port managed by the kernel, until `%stop`. Its page is
+// previewed in an iframe in the cell output.
+
+const (
+	// ServeBinaryName and ServeLogName are the names of the copy of the binary being served and of its
+	// log (stdout and stderr), in State.TempDir.
+	ServeBinaryName = "gonb_serve"
+	ServeLogName    = "serve.log"
+
+	// DefaultServeURL is the default URL template of the iframe previewing a `%serve` cell, see State.ServeURL.
+	DefaultServeURL = "http://localhost:{port}/"
+
+	// ServeStartTimeout is how long to wait for the program of a `%serve` cell to accept connections.
+	ServeStartTimeout = 30 * time.Second
+)
+
+// servedProgram is the program of a `%serve` cell running in the background.
+type servedProgram struct {
+	cmd    *exec.Cmd
+	port   int
+	grpc   bool
+	exited chan struct{}
+}
+
+// serveURL returns the URL of the page served by the `%serve` cell running on the given port.
+func (s *State) serveURL(port int) string {
+	urlTemplate := s.ServeURL
+	if urlTemplate == "" {
+		urlTemplate = DefaultServeURL
+	}
+	return strings.ReplaceAll(urlTemplate, "{port}", strconv.Itoa(port))
+}
+
+// freePort returns a port currently available in localhost.
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to find a free port")
+	}
+	defer func() { _ = listener.Close() }()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Serve starts the compiled program of the cell in the background, with GONB_SERVE_ADDR set to a free
+// port, replacing any program previously served. Once it accepts connections, its page is previewed in
+// an iframe.
+func (s *State) Serve(msg kernel.Message) error {
+	if err := s.StopServing(nil); err != nil {
+		return err
+	}
+
+	// The binary is copied, so compiling the following cells doesn't interfere with the program served.
+	binaryPath := path.Join(s.TempDir, ServeBinaryName)
+	contents, err := os.ReadFile(s.BinaryPath())
+	if err != nil {
+		return errors.Wrapf(err, "failed to read compiled binary %q", s.BinaryPath())
+	}
+	if err = os.WriteFile(binaryPath, contents, 0700); err != nil {
+		return errors.Wrapf(err, "failed to copy compiled binary to %q", binaryPath)
+	}
+	logPath := path.Join(s.TempDir, ServeLogName)
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create log file %q", logPath)
+	}
+	defer func() { _ = logFile.Close() }()
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	cmd := exec.Command(binaryPath, s.Args...)
+	cmd.Dir, _ = os.Getwd()
+	cmd.Env = append(append(cmd.Environ(), protocol.GONB_SERVE_ADDR_ENV+"="+addr), s.CellSecretsEnv()...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	// Start on its own process group, so it doesn't receive the interruptions of the kernel.
+	setNewProcessGroup(cmd)
+	if err = cmd.Start(); err != nil {
+		return errors.Wrapf(err, "failed to start %q", cmd)
+	}
+	served := &servedProgram{cmd: cmd, port: port, grpc: s.CellServeGRPC, exited: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		klog.V(1).Infof("%%serve program on port %d exited: %v", port, err)
+		close(served.exited)
+	}()
+	s.served = served
+
+	// Wait for the program to accept connections.
+	deadline := time.Now().Add(ServeStartTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		select {
+		case <-served.exited:
+			s.served = nil
+			output, _ := os.ReadFile(logPath)
+			return errors.Errorf("%%serve program exited before serving on %s -- did it call "+
+				"`gonbui.ServeHTTP` or `gonbui.ServeListener`? Output:\n%s", addr, output)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			_ = s.StopServing(nil)
+			return errors.Errorf("%%serve program didn't accept connections on %s after %s", addr, ServeStartTimeout)
+		}
+	}
+
+	if served.grpc {
+		return kernel.PublishHtml(msg, fmt.Sprintf(
+			"<div>Serving gRPC on <code>%s</code> (log in <code>$GONB_TMP_DIR/%s</code>), use "+
+				"<code>%%grpc call &lt;Service&gt;/&lt;Method&gt; &lt;json&gt;</code> to send requests and "+
+				"<code>%%stop</code> to stop.</div>\n", addr, ServeLogName))
+	}
+	url := html.EscapeString(s.serveURL(port))
+	return kernel.PublishHtml(msg, fmt.Sprintf(
+		"<div>Serving on port %d (log in <code>$GONB_TMP_DIR/%s</code>), use <code>%%stop</code> to stop: "+
+			"<a href=\"%s\" target=\"_blank\">%s</a></div>\n"+
+			"<iframe src=\"%s\" style=\"width: 100%%; height: 400px; border: 1px solid lightgray; resize: vertical\"></iframe>\n",
+		port, ServeLogName, url, url, url))
+}
+
+// StopServing stops the program of the `%serve` cell running in the background, if any.
+// If msg is not nil, it reports it.
+func (s *State) StopServing(msg kernel.Message) error {
+	served := s.served
+	if served == nil {
+		if msg != nil {
+			return kernel.PublishWriteStream(msg, kernel.StreamStdout, "%stop: nothing being served.\n")
+		}
+		return nil
+	}
+	s.served = nil
+	if err := killProcessGroup(served.cmd); err != nil {
+		return errors.Wrapf(err, "failed to stop %%serve program (pid %d)", served.cmd.Process.Pid)
+	}
+	<-served.exited
+	if msg != nil {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("%%stop: stopped serving on port %d.\n", served.port))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package goexec
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe(t *testing.T) {
+	s := &State{TempDir: t.TempDir(), Package: "gonb_test"}
+	assert.Equal(t, "http://localhost:8080/", s.serveURL(8080))
+	s.ServeURL = "/proxy/{port}/"
+	assert.Equal(t, "/proxy/8080/", s.serveURL(8080))
+
+	// Nothing being served.
+	require.NoError(t, s.StopServing(nil))
+
+	// A program that exits without serving.
+	require.NoError(t, os.WriteFile(s.BinaryPath(), []byte("#!/bin/sh\necho \"not serving on $GONB_SERVE_ADDR\"\n"), 0700))
+	err := s.Serve(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not serving on 127.0.0.1:")
+	assert.Nil(t, s.served)
+}
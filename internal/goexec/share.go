@@ -0,0 +1,117 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%share`: the program of the cell (the memorized declarations plus the cell) is
+// posted to the Go Playground, and the link to it is displayed, so it can be shared with non-notebook users.
+
+const (
+	// DefaultPlaygroundURL is the default Go Playground instance used by `%share`, see State.PlaygroundURL.
+	DefaultPlaygroundURL = "https://play.golang.org"
+
+	// ShareTimeout is the maximum time to wait for the playground to reply to `%share`.
+	ShareTimeout = 30 * time.Second
+)
+
+// playgroundURL returns the URL of the Go Playground instance, without a trailing "/".
+func (s *State) playgroundURL() string {
+	url := s.PlaygroundURL
+	if url == "" {
+		url = DefaultPlaygroundURL
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// playgroundSource returns the program to share in the playground: the `main.go` generated for the cell
+// and, if it requires other modules, the `go.mod` (in the txtar format accepted by the playground).
+// Replaced modules are dropped from `go.mod`, since they are not available in the playground: they
+// are returned as warnings.
+func (s *State) playgroundSource() (source string, warnings []string, err error) {
+	mainContents, err := os.ReadFile(s.CodePath())
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to read %q", s.CodePath())
+	}
+	goModPath := path.Join(s.TempDir, "go.mod")
+	goModContents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.Parse(goModPath, goModContents, nil)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	if len(modFile.Require) == 0 {
+		return string(mainContents), nil, nil
+	}
+	for _, replace := range modFile.Replace {
+		warnings = append(warnings, fmt.Sprintf("module %s is replaced by %s, not available in the playground",
+			replace.Old.Path, replace.New.Path))
+		if err = modFile.DropReplace(replace.Old.Path, replace.Old.Version); err != nil {
+			return "", nil, errors.Wrapf(err, "failed to drop replace of %q", replace.Old.Path)
+		}
+	}
+	modFile.Cleanup()
+	goModContents, err = modFile.Format()
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to format %q", goModPath)
+	}
+	return fmt.Sprintf("%s\n-- go.mod --\n%s", mainContents, goModContents), warnings, nil
+}
+
+// postToPlayground posts the source to the share endpoint of the playground and returns the URL of the
+// shared program.
+func postToPlayground(playgroundURL, source string) (string, error) {
+	client := &http.Client{Timeout: ShareTimeout}
+	resp, err := client.Post(playgroundURL+"/share", "text/plain; charset=utf-8", strings.NewReader(source))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to post program to %q", playgroundURL)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read reply from %q", playgroundURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("playground %q failed to share the program: %s %s",
+			playgroundURL, resp.Status, bytes.TrimSpace(body))
+	}
+	id := string(bytes.TrimSpace(body))
+	if id == "" || strings.ContainsAny(id, "/ \n") {
+		return "", errors.Errorf("playground %q replied with an invalid id %q", playgroundURL, id)
+	}
+	return playgroundURL + "/p/" + id, nil
+}
+
+// Share posts the program of the cell, already composed (and fixed by `goimports`) in `main.go`, to the
+// Go Playground (see State.PlaygroundURL), and displays the link to it.
+func (s *State) Share(msg kernel.Message) error {
+	source, warnings, err := s.playgroundSource()
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, "%share: "+warning+"\n")
+	}
+	klog.V(1).Infof("%%share: posting %d bytes to %s", len(source), s.playgroundURL())
+	url, err := postToPlayground(s.playgroundURL(), source)
+	if err != nil {
+		return err
+	}
+	return kernel.PublishHtml(msg, fmt.Sprintf(`Shared in the Go Playground: <a href="%s" target="_blank">%s</a>`,
+		html.EscapeString(url), html.EscapeString(url)))
+}
@@ -0,0 +1,59 @@
+package goexec
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShare(t *testing.T) {
+	s := &State{TempDir: t.TempDir()}
+	mainGo := "package main\n\nfunc main() {}\n"
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(mainGo), 0600))
+	goMod := path.Join(s.TempDir, "go.mod")
+	require.NoError(t, os.WriteFile(goMod, []byte("module gonb_test\n\ngo 1.21\n"), 0600))
+
+	// No requirements: only the program is shared.
+	source, warnings, err := s.playgroundSource()
+	require.NoError(t, err)
+	assert.Equal(t, mainGo, source)
+	assert.Empty(t, warnings)
+
+	// Requirements are shared, without the replaced modules.
+	require.NoError(t, os.WriteFile(goMod, []byte("module gonb_test\n\ngo 1.21\n\n"+
+		"require github.com/janpfeifer/must v0.0.2\n\n"+
+		"replace github.com/janpfeifer/must => /home/user/must\n"), 0600))
+	source, warnings, err = s.playgroundSource()
+	require.NoError(t, err)
+	assert.Contains(t, source, mainGo+"\n-- go.mod --\n")
+	assert.Contains(t, source, "require github.com/janpfeifer/must v0.0.2")
+	assert.NotContains(t, source, "replace")
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "/home/user/must")
+
+	// Post to a fake playground.
+	var posted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/share" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		posted = string(body)
+		_, _ = io.WriteString(w, "AbCdEf123\n")
+	}))
+	defer server.Close()
+	url, err := postToPlayground(server.URL, mainGo)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/p/AbCdEf123", url)
+	assert.Equal(t, mainGo, posted)
+
+	_, err = postToPlayground(server.URL+"/missing", mainGo)
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package goexec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file implements the configuration of the shell that executes the `!` commands, and the
+// interpolation of memorized Go variables and constants in them.
+
+// ShellKind identifies the family of a shell, which defines how it's invoked and how values are quoted.
+type ShellKind int
+
+const (
+	// PosixShell is a POSIX compatible shell: bash, sh, zsh, dash, ksh, etc.
+	PosixShell ShellKind = iota
+
+	// FishShell is the fish shell.
+	FishShell
+
+	// PowerShell is PowerShell: `pwsh` or the Windows PowerShell `powershell`.
+	PowerShell
+
+	// CmdShell is the Windows command interpreter `cmd`.
+	CmdShell
+)
+
+// ShellKindOf returns the kind of the shell, from the name of its executable.
+func ShellKindOf(shell string) ShellKind {
+	name := strings.ToLower(shell[strings.LastIndexAny(shell, `/\`)+1:])
+	name = strings.TrimSuffix(name, ".exe")
+	switch name {
+	case "fish":
+		return FishShell
+	case "pwsh", "powershell":
+		return PowerShell
+	case "cmd":
+		return CmdShell
+	default:
+		return PosixShell
+	}
+}
+
+// shell returns the configured shell, or DefaultShell.
+func (s *State) shell() string {
+	if s.Shell == "" {
+		return DefaultShell
+	}
+	return s.Shell
+}
+
+// ShellCommand returns the command (and its arguments) that executes the `!` command line in the configured
+// shell (see State.Shell), e.g.: `/bin/bash -c <cmdLine>`, `pwsh -NoProfile -NonInteractive -Command <cmdLine>`
+// or `cmd /C <cmdLine>`.
+func (s *State) ShellCommand(cmdLine string) (command string, args []string) {
+	command = s.shell()
+	switch ShellKindOf(command) {
+	case PowerShell:
+		return command, []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", cmdLine}
+	case CmdShell:
+		return command, []string{"/C", cmdLine}
+	default:
+		return command, []string{"-c", cmdLine}
+	}
+}
+
+// ShellEnv returns the extra environment variables (in the form "key=value") of the `!` commands, on top of
+// the environment of the kernel (including the variables set with `%env`), which is always inherited: the
+// configuration of the `go` tool (see GoEnv), so `!*go get ...` behaves like the builds of the cells, and the
+// secrets read for the cell.
+func (s *State) ShellEnv() []string {
+	return append(s.GoEnv(), s.CellSecretsEnv()...)
+}
+
+// ShellQuote returns the value quoted as a single argument for the given kind of shell.
+func ShellQuote(kind ShellKind, value string) string {
+	switch kind {
+	case FishShell:
+		value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+		return "'" + value + "'"
+	case PowerShell:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case CmdShell:
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	default:
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+}
+
+// reShellPlaceholder matches the `{name}` (or `{name:q}`) placeholders of Go variables in `!` commands. The
+// preceding `$`, if any, is also matched, to leave the shell parameter expansions (`${name}`) untouched.
+var reShellPlaceholder = regexp.MustCompile(`(\$?)\{([\pL_][\pL\pN_]*)(:q)?\}`)
+
+// literalValue returns the value of a Go basic literal expression: unquoted for strings and characters, as
+// written for numbers. It returns false if the expression is not a basic literal.
+func literalValue(expr string) (string, bool) {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", false
+	}
+	lit, ok := parsed.(*ast.BasicLit)
+	if !ok {
+		return "", false
+	}
+	if lit.Kind == token.STRING || lit.Kind == token.CHAR {
+		value, err := strconv.Unquote(lit.Value)
+		return value, err == nil
+	}
+	return lit.Value, true
+}
+
+// literalDeclarations returns the values of the memorized Go variables and constants initialized with a
+// literal, by name, see literalValue.
+func (s *State) literalDeclarations() map[string]string {
+	values := make(map[string]string)
+	if s.Definitions == nil {
+		return values
+	}
+	for _, v := range s.Definitions.Variables {
+		if value, ok := literalValue(v.ValueDefinition); ok && v.Name != "_" {
+			values[v.Name] = value
+		}
+	}
+	for key, c := range s.Definitions.Constants {
+		if value, ok := literalValue(c.ValueDefinition); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// InterpolateShellVars replaces the `{name}` placeholders in the `!` command line with the values of the
+// memorized Go variables or constants with that name, if they are initialized with a literal (e.g.:
+// `var dataDir = "/data/mnist"`). Other placeholders (e.g.: `awk '{print}'`), and shell parameter expansions
+// (e.g.: `${HOME}`), are left untouched.
+//
+// Values of `{name}` are inserted as they are, and values of `{name:q}` are quoted for the configured shell
+// (see ShellQuote), so they are passed as one argument even if they contain spaces or quotes.
+func (s *State) InterpolateShellVars(cmdLine string) string {
+	values := s.literalDeclarations()
+	if len(values) == 0 {
+		return cmdLine
+	}
+	kind := ShellKindOf(s.shell())
+	return reShellPlaceholder.ReplaceAllStringFunc(cmdLine, func(placeholder string) string {
+		match := reShellPlaceholder.FindStringSubmatch(placeholder)
+		value, found := values[match[2]]
+		if !found || match[1] != "" {
+			return placeholder
+		}
+		if match[3] != "" {
+			return ShellQuote(kind, value)
+		}
+		return value
+	})
+}
@@ -0,0 +1,58 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolateShellVars(t *testing.T) {
+	s := &State{Definitions: NewDeclarations()}
+	s.Definitions.Variables["dataDir"] = &Variable{Key: "dataDir", Name: "dataDir", ValueDefinition: `"/data/my set"`}
+	s.Definitions.Variables["n"] = &Variable{Key: "n", Name: "n", ValueDefinition: "10"}
+	s.Definitions.Variables["computed"] = &Variable{Key: "computed", Name: "computed", ValueDefinition: "f()"}
+	s.Definitions.Constants["ext"] = &Constant{Key: "ext", ValueDefinition: "`.csv`"}
+
+	assert.Equal(t, `head -n 10 "/data/my set/train.csv"`,
+		s.InterpolateShellVars(`head -n {n} "{dataDir}/train{ext}"`))
+	// Unknown names, non-literal values and other braces are left untouched.
+	assert.Equal(t, `echo {computed} {unknown} | awk '{print $1}'`,
+		s.InterpolateShellVars(`echo {computed} {unknown} | awk '{print $1}'`))
+	// Shell parameter expansions are left untouched, even if a Go variable with the same name exists.
+	s.Definitions.Variables["HOME"] = &Variable{Key: "HOME", Name: "HOME", ValueDefinition: `"/go/home"`}
+	assert.Equal(t, `echo ${HOME} /go/home`, s.InterpolateShellVars(`echo ${HOME} {HOME}`))
+
+	command, args := s.ShellCommand("ls")
+	assert.Equal(t, DefaultShell, command)
+	assert.Equal(t, []string{"-c", "ls"}, args)
+	s.Shell = "/bin/zsh"
+	command, _ = s.ShellCommand("ls")
+	assert.Equal(t, "/bin/zsh", command)
+	s.Shell = "cmd.exe"
+	command, args = s.ShellCommand("dir")
+	assert.Equal(t, s.Shell, command)
+	assert.Equal(t, []string{"/C", "dir"}, args)
+	s.Shell = "pwsh"
+	_, args = s.ShellCommand("dir")
+	assert.Equal(t, []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", "dir"}, args)
+
+	// Quoted placeholders.
+	s.Definitions.Variables["quoted"] = &Variable{Key: "quoted", Name: "quoted", ValueDefinition: `"it's \\ here"`}
+	s.Shell = "/bin/bash"
+	assert.Equal(t, `ls '/data/my set' 'it'\''s \ here'`, s.InterpolateShellVars(`ls {dataDir:q} {quoted:q}`))
+	s.Shell = "/usr/bin/fish"
+	assert.Equal(t, `ls 'it\'s \\ here'`, s.InterpolateShellVars(`ls {quoted:q}`))
+	s.Shell = "pwsh"
+	assert.Equal(t, `ls 'it''s \ here'`, s.InterpolateShellVars(`ls {quoted:q}`))
+	s.Shell = `C:\Windows\System32\cmd.exe`
+	assert.Equal(t, `dir "/data/my set"`, s.InterpolateShellVars(`dir {dataDir:q}`))
+}
+
+func TestShellKindOf(t *testing.T) {
+	assert.Equal(t, PosixShell, ShellKindOf("/bin/bash"))
+	assert.Equal(t, PosixShell, ShellKindOf("zsh"))
+	assert.Equal(t, FishShell, ShellKindOf("/usr/local/bin/fish"))
+	assert.Equal(t, PowerShell, ShellKindOf("pwsh"))
+	assert.Equal(t, PowerShell, ShellKindOf(`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`))
+	assert.Equal(t, CmdShell, ShellKindOf(`C:\Windows\System32\cmd.exe`))
+}
@@ -0,0 +1,89 @@
+package goexec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the kernel side of the chart snapshots (see `gonbui.SnapshotJavascript`): the
+// interactive charts rendered in the front-end send a PNG snapshot of themselves, and the kernel updates their
+// outputs to include it, as an `<img>` in the HTML (shown where scripts don't run, e.g. GitHub previews) and
+// as the "image/png" version (used e.g. when exporting to PDF).
+
+// chartSnapshotEnv returns the environment variables for the execution of the cell programs, if the
+// charts snapshots are enabled (with `%config chart_snapshot=on`).
+func (s *State) chartSnapshotEnv() []string {
+	if !s.ChartSnapshot {
+		return nil
+	}
+	return []string{protocol.GONB_CHART_SNAPSHOT_ENV + "=true"}
+}
+
+// chartSnapshot is the snapshot of a chart, sent to protocol.GonbuiSnapshotAddress.
+type chartSnapshot struct {
+	DisplayId string `json:"display_id"`
+	Html      string `json:"html"`
+	Text      string `json:"text"`
+	Png       string `json:"png"` // Data URL, "data:image/png;base64,...".
+}
+
+// pngSignature are the first bytes of PNG files.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// snapshotDisplayData returns the data to update the output of the chart with its snapshot: the HTML with
+// protocol.SnapshotPlaceholder replaced by the image, the image and the text.
+func snapshotDisplayData(snapshot *chartSnapshot) (kernel.Data, error) {
+	if snapshot.DisplayId == "" {
+		return kernel.Data{}, errors.New("chart snapshot without a display id")
+	}
+	encoded, found := strings.CutPrefix(snapshot.Png, "data:image/png;base64,")
+	if !found {
+		return kernel.Data{}, errors.Errorf("chart snapshot of %q is not a PNG data URL", snapshot.DisplayId)
+	}
+	png, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !bytes.HasPrefix(png, pngSignature) {
+		return kernel.Data{}, errors.Errorf("chart snapshot of %q is not a valid PNG image", snapshot.DisplayId)
+	}
+	img := fmt.Sprintf(`<img src="%s" alt="%s">`, snapshot.Png, html.EscapeString(snapshot.Text))
+	data := kernel.Data{
+		Data: kernel.MIMEMap{
+			string(protocol.MIMETextHTML): strings.Replace(snapshot.Html, protocol.SnapshotPlaceholder, img, 1),
+			string(protocol.MIMEImagePNG): encoded,
+		},
+		Transient: kernel.MIMEMap{"display_id": snapshot.DisplayId},
+	}
+	if snapshot.Text != "" {
+		data.Data[string(protocol.MIMETextPlain)] = snapshot.Text
+	}
+	return data, nil
+}
+
+// handleChartSnapshot updates the output of a chart with its snapshot, sent to protocol.GonbuiSnapshotAddress,
+// registered with comms.State.HandleAddressPrefix.
+func (s *State) handleChartSnapshot(msg kernel.Message, address string, value any) {
+	var snapshot chartSnapshot
+	encoded, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(encoded, &snapshot)
+	}
+	if err != nil {
+		klog.Warningf("Invalid chart snapshot sent to %q: %+v", address, err)
+		return
+	}
+	data, err := snapshotDisplayData(&snapshot)
+	if err == nil {
+		err = kernel.PublishUpdateDisplayData(msg, data)
+	}
+	if err != nil {
+		klog.Warningf("Failed to update the chart with its snapshot: %+v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package goexec
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleChartSnapshot(t *testing.T) {
+	s := &State{}
+	assert.Empty(t, s.chartSnapshotEnv())
+	s.ChartSnapshot = true
+	assert.Equal(t, []string{"GONB_CHART_SNAPSHOT=true"}, s.chartSnapshotEnv())
+
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	png := base64.StdEncoding.EncodeToString(append(pngSignature, 0, 1, 2))
+	s.handleChartSnapshot(msg, protocol.GonbuiSnapshotAddress, map[string]any{
+		"display_id": "gonb_chart_1",
+		"html":       `<div id="1">` + protocol.SnapshotPlaceholder + `</div><script>plot()</script>`,
+		"text":       "[Plotly figure with 1 trace(s)]",
+		"png":        "data:image/png;base64," + png,
+	})
+	require.Len(t, msg.Outputs(), 1)
+	data := msg.Outputs()[0]["data"].(kernel.MIMEMap)
+	assert.Equal(t, `<div id="1"><img src="data:image/png;base64,`+png+`" alt="[Plotly figure with 1 trace(s)]"></div>`+
+		`<script>plot()</script>`, data["text/html"])
+	assert.Equal(t, png, data["image/png"])
+	assert.Equal(t, "[Plotly figure with 1 trace(s)]", data["text/plain"])
+
+	// Invalid snapshots are ignored.
+	_, err = snapshotDisplayData(&chartSnapshot{DisplayId: "gonb_chart_1", Png: "data:image/jpeg;base64," + png})
+	assert.ErrorContains(t, err, "not a PNG data URL")
+	_, err = snapshotDisplayData(&chartSnapshot{DisplayId: "gonb_chart_1", Png: "data:image/png;base64,bm90IGEgcG5n"})
+	assert.ErrorContains(t, err, "not a valid PNG image")
+	s.handleChartSnapshot(msg, protocol.GonbuiSnapshotAddress, map[string]any{"png": "data:image/png;base64," + png})
+	assert.Len(t, msg.Outputs(), 1)
+}
@@ -0,0 +1,215 @@
+package goexec
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"golang.org/x/mod/module"
+	"k8s.io/klog/v2"
+)
+
+// This file renders the stack trace of a program that panicked as HTML, to make it navigable: frames in the
+// notebook code are annotated with their cell and line, and frames in external modules (or in the standard
+// library) link to their documentation in pkg.go.dev.
+
+// PkgGoDevURL is the site with the documentation of the Go packages, linked from the stack traces.
+const PkgGoDevURL = "https://pkg.go.dev/"
+
+// maxStackTrace is the maximum number of bytes of a stack trace captured by stackTraceCollector.
+const maxStackTrace = 64 * 1024
+
+// stackTraceCollector is an io.Writer that captures the stack trace printed to stderr by a program that
+// panicked: everything from the `panic: ...` (or `fatal error: ...`) line on, up to maxStackTrace bytes.
+type stackTraceCollector struct {
+	mu        sync.Mutex
+	partial   string // Last line written, if not yet complete.
+	capturing bool
+	trace     strings.Builder
+}
+
+// Write implements io.Writer.
+func (c *stackTraceCollector) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.trace.Len() >= maxStackTrace {
+		return len(p), nil
+	}
+	lines := strings.Split(c.partial+string(p), "\n")
+	c.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		if !c.capturing && (strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ")) {
+			c.capturing = true
+		}
+		if c.capturing && c.trace.Len() < maxStackTrace {
+			c.trace.WriteString(line)
+			c.trace.WriteByte('\n')
+		}
+	}
+	return len(p), nil
+}
+
+// Trace returns the stack trace captured, or "" if the program didn't panic.
+func (c *stackTraceCollector) Trace() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capturing && c.partial != "" && c.trace.Len() < maxStackTrace {
+		return c.trace.String() + c.partial
+	}
+	return c.trace.String()
+}
+
+// stackFrame is one frame of the stack trace of a goroutine.
+type stackFrame struct {
+	Function string // E.g.: "github.com/user/module/pkg.(*Type).Method(...)".
+	File     string
+	Line     int
+}
+
+// reStackFrameLocation matches the location line of a stack frame, e.g.: "\t/path/to/file.go:12 +0x1d".
+var reStackFrameLocation = regexp.MustCompile(`^\t(.+\.(?:go|s)):(\d+)(?: \+0x[0-9a-f]+)?$`)
+
+// parseStackTrace splits the stack trace of the panicking goroutine (the first one) into the lines before
+// it (the `panic: ...` message and the goroutine header) and its frames. It returns no frames if it doesn't
+// recognize the stack trace.
+func parseStackTrace(trace string) (header []string, frames []stackFrame) {
+	lines := strings.Split(trace, "\n")
+	ii := 0
+	for ; ii < len(lines) && !strings.HasPrefix(lines[ii], "goroutine "); ii++ {
+		header = append(header, lines[ii])
+	}
+	if ii == len(lines) {
+		return header, nil
+	}
+	header = append(header, lines[ii])
+	for ii++; ii+1 < len(lines) && lines[ii] != ""; ii += 2 {
+		matches := reStackFrameLocation.FindStringSubmatch(lines[ii+1])
+		if matches == nil {
+			break
+		}
+		lineNum, _ := strconv.Atoi(matches[2])
+		frames = append(frames, stackFrame{Function: lines[ii], File: matches[1], Line: lineNum})
+	}
+	return
+}
+
+// reModuleCacheVersion matches the version of the module in the path of a file in the module cache.
+var reModuleCacheVersion = regexp.MustCompile(`@(v[0-9][^/\\]*)[/\\]`)
+
+// funcPackageAndSymbol splits the function of a stack frame (e.g.: `github.com/user/pkg.(*Type).Method(...)`,
+// or `created by pkg.Func in goroutine 1`) into its package path and the documented symbol (e.g.:
+// `Type.Method`). symbol is empty if the function is not exported (or it's a method of a non-exported type).
+func funcPackageAndSymbol(function string) (pkgPath, symbol string) {
+	function = strings.TrimPrefix(function, "created by ")
+	if idx := strings.Index(function, " in goroutine "); idx >= 0 {
+		function = function[:idx]
+	}
+	if strings.HasSuffix(function, ")") {
+		if idx := strings.LastIndex(function, "("); idx > 0 {
+			function = function[:idx]
+		}
+	}
+	lastSlash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[lastSlash+1:], ".")
+	if dot < 0 {
+		return "", ""
+	}
+	pkgPath = function[:lastSlash+1+dot]
+	name := function[lastSlash+1+dot+1:]
+	name = strings.NewReplacer("(*", "", "(", "", ")", "").Replace(name)
+	if idx := strings.Index(name, "["); idx >= 0 {
+		// Generic functions, e.g.: `Map[...]`.
+		if end := strings.Index(name, "]"); end > idx {
+			name = name[:idx] + name[end+1:]
+		}
+	}
+	var parts []string
+	for _, part := range strings.Split(name, ".") {
+		if part == "" || !unicode.IsUpper([]rune(part)[0]) {
+			// Closures (`func1`), wrappers (`gowrap1`) or non-exported names.
+			break
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return pkgPath, strings.Join(parts, ".")
+}
+
+// frameDocURL returns the link to the documentation in pkg.go.dev of the function of the stack frame, or ""
+// if it's not in an external module or in the standard library.
+func frameDocURL(frame stackFrame) string {
+	pkgPath, symbol := funcPackageAndSymbol(frame.Function)
+	if pkgPath == "" || pkgPath == "main" {
+		return ""
+	}
+	url := PkgGoDevURL + pkgPath
+	if firstElem, _, _ := strings.Cut(pkgPath, "/"); strings.Contains(firstElem, ".") {
+		// Package of a module: use the version from its path in the module cache, if there.
+		if matches := reModuleCacheVersion.FindStringSubmatch(frame.File); matches != nil {
+			if version, err := module.UnescapeVersion(matches[1]); err == nil {
+				url = PkgGoDevURL + pkgPath + "@" + version
+			}
+		}
+	}
+	if symbol != "" {
+		url += "#" + symbol
+	}
+	return url
+}
+
+// stackTraceHTML renders the stack trace: frames in the file mainPath are annotated with their cell and
+// line (see fileToCellIdAndLine), and the others link to their documentation (see frameDocURL).
+func stackTraceHTML(header []string, frames []stackFrame, mainPath string, fileToCellIdAndLine []CellIdAndLine) string {
+	var parts []string
+	parts = append(parts, `<details open class="gonb-trace"><summary>Stack trace of the panic</summary>`)
+	parts = append(parts, `<div style="font-family: monospace; white-space: pre;">`)
+	for _, line := range header {
+		parts = append(parts, html.EscapeString(line))
+	}
+	for _, frame := range frames {
+		location := html.EscapeString(fmt.Sprintf("%s:%d", frame.File, frame.Line))
+		lineIdx := frame.Line - 1
+		if frame.File == mainPath && lineIdx >= 0 && lineIdx < len(fileToCellIdAndLine) &&
+			fileToCellIdAndLine[lineIdx].Line != NoCursorLine {
+			cell := fileToCellIdAndLine[lineIdx]
+			cellInfo := fmt.Sprintf("Cell Line %d", cell.Line+1)
+			if cell.Id != -1 {
+				cellInfo = fmt.Sprintf("Cell[%d]: Line %d", cell.Id, cell.Line+1)
+			}
+			parts = append(parts, fmt.Sprintf(`<b>%s</b>`, html.EscapeString(frame.Function)),
+				fmt.Sprintf(`	<span class="gonb-cell-line-info" style="background: var(--jp-layout-color2);">%s</span> `+
+					`<span style="color: var(--jp-content-font-color2);">%s</span>`, cellInfo, location))
+			continue
+		}
+		function := html.EscapeString(frame.Function)
+		if url := frameDocURL(frame); url != "" {
+			function = fmt.Sprintf(`<a href="%s" target="_blank">%s</a>`, html.EscapeString(url), function)
+		}
+		parts = append(parts, function,
+			fmt.Sprintf(`	<span style="color: var(--jp-content-font-color2);">%s</span>`, location))
+	}
+	parts = append(parts, `</div></details>`)
+	return strings.Join(parts, "\n")
+}
+
+// publishStackTrace displays the stack trace captured by the collector, if the program panicked, as HTML
+// (see stackTraceHTML). It's not displayed if the State was created with rawError, to keep outputs as text.
+func (s *State) publishStackTrace(msg kernel.Message, collector *stackTraceCollector, fileToCellIdAndLine []CellIdAndLine) {
+	if s.rawError {
+		return
+	}
+	header, frames := parseStackTrace(collector.Trace())
+	if len(frames) == 0 {
+		return
+	}
+	if err := kernel.PublishHtml(msg, stackTraceHTML(header, frames, s.CodePath(), fileToCellIdAndLine)); err != nil {
+		klog.Warningf("Failed to publish the stack trace: %+v", err)
+	}
+}
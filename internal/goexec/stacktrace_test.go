@@ -0,0 +1,74 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleStackTrace = `panic: runtime error: index out of range [3] with length 2
+
+goroutine 1 [running]:
+github.com/BurntSushi/toml.(*Decoder).Decode(0xc000012345?, {0x4b1f00, 0xc000010000})
+	/home/user/go/pkg/mod/github.com/!burnt!sushi/toml@v1.3.2/decode.go:120 +0x1d
+strings.Map(...)
+	/usr/local/go/src/strings/strings.go:500
+main.process.func1()
+	/tmp/gonb_1234/main.go:6 +0x25
+main.main()
+	/tmp/gonb_1234/main.go:9 +0x40
+
+goroutine 7 [chan receive]:
+main.worker()
+	/tmp/gonb_1234/main.go:20 +0x40
+exit status 2
+`
+
+func TestStackTrace(t *testing.T) {
+	collector := &stackTraceCollector{}
+	// Writes split in the middle of lines.
+	for _, part := range []string{"output of the program\npan", "ic: runtime", sampleStackTrace[len("panic: runtime"):]} {
+		n, err := collector.Write([]byte(part))
+		require.NoError(t, err)
+		require.Equal(t, len(part), n)
+	}
+	trace := collector.Trace()
+	assert.Equal(t, sampleStackTrace, trace)
+
+	header, frames := parseStackTrace(trace)
+	assert.Equal(t, []string{"panic: runtime error: index out of range [3] with length 2", "", "goroutine 1 [running]:"}, header)
+	require.Len(t, frames, 4) // Only the panicking goroutine.
+	assert.Equal(t, "/tmp/gonb_1234/main.go", frames[2].File)
+	assert.Equal(t, 6, frames[2].Line)
+
+	assert.Equal(t, "https://pkg.go.dev/github.com/BurntSushi/toml@v1.3.2#Decoder.Decode", frameDocURL(frames[0]))
+	assert.Equal(t, "https://pkg.go.dev/strings#Map", frameDocURL(frames[1]))
+	assert.Equal(t, "", frameDocURL(frames[2]))
+
+	pkgPath, symbol := funcPackageAndSymbol("created by golang.org/x/sync/errgroup.(*Group).Go in goroutine 1")
+	assert.Equal(t, "golang.org/x/sync/errgroup", pkgPath)
+	assert.Equal(t, "Group.Go", symbol)
+	pkgPath, symbol = funcPackageAndSymbol("github.com/user/lib.Map[...].func2(...)")
+	assert.Equal(t, "github.com/user/lib", pkgPath)
+	assert.Equal(t, "Map", symbol)
+	_, symbol = funcPackageAndSymbol("github.com/user/lib.(*state).run()")
+	assert.Equal(t, "", symbol)
+
+	fileToCellIdAndLine := make([]CellIdAndLine, 10)
+	for ii := range fileToCellIdAndLine {
+		fileToCellIdAndLine[ii] = CellIdAndLine{Id: -1, Line: NoCursorLine}
+	}
+	fileToCellIdAndLine[5] = CellIdAndLine{Id: 3, Line: 1}
+	report := stackTraceHTML(header, frames, "/tmp/gonb_1234/main.go", fileToCellIdAndLine)
+	assert.Contains(t, report, `<a href="https://pkg.go.dev/github.com/BurntSushi/toml@v1.3.2#Decoder.Decode" target="_blank">`)
+	assert.Contains(t, report, `Cell[3]: Line 2</span>`)
+	// Line 9 is not mapped to a cell: it's not annotated.
+	assert.Contains(t, report, "main.main()\n\t<span style=\"color: var(--jp-content-font-color2);\">/tmp/gonb_1234/main.go:9</span>")
+	assert.Contains(t, report, "index out of range [3] with length 2")
+
+	// No panic, no trace.
+	collector = &stackTraceCollector{}
+	_, _ = collector.Write([]byte("exit status 1\n"))
+	assert.Empty(t, collector.Trace())
+}
@@ -0,0 +1,359 @@
+package goexec
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the hints of stale cells: for each cell executed, the memorized declarations it
+// uses (but doesn't define) are recorded, and when a later cell changes one of them, a transient note lists
+// the cells executed before that use it, since their results may be stale.
+//
+// In the (experimental) reactive mode, enabled with State.Reactive, the stale cells are re-executed instead,
+// in the order they were first executed, which is a topological order of the references between cells.
+
+// cellReferences are the memorized declarations used by a cell, see State.recordCellReferences.
+type cellReferences struct {
+	key     string      // Key of the cell in State.cellRefs, see State.cellKey.
+	id      int         // Cell id (execution count) of the last execution of the cell.
+	keys    Set[string] // Keys of the declarations used.
+	defines []string    // Keys of the declarations defined.
+
+	// lines and skipLines of the cell, to re-execute it in the reactive mode, if reactive is set: cells
+	// with special flags (e.g.: `%test` or `%wasm`) are not re-executed.
+	lines     []string
+	skipLines Set[int]
+	reactive  bool
+}
+
+// cellIdentifiers returns the identifiers in the Go code of the cell, except in the lines in skipLines
+// (special commands).
+func cellIdentifiers(lines []string, skipLines Set[int]) Set[string] {
+	var code strings.Builder
+	for ii, line := range lines {
+		if !skipLines.Has(ii) {
+			code.WriteString(line)
+		}
+		code.WriteByte('\n')
+	}
+	src := []byte(code.String())
+	fileSet := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fileSet.AddFile("", fileSet.Base(), len(src)), src, nil, 0) // Errors are ignored.
+	identifiers := MakeSet[string]()
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.IDENT {
+			identifiers.Insert(lit)
+		}
+	}
+	return identifiers
+}
+
+// declarationDefinitions returns the definitions of the functions (including methods), variables, types
+// and constants in decls, by their keys. Imports are not included.
+func declarationDefinitions(decls *Declarations) map[string]string {
+	definitions := make(map[string]string)
+	for key, f := range decls.Functions {
+		if key != "main" {
+			definitions[key] = f.Definition
+		}
+	}
+	for key, v := range decls.Variables {
+		if !strings.HasPrefix(key, "_~") {
+			definitions[key] = v.TypeDefinition + " = " + v.ValueDefinition
+		}
+	}
+	for key, t := range decls.Types {
+		definitions[key] = t.TypeDefinition
+	}
+	for key, c := range decls.Constants {
+		definitions[key] = c.TypeDefinition + " = " + c.ValueDefinition
+	}
+	return definitions
+}
+
+// declarationCellIds returns the cell ids where the declarations in decls were defined, by their keys.
+func declarationCellIds(decls *Declarations) map[string]int {
+	ids := make(map[string]int)
+	for key, f := range decls.Functions {
+		ids[key] = f.Id
+	}
+	for key, v := range decls.Variables {
+		ids[key] = v.Id
+	}
+	for key, t := range decls.Types {
+		ids[key] = t.Id
+	}
+	for key, c := range decls.Constants {
+		ids[key] = c.Id
+	}
+	return ids
+}
+
+// dependentDeclarations returns the keys in changed, plus the ones of the declarations (with the given
+// definitions) that use them, directly or indirectly. E.g.: if `y` is defined as `var y = foo()`, and `foo`
+// changed, `y` also changed.
+func dependentDeclarations(definitions map[string]string, changed Set[string]) Set[string] {
+	identifierKeys := make(map[string][]string)
+	for key := range definitions {
+		identifierKeys[keyIdentifier(key)] = append(identifierKeys[keyIdentifier(key)], key)
+	}
+	usedBy := make(map[string][]string)
+	for key, definition := range definitions {
+		for identifier := range cellIdentifiers([]string{definition}, nil) {
+			for _, used := range identifierKeys[identifier] {
+				if used != key {
+					usedBy[used] = append(usedBy[used], key)
+				}
+			}
+		}
+	}
+	dependents := MakeSet[string]()
+	toVisit := Keys(changed)
+	for len(toVisit) > 0 {
+		key := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+		if dependents.Has(key) {
+			continue
+		}
+		dependents.Insert(key)
+		toVisit = append(toVisit, usedBy[key]...)
+	}
+	return dependents
+}
+
+// keyIdentifier returns the identifier used to refer to the declaration with the given key: for methods
+// (keys `<type>~<method>`) it's the name of the method.
+func keyIdentifier(key string) string {
+	if idx := strings.LastIndex(key, "~"); idx >= 0 {
+		return key[idx+1:]
+	}
+	return key
+}
+
+// cellKey returns the key of the notebook cell being executed, used to replace the references recorded for
+// its previous executions: the cell id sent by the front-end ("cellId" in the metadata of the request, sent
+// by JupyterLab and Notebook 7), or otherwise the code of the cell.
+func cellKey(msg kernel.Message, lines []string) string {
+	if msg != nil {
+		if id, _ := msg.ComposedMsg().Metadata["cellId"].(string); id != "" {
+			return "id:" + id
+		}
+	}
+	return "source:" + strings.Join(lines, "\n")
+}
+
+// recordCellReferences records the memorized declarations (in decls) used by the cell with the given key
+// (see cellKey), replacing the ones of its previous execution, and returns the ones used by cells executed
+// before, that changed from the previously memorized declarations (s.Definitions), or that use the ones that
+// changed.
+//
+// If the front-end doesn't send the cell id, the cells are keyed by their code, and the references of an
+// edited cell are replaced by the ones of the cell that defines the same declarations.
+func (s *State) recordCellReferences(key string, cellId int, lines []string, skipLines Set[int], decls *Declarations) (stale map[int][]string) {
+	if s.cellRefs == nil {
+		s.cellRefs = make(map[string]*cellReferences)
+	}
+
+	// Declarations used by the cell.
+	cellIds := declarationCellIds(decls)
+	identifierKeys := make(map[string][]string)
+	var defines []string
+	for key, id := range cellIds {
+		if id == cellId {
+			defines = append(defines, key)
+		} else {
+			identifierKeys[keyIdentifier(key)] = append(identifierKeys[keyIdentifier(key)], key)
+		}
+	}
+	refs := &cellReferences{key: key, defines: defines,
+		id: cellId, keys: MakeSet[string](), lines: lines, skipLines: skipLines,
+		reactive: !(s.CellIsTest || s.CellIsWasm || s.CellServe || s.CellServeGRPC || s.CellRunCLI || s.CellShare),
+	}
+	for identifier := range cellIdentifiers(lines, skipLines) {
+		for _, key := range identifierKeys[identifier] {
+			refs.keys.Insert(key)
+		}
+	}
+	delete(s.cellRefs, key)
+	delete(s.cellRefs, cellKey(nil, lines)) // Recorded before the front-end sent the cell id.
+	if len(defines) > 0 {
+		// Previous versions of the cell, before it was edited, keyed by their code.
+		for otherKey, other := range s.cellRefs {
+			if strings.HasPrefix(otherKey, "source:") && sameKeys(other.defines, defines) {
+				delete(s.cellRefs, otherKey)
+			}
+		}
+	}
+
+	// Declarations changed by the cell, used by cells executed before.
+	previous := declarationDefinitions(s.Definitions)
+	definitions := declarationDefinitions(decls)
+	changed := MakeSet[string]()
+	for key, definition := range definitions {
+		if previousDefinition, found := previous[key]; found && previousDefinition != definition {
+			changed.Insert(key)
+		}
+	}
+	if len(changed) > 0 {
+		changed = dependentDeclarations(definitions, changed)
+		for _, other := range s.cellRefs {
+			for key := range other.keys {
+				if changed.Has(key) {
+					if stale == nil {
+						stale = make(map[int][]string)
+					}
+					stale[other.id] = append(stale[other.id], key)
+				}
+			}
+		}
+	}
+
+	if len(refs.keys) > 0 {
+		s.cellRefs[key] = refs
+	}
+	return
+}
+
+// sameKeys returns whether the two lists of declaration keys have the same elements.
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := MakeSet[string](len(a))
+	for _, key := range a {
+		set.Insert(key)
+	}
+	for _, key := range b {
+		if !set.Has(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// staleCellsNote returns the note listing the cells (and the declarations they use) that may be stale.
+func staleCellsNote(stale map[int][]string) string {
+	ids := SortedKeys(stale)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys := make([]string, 0, len(stale[id]))
+		for _, key := range stale[id] {
+			keys = append(keys, "`"+strings.Replace(key, "~", ".", 1)+"`")
+		}
+		sort.Strings(keys)
+		parts = append(parts, fmt.Sprintf("[%d] (%s)", id, strings.Join(keys, ", ")))
+	}
+	return fmt.Sprintf("* Declarations changed by this cell are used by cells executed before, whose results "+
+		"may be stale: %s.\n", strings.Join(parts, ", "))
+}
+
+// publishStaleCells records the declarations used by the cell and, if StaleHints is enabled, publishes a
+// transient note listing the cells executed before whose results may be stale, because they use declarations
+// changed by the cell. The note is erased when the next cell is executed, so it is not saved in the notebook.
+// It must be called before the updated declarations (decls) are memorized.
+//
+// In the reactive mode, the stale cells that can be re-executed are instead scheduled for re-execution,
+// see State.reexecuteStaleCells.
+func (s *State) publishStaleCells(msg kernel.Message, cellId int, lines []string, skipLines Set[int], decls *Declarations) {
+	if s.staleHintDisplayId != "" {
+		_ = kernel.PublishUpdateDisplayData(msg, kernel.Data{
+			Data:      kernel.MIMEMap{string(protocol.MIMETextPlain): ""},
+			Metadata:  make(kernel.MIMEMap),
+			Transient: kernel.MIMEMap{"display_id": s.staleHintDisplayId},
+		})
+		s.staleHintDisplayId = ""
+	}
+	key := s.reexecutingCellKey
+	if key == "" {
+		key = cellKey(msg, lines)
+	}
+	stale := s.recordCellReferences(key, cellId, lines, skipLines, decls)
+	if s.Reactive {
+		for id := range stale {
+			if refs := s.cellReferencesById(id); refs != nil && refs.reactive {
+				s.reactivePending.Insert(id)
+				delete(stale, id)
+			}
+		}
+	}
+	if len(stale) == 0 || !s.StaleHints {
+		return
+	}
+	s.staleHintDisplayId = "gonb_stale_" + UniqueId()
+	_ = kernel.PublishUpdateDisplayData(msg, kernel.Data{
+		Data:      kernel.MIMEMap{string(protocol.MIMETextPlain): staleCellsNote(stale)},
+		Metadata:  make(kernel.MIMEMap),
+		Transient: kernel.MIMEMap{"display_id": s.staleHintDisplayId},
+	})
+}
+
+// cellReferencesById returns the references recorded for the last execution with the cell id, or nil.
+func (s *State) cellReferencesById(cellId int) *cellReferences {
+	for _, refs := range s.cellRefs {
+		if refs.id == cellId {
+			return refs
+		}
+	}
+	return nil
+}
+
+// redefinedDeclaration returns the key of a declaration defined by the cell that was redefined by another
+// cell, and the id of that cell, or "" if there is none.
+func (s *State) redefinedDeclaration(refs *cellReferences) (key string, cellId int) {
+	ids := declarationCellIds(s.Definitions)
+	for _, key := range refs.defines {
+		if id, found := ids[key]; found && id != refs.id {
+			return key, id
+		}
+	}
+	return "", 0
+}
+
+// reexecuteStaleCells re-executes, in the reactive mode, the cells scheduled by State.publishStaleCells,
+// in the order they were executed: since cells can only use declarations of cells executed before them,
+// this is a topological order of the references. Cells made stale by the re-executed ones are scheduled
+// in turn. It stops at the first cell that fails.
+//
+// Only the last version of each cell is re-executed, and its references are recorded under its own key
+// (and not the one of the cell that triggered the re-execution).
+func (s *State) reexecuteStaleCells(msg kernel.Message) error {
+	executed := MakeSet[int]()
+	for len(s.reactivePending) > 0 {
+		cellId := SortedKeys(s.reactivePending)[0]
+		s.reactivePending.Delete(cellId)
+		refs := s.cellReferencesById(cellId)
+		if executed.Has(cellId) || refs == nil {
+			continue
+		}
+		executed.Insert(cellId)
+		if key, redefinedBy := s.redefinedDeclaration(refs); key != "" {
+			// Re-executing it would revert the declaration to its definition in this cell.
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+				"* Reactive mode: cell [%d] not re-executed, since it defines `%s`, redefined by cell [%d]: "+
+					"its results may be stale.\n", cellId, strings.Replace(key, "~", ".", 1), redefinedBy))
+			continue
+		}
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("* Reactive mode: re-executing cell [%d].\n", cellId))
+		s.reexecutingCellKey = refs.key
+		err := s.executeCellImpl(msg, cellId, refs.lines, refs.skipLines)
+		s.reexecutingCellKey = ""
+		if err != nil {
+			return errors.WithMessagef(err, "reactive mode: failed to re-execute cell [%d]", cellId)
+		}
+	}
+	return nil
+}
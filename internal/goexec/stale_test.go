@@ -0,0 +1,169 @@
+package goexec
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCellIdentifiers(t *testing.T) {
+	lines := []string{"%%", `fmt.Println(foo(x), "bar") // baz`, "!echo qux"}
+	skipLines := MakeSet[int]()
+	skipLines.Insert(0)
+	skipLines.Insert(2)
+	assert.Equal(t, []string{"Println", "fmt", "foo", "x"}, SortedKeys(cellIdentifiers(lines, skipLines)))
+}
+
+func TestStaleCells(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	// withFoo returns the memorized declarations with the function `foo` (re-)defined in the cell.
+	withFoo := func(cellId int, definition string) *Declarations {
+		decls := s.Definitions.Copy()
+		decls.Functions["foo"] = &Function{CellLines: CellLines{Id: cellId}, Key: "foo", Definition: definition}
+		return decls
+	}
+	skipLines := MakeSet[int]()
+	skipLines.Insert(0)
+	execute := func(cellId int, lines []string, decls *Declarations) map[int][]string {
+		stale := s.recordCellReferences(cellKey(nil, lines), cellId, lines, skipLines, decls)
+		s.Definitions = decls
+		return stale
+	}
+	fooCell := []string{"", "func foo() int { return 1 }"}
+	useCell := []string{"%%", "fmt.Println(foo(), x)", "var y = T{}.Bar()"}
+
+	decls := withFoo(1, "func foo() int { return 1 }")
+	decls.Variables["x"] = &Variable{CellLines: CellLines{Id: 1}, Key: "x", Name: "x", ValueDefinition: "1"}
+	decls.Functions["T~Bar"] = &Function{CellLines: CellLines{Id: 1}, Key: "T~Bar", Definition: "func (T) Bar() int"}
+	assert.Nil(t, execute(1, fooCell, decls))
+	assert.Nil(t, execute(2, useCell, s.Definitions.Copy()))
+
+	// Same definition: nothing is stale.
+	assert.Nil(t, execute(3, fooCell, withFoo(3, "func foo() int { return 1 }")))
+
+	// Definition changed.
+	stale := execute(4, fooCell, withFoo(4, "func foo() int { return 2 }"))
+	assert.Equal(t, map[int][]string{2: {"foo"}}, stale)
+	decls = s.Definitions.Copy()
+	decls.Functions["T~Bar"] = &Function{CellLines: CellLines{Id: 5}, Key: "T~Bar", Definition: "func (T) Bar() int { return 0 }"}
+	decls.Variables["x"] = &Variable{CellLines: CellLines{Id: 5}, Key: "x", Name: "x", ValueDefinition: "2"}
+	stale = execute(5, []string{"", "..."}, decls)
+	require.Len(t, stale, 1)
+	assert.ElementsMatch(t, []string{"T~Bar", "x"}, stale[2])
+	assert.Equal(t, "* Declarations changed by this cell are used by cells executed before, whose results may be "+
+		"stale: [2] (`T.Bar`, `x`).\n", staleCellsNote(stale))
+
+	// Re-executing the cell replaces its previous execution.
+	assert.Nil(t, execute(6, useCell, s.Definitions.Copy()))
+	stale = execute(7, fooCell, withFoo(7, "func foo() int { return 3 }"))
+	assert.Equal(t, map[int][]string{6: {"foo"}}, stale)
+}
+
+func TestStaleCellsEdited(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	skipLines := MakeSet[int]()
+	execute := func(key string, cellId int, lines []string, decls *Declarations) {
+		s.recordCellReferences(key, cellId, lines, skipLines, decls)
+		s.Definitions = decls
+	}
+	decls := s.Definitions.Copy()
+	decls.Functions["foo"] = &Function{CellLines: CellLines{Id: 1}, Key: "foo", Definition: "func foo() int { return 1 }"}
+	execute("id:a", 1, []string{"func foo() int { return 1 }"}, decls)
+
+	// Cell with the front-end id "b", edited: only its last version is kept.
+	execute("id:b", 2, []string{"var y = foo()"}, s.Definitions.Copy())
+	execute("id:b", 3, []string{"var y = foo() + 1"}, s.Definitions.Copy())
+	assert.Equal(t, []string{"id:b"}, SortedKeys(s.cellRefs))
+	assert.Equal(t, 3, s.cellRefs["id:b"].id)
+	assert.Nil(t, s.cellReferencesById(2))
+
+	// Cells keyed by their code: the edited version replaces the one defining the same declarations.
+	decls = s.Definitions.Copy()
+	decls.Variables["z"] = &Variable{CellLines: CellLines{Id: 4}, Key: "z", Name: "z", ValueDefinition: "foo()"}
+	lines := []string{"var z = foo()"}
+	execute(cellKey(nil, lines), 4, lines, decls)
+	decls = s.Definitions.Copy()
+	decls.Variables["z"] = &Variable{CellLines: CellLines{Id: 5}, Key: "z", Name: "z", ValueDefinition: "foo() * 2"}
+	lines = []string{"var z = foo() * 2"}
+	execute(cellKey(nil, lines), 5, lines, decls)
+	assert.Equal(t, []string{"id:b", cellKey(nil, lines)}, SortedKeys(s.cellRefs))
+	assert.Nil(t, s.cellReferencesById(4))
+}
+
+func TestDependentDeclarations(t *testing.T) {
+	definitions := map[string]string{
+		"foo":   "func foo() int { return 1 }",
+		"y":     " = foo()",
+		"z":     " = y + 1",
+		"T~Bar": "func (T) Bar() int { return z }",
+		"w":     " = 3",
+	}
+	changed := MakeSet[string]()
+	changed.Insert("foo")
+	assert.Equal(t, []string{"T~Bar", "foo", "y", "z"}, SortedKeys(dependentDeclarations(definitions, changed)))
+}
+
+func TestReactiveScheduling(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	s.Reactive = true
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	skipLines := MakeSet[int]()
+	skipLines.Insert(0)
+	execute := func(cellId int, lines []string, decls *Declarations) {
+		s.publishStaleCells(msg, cellId, lines, skipLines, decls)
+		s.Definitions = decls
+	}
+
+	decls := s.Definitions.Copy()
+	decls.Functions["foo"] = &Function{CellLines: CellLines{Id: 1}, Key: "foo", Definition: "func foo() int { return 1 }"}
+	execute(1, []string{"", "func foo() int { return 1 }"}, decls)
+	decls = s.Definitions.Copy()
+	decls.Variables["y"] = &Variable{CellLines: CellLines{Id: 2}, Key: "y", Name: "y", ValueDefinition: "foo()"}
+	execute(2, []string{"", "var y = foo()"}, decls)
+	s.CellIsTest = true // Cells with special flags are not re-executed.
+	execute(3, []string{"%test", "func TestY(t *testing.T) { _ = y }"}, s.Definitions.Copy())
+	s.CellIsTest = false
+	execute(4, []string{"%%", "fmt.Println(y)"}, s.Definitions.Copy())
+
+	decls = s.Definitions.Copy()
+	decls.Functions["foo"] = &Function{CellLines: CellLines{Id: 5}, Key: "foo", Definition: "func foo() int { return 2 }"}
+	execute(5, []string{"", "func foo() int { return 2 }"}, decls)
+	assert.Equal(t, []int{2, 4}, SortedKeys(s.reactivePending))
+	require.Len(t, msg.Outputs(), 1)
+	assert.Contains(t, fmt.Sprint(msg.Outputs()[0]["data"]), "[3] (`y`)")
+
+	// Cell 6 redefines `y`: re-executing cell 2 would revert it.
+	decls = s.Definitions.Copy()
+	decls.Variables["y"] = &Variable{CellLines: CellLines{Id: 6}, Key: "y", Name: "y", ValueDefinition: "foo() + 1"}
+	decls.Variables["w"] = &Variable{CellLines: CellLines{Id: 6}, Key: "w", Name: "w", ValueDefinition: "0"}
+	execute(6, []string{"", "var y, w = foo() + 1, 0"}, decls)
+	assert.Equal(t, "", fmt.Sprint(msg.Outputs()[0]["data"].(map[string]any)["text/plain"]),
+		"the note of stale cells should be erased when the next cell is executed")
+	key, redefinedBy := s.redefinedDeclaration(s.cellReferencesById(2))
+	assert.Equal(t, "y", key)
+	assert.Equal(t, 6, redefinedBy)
+	key, _ = s.redefinedDeclaration(s.cellReferencesById(4))
+	assert.Empty(t, key)
+
+	// A re-executed cell keeps its own key, and replaces its entry, instead of the triggering cell's one.
+	refs := s.cellReferencesById(4)
+	delete(s.cellRefs, refs.key)
+	refs.key = "id:cell4" // As if sent by the front-end.
+	s.cellRefs[refs.key] = refs
+	numRefs := len(s.cellRefs)
+	s.reexecutingCellKey = refs.key
+	execute(4, refs.lines, s.Definitions.Copy())
+	s.reexecutingCellKey = ""
+	assert.Len(t, s.cellRefs, numRefs)
+	assert.NotSame(t, refs, s.cellRefs["id:cell4"])
+	assert.Equal(t, 4, s.cellRefs["id:cell4"].id)
+}
@@ -0,0 +1,151 @@
+package goexec
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+)
+
+// This file implements `%stats`: a summary of the session, to help find what makes a notebook slow.
+// Everything is computed locally, and nothing is sent anywhere.
+
+// Phases of the execution of a cell, whose times are accumulated by sessionStats.
+const (
+	phaseParse     = "parse"
+	phaseGoImports = "goimports"
+	phaseCompile   = "compile"
+	phaseRun       = "run"
+)
+
+// statsPhases lists the phases in the order they are executed.
+var statsPhases = []string{phaseParse, phaseGoImports, phaseCompile, phaseRun}
+
+// maxStatsDeclarations is the number of largest memorized declarations listed by `%stats`.
+const maxStatsDeclarations = 10
+
+// sessionStats accumulates the statistics of the session reported by `%stats`.
+type sessionStats struct {
+	executions, failures int
+	phases               map[string]time.Duration
+}
+
+// addExecution counts the execution of a cell, and whether it failed.
+func (st *sessionStats) addExecution(err error) {
+	st.executions++
+	if err != nil {
+		st.failures++
+	}
+}
+
+// startPhase starts timing a phase of the execution of a cell: the returned function stops it, and adds the
+// elapsed time to the phase total.
+func (st *sessionStats) startPhase(phase string) func() {
+	start := time.Now()
+	return func() {
+		if st.phases == nil {
+			st.phases = make(map[string]time.Duration)
+		}
+		st.phases[phase] += time.Since(start)
+	}
+}
+
+// declarationSize describes the size of a memorized declaration.
+type declarationSize struct {
+	Kind, Key    string
+	CellId       int
+	Bytes, Lines int
+}
+
+// largestDeclarations returns the n largest memorized declarations, by the size of their source code.
+func largestDeclarations(decls *Declarations, n int) []declarationSize {
+	var sizes []declarationSize
+	add := func(kind, key string, cellLines CellLines, source string) {
+		sizes = append(sizes, declarationSize{Kind: kind, Key: key, CellId: cellLines.Id,
+			Bytes: len(source), Lines: strings.Count(source, "\n") + 1})
+	}
+	for key, f := range decls.Functions {
+		add("func", key, f.CellLines, f.Definition)
+	}
+	for key, v := range decls.Variables {
+		add("var", key, v.CellLines, v.TypeDefinition+v.ValueDefinition)
+	}
+	for key, t := range decls.Types {
+		add("type", key, t.CellLines, t.TypeDefinition)
+	}
+	for key, c := range decls.Constants {
+		add("const", key, c.CellLines, c.TypeDefinition+c.ValueDefinition)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Bytes != sizes[j].Bytes {
+			return sizes[i].Bytes > sizes[j].Bytes
+		}
+		return sizes[i].Key < sizes[j].Key
+	})
+	if len(sizes) > n {
+		sizes = sizes[:n]
+	}
+	return sizes
+}
+
+// formatStatsDuration rounds the duration for display.
+func formatStatsDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+// Stats implements `%stats`: it displays the number of executions, the total time spent in each phase of the
+// execution of the cells (parsing, goimports, compiling and running), the number of `gopls` (re-)starts and
+// the largest memorized declarations.
+func (s *State) Stats(msg kernel.Message) error {
+	st := &s.stats
+	var sb strings.Builder
+	row := func(name, value string) {
+		fmt.Fprintf(&sb, "<tr><td style=\"text-align: left\">%s</td><td style=\"text-align: left\">%s</td></tr>\n",
+			name, html.EscapeString(value))
+	}
+	sb.WriteString("<b>Session statistics</b> (computed locally)\n<table>\n")
+	row("Executions", fmt.Sprintf("%d (%d failed)", st.executions, st.failures))
+	var total time.Duration
+	for _, phase := range statsPhases {
+		total += st.phases[phase]
+	}
+	for _, phase := range statsPhases {
+		value := formatStatsDuration(st.phases[phase])
+		if total > 0 {
+			value += fmt.Sprintf(" (%.0f%%)", 100*float64(st.phases[phase])/float64(total))
+		}
+		if st.executions > 0 {
+			value += fmt.Sprintf(", %s per execution", formatStatsDuration(st.phases[phase]/time.Duration(st.executions)))
+		}
+		row("Total "+phase+" time", value)
+	}
+	if s.gopls == nil {
+		row("<code>gopls</code> starts", "not installed")
+	} else {
+		starts, failures := s.gopls.Starts()
+		row("<code>gopls</code> starts", fmt.Sprintf("%d (%d restarts, %d failures)", starts, max(starts-1, 0), failures))
+	}
+	sb.WriteString("</table>\n")
+
+	sizes := largestDeclarations(s.Definitions, maxStatsDeclarations)
+	if len(sizes) > 0 {
+		sb.WriteString("<b>Largest memorized declarations</b>\n<table>\n" +
+			"<tr><th>Kind</th><th>Name</th><th>Cell</th><th>Bytes</th><th>Lines</th></tr>\n")
+		for _, size := range sizes {
+			cell := "-"
+			if size.CellId >= 0 {
+				cell = fmt.Sprintf("%d", size.CellId)
+			}
+			fmt.Fprintf(&sb, "<tr><td>%s</td><td style=\"text-align: left\"><code>%s</code></td><td>%s</td><td>%d</td><td>%d</td></tr>\n",
+				size.Kind, html.EscapeString(size.Key), cell, size.Bytes, size.Lines)
+		}
+		sb.WriteString("</table>")
+	}
+	return kernel.PublishHtml(msg, sb.String())
+}
@@ -0,0 +1,38 @@
+package goexec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStats(t *testing.T) {
+	var st sessionStats
+	st.addExecution(nil)
+	st.addExecution(errors.New("failed"))
+	assert.Equal(t, 2, st.executions)
+	assert.Equal(t, 1, st.failures)
+
+	stop := st.startPhase(phaseCompile)
+	time.Sleep(time.Millisecond)
+	stop()
+	assert.GreaterOrEqual(t, st.phases[phaseCompile], time.Millisecond)
+	assert.Zero(t, st.phases[phaseRun])
+}
+
+func TestLargestDeclarations(t *testing.T) {
+	d := NewDeclarations()
+	d.Functions["small"] = &Function{Key: "small", CellLines: CellLines{Id: 1}, Definition: "func small() {}"}
+	d.Functions["large"] = &Function{Key: "large", CellLines: CellLines{Id: 2},
+		Definition: "func large() {\n\tfmt.Println(\"large\")\n}"}
+	d.Types["Point"] = &TypeDecl{Key: "Point", CellLines: CellLines{Id: 3}, TypeDefinition: "Point struct{ X, Y int }"}
+	d.Variables["x"] = &Variable{Key: "x", Name: "x", CellLines: CellLines{Id: -1}, ValueDefinition: "1"}
+
+	assert.Equal(t, []declarationSize{
+		{Kind: "func", Key: "large", CellId: 2, Bytes: 38, Lines: 3},
+		{Kind: "type", Key: "Point", CellId: 3, Bytes: 24, Lines: 1},
+	}, largestDeclarations(d, 2))
+	assert.Len(t, largestDeclarations(d, 10), 4)
+}
@@ -0,0 +1,150 @@
+package goexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/gonbui/streamtap"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%tap`: it taps into a Kafka topic or a NATS subject for a few seconds, using their
+// command line tools (`kcat` and `nats`), and displays the messages received in a table updated live (see
+// package gonbui/streamtap, that offers the same from Go code).
+
+// DefaultTapDuration is the default duration of `%tap`.
+const DefaultTapDuration = 10 * time.Second
+
+// TapConfig is the configuration of `%tap`.
+type TapConfig struct {
+	// Kind of the stream: "kafka" or "nats".
+	Kind string
+
+	// Brokers is the comma separated list of Kafka brokers, and Server the URL of the NATS server (if empty,
+	// the default of the `nats` tool is used).
+	Brokers, Server string
+
+	// Topic is the Kafka topic or the NATS subject.
+	Topic string
+
+	// Duration to tap into the stream.
+	Duration time.Duration
+}
+
+// tapCommand returns the command line to tap into the stream: one message per line, with the Kafka topic and
+// key separated by tabs.
+func tapCommand(config *TapConfig) ([]string, error) {
+	switch config.Kind {
+	case "kafka":
+		return []string{"kcat", "-C", "-q", "-u", "-o", "end", "-b", config.Brokers, "-t", config.Topic,
+			"-f", `%t\t%k\t%s\n`}, nil
+	case "nats":
+		args := []string{"nats", "sub", config.Topic, "--raw"}
+		if config.Server != "" {
+			args = append(args, "--server", config.Server)
+		}
+		return args, nil
+	}
+	return nil, errors.Errorf("%%tap: unknown kind of stream %q, use `kafka` or `nats`", config.Kind)
+}
+
+// parseTapLine returns the message printed in the line by the tool of tapCommand.
+func parseTapLine(config *TapConfig, line string) *streamtap.Message {
+	msg := &streamtap.Message{Time: time.Now(), Topic: config.Topic}
+	if config.Kind == "kafka" {
+		if parts := strings.SplitN(line, "\t", 3); len(parts) == 3 {
+			msg.Topic, msg.Key, line = parts[0], parts[1], parts[2]
+		}
+	}
+	msg.Value = []byte(line)
+	return msg
+}
+
+// Tap implements `%tap`: it runs the tool that taps into the stream for the configured duration, or until the
+// execution is interrupted, and displays the messages received in a table updated live.
+func (s *State) Tap(msg kernel.Message, config *TapConfig) error {
+	args, err := tapCommand(config)
+	if err != nil {
+		return err
+	}
+	if _, err = exec.LookPath(args[0]); err != nil {
+		return errors.Errorf("%%tap %s requires %q to be installed and in the PATH", config.Kind, args[0])
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.Duration)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrapf(err, "%%tap: failed to create pipe for %q", args[0])
+	}
+	klog.V(2).Infof("%%tap: executing %q", args)
+	if err = cmd.Start(); err != nil {
+		return errors.Wrapf(err, "%%tap: failed to start %q", args[0])
+	}
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	table := streamtap.NewTable()
+	displayId := "gonb_tap_" + common.UniqueId()
+	status := fmt.Sprintf("Tapping %s %q for %s", config.Kind, config.Topic, config.Duration)
+	update := func() {
+		err := kernel.PublishUpdateDisplayData(msg, kernel.Data{
+			Data: kernel.MIMEMap{
+				string(protocol.MIMETextHTML):  table.Html(status),
+				string(protocol.MIMETextPlain): table.Text(status),
+			},
+			Transient: kernel.MIMEMap{"display_id": displayId},
+		})
+		if err != nil {
+			klog.Warningf("%%tap: failed to update the table: %+v", err)
+		}
+	}
+	update()
+	ticker := time.NewTicker(streamtap.UpdateInterval)
+	defer ticker.Stop()
+	changed := false
+	for lines != nil {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				break
+			}
+			table.Add(parseTapLine(config, line))
+			changed = true
+		case <-ticker.C:
+			if msg.Kernel().Interrupted.Load() {
+				cancel()
+			}
+			if changed {
+				update()
+				changed = false
+			}
+		}
+	}
+	err = cmd.Wait()
+	status = fmt.Sprintf("Tapped %s %q", config.Kind, config.Topic)
+	update()
+	if err != nil && ctx.Err() == nil {
+		return errors.Errorf("%%tap: %q failed: %v\n%s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
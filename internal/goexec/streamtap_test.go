@@ -0,0 +1,53 @@
+package goexec
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTapCommand(t *testing.T) {
+	args, err := tapCommand(&TapConfig{Kind: "kafka", Brokers: "localhost:9092", Topic: "orders"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kcat", "-C", "-q", "-u", "-o", "end", "-b", "localhost:9092", "-t", "orders",
+		"-f", `%t\t%k\t%s\n`}, args)
+	args, err = tapCommand(&TapConfig{Kind: "nats", Topic: "orders.>", Server: "nats://localhost:4222"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nats", "sub", "orders.>", "--raw", "--server", "nats://localhost:4222"}, args)
+	_, err = tapCommand(&TapConfig{Kind: "pulsar"})
+	assert.Error(t, err)
+
+	msg := parseTapLine(&TapConfig{Kind: "kafka", Topic: "orders"}, "orders\tkey1\t{\"id\": 1}\t")
+	assert.Equal(t, "orders", msg.Topic)
+	assert.Equal(t, "key1", msg.Key)
+	assert.Equal(t, "{\"id\": 1}\t", string(msg.Value))
+}
+
+func TestTap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a shell script")
+	}
+	// Fake `nats` tool, that prints two messages and exits.
+	binDir := t.TempDir()
+	script := "#!/bin/sh\necho '{\"id\": 1, \"item\": \"book\"}'\necho 'not json'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "nats"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	s := &State{}
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Tap(msg, &TapConfig{Kind: "nats", Topic: "orders", Duration: 5 * time.Second}))
+	outputs := msg.Outputs()
+	require.NotEmpty(t, outputs)
+	text := outputs[len(outputs)-1]["data"].(kernel.MIMEMap)["text/plain"].(string)
+	assert.Contains(t, text, `Tapped nats "orders": 2 message(s)`)
+	assert.Regexp(t, `time\s+topic\s+value\s+id\s+item`, text)
+	assert.Regexp(t, `orders\s+not json`, text)
+	assert.Regexp(t, `orders\s+1\s+book`, text)
+}
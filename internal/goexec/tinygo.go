@@ -0,0 +1,93 @@
+package goexec
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/jpyexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the TinyGo build mode, for embedded targets: while it is enabled (`%tinygo target=...`)
+// cells are compiled with `tinygo` instead of the standard toolchain, and `%flash` programs a connected board.
+
+// TinyGoFirmwareName is the name of the firmware file (in State.TempDir) built by TinyGo.
+const TinyGoFirmwareName = "firmware.elf"
+
+// TinyGoFirmwarePath is the path to the firmware built by TinyGo for the last cell.
+func (s *State) TinyGoFirmwarePath() string {
+	return path.Join(s.TempDir, TinyGoFirmwareName)
+}
+
+// tinyGoArgs returns the arguments to `tinygo` for the sub-command (`build` or `flash`), for the configured
+// target, build tags and `%goflags`.
+func (s *State) tinyGoArgs(subCommand string, extraArgs ...string) []string {
+	args := []string{subCommand, "-target=" + s.TinyGoTarget, "-size=short"}
+	if len(s.BuildTags) > 0 {
+		args = append(args, "-tags="+strings.Join(s.BuildTags, " "))
+	}
+	args = append(args, extraArgs...)
+	return append(args, s.GoBuildFlags...)
+}
+
+// compileTinyGo compiles the generated go files in State.TempDir with TinyGo, for State.TinyGoTarget,
+// and displays the size report. The firmware is not executed: use `%flash` to program a board.
+func (s *State) compileTinyGo(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine) error {
+	tinyGoPath, err := exec.LookPath("tinygo")
+	if err != nil {
+		return errors.Wrapf(err, "`tinygo` not found in PATH, see installation instructions in https://tinygo.org/getting-started/install/")
+	}
+	cmd := exec.Command(tinyGoPath, s.tinyGoArgs("build", "-o", s.TinyGoFirmwarePath())...)
+	cmd.Dir = s.TempDir
+	cmd.Env = append(cmd.Environ(), s.GoEnv()...)
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		klog.Errorf("Failed %q:\n%s\n", cmd, output)
+		err := s.DisplayErrorWithContext(msg, fileToCellIdAndLines, s.filterWarnings(string(output)), err)
+		return errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+		"TinyGo firmware for %q built in %s, use `%%flash` to program the board:\n%s",
+		s.TinyGoTarget, s.TinyGoFirmwarePath(), s.filterWarnings(string(output))))
+}
+
+// Flash composes the memorized declarations and programs a connected board with `tinygo flash`, for
+// State.TinyGoTarget. If port is empty, TinyGo tries to find the board.
+func (s *State) Flash(msg kernel.Message, port string) (err error) {
+	if s.TinyGoTarget == "" {
+		return errors.New("%flash requires a TinyGo target, set with `%tinygo target=<target>`")
+	}
+	tinyGoPath, err := exec.LookPath("tinygo")
+	if err != nil {
+		return errors.Wrapf(err, "`tinygo` not found in PATH, see installation instructions in https://tinygo.org/getting-started/install/")
+	}
+	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
+	err = s.AutoTrack()
+	if err != nil {
+		return
+	}
+	_, err = s.composeMemorizedDeclarations(msg)
+	if err != nil {
+		return
+	}
+	var extraArgs []string
+	if port != "" {
+		extraArgs = append(extraArgs, "-port="+port)
+	}
+	executor := jpyexec.New(msg, tinyGoPath, s.tinyGoArgs("flash", extraArgs...)...).
+		ExecutionCount(msg.Kernel().ExecCounter).
+		InDir(s.TempDir).
+		WithEnv(s.GoEnv())
+	if err = executor.Exec(); err != nil {
+		return errors.WithMessagef(err, "`tinygo flash` failed")
+	}
+	if state := executor.ProcessState(); state != nil && !state.Success() {
+		return errors.Errorf("`tinygo flash` failed for target %q", s.TinyGoTarget)
+	}
+	return nil
+}
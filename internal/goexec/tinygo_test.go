@@ -0,0 +1,18 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTinyGoArgs(t *testing.T) {
+	s := &State{TempDir: "/tmp/gonb_test", TinyGoTarget: "arduino"}
+	assert.Equal(t, []string{"build", "-target=arduino", "-size=short", "-o", "/tmp/gonb_test/" + TinyGoFirmwareName},
+		s.tinyGoArgs("build", "-o", s.TinyGoFirmwarePath()))
+
+	s.BuildTags = []string{"debug", "leds"}
+	s.GoBuildFlags = []string{"-opt=s"}
+	assert.Equal(t, []string{"flash", "-target=arduino", "-size=short", "-tags=debug leds", "-port=/dev/ttyACM0", "-opt=s"},
+		s.tinyGoArgs("flash", "-port=/dev/ttyACM0"))
+}
@@ -32,6 +32,9 @@ type trackingInfo struct {
 	// updated is the list of files that changed since last call to State.EnumerateUpdatedFiles.
 	updated common.Set[string]
 
+	// changed is the list of files that changed since the last call to State.reportChangedSources.
+	changed common.Set[string]
+
 	// watcher for files being tracked. It is notified of file system changes.
 	watcher *fsnotify.Watcher
 
@@ -50,6 +53,7 @@ func newTrackingInfo() *trackingInfo {
 	return &trackingInfo{
 		tracked: make(map[string]*trackEntry),
 		updated: common.MakeSet[string](),
+		changed: common.MakeSet[string](),
 	}
 }
 
@@ -129,7 +133,18 @@ func (s *State) lockedTrack(root, fileOrDirPath string, visited common.Set[strin
 					if !ok {
 						return
 					}
-					if event.Op != fsnotify.Write && event.Op != fsnotify.Remove {
+					if event.Has(fsnotify.Create) {
+						// New subdirectories of tracked directories are also watched.
+						if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+							ti.mu.Lock()
+							s.lockedWatchSubdirs(event.Name)
+							ti.mu.Unlock()
+							continue
+						}
+					}
+					// Editors often save files by renaming a new file over the old one.
+					if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) &&
+						!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
 						// Not interested.
 						continue
 					}
@@ -140,6 +155,7 @@ func (s *State) lockedTrack(root, fileOrDirPath string, visited common.Set[strin
 					ti.mu.Lock()
 					klog.V(2).Infof("goexec.Track: updates to %q", event.Name)
 					ti.updated.Insert(event.Name)
+					ti.changed.Insert(event.Name)
 					ti.mu.Unlock()
 				case err, ok := <-ti.watcher.Errors:
 					klog.V(2).Infof("goexec.Track: async err received %+v", err)
@@ -161,6 +177,16 @@ func (s *State) lockedTrack(root, fileOrDirPath string, visited common.Set[strin
 			if err != nil {
 				return errors.Wrapf(err, "failed to track file under tracked directory %q", fileOrDirPath)
 			}
+			if d.IsDir() && path != fileOrDirPath {
+				// The watcher is not recursive: subdirectories (packages of the module) are watched explicitly.
+				if isIgnoredDir(d.Name()) {
+					return fs.SkipDir
+				}
+				if err := ti.watcher.Add(path); err != nil {
+					klog.Warningf("Failed to watch tracked directory %q: %+v", path, err)
+				}
+				return nil
+			}
 			if d.IsDir() || !isGoRelated(path) {
 				// Directories or files we don't care about.
 				return nil
@@ -177,6 +203,57 @@ func (s *State) lockedTrack(root, fileOrDirPath string, visited common.Set[strin
 	return
 }
 
+// isIgnoredDir returns whether the directory name is of a hidden directory (e.g. `.git`), `testdata` or
+// `vendor`, which are not watched for changes.
+func isIgnoredDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "testdata" || name == "vendor"
+}
+
+// lockedWatchSubdirs adds the directory, and its subdirectories, to the watcher. It assumes `trackingInfo`
+// is locked.
+func (s *State) lockedWatchSubdirs(dirPath string) {
+	ti := s.trackingInfo
+	if ti.watcher == nil || isIgnoredDir(path.Base(dirPath)) {
+		return
+	}
+	_ = common.WalkDirWithSymbolicLinks(dirPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p != dirPath && isIgnoredDir(d.Name()) {
+			return fs.SkipDir
+		}
+		if err := ti.watcher.Add(p); err != nil {
+			klog.Warningf("Failed to watch tracked directory %q: %+v", p, err)
+		}
+		return nil
+	})
+}
+
+// reportChangedSources reports (to stderr) the tracked files that changed on disk since the last
+// execution, if State.NotifyChangedSources is set. They are always compiled from their current contents:
+// this is only to let the user know the behavior of the cell may have changed.
+func (s *State) reportChangedSources(msg kernel.Message) {
+	ti := s.trackingInfo
+	ti.mu.Lock()
+	changed := common.SortedKeys(ti.changed)
+	ti.changed = common.MakeSet[string]()
+	ti.mu.Unlock()
+	if !s.NotifyChangedSources || len(changed) == 0 {
+		return
+	}
+	const maxListed = 5
+	listed := changed
+	if len(listed) > maxListed {
+		listed = listed[:maxListed]
+	}
+	report := fmt.Sprintf("* Tracked local sources changed since the last execution: %s", strings.Join(listed, ", "))
+	if len(changed) > maxListed {
+		report += fmt.Sprintf(" and %d more", len(changed)-maxListed)
+	}
+	_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, report+".\n")
+}
+
 // Untrack removes file or dir from path of tracked files. If it ends with "...", it un-tracks
 // anything that has fileOrDirPath as prefix. If you set `fileOrDirPath == "..."`, it will
 // un-tracks everything.
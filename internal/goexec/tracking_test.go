@@ -0,0 +1,45 @@
+package goexec
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackChangedSources(t *testing.T) {
+	moduleDir := t.TempDir()
+	pkgDir := path.Join(moduleDir, "pkg")
+	require.NoError(t, os.Mkdir(pkgDir, 0700))
+	require.NoError(t, os.WriteFile(path.Join(pkgDir, "pkg.go"), []byte("package pkg\n"), 0600))
+
+	s := &State{trackingInfo: newTrackingInfo(), NotifyChangedSources: true}
+	require.NoError(t, s.Track(moduleDir))
+	defer func() { _ = s.Untrack("...") }()
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	s.reportChangedSources(msg)
+	assert.Empty(t, msg.Outputs(), "tracking files is not a change")
+
+	// Files in subdirectories are watched.
+	changedFile := path.Join(pkgDir, "pkg.go")
+	require.NoError(t, os.WriteFile(changedFile, []byte("package pkg\n\nconst X = 1\n"), 0600))
+	require.Eventually(t, func() bool {
+		s.trackingInfo.mu.Lock()
+		defer s.trackingInfo.mu.Unlock()
+		return s.trackingInfo.changed.Has(changedFile)
+	}, 5*time.Second, 10*time.Millisecond)
+	s.reportChangedSources(msg)
+	outputs := msg.Outputs()
+	require.Len(t, outputs, 1)
+	assert.Equal(t, "stderr", outputs[0]["name"])
+	assert.Contains(t, outputs[0]["text"], changedFile)
+
+	// Reported only once.
+	s.reportChangedSources(msg)
+	assert.Len(t, msg.Outputs(), 1)
+}
@@ -0,0 +1,226 @@
+package goexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/jpyexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%upgrade`, that installs the latest release of GoNB with `go install` and
+// re-installs the kernel in Jupyter, and the check for a newer release when the kernel starts.
+
+const (
+	// GoNBModulePath is the module of GoNB, installed by `%upgrade`.
+	GoNBModulePath = "github.com/janpfeifer/gonb"
+
+	// LatestReleaseURL is the GitHub API endpoint with the latest release of GoNB.
+	LatestReleaseURL = "https://api.github.com/repos/janpfeifer/gonb/releases/latest"
+
+	// UpdateCheckTimeout is the time limit to fetch the latest release of GoNB.
+	UpdateCheckTimeout = 5 * time.Second
+)
+
+// CurrentVersion returns the version of the running GoNB, as installed by `go install`, or "" for
+// development builds.
+func CurrentVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || !semver.IsValid(info.Main.Version) {
+		return ""
+	}
+	return info.Main.Version
+}
+
+// LatestVersion returns the version (tag) of the latest release of GoNB in GitHub.
+func LatestVersion() (string, error) {
+	client := &http.Client{Timeout: UpdateCheckTimeout}
+	req, err := http.NewRequest(http.MethodGet, LatestReleaseURL, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create request to %q", LatestReleaseURL)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to fetch the latest release of GoNB from %q", LatestReleaseURL)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to fetch the latest release of GoNB from %q: %s", LatestReleaseURL, resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", errors.Wrapf(err, "failed to parse the latest release of GoNB from %q", LatestReleaseURL)
+	}
+	if !semver.IsValid(release.TagName) {
+		return "", errors.Errorf("invalid version %q of the latest release of GoNB", release.TagName)
+	}
+	return release.TagName, nil
+}
+
+// isNewerVersion returns whether latest is a newer version than current. It is false if current is
+// unknown (a development build).
+func isNewerVersion(latest, current string) bool {
+	return semver.IsValid(latest) && semver.IsValid(current) && semver.Compare(latest, current) > 0
+}
+
+// updateNotice returns the note displayed when a newer version of GoNB is available.
+func updateNotice(latest, current string) string {
+	return fmt.Sprintf("* GoNB %s is available (running %s): use `%%upgrade` to install it.\n", latest, current)
+}
+
+// StartUpdateCheck checks in the background whether a newer release of GoNB is available. If so, a note
+// is displayed in the output of the next cell executed.
+func (s *State) StartUpdateCheck() {
+	current := CurrentVersion()
+	if current == "" {
+		klog.V(1).Infof("Update check skipped: development build of GoNB")
+		return
+	}
+	go func() {
+		latest, err := LatestVersion()
+		if err != nil {
+			klog.Warningf("Update check failed: %+v", err)
+			return
+		}
+		if isNewerVersion(latest, current) {
+			klog.Infof("GoNB %s is available (running %s)", latest, current)
+			notice := updateNotice(latest, current)
+			s.updateNotice.Store(&notice)
+		}
+	}()
+}
+
+// publishUpdateNotice displays the note of the update check, only once.
+func (s *State) publishUpdateNotice(msg kernel.Message) {
+	if notice := s.updateNotice.Swap(nil); notice != nil {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, *notice)
+	}
+}
+
+// kernelArgs returns the arguments the kernel was started with (the ones given to `gonb --install`),
+// without the `--kernel` flag.
+func kernelArgs(args []string) (kept []string) {
+	for ii := 0; ii < len(args); ii++ {
+		arg := args[ii]
+		if arg == "--kernel" || arg == "-kernel" {
+			ii++ // Skip the connection file.
+			continue
+		}
+		if strings.HasPrefix(arg, "--kernel=") || strings.HasPrefix(arg, "-kernel=") {
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return
+}
+
+// Upgrade implements `%upgrade`: it reports the current and latest versions of GoNB and, unless
+// checkOnly is set, installs the latest one with `go install` and re-installs the kernel in Jupyter, with
+// the same flags. force upgrades even if the versions can't be compared (e.g.: development builds).
+// The kernel must be restarted to use the new version.
+func (s *State) Upgrade(msg kernel.Message, checkOnly, force bool) error {
+	current := CurrentVersion()
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	currentDesc := current
+	if current == "" {
+		currentDesc = "a development build"
+	}
+	newer := isNewerVersion(latest, current)
+	switch {
+	case newer:
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("GoNB %s is available, running %s.\n", latest, currentDesc))
+	case current == "":
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("Latest GoNB is %s, running %s.\n", latest, currentDesc))
+	default:
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("GoNB %s is up-to-date (latest release is %s).\n", current, latest))
+	}
+	if checkOnly {
+		return nil
+	}
+	if !newer && !force {
+		if current == "" {
+			return errors.New("version of the running GoNB is unknown: use `%upgrade --force` to install the latest release")
+		}
+		return nil
+	}
+	if s.IsolatedGoPath {
+		return errors.New("`%upgrade` would install GoNB in the notebook's isolated GOPATH: " +
+			"use `%config isolated_gopath=off` first")
+	}
+
+	// Install the latest release.
+	executor, err := s.goExecutor(msg, "install", GoNBModulePath+"@"+latest)
+	if err != nil {
+		return err
+	}
+	if err = executor.Exec(); err != nil {
+		return errors.WithMessagef(err, "failed to install GoNB %s", latest)
+	}
+	if state := executor.ProcessState(); state != nil && !state.Success() {
+		return errors.Errorf("failed to install GoNB %s", latest)
+	}
+
+	// Re-install the kernel in Jupyter, with the new binary.
+	gonbPath, err := s.installedGoNBPath()
+	if err != nil {
+		return err
+	}
+	args := append([]string{"--install"}, kernelArgs(os.Args[1:])...)
+	executor = jpyexec.New(msg, gonbPath, args...).
+		ExecutionCount(msg.Kernel().ExecCounter).
+		InDir(s.TempDir)
+	if err = executor.Exec(); err != nil {
+		return errors.WithMessagef(err, "failed to install the kernel with %q", gonbPath)
+	}
+	if state := executor.ProcessState(); state != nil && !state.Success() {
+		return errors.Errorf("failed to install the kernel with %q", gonbPath)
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("* GoNB %s installed in %q: restart the kernel to use it.\n", latest, gonbPath))
+}
+
+// installedGoNBPath returns the path where `go install` installed GoNB: in GOBIN, or in the `bin`
+// directory of the first entry of GOPATH.
+func (s *State) installedGoNBPath() (string, error) {
+	cmd := s.goCommand("env", "GOBIN", "GOPATH")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	// GOBIN is printed as an empty line, if not set.
+	lines := strings.Split(strings.TrimRight(string(output), "\r\n"), "\n")
+	goBin := strings.TrimSpace(lines[0])
+	if goBin == "" && len(lines) > 1 {
+		if goPaths := filepath.SplitList(strings.TrimSpace(lines[1])); len(goPaths) > 0 {
+			goBin = filepath.Join(goPaths[0], "bin")
+		}
+	}
+	gonbPath := filepath.Join(goBin, "gonb")
+	if runtime.GOOS == "windows" {
+		gonbPath += ".exe"
+	}
+	if _, err = exec.LookPath(gonbPath); err != nil {
+		return "", errors.Wrapf(err, "installed GoNB not found in %q", goBin)
+	}
+	return gonbPath, nil
+}
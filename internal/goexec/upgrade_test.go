@@ -0,0 +1,39 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	assert.True(t, isNewerVersion("v0.10.0", "v0.9.6"))
+	assert.False(t, isNewerVersion("v0.9.6", "v0.9.6"))
+	assert.False(t, isNewerVersion("v0.9.5", "v0.9.6"))
+	// Development builds are never considered outdated.
+	assert.False(t, isNewerVersion("v0.10.0", ""))
+	assert.False(t, isNewerVersion("v0.10.0", "(devel)"))
+}
+
+func TestKernelArgs(t *testing.T) {
+	assert.Equal(t, []string{"--raw_error", "--isolated_gopath"},
+		kernelArgs([]string{"--kernel", "/tmp/conn.json", "--raw_error", "--isolated_gopath"}))
+	assert.Equal(t, []string{"--work"}, kernelArgs([]string{"--kernel=/tmp/conn.json", "--work"}))
+	assert.Empty(t, kernelArgs([]string{"--kernel", "/tmp/conn.json"}))
+}
+
+func TestPublishUpdateNotice(t *testing.T) {
+	s := &State{}
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	s.publishUpdateNotice(msg)
+	assert.Empty(t, msg.Outputs())
+
+	notice := updateNotice("v0.10.0", "v0.9.6")
+	s.updateNotice.Store(&notice)
+	s.publishUpdateNotice(msg)
+	s.publishUpdateNotice(msg) // Only displayed once.
+	require.Len(t, msg.Outputs(), 1)
+}
@@ -0,0 +1,133 @@
+package goexec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the kernel side of the upload widgets displayed with `gonbui.RequestFileUpload`: the
+// files chosen in the browser are sent in chunks, saved in UploadsDir, and their paths are listed in
+// UploadedFilesFile, read by `gonbui.UploadedFiles` in the following cell executions.
+
+const (
+	// UploadsDir is the directory, under State.TempDir, where the files uploaded are saved.
+	UploadsDir = "gonb_uploads"
+
+	// UploadedFilesFile is the file, under State.TempDir, with the paths of the files uploaded, by their
+	// names. It is given to the cells in the environment variable protocol.GONB_UPLOADED_FILES_ENV.
+	UploadedFilesFile = "gonb_uploaded_files.json"
+)
+
+// initUploads sets the environment variable pointing to the file with the paths of the files uploaded.
+func (s *State) initUploads() error {
+	return errors.Wrapf(os.Setenv(protocol.GONB_UPLOADED_FILES_ENV, path.Join(s.TempDir, UploadedFilesFile)),
+		"failed to set environment variable %q", protocol.GONB_UPLOADED_FILES_ENV)
+}
+
+// uploadChunk is a chunk of a file uploaded, sent to protocol.GonbuiUploadAddress.
+type uploadChunk struct {
+	Id     string  `json:"id"`
+	Name   string  `json:"name"`
+	Offset float64 `json:"offset"`
+	Data   string  `json:"data"` // Base64 encoded.
+	Last   bool    `json:"last"`
+}
+
+// saveUploadChunk writes the chunk to the file being uploaded, in UploadsDir, and returns its path. The chunks
+// must be sent in order: the first one (offset 0) creates (or truncates) the file, and the following ones must
+// start where the previous ended. When the last one is received, the file is added to UploadedFilesFile.
+func (s *State) saveUploadChunk(chunk *uploadChunk) (string, error) {
+	name := filepath.Base(chunk.Name)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return "", errors.Errorf("invalid name %q for the file uploaded", chunk.Name)
+	}
+	data, err := base64.StdEncoding.DecodeString(chunk.Data)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid data uploaded for %q", name)
+	}
+	dir := filepath.Join(s.TempDir, UploadsDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrapf(err, "failed to create directory %q for the uploads", dir)
+	}
+	filePath := filepath.Join(dir, name)
+	flags := os.O_WRONLY | os.O_CREATE
+	if chunk.Offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(filePath, flags, 0600)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open %q", filePath)
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err == nil && size != int64(chunk.Offset) {
+		err = errors.Errorf("chunk of %q at offset %d, but %d bytes were received", name, int64(chunk.Offset), size)
+	}
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to save the upload of %q", name)
+	}
+	if chunk.Last {
+		s.muInputs.Lock()
+		defer s.muInputs.Unlock()
+		if s.uploadedFiles == nil {
+			s.uploadedFiles = make(map[string]string)
+		}
+		s.uploadedFiles[name] = filePath
+		if err = s.saveUploadedFilesLocked(); err != nil {
+			return "", err
+		}
+	}
+	return filePath, nil
+}
+
+// saveUploadedFilesLocked writes the paths of the files uploaded to UploadedFilesFile.
+// It must be called with s.muInputs locked.
+func (s *State) saveUploadedFilesLocked() error {
+	filePath := path.Join(s.TempDir, UploadedFilesFile)
+	contents, err := json.Marshal(s.uploadedFiles)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the paths of the files uploaded")
+	}
+	return errors.Wrapf(os.WriteFile(filePath, contents, 0600), "failed to write %q", filePath)
+}
+
+// handleUploadChunk saves the chunks of the files uploaded with the widgets of `gonbui.RequestFileUpload`, sent
+// to protocol.GonbuiUploadAddress, registered with comms.State.HandleAddressPrefix. Each chunk is acknowledged
+// to protocol.GonbuiUploadAckAddress with its "id", and the "path" of the file once the last chunk is saved --
+// or an "error".
+func (s *State) handleUploadChunk(msg kernel.Message, address string, value any) {
+	var chunk uploadChunk
+	encoded, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(encoded, &chunk)
+	}
+	if err != nil {
+		klog.Warningf("Invalid upload chunk sent to %q: %+v", address, err)
+		return
+	}
+	ack := map[string]any{"id": chunk.Id}
+	filePath, err := s.saveUploadChunk(&chunk)
+	if err != nil {
+		klog.Warningf("Upload failed: %+v", err)
+		ack["error"] = err.Error()
+	} else if chunk.Last {
+		ack["path"] = filePath
+	}
+	if err = s.Comms.Send(msg, protocol.GonbuiUploadAckAddress, ack); err != nil {
+		klog.Warningf("Failed to acknowledge the upload of %q: %+v", chunk.Name, err)
+	}
+}
@@ -0,0 +1,54 @@
+package goexec
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveUploadChunk(t *testing.T) {
+	t.Setenv(protocol.GONB_UPLOADED_FILES_ENV, "")
+	s := &State{TempDir: t.TempDir()}
+	require.NoError(t, s.initUploads())
+	files, err := gonbui.UploadedFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	chunk := func(name string, offset int, data string, last bool) *uploadChunk {
+		return &uploadChunk{Id: "upload_0", Name: name, Offset: float64(offset),
+			Data: base64.StdEncoding.EncodeToString([]byte(data)), Last: last}
+	}
+	filePath, err := s.saveUploadChunk(chunk("data.csv", 0, "a,b\n", false))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(s.TempDir, UploadsDir, "data.csv"), filePath)
+	files, err = gonbui.UploadedFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files, "file only listed after the last chunk")
+
+	_, err = s.saveUploadChunk(chunk("data.csv", 10, "3,4\n", false))
+	assert.ErrorContains(t, err, "4 bytes were received")
+	_, err = s.saveUploadChunk(chunk("data.csv", 4, "1,2\n", true))
+	require.NoError(t, err)
+	contents, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(contents))
+	files, err = gonbui.UploadedFiles()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"data.csv": filePath}, files)
+
+	// Uploading again overwrites the file, and names can't escape the uploads directory.
+	filePath, err = s.saveUploadChunk(chunk("../../data.csv", 0, "x\n", true))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(s.TempDir, UploadsDir, "data.csv"), filePath)
+	contents, err = os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "x\n", string(contents))
+	_, err = s.saveUploadChunk(chunk("", 0, "x\n", true))
+	assert.ErrorContains(t, err, "invalid name")
+}
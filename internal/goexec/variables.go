@@ -0,0 +1,198 @@
+package goexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"html"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%variables`: it lists the memorized variables, with their static types and, if
+// initialized with a literal, their values (the values computed by the cells are not kept between executions).
+// With `--json`, the list is printed as JSON, for tools that read them.
+//
+// It also implements the kernel side of the [jupyterlab-variableinspector](https://github.com/jupyterlab-contrib/jupyterlab-variableinspector)
+// extension: the extension executes the commands of the "language model" of the kernel (see InspectorQueryCommand
+// and the following constants) as cells, and reads the variables, a JSON list of VariableInfo, from the
+// `text/plain` data of the `execute_result` replied.
+
+// maxVariableContentLen is the maximum length of the content shown for a variable, longer contents are
+// truncated.
+const maxVariableContentLen = 100
+
+// Commands of the jupyterlab-variableinspector language model of GoNB. The extension calls the delete, matrix and
+// widget commands with arguments, e.g. `%variables --inspector-delete('x')`. No variable is reported as a matrix
+// or a widget, so the matrix and widget commands only return an error.
+const (
+	InspectorQueryCommand       = "%variables --inspector"
+	InspectorDeleteCommand      = "%variables --inspector-delete"
+	InspectorMatrixQueryCommand = "%variables --inspector-matrix"
+	InspectorWidgetQueryCommand = "%variables --inspector-widget"
+)
+
+// VariableInfo describes a memorized variable, with the JSON fields of the variables (`IVariable`) of the
+// jupyterlab-variableinspector extension.
+type VariableInfo struct {
+	Name     string `json:"varName"`
+	Type     string `json:"varType"`
+	Size     string `json:"varSize"`
+	Shape    string `json:"varShape"`
+	Content  string `json:"varContent"`
+	IsMatrix bool   `json:"isMatrix"`
+	IsWidget bool   `json:"isWidget"`
+}
+
+// variableTypesCache caches the types of the memorized variables, keyed by the source code with the
+// declarations they were inferred from.
+type variableTypesCache struct {
+	source string
+	types  map[string]string
+}
+
+// variableTypes returns the static types of the memorized variables, type-checking the memorized
+// declarations with go/types. Packages are imported from their sources (with the notebook's `go.mod`), and
+// the results are cached until the declarations change. Variables whose types can't be inferred are omitted.
+func (s *State) variableTypes() (map[string]string, error) {
+	var buf bytes.Buffer
+	if _, _, err := s.createCodeFromDecls(&buf, s.Definitions, nil); err != nil {
+		return nil, errors.WithMessagef(err, "failed to compose the memorized declarations")
+	}
+	source := buf.String()
+	if s.variableTypesCache != nil && s.variableTypesCache.source == source {
+		return s.variableTypesCache.types, nil
+	}
+
+	pkg, err := s.typeCheckSource(token.NewFileSet(), source, "%variables")
+	if err != nil {
+		return nil, err
+	}
+	qualifier := packageQualifier(pkg)
+	varTypes := make(map[string]string)
+	for _, v := range s.Definitions.Variables {
+		obj := pkg.Scope().Lookup(v.Name)
+		if obj == nil || obj.Type() == nil || obj.Type() == types.Typ[types.Invalid] {
+			continue
+		}
+		varTypes[v.Name] = types.TypeString(obj.Type(), qualifier)
+	}
+	s.variableTypesCache = &variableTypesCache{source: source, types: varTypes}
+	return varTypes, nil
+}
+
+// typeCheckSource parses and type-checks the source of `main.go` composed with the memorized declarations, as
+// much as possible: errors are only logged (prefixed with the command using it), and the package returned
+// contains the objects that could be type-checked. Packages are imported from their sources.
+func (s *State) typeCheckSource(fset *token.FileSet, source, command string) (*types.Package, error) {
+	// The file is placed in TempDir, so the imports are resolved with the notebook's `go.mod`.
+	file, err := parser.ParseFile(fset, path.Join(s.TempDir, MainGo), source, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the memorized declarations")
+	}
+	config := &types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) { klog.V(2).Infof("%s: %v", command, err) },
+	}
+	pkg, _ := config.Check("main", fset, []*ast.File{file}, nil)
+	return pkg, nil
+}
+
+// packageQualifier returns a types.Qualifier that omits the name of pkg, and uses the names of other packages.
+func packageQualifier(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
+
+// InspectVariables returns the information of the memorized variables, sorted by name.
+//
+// The content of a variable is its value if it is initialized with a literal, and otherwise the expression
+// that initializes it, prefixed by "=": the values of the variables are not kept between executions.
+func (s *State) InspectVariables() []VariableInfo {
+	varTypes, err := s.variableTypes()
+	if err != nil {
+		klog.Warningf("%%variables: failed to infer the types of the variables: %+v", err)
+	}
+	infos := make([]VariableInfo, 0, len(s.Definitions.Variables))
+	for _, v := range s.Definitions.Variables {
+		if v.Name == "_" {
+			continue
+		}
+		info := VariableInfo{Name: v.Name, Type: varTypes[v.Name]}
+		if info.Type == "" {
+			info.Type = strings.TrimSpace(v.TypeDefinition)
+		}
+		if value, ok := literalValue(v.ValueDefinition); ok {
+			info.Content = value
+		} else if v.ValueDefinition != "" {
+			info.Content = "= " + strings.Join(strings.Fields(v.ValueDefinition), " ")
+		}
+		if runes := []rune(info.Content); len(runes) > maxVariableContentLen {
+			info.Content = string(runes[:maxVariableContentLen]) + "…"
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Variables implements `%variables`: it displays the memorized variables as a table, or, if asJSON is set,
+// prints them as JSON (as `text/plain`), with the fields used by the jupyterlab-variableinspector extension.
+// See also InspectVariablesResult, for the extension itself.
+func (s *State) Variables(msg kernel.Message, asJSON bool) error {
+	infos := s.InspectVariables()
+	if asJSON {
+		encoded, err := json.Marshal(infos)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode the variables")
+		}
+		return kernel.PublishDisplayData(msg, kernel.Data{
+			Data: kernel.MIMEMap{string(protocol.MIMETextPlain): string(encoded)}})
+	}
+	if len(infos) == 0 {
+		return kernel.PublishMarkdown(msg, "No memorized variables.")
+	}
+	var sb strings.Builder
+	sb.WriteString("<table>\n<tr><th>Name</th><th>Type</th><th>Value</th></tr>\n")
+	for _, info := range infos {
+		sb.WriteString(fmt.Sprintf("<tr><td><code>%s</code></td><td><code>%s</code></td><td style=\"text-align: left\">%s</td></tr>\n",
+			html.EscapeString(info.Name), html.EscapeString(info.Type), html.EscapeString(info.Content)))
+	}
+	sb.WriteString("</table>")
+	return kernel.PublishHtml(msg, sb.String())
+}
+
+// inspectorData returns the reply to the InspectorQueryCommand of the jupyterlab-variableinspector extension:
+// the JSON list of the variables, as `text/plain`.
+func inspectorData(infos []VariableInfo) (kernel.Data, error) {
+	encoded, err := json.Marshal(infos)
+	if err != nil {
+		return kernel.Data{}, errors.Wrapf(err, "failed to encode the variables")
+	}
+	return kernel.Data{Data: kernel.MIMEMap{string(protocol.MIMETextPlain): string(encoded)}}, nil
+}
+
+// InspectVariablesResult implements InspectorQueryCommand (`%variables --inspector`): it publishes the memorized
+// variables as the `execute_result` read by the jupyterlab-variableinspector extension.
+func (s *State) InspectVariablesResult(msg kernel.Message) error {
+	data, err := inspectorData(s.InspectVariables())
+	if err != nil {
+		return err
+	}
+	return kernel.PublishExecuteResult(msg, data)
+}
@@ -0,0 +1,71 @@
+package goexec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectVariables(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	d := s.Definitions
+	d.Imports["strings"] = NewImport("strings", "")
+	d.Types["Point"] = &TypeDecl{Key: "Point", TypeDefinition: "Point struct{ X, Y int }"}
+	d.Variables["name"] = &Variable{Key: "name", Name: "name", ValueDefinition: `"gopher"`}
+	d.Variables["builder"] = &Variable{Key: "builder", Name: "builder", ValueDefinition: "&strings.Builder{}"}
+	d.Variables["points"] = &Variable{Key: "points", Name: "points", ValueDefinition: "make([]Point, 0,\n\t10)"}
+	d.Variables["count"] = &Variable{Key: "count", Name: "count", TypeDefinition: "int64"}
+
+	infos := s.InspectVariables()
+	assert.Equal(t, []VariableInfo{
+		{Name: "builder", Type: "*strings.Builder", Content: "= &strings.Builder{}"},
+		{Name: "count", Type: "int64"},
+		{Name: "name", Type: "string", Content: "gopher"},
+		{Name: "points", Type: "[]Point", Content: "= make([]Point, 0, 10)"},
+	}, infos)
+	require.NotNil(t, s.variableTypesCache)
+
+	// Variables whose type can't be inferred keep their declared type, if any.
+	d.Variables["broken"] = &Variable{Key: "broken", Name: "broken", ValueDefinition: "undefinedFunc()"}
+	infos = s.InspectVariables()
+	assert.Equal(t, VariableInfo{Name: "broken", Content: "= undefinedFunc()"}, infos[0])
+}
+
+func TestInspectorData(t *testing.T) {
+	infos := []VariableInfo{
+		{Name: "count", Type: "int64"},
+		{Name: "name", Type: "string", Content: "it's \"gopher\""},
+	}
+	data, err := inspectorData(infos)
+	require.NoError(t, err)
+
+	// The jupyterlab-variableinspector extension reads the "text/plain" of the result, strips the surrounding
+	// quotes if there are any (Python kernels return the repr of a string) and parses it as a list of IVariable.
+	text, ok := data.Data[string(protocol.MIMETextPlain)].(string)
+	require.True(t, ok, "text/plain missing in %+v", data.Data)
+	if strings.HasPrefix(text, "'") || strings.HasPrefix(text, `"`) {
+		text = text[1 : len(text)-1]
+	}
+	var parsed []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(text), &parsed))
+	require.Len(t, parsed, 2)
+	for _, variable := range parsed {
+		for _, field := range []string{"varName", "varType", "varSize", "varShape", "varContent"} {
+			assert.IsType(t, "", variable[field], "field %q of %v", field, variable)
+		}
+		for _, field := range []string{"isMatrix", "isWidget"} {
+			assert.Equal(t, false, variable[field], "field %q of %v", field, variable)
+		}
+	}
+	assert.Equal(t, "count", parsed[0]["varName"])
+	assert.Equal(t, "int64", parsed[0]["varType"])
+	assert.Equal(t, `it's "gopher"`, parsed[1]["varContent"])
+}
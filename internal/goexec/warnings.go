@@ -0,0 +1,98 @@
+package goexec
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%config hide_warnings=<regexp>`: lines of the build output matching the regular
+// expression (e.g.: recurring warnings of generated code, or of the C compiler for cgo) are not displayed.
+// The warnings printed by successful builds are displayed to stderr.
+
+// reBuildWarning matches the lines of the build output that are warnings, e.g.: of the C compiler (for cgo)
+// or of the linker.
+var reBuildWarning = regexp.MustCompile(`(?i)\bwarning\b`)
+
+// SetHideWarnings sets the regular expression of the lines of the build output not displayed. An empty
+// pattern displays all lines.
+func (s *State) SetHideWarnings(pattern string) error {
+	if pattern == "" {
+		s.HideWarnings = nil
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "invalid regular expression %q for hide_warnings", pattern)
+	}
+	s.HideWarnings = re
+	return nil
+}
+
+// filterWarnings returns the build output without the lines matched by State.HideWarnings. The headers
+// of the packages (`# <package>` lines) left without any line are also removed.
+func (s *State) filterWarnings(output string) string {
+	if s.HideWarnings == nil {
+		return output
+	}
+	var kept []string
+	header := -1 // Index in kept of the last package header.
+	hidden := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			if header >= 0 && header == len(kept)-1 {
+				// Previous header has no more lines.
+				kept = kept[:header]
+			}
+			header = len(kept)
+			kept = append(kept, line)
+			continue
+		}
+		if s.HideWarnings.MatchString(line) {
+			hidden++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if header >= 0 && header == len(kept)-1 {
+		kept = kept[:header]
+	}
+	klog.V(1).Infof("hide_warnings: %d lines of the build output hidden", hidden)
+	return strings.Join(kept, "\n")
+}
+
+// buildWarnings returns the output of a successful build, filtered with State.HideWarnings, if it contains
+// any warnings, or "" otherwise. The messages of the `go` tool itself (e.g.: `go: downloading ...`) are
+// removed.
+func (s *State) buildWarnings(output string) string {
+	var kept []string
+	hasWarnings := false
+	for _, line := range strings.Split(s.filterWarnings(output), "\n") {
+		if strings.HasPrefix(line, "go: ") {
+			continue
+		}
+		hasWarnings = hasWarnings || reBuildWarning.MatchString(line)
+		kept = append(kept, line)
+	}
+	if !hasWarnings {
+		return ""
+	}
+	return strings.Join(kept, "\n")
+}
+
+// publishBuildWarnings displays to stderr the warnings in the output of a successful build, if any.
+// See buildWarnings.
+func (s *State) publishBuildWarnings(msg kernel.Message, output string) {
+	output = s.buildWarnings(output)
+	if output == "" {
+		return
+	}
+	output = s.mapGoPackageReferences(s.mapCSourceReferences(output))
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, output)
+}
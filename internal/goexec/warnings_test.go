@@ -0,0 +1,36 @@
+package goexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterWarnings(t *testing.T) {
+	s := &State{}
+	output := "# example.com/gen\ngen.c:3:2: warning: unused variable 'x'\n# command-line-arguments\n./main.go:5:2: x declared and not used\n"
+	assert.Equal(t, output, s.filterWarnings(output))
+
+	require.NoError(t, s.SetHideWarnings(`^gen\.c:.*warning:`))
+	assert.Equal(t, "# command-line-arguments\n./main.go:5:2: x declared and not used\n", s.filterWarnings(output))
+
+	require.Error(t, s.SetHideWarnings(`(`))
+	require.NoError(t, s.SetHideWarnings(""))
+	assert.Nil(t, s.HideWarnings)
+	assert.Equal(t, output, s.filterWarnings(output))
+}
+
+func TestBuildWarnings(t *testing.T) {
+	s := &State{}
+	assert.Empty(t, s.buildWarnings(""))
+	assert.Empty(t, s.buildWarnings("go: downloading example.com/m v1.0.0\n# example.com/m\n"))
+
+	output := "go: downloading example.com/gen v1.0.0\n# example.com/gen\ngen.c:3:2: warning: unused variable 'x'\n" +
+		"    3 |   int x;\n"
+	assert.Equal(t, "# example.com/gen\ngen.c:3:2: warning: unused variable 'x'\n    3 |   int x;\n", s.buildWarnings(output))
+
+	// Warnings hidden with hide_warnings.
+	require.NoError(t, s.SetHideWarnings(`^gen\.c:.*warning:`))
+	assert.Empty(t, s.buildWarnings(output))
+}
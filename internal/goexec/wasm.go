@@ -2,10 +2,13 @@ package goexec
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/janpfeifer/gonb/gonbui/protocol"
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/pkg/errors"
+	"html"
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
@@ -56,19 +59,16 @@ func (s *State) MakeWasmSubdir() (err error) {
 
 	// Copy over `wasm_exec.js` if needed.
 	var wasmExecSrc string
-	wasmExecSrc, err = GoRoot()
+	wasmExecSrc, err = WasmExecJsPath()
 	if err != nil {
-		err = errors.WithMessage(err, "failed to find GOROOT, needed to copy wasm_exec.js for WASM programs")
 		return
 	}
-	klog.Infof("GOROOT=%q", goRoot)
-	wasmExecSrc = path.Join(wasmExecSrc, "misc", "wasm", "wasm_exec.js")
 	wasmExecDst := path.Join(s.WasmDir, "wasm_exec.js")
 
 	var data []byte
 	data, err = os.ReadFile(wasmExecSrc)
 	if err != nil {
-		err = errors.Wrapf(err, "failed to read '$GOROOT/misc/wasm/wasm_exec.js'")
+		err = errors.Wrapf(err, "failed to read %q", wasmExecSrc)
 		return
 	}
 	err = os.WriteFile(wasmExecDst, data, 0775)
@@ -145,6 +145,33 @@ func GoRoot() (string, error) {
 	return goRoot, nil
 }
 
+// WasmExecJsPath returns the path to the `wasm_exec.js` of the Go compiler, needed to run WASM programs
+// in the browser. It is in `$GOROOT/lib/wasm` since Go 1.24, and in `$GOROOT/misc/wasm` before.
+func WasmExecJsPath() (string, error) {
+	goRoot, err := GoRoot()
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to find GOROOT, needed to find wasm_exec.js for WASM programs")
+	}
+	klog.V(1).Infof("GOROOT=%q", goRoot)
+	var wasmExecPath string
+	for _, dir := range []string{"lib", "misc"} {
+		wasmExecPath = path.Join(goRoot, dir, "wasm", "wasm_exec.js")
+		if _, err = os.Stat(wasmExecPath); err == nil {
+			return wasmExecPath, nil
+		}
+	}
+	return "", errors.Wrapf(err, "failed to find wasm_exec.js in GOROOT=%q", goRoot)
+}
+
+// CompiledWasmPath is the path where the wasm of the current cell is compiled to.
+// If CellWasmIframe is set, it is kept in the kernel's temporary directory, since it is not served by Jupyter.
+func (s *State) CompiledWasmPath() string {
+	if s.CellWasmIframe {
+		return path.Join(s.TempDir, CompiledWasmName)
+	}
+	return path.Join(s.WasmDir, CompiledWasmName)
+}
+
 var (
 	runWasmHtml = template.Must(template.New("wasm_exec_html").Parse(
 		`<div id="{{.WasmDivId}}"></div><script src="{{.WasmExecJsUrl}}"></script>`))
@@ -162,8 +189,12 @@ var (
 )
 
 // ExecuteWasm expects `wasm_exec.js` and CompiledWasmName to be in the directory
-// pointed to `s.WasmDir` already.
+// pointed to `s.WasmDir` already -- or, if CellWasmIframe is set, the compiled wasm to be in
+// CompiledWasmPath.
 func (s *State) ExecuteWasm(msg kernel.Message) error {
+	if s.CellWasmIframe {
+		return s.executeWasmInIframe(msg)
+	}
 	data := struct {
 		Id, WasmExecJsUrl, CompiledWasmUrl, WasmDivId string
 		Args                                          []string
@@ -233,3 +264,64 @@ func (s *State) RemoveWasmConstants(decls *Declarations) {
 	delete(decls.Constants, "GonbWasmUrl")
 	delete(decls.Constants, "GonbWasmDivId")
 }
+
+var runWasmIframeHtml = template.Must(template.New("wasm_iframe_html").Parse(
+	`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><script>{{.WasmExecJs}}</script></head>
+<body>
+<div id="{{.WasmDivId}}"></div>
+<script>
+(() => {
+	const wasm = Uint8Array.from(atob("{{.WasmBase64}}"), (c) => c.charCodeAt(0));
+	const go = new Go();
+	go.argv = ["js"].concat({{.ArgsJson}});
+	WebAssembly.instantiate(wasm, go.importObject).
+		then((result) => { go.run(result.instance); }).
+		catch((err) => { document.body.append("Failed to run WASM: " + err); });
+})();
+</script>
+</body>
+</html>
+`))
+
+// executeWasmInIframe embeds the compiled wasm and `wasm_exec.js` in an iframe in the output of the cell,
+// where it runs. Everything is served from the kernel (in the iframe's `srcdoc`), so it doesn't depend on
+// Jupyter serving the files, and the program runs isolated from the notebook page.
+func (s *State) executeWasmInIframe(msg kernel.Message) error {
+	wasmExecPath, err := WasmExecJsPath()
+	if err != nil {
+		return err
+	}
+	wasmExecJs, err := os.ReadFile(wasmExecPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", wasmExecPath)
+	}
+	wasm, err := os.ReadFile(s.CompiledWasmPath())
+	if err != nil {
+		return errors.Wrapf(err, "failed to read compiled wasm %q", s.CompiledWasmPath())
+	}
+	args := s.Args
+	if args == nil {
+		args = []string{}
+	}
+	argsJson, err := json.Marshal(args)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode arguments %q", args)
+	}
+	data := struct {
+		WasmDivId, WasmBase64, WasmExecJs, ArgsJson string
+	}{
+		WasmDivId:  s.WasmDivId,
+		WasmBase64: base64.StdEncoding.EncodeToString(wasm),
+		WasmExecJs: string(wasmExecJs),
+		ArgsJson:   string(argsJson),
+	}
+	var buf bytes.Buffer
+	if err = runWasmIframeHtml.Execute(&buf, &data); err != nil {
+		return errors.Wrapf(err, "failed to generate html to bootstrap WASM")
+	}
+	return kernel.PublishHtml(msg, fmt.Sprintf(
+		"<iframe srcdoc=\"%s\" style=\"width: 100%%; height: 400px; border: none; resize: vertical\"></iframe>\n",
+		html.EscapeString(buf.String())))
+}
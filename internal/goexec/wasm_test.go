@@ -0,0 +1,21 @@
+package goexec
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasmPaths(t *testing.T) {
+	wasmExecPath, err := WasmExecJsPath()
+	require.NoError(t, err)
+	assert.FileExists(t, wasmExecPath)
+	assert.Equal(t, "wasm_exec.js", path.Base(wasmExecPath))
+
+	s := &State{TempDir: "/tmp/gonb_test", WasmDir: "/jupyter/jupyter_files/test"}
+	assert.Equal(t, "/jupyter/jupyter_files/test/"+CompiledWasmName, s.CompiledWasmPath())
+	s.CellWasmIframe = true
+	assert.Equal(t, "/tmp/gonb_test/"+CompiledWasmName, s.CompiledWasmPath())
+}
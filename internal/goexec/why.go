@@ -0,0 +1,211 @@
+package goexec
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/janpfeifer/gonb/internal/kernel"
+)
+
+// This file implements `%why`: after a failed build it reports which cell each failing declaration came
+// from, which memorized declarations the failed execution replaced, and the diff of the composed code
+// against the one of the last successful build -- to debug "it worked a minute ago" situations.
+
+// buildFailure records the last failed build, see State.recordBuildFailure.
+type buildFailure struct {
+	cellId   int
+	composed *composedCode
+	output   string // Output of the compiler.
+
+	// replaced and added are the keys of the declarations replaced (with a different definition) and
+	// added by the cell.
+	replaced, added []string
+	previousIds     map[string]int // Cells where the replaced declarations were defined.
+}
+
+// recordBuildFailure records the failed build of the cell, with its updated declarations (decls), to be
+// reported by `%why`.
+func (s *State) recordBuildFailure(cellId int, decls *Declarations) {
+	failure := &buildFailure{
+		cellId:      cellId,
+		composed:    s.lastComposed,
+		output:      s.lastBuildOutput,
+		previousIds: declarationCellIds(s.Definitions),
+	}
+	previous := declarationDefinitions(s.Definitions)
+	for key, definition := range declarationDefinitions(decls) {
+		previousDefinition, found := previous[key]
+		switch {
+		case !found:
+			failure.added = append(failure.added, key)
+		case previousDefinition != definition:
+			failure.replaced = append(failure.replaced, key)
+		}
+	}
+	sort.Strings(failure.added)
+	sort.Strings(failure.replaced)
+	s.lastBuildFailure = failure
+}
+
+// recordBuildSuccess records the code composed for a successful build, to be diffed by `%why` in a later
+// failure.
+func (s *State) recordBuildSuccess() {
+	s.lastGoodComposed = s.lastComposed
+	s.lastBuildFailure = nil
+}
+
+// failingLocation is an error reported by the compiler in the composed code, mapped to the cell.
+type failingLocation struct {
+	line, col        int // In the composed file, 1-based.
+	cellId, cellLine int // Cell line is 0-based, NoCursorLine if not known.
+	declaration      string
+	message          string
+}
+
+// failingLocations parses the errors in the compiler output that refer to the composed file, and maps
+// them to their cells and the enclosing top-level declaration.
+func (f *buildFailure) failingLocations() []failingLocation {
+	if f.composed == nil {
+		return nil
+	}
+	re := regexp.MustCompile(`(?m)^(?:\./)?` + regexp.QuoteMeta(f.composed.fileName) + `:(\d+):(\d+): (.*)$`)
+	fileSet := token.NewFileSet()
+	fileAst, _ := parser.ParseFile(fileSet, f.composed.fileName, f.composed.code, parser.AllErrors) // Partial AST on errors.
+	var tokFile *token.File
+	if fileAst != nil {
+		tokFile = fileSet.File(fileAst.Pos())
+	}
+	var locations []failingLocation
+	for _, match := range re.FindAllStringSubmatch(f.output, -1) {
+		loc := failingLocation{cellId: NoCursorLine, cellLine: NoCursorLine, declaration: "?", message: match[3]}
+		loc.line, _ = strconv.Atoi(match[1])
+		loc.col, _ = strconv.Atoi(match[2])
+		if loc.line >= 1 && loc.line <= len(f.composed.fileToCellIdAndLine) {
+			cellIdAndLine := f.composed.fileToCellIdAndLine[loc.line-1]
+			loc.cellId, loc.cellLine = cellIdAndLine.Id, cellIdAndLine.Line
+		}
+		if tokFile != nil && loc.line <= tokFile.LineCount() {
+			offset := tokFile.Offset(tokFile.LineStart(loc.line)) + max(loc.col-1, 0)
+			if offset < tokFile.Size() {
+				loc.declaration = enclosingDeclarationName(fileAst, tokFile, offset)
+			}
+		}
+		locations = append(locations, loc)
+	}
+	return locations
+}
+
+// whyDiffContext is the number of unchanged lines shown around the changes in the diff of `%why`.
+const whyDiffContext = 2
+
+// composedDiff returns the changed lines from the code a to b, with whyDiffContext lines of context around
+// them, and `...` for the unchanged lines omitted. It returns "" if there are no changes.
+func composedDiff(a, b string) string {
+	lines := gonbui.DiffLines(strings.Split(a, "\n"), strings.Split(b, "\n"))
+	shown := make([]bool, len(lines))
+	changed := false
+	for ii, line := range lines {
+		if line.Op == ' ' {
+			continue
+		}
+		changed = true
+		for jj := max(ii-whyDiffContext, 0); jj <= min(ii+whyDiffContext, len(lines)-1); jj++ {
+			shown[jj] = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	var sb strings.Builder
+	skipped := false
+	for ii, line := range lines {
+		if !shown[ii] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			sb.WriteString("...\n")
+			skipped = false
+		}
+		sb.WriteString(string(line.Op) + line.Text + "\n")
+	}
+	if skipped {
+		sb.WriteString("...\n")
+	}
+	return sb.String()
+}
+
+// whyKeysList formats the keys of declarations as a Markdown list, with the cells where they were defined.
+func whyKeysList(sb *strings.Builder, keys []string, cellIds map[string]int) {
+	for _, key := range keys {
+		fmt.Fprintf(sb, "- `%s`", strings.Replace(key, "~", ".", 1))
+		if id, found := cellIds[key]; found && id > 0 {
+			fmt.Fprintf(sb, " (previously defined in cell [%d])", id)
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// WhyMarkdown returns the report of `%why`, in Markdown, about the last failed build.
+func (s *State) WhyMarkdown() string {
+	failure := s.lastBuildFailure
+	if failure == nil {
+		return "The last build succeeded (or no cell was built yet): nothing to explain."
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Why the build of cell [%d] failed\n\n", failure.cellId)
+
+	sb.WriteString("### Errors\n\n")
+	locations := failure.failingLocations()
+	if len(locations) == 0 {
+		sb.WriteString("No errors in the composed code: see the compiler output of the cell.\n")
+	}
+	for _, loc := range locations {
+		fmt.Fprintf(&sb, "- `%s:%d:%d`", failure.composed.fileName, loc.line, loc.col)
+		if loc.cellLine != NoCursorLine {
+			if loc.cellId == NoCursorLine || loc.cellId == failure.cellId {
+				fmt.Fprintf(&sb, ", this cell, line %d", loc.cellLine+1)
+			} else {
+				fmt.Fprintf(&sb, ", cell [%d], line %d", loc.cellId, loc.cellLine+1)
+			}
+		}
+		fmt.Fprintf(&sb, ", in `%s`: %s\n", loc.declaration, loc.message)
+	}
+
+	if len(failure.replaced) > 0 {
+		sb.WriteString("\n### Declarations replaced by this execution\n\n")
+		whyKeysList(&sb, failure.replaced, failure.previousIds)
+	}
+	if len(failure.added) > 0 {
+		sb.WriteString("\n### Declarations added by this execution\n\n")
+		whyKeysList(&sb, failure.added, nil)
+	}
+
+	sb.WriteString("\n### Changes since the last successful build\n\n")
+	switch {
+	case s.lastGoodComposed == nil:
+		sb.WriteString("No successful build yet.\n")
+	case failure.composed == nil:
+		sb.WriteString("The composed code of the failed build is not available.\n")
+	default:
+		diff := composedDiff(s.lastGoodComposed.code, failure.composed.code)
+		if diff == "" {
+			sb.WriteString("None: the composed code is the same as the last successful build " +
+				"(changes outside the notebook, e.g. in tracked files or `go.mod`, may be the cause).\n")
+		} else {
+			sb.WriteString("```diff\n" + diff + "```\n")
+		}
+	}
+	return sb.String()
+}
+
+// Why implements `%why`: it displays the report of the last failed build, see WhyMarkdown.
+func (s *State) Why(msg kernel.Message) error {
+	return kernel.PublishMarkdown(msg, s.WhyMarkdown())
+}
@@ -0,0 +1,52 @@
+package goexec
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposedDiff(t *testing.T) {
+	assert.Equal(t, "", composedDiff("a\nb\n", "a\nb\n"))
+	assert.Equal(t, "...\n c\n d\n-e\n+E\n f\n g\n...\n",
+		composedDiff("a\nb\nc\nd\ne\nf\ng\nh\ni", "a\nb\nc\nd\nE\nf\ng\nh\ni"))
+	assert.Equal(t, " a\n+b\n c\n", composedDiff("a\nc", "a\nb\nc"))
+}
+
+func TestWhyMarkdown(t *testing.T) {
+	s := &State{TempDir: t.TempDir(), Definitions: NewDeclarations()}
+	assert.Contains(t, s.WhyMarkdown(), "nothing to explain")
+
+	// Successful build of cell 1.
+	s.Definitions.Functions["f"] = &Function{CellLines: CellLines{Id: 1}, Key: "f", Name: "f",
+		Definition: "func f() int { return 1 }"}
+	require.NoError(t, os.WriteFile(s.CodePath(),
+		[]byte("package main\n\nfunc f() int { return 1 }\n\nfunc main() {\n}\n"), 0600))
+	s.recordComposed([]CellIdAndLine{{-1, -1}, {-1, -1}, {1, 0}, {-1, -1}, {1, 1}, {1, 2}})
+	s.recordBuildSuccess()
+
+	// Cell 2 redefines f, and adds g, with an error.
+	decls := s.Definitions.Copy()
+	decls.Functions["f"] = &Function{CellLines: CellLines{Id: 2}, Key: "f", Name: "f",
+		Definition: "func f() int { return undefinedX }"}
+	decls.Functions["g"] = &Function{CellLines: CellLines{Id: 2}, Key: "g", Name: "g", Definition: "func g() {}"}
+	require.NoError(t, os.WriteFile(s.CodePath(),
+		[]byte("package main\n\nfunc f() int { return undefinedX }\n\nfunc g() {}\n\nfunc main() {\n}\n"), 0600))
+	s.recordComposed([]CellIdAndLine{{-1, -1}, {-1, -1}, {2, 0}, {-1, -1}, {2, 2}, {-1, -1}, {2, 3}, {2, 4}})
+	s.lastBuildOutput = "# gonb_test\n./main.go:3:23: undefined: undefinedX\n"
+	s.recordBuildFailure(2, decls)
+
+	report := s.WhyMarkdown()
+	assert.Contains(t, report, "## Why the build of cell [2] failed")
+	assert.Contains(t, report, "- `main.go:3:23`, this cell, line 1, in `f`: undefined: undefinedX")
+	assert.Contains(t, report, "### Declarations replaced by this execution\n\n- `f` (previously defined in cell [1])")
+	assert.Contains(t, report, "### Declarations added by this execution\n\n- `g`\n")
+	assert.Contains(t, report, "-func f() int { return 1 }\n+func f() int { return undefinedX }\n")
+	assert.Contains(t, report, "+func g() {}\n")
+
+	// A successful build clears the failure.
+	s.recordBuildSuccess()
+	assert.Contains(t, s.WhyMarkdown(), "nothing to explain")
+}
@@ -0,0 +1,139 @@
+package goexec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the automatic wiring of local modules into the temporary module: if the
+// workspace directory (by default the current directory of the kernel, usually the notebook's directory)
+// has a `go.work` file or is itself a Go module, a `go.work` is created in State.TempDir using them.
+//
+// Since the `go.work` "use" paths are tracked (see State.AutoTrack), edits to those modules are picked up
+// on each execution. It's configured with the special command `%workspace`.
+
+// GoWorkPath is the path to the `go.work` file of the temporary module.
+func (s *State) GoWorkPath() string {
+	return path.Join(s.TempDir, "go.work")
+}
+
+// workspaceModules returns the absolute paths of the local modules in the workspace directory dir:
+// the `use` paths of its `go.work` file if there is one, or dir itself if it is a Go module.
+func workspaceModules(dir string) (modules []string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get absolute path of %q", dir)
+	}
+	goWorkPath := filepath.Join(dir, "go.work")
+	contents, err := os.ReadFile(goWorkPath)
+	if err == nil {
+		var workFile *modfile.WorkFile
+		workFile, err = modfile.ParseWork(goWorkPath, contents, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %q", goWorkPath)
+		}
+		for _, use := range workFile.Use {
+			p := use.Path
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			modules = append(modules, filepath.Clean(p))
+		}
+		return modules, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read %q", goWorkPath)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return []string{dir}, nil
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to check for go.mod in %q", dir)
+	}
+	return nil, nil
+}
+
+// composeGoWork returns the contents of the `go.work` using the temporary module and the given modules.
+func composeGoWork(goVersion string, modules []string) string {
+	var sb strings.Builder
+	if goVersion != "" {
+		sb.WriteString(fmt.Sprintf("go %s\n\n", goVersion))
+	}
+	sb.WriteString("use (\n\t.\n")
+	for _, module := range modules {
+		sb.WriteString(fmt.Sprintf("\t%s\n", modfile.AutoQuote(module)))
+	}
+	sb.WriteString(")\n")
+	return sb.String()
+}
+
+// AutoWorkspace creates (or updates) the `go.work` of the temporary module with the local modules of the
+// workspace directory (see State.WorkspaceDir), if any.
+//
+// A `go.work` created by the user in State.TempDir (e.g.: with `go work init`) is never overwritten.
+func (s *State) AutoWorkspace(msg kernel.Message) error {
+	if !s.AutoWorkspaceEnabled {
+		return nil
+	}
+	dir := s.WorkspaceDir
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get current directory")
+		}
+	}
+	modules, err := workspaceModules(dir)
+	if err != nil {
+		return err
+	}
+
+	// Check whether current go.work was created by us.
+	goWorkPath := s.GoWorkPath()
+	current, err := os.ReadFile(goWorkPath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %q", goWorkPath)
+	}
+	exists := err == nil
+	if exists && string(current) != s.autoWorkspaceContents {
+		klog.V(2).Infof("AutoWorkspace(): %q managed by the user, not changed", goWorkPath)
+		return nil
+	}
+
+	if len(modules) == 0 {
+		if exists {
+			s.autoWorkspaceContents = ""
+			if err = os.Remove(goWorkPath); err != nil {
+				return errors.Wrapf(err, "failed to remove %q", goWorkPath)
+			}
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+				fmt.Sprintf("* No local modules found in %q: removed `go.work`.\n", dir))
+		}
+		return nil
+	}
+
+	var goVersion string
+	if goModContents, err := os.ReadFile(s.GoModPath()); err == nil {
+		if modFile, err := modfile.ParseLax(s.GoModPath(), goModContents, nil); err == nil && modFile.Go != nil {
+			goVersion = modFile.Go.Version
+		}
+	}
+	contents := composeGoWork(goVersion, modules)
+	if exists && contents == string(current) {
+		return nil
+	}
+	err = os.WriteFile(goWorkPath, []byte(contents), 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %q", goWorkPath)
+	}
+	s.autoWorkspaceContents = contents
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("* Using local modules from workspace %q: %s\n", dir, strings.Join(modules, ", ")))
+}
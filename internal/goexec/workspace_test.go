@@ -0,0 +1,54 @@
+package goexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceModules(t *testing.T) {
+	dir := t.TempDir()
+	modules, err := workspaceModules(dir)
+	require.NoError(t, err)
+	assert.Empty(t, modules)
+
+	// Directory is a module.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/a\n"), 0600))
+	modules, err = workspaceModules(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir}, modules)
+
+	// A go.work takes precedence.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.work"),
+		[]byte("go 1.21\n\nuse (\n\t./a\n\t/opt/b\n)\n"), 0600))
+	modules, err = workspaceModules(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a"), "/opt/b"}, modules)
+
+	assert.Equal(t, "go 1.21\n\nuse (\n\t.\n\t/opt/b\n\t\"/opt/with space\"\n)\n",
+		composeGoWork("1.21", []string{"/opt/b", "/opt/with space"}))
+}
+
+func TestAutoWorkspaceOptIn(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	s.WorkspaceDir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(s.WorkspaceDir, "go.mod"), []byte("module example.com/a\n"), 0600))
+
+	// Disabled by default: local modules are not used.
+	require.False(t, s.AutoWorkspaceEnabled)
+	require.NoError(t, s.AutoWorkspace(nil))
+	assert.NoFileExists(t, s.GoWorkPath())
+
+	s.AutoWorkspaceEnabled = true
+	require.NoError(t, s.AutoWorkspace(nil))
+	contents, err := os.ReadFile(s.GoWorkPath())
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), s.WorkspaceDir)
+}
@@ -0,0 +1,145 @@
+package jpyexec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the detection of binary data written to the stdout of the program (see
+// Executor.WithBinaryOutput), e.g.: a PNG written by an external tool executed with `!`. Instead of corrupting
+// the text stream, the data is saved to a file and, if it is an image the front-end can render, displayed.
+
+// MaxBinaryOutputDisplaySize is the maximum size of the binary data written to stdout displayed: larger images
+// are only saved.
+const MaxBinaryOutputDisplaySize = 10 << 20
+
+// MinBinaryOutputSize is the minimum size of the first write to stdout for it to be considered binary data:
+// shorter writes are always text.
+const MinBinaryOutputSize = 16
+
+// binaryOutputSignature is the "magic" prefix that identifies a binary format, and its MIME type and extension.
+type binaryOutputSignature struct {
+	magic, mimeType, ext string
+}
+
+// binaryOutputSignatures are the formats detected in the stdout of the program: only images with strong
+// signatures, so text output is never mistaken for binary data.
+var binaryOutputSignatures = []binaryOutputSignature{
+	{"\x89PNG\r\n\x1a\n", "image/png", ".png"},
+	{"\xff\xd8\xff", "image/jpeg", ".jpg"},
+	{"GIF87a", "image/gif", ".gif"},
+	{"GIF89a", "image/gif", ".gif"},
+}
+
+// hasBinaryControlBytes returns whether data has control characters not used by text (including the ANSI
+// escape sequences of terminals).
+func hasBinaryControlBytes(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\b' && b != 0x1b {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffBinary returns the binary format of data, or nil if it is text. Data is only considered binary if it is
+// not text (invalid UTF-8, or with binary control bytes), and starts with the signature of a known format.
+func sniffBinary(data []byte) *binaryOutputSignature {
+	if len(data) < MinBinaryOutputSize || (utf8.Valid(data) && !hasBinaryControlBytes(data)) {
+		return nil
+	}
+	for ii := range binaryOutputSignatures {
+		if bytes.HasPrefix(data, []byte(binaryOutputSignatures[ii].magic)) {
+			return &binaryOutputSignatures[ii]
+		}
+	}
+	return nil
+}
+
+// binaryOutputWriter is an io.Writer for the stdout of the program, that detects (sniffs) binary data in the
+// first write. Text is written to the underlying writer, and binary data to a file in dir, displayed or
+// reported by finish.
+type binaryOutputWriter struct {
+	msg     kernel.Message
+	text    io.Writer
+	dir     string
+	sniffed bool
+	format  *binaryOutputSignature
+	file    *os.File
+	size    int64
+	err     error
+}
+
+// newBinaryOutputWriter creates a binaryOutputWriter, writing text to text and saving binary data in dir.
+func newBinaryOutputWriter(msg kernel.Message, text io.Writer, dir string) *binaryOutputWriter {
+	return &binaryOutputWriter{msg: msg, text: text, dir: dir}
+}
+
+// Write implements io.Writer.
+func (w *binaryOutputWriter) Write(p []byte) (int, error) {
+	if !w.sniffed && len(p) > 0 {
+		w.sniffed = true
+		w.format = sniffBinary(p)
+		if w.format != nil {
+			w.file, w.err = w.createFile()
+		}
+	}
+	if w.format == nil {
+		return w.text.Write(p)
+	}
+	if w.err == nil {
+		var n int
+		n, w.err = w.file.Write(p)
+		w.size += int64(n)
+	}
+	return len(p), nil // Errors are reported by finish, the output of the program is consumed regardless.
+}
+
+// createFile creates the file where the binary output is saved.
+func (w *binaryOutputWriter) createFile() (*os.File, error) {
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "failed to create directory %q for the binary output", w.dir)
+	}
+	f, err := os.CreateTemp(w.dir, "stdout_*"+w.format.ext)
+	return f, errors.Wrapf(err, "failed to create file for the binary output in %q", w.dir)
+}
+
+// finish is called when the stdout of the program is closed: if binary data was written, it is displayed, if it
+// is not too large, and the path of the file where it was saved is reported.
+func (w *binaryOutputWriter) finish() {
+	if w.format == nil {
+		return
+	}
+	if w.file != nil {
+		if err := w.file.Close(); err != nil && w.err == nil {
+			w.err = errors.Wrapf(err, "failed to close %q", w.file.Name())
+		}
+	}
+	if w.err != nil {
+		klog.Errorf("Failed to save the binary output of the program: %+v", w.err)
+		_ = kernel.PublishWriteStream(w.msg, kernel.StreamStderr, fmt.Sprintf(
+			"* The program wrote binary data (%s) to stdout, but it couldn't be saved: %v\n", w.format.mimeType, w.err))
+		return
+	}
+	report := fmt.Sprintf("Binary output (%s, %d bytes) saved in %s", w.format.mimeType, w.size, w.file.Name())
+	data := kernel.MIMEMap{"text/plain": report}
+	if w.size <= MaxBinaryOutputDisplaySize {
+		contents, err := os.ReadFile(w.file.Name())
+		if err != nil {
+			klog.Errorf("Failed to read the binary output of the program: %+v", err)
+		} else {
+			data[w.format.mimeType] = base64.StdEncoding.EncodeToString(contents)
+		}
+	}
+	if err := kernel.PublishDisplayData(w.msg, kernel.Data{Data: data}); err != nil {
+		klog.Errorf("Failed to publish the binary output of the program: %+v", err)
+	}
+}
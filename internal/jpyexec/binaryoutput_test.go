@@ -0,0 +1,66 @@
+package jpyexec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryOutputWriter(t *testing.T) {
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "binary")
+
+	// Text is passed through.
+	var text bytes.Buffer
+	w := newBinaryOutputWriter(msg, &text, dir)
+	_, err = w.Write([]byte("hello\x1b[1m world\n"))
+	require.NoError(t, err)
+	w.finish()
+	assert.Equal(t, "hello\x1b[1m world\n", text.String())
+	assert.Empty(t, msg.Outputs())
+
+	// A PNG image, written in parts, is saved and displayed.
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 4, 4))))
+	w = newBinaryOutputWriter(msg, &text, dir)
+	half := encoded.Len() / 2
+	for _, part := range [][]byte{encoded.Bytes()[:half], encoded.Bytes()[half:]} {
+		_, err = w.Write(part)
+		require.NoError(t, err)
+	}
+	w.finish()
+	require.Len(t, msg.Outputs(), 1)
+	data := msg.Outputs()[0]["data"].(kernel.MIMEMap)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(encoded.Bytes()), data["image/png"])
+	report := fmt.Sprint(data["text/plain"])
+	assert.Contains(t, report, fmt.Sprintf("Binary output (image/png, %d bytes) saved in %s", encoded.Len(), dir))
+	files, err := filepath.Glob(filepath.Join(dir, "stdout_*.png"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	saved, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, encoded.Bytes(), saved)
+
+	// Other binary data, and text that looks like a binary format, is passed through.
+	text.Reset()
+	for _, data := range []string{"\x00\x01\x02\x03\xff padding to min size", "BMI: 23.4\n", "ID3 tags of the song\n",
+		"%PDF-1.4 found in the header\n", "\x89PNG"} {
+		w = newBinaryOutputWriter(msg, &text, dir)
+		_, err = w.Write([]byte(data))
+		require.NoError(t, err)
+		w.finish()
+		assert.Equal(t, data, text.String())
+		text.Reset()
+	}
+	require.Len(t, msg.Outputs(), 1)
+}
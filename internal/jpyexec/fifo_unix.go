@@ -0,0 +1,42 @@
+//go:build !windows
+
+package jpyexec
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// newFifo creates a named pipe (with Mkfifo) with a unique name in dir, and returns its path.
+func newFifo(dir string) (string, error) {
+	// Create a temporary file name.
+	f, err := os.CreateTemp(dir, "gonb_pipe_")
+	if err != nil {
+		return "", err
+	}
+	pipePath := f.Name()
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Remove(pipePath); err != nil {
+		return "", err
+	}
+
+	// Create pipe.
+	if err = syscall.Mkfifo(pipePath, 0600); err != nil {
+		return "", errors.Wrapf(err, "failed to create pipe (Mkfifo) for %q", pipePath)
+	}
+	return pipePath, nil
+}
+
+// openFifo opens the named pipe created by newFifo. It blocks until the other end is opened.
+func openFifo(pipePath string, flag int) (*os.File, error) {
+	return os.OpenFile(pipePath, flag, 0600)
+}
+
+// removeFifo removes the named pipe created by newFifo.
+func removeFifo(pipePath string) {
+	_ = os.Remove(pipePath)
+}
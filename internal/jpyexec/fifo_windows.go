@@ -0,0 +1,75 @@
+//go:build windows
+
+package jpyexec
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// On Windows the named pipes are created in the `\\.\pipe\` namespace with CreateNamedPipe: the kernel
+// holds the server end, and the program opens the client end by its path (with os.OpenFile), like a FIFO.
+
+var (
+	// fifoHandles are the server handles of the named pipes created by newFifo and not yet opened, by path.
+	fifoHandles   = make(map[string]windows.Handle)
+	muFifoHandles sync.Mutex
+	fifoCount     atomic.Int64
+)
+
+// fifoBufferSize is the size of the buffers of the named pipes, in each direction.
+const fifoBufferSize = 64 * 1024
+
+// newFifo creates a named pipe with a unique name, and returns its path. dir is not used on Windows.
+func newFifo(dir string) (string, error) {
+	pipePath := fmt.Sprintf(`\\.\pipe\gonb_pipe_%d_%d`, os.Getpid(), fifoCount.Add(1))
+	name, err := windows.UTF16PtrFromString(pipePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid pipe name %q", pipePath)
+	}
+	handle, err := windows.CreateNamedPipe(name, windows.PIPE_ACCESS_DUPLEX,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT,
+		1, fifoBufferSize, fifoBufferSize, 0, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create pipe (CreateNamedPipe) for %q", pipePath)
+	}
+	muFifoHandles.Lock()
+	fifoHandles[pipePath] = handle
+	muFifoHandles.Unlock()
+	return pipePath, nil
+}
+
+// openFifo opens the server end of the named pipe created by newFifo. It blocks until the program opens
+// the client end. flag is not used, the pipe is opened for reading and writing.
+func openFifo(pipePath string, flag int) (*os.File, error) {
+	muFifoHandles.Lock()
+	handle, found := fifoHandles[pipePath]
+	delete(fifoHandles, pipePath)
+	muFifoHandles.Unlock()
+	if !found {
+		return nil, errors.Errorf("named pipe %q not created or already opened", pipePath)
+	}
+	err := windows.ConnectNamedPipe(handle, nil)
+	if err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		_ = windows.CloseHandle(handle)
+		return nil, errors.Wrapf(err, "failed to connect to named pipe %q", pipePath)
+	}
+	return os.NewFile(uintptr(handle), pipePath), nil
+}
+
+// removeFifo closes the server end of the named pipe created by newFifo, if it was never opened. The pipe
+// is removed by Windows when all its handles are closed.
+func removeFifo(pipePath string) {
+	muFifoHandles.Lock()
+	handle, found := fifoHandles[pipePath]
+	delete(fifoHandles, pipePath)
+	muFifoHandles.Unlock()
+	if found {
+		_ = windows.CloseHandle(handle)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"io"
 	"k8s.io/klog/v2"
+	"os"
 	osexec "os/exec"
 	"sync"
 	"time"
@@ -28,11 +29,14 @@ type Executor struct {
 	command                    string
 	args                       []string
 	dir                        string
+	env                        []string
 	useNamedPipes              bool
 	commsHandler               CommsHandler
 	stdoutWriter, stderrWriter io.Writer
 	millisecondsToInput        int
 	inputPassword              bool
+	displayMaxFPS              int
+	binaryOutputDir            string
 
 	// State when execution starts (after call to Exec)
 	cmd                                      *osexec.Cmd
@@ -53,6 +57,9 @@ type Executor struct {
 	// Currently, it is assumed that it will be used by the CommsHandler.
 	PipeWriterFifo chan *protocol.CommValue
 
+	// displayLimiter rate limits the display updates published by the program, if displayMaxFPS is set.
+	displayLimiter *displayRateLimiter
+
 	isDone   bool
 	doneChan chan struct{}
 	muDone   sync.Mutex
@@ -106,6 +113,29 @@ func (exec *Executor) InDir(dir string) *Executor {
 	return exec
 }
 
+// WithEnv configures extra environment variables (in the form "key=value") for the execution, on top
+// of the environment of the kernel. Returns the modified builder.
+func (exec *Executor) WithEnv(env []string) *Executor {
+	exec.env = env
+	return exec
+}
+
+// WithDisplayRateLimit configures the updates of each display (with a "display_id") published by the program,
+// through the named pipes, to be coalesced to at most maxFPS per second. The last update is always published.
+// If maxFPS is 0, the updates are not rate limited. Returns the modified builder.
+func (exec *Executor) WithDisplayRateLimit(maxFPS int) *Executor {
+	exec.displayMaxFPS = maxFPS
+	return exec
+}
+
+// WithBinaryOutput configures the detection of binary data (e.g.: an image) written to stdout: instead of
+// being streamed as text, it is saved to a file in dir and, if it is an image, displayed. If dir is empty, the
+// detection is disabled. Returns the modified builder.
+func (exec *Executor) WithBinaryOutput(dir string) *Executor {
+	exec.binaryOutputDir = dir
+	return exec
+}
+
 // WithStderr configures piping of stderr to the given `io.Writer`.
 func (exec *Executor) WithStderr(stderrWriter io.Writer) *Executor {
 	exec.stderrWriter = stderrWriter
@@ -162,6 +192,9 @@ func (exec *Executor) Exec() error {
 	cmd := osexec.Command(exec.command, exec.args...)
 	exec.cmd = cmd
 	cmd.Dir = exec.dir
+	if len(exec.env) > 0 {
+		cmd.Env = append(cmd.Environ(), exec.env...)
+	}
 
 	var err error
 	exec.cmdStdout, err = cmd.StdoutPipe()
@@ -184,14 +217,23 @@ func (exec *Executor) Exec() error {
 	if exec.stderrWriter == nil {
 		exec.stderrWriter = kernel.NewJupyterStreamWriter(exec.Msg, kernel.StreamStderr)
 	}
+	stdoutWriter := exec.stdoutWriter
+	var binaryWriter *binaryOutputWriter
+	if exec.binaryOutputDir != "" {
+		binaryWriter = newBinaryOutputWriter(exec.Msg, stdoutWriter, exec.binaryOutputDir)
+		stdoutWriter = binaryWriter
+	}
 	var streamersWG sync.WaitGroup
 	streamersWG.Add(2)
 	go func() {
 		defer streamersWG.Done()
-		_, err := io.Copy(exec.stdoutWriter, exec.cmdStdout)
+		_, err := io.Copy(stdoutWriter, exec.cmdStdout)
 		if err != nil {
 			klog.Errorf("Failed copying execution stdout: %+v", err)
 		}
+		if binaryWriter != nil {
+			binaryWriter.finish()
+		}
 	}()
 	go func() {
 		defer streamersWG.Done()
@@ -217,13 +259,21 @@ func (exec *Executor) Exec() error {
 	}
 
 	// Start command.
+	setProcessGroup(cmd)
 	if err := cmd.Start(); err != nil {
 		klog.Warningf("Failed to start command %q", exec.command)
 		return errors.WithMessagef(err, "failed to start to execute command %q", exec.command)
 	}
 
+	// Interrupt the program on `interrupt_request` messages, see kernel.Kernel.Interrupt.
+	if k := exec.Msg.Kernel(); k != nil {
+		unregister := k.OnInterrupt(func() { interruptProcess(cmd) })
+		defer unregister()
+	}
+
 	// Wait for output pipes to finish.
 	streamersWG.Wait()
+	exec.Msg.Kernel().FlushStreams()
 	if err := cmd.Wait(); err != nil {
 		errMsg := err.Error() + "\n"
 		if exec.Msg.Kernel().Interrupted.Load() {
@@ -238,6 +288,15 @@ func (exec *Executor) Exec() error {
 	return nil
 }
 
+// ProcessState returns the state of the executed program after Exec returns, including its
+// exit status. It returns nil if the program was not executed.
+func (exec *Executor) ProcessState() *os.ProcessState {
+	if exec.cmd == nil {
+		return nil
+	}
+	return exec.cmd.ProcessState
+}
+
 // done signals program finished executing, and triggers the closing of everything.
 func (exec *Executor) done() {
 	exec.muDone.Lock()
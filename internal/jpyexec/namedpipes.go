@@ -16,7 +16,6 @@ import (
 	"k8s.io/klog/v2"
 	"os"
 	"sync"
-	"syscall"
 )
 
 func init() {
@@ -83,25 +82,9 @@ func (exec *Executor) handleNamedPipes() (err error) {
 	return
 }
 
+// createTmpFifo creates a new named pipe, with a unique name, see newFifo.
 func (exec *Executor) createTmpFifo() (string, error) {
-	// Create a temporary file name.
-	f, err := os.CreateTemp(exec.dir, "gonb_pipe_")
-	if err != nil {
-		return "", err
-	}
-	pipePath := f.Name()
-	if err = f.Close(); err != nil {
-		return "", err
-	}
-	if err = os.Remove(pipePath); err != nil {
-		return "", err
-	}
-
-	// Create pipe.
-	if err = syscall.Mkfifo(pipePath, 0600); err != nil {
-		return "", errors.Wrapf(err, "failed to create pipe (Mkfifo) for %q", pipePath)
-	}
-	return pipePath, nil
+	return newFifo(exec.dir)
 }
 
 // openPipeReader opens `exec.namedPipeReaderPath` and handles its proper closing, and removal of
@@ -133,7 +116,7 @@ func (exec *Executor) openPipeReader() {
 			}
 		}
 		muFifo.Unlock()
-		_ = os.Remove(exec.namedPipeReaderPath)
+		removeFifo(exec.namedPipeReaderPath)
 	}()
 
 	go func() {
@@ -145,7 +128,7 @@ func (exec *Executor) openPipeReader() {
 		// Notice that opening pipeReader below blocks, until the other end
 		// (the go program being executed) opens it as well.
 		var err error
-		exec.pipeReader, err = os.Open(exec.namedPipeReaderPath)
+		exec.pipeReader, err = openFifo(exec.namedPipeReaderPath, os.O_RDONLY)
 		if err != nil {
 			klog.Warningf("Failed to open pipe (Mkfifo) %q for reading: %+v", exec.namedPipeReaderPath, err)
 			return
@@ -161,13 +144,21 @@ func (exec *Executor) openPipeReader() {
 		// Wait program execution to finish to close reader (in case it is not yet closed).
 		<-exec.doneChan
 		_ = exec.pipeReader.Close()
-		_ = os.Remove(exec.namedPipeReaderPath)
+		removeFifo(exec.namedPipeReaderPath)
 	}()
 }
 
 // pollNamedPipeReader will continuously read for incoming requests with displaying content
 // on the notebook or widgets updates.
 func (exec *Executor) pollNamedPipeReader() {
+	if exec.displayMaxFPS > 0 {
+		exec.displayLimiter = newDisplayRateLimiter(exec.displayMaxFPS, func(data kernel.Data) {
+			if err := kernel.PublishUpdateDisplayData(exec.Msg, data); err != nil {
+				klog.Errorf("Failed to display data (ignoring): %v", err)
+			}
+		})
+		defer exec.displayLimiter.Close()
+	}
 	decoder := gob.NewDecoder(exec.pipeReader)
 	for {
 		data := &protocol.DisplayData{}
@@ -193,6 +184,15 @@ func (exec *Executor) pollNamedPipeReader() {
 			continue
 		}
 
+		// Clear the outputs of the cell.
+		if waitAny, found := data.Data[protocol.MIMEClearOutput]; found {
+			wait, _ := waitAny.(bool)
+			if err := kernel.PublishClearOutput(exec.Msg, wait); err != nil {
+				klog.Errorf("Failed to clear output (ignoring): %v", err)
+			}
+			continue
+		}
+
 		// CommValue: update or read value in the front-end.
 		if reqAny, found := data.Data[protocol.MIMECommValue]; found {
 			req, ok := reqAny.(protocol.CommValue)
@@ -291,6 +291,10 @@ func (exec *Executor) dispatchDisplayData(data *protocol.DisplayData) {
 	var err error
 	if data.DisplayID != "" {
 		msgData.Transient["display_id"] = data.DisplayID
+		if exec.displayLimiter != nil {
+			exec.displayLimiter.Publish(data.DisplayID, msgData)
+			return
+		}
 		err = kernel.PublishUpdateDisplayData(exec.Msg, msgData)
 	} else {
 		err = kernel.PublishData(exec.Msg, msgData)
@@ -362,7 +366,7 @@ func (exec *Executor) openPipeWriter() {
 			}
 		}
 		muFifo.Unlock()
-		_ = os.Remove(exec.namedPipeWriterPath)
+		removeFifo(exec.namedPipeWriterPath)
 	}()
 
 	go func() {
@@ -374,7 +378,7 @@ func (exec *Executor) openPipeWriter() {
 		}
 		// Notice that opening the pipe below blocks, until the other end (the go program being executed) opens it
 		// as well.
-		f, err := os.OpenFile(exec.namedPipeWriterPath, os.O_WRONLY, 0600)
+		f, err := openFifo(exec.namedPipeWriterPath, os.O_WRONLY)
 		if err != nil {
 			klog.Warningf("Failed to open pipe (Mkfifo) %q for writing: %+v", exec.namedPipeWriterPath, err)
 			return
@@ -392,7 +396,7 @@ func (exec *Executor) openPipeWriter() {
 		<-exec.doneChan
 		close(exec.PipeWriterFifo)
 		_ = exec.pipeWriter.Close()
-		_ = os.Remove(exec.namedPipeWriterPath)
+		removeFifo(exec.namedPipeWriterPath)
 	}()
 }
 
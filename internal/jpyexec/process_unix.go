@@ -0,0 +1,21 @@
+//go:build !windows
+
+package jpyexec
+
+import (
+	"os"
+	osexec "os/exec"
+
+	"k8s.io/klog/v2"
+)
+
+// setProcessGroup configures the process group of the program: on Unix it stays in the process group of
+// the kernel, so it receives the SIGINT sent by Jupyter to interrupt the execution.
+func setProcessGroup(*osexec.Cmd) {}
+
+// interruptProcess sends SIGINT to the program.
+func interruptProcess(cmd *osexec.Cmd) {
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		klog.Warningf("Failed to interrupt %q: %+v", cmd, err)
+	}
+}
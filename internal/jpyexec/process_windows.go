@@ -0,0 +1,30 @@
+//go:build windows
+
+package jpyexec
+
+import (
+	osexec "os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+	"k8s.io/klog/v2"
+)
+
+// setProcessGroup starts the program on its own process group, so it can be sent a CTRL_BREAK_EVENT (seen
+// as os.Interrupt by Go programs) by interruptProcess.
+func setProcessGroup(cmd *osexec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// interruptProcess sends a CTRL_BREAK_EVENT to the process group of the program or, if that fails (e.g.:
+// the kernel has no console), kills it.
+func interruptProcess(cmd *osexec.Cmd) {
+	err := windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(cmd.Process.Pid))
+	if err == nil {
+		return
+	}
+	klog.V(1).Infof("Failed to send CTRL_BREAK_EVENT to %q, killing it instead: %v", cmd, err)
+	if err = cmd.Process.Kill(); err != nil {
+		klog.Warningf("Failed to interrupt %q: %+v", cmd, err)
+	}
+}
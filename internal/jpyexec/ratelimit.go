@@ -0,0 +1,88 @@
+package jpyexec
+
+import (
+	"sync"
+	"time"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the rate limit of the display updates published by the program (see
+// Executor.WithDisplayRateLimit): programs that update a display thousands of times per second (e.g.: the plot
+// of a training loop) would otherwise flood the front-end, making it unresponsive.
+
+// displayRateLimiter coalesces the updates of each "display_id", so they are published at most once per
+// interval: an update that arrives sooner is held, replaced by any following update, and published when the
+// interval is over. The first display of each "display_id" is never delayed.
+type displayRateLimiter struct {
+	interval time.Duration
+	publish  func(data kernel.Data)
+
+	mu            sync.Mutex
+	lastPublished map[string]time.Time
+	pending       map[string]kernel.Data
+	coalesced     int
+	closed        bool
+}
+
+// newDisplayRateLimiter creates a displayRateLimiter that publishes the updates of each "display_id" at most
+// maxFPS times per second, with publish.
+func newDisplayRateLimiter(maxFPS int, publish func(data kernel.Data)) *displayRateLimiter {
+	return &displayRateLimiter{
+		interval:      time.Second / time.Duration(maxFPS),
+		publish:       publish,
+		lastPublished: make(map[string]time.Time),
+		pending:       make(map[string]kernel.Data),
+	}
+}
+
+// Publish the data for displayId, or hold it if the last update of displayId was published less than the
+// interval ago.
+func (rl *displayRateLimiter) Publish(displayId string, data kernel.Data) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	last, found := rl.lastPublished[displayId]
+	if rl.closed || !found || now.Sub(last) >= rl.interval {
+		delete(rl.pending, displayId) // Superseded, if the flush was not yet run.
+		rl.lastPublished[displayId] = now
+		rl.publish(data)
+		return
+	}
+	if _, scheduled := rl.pending[displayId]; scheduled {
+		rl.coalesced++
+	} else {
+		time.AfterFunc(last.Add(rl.interval).Sub(now), func() { rl.flush(displayId) })
+	}
+	rl.pending[displayId] = data
+}
+
+// flush publishes the update held for displayId, if any.
+func (rl *displayRateLimiter) flush(displayId string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	data, found := rl.pending[displayId]
+	if !found {
+		return
+	}
+	delete(rl.pending, displayId)
+	rl.lastPublished[displayId] = time.Now()
+	rl.publish(data)
+}
+
+// Close publishes the updates held, so the displays show the last state, and publishes the following ones
+// immediately.
+func (rl *displayRateLimiter) Close() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.closed = true
+	for _, displayId := range common.SortedKeys(rl.pending) {
+		rl.publish(rl.pending[displayId])
+	}
+	rl.pending = make(map[string]kernel.Data)
+	if rl.coalesced > 0 {
+		klog.V(1).Infof("Display rate limit: %d updates coalesced", rl.coalesced)
+	}
+}
@@ -0,0 +1,46 @@
+package jpyexec
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayRateLimiter(t *testing.T) {
+	var mu sync.Mutex
+	var published []string
+	rl := newDisplayRateLimiter(20, func(data kernel.Data) {
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, data.Data["text/plain"].(string))
+	})
+	update := func(displayId, text string) {
+		rl.Publish(displayId, kernel.Data{Data: kernel.MIMEMap{"text/plain": text}})
+	}
+	publishedCopy := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), published...)
+	}
+
+	// The first display of each id is published immediately, the following updates are coalesced.
+	for ii := 0; ii < 100; ii++ {
+		update("a", "a"+string(rune('0'+ii%10)))
+	}
+	update("b", "b0")
+	assert.Equal(t, []string{"a0", "b0"}, publishedCopy())
+
+	// The last update is published when the interval (50ms) is over.
+	assert.Eventually(t, func() bool { return len(publishedCopy()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "a9", publishedCopy()[2])
+
+	// Close publishes the updates held.
+	update("b", "b1")
+	rl.Close()
+	assert.Equal(t, []string{"a0", "b0", "a9", "b1"}, publishedCopy())
+	update("b", "b2")
+	assert.Equal(t, "b2", publishedCopy()[4])
+}
@@ -0,0 +1,10 @@
+package kernel
+
+// This file handles console front-ends (jupyter-console, emacs-jupyter), that only display "text/plain":
+// the text version of the rich outputs is included for all front-ends, see textFallbackData.
+
+// MarkConsoleSession marks the session of the message as a console front-end: it is called when an
+// "is_complete_request" is received, since only consoles send them. See FrontendOf.
+func (k *Kernel) MarkConsoleSession(msg Message) {
+	k.consoleSessions.Store(msg.ComposedMsg().Header.Session, true)
+}
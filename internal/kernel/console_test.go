@@ -0,0 +1,28 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoleFrontend(t *testing.T) {
+	t.Setenv(insideEmacsEnvVar, "")
+	for _, envVar := range vsCodeEnvVars {
+		t.Setenv(envVar, "")
+	}
+	k := NewHeadless()
+	msg, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	msg.composed.Header.Session = "console-session"
+	assert.Equal(t, FrontendJupyter, FrontendOf(msg))
+	k.MarkConsoleSession(msg)
+	assert.Equal(t, FrontendConsole, FrontendOf(msg))
+
+	other, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	assert.Equal(t, FrontendJupyter, FrontendOf(other))
+	t.Setenv(insideEmacsEnvVar, "29.1,comint")
+	assert.Equal(t, FrontendConsole, FrontendOf(other))
+}
@@ -0,0 +1,156 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"regexp"
+	"strings"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// This file completes the metadata of the display data published, so notebooks exported with nbconvert
+// (or Jupyter Book) render as in the live notebook, see prepareDisplayData.
+
+const (
+	// MIMEApplicationJavascript is the MIME type of Javascript used by Jupyter front-ends, along with
+	// protocol.MIMETextJavascript.
+	MIMEApplicationJavascript = "application/javascript"
+
+	// MIMEImageJPEG and MIMEImageGIF are other image MIME types, whose sizes are included in the metadata.
+	MIMEImageJPEG = "image/jpeg"
+	MIMEImageGIF  = "image/gif"
+)
+
+// imageMIMETypes are the MIME types of raster images, whose sizes are included in the metadata.
+var imageMIMETypes = []string{string(protocol.MIMEImagePNG), MIMEImageJPEG, MIMEImageGIF}
+
+// reHtmlDocument matches HTML content that is a full document, as opposed to a fragment.
+var reHtmlDocument = regexp.MustCompile(`(?is)^\s*(<!DOCTYPE\s+html|<html[\s>])`)
+
+// prepareDisplayData completes the metadata of display data, as nbconvert and Jupyter front-ends expect:
+//
+//   - Raster images get their "width" and "height", and "needs_background": "light" if they have
+//     transparency (as plots usually have).
+//   - HTML that is a full document (as opposed to a fragment) is marked as "isolated", so it's rendered in
+//     an iframe, and its styles don't leak to the rest of the notebook.
+//
+// If Kernel.ExportSafeHTML is set, Javascript-only outputs (which nbconvert doesn't render) are converted
+// to HTML with the script. For VS Code, the MIME types are adjusted to its renderer, see vsCodeFallbackData.
+// Finally, a "text/plain" version is included, if there isn't one, see textFallbackData: it is marked with
+// TransientTextFallback.
+func prepareDisplayData(msg Message, data Data) Data {
+	data.Metadata = EnsureMIMEMap(data.Metadata)
+	for _, mimeType := range imageMIMETypes {
+		content, found := data.Data[mimeType]
+		if !found {
+			continue
+		}
+		if _, found := data.Metadata[mimeType]; found {
+			// Metadata set by the program.
+			continue
+		}
+		var raw []byte
+		switch typed := content.(type) {
+		case []byte:
+			raw = typed
+		case string:
+			var err error
+			if raw, err = base64.StdEncoding.DecodeString(typed); err != nil {
+				continue
+			}
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+		if err != nil {
+			continue
+		}
+		data.Metadata[mimeType] = map[string]any{"width": config.Width, "height": config.Height}
+		if _, found := data.Metadata["needs_background"]; !found && hasTransparency(config.ColorModel, raw) {
+			data.Metadata["needs_background"] = "light"
+		}
+	}
+
+	if html, ok := data.Data[string(protocol.MIMETextHTML)].(string); ok && reHtmlDocument.MatchString(html) {
+		if _, found := data.Metadata[string(protocol.MIMETextHTML)]; !found {
+			data.Metadata[string(protocol.MIMETextHTML)] = map[string]any{"isolated": true}
+		}
+	}
+
+	if msg != nil && msg.Kernel() != nil && msg.Kernel().ExportSafeHTML {
+		data.Data = exportSafeData(data.Data)
+	}
+	if FrontendOf(msg) == FrontendVSCode {
+		data.Data = vsCodeFallbackData(data.Data)
+	}
+	_, hadPlain := data.Data[string(protocol.MIMETextPlain)]
+	data.Data = textFallbackData(data.Data, data.Metadata)
+	if _, hasPlain := data.Data[string(protocol.MIMETextPlain)]; hasPlain && !hadPlain {
+		// Copied, since the transient data (e.g.: the "display_id") may be reused by the program.
+		transient := make(MIMEMap, len(data.Transient)+1)
+		for key, value := range data.Transient {
+			transient[key] = value
+		}
+		transient[TransientTextFallback] = true
+		data.Transient = transient
+	}
+	return data
+}
+
+// hasTransparency returns whether the encoded image has any pixel that is not fully opaque. It is only
+// decoded if its color model supports transparency.
+func hasTransparency(model color.Model, raw []byte) bool {
+	switch model {
+	case color.YCbCrModel, color.CMYKModel, color.GrayModel, color.Gray16Model:
+		return false
+	}
+	if palette, ok := model.(color.Palette); ok {
+		hasAlpha := false
+		for _, c := range palette {
+			if _, _, _, a := c.RGBA(); a != 0xffff {
+				hasAlpha = true
+				break
+			}
+		}
+		if !hasAlpha {
+			return false
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return false
+}
+
+// exportSafeData converts Javascript-only display data to HTML with the script, which is kept when the
+// notebook is exported to HTML.
+func exportSafeData(data MIMEMap) MIMEMap {
+	if _, found := data[string(protocol.MIMETextHTML)]; found {
+		return data
+	}
+	for _, mimeType := range []string{string(protocol.MIMETextJavascript), MIMEApplicationJavascript} {
+		js, ok := data[mimeType].(string)
+		if !ok {
+			continue
+		}
+		converted := make(MIMEMap, len(data))
+		for key, value := range data {
+			if key != string(protocol.MIMETextJavascript) && key != MIMEApplicationJavascript {
+				converted[key] = value
+			}
+		}
+		// "</script" would end the script element early.
+		js = strings.ReplaceAll(js, "</script", "<\\/script")
+		converted[string(protocol.MIMETextHTML)] = "<script>\n" + js + "\n</script>"
+		return converted
+	}
+	return data
+}
@@ -0,0 +1,70 @@
+package kernel
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestPrepareDisplayData(t *testing.T) {
+	k := NewHeadless()
+	k.Frontend = FrontendJupyter
+	msg, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+
+	// Opaque image.
+	opaque := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for ii := range opaque.Pix {
+		opaque.Pix[ii] = 0xff
+	}
+	data := prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMEImagePNG): encodePNG(t, opaque)}})
+	assert.Equal(t, map[string]any{"width": 3, "height": 2}, data.Metadata[string(protocol.MIMEImagePNG)])
+	assert.NotContains(t, data.Metadata, "needs_background")
+
+	// Transparent image.
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	transparent.Set(1, 1, color.NRGBA{R: 255, A: 255})
+	data = prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMEImagePNG): encodePNG(t, transparent)}})
+	assert.Equal(t, map[string]any{"width": 4, "height": 4}, data.Metadata[string(protocol.MIMEImagePNG)])
+	assert.Equal(t, "light", data.Metadata["needs_background"])
+
+	// HTML documents are isolated, fragments are not.
+	data = prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMETextHTML): "<!DOCTYPE html><html><body>x</body></html>"}})
+	assert.Equal(t, map[string]any{"isolated": true}, data.Metadata[string(protocol.MIMETextHTML)])
+	data = prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMETextHTML): "<div>x</div>"}})
+	assert.NotContains(t, data.Metadata, string(protocol.MIMETextHTML))
+
+	// Javascript-only outputs, converted if ExportSafeHTML is set.
+	js := MIMEMap{string(protocol.MIMETextJavascript): "console.log('</script>');"}
+	data = prepareDisplayData(msg, Data{Data: js})
+	assert.Equal(t, MIMEMap{string(protocol.MIMETextJavascript): "console.log('</script>');",
+		string(protocol.MIMETextPlain): ""}, data.Data)
+	k.ExportSafeHTML = true
+	data = prepareDisplayData(msg, Data{Data: js})
+	assert.Equal(t, MIMEMap{string(protocol.MIMETextHTML): "<script>\nconsole.log('<\\/script>');\n</script>",
+		string(protocol.MIMETextPlain): ""}, data.Data)
+
+	// A text version is always included.
+	data = prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMEImagePNG): encodePNG(t, opaque)}})
+	assert.Equal(t, "[image/png 3x2]", data.Data[string(protocol.MIMETextPlain)])
+
+	// The generated text version is marked as such, without changing the transient data given.
+	transient := MIMEMap{"display_id": "plot"}
+	data = prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMETextHTML): "<b>x</b>"}, Transient: transient})
+	assert.Equal(t, MIMEMap{"display_id": "plot", TransientTextFallback: true}, data.Transient)
+	assert.Equal(t, MIMEMap{"display_id": "plot"}, transient)
+	data = prepareDisplayData(msg, Data{Data: MIMEMap{string(protocol.MIMETextPlain): "x"}, Transient: transient})
+	assert.NotContains(t, data.Transient, TransientTextFallback)
+}
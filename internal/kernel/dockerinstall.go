@@ -0,0 +1,123 @@
+package kernel
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `gonb --docker_install`: it generates a minimal Dockerfile and kernel configuration
+// (kernelspec) to run GoNB inside a Jupyter docker (e.g. the Jupyter Docker Stacks), on amd64 or arm64, and
+// with glibc or musl (Alpine) based images.
+
+const (
+	// DefaultDockerBaseImage is the base image of the Dockerfile generated by DockerInstall, if none is given.
+	DefaultDockerBaseImage = "quay.io/jupyter/base-notebook:latest"
+
+	// DockerModCache is where the Go module cache of the host is mounted in the docker.
+	DockerModCache = "/opt/gomodcache"
+
+	// DockerGoNBPath is where GoNB is installed in the docker.
+	DockerGoNBPath = "/usr/local/bin/gonb"
+
+	// dockerFallbackGoVersion is the version of Go installed in the docker, if the one GoNB was built with
+	// is not a release.
+	dockerFallbackGoVersion = "1.22.0"
+)
+
+//go:embed Dockerfile.tmpl
+var dockerfileTemplate string
+
+// dockerfileData are the values used to generate the Dockerfile from dockerfileTemplate.
+type dockerfileData struct {
+	BaseImage, GoVersion, GoNBVersion string
+	ModCache, NotebooksDir            string
+	Alpine                            bool
+}
+
+// dockerGoVersion returns the version of Go to install in the docker: the one GoNB was built with, if it's
+// a release.
+func dockerGoVersion() string {
+	fields := strings.Fields(runtime.Version()) // E.g.: "go1.22.1 X:boringcrypto"
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "go1.") {
+		return dockerFallbackGoVersion
+	}
+	return strings.TrimPrefix(fields[0], "go")
+}
+
+// DockerInstall writes to dir a Dockerfile that installs Go, GoNB (version gonbVersion, or "latest" if empty)
+// and its kernel configuration on top of the baseImage (DefaultDockerBaseImage if empty), and the kernel
+// configuration itself in the `kernelspec` subdirectory. extraArgs are passed to the kernel, as in Install.
+//
+// Images with "alpine" in the name are assumed to be Alpine Linux (musl) based, without Jupyter installed.
+// Otherwise, they are assumed to be one of the Jupyter Docker Stacks.
+func DockerInstall(dir, baseImage, gonbVersion string, extraArgs []string) error {
+	if baseImage == "" {
+		baseImage = DefaultDockerBaseImage
+	}
+	if gonbVersion == "" {
+		gonbVersion = "latest"
+	}
+	data := dockerfileData{
+		BaseImage:    baseImage,
+		GoVersion:    dockerGoVersion(),
+		GoNBVersion:  gonbVersion,
+		ModCache:     DockerModCache,
+		NotebooksDir: "/home/jovyan/work",
+		Alpine:       strings.Contains(strings.ToLower(baseImage), "alpine"),
+	}
+	if data.Alpine {
+		data.NotebooksDir = "/notebooks"
+	}
+
+	kernelSpecDir := filepath.Join(dir, "kernelspec")
+	if err := os.MkdirAll(kernelSpecDir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %q", kernelSpecDir)
+	}
+
+	// Dockerfile.
+	tmpl, err := template.New("Dockerfile").Parse(dockerfileTemplate)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Dockerfile template")
+	}
+	var contents strings.Builder
+	if err = tmpl.Execute(&contents, data); err != nil {
+		return errors.Wrap(err, "failed to generate Dockerfile")
+	}
+	dockerfilePath := filepath.Join(dir, "Dockerfile")
+	if err = os.WriteFile(dockerfilePath, []byte(contents.String()), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %q", dockerfilePath)
+	}
+
+	// Kernel configuration, pointing to the GoNB installed in the docker.
+	config := jupyterKernelConfig{
+		Argv:        append([]string{DockerGoNBPath, "--kernel", "{connection_file}"}, extraArgs...),
+		DisplayName: "Go (gonb)",
+		Language:    "go",
+		Env:         make(map[string]string),
+	}
+	configJSON, err := json.MarshalIndent(&config, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode kernel configuration")
+	}
+	for name, contents := range map[string][]byte{
+		"kernel.json":  configJSON,
+		"logo-svg.svg": logoSVG,
+		"kernel.js":    kernelJS,
+	} {
+		filePath := filepath.Join(kernelSpecDir, name)
+		if err = os.WriteFile(filePath, contents, 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %q", filePath)
+		}
+	}
+	klog.Infof("Dockerfile and kernel configuration for GoNB %s (Go %s) written to %q: see the instructions "+
+		"to build and run it at the top of %q.", gonbVersion, data.GoVersion, dir, dockerfilePath)
+	return nil
+}
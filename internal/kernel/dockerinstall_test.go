@@ -0,0 +1,41 @@
+package kernel
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerInstall(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, DockerInstall(dir, "", "v0.10.0", []string{"--raw_error"}))
+	dockerfile, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(dockerfile), "ARG BASE_IMAGE="+DefaultDockerBaseImage+"\n")
+	assert.Contains(t, string(dockerfile), "ARG GONB_VERSION=v0.10.0\n")
+	assert.Contains(t, string(dockerfile), "linux-${TARGETARCH}.tar.gz")
+	assert.Contains(t, string(dockerfile), "apt-get install")
+	assert.NotContains(t, string(dockerfile), "apk add")
+	assert.Contains(t, string(dockerfile), "USER ${NB_UID}")
+
+	configJSON, err := os.ReadFile(filepath.Join(dir, "kernelspec", "kernel.json"))
+	require.NoError(t, err)
+	var config jupyterKernelConfig
+	require.NoError(t, json.Unmarshal(configJSON, &config))
+	assert.Equal(t, []string{DockerGoNBPath, "--kernel", "{connection_file}", "--raw_error"}, config.Argv)
+	assert.FileExists(t, filepath.Join(dir, "kernelspec", "logo-svg.svg"))
+	assert.FileExists(t, filepath.Join(dir, "kernelspec", "kernel.js"))
+
+	// Alpine (musl) based image.
+	require.NoError(t, DockerInstall(dir, "python:3.12-alpine", "", nil))
+	dockerfile, err = os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(dockerfile), "ARG GONB_VERSION=latest\n")
+	assert.Contains(t, string(dockerfile), "apk add")
+	assert.NotContains(t, string(dockerfile), "apt-get")
+	assert.Contains(t, string(dockerfile), "WORKDIR /notebooks")
+}
@@ -0,0 +1,105 @@
+package kernel
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// This file handles the differences among Jupyter front-ends: in particular, the VS Code Jupyter extension
+// renders outputs in a sandboxed webview, where some of the MIME types and the scripts that work in
+// JupyterLab don't. And console front-ends (jupyter-console, emacs-jupyter) only display text.
+
+// Frontend identifies the front-end (Jupyter client) connected to the kernel.
+type Frontend string
+
+const (
+	// FrontendAuto detects the front-end from the messages received and the environment, see FrontendOf.
+	FrontendAuto Frontend = ""
+
+	// FrontendJupyter is JupyterLab, the classic Jupyter Notebook, or any other front-end that renders
+	// outputs as they do.
+	FrontendJupyter Frontend = "jupyter"
+
+	// FrontendVSCode is the Jupyter extension of VS Code.
+	FrontendVSCode Frontend = "vscode"
+
+	// FrontendConsole is a text front-end, like jupyter-console or emacs-jupyter's REPL.
+	FrontendConsole Frontend = "console"
+)
+
+// vsCodeUsername is the username in the header of the messages sent by the VS Code Jupyter extension.
+const vsCodeUsername = "vscode"
+
+// vsCodeEnvVars are environment variables set by VS Code for the processes it starts, inherited by
+// kernels started by its Jupyter extension.
+var vsCodeEnvVars = []string{"VSCODE_PID", "VSCODE_CWD"}
+
+// insideEmacsEnvVar is set by Emacs for the processes it starts, including kernels started by emacs-jupyter.
+const insideEmacsEnvVar = "INSIDE_EMACS"
+
+// FrontendOf returns the front-end that sent the message: Kernel.Frontend, if it was configured
+// (with `%config frontend=...`), otherwise it is detected from the session of the message (sessions that
+// sent an "is_complete_request", only sent by consoles, see Kernel.MarkConsoleSession), the username in its
+// header, or from the environment of the kernel. These are heuristics: if they fail, configure it explicitly.
+func FrontendOf(msg Message) Frontend {
+	if msg == nil || msg.Kernel() == nil {
+		return FrontendJupyter
+	}
+	if frontend := msg.Kernel().Frontend; frontend != FrontendAuto {
+		return frontend
+	}
+	if _, found := msg.Kernel().consoleSessions.Load(msg.ComposedMsg().Header.Session); found {
+		return FrontendConsole
+	}
+	if msg.ComposedMsg().Header.Username == vsCodeUsername {
+		return FrontendVSCode
+	}
+	if os.Getenv(insideEmacsEnvVar) != "" {
+		return FrontendConsole
+	}
+	for _, envVar := range vsCodeEnvVars {
+		if os.Getenv(envVar) != "" {
+			return FrontendVSCode
+		}
+	}
+	return FrontendJupyter
+}
+
+// reHtmlScript matches HTML that includes scripts, which VS Code runs sandboxed (without RequireJS or
+// access to the Jupyter server), and doesn't run at all for untrusted notebooks.
+var reHtmlScript = regexp.MustCompile(`(?i)<script[\s>]`)
+
+// interactiveHtmlFallback is the "text/plain" fallback included with interactive HTML outputs, if
+// the program didn't provide one.
+const interactiveHtmlFallback = "[Interactive HTML output: it requires Javascript, which is not enabled for this notebook]"
+
+// vsCodeFallbackData adjusts the MIME types of display data for the VS Code renderer:
+//
+//   - "text/javascript", not rendered by VS Code, is converted to "application/javascript".
+//   - HTML with scripts gets a "text/plain" fallback (if there isn't one), displayed by VS Code when the
+//     notebook is not trusted, or when the HTML renderer is disabled.
+func vsCodeFallbackData(data MIMEMap) MIMEMap {
+	js, hasTextJS := data[string(protocol.MIMETextJavascript)]
+	html, _ := data[string(protocol.MIMETextHTML)].(string)
+	_, hasPlain := data[string(protocol.MIMETextPlain)]
+	needsPlain := !hasPlain && reHtmlScript.MatchString(html)
+	if !hasTextJS && !needsPlain {
+		return data
+	}
+	converted := make(MIMEMap, len(data)+1)
+	for key, value := range data {
+		converted[key] = value
+	}
+	if hasTextJS {
+		delete(converted, string(protocol.MIMETextJavascript))
+		if _, found := converted[MIMEApplicationJavascript]; !found {
+			converted[MIMEApplicationJavascript] = js
+		}
+	}
+	if needsPlain {
+		converted[string(protocol.MIMETextPlain)] = interactiveHtmlFallback
+	}
+	return converted
+}
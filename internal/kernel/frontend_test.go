@@ -0,0 +1,41 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrontendOf(t *testing.T) {
+	for _, envVar := range vsCodeEnvVars {
+		t.Setenv(envVar, "")
+	}
+	k := NewHeadless()
+	msg, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	assert.Equal(t, FrontendJupyter, FrontendOf(msg))
+
+	msg.composed.Header.Username = vsCodeUsername
+	assert.Equal(t, FrontendVSCode, FrontendOf(msg))
+
+	k.Frontend = FrontendJupyter
+	assert.Equal(t, FrontendJupyter, FrontendOf(msg))
+}
+
+func TestVSCodeFallbackData(t *testing.T) {
+	// Javascript is converted to the MIME type rendered by VS Code.
+	data := vsCodeFallbackData(MIMEMap{string(protocol.MIMETextJavascript): "alert(1);"})
+	assert.Equal(t, MIMEMap{MIMEApplicationJavascript: "alert(1);"}, data)
+
+	// Interactive HTML gets a text fallback.
+	data = vsCodeFallbackData(MIMEMap{string(protocol.MIMETextHTML): "<div id='x'></div><script>draw('x');</script>"})
+	assert.Equal(t, interactiveHtmlFallback, data[string(protocol.MIMETextPlain)])
+
+	// Static HTML, or HTML with a text fallback, is not changed.
+	static := MIMEMap{string(protocol.MIMETextHTML): "<b>bold</b>"}
+	assert.Equal(t, static, vsCodeFallbackData(static))
+	withPlain := MIMEMap{string(protocol.MIMETextHTML): "<script></script>", string(protocol.MIMETextPlain): "plain"}
+	assert.Equal(t, withPlain, vsCodeFallbackData(withPlain))
+}
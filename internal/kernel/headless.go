@@ -0,0 +1,206 @@
+package kernel
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// NewHeadless creates a Kernel not connected to Jupyter, used to execute notebooks from the command line
+// (`gonb run`). Messages are created with NewHeadlessMessage, and they collect the outputs published.
+func NewHeadless() *Kernel {
+	return &Kernel{
+		stop:          make(chan struct{}),
+		KnownBlockIds: make(common.Set[string]),
+	}
+}
+
+// HeadlessMessage implements Message for a Kernel created with NewHeadless: instead of being sent to
+// Jupyter, the outputs published are collected in the format of the outputs of a code cell in a
+// notebook file (nbformat).
+type HeadlessMessage struct {
+	kernel   *Kernel
+	composed ComposedMsg
+
+	mu      sync.Mutex
+	outputs []map[string]any
+	reply   map[string]any
+
+	// clearPending is set by a "clear_output" with wait, to clear the outputs before the next one.
+	clearPending bool
+
+	// OnOutput, if set, is called with each new output collected.
+	OnOutput func(output map[string]any)
+}
+
+// Assert HeadlessMessage implements Message.
+var _ Message = &HeadlessMessage{}
+
+// NewHeadlessMessage creates a message of the given type and content (e.g.: an "execute_request"),
+// for a Kernel created with NewHeadless.
+func NewHeadlessMessage(k *Kernel, msgType string, content map[string]any) (*HeadlessMessage, error) {
+	composed, err := NewComposed(msgType, ComposedMsg{})
+	if err != nil {
+		return nil, err
+	}
+	composed.Content = content
+	return &HeadlessMessage{kernel: k, composed: *composed}, nil
+}
+
+// SetMetadata sets the metadata of the message, e.g.: the "tags" of the cell of an "execute_request".
+func (m *HeadlessMessage) SetMetadata(metadata map[string]any) {
+	m.composed.Metadata = metadata
+}
+
+// Error implements Message: headless messages have no receiving errors.
+func (m *HeadlessMessage) Error() error { return nil }
+
+// Ok implements Message.
+func (m *HeadlessMessage) Ok() bool { return true }
+
+// ComposedMsg implements Message.
+func (m *HeadlessMessage) ComposedMsg() ComposedMsg { return m.composed }
+
+// Kernel implements Message.
+func (m *HeadlessMessage) Kernel() *Kernel { return m.kernel }
+
+// toMap converts the content of a message (usually an anonymous struct with JSON tags) to a map.
+func toMap(content any) (map[string]any, error) {
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode content of message")
+	}
+	var m map[string]any
+	if err = json.Unmarshal(encoded, &m); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode content of message")
+	}
+	return m, nil
+}
+
+// Publish implements Message: it collects the outputs ("stream", "display_data", "update_display_data",
+// "execute_result" and "error" messages), and ignores the others.
+func (m *HeadlessMessage) Publish(msgType string, content interface{}) error {
+	content, err := m.kernel.MaskSecrets(content)
+	if err != nil {
+		return err
+	}
+	c, err := toMap(content)
+	if err != nil {
+		return err
+	}
+	var output map[string]any
+	switch msgType {
+	case "stream", "error", "execute_result", "display_data":
+		// The "transient" field of "display_data" is kept to match later updates, but it is not saved.
+		output = c
+	case "update_display_data":
+		displayId := displayIdOf(c)
+		m.mu.Lock()
+		for _, previous := range m.outputs {
+			if displayId != "" && displayIdOf(previous) == displayId {
+				previous["data"], previous["metadata"] = c["data"], c["metadata"]
+			}
+		}
+		m.mu.Unlock()
+		return nil
+	case "clear_output":
+		m.mu.Lock()
+		if wait, _ := c["wait"].(bool); wait {
+			m.clearPending = true
+		} else {
+			m.outputs = nil
+		}
+		m.mu.Unlock()
+		return nil
+	default:
+		klog.V(2).Infof("HeadlessMessage: ignoring published %q", msgType)
+		return nil
+	}
+	output["output_type"] = msgType
+	m.mu.Lock()
+	if m.clearPending {
+		m.outputs, m.clearPending = nil, false
+	}
+	if msgType == "stream" && len(m.outputs) > 0 {
+		// Consecutive writes to the same stream are merged, as Jupyter does.
+		last := m.outputs[len(m.outputs)-1]
+		if last["output_type"] == "stream" && last["name"] == output["name"] {
+			last["text"] = last["text"].(string) + output["text"].(string)
+			output = nil
+		}
+	}
+	if output != nil {
+		m.outputs = append(m.outputs, output)
+	}
+	m.mu.Unlock()
+	if m.OnOutput != nil {
+		m.OnOutput(c)
+	}
+	return nil
+}
+
+// displayIdOf returns the "display_id" in the transient data of an output, or "" if there is none.
+func displayIdOf(output map[string]any) string {
+	transient, _ := output["transient"].(map[string]any)
+	displayId, _ := transient["display_id"].(string)
+	return displayId
+}
+
+// Outputs returns the outputs collected, in the format of the "outputs" of a code cell of a notebook.
+func (m *HeadlessMessage) Outputs() []map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outputs := make([]map[string]any, 0, len(m.outputs))
+	for _, output := range m.outputs {
+		if _, found := output["transient"]; found {
+			// Not saved in the notebook.
+			copied := make(map[string]any, len(output))
+			for key, value := range output {
+				if key != "transient" {
+					copied[key] = value
+				}
+			}
+			output = copied
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+// Reply implements Message: it keeps the content of the reply, see HeadlessMessage.ReplyContent.
+func (m *HeadlessMessage) Reply(msgType string, content interface{}) error {
+	content, err := m.kernel.MaskSecrets(content)
+	if err != nil {
+		return err
+	}
+	c, err := toMap(content)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.reply = c
+	m.mu.Unlock()
+	return nil
+}
+
+// ReplyContent returns the content of the last reply to the message (e.g.: the "execute_reply"), or nil
+// if there was none.
+func (m *HeadlessMessage) ReplyContent() map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reply
+}
+
+// PromptInput implements Message: there is no front-end to input from, so it fails.
+func (m *HeadlessMessage) PromptInput(prompt string, password bool, onInput OnInputFn) error {
+	return errors.Errorf("input %q requested, but no input is available when running a notebook headless", prompt)
+}
+
+// CancelInput implements Message.
+func (m *HeadlessMessage) CancelInput() error { return nil }
+
+// DeliverInput implements Message.
+func (m *HeadlessMessage) DeliverInput() error { return nil }
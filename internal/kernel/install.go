@@ -7,7 +7,7 @@ import (
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
@@ -26,10 +26,11 @@ var logoSVG []byte
 // converted to a `kernel.json` file under `~/.local/share/jupyter/kernels/gonb`
 // (or `${HOME}/Library/Jupyter/kernels/` in Macs)
 type jupyterKernelConfig struct {
-	Argv        []string          `json:"argv"`
-	DisplayName string            `json:"display_name"`
-	Language    string            `json:"language"`
-	Env         map[string]string `json:"env"`
+	Argv          []string          `json:"argv"`
+	DisplayName   string            `json:"display_name"`
+	Language      string            `json:"language"`
+	Env           map[string]string `json:"env"`
+	InterruptMode string            `json:"interrupt_mode,omitempty"`
 }
 
 // Install gonb in users local Jupyter configuration, making it available. It assumes
@@ -54,6 +55,10 @@ func Install(extraArgs []string, forceDeps, forceCopy bool) error {
 	if len(extraArgs) > 0 {
 		config.Argv = append(config.Argv, extraArgs...)
 	}
+	if runtime.GOOS == "windows" {
+		// There is no SIGINT on Windows: Jupyter sends an `interrupt_request` message instead.
+		config.InterruptMode = "message"
+	}
 
 	// Jupyter configuration directory for gonb.
 	home := os.Getenv("HOME")
@@ -61,25 +66,31 @@ func Install(extraArgs []string, forceDeps, forceCopy bool) error {
 	if jupyterDataDir == "" {
 		switch runtime.GOOS {
 		case "linux":
-			jupyterDataDir = path.Join(home, ".local/share/jupyter")
+			jupyterDataDir = filepath.Join(home, ".local/share/jupyter")
 		case "darwin":
-			jupyterDataDir = path.Join(home, "Library/Jupyter")
+			jupyterDataDir = filepath.Join(home, "Library/Jupyter")
+		case "windows":
+			jupyterDataDir = filepath.Join(os.Getenv("APPDATA"), "jupyter")
 		default:
 			return errors.Errorf("Unknown OS %q: not sure where to install GoNB kernel -- set the environment %q to force a location.", runtime.GOOS, JupyterDataDirEnv)
 		}
 	}
-	kernelDir := path.Join(jupyterDataDir, "/kernels/gonb")
+	kernelDir := filepath.Join(jupyterDataDir, "kernels", "gonb")
 	if err := os.MkdirAll(kernelDir, 0755); err != nil {
 		return errors.WithMessagef(err, "failed to create configuration directory %q", kernelDir)
 	}
 
-	// If binary is in `/tmp` or `/var/folders`, then presumably it is a temporary compilation of Go binary,
-	// and we make a copy of the binary (since it will be deleted) to the configuration
-	// directory -- otherwise we just point to the current binary.
+	// If binary is in `/tmp` or `/var/folders` (or the temporary directory, e.g. on Windows), then presumably
+	// it is a temporary compilation of Go binary, and we make a copy of the binary (since it will be deleted)
+	// to the configuration directory -- otherwise we just point to the current binary.
 	if forceCopy ||
 		strings.HasPrefix(os.Args[0], "/tmp/") ||
-		strings.HasPrefix(os.Args[0], "/var/folders") {
-		newBinary := path.Join(kernelDir, "gonb")
+		strings.HasPrefix(os.Args[0], "/var/folders") ||
+		(runtime.GOOS == "windows" && strings.HasPrefix(os.Args[0], os.TempDir())) {
+		newBinary := filepath.Join(kernelDir, "gonb")
+		if runtime.GOOS == "windows" {
+			newBinary += ".exe"
+		}
 		// Move the previous version out of the way.
 		if _, err := os.Stat(newBinary); err == nil {
 			err = os.Rename(newBinary, newBinary+"~")
@@ -96,7 +107,7 @@ func Install(extraArgs []string, forceDeps, forceCopy bool) error {
 	}
 
 	// Create kernel.json.
-	configPath := path.Join(kernelDir, "kernel.json")
+	configPath := filepath.Join(kernelDir, "kernel.json")
 	f, err := os.Create(configPath)
 	if err != nil {
 		return errors.WithMessagef(err, "failed to create configuration file %q", configPath)
@@ -116,12 +127,19 @@ func Install(extraArgs []string, forceDeps, forceCopy bool) error {
 	klog.Infof("Go (gonb) kernel configuration installed in %q.\n", configPath)
 
 	// Create `logo-svg.svg`.
-	logoPath := path.Join(kernelDir, "logo-svg.svg")
+	logoPath := filepath.Join(kernelDir, "logo-svg.svg")
 	err = os.WriteFile(logoPath, logoSVG, 0755)
 	if err != nil {
 		return errors.WithMessagef(err, "failed to install logo file %q", logoPath)
 	}
 
+	// Create `kernel.js`, with the syntax highlighting of special commands for the classic Notebook.
+	kernelJSPath := filepath.Join(kernelDir, "kernel.js")
+	err = os.WriteFile(kernelJSPath, kernelJS, 0644)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to install %q", kernelJSPath)
+	}
+
 	// Check that goimports and gopls are installed.
 	_, err = exec.LookPath("goimports")
 	if err == nil {
@@ -0,0 +1,44 @@
+package kernel
+
+import "k8s.io/klog/v2"
+
+// This file implements the interruption of the execution by an `interrupt_request` message, used by
+// Jupyter when the kernel is installed with `"interrupt_mode": "message"` (the default on Windows, where
+// there is no SIGINT): the kernel is marked as interrupted, and the programs being executed are
+// interrupted by the handlers registered with OnInterrupt.
+//
+// With the default "signal" mode (see HandleInterrupt) Jupyter sends SIGINT to the whole process group
+// of the kernel, so the programs being executed receive it directly, and the handlers are not called.
+
+// Interrupt marks the kernel as interrupted and calls the handlers registered with OnInterrupt.
+func (k *Kernel) Interrupt() {
+	klog.Infof("INTERRUPT requested.")
+	k.Interrupted.Store(true)
+	k.muInterrupt.Lock()
+	handlers := make([]func(), 0, len(k.interruptHandlers))
+	for _, handler := range k.interruptHandlers {
+		handlers = append(handlers, handler)
+	}
+	k.muInterrupt.Unlock()
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// OnInterrupt registers a handler called by Interrupt, e.g. to interrupt a program being executed.
+// It returns a function that unregisters it.
+func (k *Kernel) OnInterrupt(handler func()) (unregister func()) {
+	k.muInterrupt.Lock()
+	defer k.muInterrupt.Unlock()
+	if k.interruptHandlers == nil {
+		k.interruptHandlers = make(map[int]func())
+	}
+	k.interruptHandlersId++
+	id := k.interruptHandlersId
+	k.interruptHandlers[id] = handler
+	return func() {
+		k.muInterrupt.Lock()
+		defer k.muInterrupt.Unlock()
+		delete(k.interruptHandlers, id)
+	}
+}
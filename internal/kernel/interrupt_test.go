@@ -0,0 +1,25 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterrupt(t *testing.T) {
+	k := NewHeadless()
+	var calls1, calls2 int
+	unregister1 := k.OnInterrupt(func() { calls1++ })
+	unregister2 := k.OnInterrupt(func() { calls2++ })
+	defer unregister2()
+
+	k.Interrupt()
+	assert.True(t, k.Interrupted.Load())
+	assert.Equal(t, 1, calls1)
+	assert.Equal(t, 1, calls2)
+
+	unregister1()
+	k.Interrupt()
+	assert.Equal(t, 1, calls1)
+	assert.Equal(t, 2, calls2)
+}
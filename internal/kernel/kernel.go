@@ -108,6 +108,11 @@ type Kernel struct {
 	// Interrupted indicates whether shell currently being executed was Interrupted.
 	Interrupted atomic.Bool
 
+	// interruptHandlers are called by Interrupt, see OnInterrupt.
+	interruptHandlers   map[int]func()
+	interruptHandlersId int
+	muInterrupt         sync.Mutex
+
 	// stdinMsg holds the MessageImpl that last asked from input from stdin (MessageImpl.PromptInput).
 	stdinMsg *MessageImpl
 	stdinFn  OnInputFn // Callback when stdin input is received.
@@ -122,6 +127,32 @@ type Kernel struct {
 	// KnownBlockIds are display data blocks with a "display_id" that have already been created, and
 	// hence should be updated (instead of created anew) in calls to PublishUpdate
 	KnownBlockIds common.Set[string]
+
+	// ExportSafeHTML converts Javascript-only outputs to HTML, so they are kept when the notebook is exported
+	// (e.g.: with nbconvert). Set with `%config export_safe_html=on`.
+	ExportSafeHTML bool
+
+	// Frontend connected to the kernel, if configured with `%config frontend=...`. If left as FrontendAuto,
+	// it is detected from the messages received, see FrontendOf.
+	Frontend Frontend
+
+	// consoleSessions are the sessions of the console front-ends, see MarkConsoleSession.
+	consoleSessions sync.Map
+
+	// secrets are masked in the messages sent, see AddSecret. heldStreams are the stream writers holding
+	// back output that may be the start of a secret, see FlushStreams.
+	secretsMu   sync.Mutex
+	secrets     []string
+	heldStreams common.Set[*jupyterStreamWriter]
+
+	// Recorder, if set, saves the cells executed, with their outputs, in a session file.
+	// Set with `%record start`.
+	Recorder *Recorder
+
+	// muPayloads protects payloads, the payloads to include in the "execute_reply" of the cell being
+	// executed. See SetNextInput.
+	muPayloads sync.Mutex
+	payloads   []map[string]any
 }
 
 // IsStopped returns whether the Kernel has been stopped.
@@ -146,6 +177,10 @@ func (k *Kernel) Stop() {
 	klog.V(1).Infof("Kernel.Stop()")
 	k.Interrupted.Store(true) // Also mark as interrupted.
 	close(k.stop)
+	if k.sockets == nil {
+		// Headless kernel, see NewHeadless.
+		return
+	}
 	err := k.sockets.ShellSocket.Socket.Close()
 	if err != nil {
 		klog.Errorf("Failed to close Shell socket: %v", err)
@@ -0,0 +1,73 @@
+// GoNB syntax highlighting for the classic Jupyter Notebook (and nbclassic), installed with the kernel.
+//
+// It defines the CodeMirror mode "gonb": Go, except for the special commands (lines starting with `%`) and
+// shell commands (lines starting with `!`), including their continuation lines (ending in `\`), which are
+// highlighted as "meta" instead of being flagged as Go syntax errors. The bodies of `%%c` and `%%writefile`
+// cells are not Go, and are highlighted as plain text.
+define([
+    "base/js/namespace",
+    "codemirror/lib/codemirror",
+    "codemirror/mode/go/go"
+], function (Jupyter, CodeMirror) {
+    "use strict";
+
+    var reSpecialLine = /^\s*[%!]/;
+    var reContinuedLine = /\\\s*$/;
+    var reNotGoBody = /^\s*%%(c|http|protoc|writefile)(\s|$)/;
+
+    CodeMirror.defineMode("gonb", function (config) {
+        var goMode = CodeMirror.getMode(config, "go");
+        return {
+            startState: function () {
+                return {go: CodeMirror.startState(goMode), special: false, continued: false, goBody: true};
+            },
+            copyState: function (state) {
+                return {
+                    go: CodeMirror.copyState(goMode, state.go),
+                    special: state.special,
+                    continued: state.continued,
+                    goBody: state.goBody
+                };
+            },
+            token: function (stream, state) {
+                if (stream.sol()) {
+                    var line = stream.string;
+                    state.special = state.continued || (state.goBody && reSpecialLine.test(line));
+                    state.continued = state.special && reContinuedLine.test(line);
+                    if (state.special && reNotGoBody.test(line)) {
+                        state.goBody = false;
+                    }
+                }
+                if (state.special) {
+                    stream.skipToEnd();
+                    return "meta";
+                }
+                if (!state.goBody) {
+                    stream.skipToEnd();
+                    return null;
+                }
+                return goMode.token(stream, state.go);
+            },
+            indent: function (state, textAfter) {
+                if (state.special || !state.goBody) {
+                    return CodeMirror.Pass;
+                }
+                return goMode.indent(state.go, textAfter);
+            },
+            electricChars: goMode.electricChars,
+            closeBrackets: goMode.closeBrackets,
+            fold: "brace",
+            lineComment: "//",
+            blockCommentStart: "/*",
+            blockCommentEnd: "*/"
+        };
+    }, "go");
+    CodeMirror.defineMIME("text/x-gonb", "gonb");
+
+    var onload = function () {
+        if (Jupyter.notebook && Jupyter.notebook.set_codemirror_mode) {
+            Jupyter.notebook.set_codemirror_mode("gonb");
+        }
+    };
+    return {onload: onload};
+});
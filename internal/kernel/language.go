@@ -0,0 +1,35 @@
+package kernel
+
+import (
+	_ "embed"
+)
+
+// Information about the Go language reported to front-ends in kernel_info_reply (and saved in the notebooks
+// metadata), used for syntax highlighting and exporting.
+const (
+	LanguageMIMEType          = "text/x-go"
+	LanguagePygmentsLexer     = "go"
+	LanguageCodeMirrorMode    = "go"
+	LanguageNBConvertExporter = "script"
+)
+
+// kernelJS defines the CodeMirror mode "gonb" for the classic Jupyter Notebook (and nbclassic): Go, where
+// the special (`%`) and shell (`!`) command lines are highlighted as "meta", instead of flagged as Go syntax
+// errors. It is installed along the kernel configuration, and loaded by the Notebook when the kernel starts.
+//
+//go:embed kernel.js
+var kernelJS []byte
+
+// NewLanguageInfo returns the information about the language reported in kernel_info_reply. goVersion is the
+// version of the Go toolchain.
+func NewLanguageInfo(goVersion string) KernelLanguageInfo {
+	return KernelLanguageInfo{
+		Name:              "go",
+		Version:           goVersion,
+		MIMEType:          LanguageMIMEType,
+		FileExtension:     ".go",
+		PygmentsLexer:     LanguagePygmentsLexer,
+		CodeMirrorMode:    LanguageCodeMirrorMode,
+		NBConvertExporter: LanguageNBConvertExporter,
+	}
+}
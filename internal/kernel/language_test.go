@@ -0,0 +1,26 @@
+package kernel
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLanguageInfo(t *testing.T) {
+	encoded, err := json.Marshal(NewLanguageInfo("go1.22.1"))
+	require.NoError(t, err)
+	var info map[string]string
+	require.NoError(t, json.Unmarshal(encoded, &info))
+	assert.Equal(t, map[string]string{
+		"name":               "go",
+		"version":            "go1.22.1",
+		"mimetype":           "text/x-go",
+		"file_extension":     ".go",
+		"pygments_lexer":     "go",
+		"codemirror_mode":    "go",
+		"nbconvert_exporter": "script",
+	}, info)
+	assert.Contains(t, string(kernelJS), `CodeMirror.defineMode("gonb"`)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"k8s.io/klog/v2"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/go-zeromq/zmq4"
@@ -266,7 +267,9 @@ func (m *MessageImpl) Publish(msgType string, content interface{}) error {
 		return err
 	}
 	klog.V(1).Infof("[IOPub] Publish message %q -- parent msg_id=%q", msgType, msg.ParentHeader.MsgID)
-	msg.Content = content
+	if msg.Content, err = m.kernel.MaskSecrets(content); err != nil {
+		return err
+	}
 	return m.kernel.sockets.IOPubSocket.RunLocked(func(socket zmq4.Socket) error {
 		return m.sendMessage(socket, msg)
 	})
@@ -284,6 +287,13 @@ type OnInputFn func(original, input *MessageImpl) error
 // message (m) and the message with the incoming input value.
 func (m *MessageImpl) PromptInput(prompt string, password bool, onInput OnInputFn) error {
 	klog.V(1).Infof("MessageImpl.PromptInput(%q, %v)", prompt, password)
+	if content, ok := m.Composed.Content.(map[string]any); ok {
+		if allowStdin, found := content["allow_stdin"].(bool); found && !allowStdin {
+			// E.g.: VS Code's interactive window, or cells executed by extensions.
+			return errors.Errorf("input %q requested, but the front-end doesn't accept input for this execution "+
+				"(allow_stdin=false)", prompt)
+		}
+	}
 	inputRequest, err := NewComposed("input_request", m.Composed)
 	if err != nil {
 		return errors.WithMessagef(err, "MessageImpl.PromptInput(): creating an input_request message")
@@ -336,7 +346,9 @@ func (m *MessageImpl) Reply(msgType string, content interface{}) error {
 		return err
 	}
 
-	msg.Content = content
+	if msg.Content, err = m.kernel.MaskSecrets(content); err != nil {
+		return err
+	}
 	klog.V(1).Infof("[Shell] Reply message %q, parent msg_id=%q", msgType, msg.ParentHeader.MsgID)
 	return m.kernel.sockets.ShellSocket.RunLocked(func(shell zmq4.Socket) error {
 		return m.sendMessage(shell, msg)
@@ -364,7 +376,12 @@ func EnsureMIMEMap(bundle MIMEMap) MIMEMap {
 //}
 
 // PublishExecutionError publishes a serialized error that was encountered during execution.
+//
+// Front-ends like VS Code only display the traceback, so if it is empty, the error itself is used.
 func PublishExecutionError(msg Message, err string, trace []string, name string) error {
+	if len(trace) == 0 {
+		trace = []string{fmt.Sprintf("%s: %s", name, err)}
+	}
 	return msg.Publish("error",
 		struct {
 			Name  string   `json:"ename"`
@@ -400,6 +417,7 @@ func PublishDisplayData(msg Message, data Data) error {
 		// Ignore if there is no message to reply to.
 		return nil
 	}
+	data = prepareDisplayData(msg, data)
 	// copy Data in a struct with appropriate json tags
 	return msg.Publish("display_data", struct {
 		Data      MIMEMap `json:"data"`
@@ -442,6 +460,8 @@ func PublishUpdateDisplayData(msg Message, data Data) error {
 		return errors.Errorf("PublishUpdateDisplayData call with a Trasient[display_id] that is not string, instead %T!?", displayIdAny)
 	}
 
+	data = prepareDisplayData(msg, data)
+
 	// Check whether displayId is new.
 	kernel := msg.Kernel()
 	msgType := "display_data"
@@ -463,6 +483,16 @@ func PublishUpdateDisplayData(msg Message, data Data) error {
 	})
 }
 
+// PublishClearOutput clears the outputs of the cell being executed. If wait is true, they are only cleared
+// when the next output is published, which avoids flickering when the outputs are replaced.
+func PublishClearOutput(msg Message, wait bool) error {
+	return msg.Publish("clear_output", struct {
+		Wait bool `json:"wait"`
+	}{
+		Wait: wait,
+	})
+}
+
 // PublishHtml is a shortcut to PublishData for HTML content.
 func PublishHtml(msg Message, html string) error {
 	return PublishData(msg, Data{
@@ -500,6 +530,33 @@ const (
 	StreamStderr = "stderr"
 )
 
+// SetNextInput requests the front-end to create a new cell with the given text after the cell being
+// executed or, if replace is true, to replace the contents of the cell being executed. It is sent as
+// a "set_next_input" payload of the "execute_reply", see Kernel.TakePayloads.
+func SetNextInput(msg Message, text string, replace bool) {
+	if msg == nil || msg.Kernel() == nil {
+		return
+	}
+	k := msg.Kernel()
+	k.muPayloads.Lock()
+	defer k.muPayloads.Unlock()
+	k.payloads = append(k.payloads, map[string]any{
+		"source":  "set_next_input",
+		"text":    text,
+		"replace": replace,
+	})
+}
+
+// TakePayloads returns the payloads to include in the "execute_reply" of the cell being executed, and
+// clears them.
+func (k *Kernel) TakePayloads() []map[string]any {
+	k.muPayloads.Lock()
+	defer k.muPayloads.Unlock()
+	payloads := k.payloads
+	k.payloads = nil
+	return payloads
+}
+
 // PublishWriteStream prints the data string to a stream on the front-end. This is
 // either `StreamStdout` or `StreamStderr`.
 func PublishWriteStream(msg Message, stream string, data string) error {
@@ -523,23 +580,57 @@ func PublishWriteStream(msg Message, stream string, data string) error {
 type jupyterStreamWriter struct {
 	stream string
 	msg    Message
+
+	// pending is the output held back because it may be the start of a secret, see Kernel.FlushStreams.
+	mu      sync.Mutex
+	pending string
 }
 
 // NewJupyterStreamWriter returns an io.Writer that forwards what is written to the Jupyter client,
 // under the given stream name.
+//
+// Secrets split across writes are masked (see Kernel.AddSecret): the end of the output that could be the
+// start of a secret is held back until the next write, or until Kernel.FlushStreams is called.
 func NewJupyterStreamWriter(msg Message, stream string) io.Writer {
-	return &jupyterStreamWriter{stream, msg}
+	return &jupyterStreamWriter{stream: stream, msg: msg}
 }
 
 // Write implements `io.Writer.Write` by publishing the data via `PublishWriteStream`
 func (w *jupyterStreamWriter) Write(p []byte) (n int, err error) {
-	data := string(p)
+	var k *Kernel
+	if w.msg != nil {
+		k = w.msg.Kernel()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := w.pending + string(p)
+	held := k.secretPrefixLength(data)
+	data, w.pending = data[:len(data)-held], data[len(data)-held:]
+	if held > 0 {
+		k.holdStream(w)
+	}
+	if data == "" {
+		return len(p), nil
+	}
 	if err := PublishWriteStream(w.msg, w.stream, data); err != nil {
-		klog.Errorf("Failed to stream %d bytes of data to stream %q: %+v", n, w.stream, err)
+		klog.Errorf("Failed to stream %d bytes of data to stream %q: %+v", len(data), w.stream, err)
 	}
 	return len(p), nil
 }
 
+// flush publishes the output held back, if any.
+func (w *jupyterStreamWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending == "" {
+		return
+	}
+	if err := PublishWriteStream(w.msg, w.stream, w.pending); err != nil {
+		klog.Errorf("Failed to stream %d bytes of data to stream %q: %+v", len(w.pending), w.stream, err)
+	}
+	w.pending = ""
+}
+
 // PublishKernelStatus publishes a status message notifying front-ends of the state the kernel
 // is in. It supports the states "starting", "busy", and "idle".
 func PublishKernelStatus(msg Message, status string) error {
@@ -552,19 +643,19 @@ func PublishKernelStatus(msg Message, status string) error {
 	)
 }
 
-// SendKernelInfo sends a kernel_info_reply message.
-func SendKernelInfo(msg Message, version string) error {
+// SendKernelInfo sends a kernel_info_reply message. goVersion is the version of the Go toolchain
+// used to build the notebook, or empty if it is the one the kernel was built with.
+func SendKernelInfo(msg Message, version, goVersion string) error {
+	if goVersion == "" {
+		goVersion = runtime.Version()
+	}
 	return msg.Reply("kernel_info_reply",
 		KernelInfo{
 			ProtocolVersion:       ProtocolVersion,
 			Implementation:        "gonb",
 			ImplementationVersion: version,
 			Banner:                fmt.Sprintf("Go kernel: gonb - v%s", version),
-			LanguageInfo: KernelLanguageInfo{
-				Name:          "go",
-				Version:       runtime.Version(),
-				FileExtension: ".go",
-			},
+			LanguageInfo:          NewLanguageInfo(goVersion),
 			HelpLinks: []HelpLink{
 				{Text: "Go", URL: "https://golang.org/"},
 				{Text: "gonb", URL: "https://github.com/janpfeifer/gonb"},
@@ -0,0 +1,79 @@
+package kernel
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// MaxCapturedOutput is the maximum size of the textual output captured by a CapturedMessage: only its
+// end is kept.
+const MaxCapturedOutput = 1 << 20
+
+// CapturedMessage wraps the Message of an "execute_request", and captures its textual output: what is
+// written to the standard output, and the "text/plain" version of the data displayed (if given by the
+// program, as opposed to generated, see TransientTextFallback). It is kept by the
+// kernel, to be accessed by the following cells, see `gonbui.Out`.
+type CapturedMessage struct {
+	Message
+
+	mu     sync.Mutex
+	output strings.Builder
+}
+
+// NewCapturedMessage wraps the message, to capture its textual output.
+func NewCapturedMessage(msg Message) *CapturedMessage {
+	return &CapturedMessage{Message: msg}
+}
+
+// Publish implements Message: the textual output is captured, and then published.
+func (m *CapturedMessage) Publish(msgType string, content interface{}) error {
+	switch msgType {
+	case "stream", "display_data", "execute_result", "clear_output":
+		if c, err := toMap(content); err == nil {
+			m.capture(msgType, c)
+		}
+	}
+	return m.Message.Publish(msgType, content)
+}
+
+// capture the textual output of the message content.
+func (m *CapturedMessage) capture(msgType string, content map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var text string
+	switch msgType {
+	case "clear_output":
+		m.output.Reset()
+		return
+	case "stream":
+		if content["name"] != StreamStdout {
+			return
+		}
+		text, _ = content["text"].(string)
+	default:
+		if transient, _ := content["transient"].(map[string]any); transient[TransientTextFallback] == true {
+			// The "text/plain" version was not output by the program.
+			return
+		}
+		data, _ := content["data"].(map[string]any)
+		text, _ = data[string(protocol.MIMETextPlain)].(string)
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+	}
+	m.output.WriteString(text)
+	if m.output.Len() > MaxCapturedOutput {
+		tail := m.output.String()[m.output.Len()-MaxCapturedOutput:]
+		m.output.Reset()
+		m.output.WriteString(tail)
+	}
+}
+
+// Output returns the textual output captured.
+func (m *CapturedMessage) Output() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.output.String()
+}
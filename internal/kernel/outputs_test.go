@@ -0,0 +1,37 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapturedMessage(t *testing.T) {
+	k := NewHeadless()
+	headless, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	captured := NewCapturedMessage(headless)
+	require.NoError(t, PublishWriteStream(captured, StreamStdout, "hello\n"))
+	require.NoError(t, PublishWriteStream(captured, StreamStderr, "warning\n")) // Not captured.
+	require.NoError(t, PublishMarkdown(captured, "*no plain text*"))            // Not captured.
+	require.NoError(t, PublishData(captured, Data{Data: MIMEMap{
+		string(protocol.MIMETextPlain): "42",
+		string(protocol.MIMETextHTML):  "<b>42</b>",
+	}}))
+	assert.Equal(t, "hello\n42\n", captured.Output())
+	// Outputs are still published.
+	require.Len(t, headless.Outputs(), 4)
+
+	// Clearing the output clears the captured output.
+	require.NoError(t, PublishClearOutput(captured, false))
+	assert.Empty(t, captured.Output())
+
+	// Only the end of large outputs is kept.
+	require.NoError(t, PublishWriteStream(captured, StreamStdout, strings.Repeat("x", MaxCapturedOutput)))
+	require.NoError(t, PublishWriteStream(captured, StreamStdout, "end\n"))
+	assert.Len(t, captured.Output(), MaxCapturedOutput)
+	assert.True(t, strings.HasSuffix(captured.Output(), "xend\n"))
+}
@@ -0,0 +1,159 @@
+package kernel
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the recording of a session (`%record start`): each cell executed is saved, with its
+// outputs and their timing, in a session file (JSON lines, one SessionExecution per line). The session can
+// be replayed later with `gonb replay`.
+
+// SessionOutput is an output published during the execution of a recorded cell.
+type SessionOutput struct {
+	// Offset is the time since the start of the execution of the cell, in milliseconds.
+	Offset int64 `json:"offset_ms"`
+
+	// MsgType is the type of the message published, e.g.: "stream" or "display_data".
+	MsgType string `json:"msg_type"`
+
+	// Content of the message published.
+	Content map[string]any `json:"content"`
+}
+
+// SessionExecution is the execution of one cell in a recorded session.
+type SessionExecution struct {
+	Source    string          `json:"source"`
+	StartedAt time.Time       `json:"started_at"`
+	Duration  int64           `json:"duration_ms"`
+	Status    string          `json:"status"` // "ok" or "error".
+	Outputs   []SessionOutput `json:"outputs"`
+}
+
+// sessionOutputTypes are the types of messages recorded as outputs.
+var sessionOutputTypes = map[string]bool{
+	"stream": true, "display_data": true, "update_display_data": true, "execute_result": true,
+	"error": true, "clear_output": true,
+}
+
+// Recorder saves the cells executed in a session file. It is created with `%record start`, see
+// Kernel.Recorder.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewRecorder creates the session file (or truncates it, if it exists) and returns a Recorder that
+// saves to it.
+func NewRecorder(sessionPath string) (*Recorder, error) {
+	f, err := os.Create(sessionPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create session file %q", sessionPath)
+	}
+	return &Recorder{path: sessionPath, file: f}, nil
+}
+
+// Path of the session file.
+func (r *Recorder) Path() string { return r.path }
+
+// Save the execution of a cell in the session file.
+func (r *Recorder) Save(execution *SessionExecution) error {
+	encoded, err := json.Marshal(execution)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode execution of cell for session file")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return errors.Errorf("recording to %q already stopped", r.path)
+	}
+	_, err = r.file.Write(append(encoded, '\n'))
+	return errors.Wrapf(err, "failed to write to session file %q", r.path)
+}
+
+// Close the session file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return errors.Wrapf(err, "failed to close session file %q", r.path)
+}
+
+// ReadSession reads the executions saved in a session file by a Recorder.
+func ReadSession(sessionPath string) ([]*SessionExecution, error) {
+	f, err := os.Open(sessionPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open session file %q", sessionPath)
+	}
+	defer func() { _ = f.Close() }()
+	var executions []*SessionExecution
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 256*1024*1024) // Outputs may include large images.
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		execution := &SessionExecution{}
+		if err = json.Unmarshal(scanner.Bytes(), execution); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse line %d of session file %q", lineNum, sessionPath)
+		}
+		executions = append(executions, execution)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read session file %q", sessionPath)
+	}
+	return executions, nil
+}
+
+// RecordedMessage wraps the Message of an "execute_request", and records the outputs published,
+// with their timing.
+type RecordedMessage struct {
+	Message
+
+	mu        sync.Mutex
+	execution SessionExecution
+}
+
+// NewRecordedMessage wraps the message executing the given source code, to record its outputs.
+func NewRecordedMessage(msg Message, source string) *RecordedMessage {
+	return &RecordedMessage{
+		Message:   msg,
+		execution: SessionExecution{Source: source, StartedAt: time.Now()},
+	}
+}
+
+// Publish implements Message: outputs are recorded and then published.
+func (m *RecordedMessage) Publish(msgType string, content interface{}) error {
+	if sessionOutputTypes[msgType] {
+		if c, err := toMap(content); err == nil {
+			m.mu.Lock()
+			m.execution.Outputs = append(m.execution.Outputs, SessionOutput{
+				Offset:  time.Since(m.execution.StartedAt).Milliseconds(),
+				MsgType: msgType,
+				Content: c,
+			})
+			m.mu.Unlock()
+		}
+	}
+	return m.Message.Publish(msgType, content)
+}
+
+// Execution returns the recorded execution, finished with the given status.
+func (m *RecordedMessage) Execution(status string) *SessionExecution {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	execution := m.execution
+	execution.Status = status
+	execution.Duration = time.Since(execution.StartedAt).Milliseconds()
+	return &execution
+}
@@ -0,0 +1,42 @@
+package kernel
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	k := NewHeadless()
+	headless, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	recorded := NewRecordedMessage(headless, "fmt.Println(\"hello\")")
+	require.NoError(t, PublishWriteStream(recorded, StreamStdout, "hello\n"))
+	require.NoError(t, PublishMarkdown(recorded, "*done*"))
+	require.NoError(t, PublishKernelStatus(recorded, "idle")) // Not an output, not recorded.
+
+	// Outputs are still published.
+	require.Len(t, headless.Outputs(), 2)
+
+	sessionPath := path.Join(t.TempDir(), "session.jsonl")
+	recorder, err := NewRecorder(sessionPath)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Save(recorded.Execution("ok")))
+	require.NoError(t, recorder.Save(NewRecordedMessage(headless, "panic(1)").Execution("error")))
+	require.NoError(t, recorder.Close())
+	assert.Error(t, recorder.Save(recorded.Execution("ok")))
+
+	executions, err := ReadSession(sessionPath)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+	assert.Equal(t, "fmt.Println(\"hello\")", executions[0].Source)
+	assert.Equal(t, "ok", executions[0].Status)
+	require.Len(t, executions[0].Outputs, 2)
+	assert.Equal(t, "stream", executions[0].Outputs[0].MsgType)
+	assert.Equal(t, "hello\n", executions[0].Outputs[0].Content["text"])
+	assert.Equal(t, "display_data", executions[0].Outputs[1].MsgType)
+	assert.Equal(t, "error", executions[1].Status)
+	assert.Empty(t, executions[1].Outputs)
+}
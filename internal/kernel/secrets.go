@@ -0,0 +1,114 @@
+package kernel
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+)
+
+// This file implements the masking of secrets (see `%secret`) in the messages sent to the front-end, so they
+// are never saved in the notebooks.
+
+// SecretMask replaces the secret values in the messages.
+const SecretMask = "********"
+
+// MinSecretLength is the minimum length of the secrets masked: shorter values would mask unrelated text.
+const MinSecretLength = 4
+
+// AddSecret registers a secret value to be masked in all the messages published or replied from then on,
+// for the rest of the session. Values shorter than MinSecretLength are ignored.
+func (k *Kernel) AddSecret(value string) {
+	if len(value) < MinSecretLength {
+		return
+	}
+	k.secretsMu.Lock()
+	defer k.secretsMu.Unlock()
+	for _, secret := range k.secrets {
+		if secret == value {
+			return
+		}
+	}
+	k.secrets = append(k.secrets, value)
+}
+
+// MaskSecrets returns the content of a message with the secret values replaced by SecretMask. If there are
+// no secrets, or none is present, content is returned unchanged. Otherwise, it is re-encoded as a
+// map[string]any.
+func (k *Kernel) MaskSecrets(content any) (any, error) {
+	if k == nil {
+		return content, nil
+	}
+	k.secretsMu.Lock()
+	secrets := k.secrets
+	k.secretsMu.Unlock()
+	if len(secrets) == 0 {
+		return content, nil
+	}
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode content of message")
+	}
+	masked := string(encoded)
+	for _, secret := range secrets {
+		// Secrets are matched as they are escaped in JSON strings.
+		escaped, _ := json.Marshal(secret)
+		masked = strings.ReplaceAll(masked, string(escaped[1:len(escaped)-1]), SecretMask)
+	}
+	if masked == string(encoded) {
+		return content, nil
+	}
+	var m map[string]any
+	if err = json.Unmarshal([]byte(masked), &m); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode content of message")
+	}
+	return m, nil
+}
+
+// secretPrefixLength returns the length of the longest suffix of text that is the start (but not the whole)
+// of a secret: stream output is written in chunks, so it is held back until the rest of the secret could be
+// masked.
+func (k *Kernel) secretPrefixLength(text string) int {
+	if k == nil {
+		return 0
+	}
+	k.secretsMu.Lock()
+	defer k.secretsMu.Unlock()
+	length := 0
+	for _, secret := range k.secrets {
+		for n := min(len(secret)-1, len(text)); n > length; n-- {
+			if strings.HasSuffix(text, secret[:n]) {
+				length = n
+				break
+			}
+		}
+	}
+	return length
+}
+
+// holdStream registers a stream writer that is holding back output, to be published by FlushStreams.
+func (k *Kernel) holdStream(w *jupyterStreamWriter) {
+	k.secretsMu.Lock()
+	defer k.secretsMu.Unlock()
+	if k.heldStreams == nil {
+		k.heldStreams = make(common.Set[*jupyterStreamWriter])
+	}
+	k.heldStreams.Insert(w)
+}
+
+// FlushStreams publishes the output held back by the stream writers (see NewJupyterStreamWriter), because it
+// could be the start of a secret. It's called when a program finishes, and at the end of the execution of
+// each cell.
+func (k *Kernel) FlushStreams() {
+	if k == nil {
+		return
+	}
+	k.secretsMu.Lock()
+	held := k.heldStreams
+	k.heldStreams = nil
+	k.secretsMu.Unlock()
+	for w := range held {
+		w.flush()
+	}
+}
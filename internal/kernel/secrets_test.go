@@ -0,0 +1,56 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskSecrets(t *testing.T) {
+	k := NewHeadless()
+	msg, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+
+	k.AddSecret("abc") // Too short, ignored.
+	k.AddSecret(`s3cr"et`)
+	k.AddSecret(`s3cr"et`)
+	require.Len(t, k.secrets, 1)
+
+	require.NoError(t, PublishWriteStream(msg, StreamStdout, "token=s3cr\"et abc\n"))
+	require.NoError(t, PublishHtml(msg, `<b>s3cr"et</b>`))
+	outputs := msg.Outputs()
+	require.Len(t, outputs, 2)
+	assert.Equal(t, "token="+SecretMask+" abc\n", outputs[0]["text"])
+	assert.Equal(t, "<b>"+SecretMask+"</b>", outputs[1]["data"].(map[string]any)["text/html"])
+
+	// Content without secrets is not changed.
+	content := map[string]any{"text": "nothing to hide"}
+	masked, err := k.MaskSecrets(content)
+	require.NoError(t, err)
+	assert.Equal(t, content, masked)
+}
+
+func TestMaskSecretsAcrossWrites(t *testing.T) {
+	k := NewHeadless()
+	msg, err := NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	k.AddSecret("s3cret")
+	stdout := NewJupyterStreamWriter(msg, StreamStdout)
+
+	// The secret written in two chunks, as a pipe read may split it.
+	_, err = stdout.Write([]byte("token=s3c"))
+	require.NoError(t, err)
+	_, err = stdout.Write([]byte("ret\n"))
+	require.NoError(t, err)
+	outputs := msg.Outputs()
+	require.Len(t, outputs, 1)
+	assert.Equal(t, "token="+SecretMask+"\n", outputs[0]["text"])
+
+	// The start of a secret at the end of the output is published when flushed.
+	_, err = stdout.Write([]byte("done s3"))
+	require.NoError(t, err)
+	assert.Equal(t, "token="+SecretMask+"\ndone ", msg.Outputs()[0]["text"])
+	k.FlushStreams()
+	assert.Equal(t, "token="+SecretMask+"\ndone s3", msg.Outputs()[0]["text"])
+}
@@ -0,0 +1,254 @@
+package kernel
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+)
+
+// This file includes a "text/plain" version in all the display data, so there is always usable content for
+// consoles (jupyter-console, emacs-jupyter), scripts exported with nbconvert, or a grep on the `.ipynb` file.
+
+// MaxTextFallbackLength is the maximum length of the "text/plain" versions generated: longer ones are
+// truncated.
+const MaxTextFallbackLength = 16 * 1024
+
+// TransientTextFallback is the key set in the "transient" data (which is not saved in the notebook) of the
+// outputs whose "text/plain" version was generated by textFallbackData, as opposed to given by the program.
+// It's used to capture only the text output by the program, see CapturedMessage.
+const TransientTextFallback = "gonb_text_fallback"
+
+// textFallbackData includes a "text/plain" version of the display data, if there isn't one: converted from
+// HTML (see HtmlToText), the Markdown source, or a summary of other outputs (e.g.: "[image/png 640x480]",
+// with the sizes from the metadata). Javascript-only outputs (e.g.: loading libraries for widgets) get an
+// empty text.
+func textFallbackData(data, metadata MIMEMap) MIMEMap {
+	if _, hasPlain := data[string(protocol.MIMETextPlain)]; hasPlain || len(data) == 0 {
+		return data
+	}
+	var text string
+	if htmlText, ok := data[string(protocol.MIMETextHTML)].(string); ok {
+		text = HtmlToText(htmlText)
+	} else if markdown, ok := data[string(protocol.MIMETextMarkdown)].(string); ok {
+		text = markdown
+	} else if _, ok := data[string(protocol.MIMETextJavascript)]; ok {
+		text = ""
+	} else if _, ok := data[MIMEApplicationJavascript]; ok {
+		text = ""
+	} else {
+		mimeTypes := make([]string, 0, len(data))
+		for mimeType := range data {
+			if size, ok := metadata[mimeType].(map[string]any); ok && size["width"] != nil {
+				mimeType = fmt.Sprintf("%s %vx%v", mimeType, size["width"], size["height"])
+			}
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+		sort.Strings(mimeTypes)
+		text = fmt.Sprintf("[%s]", strings.Join(mimeTypes, ", "))
+	}
+	if len(text) > MaxTextFallbackLength {
+		cut := MaxTextFallbackLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "…"
+	}
+	converted := make(MIMEMap, len(data)+1)
+	for key, value := range data {
+		converted[key] = value
+	}
+	converted[string(protocol.MIMETextPlain)] = text
+	return converted
+}
+
+var (
+	// reHtmlNonText matches the HTML elements whose contents are not displayed as text.
+	reHtmlNonText = regexp.MustCompile(`(?is)<(script|style|head)[\s>].*?</(script|style|head)\s*>|<!--.*?-->`)
+
+	// reHtmlTag matches an HTML tag, capturing whether it is a closing tag and its name.
+	reHtmlTag = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)[^>]*>`)
+
+	// reBlankLines matches more than one blank line.
+	reBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlBlockTags are the HTML elements rendered in their own lines.
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "pre": true, "details": true, "summary": true, "hr": true,
+	"blockquote": true, "section": true, "header": true, "footer": true, "caption": true,
+}
+
+// htmlTextConverter holds the state of HtmlToText.
+type htmlTextConverter struct {
+	out        strings.Builder
+	inPre      bool
+	tableDepth int
+	rows       [][]string // Rows of the current table.
+	headerRows int        // Number of leading rows with header cells (`<th>`).
+	row        []string   // Cells of the current row.
+	cell       *strings.Builder
+	isHeader   bool // Whether the current row has header cells.
+}
+
+// HtmlToText converts HTML to readable text, for consoles: tags are removed, blocks are placed in their own
+// lines, list items are prefixed by "- ", and tables are rendered as aligned columns.
+func HtmlToText(htmlText string) string {
+	htmlText = reHtmlNonText.ReplaceAllString(htmlText, "")
+	c := &htmlTextConverter{}
+	pos := 0
+	for _, match := range reHtmlTag.FindAllStringSubmatchIndex(htmlText, -1) {
+		c.text(htmlText[pos:match[0]])
+		pos = match[1]
+		closing := match[3] > match[2]
+		c.tag(strings.ToLower(htmlText[match[4]:match[5]]), closing)
+	}
+	c.text(htmlText[pos:])
+	if c.tableDepth > 0 {
+		c.flushTable()
+	}
+	lines := strings.Split(c.out.String(), "\n")
+	for ii, line := range lines {
+		lines[ii] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(reBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// text writes the text between tags, unescaped and, except in `<pre>`, with collapsed white space.
+func (c *htmlTextConverter) text(text string) {
+	text = html.UnescapeString(text)
+	if !c.inPre {
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			return
+		}
+		if out := c.out.String(); c.tableDepth == 0 && out != "" && !strings.HasSuffix(out, "\n") &&
+			!strings.HasSuffix(out, " ") {
+			text = " " + text
+		}
+	}
+	if c.tableDepth > 0 {
+		if c.cell == nil {
+			c.cell = &strings.Builder{} // Text outside cells, e.g.: a caption.
+		}
+		if c.cell.Len() > 0 {
+			c.cell.WriteString(" ")
+		}
+		c.cell.WriteString(strings.TrimSpace(text))
+		return
+	}
+	c.out.WriteString(text)
+}
+
+// tag handles an opening or closing tag.
+func (c *htmlTextConverter) tag(name string, closing bool) {
+	switch {
+	case name == "table" && !closing:
+		c.tableDepth++
+		if c.tableDepth == 1 {
+			c.newLine()
+			c.rows, c.headerRows, c.row, c.cell = nil, 0, nil, nil
+		}
+	case name == "table" && closing:
+		if c.tableDepth == 1 {
+			c.flushTable()
+		}
+		c.tableDepth = max(c.tableDepth-1, 0)
+	case c.tableDepth > 1:
+		// Nested tables are rendered as text in the cell.
+	case c.tableDepth == 1 && name == "tr":
+		if closing || c.row != nil {
+			c.endRow()
+		}
+	case c.tableDepth == 1 && (name == "td" || name == "th"):
+		if closing {
+			c.endCell()
+		} else {
+			c.endCell()
+			c.cell = &strings.Builder{}
+			c.isHeader = c.isHeader || name == "th"
+		}
+	case c.tableDepth == 1:
+		// Other tags inside tables (e.g.: `<code>`) are ignored.
+	case name == "br":
+		c.out.WriteString("\n")
+	case name == "pre":
+		c.newLine()
+		c.inPre = !closing
+	case name == "li" && !closing:
+		c.newLine()
+		c.out.WriteString("- ")
+	case htmlBlockTags[name]:
+		c.newLine()
+	}
+}
+
+// newLine starts a new line, if not at the start of one.
+func (c *htmlTextConverter) newLine() {
+	if c.out.Len() > 0 && !strings.HasSuffix(c.out.String(), "\n") {
+		c.out.WriteString("\n")
+	}
+}
+
+// endCell adds the current cell to the current row.
+func (c *htmlTextConverter) endCell() {
+	if c.cell == nil {
+		return
+	}
+	c.row = append(c.row, c.cell.String())
+	c.cell = nil
+}
+
+// endRow adds the current row to the table.
+func (c *htmlTextConverter) endRow() {
+	c.endCell()
+	if len(c.row) > 0 {
+		if c.isHeader && len(c.rows) == c.headerRows {
+			c.headerRows++
+		}
+		c.rows = append(c.rows, c.row)
+	}
+	c.row, c.isHeader = nil, false
+}
+
+// flushTable renders the current table as aligned columns, with a line separating the header rows.
+func (c *htmlTextConverter) flushTable() {
+	c.endRow()
+	var widths []int
+	for _, row := range c.rows {
+		for ii, cell := range row {
+			if ii >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[ii] = max(widths[ii], utf8.RuneCountInString(cell))
+		}
+	}
+	writeRow := func(row []string) {
+		for ii, cell := range row {
+			if ii > 0 {
+				c.out.WriteString("  ")
+			}
+			c.out.WriteString(cell)
+			if ii < len(row)-1 {
+				c.out.WriteString(strings.Repeat(" ", widths[ii]-utf8.RuneCountInString(cell)))
+			}
+		}
+		c.out.WriteString("\n")
+	}
+	for ii, row := range c.rows {
+		writeRow(row)
+		if ii == c.headerRows-1 {
+			separator := make([]string, len(widths))
+			for jj, width := range widths {
+				separator[jj] = strings.Repeat("-", width)
+			}
+			writeRow(separator)
+		}
+	}
+	c.rows, c.headerRows = nil, 0
+}
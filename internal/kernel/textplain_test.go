@@ -0,0 +1,47 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlToText(t *testing.T) {
+	text := HtmlToText(`<h3>Results</h3><p>Found <b>2</b> matches &amp; more:</p>
+<table>
+<tr><th>Name</th><th>Type</th></tr>
+<tr><td><code>x</code></td><td>int</td></tr>
+<tr><td>longName</td><td>map[string]int</td></tr>
+</table>
+<ul><li>first</li><li>second</li></ul><script>alert(1)</script>`)
+	assert.Equal(t, `Results
+Found 2 matches & more:
+Name      Type
+--------  --------------
+x         int
+longName  map[string]int
+- first
+- second`, text)
+	assert.Equal(t, "line 1\n  indented", HtmlToText("<pre>line 1\n  indented</pre>"))
+}
+
+func TestTextFallbackData(t *testing.T) {
+	data := textFallbackData(MIMEMap{string(protocol.MIMETextHTML): "<b>bold</b>"}, nil)
+	assert.Equal(t, "bold", data[string(protocol.MIMETextPlain)])
+	data = textFallbackData(MIMEMap{string(protocol.MIMETextMarkdown): "**bold**"}, nil)
+	assert.Equal(t, "**bold**", data[string(protocol.MIMETextPlain)])
+	data = textFallbackData(MIMEMap{string(protocol.MIMETextJavascript): "load();"}, nil)
+	assert.Equal(t, "", data[string(protocol.MIMETextPlain)])
+	data = textFallbackData(MIMEMap{string(protocol.MIMEImagePNG): "..."}, MIMEMap{string(protocol.MIMEImagePNG): map[string]any{"width": 640, "height": 480}})
+	assert.Equal(t, "[image/png 640x480]", data[string(protocol.MIMETextPlain)])
+	withPlain := MIMEMap{string(protocol.MIMETextHTML): "<b>x</b>", string(protocol.MIMETextPlain): "plain"}
+	assert.Equal(t, withPlain, textFallbackData(withPlain, nil))
+	data = textFallbackData(MIMEMap{"application/pdf": "...", "image/svg+xml": "<svg/>"}, nil)
+	assert.Equal(t, "[application/pdf, image/svg+xml]", data[string(protocol.MIMETextPlain)])
+	data = textFallbackData(MIMEMap{string(protocol.MIMETextMarkdown): strings.Repeat("é", MaxTextFallbackLength)}, nil)
+	assert.True(t, strings.HasSuffix(data[string(protocol.MIMETextPlain)].(string), "…"))
+	assert.True(t, utf8.ValidString(data[string(protocol.MIMETextPlain)].(string)))
+}
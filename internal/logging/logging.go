@@ -0,0 +1,241 @@
+// Package logging manages the kernel's log: besides STDERR (shown by Jupyter in its console) and the
+// optional `--extra_log` file, `klog` logs are written to a log file per kernel session, named after the
+// notebook, and the most recent lines are kept in memory to be displayed in the notebook with `%log tail`.
+//
+// SetUp directs `klog` (and the standard "log" package) to those writers, with the kernel's unique id as a
+// prefix of every line.
+//
+// The log level (`klog` verbosity) can be changed at runtime with SetLevel (`%log level=debug`).
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// LogDirName is the default directory, under the system temporary directory, of the session log files.
+	LogDirName = "gonb_logs"
+
+	// MaxTailLines is the number of most recent log lines kept in memory, see Tail.
+	MaxTailLines = 1000
+)
+
+// Levels maps the named log levels to `klog` verbosity: "info" logs only the kernel's main events, "debug"
+// also logs the messages handled, and "trace" their contents.
+var Levels = map[string]int{
+	"info":  0,
+	"debug": 1,
+	"trace": 2,
+}
+
+// SessionLog writes the log of the kernel session to a file, and keeps the most recent lines in memory.
+// It implements io.Writer.
+type SessionLog struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	lines   []string // Ring buffer of the last MaxTailLines lines.
+	next    int      // Position in lines of the next line.
+	partial string   // Last line written, if not terminated by "\n".
+}
+
+var (
+	muSession sync.Mutex
+	session   *SessionLog
+)
+
+// SessionLogPath returns the path of the log file for the session with the given unique id, in the given
+// directory (or, if empty, under LogDirName in the system temporary directory). The file is named after
+// the notebook, if its path (notebookPath, usually from goexec.JupyterSessionNameEnv) is known.
+func SessionLogPath(dir, notebookPath, uniqueID string) string {
+	if dir == "" {
+		dir = path.Join(os.TempDir(), LogDirName)
+	}
+	name := "gonb"
+	if notebookPath != "" {
+		name = strings.TrimSuffix(filepath.Base(notebookPath), filepath.Ext(notebookPath))
+	}
+	return path.Join(dir, fmt.Sprintf("%s_%s_%s.log", name, time.Now().Format("20060102_150405"), uniqueID))
+}
+
+// Open creates the session log file in the given path (and its directory, if needed), and makes it
+// the current session log, see Current.
+func Open(logPath string) (*SessionLog, error) {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create directory for the session log %q", logPath)
+	}
+	f, err := os.Create(logPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create session log %q", logPath)
+	}
+	l := &SessionLog{path: logPath, file: f, lines: make([]string, 0, MaxTailLines)}
+	muSession.Lock()
+	session = l
+	muSession.Unlock()
+	return l, nil
+}
+
+// Current returns the current session log, or nil if none was opened.
+func Current() *SessionLog {
+	muSession.Lock()
+	defer muSession.Unlock()
+	return session
+}
+
+// Path of the session log file.
+func (l *SessionLog) Path() string { return l.path }
+
+// Write implements io.Writer.
+func (l *SessionLog) Write(data []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		n, err = l.file.Write(data)
+	} else {
+		n = len(data)
+	}
+	text := l.partial + string(data)
+	lines := strings.Split(text, "\n")
+	l.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		if len(l.lines) < MaxTailLines {
+			l.lines = append(l.lines, line)
+		} else {
+			l.lines[l.next] = line
+		}
+		l.next = (l.next + 1) % MaxTailLines
+	}
+	return
+}
+
+// Tail returns the last n lines logged, up to MaxTailLines.
+func (l *SessionLog) Tail(n int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n = min(n, len(l.lines))
+	tail := make([]string, 0, n)
+	for ii := len(l.lines) - n; ii < len(l.lines); ii++ {
+		// Once the ring buffer is full, the oldest line is in l.next.
+		tail = append(tail, l.lines[(ii+l.next)%len(l.lines)])
+	}
+	return tail
+}
+
+// Close the session log file: lines written after that are only kept in memory.
+func (l *SessionLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return errors.Wrapf(err, "failed to close session log %q", l.path)
+}
+
+// SetUp directs the `klog` logs, and the ones of the standard "log" package, to the given writers (nil
+// ones are skipped), each line prefixed with prefix (e.g.: the colored unique id of the kernel). If there
+// are no writers, it falls back to STDERR, so the logs are never silently dropped.
+func SetUp(prefix string, writers ...io.Writer) {
+	klog.SetOutput(Writer(writers...))
+	// All severities are written to the same writer, each line must be written only once.
+	_ = klogFlag("one_output").Value.Set("true")
+	klog.SetLogFilter(prefixFilter(prefix))
+	klog.CopyStandardLogTo("INFO")
+}
+
+// Writer returns a writer to all the given writers (nil ones are skipped), or os.Stderr if there are none.
+func Writer(writers ...io.Writer) io.Writer {
+	var nonNil []io.Writer
+	for _, w := range writers {
+		if w != nil {
+			nonNil = append(nonNil, w)
+		}
+	}
+	switch len(nonNil) {
+	case 0:
+		return os.Stderr
+	case 1:
+		return nonNil[0]
+	}
+	return io.MultiWriter(nonNil...)
+}
+
+// prefixFilter implements klog.LogFilter, prepending the prefix to every log line.
+type prefixFilter string
+
+// Filter implements klog.LogFilter.
+func (p prefixFilter) Filter(args []any) []any {
+	return append([]any{string(p)}, args...)
+}
+
+// FilterF implements klog.LogFilter.
+func (p prefixFilter) FilterF(format string, args []any) (string, []any) {
+	return "%s" + format, append([]any{string(p)}, args...)
+}
+
+// FilterS implements klog.LogFilter.
+func (p prefixFilter) FilterS(msg string, keysAndValues []any) (string, []any) {
+	return string(p) + msg, keysAndValues
+}
+
+var (
+	onceKlogFlags sync.Once
+	klogFlags     *flag.FlagSet
+)
+
+// klogFlag returns the `klog` flag with the given name, which controls the global `klog` configuration.
+func klogFlag(name string) *flag.Flag {
+	onceKlogFlags.Do(func() {
+		klogFlags = flag.NewFlagSet("klog", flag.ContinueOnError)
+		klog.InitFlags(klogFlags)
+	})
+	return klogFlags.Lookup(name)
+}
+
+// SetLevel sets the log level (`klog` verbosity): it can be one of the names in Levels, or a
+// verbosity number.
+func SetLevel(level string) error {
+	verbosity, found := Levels[strings.ToLower(level)]
+	if !found {
+		var err error
+		verbosity, err = strconv.Atoi(level)
+		if err != nil || verbosity < 0 {
+			return errors.Errorf("invalid log level %q, valid values are \"info\", \"debug\", \"trace\" or "+
+				"a verbosity number", level)
+		}
+	}
+	return errors.Wrapf(klogFlag("v").Value.Set(strconv.Itoa(verbosity)), "failed to set log verbosity")
+}
+
+// Level returns the current log level: the name of the verbosity, if it has one, or the verbosity number.
+func Level() string {
+	verbosity := klogFlag("v").Value.String()
+	for name, v := range Levels {
+		if strconv.Itoa(v) == verbosity {
+			return name
+		}
+	}
+	return verbosity
+}
+
+// reAnsiEscape matches the ANSI escape sequences, used to color the kernel's unique id in the logs.
+var reAnsiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// StripColors removes the ANSI color escape sequences from a log line.
+func StripColors(line string) string {
+	return reAnsiEscape.ReplaceAllString(line, "")
+}
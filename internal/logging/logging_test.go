@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionLog(t *testing.T) {
+	logPath := path.Join(t.TempDir(), "logs", "session.log")
+	l, err := Open(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, l, Current())
+	assert.Equal(t, logPath, l.Path())
+	assert.Empty(t, l.Tail(10))
+
+	// Lines may be written in parts.
+	_, _ = l.Write([]byte("line 1\nline "))
+	assert.Equal(t, []string{"line 1"}, l.Tail(10))
+	_, _ = l.Write([]byte("2\n"))
+	assert.Equal(t, []string{"line 1", "line 2"}, l.Tail(10))
+	assert.Equal(t, []string{"line 2"}, l.Tail(1))
+
+	// Only the last MaxTailLines are kept in memory, but all are written to the file.
+	for ii := 3; ii <= MaxTailLines+5; ii++ {
+		_, _ = fmt.Fprintf(l, "line %d\n", ii)
+	}
+	tail := l.Tail(MaxTailLines + 100)
+	require.Len(t, tail, MaxTailLines)
+	assert.Equal(t, "line 6", tail[0])
+	assert.Equal(t, fmt.Sprintf("line %d", MaxTailLines+5), tail[len(tail)-1])
+	assert.Equal(t, []string{fmt.Sprintf("line %d", MaxTailLines+4), fmt.Sprintf("line %d", MaxTailLines+5)},
+		l.Tail(2))
+	require.NoError(t, l.Close())
+	contents, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "line 1\nline 2\nline 3\n")
+}
+
+func TestSessionLogPath(t *testing.T) {
+	logPath := SessionLogPath("/var/log/gonb", "/home/user/notebooks/analysis.ipynb", "abcd1234")
+	assert.Equal(t, "/var/log/gonb", path.Dir(logPath))
+	assert.Regexp(t, `^analysis_\d{8}_\d{6}_abcd1234\.log$`, path.Base(logPath))
+	assert.Regexp(t, `/gonb_logs/gonb_\d{8}_\d{6}_abcd1234\.log$`, SessionLogPath("", "", "abcd1234"))
+}
+
+func TestWriter(t *testing.T) {
+	assert.Equal(t, os.Stderr, Writer())
+	assert.Equal(t, os.Stderr, Writer(nil))
+	var buf1, buf2 bytes.Buffer
+	assert.Equal(t, &buf1, Writer(nil, &buf1))
+	_, err := Writer(&buf1, nil, &buf2).Write([]byte("line\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "line\n", buf1.String())
+	assert.Equal(t, "line\n", buf2.String())
+}
+
+func TestLevel(t *testing.T) {
+	defer func() { _ = SetLevel("0") }()
+	require.NoError(t, SetLevel("debug"))
+	assert.Equal(t, "debug", Level())
+	require.NoError(t, SetLevel("5"))
+	assert.Equal(t, "5", Level())
+	require.NoError(t, SetLevel("TRACE"))
+	assert.Equal(t, "trace", Level())
+	assert.Error(t, SetLevel("verbose"))
+	assert.Error(t, SetLevel("-1"))
+}
+
+func TestStripColors(t *testing.T) {
+	assert.Equal(t, "[1234abcd] I1015 message", StripColors("\033[7;39;32m[1234abcd]\033[0m I1015 message"))
+}
@@ -0,0 +1,287 @@
+package specialcmd
+
+import (
+	_ "embed"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"k8s.io/klog/v2"
+)
+
+// This file implements the auto-complete of special command lines (starting with "%"): command names,
+// their arguments, file paths and environment variable names. And of shell lines (starting with "!"):
+// executable names, file paths and environment variable names.
+
+//go:embed specialcmd.go
+var specialCmdSource string
+
+// commandNames returns the special commands (without the "%") offered by Complete: the ones dispatched by Parse
+// and execInternal, read from the comparisons of `parts[0]` (the command) in their source, so the list is never
+// out of date. Cell magics (e.g.: `%%http`) and `%%` are not included.
+var commandNames = sync.OnceValue(func() []string {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "specialcmd.go", specialCmdSource, 0)
+	if err != nil {
+		klog.Errorf("Failed to parse the special commands for auto-complete: %+v", err)
+		return nil
+	}
+	isCommand := func(expr ast.Expr) bool {
+		index, ok := expr.(*ast.IndexExpr)
+		if !ok {
+			return false
+		}
+		ident, isIdent := index.X.(*ast.Ident)
+		lit, isLit := index.Index.(*ast.BasicLit)
+		return isIdent && ident.Name == "parts" && isLit && lit.Value == "0"
+	}
+	names := MakeSet[string]()
+	addName := func(expr ast.Expr) {
+		if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if name, err := strconv.Unquote(lit.Value); err == nil && name != "" && !strings.HasPrefix(name, "%") {
+				names.Insert(name)
+			}
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SwitchStmt:
+			if node.Tag == nil || !isCommand(node.Tag) {
+				return true
+			}
+			for _, stmt := range node.Body.List {
+				for _, expr := range stmt.(*ast.CaseClause).List {
+					addName(expr)
+				}
+			}
+		case *ast.BinaryExpr:
+			if node.Op == token.EQL && isCommand(node.X) {
+				addName(node.Y)
+			}
+		}
+		return true
+	})
+	return SortedKeys(names)
+})
+
+// commandArgs returns the fixed arguments (subcommands or options) accepted by the special commands.
+var commandArgs = map[string]func(goExec *goexec.State) []string{
+	"autoget":   func(*goexec.State) []string { return []string{"allow", "deny"} },
+	"bugreport": func(*goexec.State) []string { return []string{"--redact"} },
+	"compose":   func(*goexec.State) []string { return []string{"off", "on", "show"} },
+	"gpu":       func(*goexec.State) []string { return []string{"info"} },
+	"journal":   func(*goexec.State) []string { return []string{"discard", "restore"} },
+	"log":       func(*goexec.State) []string { return []string{"level=debug", "level=info", "level=trace", "tail"} },
+	"prelude":   func(*goexec.State) []string { return []string{"--file=", "reset"} },
+	"record":    func(*goexec.State) []string { return []string{"start", "stop"} },
+	"secret":    func(*goexec.State) []string { return []string{"get"} },
+	"tap":       func(*goexec.State) []string { return []string{"kafka", "nats"} },
+	"serve":     func(*goexec.State) []string { return []string{"--grpc"} },
+	"settings":  func(*goexec.State) []string { return []string{"clear", "save"} },
+	"tinygo":    func(*goexec.State) []string { return []string{"off", "target="} },
+	"upgrade":   func(*goexec.State) []string { return []string{"--check", "--force"} },
+	"variables": func(*goexec.State) []string { return []string{"--inspector", "--json"} },
+	"workspace": func(*goexec.State) []string { return []string{"off", "on"} },
+	"config":    configCompletions,
+	"snippet": func(*goexec.State) []string {
+		snippets, err := loadSnippets()
+		if err != nil {
+			return nil
+		}
+		return SortedKeys(snippets)
+	},
+}
+
+// pathCommands are the special commands whose arguments are file paths, mapped to whether they take only
+// directories.
+var pathCommands = map[string]bool{
+	"cd": true, "nbimport": false, "record": false, "track": false, "untrack": false, "writefile": false,
+	"%c": false, "run-cli": false,
+}
+
+// configCompletions returns the `key=` of each configuration option, and `key=on` and `key=off` for the
+// boolean options.
+func configCompletions(goExec *goexec.State) []string {
+	var completions []string
+	for _, key := range SortedKeys(configOptions) {
+		completions = append(completions, key+"=")
+		if goExec == nil {
+			continue
+		}
+		if value := configOptions[key].get(goExec); value == "on" || value == "off" {
+			completions = append(completions, key+"=off", key+"=on")
+		}
+	}
+	return completions
+}
+
+// Complete returns the completions for the special command (starting with "%") or shell command (starting
+// with "!") in the line, with the cursor at the byte position col. It returns the matches, and the byte
+// position in the line where the text replaced by them starts.
+func Complete(goExec *goexec.State, line string, col int) (matches []string, start int) {
+	prefix := line[:col]
+	if strings.HasPrefix(prefix, "!") {
+		return completeShell(prefix)
+	}
+	if !strings.HasPrefix(prefix, "%") {
+		return nil, col
+	}
+	fields := strings.Fields(prefix[1:])
+	current := ""
+	if len(fields) > 0 && !strings.HasSuffix(prefix, " ") {
+		current = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+	start = col - len(current)
+
+	if len(fields) == 0 {
+		// Completing the command name.
+		if current == "" && strings.TrimSpace(prefix) != "%" {
+			return nil, col
+		}
+		for _, name := range commandNames() {
+			if strings.HasPrefix(name, current) {
+				matches = append(matches, name)
+			}
+		}
+		return matches, start
+	}
+
+	command := fields[0]
+	if envPrefix, found := strings.CutPrefix(current, "$"); found {
+		for _, name := range envVarNames(envPrefix) {
+			matches = append(matches, "$"+name)
+		}
+		return matches, start
+	}
+	if command == "env" && len(fields) == 1 {
+		for _, name := range envVarNames(current) {
+			matches = append(matches, name+"=")
+		}
+		return matches, start
+	}
+	if dirsOnly, found := pathCommands[command]; found && !(command == "record" && len(fields) == 1) {
+		return completePath(current, dirsOnly), start
+	}
+	if argsFn, found := commandArgs[command]; found {
+		for _, arg := range argsFn(goExec) {
+			if strings.HasPrefix(arg, current) {
+				matches = append(matches, arg)
+			}
+		}
+	}
+	return matches, start
+}
+
+// completeShell returns the completions for the shell command line prefix (up to the cursor): the name of
+// the executable for the first word (or of each command after a pipe or separator), environment variables
+// (prefixed by "$") or file paths otherwise.
+func completeShell(prefix string) (matches []string, start int) {
+	line := strings.TrimPrefix(prefix[1:], "*")
+	current := line[strings.LastIndexAny(line, " \t")+1:]
+	start = len(prefix) - len(current)
+	if current == "" {
+		return nil, start
+	}
+	if envPrefix, found := strings.CutPrefix(current, "$"); found {
+		for _, name := range envVarNames(envPrefix) {
+			matches = append(matches, "$"+name)
+		}
+		return matches, start
+	}
+	if isCommandPosition(strings.TrimSpace(line[:len(line)-len(current)])) && !strings.ContainsRune(current, '/') {
+		return executableNames(current), start
+	}
+	return completePath(current, false), start
+}
+
+// isCommandPosition returns whether the word following the shell text before is a command name: at the
+// start of the line or after a pipe or command separator.
+func isCommandPosition(before string) bool {
+	return before == "" || strings.HasSuffix(before, "|") || strings.HasSuffix(before, ";") ||
+		strings.HasSuffix(before, "&&") || strings.HasSuffix(before, "(")
+}
+
+// executableNames returns the sorted names of the executables in the `PATH` starting with prefix.
+func executableNames(prefix string) []string {
+	seen := MakeSet[string]()
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, prefix) || seen.Has(name) {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			seen.Insert(name)
+		}
+	}
+	return SortedKeys(seen)
+}
+
+// envVarNames returns the sorted names of the environment variables starting with prefix.
+func envVarNames(prefix string) []string {
+	var names []string
+	for _, keyValue := range os.Environ() {
+		name, _, _ := strings.Cut(keyValue, "=")
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completePath returns the paths of the files (or only directories) starting with prefix, relative to the
+// current directory if prefix is not absolute. Directories end with "/". Hidden files are only included if
+// prefix names them (starts with ".").
+func completePath(prefix string, dirsOnly bool) []string {
+	dir, base := filepath.Split(prefix)
+	if strings.HasPrefix(prefix, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:]) + string(filepath.Separator)
+		}
+	}
+	listDir := dir
+	if listDir == "" {
+		listDir = "."
+	}
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return nil
+	}
+	displayDir := prefix[:len(prefix)-len(base)] // Directory as typed (e.g.: with "~/").
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(listDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			matches = append(matches, displayDir+name+"/")
+		} else if !dirsOnly {
+			matches = append(matches, displayDir+name)
+		}
+	}
+	return matches
+}
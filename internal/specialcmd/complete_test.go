@@ -0,0 +1,113 @@
+package specialcmd
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteDispatchedCommands(t *testing.T) {
+	// Commands of the cases of the `switch parts[0]` of execInternal.
+	body, _, found := strings.Cut(specialCmdSource[strings.Index(specialCmdSource, "\nfunc execInternal("):], "\n}\n")
+	require.True(t, found)
+	var dispatched []string
+	for _, match := range regexp.MustCompile(`(?m)^\tcase (.*):$`).FindAllStringSubmatch(body, -1) {
+		for _, name := range regexp.MustCompile(`"([^"]*)"`).FindAllStringSubmatch(match[1], -1) {
+			if name[1] != "%" {
+				dispatched = append(dispatched, name[1])
+			}
+		}
+	}
+	require.Contains(t, dispatched, "bugreport")
+	dispatched = append(dispatched, "writefile") // Handled by Parse.
+
+	goExec := &goexec.State{}
+	for _, name := range dispatched {
+		matches, _ := Complete(goExec, "%"+name, len(name)+1)
+		assert.Containsf(t, matches, name, "special command %%%s can't be completed", name)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	goExec := &goexec.State{}
+
+	// Command names.
+	matches, start := Complete(goExec, "%tr", 3)
+	assert.Equal(t, []string{"track"}, matches)
+	assert.Equal(t, 1, start)
+	matches, _ = Complete(goExec, "%", 1)
+	assert.Equal(t, commandNames(), matches)
+
+	// Arguments.
+	line := "%config leak"
+	matches, start = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{"leak_check=", "leak_check=off", "leak_check=on"}, matches)
+	assert.Equal(t, len("%config "), start)
+	line = "%journal "
+	matches, start = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{"discard", "restore"}, matches)
+	assert.Equal(t, len(line), start)
+
+	// Environment variables.
+	t.Setenv("GONB_TEST_COMPLETE", "1")
+	line = "%env GONB_TEST_COMP"
+	matches, _ = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{"GONB_TEST_COMPLETE="}, matches)
+	line = "%cd $GONB_TEST_COMP"
+	matches, _ = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{"$GONB_TEST_COMPLETE"}, matches)
+
+	// File paths: only directories for `%cd`.
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(path.Join(dir, "data"), 0700))
+	require.NoError(t, os.WriteFile(path.Join(dir, "data.ipynb"), nil, 0600))
+	require.NoError(t, os.WriteFile(path.Join(dir, ".hidden"), nil, 0600))
+	line = "%nbimport " + dir + "/"
+	matches, start = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{dir + "/data/", dir + "/data.ipynb"}, matches)
+	assert.Equal(t, len("%nbimport "), start)
+	line = "%cd " + dir + "/da"
+	matches, _ = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{dir + "/data/"}, matches)
+
+	// Not a special command.
+	matches, _ = Complete(goExec, "fmt.Pr", 6)
+	assert.Empty(t, matches)
+}
+
+func TestCompleteShell(t *testing.T) {
+	goExec := &goexec.State{}
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(binDir, "gonb-test-tool"), nil, 0700))
+	require.NoError(t, os.WriteFile(path.Join(binDir, "gonb-test-data"), nil, 0600)) // Not executable.
+	t.Setenv("PATH", binDir)
+
+	// Executable names: at the start of the line and after a pipe.
+	matches, start := Complete(goExec, "!gonb-te", 8)
+	assert.Equal(t, []string{"gonb-test-tool"}, matches)
+	assert.Equal(t, 1, start)
+	line := "!*cat go.mod | gonb-"
+	matches, start = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{"gonb-test-tool"}, matches)
+	assert.Equal(t, len(line)-len("gonb-"), start)
+
+	// File paths for the arguments.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "input.csv"), nil, 0600))
+	line = "!gonb-test-tool " + dir + "/in"
+	matches, start = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{dir + "/input.csv"}, matches)
+	assert.Equal(t, len("!gonb-test-tool "), start)
+
+	// Environment variables.
+	t.Setenv("GONB_TEST_COMPLETE", "1")
+	line = "!echo $GONB_TEST_COMP"
+	matches, _ = Complete(goExec, line, len(line))
+	assert.Equal(t, []string{"$GONB_TEST_COMPLETE"}, matches)
+}
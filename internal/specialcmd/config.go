@@ -0,0 +1,404 @@
+package specialcmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements the "%config" command, that sets configuration options of the kernel in the
+// form `key=value`.
+
+// configOption is one option that can be set with `%config`.
+type configOption struct {
+	description string
+	get         func(goExec *goexec.State) string
+	set         func(goExec *goexec.State, value string) error
+}
+
+// configOptions are all the options that can be set with `%config`.
+var configOptions = map[string]configOption{
+	"allow_unused": {
+		description: "Mark the local variables of the `func main()` created by `%%` as used (adding `_ = x`), " +
+			"so unused variables of exploratory code don't fail the compilation.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.AllowUnused) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.AllowUnused, err = parseConfigBool(value)
+			return
+		},
+	},
+	"binary_output": {
+		description: "Detect images (PNG, JPEG or GIF) written to the standard output by the programs of the cells " +
+			"and shell commands: they are saved to a file and displayed, instead of streamed as text. Defaults to on.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.BinaryOutput) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.BinaryOutput, err = parseConfigBool(value)
+			return
+		},
+	},
+	"chart_snapshot": {
+		description: "Also capture the interactive charts (e.g. `plotly.DisplayFig`) as static PNG images, rendered " +
+			"by the browser and included in their outputs, so exported notebooks (PDF, GitHub previews) show them.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.ChartSnapshot) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.ChartSnapshot, err = parseConfigBool(value)
+			return
+		},
+	},
+	"display_fps": {
+		description: "Maximum number of updates per second of each display updated by the program (e.g. with " +
+			"`gonbui.UpdateHtml`): more frequent updates are coalesced. 0 disables it. Defaults to " +
+			strconv.Itoa(goexec.DefaultDisplayMaxFPS) + ".",
+		get: func(goExec *goexec.State) string { return strconv.Itoa(goExec.DisplayMaxFPS) },
+		set: func(goExec *goexec.State, value string) error {
+			fps, err := strconv.Atoi(value)
+			if err != nil || fps < 0 {
+				return errors.Errorf("invalid number of updates per second %q for display_fps", value)
+			}
+			goExec.DisplayMaxFPS = fps
+			return nil
+		},
+	},
+	"export_safe_html": {
+		description: "Convert Javascript-only outputs to HTML (with the script), so they are kept when the notebook " +
+			"is exported with nbconvert (e.g. to HTML).",
+		get: func(goExec *goexec.State) string {
+			return formatConfigBool(goExec.Kernel != nil && goExec.Kernel.ExportSafeHTML)
+		},
+		set: func(goExec *goexec.State, value string) error {
+			enabled, err := parseConfigBool(value)
+			if err != nil {
+				return err
+			}
+			if goExec.Kernel == nil {
+				return errors.New("export_safe_html requires a kernel")
+			}
+			goExec.Kernel.ExportSafeHTML = enabled
+			return nil
+		},
+	},
+	"frontend": {
+		description: "The Jupyter front-end, whose rendering differences are accounted for: `auto` (detected, the " +
+			"default), `jupyter` (JupyterLab or Notebook), `vscode` or `console` (jupyter-console, emacs-jupyter).",
+		get: func(goExec *goexec.State) string {
+			if goExec.Kernel == nil || goExec.Kernel.Frontend == kernel.FrontendAuto {
+				return "auto"
+			}
+			return string(goExec.Kernel.Frontend)
+		},
+		set: func(goExec *goexec.State, value string) error {
+			frontend := kernel.Frontend(strings.ToLower(value))
+			switch frontend {
+			case "auto":
+				frontend = kernel.FrontendAuto
+			case kernel.FrontendJupyter, kernel.FrontendVSCode, kernel.FrontendConsole:
+			default:
+				return errors.Errorf("invalid front-end %q, valid values are auto, jupyter, vscode or console", value)
+			}
+			if goExec.Kernel == nil {
+				return errors.New("frontend requires a kernel")
+			}
+			goExec.Kernel.Frontend = frontend
+			return nil
+		},
+	},
+	"goexperiment": {
+		description: "Overrides `GOEXPERIMENT` for the `go` tool: comma-separated experiments to enable " +
+			"(e.g.: `rangefunc`), also used by `gopls`.",
+		get: func(goExec *goexec.State) string { return goExec.GoExperiment },
+		set: func(goExec *goexec.State, value string) error {
+			goExec.GoExperiment = value
+			return nil
+		},
+	},
+	"goprivate": {
+		description: "Overrides `GOPRIVATE` for the `go` tool: comma-separated glob patterns of private modules, " +
+			"fetched directly (using `~/.netrc` or git credentials) and not checked against the checksum database.",
+		get: func(goExec *goexec.State) string { return goExec.GoPrivate },
+		set: func(goExec *goexec.State, value string) error {
+			goExec.GoPrivate = value
+			return nil
+		},
+	},
+	"goproxy": {
+		description: "Overrides `GOPROXY` for the `go` tool: comma-separated module proxy URLs, `direct` or `off`.",
+		get:         func(goExec *goexec.State) string { return goExec.GoProxy },
+		set: func(goExec *goexec.State, value string) error {
+			if value != "" {
+				if err := goexec.ValidateGoProxy(value); err != nil {
+					return err
+				}
+			}
+			goExec.GoProxy = value
+			return nil
+		},
+	},
+	"gosumdb": {
+		description: "Overrides `GOSUMDB` for the `go` tool: the checksum database used to verify modules, or `off`.",
+		get:         func(goExec *goexec.State) string { return goExec.GoSumDB },
+		set: func(goExec *goexec.State, value string) error {
+			if value != "" {
+				if err := goexec.ValidateGoSumDB(value); err != nil {
+					return err
+				}
+			}
+			goExec.GoSumDB = value
+			return nil
+		},
+	},
+	"hide_warnings": {
+		description: "Regular expression of the lines of the build output (e.g. recurring warnings of generated " +
+			"code) not displayed. Empty to display all lines.",
+		get: func(goExec *goexec.State) string {
+			if goExec.HideWarnings == nil {
+				return ""
+			}
+			return goExec.HideWarnings.String()
+		},
+		set: func(goExec *goexec.State, value string) error { return goExec.SetHideWarnings(value) },
+	},
+	"isolated_gopath": {
+		description: "Use a GOPATH (and so GOBIN and module cache) private to this notebook, under `$GONB_TMP_DIR/gopath`, " +
+			"so binaries installed with `go install` and downloaded modules don't collide with other notebooks.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.IsolatedGoPath) },
+		set: func(goExec *goexec.State, value string) error {
+			enabled, err := parseConfigBool(value)
+			if err != nil {
+				return err
+			}
+			return goExec.SetIsolatedGoPath(enabled)
+		},
+	},
+	"leak_check": {
+		description: "Report the goroutines still running when the `func main()` of `%%` cells returns, " +
+			"with their stacks.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.LeakCheck) },
+		set: func(goExec *goexec.State, value string) error {
+			enabled, err := parseConfigBool(value)
+			if err != nil {
+				return err
+			}
+			return goExec.SetLeakCheck(enabled)
+		},
+	},
+	"logview": {
+		description: "Display the structured logs (JSON lines, e.g. from `log/slog`'s JSONHandler, zap or zerolog) " +
+			"printed by the program as a filterable table, instead of raw text.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.LogView) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.LogView, err = parseConfigBool(value)
+			return
+		},
+	},
+	"main_context": {
+		description: "Define a `ctx` (`context.Context`) in the `func main()` of `%%` cells, canceled when the " +
+			"execution is interrupted, e.g. to use with `http.NewRequestWithContext(ctx, ...)`.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.MainContext) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.MainContext, err = parseConfigBool(value)
+			return
+		},
+	},
+	"notify_changes": {
+		description: "Report the tracked local sources (see `%track`, and the local modules in `go.mod` and " +
+			"`go.work`) that changed on disk since the last execution, when executing a cell.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.NotifyChangedSources) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.NotifyChangedSources, err = parseConfigBool(value)
+			return
+		},
+	},
+	"offline": {
+		description: "Don't access the network for Go modules (`GOPROXY=off`), and use vendored dependencies " +
+			"(see `%vendor`) if available.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.Offline) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.Offline, err = parseConfigBool(value)
+			return
+		},
+	},
+	"pager_lines": {
+		description: "Number of lines of the output of the program displayed as they are printed, and at the end of " +
+			"the execution: the lines in between are hidden in a pager, with a button to expand them. 0 disables " +
+			"the pager. Defaults to " + strconv.Itoa(goexec.DefaultPagerLines) + ".",
+		get: func(goExec *goexec.State) string { return strconv.Itoa(goExec.PagerLines) },
+		set: func(goExec *goexec.State, value string) error {
+			lines, err := strconv.Atoi(value)
+			if err != nil || lines < 0 {
+				return errors.Errorf("invalid number of lines %q for pager_lines", value)
+			}
+			goExec.PagerLines = lines
+			return nil
+		},
+	},
+	"playground_url": {
+		description: "URL of the Go Playground instance where `%share` posts programs. Defaults to `" +
+			goexec.DefaultPlaygroundURL + "`.",
+		get: func(goExec *goexec.State) string { return goExec.PlaygroundURL },
+		set: func(goExec *goexec.State, value string) error {
+			if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return errors.Errorf("invalid playground URL %q, it must start with http:// or https://", value)
+			}
+			goExec.PlaygroundURL = value
+			return nil
+		},
+	},
+	"reactive": {
+		description: "Experimental: executing a cell that changes memorized declarations re-executes the cells " +
+			"executed before that use them, in order. Cells with special flags (e.g. `%test`) are not re-executed.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.Reactive) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.Reactive, err = parseConfigBool(value)
+			return
+		},
+	},
+	"report_resources": {
+		description: "After each execution, show the wall time, CPU time, maximum memory (RSS) and exit status " +
+			"of the program, in a collapsible footer.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.ReportResources) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.ReportResources, err = parseConfigBool(value)
+			return
+		},
+	},
+	"secret_command": {
+		description: "Command that prints the secret read by `%secret get <name>`, where `{name}` is replaced by " +
+			"the name of the secret, e.g. `vault kv get -field={name} secret/notebooks`. If set, it is used by default.",
+		get: func(goExec *goexec.State) string { return goExec.SecretCommand },
+		set: func(goExec *goexec.State, value string) error {
+			goExec.SecretCommand = value
+			return nil
+		},
+	},
+	"seed": {
+		description: "Seed `math/rand` with the given number in the programs and tests of the cells, to make " +
+			"them deterministic (e.g. for autograding), or `off`. The seed is also given in `$GONB_SEED`.",
+		get: func(goExec *goexec.State) string {
+			if goExec.Seed == nil {
+				return "off"
+			}
+			return strconv.FormatInt(*goExec.Seed, 10)
+		},
+		set: func(goExec *goexec.State, value string) error {
+			if value == "off" {
+				return goExec.SetSeed(nil)
+			}
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.Errorf("invalid seed %q, use an integer or `off`", value)
+			}
+			return goExec.SetSeed(&seed)
+		},
+	},
+	"serve_url": {
+		description: "Template of the URL used to preview `%serve` cells, where `{port}` is replaced by the port " +
+			"served. Defaults to `" + goexec.DefaultServeURL + "`, use e.g. `/proxy/{port}/` with jupyter-server-proxy.",
+		get: func(goExec *goexec.State) string { return goExec.ServeURL },
+		set: func(goExec *goexec.State, value string) error {
+			goExec.ServeURL = value
+			return nil
+		},
+	},
+	"shell": {
+		description: "The shell that executes the `!` commands, e.g. `bash`, `zsh`, `fish`, `pwsh` or `cmd`. Defaults to `" +
+			goexec.DefaultShell + "`.",
+		get: func(goExec *goexec.State) string {
+			shell, _ := goExec.ShellCommand("")
+			return shell
+		},
+		set: func(goExec *goexec.State, value string) error {
+			if _, err := exec.LookPath(value); err != nil {
+				return errors.Wrapf(err, "shell %q not found", value)
+			}
+			goExec.Shell = value
+			return nil
+		},
+	},
+	"stale_hints": {
+		description: "After a cell changes memorized declarations, list the cells executed before that use them, " +
+			"since their results may be stale. Defaults to on.",
+		get: func(goExec *goexec.State) string { return formatConfigBool(goExec.StaleHints) },
+		set: func(goExec *goexec.State, value string) (err error) {
+			goExec.StaleHints, err = parseConfigBool(value)
+			return
+		},
+	},
+	"toolchain_retries": {
+		description: "Number of times toolchain operations that fail with a transient error (\"text file busy\", " +
+			"module cache lock errors or `gopls` timeouts) are retried, with exponential backoff. Defaults to " +
+			strconv.Itoa(goexec.DefaultToolchainRetries) + ".",
+		get: func(goExec *goexec.State) string { return strconv.Itoa(goExec.ToolchainRetries) },
+		set: func(goExec *goexec.State, value string) error {
+			retries, err := strconv.Atoi(value)
+			if err != nil || retries < 0 {
+				return errors.Errorf("invalid number of retries %q for toolchain_retries", value)
+			}
+			goExec.ToolchainRetries = retries
+			return nil
+		},
+	},
+}
+
+// parseConfigBool parses the boolean value of a configuration option.
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "1", "yes":
+		return true, nil
+	case "off", "false", "0", "no":
+		return false, nil
+	}
+	return false, errors.Errorf("invalid boolean value %q, use `on` or `off`", value)
+}
+
+func formatConfigBool(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+// execConfig sets the given configuration options, each in the form `key=value`, or lists the current
+// configuration, if no options are given. It implements the "%config" command.
+func execConfig(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 0 {
+		var sb strings.Builder
+		sb.WriteString("| Option | Value | Description |\n|---|---|---|\n")
+		for _, key := range common.SortedKeys(configOptions) {
+			option := configOptions[key]
+			sb.WriteString(fmt.Sprintf("| `%s` | `%s` | %s |\n", key, option.get(goExec), option.description))
+		}
+		if values, err := goExec.EffectiveModuleEnv(); err == nil {
+			sb.WriteString("\n**Effective `go env`:**\n\n")
+			for _, key := range goexec.ModuleEnvVars {
+				sb.WriteString(fmt.Sprintf("* `%s=%s`\n", key, values[key]))
+			}
+		}
+		return kernel.PublishMarkdown(msg, sb.String())
+	}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			return errors.Errorf("%%config options must be given as `key=value`, got %q", arg)
+		}
+		option, found := configOptions[key]
+		if !found {
+			return errors.Errorf("%%config: unknown option %q, valid options are %q", key, common.SortedKeys(configOptions))
+		}
+		if err := option.set(goExec, value); err != nil {
+			return errors.WithMessagef(err, "%%config %s", key)
+		}
+		goExec.RecordConfigSetting(key, value)
+		goExec.UpdateGoplsSettings()
+		err := kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("%%config %s=%s\n", key, option.get(goExec)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,12 +5,15 @@ import (
 	"github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
 	"k8s.io/klog/v2"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
-// This file handles the commands %list (or %ls), %remove (%rm) and %reset, which help manipulate
-// memorized definitions.
+// This file handles the commands %list (or %ls), %remove (%rm), %reset, %variables and %fix, which help
+// manipulate memorized definitions. See also %rename, implemented by goexec.State.Rename.
 
 // reset removes all definitions memorized, as if the kernel had been reset.
 func resetDefinitions(msg kernel.Message, goExec *goexec.State) {
@@ -46,6 +49,7 @@ func listDefinitions(msg kernel.Message, goExec *goexec.State) {
 	displayEnumeration(msg, "Types", common.SortedKeys(goExec.Definitions.Types))
 	displayEnumeration(msg, "Variables", common.SortedKeys(goExec.Definitions.Variables))
 	displayEnumeration(msg, "Functions", common.SortedKeys(goExec.Definitions.Functions))
+	displayEnumeration(msg, "Generate Directives", common.SortedKeys(goExec.Definitions.GenerateDirectives))
 }
 
 func removeDefinitionImpl[T any](msg kernel.Message, mapName string, m *map[string]*T, key string) bool {
@@ -72,6 +76,7 @@ func removeDefinitions(msg kernel.Message, goExec *goexec.State, keys []string)
 		found = found || removeDefinitionImpl(msg, "type", &goExec.Definitions.Types, key)
 		found = found || removeDefinitionImpl(msg, "var", &goExec.Definitions.Variables, key)
 		found = found || removeDefinitionImpl(msg, "func", &goExec.Definitions.Functions, key)
+		found = found || removeDefinitionImpl(msg, "go:generate", &goExec.Definitions.GenerateDirectives, key)
 		if !found {
 			err := kernel.PublishWriteStream(msg, kernel.StreamStderr,
 				fmt.Sprintf(". key %q not found in any definition, not removed\n", key))
@@ -81,3 +86,48 @@ func removeDefinitions(msg kernel.Message, goExec *goexec.State, keys []string)
 		}
 	}
 }
+
+// reInspectorCall matches the arguments of the calls of the jupyterlab-variableinspector extension to the
+// commands of the language model of GoNB (see goexec.InspectorQueryCommand), e.g.: `--inspector-delete('x')`.
+var reInspectorCall = regexp.MustCompile(`^(--inspector-\w+)\((.*)\)$`)
+
+// execVariables implements the "%variables" command: it lists the memorized variables, and answers the commands
+// of the jupyterlab-variableinspector extension.
+func execVariables(msg kernel.Message, goExec *goexec.State, args []string) error {
+	switch {
+	case len(args) == 0:
+		return goExec.Variables(msg, false)
+	case len(args) == 1 && args[0] == "--json":
+		return goExec.Variables(msg, true)
+	case len(args) == 1 && args[0] == "--inspector":
+		return goExec.InspectVariablesResult(msg)
+	}
+	matches := reInspectorCall.FindStringSubmatch(strings.Join(args, " "))
+	if matches == nil {
+		return errors.Errorf("%%variables usage: `%%variables [--json]`, got %q", args)
+	}
+	switch "%variables " + matches[1] {
+	case goexec.InspectorDeleteCommand:
+		name := strings.Trim(strings.TrimSpace(matches[2]), `'"`)
+		removeDefinitions(msg, goExec, []string{name})
+		return goExec.InspectVariablesResult(msg)
+	case goexec.InspectorMatrixQueryCommand, goexec.InspectorWidgetQueryCommand:
+		return errors.Errorf("%%variables %s: Go variables can't be inspected as matrices or widgets", matches[1])
+	}
+	return errors.Errorf("%%variables: unknown variable inspector command %q", matches[1])
+}
+
+// execFix lists or applies `gopls` quick-fixes to the memorized definitions. It implements the "%fix" command.
+func execFix(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 0 {
+		return goExec.Fix(msg, -1)
+	}
+	if len(args) > 1 {
+		return errors.Errorf("%%fix takes at most one argument, the number of the fix to apply, got %q", args)
+	}
+	fixNum, err := strconv.Atoi(args[0])
+	if err != nil || fixNum < 1 {
+		return errors.Errorf("%%fix argument must be the number of the fix to apply (as listed by `%%fix`), got %q", args[0])
+	}
+	return goExec.Fix(msg, fixNum-1)
+}
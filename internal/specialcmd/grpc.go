@@ -0,0 +1,30 @@
+package specialcmd
+
+import (
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// splitGRPCCall parses the `%grpc call <Service>/<Method> <json>` command. The request is taken verbatim
+// (not split or unquoted like other special commands), since it is JSON.
+func splitGRPCCall(cmdStr string) (fullMethod, request string, err error) {
+	fields := strings.Fields(cmdStr)
+	if len(fields) < 3 || fields[0] != "grpc" || fields[1] != "call" {
+		return "", "", errors.New("%grpc usage: `%grpc call <package>.<Service>/<Method> <json request>`")
+	}
+	fullMethod = fields[2]
+	_, rest, _ := strings.Cut(cmdStr, fullMethod)
+	return fullMethod, strings.TrimSpace(rest), nil
+}
+
+// execGRPC implements `%grpc call`.
+func execGRPC(msg kernel.Message, goExec *goexec.State, cmdStr string) error {
+	fullMethod, request, err := splitGRPCCall(cmdStr)
+	if err != nil {
+		return err
+	}
+	return goExec.GRPCCall(msg, fullMethod, request)
+}
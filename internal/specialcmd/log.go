@@ -0,0 +1,83 @@
+package specialcmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/logging"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// DefaultLogTailLines is the number of lines displayed by `%log tail`, if not given.
+const DefaultLogTailLines = 20
+
+// sessionLogContents returns the contents of the session log, or of its most recent lines if the file can't
+// be read. It returns "" if there is no session log.
+func sessionLogContents() string {
+	sessionLog := logging.Current()
+	if sessionLog == nil {
+		return ""
+	}
+	contents, err := os.ReadFile(sessionLog.Path())
+	if err == nil {
+		return string(contents)
+	}
+	klog.Warningf("Failed to read the session log %q: %+v", sessionLog.Path(), err)
+	var sb strings.Builder
+	for _, line := range sessionLog.Tail(logging.MaxTailLines) {
+		sb.WriteString(logging.StripColors(line))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// execLog implements `%log`, `%log level=<level>` and `%log tail [<n>]`, to troubleshoot the kernel from
+// the notebook, see package logging.
+func execLog(msg kernel.Message, args []string) error {
+	const usage = "%log usage: `%log`, `%log level=<info|debug|trace|verbosity>` or `%log tail [<num_lines>]`"
+	sessionLog := logging.Current()
+	switch {
+	case len(args) == 0:
+		logPath := "(no session log)"
+		if sessionLog != nil {
+			logPath = sessionLog.Path()
+		}
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+			"Log level: %s\nSession log: %s\n", logging.Level(), logPath))
+
+	case len(args) == 1 && strings.HasPrefix(args[0], "level="):
+		if err := logging.SetLevel(strings.TrimPrefix(args[0], "level=")); err != nil {
+			return err
+		}
+		klog.InfoS("Log level changed", "level", logging.Level())
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("Log level set to %s.\n", logging.Level()))
+
+	case args[0] == "tail" && len(args) <= 2:
+		numLines := DefaultLogTailLines
+		if len(args) == 2 {
+			var err error
+			numLines, err = strconv.Atoi(args[1])
+			if err != nil || numLines <= 0 {
+				return errors.Errorf("%%log tail: invalid number of lines %q", args[1])
+			}
+		}
+		if sessionLog == nil {
+			return errors.New("%log tail: no session log, the kernel was not started by Jupyter")
+		}
+		lines := sessionLog.Tail(numLines)
+		if len(lines) == 0 {
+			return kernel.PublishWriteStream(msg, kernel.StreamStdout, "(log is empty)\n")
+		}
+		var sb strings.Builder
+		for _, line := range lines {
+			sb.WriteString(logging.StripColors(line))
+			sb.WriteByte('\n')
+		}
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
+	}
+	return errors.New(usage)
+}
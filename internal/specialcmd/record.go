@@ -0,0 +1,52 @@
+package specialcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// execRecord implements `%record start [<session file>]` and `%record stop`, see kernel.Recorder.
+func execRecord(msg kernel.Message, goExec *goexec.State, args []string) error {
+	k := goExec.Kernel
+	if k == nil {
+		return errors.New("%record requires a kernel")
+	}
+	if len(args) == 0 || (args[0] != "start" && args[0] != "stop") || len(args) > 2 ||
+		(args[0] == "stop" && len(args) > 1) {
+		return errors.New("%record usage: `%record start [<session file>]` or `%record stop`")
+	}
+	if args[0] == "stop" {
+		if k.Recorder == nil {
+			return errors.New("%record stop: no session being recorded")
+		}
+		recorder := k.Recorder
+		k.Recorder = nil
+		if err := recorder.Close(); err != nil {
+			return err
+		}
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+			"Session recorded in %q: replay it with `gonb replay %s`.\n", recorder.Path(), recorder.Path()))
+	}
+
+	sessionPath := fmt.Sprintf("gonb_session_%s.jsonl", time.Now().Format("20060102_150405"))
+	if len(args) == 2 {
+		sessionPath = args[1]
+	}
+	if k.Recorder != nil {
+		if err := k.Recorder.Close(); err != nil {
+			return err
+		}
+		k.Recorder = nil
+	}
+	recorder, err := kernel.NewRecorder(sessionPath)
+	if err != nil {
+		return err
+	}
+	k.Recorder = recorder
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+		"Recording the following cells in %q, until `%%record stop`.\n", sessionPath))
+}
@@ -0,0 +1,58 @@
+package specialcmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+)
+
+// This file implements restoring the settings persisted in the notebook metadata with `%settings save`,
+// see goexec.NotebookSettings.
+
+// restoreNotebookSettings applies the settings persisted in the notebook metadata with `%settings save`, if any,
+// on the first execution of the kernel. Settings that fail to apply are reported and skipped.
+func restoreNotebookSettings(msg kernel.Message, goExec *goexec.State) {
+	settings := goExec.SettingsToRestore()
+	if settings == nil {
+		return
+	}
+	var restored []string
+	for _, key := range common.SortedKeys(settings.Config) {
+		value := settings.Config[key]
+		option, found := configOptions[key]
+		if !found {
+			klog.Warningf("Unknown %%config option %q in the notebook settings, ignored", key)
+			continue
+		}
+		if err := option.set(goExec, value); err != nil {
+			klog.Warningf("Failed to restore `%%config %s=%s`: %+v", key, value, err)
+			continue
+		}
+		goExec.RecordConfigSetting(key, value)
+		restored = append(restored, fmt.Sprintf("`%%config %s=%s`", key, value))
+	}
+	for _, key := range common.SortedKeys(settings.Env) {
+		if err := os.Setenv(key, settings.Env[key]); err != nil {
+			klog.Warningf("Failed to restore `%%env %s`: %+v", key, err)
+			continue
+		}
+		goExec.RecordEnvSetting(key, settings.Env[key])
+		restored = append(restored, fmt.Sprintf("`%%env %s=%s`", key, settings.Env[key]))
+	}
+	if len(settings.GoFlags) > 0 {
+		goExec.GoBuildFlags = settings.GoFlags
+		goExec.RecordGoFlagsSetting(settings.GoFlags)
+		restored = append(restored, fmt.Sprintf("`%%goflags %s`", strings.Join(settings.GoFlags, " ")))
+	}
+	if len(restored) == 0 {
+		return
+	}
+	goExec.UpdateGoplsSettings()
+	_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+		"* Restored the settings saved in the notebook metadata: %s.\n", strings.Join(restored, ", ")))
+}
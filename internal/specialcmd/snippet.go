@@ -0,0 +1,123 @@
+package specialcmd
+
+import (
+	"embed"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// This file implements `%snippet <name>`, which creates a new cell with a ready-made template of common
+// notebook boilerplate (e.g.: an HTTP server), from the library of snippets.
+
+// snippetsFS holds the snippets included in GoNB, one file `<name>.txt` per snippet. The first line of each
+// snippet is a comment describing it.
+//
+//go:embed snippets/*.txt
+var snippetsFS embed.FS
+
+// SnippetsDirEnv is the environment variable with the directory of the user's snippets, one file per
+// snippet named `<name>.go` or `<name>.txt`, which are added to (or replace) the snippets included.
+// It defaults to `gonb/snippets` in the user configuration directory (e.g.: `~/.config/gonb/snippets`).
+const SnippetsDirEnv = "GONB_SNIPPETS_DIR"
+
+// userSnippetsDir returns the directory of the user's snippets.
+func userSnippetsDir() string {
+	if dir := os.Getenv(SnippetsDirEnv); dir != "" {
+		return dir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(configDir, "gonb", "snippets")
+}
+
+// loadSnippets returns the contents of all snippets, by name: the ones included in GoNB and the user's.
+func loadSnippets() (map[string]string, error) {
+	snippets := make(map[string]string)
+	err := fs.WalkDir(snippetsFS, "snippets", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		contents, err := snippetsFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		snippets[strings.TrimSuffix(d.Name(), ".txt")] = string(contents)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the snippets included in GoNB")
+	}
+
+	dir := userSnippetsDir()
+	if dir == "" {
+		return snippets, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snippets, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read user snippets from %q", dir)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".go" && ext != ".txt") {
+			continue
+		}
+		contents, err := os.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read user snippet %q", entry.Name())
+		}
+		snippets[strings.TrimSuffix(entry.Name(), ext)] = string(contents)
+	}
+	return snippets, nil
+}
+
+// snippetDescription returns the description of the snippet: its first line, if it is a comment.
+func snippetDescription(contents string) string {
+	firstLine, _, _ := strings.Cut(contents, "\n")
+	if description, found := strings.CutPrefix(firstLine, "//"); found {
+		return strings.TrimSpace(description)
+	}
+	return ""
+}
+
+// execSnippet implements `%snippet [<name>]`: it creates a new cell with the snippet, or lists the snippets
+// available, if no name is given.
+func execSnippet(msg kernel.Message, args []string) error {
+	if len(args) > 1 {
+		return errors.New("%snippet usage: `%snippet [<name>]`")
+	}
+	snippets, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		parts := []string{"<h4>Snippets</h4>", "<ul>"}
+		for _, name := range common.SortedKeys(snippets) {
+			parts = append(parts, fmt.Sprintf("<li><code>%%snippet %s</code>: %s</li>",
+				html.EscapeString(name), html.EscapeString(snippetDescription(snippets[name]))))
+		}
+		parts = append(parts, "</ul>", fmt.Sprintf("<p>Add your own snippets in <code>%s</code>.</p>",
+			html.EscapeString(userSnippetsDir())))
+		return kernel.PublishHtml(msg, strings.Join(parts, "\n"))
+	}
+	contents, found := snippets[args[0]]
+	if !found {
+		return errors.Errorf("%%snippet: unknown snippet %q, valid snippets are: %s", args[0],
+			strings.Join(common.SortedKeys(snippets), ", "))
+	}
+	kernel.SetNextInput(msg, strings.TrimRight(contents, "\n"), false)
+	return nil
+}
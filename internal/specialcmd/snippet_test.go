@@ -0,0 +1,48 @@
+package specialcmd
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnippet(t *testing.T) {
+	userDir := t.TempDir()
+	t.Setenv(SnippetsDirEnv, userDir)
+	require.NoError(t, os.WriteFile(path.Join(userDir, "mine.go"), []byte("// My snippet.\n%%\nfmt.Println(1)\n"), 0600))
+	require.NoError(t, os.WriteFile(path.Join(userDir, "cobra.txt"), []byte("// My cobra.\n"), 0600))
+	require.NoError(t, os.WriteFile(path.Join(userDir, "README.md"), []byte("Not a snippet."), 0600))
+
+	snippets, err := loadSnippets()
+	require.NoError(t, err)
+	for _, name := range []string{"httpserver", "cobra", "testmain", "contextmain", "mine"} {
+		require.Contains(t, snippets, name)
+		assert.NotEmpty(t, snippetDescription(snippets[name]), "snippet %q has no description", name)
+	}
+	assert.NotContains(t, snippets, "README")
+	assert.Equal(t, "My cobra.", snippetDescription(snippets["cobra"]), "user snippets replace included ones")
+
+	k := kernel.NewHeadless()
+	msg, err := kernel.NewHeadlessMessage(k, "execute_request", nil)
+	require.NoError(t, err)
+	require.NoError(t, execSnippet(msg, []string{"httpserver"}))
+	payloads := k.TakePayloads()
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "set_next_input", payloads[0]["source"])
+	assert.Equal(t, false, payloads[0]["replace"])
+	assert.True(t, strings.HasPrefix(payloads[0]["text"].(string), "// HTTP server"))
+	assert.Contains(t, payloads[0]["text"], "%serve\n")
+	assert.Empty(t, k.TakePayloads())
+
+	// Listing the snippets.
+	require.NoError(t, execSnippet(msg, nil))
+	outputs := msg.Outputs()
+	require.Len(t, outputs, 1)
+	assert.Contains(t, outputs[0]["data"].(map[string]any)["text/html"], "%snippet mine")
+	assert.Error(t, execSnippet(msg, []string{"unknown"}))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/janpfeifer/gonb/internal/jpyexec"
 	"golang.org/x/exp/slices"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -45,6 +46,9 @@ type cellStatus struct {
 // If any errors happen, it is returned in err.
 func Parse(msg kernel.Message, goExec *goexec.State, execute bool, codeLines []string, usedLines Set[int]) (err error) {
 	status := &cellStatus{}
+	if execute {
+		restoreNotebookSettings(msg, goExec)
+	}
 	for lineNum := 0; lineNum < len(codeLines); lineNum++ {
 		if usedLines.Has(lineNum) {
 			continue
@@ -73,6 +77,70 @@ func Parse(msg kernel.Message, goExec *goexec.State, execute bool, codeLines []s
 						if err != nil {
 							return
 						}
+					} else if len(parts) > 0 && parts[0] == "%c" {
+						// C source cell: `%%c <file>`.
+						cmdBody := parseCmdBody(codeLines, lineNum, usedLines)
+						if len(parts) != 2 {
+							return errors.Errorf("%%%%c takes exactly one file name, e.g.: `%%%%c mylib.c`, got %q", parts[1:])
+						}
+						cellId := -1
+						if msg != nil {
+							cellId = msg.Kernel().ExecCounter
+						}
+						err = goExec.WriteCSource(msg, cellId, lineNum+1, parts[1], cmdBody)
+						if err != nil {
+							return
+						}
+					} else if len(parts) > 0 && parts[0] == "%prelude" {
+						// Prelude cell: `%%prelude`, followed by the code.
+						cmdBody := parseCmdBody(codeLines, lineNum, usedLines)
+						if len(parts) != 1 {
+							return errors.Errorf("%%%%prelude takes no arguments, the prelude code follows in the next lines, got %q", parts[1:])
+						}
+						if err = goExec.SetPrelude(cmdBody); err != nil {
+							return
+						}
+						err = goExec.DisplayPrelude(msg)
+						if err != nil {
+							return
+						}
+					} else if len(parts) > 0 && parts[0] == "%http" {
+						// HTTP request cell: `%%http`, followed by the request.
+						cmdBody := parseCmdBody(codeLines, lineNum, usedLines)
+						if len(parts) != 1 {
+							return errors.Errorf("%%%%http takes no arguments, the request follows in the next lines, got %q", parts[1:])
+						}
+						if err = goExec.HTTPRequest(msg, cmdBody); err != nil {
+							return
+						}
+					} else if len(parts) > 0 && parts[0] == "%gopkg" {
+						// Go package file cell: `%%gopkg <dir>/<file>.go`.
+						cmdBody := parseCmdBody(codeLines, lineNum, usedLines)
+						if len(parts) != 2 {
+							return errors.Errorf("%%%%gopkg takes exactly one file path, e.g.: `%%%%gopkg utils/strings.go`, got %q", parts[1:])
+						}
+						cellId := -1
+						if msg != nil {
+							cellId = msg.Kernel().ExecCounter
+						}
+						err = goExec.WriteGoPackageFile(msg, cellId, lineNum+1, parts[1], cmdBody)
+						if err != nil {
+							return
+						}
+					} else if len(parts) > 0 && parts[0] == "%protoc" {
+						// Protocol Buffers cell: `%%protoc <dir>/<file>.proto`.
+						cmdBody := parseCmdBody(codeLines, lineNum, usedLines)
+						if len(parts) != 2 {
+							return errors.Errorf("%%%%protoc takes exactly one file path, e.g.: `%%%%protoc pb/person.proto`, got %q", parts[1:])
+						}
+						cellId := -1
+						if msg != nil {
+							cellId = msg.Kernel().ExecCounter
+						}
+						err = goExec.CompileProto(msg, cellId, lineNum+1, parts[1], cmdBody)
+						if err != nil {
+							return
+						}
 					} else {
 						err = execInternal(msg, goExec, cmdStr, status)
 						if err != nil {
@@ -153,17 +221,32 @@ func execInternal(msg kernel.Message, goExec *goexec.State, cmdStr string, statu
 		klog.V(2).Infof("Program args to use (%%%s): %+q", parts[0], goExec.Args)
 		if parts[0] == "test" {
 			goExec.CellIsTest = true
+			// `-cover` is handled by GoNB, and not passed to the test binary.
+			goExec.Args = slices.DeleteFunc(goExec.Args, func(arg string) bool {
+				if arg == "-cover" || arg == "--cover" {
+					goExec.CellCoverage = true
+					return true
+				}
+				return false
+			})
 		}
 		// %% and %main are also handled specially by goexec, where it starts a main() clause.
+	case "run-cli":
+		// Executes the program as a command line tool, with the arguments.
+		goExec.Args = parts[1:]
+		goExec.CellRunCLI = true
 	case "wasm":
-		if len(parts) > 1 {
-			return errors.Errorf("`%%wasm` takes no extra parameters.")
+		if len(parts) > 2 || (len(parts) == 2 && parts[1] != "--iframe" && parts[1] != "-iframe") {
+			return errors.Errorf("`%%wasm` only takes the optional flag `--iframe`.")
 		}
 		goExec.CellIsWasm = true
-		var err error
-		err = goExec.MakeWasmSubdir()
-		if err != nil {
-			return errors.WithMessagef(err, "failed to prepare `%%wasm`")
+		goExec.CellWasmIframe = len(parts) == 2
+		if !goExec.CellWasmIframe {
+			// The iframe embeds the wasm, so it doesn't need Jupyter to serve it.
+			err := goExec.MakeWasmSubdir()
+			if err != nil {
+				return errors.WithMessagef(err, "failed to prepare `%%wasm`")
+			}
 		}
 		goExec.WasmDivId = UniqueId() // Unique ID for this cell.
 
@@ -199,8 +282,12 @@ func execInternal(msg kernel.Message, goExec *goexec.State, cmdStr string, statu
 		if err != nil {
 			return errors.Wrapf(err, "`%%env %q %q` failed", parts[1], parts[2])
 		}
+		note := ""
+		if !goExec.RecordEnvSetting(parts[1], parts[2]) {
+			note = " (not saved by `%settings save`, since the name looks like a secret)"
+		}
 		err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
-			fmt.Sprintf("Set: %s=%q\n", parts[1], parts[2]))
+			fmt.Sprintf("Set: %s=%q%s\n", parts[1], parts[2], note))
 		if err != nil {
 			klog.Errorf("Failed to output: %+v", err)
 		}
@@ -234,6 +321,7 @@ func execInternal(msg kernel.Message, goExec *goexec.State, cmdStr string, statu
 		if len(parts) > 1 {
 			nonEmptyArgs := slices.DeleteFunc(parts[1:], func(s string) bool { return s == "" })
 			goExec.GoBuildFlags = nonEmptyArgs
+			goExec.RecordGoFlagsSetting(nonEmptyArgs)
 		}
 
 		err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
@@ -242,9 +330,142 @@ func execInternal(msg kernel.Message, goExec *goexec.State, cmdStr string, statu
 			klog.Errorf("Failed publishing contents: %+v", err)
 		}
 
+		// Build tags, for composing and building:
+	case "tags":
+		if len(parts) > 1 {
+			var tags []string
+			for _, arg := range parts[1:] {
+				tags = append(tags, slices.DeleteFunc(strings.Split(arg, ","), func(s string) bool { return s == "" })...)
+			}
+			goExec.BuildTags = tags
+			goExec.UpdateGoplsSettings()
+		}
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("%%tags %s\n", strings.Join(goExec.BuildTags, ",")))
+
 		// Automatic `go get` control:
 	case "autoget":
-		goExec.AutoGet = true
+		return execAutoGet(msg, goExec, parts[1:])
+	case "deps":
+		if len(parts) > 1 && parts[1] == "why" {
+			return goExec.DepsWhy(msg, parts[2:])
+		}
+		if len(parts) != 1 {
+			return errors.Errorf("%%deps usage: `%%deps` or `%%deps why <packages...>`, got %q", parts[1:])
+		}
+		return goExec.Deps(msg)
+	case "go-version":
+		if len(parts) == 1 {
+			version := goExec.ActiveGoVersion()
+			if version == "" {
+				version = "default"
+			}
+			return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("%%go-version %s\n", version))
+		}
+		if len(parts) != 2 {
+			return errors.Errorf("%%go-version takes one version (e.g.: `1.22.3` or `default`), got %q", parts[1:])
+		}
+		return goExec.SetGoVersion(msg, parts[1])
+	case "serve":
+		for _, arg := range parts[1:] {
+			if arg != "--grpc" && arg != "-grpc" {
+				return errors.Errorf("%%serve usage: `%%serve` or `%%serve --grpc`, got %q", arg)
+			}
+			goExec.CellServeGRPC = true
+		}
+		goExec.CellServe = true
+	case "stop":
+		return goExec.StopServing(msg)
+	case "nbimport":
+		if len(parts) != 4 || parts[2] != "as" {
+			return errors.Errorf("%%nbimport usage: `%%nbimport <notebook.ipynb> as <name>`, got %q", parts[1:])
+		}
+		return goExec.NotebookImport(msg, parts[1], parts[3])
+	case "record":
+		return execRecord(msg, goExec, parts[1:])
+	case "log":
+		return execLog(msg, parts[1:])
+	case "snippet":
+		return execSnippet(msg, parts[1:])
+	case "search":
+		if len(parts) < 2 {
+			return errors.Errorf("%%search usage: `%%search <regexp>`")
+		}
+		return goExec.Search(msg, strings.Join(parts[1:], " "))
+	case "gpu":
+		if len(parts) != 2 || parts[1] != "info" {
+			return errors.Errorf("%%gpu usage: `%%gpu info`, got %q", parts[1:])
+		}
+		return goExec.GPUInfo(msg)
+	case "journal":
+		switch {
+		case len(parts) == 1:
+			return goExec.JournalStatus(msg)
+		case len(parts) == 2 && parts[1] == "restore":
+			return goExec.RestoreJournal(msg)
+		case len(parts) == 2 && parts[1] == "discard":
+			return goExec.DiscardCrashedJournal(msg)
+		}
+		return errors.Errorf("%%journal usage: `%%journal`, `%%journal restore` or `%%journal discard`")
+	case "share":
+		if len(parts) > 1 {
+			return errors.Errorf("%%share takes no arguments, configure the playground with `%%config playground_url=...`")
+		}
+		goExec.CellShare = true
+	case "grpc":
+		return execGRPC(msg, goExec, cmdStr)
+	case "tinygo":
+		for _, arg := range parts[1:] {
+			if arg == "off" {
+				goExec.TinyGoTarget = ""
+			} else if target, found := strings.CutPrefix(arg, "target="); found && target != "" {
+				goExec.TinyGoTarget = target
+			} else {
+				return errors.Errorf("%%tinygo usage: `%%tinygo target=<target>` or `%%tinygo off`, got %q", arg)
+			}
+		}
+		target := goExec.TinyGoTarget
+		if target == "" {
+			target = "off"
+		}
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("%%tinygo target=%s\n", target))
+	case "flash":
+		var port string
+		for _, arg := range parts[1:] {
+			var found bool
+			if port, found = strings.CutPrefix(arg, "port="); !found {
+				return errors.Errorf("%%flash usage: `%%flash [port=<serial port>]`, got %q", arg)
+			}
+		}
+		return goExec.Flash(msg, port)
+	case "config":
+		return execConfig(msg, goExec, parts[1:])
+	case "vendor":
+		if len(parts) != 1 {
+			return errors.Errorf("%%vendor takes no arguments, got %q", parts[1:])
+		}
+		return goExec.Vendor(msg)
+	case "workspace":
+		if len(parts) > 2 {
+			return errors.Errorf("%%workspace takes at most one argument, a directory, `on` or `off`, got %q", parts[1:])
+		}
+		if len(parts) == 2 {
+			switch parts[1] {
+			case "on":
+				goExec.AutoWorkspaceEnabled = true
+			case "off":
+				goExec.AutoWorkspaceEnabled = false
+			default:
+				goExec.AutoWorkspaceEnabled = true
+				goExec.WorkspaceDir = parts[1]
+			}
+		}
+		workspaceDir := goExec.WorkspaceDir
+		if workspaceDir == "" {
+			workspaceDir = "<current directory>"
+		}
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("%%workspace enabled=%v, directory=%s\n", goExec.AutoWorkspaceEnabled, workspaceDir))
 	case "noautoget":
 		goExec.AutoGet = false
 	case "help":
@@ -264,10 +485,153 @@ func execInternal(msg kernel.Message, goExec *goexec.State, cmdStr string, statu
 			}
 		}
 		return goExec.GoModInit()
+	case "go":
+		return goExec.GoCommand(msg, parts[1:])
+	case "replace":
+		args := parts[1:]
+		if len(args) == 3 && args[1] == "=>" {
+			args = []string{args[0], args[2]}
+		}
+		if len(args) != 2 {
+			return errors.Errorf("%%replace usage: `%%replace <module> => <local_path or module@version>`, got %q", parts[1:])
+		}
+		return goExec.Replace(msg, args[0], args[1])
 	case "ls", "list":
 		listDefinitions(msg, goExec)
+	case "variables":
+		return execVariables(msg, goExec, parts[1:])
+	case "stats":
+		return goExec.Stats(msg)
+	case "secret":
+		return execSecret(msg, goExec, parts[1:])
+	case "prelude":
+		return execPrelude(msg, goExec, parts[1:])
+	case "tap":
+		return execTap(msg, goExec, parts[1:])
+	case "upgrade":
+		checkOnly, force := false, false
+		for _, arg := range parts[1:] {
+			switch arg {
+			case "--check":
+				checkOnly = true
+			case "--force":
+				force = true
+			default:
+				return errors.Errorf("%%upgrade usage: `%%upgrade [--check] [--force]`, got %q", parts[1:])
+			}
+		}
+		return goExec.Upgrade(msg, checkOnly, force)
+	case "compose":
+		if len(parts) != 2 {
+			return errors.Errorf("%%compose usage: `%%compose show|on|off`, got %q", parts[1:])
+		}
+		switch parts[1] {
+		case "show":
+			return goExec.ShowComposed(msg)
+		case "on", "off":
+			goExec.EchoComposed = parts[1] == "on"
+			return nil
+		}
+		return errors.Errorf("%%compose usage: `%%compose show|on|off`, got %q", parts[1:])
+	case "settings":
+		switch {
+		case len(parts) == 1:
+			return goExec.ShowSettings(msg)
+		case len(parts) == 2 && (parts[1] == "save" || parts[1] == "clear"):
+			return goExec.SaveSettings(msg, parts[1] == "clear")
+		}
+		return errors.Errorf("%%settings usage: `%%settings`, `%%settings save` or `%%settings clear`, got %q", parts[1:])
+	case "bugreport":
+		if len(parts) > 2 || (len(parts) == 2 && parts[1] != "--redact") {
+			return errors.Errorf("%%bugreport usage: `%%bugreport [--redact]`, got %q", parts[1:])
+		}
+		return goExec.BugReport(msg, sessionLogContents(), len(parts) == 2)
+	case "explain":
+		// The expression is taken verbatim, not split or unquoted like the arguments of other commands.
+		expr := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(cmdStr), "explain"))
+		if expr == "" {
+			return errors.New("%explain requires a Go expression, e.g.: `%explain strings.NewReader(\"\")`")
+		}
+		return goExec.Explain(msg, expr)
+	case "implements", "satisfies":
+		if len(parts) != 2 {
+			return errors.Errorf("%%%s takes exactly one type, e.g.: `%%implements io.Reader` or `%%satisfies MyType`, got %q",
+				parts[0], parts[1:])
+		}
+		if parts[0] == "implements" {
+			return goExec.Implements(msg, parts[1])
+		}
+		return goExec.Satisfies(msg, parts[1])
+	case "why":
+		if len(parts) != 1 {
+			return errors.Errorf("%%why takes no arguments, got %q", parts[1:])
+		}
+		return goExec.Why(msg)
+	case "params":
+		if len(parts) == 1 {
+			return goExec.ListParams(msg)
+		}
+		for _, setting := range parts[1:] {
+			name, value, found := strings.Cut(setting, "=")
+			if !found {
+				return errors.Errorf("%%params usage: `%%params [<name>=<value>...]`, got %q", setting)
+			}
+			if err := goExec.SetParam(name, value); err != nil {
+				return err
+			}
+		}
 	case "rm", "remove":
 		removeDefinitions(msg, goExec, parts[1:])
+	case "fix":
+		return execFix(msg, goExec, parts[1:])
+	case "rename":
+		if len(parts) != 3 {
+			return errors.Errorf("%%rename takes two arguments, the old and the new names, got %q", parts[1:])
+		}
+		return goExec.Rename(msg, parts[1], parts[2])
+	case "refs":
+		if len(parts) != 2 {
+			return errors.Errorf("%%refs takes one argument, the symbol name, got %q", parts[1:])
+		}
+		return goExec.References(msg, parts[1])
+	case "callers":
+		if len(parts) != 2 {
+			return errors.Errorf("%%callers takes one argument, the function name, got %q", parts[1:])
+		}
+		return goExec.Callers(msg, parts[1])
+	case "doc":
+		if len(parts) == 2 && parts[1] == "-all" {
+			return goExec.Doc(msg, nil)
+		}
+		if len(parts) < 2 || slices.Contains(parts[1:], "-all") {
+			return errors.Errorf("%%doc takes either `-all` or the names of the declarations to document, got %q", parts[1:])
+		}
+		return goExec.Doc(msg, parts[1:])
+	case "generate":
+		if len(parts) != 1 {
+			return errors.Errorf("%%generate takes no arguments, got %q", parts[1:])
+		}
+		return goExec.Generate(msg)
+	case "fuzz":
+		if len(parts) < 2 {
+			return errors.Errorf("%%fuzz requires the name of the fuzz target, e.g.: `%%fuzz FuzzParse -fuzztime=1m`")
+		}
+		return goExec.Fuzz(msg, parts[1], parts[2:])
+	case "asm":
+		if len(parts) != 2 {
+			return errors.Errorf("%%asm takes one argument, the function name, got %q", parts[1:])
+		}
+		return goExec.Assembly(msg, parts[1])
+	case "ssa":
+		if len(parts) != 2 {
+			return errors.Errorf("%%ssa takes one argument, the function name, got %q", parts[1:])
+		}
+		return goExec.SSA(msg, parts[1])
+	case "gcflags-report":
+		if len(parts) != 2 {
+			return errors.Errorf("%%gcflags-report takes one argument, the function name, got %q", parts[1:])
+		}
+		return goExec.GCFlagsReport(msg, parts[1])
 
 		// Input handling.
 	case "with_inputs":
@@ -292,6 +656,8 @@ func execInternal(msg kernel.Message, goExec *goexec.State, cmdStr string, statu
 		// Others.
 	case "goworkfix":
 		return goExec.GoWorkFix(msg)
+	case "postmortem":
+		return goExec.PostMortem(msg, parts[1:])
 
 	default:
 		err := kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("\"%%%s\" unknown or not implemented yet.", parts[0]))
@@ -335,33 +701,122 @@ func execWriteFile(msg kernel.Message, goExec *goexec.State, args []string, cmdB
 	return kernel.PublishWriteStream(msg, kernel.StreamStdout, "write to "+filename+" success\n")
 }
 
-// execInternal executes internal configuration commands, see HelpMessage for details.
+// execShell executes the `!` shell command with the configured shell (see goexec.State.ShellCommand), after
+// replacing the `{name}` placeholders with the values of the corresponding Go variables (see
+// goexec.State.InterpolateShellVars). The command inherits the environment of the kernel, plus the one
+// given by goexec.State.ShellEnv.
 //
-// It only returns errors for system errors that will lead to the kernel restart. Syntax errors
-// on the command themselves are simply reported back to jupyter and are not returned here.
+// It returns an error if the command exits with a non-zero status, so the execution of the cell stops.
 func execShell(msg kernel.Message, goExec *goexec.State, cmdStr string, status *cellStatus) error {
 	var execDir string // Default "", means current directory.
 	if cmdStr[0] == '*' {
 		cmdStr = cmdStr[1:]
 		execDir = goExec.TempDir
 	}
+	shell, args := goExec.ShellCommand(goExec.InterpolateShellVars(cmdStr))
+	executor := jpyexec.New(msg, shell, args...).
+		ExecutionCount(msg.Kernel().ExecCounter).
+		InDir(execDir).
+		WithEnv(goExec.ShellEnv()).
+		WithBinaryOutput(goExec.BinaryOutputsPath())
 	if status.withInputs {
-		status.withInputs = false
-		status.withPassword = false
-		return jpyexec.New(msg, "/bin/bash", "-c", cmdStr).
-			ExecutionCount(msg.Kernel().ExecCounter).
-			InDir(execDir).WithInputs(MillisecondsWaitForInput).Exec()
+		executor.WithInputs(MillisecondsWaitForInput)
 	} else if status.withPassword {
-		status.withInputs = false
-		status.withPassword = false
-		return jpyexec.New(msg, "/bin/bash", "-c", cmdStr).
-			ExecutionCount(msg.Kernel().ExecCounter).
-			InDir(execDir).WithPassword(MillisecondsWaitForInput).Exec()
-	} else {
-		return jpyexec.New(msg, "/bin/bash", "-c", cmdStr).
-			ExecutionCount(msg.Kernel().ExecCounter).
-			InDir(execDir).Exec()
+		executor.WithPassword(MillisecondsWaitForInput)
+	}
+	status.withInputs = false
+	status.withPassword = false
+	if err := executor.Exec(); err != nil {
+		return err
+	}
+	if state := executor.ProcessState(); state != nil && state.ExitCode() != 0 && !msg.Kernel().Interrupted.Load() {
+		return errors.Errorf("shell command exited with status %d", state.ExitCode())
+	}
+	return nil
+}
+
+// execAutoGet enables the automatic `go get` of missing packages, and configures which packages are allowed
+// or denied. It implements the "%autoget" command.
+func execAutoGet(msg kernel.Message, goExec *goexec.State, args []string) error {
+	goExec.AutoGet = true
+	if len(args) == 0 {
+		return nil
+	}
+	switch args[0] {
+	case "allow":
+		goExec.AutoGetAllow = args[1:]
+	case "deny":
+		goExec.AutoGetDeny = args[1:]
+	default:
+		return errors.Errorf("%%autoget usage: `%%autoget [allow|deny <package patterns...>]`, got %q", args)
+	}
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("%%autoget allow=%q deny=%q\n", goExec.AutoGetAllow, goExec.AutoGetDeny))
+}
+
+// execSecret implements `%secret get <NAME> [--from=env|keychain|command]`.
+func execSecret(msg kernel.Message, goExec *goexec.State, args []string) error {
+	var source string
+	if len(args) == 3 && strings.HasPrefix(args[2], "--from=") {
+		source = strings.TrimPrefix(args[2], "--from=")
+		args = args[:2]
+	}
+	if len(args) != 2 || args[0] != "get" {
+		return errors.Errorf("%%secret usage: `%%secret get <NAME> [--from=env|keychain|command]`, got %q", args)
+	}
+	return goExec.GetSecret(msg, args[1], source)
+}
+
+// tapUsage is the usage of `%tap`, included in its errors.
+const tapUsage = "`%%tap kafka <brokers> <topic> [--seconds=<n>]` or `%%tap nats <subject> [--server=<url>] [--seconds=<n>]`"
+
+// execTap implements `%tap kafka <brokers> <topic> [--seconds=<n>]` and
+// `%tap nats <subject> [--server=<url>] [--seconds=<n>]`.
+func execTap(msg kernel.Message, goExec *goexec.State, args []string) error {
+	config := &goexec.TapConfig{Duration: goexec.DefaultTapDuration}
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--seconds="):
+			seconds, err := strconv.ParseFloat(strings.TrimPrefix(arg, "--seconds="), 64)
+			if err != nil || seconds <= 0 {
+				return errors.Errorf("%%tap: invalid number of seconds in %q", arg)
+			}
+			config.Duration = time.Duration(seconds * float64(time.Second))
+		case strings.HasPrefix(arg, "--server="):
+			config.Server = strings.TrimPrefix(arg, "--server=")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	switch {
+	case len(positional) == 3 && positional[0] == "kafka":
+		config.Kind, config.Brokers, config.Topic = positional[0], positional[1], positional[2]
+	case len(positional) == 2 && positional[0] == "nats":
+		config.Kind, config.Topic = positional[0], positional[1]
+	default:
+		return errors.Errorf("%%tap usage: "+tapUsage+", got %q", args)
+	}
+	if config.Server != "" && config.Kind != "nats" {
+		return errors.Errorf("%%tap: `--server` is only used with `nats`, use the brokers argument for `kafka`")
+	}
+	return goExec.Tap(msg, config)
+}
+
+// execPrelude implements `%prelude [--file=<path>|reset]`. The prelude code is set with `%%prelude`.
+func execPrelude(msg kernel.Message, goExec *goexec.State, args []string) error {
+	switch {
+	case len(args) == 0:
+		return goExec.DisplayPrelude(msg)
+	case len(args) == 1 && args[0] == "reset":
+		return goExec.SetPrelude("")
+	case len(args) == 1 && strings.HasPrefix(args[0], "--file="):
+		if err := goExec.SetPreludeFromFile(strings.TrimPrefix(args[0], "--file=")); err != nil {
+			return err
+		}
+		return goExec.DisplayPrelude(msg)
 	}
+	return errors.Errorf("%%prelude usage: `%%prelude [--file=<path>|reset]`, or `%%%%prelude` followed by the code, got %q", args)
 }
 
 // splitCmd split the special command into it's parts separated by space(s). It also
@@ -9,6 +9,7 @@ import (
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path"
 	"strings"
 	"testing"
 
@@ -119,3 +120,97 @@ fmt.Println("2")
 		})
 	}
 }
+
+func TestConfig(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	config := func(line string) error {
+		var msg kernel.Message
+		return Parse(msg, s, true, []string{line}, MakeSet[int]())
+	}
+	require.NoError(t, config("%config offline=on"))
+	assert.True(t, s.Offline)
+	require.NoError(t, config("%config offline=false"))
+	assert.False(t, s.Offline)
+	assert.Error(t, config("%config offline=maybe"))
+	assert.Error(t, config("%config unknown=1"))
+	assert.Error(t, config("%config offline"))
+}
+
+func TestSplitGRPCCall(t *testing.T) {
+	fullMethod, request, err := splitGRPCCall(`grpc call helloworld.Greeter/SayHello {"name": "gonb  notebook"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld.Greeter/SayHello", fullMethod)
+	assert.Equal(t, `{"name": "gonb  notebook"}`, request)
+
+	fullMethod, request, err = splitGRPCCall("grpc call helloworld.Greeter/SayHello")
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld.Greeter/SayHello", fullMethod)
+	assert.Equal(t, "", request)
+
+	_, _, err = splitGRPCCall("grpc list")
+	require.Error(t, err)
+}
+
+func TestRestoreNotebookSettings(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	notebookPath := path.Join(t.TempDir(), "settings.ipynb")
+	require.NoError(t, os.WriteFile(notebookPath, []byte(`{"cells": [], "metadata": {"gonb": {
+		"config": {"offline": "on", "unknown": "1"}, "env": {"GONB_TEST_SETTING": "x"}, "goflags": ["-race"]}}}`), 0600))
+	t.Setenv(goexec.JupyterSessionNameEnv, notebookPath)
+	t.Setenv("GONB_TEST_SETTING", "")
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+
+	// Restored on the first execution only.
+	require.NoError(t, Parse(msg, s, true, []string{""}, MakeSet[int]()))
+	assert.True(t, s.Offline)
+	assert.Equal(t, "x", os.Getenv("GONB_TEST_SETTING"))
+	assert.Equal(t, []string{"-race"}, s.GoBuildFlags)
+	require.Len(t, msg.Outputs(), 1)
+	assert.Equal(t, "* Restored the settings saved in the notebook metadata: `%config offline=on`, "+
+		"`%env GONB_TEST_SETTING=x`, `%goflags -race`.\n", fmt.Sprint(msg.Outputs()[0]["text"]))
+	s.Offline = false
+	require.NoError(t, Parse(msg, s, true, []string{""}, MakeSet[int]()))
+	assert.False(t, s.Offline)
+
+	// Restored and new settings are recorded, to be saved with `%settings save`.
+	require.NoError(t, Parse(msg, s, true, []string{"%config stale_hints=off"}, MakeSet[int]()))
+	settings := s.NotebookSettings()
+	assert.Equal(t, map[string]string{"offline": "on", "stale_hints": "off"}, settings.Config)
+	assert.Equal(t, map[string]string{"GONB_TEST_SETTING": "x"}, settings.Env)
+}
+
+func TestVariablesInspector(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	s.Definitions.Variables["a"] = &goexec.Variable{Key: "a", Name: "a", ValueDefinition: "1"}
+	s.Definitions.Variables["b"] = &goexec.Variable{Key: "b", Name: "b", ValueDefinition: `"b"`}
+
+	// lastResult runs the command as the extension does, and returns the "text/plain" of its "execute_result".
+	lastResult := func(cmd string) string {
+		msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+		require.NoError(t, err)
+		require.NoError(t, Parse(msg, s, true, []string{cmd}, MakeSet[int]()))
+		outputs := msg.Outputs()
+		require.NotEmpty(t, outputs)
+		result := outputs[len(outputs)-1]
+		require.Equal(t, "execute_result", result["output_type"])
+		data := result["data"].(map[string]any)
+		return data[string(protocol.MIMETextPlain)].(string)
+	}
+	assert.Contains(t, lastResult(goexec.InspectorQueryCommand), `"varName":"a"`)
+
+	// The extension deletes with `<deleteCommand>('<name>')`, and expects the updated list of variables.
+	text := lastResult(goexec.InspectorDeleteCommand + "('a')")
+	assert.NotContains(t, text, `"varName":"a"`)
+	assert.Contains(t, text, `"varName":"b"`)
+	assert.NotContains(t, s.Definitions.Variables, "a")
+
+	msg, err := kernel.NewHeadlessMessage(kernel.NewHeadless(), "execute_request", nil)
+	require.NoError(t, err)
+	assert.Error(t, Parse(msg, s, true, []string{goexec.InspectorMatrixQueryCommand + "(b, 100)"}, MakeSet[int]()))
+}
@@ -0,0 +1,296 @@
+// Package tracing implements OpenTelemetry tracing of the kernel internals (parsing, composing, building,
+// running cells and querying `gopls`), to diagnose why cells are slow.
+//
+// Spans are exported with the OTLP/HTTP protocol (JSON encoding) only if an endpoint is configured with
+// the standard environment variables `OTEL_EXPORTER_OTLP_TRACES_ENDPOINT` or `OTEL_EXPORTER_OTLP_ENDPOINT`
+// (e.g.: `http://localhost:4318`). `OTEL_EXPORTER_OTLP_HEADERS` and `OTEL_SERVICE_NAME` are also
+// supported. Otherwise, tracing is disabled, and spans are nil, whose methods are no-ops.
+//
+// The spans of a trace are exported when its root span ends.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ScopeName is the instrumentation scope of the spans.
+	ScopeName = "github.com/janpfeifer/gonb"
+
+	// DefaultServiceName is the service name of the spans, if `OTEL_SERVICE_NAME` is not set.
+	DefaultServiceName = "gonb"
+
+	// ExportTimeout is the maximum time to export the spans of a trace.
+	ExportTimeout = 10 * time.Second
+)
+
+// exporter sends the spans to the configured OTLP endpoint. It is nil if tracing is disabled.
+type exporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	inFlight    sync.WaitGroup
+}
+
+var (
+	muExporter     sync.Mutex
+	globalExporter *exporter
+)
+
+func init() {
+	globalExporter = exporterFromEnv()
+}
+
+// exporterFromEnv creates the exporter configured by the standard OpenTelemetry environment variables,
+// or returns nil if no endpoint is configured.
+func exporterFromEnv() *exporter {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+		klog.Warningf("OpenTelemetry: OTEL_EXPORTER_OTLP_PROTOCOL=%q is not supported, exporting with "+
+			"\"http/json\" to %q", protocol, endpoint)
+	}
+	e := &exporter{
+		endpoint:    endpoint,
+		headers:     parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		serviceName: os.Getenv("OTEL_SERVICE_NAME"),
+		client:      &http.Client{Timeout: ExportTimeout},
+	}
+	if e.serviceName == "" {
+		e.serviceName = DefaultServiceName
+	}
+	klog.V(1).Infof("OpenTelemetry: exporting traces to %q", endpoint)
+	return e
+}
+
+// parseHeaders parses the `OTEL_EXPORTER_OTLP_HEADERS` format: comma-separated `key=value` pairs, with
+// URL-encoded values.
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(val)); err == nil {
+			val = unescaped
+		}
+		headers[key] = val
+	}
+	return headers
+}
+
+// Enabled returns whether tracing is configured.
+func Enabled() bool {
+	muExporter.Lock()
+	defer muExporter.Unlock()
+	return globalExporter != nil
+}
+
+// Shutdown waits for the traces being exported to finish, up to ExportTimeout.
+func Shutdown() {
+	muExporter.Lock()
+	e := globalExporter
+	muExporter.Unlock()
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		e.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ExportTimeout):
+		klog.Warningf("OpenTelemetry: timed out waiting for traces to be exported")
+	}
+}
+
+// trace holds the finished spans of a trace, until its root span ends.
+type trace struct {
+	exporter *exporter
+	mu       sync.Mutex
+	spans    []*Span
+}
+
+// Span measures an operation of the kernel. A nil Span (returned when tracing is disabled) is valid,
+// and its methods are no-ops.
+type Span struct {
+	trace      *trace
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	isRoot     bool
+	name       string
+	start, end time.Time
+	attributes map[string]any
+	err        error
+}
+
+// Start a new trace, with the root span with the given name. It returns nil if tracing is disabled.
+func Start(name string) *Span {
+	muExporter.Lock()
+	e := globalExporter
+	muExporter.Unlock()
+	if e == nil {
+		return nil
+	}
+	span := &Span{trace: &trace{exporter: e}, isRoot: true, name: name, start: time.Now()}
+	_, _ = rand.Read(span.traceID[:])
+	_, _ = rand.Read(span.spanID[:])
+	return span
+}
+
+// Child starts a span with the given name, child of the parent span. It returns nil if parent is nil.
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	span := &Span{trace: s.trace, traceID: s.traceID, parentID: s.spanID, name: name, start: time.Now()}
+	_, _ = rand.Read(span.spanID[:])
+	return span
+}
+
+// SetAttribute sets an attribute of the span. Values can be strings, booleans, integers or floats,
+// anything else is converted to a string.
+func (s *Span) SetAttribute(key string, value any) *Span {
+	if s == nil {
+		return nil
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+	return s
+}
+
+// End the span, with the error of the operation, if any. When the root span ends, the spans of the trace
+// are exported in the background.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	t := s.trace
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	spans := t.spans
+	t.mu.Unlock()
+	if !s.isRoot {
+		return
+	}
+	t.exporter.inFlight.Add(1)
+	go func() {
+		defer t.exporter.inFlight.Done()
+		if err := t.exporter.export(spans); err != nil {
+			klog.Warningf("OpenTelemetry: failed to export trace %q: %v", s.name, err)
+		}
+	}()
+}
+
+// otlpAttributes converts the attributes to the OTLP JSON encoding.
+func otlpAttributes(attributes map[string]any) []map[string]any {
+	encoded := make([]map[string]any, 0, len(attributes))
+	for key, value := range attributes {
+		var v map[string]any
+		switch typed := value.(type) {
+		case string:
+			v = map[string]any{"stringValue": typed}
+		case bool:
+			v = map[string]any{"boolValue": typed}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(typed)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(typed, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": typed}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(typed)}
+		}
+		encoded = append(encoded, map[string]any{"key": key, "value": v})
+	}
+	return encoded
+}
+
+// otlpRequest returns the OTLP JSON request exporting the spans.
+func (e *exporter) otlpRequest(spans []*Span) map[string]any {
+	otlpSpans := make([]map[string]any, 0, len(spans))
+	for _, span := range spans {
+		otlpSpan := map[string]any{
+			"traceId":           hex.EncodeToString(span.traceID[:]),
+			"spanId":            hex.EncodeToString(span.spanID[:]),
+			"name":              span.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.attributes),
+		}
+		if !span.isRoot {
+			otlpSpan["parentSpanId"] = hex.EncodeToString(span.parentID[:])
+		}
+		if span.err != nil {
+			otlpSpan["status"] = map[string]any{"code": 2, "message": span.err.Error()} // STATUS_CODE_ERROR
+		}
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.serviceName}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": ScopeName},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
+
+// export sends the spans to the OTLP endpoint.
+func (e *exporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.otlpRequest(spans))
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode spans")
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request to %q", e.endpoint)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post spans to %q", e.endpoint)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("OTLP endpoint %q replied %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
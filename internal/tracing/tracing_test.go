@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeaders(t *testing.T) {
+	assert.Equal(t, map[string]string{"api-key": "secret", "X-Team": "a b"},
+		parseHeaders(" api-key = secret,X-Team=a%20b,invalid,=empty"))
+	assert.Empty(t, parseHeaders(""))
+}
+
+func TestDisabled(t *testing.T) {
+	muExporter.Lock()
+	saved := globalExporter
+	globalExporter = nil
+	muExporter.Unlock()
+	defer func() { globalExporter = saved }()
+
+	assert.False(t, Enabled())
+	span := Start("disabled")
+	assert.Nil(t, span)
+	// Methods of nil spans are no-ops.
+	child := span.Child("child").SetAttribute("key", 1)
+	assert.Nil(t, child)
+	child.End(nil)
+	span.End(nil)
+	Shutdown()
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("api-key"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var request map[string]any
+		assert.NoError(t, json.Unmarshal(body, &request))
+		requests <- request
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	muExporter.Lock()
+	saved := globalExporter
+	globalExporter = exporterFromEnv()
+	muExporter.Unlock()
+	defer func() { globalExporter = saved }()
+	require.True(t, Enabled())
+
+	root := Start("gonb.execute_cell").SetAttribute("gonb.cell_id", 3)
+	root.Child("gonb.build").SetAttribute("gonb.test", false).End(errors.New("build failed"))
+	root.End(nil)
+	Shutdown()
+
+	request := <-requests
+	resourceSpans := request["resourceSpans"].([]any)[0].(map[string]any)
+	serviceAttr := resourceSpans["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	assert.Equal(t, "service.name", serviceAttr["key"])
+	assert.Equal(t, DefaultServiceName, serviceAttr["value"].(map[string]any)["stringValue"])
+
+	spans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	require.Len(t, spans, 2)
+	build, exec := spans[0].(map[string]any), spans[1].(map[string]any)
+	assert.Equal(t, "gonb.build", build["name"])
+	assert.Equal(t, "gonb.execute_cell", exec["name"])
+	assert.Len(t, exec["traceId"], 32)
+	assert.Len(t, exec["spanId"], 16)
+	assert.Equal(t, exec["traceId"], build["traceId"])
+	assert.Equal(t, exec["spanId"], build["parentSpanId"])
+	assert.NotContains(t, exec, "parentSpanId")
+	assert.NotContains(t, exec, "status")
+	assert.Equal(t, map[string]any{"code": float64(2), "message": "build failed"}, build["status"])
+	assert.Equal(t, []any{map[string]any{"key": "gonb.cell_id", "value": map[string]any{"intValue": "3"}}},
+		exec["attributes"])
+}
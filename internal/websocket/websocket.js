@@ -237,6 +237,11 @@
             this.send("#heartbeat/pong", true);
             debug_log(`gonb_comm: replied #heartbeat/ping with /pong`);
             return;
+        } else if (address === "#gonb/notebook_settings") {
+            // Settings to store in the notebook metadata (`%settings save`).
+            let err = this._store_notebook_settings(data?.value);
+            this.send("#gonb/notebook_settings/ack", {saved: !err, error: err || ""});
+            return;
         }
 
         let subscribers = this._address_subscriptions[address];
@@ -301,6 +306,70 @@
         };
     }
 
+    /**
+     * _store_notebook_settings stores the settings under the "gonb" key of the metadata of the notebook
+     * connected to the kernel (or removes it, if the settings are empty), so they are saved with the notebook.
+     *
+     * It works in the classic Jupyter Notebook, and in JupyterLab (and Notebook 7) if the application is
+     * exposed in `globalThis.jupyterapp`.
+     *
+     * @param settings Object with the settings.
+     * @returns An error message, or null if stored.
+     * @private
+     */
+    gonb_comm._store_notebook_settings = function(settings) {
+        const key = "gonb";
+        const clear = !settings || Object.keys(settings).length === 0;
+
+        // Classic Jupyter Notebook.
+        let notebook = globalThis?.Jupyter?.notebook;
+        if (notebook?.metadata) {
+            if (clear) {
+                delete notebook.metadata[key];
+            } else {
+                notebook.metadata[key] = settings;
+            }
+            notebook.set_dirty(true);
+            return null;
+        }
+
+        // JupyterLab and Notebook 7: find the notebook connected to this kernel.
+        let app = globalThis?.jupyterapp;
+        if (!app?.shell) {
+            return "notebook model not accessible from the browser (in JupyterLab, start it with " +
+                "`--LabApp.expose_app_in_browser`)";
+        }
+        for (const widget of app.shell.widgets("main")) {
+            if (widget?.sessionContext?.session?.kernel?.id !== this._kernel_id) {
+                continue;
+            }
+            let model = widget?.content?.model ?? widget?.model;
+            if (!model) {
+                continue;
+            }
+            if (model.setMetadata) {
+                // JupyterLab 4.
+                if (clear) {
+                    model.deleteMetadata(key);
+                } else {
+                    model.setMetadata(key, settings);
+                }
+            } else if (model.metadata?.set) {
+                // JupyterLab 3.
+                if (clear) {
+                    model.metadata.delete(key);
+                } else {
+                    model.metadata.set(key, settings);
+                }
+            } else {
+                continue;
+            }
+            debug_log(`gonb_comm: stored notebook settings ${JSON.stringify(settings)}`);
+            return null;
+        }
+        return `no notebook connected to kernel ${this._kernel_id} found`;
+    }
+
     gonb_comm._connect_to_gonb = async function() {
         debug_log(`gonb_comm._connect_to_gonb(${this._kernel_id})`);
 
@@ -7,9 +7,10 @@ import (
 	"github.com/janpfeifer/gonb/internal/dispatcher"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/logging"
+	"github.com/janpfeifer/gonb/internal/tracing"
 	"io"
 	klog "k8s.io/klog/v2"
-	"log"
 	"os"
 	"os/exec"
 	"time"
@@ -19,22 +20,28 @@ var (
 	flagInstall   = flag.Bool("install", false, "Install kernel in local config, and make it available in Jupyter")
 	flagKernel    = flag.String("kernel", "", "ProgramExecutor kernel using given path for the `connection_file` provided by Jupyter client")
 	flagExtraLog  = flag.String("extra_log", "", "Extra file to include in the log.")
+	flagLogDir    = flag.String("session_log_dir", "", "Directory of the log files of each kernel session (see `%log`). Defaults to `gonb_logs` under the system temporary directory.")
 	flagForceDeps = flag.Bool("force_deps", false, "Force install even if goimports and/or gopls are missing.")
 	flagForceCopy = flag.Bool("force_copy", false, "Copy binary to the Jupyter kernel configuration location. This already happens by default is the binary is under `/tmp`.")
 	flagRawError  = flag.Bool("raw_error", false, "When GoNB executes cells, force raw text errors instead of HTML errors, which facilitates command line testing of notebooks.")
 	flagWork      = flag.Bool("work", false, "Print name of temporary work directory and preserve it at exit. ")
 	flagCommsLog  = flag.Bool("comms_log", false, "Enable verbose logging from communication library in Javascript console.")
+
+	flagIsolatedGoPath = flag.Bool("isolated_gopath", false, "Give each notebook its own GOPATH, GOBIN and module cache, under its temporary work directory, so they don't collide between notebooks running concurrently.")
+	flagNoUpdateCheck  = flag.Bool("no_update_check", false, "Don't check whether a newer release of GoNB is available when the kernel starts.")
+
+	flagDockerInstall = flag.Bool("docker_install", false, "Generate a Dockerfile and kernel configuration (kernelspec) to run GoNB inside a Jupyter docker, in the directory given by --docker_dir.")
+	flagDockerDir     = flag.String("docker_dir", "gonb_docker", "Directory where --docker_install writes the Dockerfile and kernel configuration.")
+	flagDockerBase    = flag.String("docker_base", kernel.DefaultDockerBaseImage, "Base image of the Dockerfile generated by --docker_install: one of the Jupyter Docker Stacks, or an Alpine Linux image (if the name contains \"alpine\").")
 )
 
 var (
 	// UniqueID uniquely identifies a kernel execution. Used to create the temporary
 	// directory holding the kernel code, and for logging.
-	// Set by SetUpLogging.
+	// Set by init.
 	UniqueID string
 
 	coloredUniqueID string
-
-	logWriter io.Writer
 )
 
 func init() {
@@ -49,28 +56,55 @@ func main() {
 	klog.InitFlags(nil)
 	defer klog.Flush()
 
+	// Sub-commands, with their own flags.
+	for name, subCommand := range map[string]func(args []string) error{
+		NbConvertCommand: runNbConvert,
+		RunCommand:       runNotebook,
+		ReplayCommand:    runReplay,
+	} {
+		if isSubCommand(name) {
+			if err := subCommand(os.Args[2:]); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s failed: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
-	// Setup logging.
+	// Setup logging: to STDERR (if not disabled), to --extra_log, if given, and to the session log, when
+	// running as a kernel. If none is enabled, logging.SetUp falls back to STDERR.
+	var logWriters []io.Writer
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "logtostderr" || f.Name == "alsologtostderr" {
+			if f.Value.String() == "true" {
+				if len(logWriters) == 0 {
+					logWriters = append(logWriters, os.Stderr)
+				}
+				_ = f.Value.Set("false")
+			}
+		}
+	})
 	if *flagExtraLog != "" {
 		logFile, err := os.OpenFile(*flagExtraLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
 		if err != nil {
 			klog.Fatalf("Failed to open log file %q for writing: %+v", *flagExtraLog, err)
 		}
 		_, _ = fmt.Fprintf(logFile, "\n\nLogging for %q (pid=%d) starting at %s\n\n", os.Args[0], os.Getpid(), time.Now())
-		logWriter = logFile
-		flag.VisitAll(func(f *flag.Flag) {
-			if f.Name == "logtostderr" || f.Name == "alsologtostderr" {
-				if f.Value.String() == "true" {
-					logWriter = io.MultiWriter(logFile, os.Stderr) // Write to STDERR and the newly open logFile.
-					_ = f.Value.Set("false")
-				}
-			}
-		})
+		logWriters = append(logWriters, logFile)
 		defer func() { _ = logFile.Close() }()
 	}
-	SetUpLogging() // "log" package.
-	SetUpKlog()    // "github.com/golang/klog" package
+	if *flagKernel != "" {
+		sessionLog, err := logging.Open(logging.SessionLogPath(*flagLogDir, os.Getenv(goexec.JupyterSessionNameEnv), UniqueID))
+		if err != nil {
+			klog.Warningf("Session log disabled: %+v", err)
+		} else {
+			logWriters = append(logWriters, sessionLog)
+			defer func() { _ = sessionLog.Close() }()
+		}
+	}
+	logging.SetUp(coloredUniqueID, logWriters...)
 
 	if *flagInstall {
 		// Install kernel in Jupyter configuration.
@@ -78,33 +112,28 @@ func main() {
 		if *flagExtraLog != "" {
 			extraArgs = []string{"--extra_log", *flagExtraLog}
 		}
-		if glogFlag := flag.Lookup("vmodule"); glogFlag != nil && glogFlag.Value.String() != "" {
-			extraArgs = append(extraArgs, fmt.Sprintf("--vmodule=%s", glogFlag.Value.String()))
-		}
-		if glogFlag := flag.Lookup("logtostderr"); glogFlag != nil && glogFlag.Value.String() != "false" {
-			extraArgs = append(extraArgs, "--logtostderr")
-		}
-		if glogFlag := flag.Lookup("alsologtostderr"); glogFlag != nil && glogFlag.Value.String() != "false" {
-			extraArgs = append(extraArgs, "--alsologtostderr")
-		}
-		if glogFlag := flag.Lookup("raw_error"); glogFlag != nil && glogFlag.Value.String() != "false" {
-			extraArgs = append(extraArgs, "--raw_error")
-		}
-		if glogFlag := flag.Lookup("work"); glogFlag != nil && glogFlag.Value.String() != "false" {
-			extraArgs = append(extraArgs, "--work")
-		}
-		if glogFlag := flag.Lookup("comms_log"); glogFlag != nil && glogFlag.Value.String() != "false" {
-			extraArgs = append(extraArgs, "--comms_log")
+		if *flagLogDir != "" {
+			extraArgs = append(extraArgs, "--session_log_dir", *flagLogDir)
 		}
+		extraArgs = append(extraArgs, kernelFlags()...)
 		err := kernel.Install(extraArgs, *flagForceDeps, *flagForceCopy)
 		if err != nil {
-			log.Fatalf("Installation failed: %+v\n", err)
+			klog.Fatalf("Installation failed: %+v\n", err)
+		}
+		return
+	}
+
+	if *flagDockerInstall {
+		// The log files are not propagated, since they would refer to paths of the host.
+		err := kernel.DockerInstall(*flagDockerDir, *flagDockerBase, goexec.CurrentVersion(), kernelFlags())
+		if err != nil {
+			klog.Fatalf("Docker installation failed: %+v\n", err)
 		}
 		return
 	}
 
 	if *flagKernel == "" {
-		_, _ = fmt.Fprintf(os.Stderr, "Use either --install to install the kernel, or if started by Jupyter the flag --kernel must be provided.\n")
+		_, _ = fmt.Fprintf(os.Stderr, "Use either --install to install the kernel, --docker_install to generate a Dockerfile with it, `%s nbconvert` to convert a notebook to Go, `%[1]s run` to execute a notebook, or if started by Jupyter the flag --kernel must be provided.\n", os.Args[0])
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -121,18 +150,30 @@ func main() {
 
 	// Create a kernel.
 	k, err := kernel.New(*flagKernel)
-	klog.Infof("kernel created\n")
 	if err != nil {
-		log.Fatalf("Failed to start kernel: %+v", err)
+		klog.Fatalf("Failed to start kernel: %+v", err)
+	}
+	if sessionLog := logging.Current(); sessionLog != nil {
+		klog.InfoS("Kernel created", "pid", os.Getpid(), "session_log", sessionLog.Path())
+	} else {
+		klog.InfoS("Kernel created", "pid", os.Getpid())
 	}
 	k.HandleInterrupt() // Handle Jupyter interruptions and Control+C.
 
 	// Create a Go executor.
 	goExec, err := goexec.New(k, UniqueID, *flagWork, *flagRawError)
 	if err != nil {
-		log.Fatalf("Failed to create go executor: %+v", err)
+		klog.Fatalf("Failed to create go executor: %+v", err)
 	}
 	goExec.Comms.LogWebSocket = *flagCommsLog
+	if *flagIsolatedGoPath {
+		if err = goExec.SetIsolatedGoPath(true); err != nil {
+			klog.Fatalf("Failed to set isolated GOPATH: %+v", err)
+		}
+	}
+	if !*flagNoUpdateCheck {
+		goExec.StartUpdateCheck()
+	}
 
 	// Orchestrate dispatching of messages.
 	dispatcher.RunKernel(k, goExec)
@@ -145,8 +186,9 @@ func main() {
 	}
 	klog.V(1).Infof("goExec stopped.")
 
-	// Wait for all polling goroutines.
+	// Wait for all polling goroutines, and for the traces being exported.
 	k.ExitWait()
+	tracing.Shutdown()
 	klog.Infof("Exiting...")
 }
 
@@ -156,47 +198,32 @@ var (
 	ColorBgYellow = "\033[7;39;32m"
 )
 
-// SetUpLogging creates a UniqueID, uses it as a prefix for logging, and sets up --extra_log if
-// requested.
-func SetUpLogging() {
-	log.SetPrefix(coloredUniqueID)
-	if logWriter != nil {
-		log.SetOutput(logWriter)
+// kernelFlags returns the flags given to `gonb --install` (or `--docker_install`) that are passed along
+// to the kernel, except the log files.
+func kernelFlags() (args []string) {
+	if glogFlag := flag.Lookup("vmodule"); glogFlag != nil && glogFlag.Value.String() != "" {
+		args = append(args, fmt.Sprintf("--vmodule=%s", glogFlag.Value.String()))
 	}
-}
-
-// UniqueIDFilter prepends the UniqueID for every log line.
-type UniqueIDFilter struct{}
-
-// prepend value to slice: it makes a copy of the slice.
-func prepend[T any](slice []T, value T) []T {
-	newSlice := make([]T, len(slice)+1)
-	if len(slice) > 0 {
-		copy(newSlice[1:], slice)
+	if glogFlag := flag.Lookup("logtostderr"); glogFlag != nil && glogFlag.Value.String() != "false" {
+		args = append(args, "--logtostderr")
 	}
-	newSlice[0] = value
-	return newSlice
-}
-
-// Filter implements klog.LogFilter interface.
-func (_ UniqueIDFilter) Filter(args []interface{}) []interface{} {
-	return prepend(args, any(coloredUniqueID))
-}
-
-// FilterF implements klog.LogFilter interface.
-func (_ UniqueIDFilter) FilterF(format string, args []interface{}) (string, []interface{}) {
-	return "%s" + format, prepend(args, any(coloredUniqueID))
-}
-
-// FilterS implements klog.LogFilter interface.
-func (_ UniqueIDFilter) FilterS(msg string, keysAndValues []interface{}) (string, []interface{}) {
-	return coloredUniqueID + msg, keysAndValues
-}
-
-// SetUpKlog to include prefix with kernel's UniqueID.
-func SetUpKlog() {
-	if logWriter != nil {
-		klog.SetOutput(logWriter)
+	if glogFlag := flag.Lookup("alsologtostderr"); glogFlag != nil && glogFlag.Value.String() != "false" {
+		args = append(args, "--alsologtostderr")
+	}
+	if glogFlag := flag.Lookup("raw_error"); glogFlag != nil && glogFlag.Value.String() != "false" {
+		args = append(args, "--raw_error")
+	}
+	if glogFlag := flag.Lookup("work"); glogFlag != nil && glogFlag.Value.String() != "false" {
+		args = append(args, "--work")
+	}
+	if glogFlag := flag.Lookup("comms_log"); glogFlag != nil && glogFlag.Value.String() != "false" {
+		args = append(args, "--comms_log")
+	}
+	if *flagIsolatedGoPath {
+		args = append(args, "--isolated_gopath")
+	}
+	if *flagNoUpdateCheck {
+		args = append(args, "--no_update_check")
 	}
-	klog.SetLogFilter(UniqueIDFilter{})
+	return
 }
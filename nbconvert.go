@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/pkg/errors"
+)
+
+// NbConvertCommand is the sub-command that converts a notebook to a Go program, see runNbConvert.
+const NbConvertCommand = "nbconvert"
+
+// runNbConvert implements `gonb nbconvert <notebook.ipynb> [-o main.go] [--module=<path>]`: it converts
+// the code cells of the notebook to a formatted Go program, outside Jupyter. With --module, the output
+// is a directory with the program and a `go.mod`, with its dependencies resolved by `go mod tidy`.
+func runNbConvert(args []string) error {
+	flagSet := flag.NewFlagSet(NbConvertCommand, flag.ExitOnError)
+	output := flagSet.String("o", "", "Output Go file. If empty, the program is written to the standard output. "+
+		"With --module it is the output directory.")
+	module := flagSet.String("module", "", "If set, creates a Go module with the given module path, in the "+
+		"directory given by -o, with the program in `main.go`.")
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprintf(flagSet.Output(), "Usage: %s %s <notebook.ipynb> [-o main.go] [--module=<path>]\n\n"+
+			"Converts the code cells of the notebook to a Go program.\n\n", os.Args[0], NbConvertCommand)
+		flagSet.PrintDefaults()
+	}
+	// Flags may come before or after the notebook.
+	_ = flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return errors.New("missing notebook to convert")
+	}
+	notebookPath := flagSet.Arg(0)
+	_ = flagSet.Parse(flagSet.Args()[1:])
+	if flagSet.NArg() > 0 {
+		return errors.Errorf("unexpected arguments %q", flagSet.Args())
+	}
+	if *module != "" && *output == "" {
+		return errors.New("--module requires the output directory, set with -o")
+	}
+
+	cells, err := goexec.ReadNotebookCells(notebookPath)
+	if err != nil {
+		return err
+	}
+	code, warnings, err := goexec.ConvertNotebook(cells)
+	for _, warning := range warnings {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", notebookPath, warning)
+	}
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = fmt.Print(code)
+		return err
+	}
+	if *module == "" {
+		return errors.Wrapf(os.WriteFile(*output, []byte(code), 0644), "failed to write %q", *output)
+	}
+
+	if err = os.MkdirAll(*output, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %q", *output)
+	}
+	mainPath := path.Join(*output, "main.go")
+	if err = os.WriteFile(mainPath, []byte(code), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %q", mainPath)
+	}
+	goModArgs := [][]string{{"mod", "tidy"}}
+	if _, err = os.Stat(path.Join(*output, "go.mod")); os.IsNotExist(err) {
+		goModArgs = append([][]string{{"mod", "init", *module}}, goModArgs...)
+	}
+	for _, goArgs := range goModArgs {
+		cmd := exec.Command("go", goArgs...)
+		cmd.Dir = *output
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "failed to run `go %s`:\n%s", strings.Join(goArgs, " "), out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/dispatcher"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+)
+
+// ReplayCommand is the sub-command that replays a session recorded with `%record`, see runReplay.
+const ReplayCommand = "replay"
+
+// runReplay implements `gonb replay <session.jsonl> [--out notebook.ipynb] [--speed 1]`: it re-emits the
+// cells recorded and their outputs, with the original timing, and saves them in a fresh notebook.
+func runReplay(args []string) error {
+	flagSet := flag.NewFlagSet(ReplayCommand, flag.ExitOnError)
+	out := flagSet.String("out", "", "Where to save the notebook with the cells replayed. "+
+		"Defaults to the session file with the extension `.ipynb`.")
+	speed := flagSet.Float64("speed", 1, "Speed of the replay, relative to the original timing. "+
+		"Use 0 to replay without delays.")
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprintf(flagSet.Output(), "Usage: %s %s <session.jsonl> [--out notebook.ipynb] [--speed 1]\n\n"+
+			"Replays a session recorded with `%%record start`.\n\n", os.Args[0], ReplayCommand)
+		flagSet.PrintDefaults()
+	}
+	// Flags may come before or after the session file.
+	_ = flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return errors.New("missing session file to replay")
+	}
+	sessionPath := flagSet.Arg(0)
+	_ = flagSet.Parse(flagSet.Args()[1:])
+	if flagSet.NArg() > 0 {
+		return errors.Errorf("unexpected arguments %q", flagSet.Args())
+	}
+	if *speed < 0 {
+		return errors.Errorf("invalid --speed=%g, it must be >= 0", *speed)
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(sessionPath, filepath.Ext(sessionPath)) + ".ipynb"
+	}
+
+	executions, err := kernel.ReadSession(sessionPath)
+	if err != nil {
+		return err
+	}
+	nb, err := dispatcher.ReplaySession(executions, *speed,
+		func(cellIdx int, source string) {
+			fmt.Printf("%sIn [%d]:%s\n%s\n\n", ColorBgYellow, cellIdx+1, ColorReset, strings.TrimRight(source, "\n"))
+		},
+		func(output map[string]any) {
+			switch output["output_type"] {
+			case "stream":
+				echoStreamOutput(output)
+			case "error":
+				traceback, _ := output["traceback"].([]any)
+				for _, line := range traceback {
+					_, _ = fmt.Fprintln(os.Stderr, line)
+				}
+			default:
+				data, _ := output["data"].(map[string]any)
+				if text, ok := data["text/plain"].(string); ok {
+					fmt.Println(text)
+				} else if len(data) > 0 {
+					fmt.Printf("[%s: %s]\n", output["output_type"], strings.Join(common.SortedKeys(data), ", "))
+				}
+			}
+		})
+	if err != nil {
+		return err
+	}
+	if err = nb.Write(*out); err != nil {
+		return err
+	}
+	fmt.Printf("\nNotebook with %d cells saved in %q.\n", len(executions), *out)
+	return nil
+}
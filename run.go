@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/dispatcher"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/tracing"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// RunCommand is the sub-command that executes a notebook without Jupyter, see runNotebook.
+const RunCommand = "run"
+
+// runNotebook implements `gonb run <notebook.ipynb> [--param <name>=<value>...] [--out executed.ipynb]`: it
+// executes all the code cells of the notebook, without a Jupyter server, and saves the executed notebook
+// with the outputs. The text outputs and errors are also printed, for the logs of scheduled or CI runs.
+func runNotebook(args []string) error {
+	flagSet := flag.NewFlagSet(RunCommand, flag.ExitOnError)
+	var params common.ArrayFlag
+	flagSet.Var(&params, "param", "Parameter `<name>=<value>` (can be set multiple times): either declared in a "+
+		"cell with `// gonb:param <name> <type> = <default>`, or injected after the cell tagged \"parameters\", "+
+		"where the parameter must be declared as a `const` or `var`.")
+	out := flagSet.String("out", "", "Where to save the executed notebook. If empty, it is not saved.")
+	work := flagSet.Bool("work", false, "Print name of temporary work directory and preserve it at exit.")
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprintf(flagSet.Output(), "Usage: %s %s <notebook.ipynb> [--param <name>=<value>...] "+
+			"[--out executed.ipynb]\n\nExecutes the notebook without Jupyter.\n\n", os.Args[0], RunCommand)
+		flagSet.PrintDefaults()
+	}
+	// Flags may come before or after the notebook.
+	_ = flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return errors.New("missing notebook to run")
+	}
+	notebookPath := flagSet.Arg(0)
+	_ = flagSet.Parse(flagSet.Args()[1:])
+	if flagSet.NArg() > 0 {
+		return errors.Errorf("unexpected arguments %q", flagSet.Args())
+	}
+	values, err := goexec.ParseNotebookParameterFlags(params)
+	if err != nil {
+		return err
+	}
+	nb, err := dispatcher.ReadNotebook(notebookPath)
+	if err != nil {
+		return err
+	}
+
+	// Only errors are logged to the terminal, since it has the outputs of the notebook.
+	_ = flag.Set("logtostderr", "false")
+	_ = flag.Set("stderrthreshold", "ERROR")
+	klog.SetOutput(io.Discard)
+
+	k := kernel.NewHeadless()
+	k.HandleInterrupt()
+	goExec, err := goexec.New(k, UniqueID, *work, false)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to create go executor")
+	}
+	defer func() {
+		k.Stop()
+		if err := goExec.Stop(); err != nil {
+			klog.Warningf("Error during shutdown: %+v", err)
+		}
+		tracing.Shutdown()
+	}()
+
+	runErr := dispatcher.RunNotebook(k, goExec, nb, values, func(_ int, output map[string]any) {
+		echoStreamOutput(output)
+	})
+	if *out != "" {
+		if err = nb.Write(*out); err != nil {
+			return err
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+	return nil
+}
+
+// echoStreamOutput prints the output of a cell to the terminal, if it is a "stream" output.
+func echoStreamOutput(output map[string]any) {
+	if output["output_type"] != "stream" {
+		return
+	}
+	if output["name"] == kernel.StreamStderr {
+		_, _ = fmt.Fprint(os.Stderr, output["text"])
+	} else {
+		_, _ = fmt.Print(output["text"])
+	}
+}
+
+// isSubCommand returns whether the first argument is the given sub-command (as opposed to a flag).
+func isSubCommand(name string) bool {
+	return len(os.Args) > 1 && strings.TrimSpace(os.Args[1]) == name
+}